@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// APIKeys accepts requests carrying one of a fixed set of keys, sent either
+// as a bearer token or in the X-API-Key header
+type APIKeys struct {
+	// keys are compared by SHA-256 digest so every comparison takes the same time
+	keys map[[sha256.Size]byte]string
+}
+
+// NewAPIKeys creates an authenticator from a key to name map
+func NewAPIKeys(keys map[string]string) (*APIKeys, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("apikey auth needs at least one key")
+	}
+	a := &APIKeys{keys: make(map[[sha256.Size]byte]string, len(keys))}
+	for key, name := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("api key for %q cannot be empty", name)
+		}
+		a.keys[sha256.Sum256([]byte(key))] = name
+	}
+	return a, nil
+}
+
+// Authenticate implements Authenticator
+func (a *APIKeys) Authenticate(r *http.Request) (Identity, error) {
+	key, ok := bearerToken(r)
+	if !ok {
+		key = r.Header.Get("X-API-Key")
+	}
+	if key == "" {
+		return Identity{}, fmt.Errorf("%w: missing api key", ErrUnauthenticated)
+	}
+	sum := sha256.Sum256([]byte(key))
+	for digest, name := range a.keys {
+		if subtle.ConstantTimeCompare(sum[:], digest[:]) == 1 {
+			return Identity{Subject: name, Method: "apikey"}, nil
+		}
+	}
+	return Identity{}, fmt.Errorf("%w: unknown api key", ErrUnauthenticated)
+}
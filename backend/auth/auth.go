@@ -0,0 +1,133 @@
+// Package auth authenticates API requests. The server selects one
+// Authenticator by name at startup; deployments that need something else can
+// compile in their own with Register instead of patching the handlers.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Identity describes who made a request
+type Identity struct {
+	// Subject names the caller: an API key's name, a token's sub claim, or a
+	// client certificate's common name
+	Subject string
+	// Method is the name of the authenticator that accepted the request
+	Method string
+}
+
+// Authenticator decides who a request comes from. It returns an error
+// wrapping ErrUnauthenticated when the request has no valid credentials, and
+// ErrUnavailable when they can't be checked right now.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+var (
+	// ErrUnauthenticated means the request must be rejected with 401
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrUnavailable means credentials could not be checked, e.g. an identity
+	// provider is unreachable; the request should be retried later
+	ErrUnavailable = errors.New("authentication unavailable")
+)
+
+// Config selects and configures an authenticator
+type Config struct {
+	// Method is none (the default), apikey, oidc, mtls, or a name passed to Register
+	Method string
+	// APIKeys maps each accepted key to the name it is logged under (apikey)
+	APIKeys map[string]string
+	// OIDCIssuer is the issuer URL tokens must come from (oidc)
+	OIDCIssuer string
+	// OIDCAudience must appear in each token's aud claim (oidc)
+	OIDCAudience string
+	// MTLSAllowedSubjects limits which certificate common names are accepted;
+	// empty accepts any certificate that chains to the client CA (mtls)
+	MTLSAllowedSubjects []string
+}
+
+// Factory builds an authenticator from config
+type Factory func(config Config) (Authenticator, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{
+		"none":   func(Config) (Authenticator, error) { return None{}, nil },
+		"apikey": func(c Config) (Authenticator, error) { return NewAPIKeys(c.APIKeys) },
+		"oidc":   func(c Config) (Authenticator, error) { return NewOIDC(c.OIDCIssuer, c.OIDCAudience, nil) },
+		"mtls":   func(c Config) (Authenticator, error) { return NewMTLS(c.MTLSAllowedSubjects), nil },
+	}
+)
+
+// Register makes a custom authenticator available under name, typically from
+// an init function in a package linked into the server. It panics if name is
+// already taken, so two builds can't silently disagree about what a name means.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("auth: authenticator %q registered twice", name))
+	}
+	factories[name] = factory
+}
+
+// Methods lists the available authenticator names in alphabetical order
+func Methods() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the authenticator named by config.Method
+func New(config Config) (Authenticator, error) {
+	method := config.Method
+	if method == "" {
+		method = "none"
+	}
+	factoriesMu.RLock()
+	factory, ok := factories[method]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown auth method %q (available: %s)", method, strings.Join(Methods(), ", "))
+	}
+	return factory(config)
+}
+
+// None accepts every request as anonymous
+type None struct{}
+
+// Authenticate implements Authenticator
+func (None) Authenticate(*http.Request) (Identity, error) {
+	return Identity{Subject: "anonymous", Method: "none"}, nil
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a copy of ctx carrying id
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the identity stored by WithIdentity
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(Identity)
+	return id, ok
+}
+
+// bearerToken returns the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	return token, ok && token != ""
+}
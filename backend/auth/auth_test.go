@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	a, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if id, err := a.Authenticate(httptest.NewRequest("GET", "/", nil)); err != nil || id.Method != "none" {
+		t.Errorf("Expected anonymous identity by default, got %+v, %v", id, err)
+	}
+
+	if _, err := New(Config{Method: "kerberos"}); err == nil {
+		t.Error("Expected unknown method to fail")
+	}
+	if _, err := New(Config{Method: "apikey"}); err == nil {
+		t.Error("Expected apikey without keys to fail")
+	}
+	if _, err := New(Config{Method: "oidc", OIDCIssuer: "https://id.example.com"}); err == nil {
+		t.Error("Expected oidc without an audience to fail")
+	}
+
+	// Custom authenticators are selected by name like the built-in ones
+	Register("test-header", func(Config) (Authenticator, error) { return headerAuth{}, nil })
+	a, err = New(Config{Method: "test-header"})
+	if err != nil {
+		t.Fatalf("New failed for registered method: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-User", "ada")
+	if id, err := a.Authenticate(req); err != nil || id.Subject != "ada" {
+		t.Errorf("Expected custom identity, got %+v, %v", id, err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a taken name to panic")
+		}
+	}()
+	Register("apikey", func(Config) (Authenticator, error) { return None{}, nil })
+}
+
+type headerAuth struct{}
+
+func (headerAuth) Authenticate(r *http.Request) (Identity, error) {
+	return Identity{Subject: r.Header.Get("X-User"), Method: "test-header"}, nil
+}
+
+func TestAPIKeys(t *testing.T) {
+	a, err := NewAPIKeys(map[string]string{"key-ci": "ci", "key-ops": "ops"})
+	if err != nil {
+		t.Fatalf("NewAPIKeys failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		value   string
+		subject string
+	}{
+		{"bearer", "Authorization", "Bearer key-ci", "ci"},
+		{"header", "X-API-Key", "key-ops", "ops"},
+		{"unknown", "Authorization", "Bearer key-nope", ""},
+		{"missing", "", "", ""},
+		{"empty bearer", "Authorization", "Bearer ", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/prompts", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		id, err := a.Authenticate(req)
+		if tt.subject == "" {
+			if !errors.Is(err, ErrUnauthenticated) {
+				t.Errorf("%s: expected ErrUnauthenticated, got %+v, %v", tt.name, id, err)
+			}
+			continue
+		}
+		if err != nil || id.Subject != tt.subject || id.Method != "apikey" {
+			t.Errorf("%s: expected subject %q, got %+v, %v", tt.name, tt.subject, id, err)
+		}
+	}
+}
+
+func TestMTLS(t *testing.T) {
+	withCert := func(cn string) *http.Request {
+		req := httptest.NewRequest("GET", "/api/prompts", nil)
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return req
+	}
+
+	open := NewMTLS(nil)
+	if id, err := open.Authenticate(withCert("deploy-bot")); err != nil || id.Subject != "deploy-bot" {
+		t.Errorf("Expected certificate subject, got %+v, %v", id, err)
+	}
+	if _, err := open.Authenticate(httptest.NewRequest("GET", "/api/prompts", nil)); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected plain HTTP request to be rejected, got %v", err)
+	}
+	// A certificate the TLS layer could not verify has no chains
+	unverified := httptest.NewRequest("GET", "/api/prompts", nil)
+	unverified.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "x"}}}}
+	if _, err := open.Authenticate(unverified); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected unverified certificate to be rejected, got %v", err)
+	}
+
+	restricted := NewMTLS([]string{"deploy-bot"})
+	if _, err := restricted.Authenticate(withCert("deploy-bot")); err != nil {
+		t.Errorf("Expected allowed subject to pass, got %v", err)
+	}
+	if _, err := restricted.Authenticate(withCert("intern-laptop")); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected other subject to be rejected, got %v", err)
+	}
+}
+
+// testProvider serves a discovery document and key set for signing test tokens
+type testProvider struct {
+	server *httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+	hits   int
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testProvider{rsaKey: rsaKey, ecKey: ecKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": p.server.URL, "jwks_uri": p.server.URL + "/keys"})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		p.hits++
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kid": "rsa-1", "kty": "RSA", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kid": "ec-1", "kty": "EC", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		}})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testProvider) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, p.rsaKey, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, p.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDC(t *testing.T) {
+	p := newTestProvider(t)
+	o, err := NewOIDC(p.server.URL, "prompt-registry", nil)
+	if err != nil {
+		t.Fatalf("NewOIDC failed: %v", err)
+	}
+	now := time.Now()
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"iss": p.server.URL,
+			"sub": "user-42",
+			"aud": []string{"other-app", "prompt-registry"},
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+	authenticate := func(token string) (Identity, error) {
+		req := httptest.NewRequest("GET", "/api/prompts", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return o.Authenticate(req)
+	}
+
+	for _, alg := range []struct{ alg, kid string }{{"RS256", "rsa-1"}, {"ES256", "ec-1"}} {
+		id, err := authenticate(p.sign(t, alg.alg, alg.kid, claims(nil)))
+		if err != nil || id.Subject != "user-42" || id.Method != "oidc" {
+			t.Errorf("%s: expected valid token to pass, got %+v, %v", alg.alg, id, err)
+		}
+	}
+	if p.hits != 1 {
+		t.Errorf("Expected keys to be fetched once and cached, got %d fetches", p.hits)
+	}
+
+	rejected := map[string]string{
+		"wrong issuer":   p.sign(t, "RS256", "rsa-1", claims(map[string]any{"iss": "https://evil.example.com"})),
+		"wrong audience": p.sign(t, "RS256", "rsa-1", claims(map[string]any{"aud": "other-app"})),
+		"expired":        p.sign(t, "RS256", "rsa-1", claims(map[string]any{"exp": now.Add(-time.Hour).Unix()})),
+		"not yet valid":  p.sign(t, "RS256", "rsa-1", claims(map[string]any{"nbf": now.Add(time.Hour).Unix()})),
+		"no expiry":      p.sign(t, "RS256", "rsa-1", claims(map[string]any{"exp": nil})),
+		"alg mismatch":   p.sign(t, "ES256", "rsa-1", claims(nil)),
+		"malformed":      "not-a-jwt",
+	}
+	tampered := p.sign(t, "RS256", "rsa-1", claims(nil))
+	rejected["tampered"] = tampered[:len(tampered)-4] + "AAAA"
+	for name, token := range rejected {
+		if _, err := authenticate(token); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("%s: expected ErrUnauthenticated, got %v", name, err)
+		}
+	}
+
+	// An unknown key id refreshes the key set, but at most once per interval
+	before := p.hits
+	for range 3 {
+		if _, err := authenticate(p.sign(t, "RS256", "rotated", claims(nil))); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Expected unknown key to be rejected, got %v", err)
+		}
+	}
+	if p.hits-before > 1 {
+		t.Errorf("Expected at most one key refresh for unknown keys, got %d", p.hits-before)
+	}
+
+	// An unreachable provider is reported as unavailable, not as bad credentials
+	down, _ := NewOIDC("http://127.0.0.1:1", "prompt-registry", nil)
+	req := httptest.NewRequest("GET", "/api/prompts", nil)
+	req.Header.Set("Authorization", "Bearer "+p.sign(t, "RS256", "rsa-1", claims(nil)))
+	if _, err := down.Authenticate(req); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+}
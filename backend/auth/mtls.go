@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// MTLS accepts requests whose client certificate the TLS layer has verified
+// against the configured client CA. The server must request client
+// certificates for this to see any.
+type MTLS struct {
+	allowed map[string]bool
+}
+
+// NewMTLS creates an authenticator; an empty subjects list accepts any
+// verified certificate
+func NewMTLS(subjects []string) *MTLS {
+	m := &MTLS{}
+	if len(subjects) > 0 {
+		m.allowed = make(map[string]bool, len(subjects))
+		for _, s := range subjects {
+			m.allowed[s] = true
+		}
+	}
+	return m
+}
+
+// Authenticate implements Authenticator
+func (m *MTLS) Authenticate(r *http.Request) (Identity, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Identity{}, fmt.Errorf("%w: no verified client certificate", ErrUnauthenticated)
+	}
+	subject := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if m.allowed != nil && !m.allowed[subject] {
+		return Identity{}, fmt.Errorf("%w: certificate subject %q is not allowed", ErrUnauthenticated, subject)
+	}
+	return Identity{Subject: subject, Method: "mtls"}, nil
+}
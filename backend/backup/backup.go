@@ -0,0 +1,249 @@
+// Package backup snapshots the registry database on a cron schedule and keeps
+// the most recent snapshots in a directory.
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+const (
+	filePrefix = "prompts-"
+	fileExt    = ".db"
+	// fileTime names backups so they sort chronologically
+	fileTime = "20060102T150405Z"
+)
+
+// DefaultKeep is the number of backups retained when Config.Keep is unset
+const DefaultKeep = 7
+
+// Config selects where and how often to back up
+type Config struct {
+	// Dir receives the backup files; it is created if missing
+	Dir string
+	// Schedule is a cron expression, see ParseSchedule
+	Schedule string
+	// Keep is how many of the newest backups to retain
+	Keep int
+}
+
+// Scheduler takes a backup whenever its schedule fires, then deletes all but
+// the newest Keep backups. Only one backup runs at a time.
+type Scheduler struct {
+	config   Config
+	schedule *Schedule
+	store    store.Store
+	logger   *slog.Logger
+	now      func() time.Time
+
+	run  sync.Mutex // held while a backup is being written
+	stop chan struct{}
+	done chan struct{}
+
+	mu            sync.Mutex
+	lastFile      string
+	lastSize      int64
+	lastSuccessAt time.Time
+	lastFailureAt time.Time
+	lastError     string
+	nextRunAt     time.Time
+}
+
+// New validates config and creates a Scheduler; call Start to begin
+func New(config Config, s store.Store, logger *slog.Logger) (*Scheduler, error) {
+	if config.Dir == "" {
+		return nil, errors.New("backup directory cannot be empty")
+	}
+	if config.Keep == 0 {
+		config.Keep = DefaultKeep
+	}
+	if config.Keep < 1 {
+		return nil, fmt.Errorf("invalid backup retention %d: must keep at least 1", config.Keep)
+	}
+	schedule, err := ParseSchedule(config.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	return &Scheduler{
+		config:   config,
+		schedule: schedule,
+		store:    s,
+		logger:   logger,
+		now:      time.Now,
+	}, nil
+}
+
+// Start runs backups in the background until Stop is called
+func (b *Scheduler) Start() {
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+	go b.loop()
+}
+
+// Stop ends the schedule, waiting for a running backup to finish
+func (b *Scheduler) Stop() {
+	if b.stop == nil {
+		return
+	}
+	close(b.stop)
+	<-b.done
+}
+
+func (b *Scheduler) loop() {
+	defer close(b.done)
+	for {
+		next := b.schedule.Next(b.now())
+		b.mu.Lock()
+		b.nextRunAt = next
+		b.mu.Unlock()
+		if next.IsZero() {
+			b.logger.Error("backup schedule never fires", "schedule", b.schedule.String())
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-b.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		// Failures are recorded in the status and logged; the schedule carries on
+		b.Run()
+	}
+}
+
+// Run takes a backup now and prunes old ones, returning the new file's path
+func (b *Scheduler) Run() (string, error) {
+	b.run.Lock()
+	defer b.run.Unlock()
+
+	start := time.Now()
+	path, size, err := b.backup()
+	if err == nil {
+		err = b.prune()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		b.lastFailureAt = b.now().UTC()
+		b.lastError = err.Error()
+		b.logger.Error("backup failed", "error", err, "dir", b.config.Dir)
+		return path, err
+	}
+	b.lastFile, b.lastSize = path, size
+	b.lastSuccessAt = b.now().UTC()
+	b.logger.Info("backup completed",
+		"file", path,
+		"size_bytes", size,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+	return path, nil
+}
+
+// backup writes a snapshot under a temporary name and renames it into place,
+// so a crash mid-backup never leaves a truncated file that looks complete
+func (b *Scheduler) backup() (string, int64, error) {
+	if err := os.MkdirAll(b.config.Dir, 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	name := filePrefix + b.now().UTC().Format(fileTime) + fileExt
+	path := filepath.Join(b.config.Dir, name)
+	tmp := filepath.Join(b.config.Dir, "."+name+".tmp")
+	os.Remove(tmp)
+
+	if err := b.store.Backup(tmp); err != nil {
+		os.Remove(tmp)
+		return "", 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", 0, fmt.Errorf("failed to move backup into place: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return path, 0, fmt.Errorf("failed to stat backup: %w", err)
+	}
+	return path, info.Size(), nil
+}
+
+// prune deletes backups beyond the newest Keep. Other files in the directory
+// are left alone.
+func (b *Scheduler) prune() error {
+	backups, err := List(b.config.Dir)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(backups)-b.config.Keep; i++ {
+		if err := os.Remove(backups[i]); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		b.logger.Info("old backup removed", "file", backups[i])
+	}
+	return nil
+}
+
+// Dir returns the directory backups are written to
+func (b *Scheduler) Dir() string {
+	return b.config.Dir
+}
+
+// List returns the backup files in dir, oldest first
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileExt) {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Status reports the schedule and the outcome of recent backups
+func (b *Scheduler) Status() models.BackupStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := models.BackupStatus{
+		Status:        "pending",
+		Schedule:      b.schedule.String(),
+		Keep:          b.config.Keep,
+		LastFile:      b.lastFile,
+		LastSizeBytes: b.lastSize,
+		LastError:     b.lastError,
+	}
+	if !b.lastSuccessAt.IsZero() {
+		at := b.lastSuccessAt
+		status.LastSuccessAt = &at
+		status.Status = "ok"
+	}
+	if !b.lastFailureAt.IsZero() {
+		at := b.lastFailureAt
+		status.LastFailureAt = &at
+		if b.lastFailureAt.After(b.lastSuccessAt) {
+			status.Status = "failing"
+		}
+	}
+	if !b.nextRunAt.IsZero() {
+		at := b.nextRunAt
+		status.NextRunAt = &at
+	}
+	return status
+}
@@ -0,0 +1,139 @@
+package backup
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2025, 1, 15, 10, 30, 20, 0, time.UTC) // a Wednesday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2025, 1, 16, 3, 0, 0, 0, time.UTC)},
+		{"30 2 1 * *", time.Date(2025, 2, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,20 6 *", time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches
+		{"0 0 1 * 5", time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: expected next run %v, got %v", tt.spec, tt.want, got)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@yearly", "mon * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("Expected ParseSchedule(%q) to fail", spec)
+		}
+	}
+}
+
+func TestSchedulerRetention(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := store.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "kept", Title: "Kept", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	// Unrelated files in the directory are never pruned
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(Config{Dir: dir, Schedule: "@daily", Keep: 2}, s, logger)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if status := b.Status(); status.Status != "pending" || status.Keep != 2 || status.Schedule != "@daily" {
+		t.Errorf("Unexpected initial status: %+v", status)
+	}
+
+	clock := time.Date(2025, 1, 15, 3, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return clock }
+	var paths []string
+	for range 3 {
+		path, err := b.Run()
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		paths = append(paths, path)
+		clock = clock.Add(24 * time.Hour)
+	}
+
+	backups, err := List(dir)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(backups) != 2 || backups[0] != paths[1] || backups[1] != paths[2] {
+		t.Errorf("Expected the two newest backups %v, got %v", paths[1:], backups)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("Expected unrelated file to survive pruning: %v", err)
+	}
+
+	// Each backup is a complete, readable database
+	db, err := sql.Open("sqlite3", paths[2])
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer db.Close()
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM prompts WHERE slug = 'kept'`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("Expected backup to contain the prompt, got %d, %v", count, err)
+	}
+
+	status := b.Status()
+	if status.Status != "ok" || status.LastFile != paths[2] || status.LastSizeBytes == 0 || status.LastSuccessAt == nil {
+		t.Errorf("Unexpected status after backups: %+v", status)
+	}
+
+	// A failed backup is reported until the next success
+	s.Close()
+	if _, err := b.Run(); err == nil {
+		t.Fatal("Expected backup of a closed store to fail")
+	}
+	if status := b.Status(); status.Status != "failing" || status.LastError == "" || status.LastFile != paths[2] {
+		t.Errorf("Unexpected status after failure: %+v", status)
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, config := range []Config{
+		{Schedule: "@daily"},
+		{Dir: "backups", Schedule: "often"},
+		{Dir: "backups", Schedule: "@daily", Keep: -1},
+	} {
+		if _, err := New(config, nil, logger); err == nil {
+			t.Errorf("Expected New(%+v) to fail", config)
+		}
+	}
+}
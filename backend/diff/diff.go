@@ -0,0 +1,229 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpKind identifies the kind of a diff operation
+type OpKind int
+
+const (
+	Equal OpKind = iota
+	Delete
+	Insert
+)
+
+// Op is a single line-level diff operation
+type Op struct {
+	Kind OpKind
+	Text string
+}
+
+// SplitLines splits text into lines, ignoring a single trailing newline
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Lines computes a minimal line diff from a to b using Myers' algorithm
+func Lines(a, b []string) []Op {
+	n, m := len(a), len(b)
+	limit := n + m
+	if limit == 0 {
+		return nil
+	}
+
+	offset := limit
+	v := make([]int, 2*limit+2)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset]
+			} else {
+				x = v[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, offset)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the recorded frontiers from the end to recover the edit script
+func backtrack(a, b []string, trace [][]int, offset int) []Op {
+	x, y := len(a), len(b)
+	var ops []Op
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+offset]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, Op{Kind: Equal, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, Op{Kind: Insert, Text: b[y-1]})
+				y--
+			} else {
+				ops = append(ops, Op{Kind: Delete, Text: a[x-1]})
+				x--
+			}
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// HasChanges reports whether ops contain any insertions or deletions
+func HasChanges(ops []Op) bool {
+	for _, op := range ops {
+		if op.Kind != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Hunk is a run of changed lines with the unchanged lines around them.
+// Starts are 1-based line numbers; an empty side starts at the line before.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Ops                []Op
+}
+
+// Header formats the hunk's unified diff header, e.g. "@@ -1,3 +1,4 @@"
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+// Hunks groups the changes between two texts into hunks with the given
+// context lines. It returns nil when the texts are identical.
+func Hunks(from, to string, context int) []Hunk {
+	ops := Lines(SplitLines(from), SplitLines(to))
+	if !HasChanges(ops) {
+		return nil
+	}
+	context = max(context, 0)
+
+	// Line numbers (1-based) of each op in the old and new text
+	oldLine := make([]int, len(ops)+1)
+	newLine := make([]int, len(ops)+1)
+	oldLine[0], newLine[0] = 1, 1
+	for i, op := range ops {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if op.Kind != Insert {
+			oldLine[i+1]++
+		}
+		if op.Kind != Delete {
+			newLine[i+1]++
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == Equal {
+			i++
+			continue
+		}
+
+		// Extend the hunk until a run of more than 2*context equal lines
+		start := max(0, i-context)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].Kind != Equal {
+				end = j + 1
+				continue
+			}
+			if j-end >= 2*context {
+				break
+			}
+		}
+		end = min(len(ops), end+context)
+
+		hunk := Hunk{OldStart: oldLine[start], NewStart: newLine[start], Ops: ops[start:end]}
+		for _, op := range hunk.Ops {
+			if op.Kind != Insert {
+				hunk.OldLines++
+			}
+			if op.Kind != Delete {
+				hunk.NewLines++
+			}
+		}
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+		hunks = append(hunks, hunk)
+		i = end
+	}
+	return hunks
+}
+
+// Unified renders a unified diff between two texts with the given context lines.
+// It returns an empty string when the texts are identical.
+func Unified(fromName, toName, from, to string, context int) string {
+	hunks := Hunks(from, to, context)
+	if hunks == nil {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for _, hunk := range hunks {
+		out.WriteString(hunk.Header())
+		out.WriteString("\n")
+		for _, op := range hunk.Ops {
+			switch op.Kind {
+			case Equal:
+				out.WriteString(" ")
+			case Delete:
+				out.WriteString("-")
+			case Insert:
+				out.WriteString("+")
+			}
+			out.WriteString(op.Text)
+			out.WriteString("\n")
+		}
+	}
+	return out.String()
+}
+
+// hunkRange formats a unified diff range
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
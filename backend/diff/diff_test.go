@@ -0,0 +1,141 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func apply(ops []Op) (from, to []string) {
+	for _, op := range ops {
+		if op.Kind != Insert {
+			from = append(from, op.Text)
+		}
+		if op.Kind != Delete {
+			to = append(to, op.Text)
+		}
+	}
+	return from, to
+}
+
+func TestLines_ReconstructsBothSides(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"identical", "a\nb\nc", "a\nb\nc"},
+		{"insert", "a\nc", "a\nb\nc"},
+		{"delete", "a\nb\nc", "a\nc"},
+		{"replace", "a\nb\nc", "a\nx\nc"},
+		{"from empty", "", "a\nb"},
+		{"to empty", "a\nb", ""},
+		{"reorder", "a\nb\nc\nd", "d\nc\nb\na"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := SplitLines(tt.a), SplitLines(tt.b)
+			ops := Lines(a, b)
+			from, to := apply(ops)
+			if strings.Join(from, "\n") != tt.a {
+				t.Errorf("Expected old side %q, got %q", tt.a, strings.Join(from, "\n"))
+			}
+			if strings.Join(to, "\n") != tt.b {
+				t.Errorf("Expected new side %q, got %q", tt.b, strings.Join(to, "\n"))
+			}
+			if HasChanges(ops) != (tt.a != tt.b) {
+				t.Errorf("HasChanges = %v for %q -> %q", HasChanges(ops), tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func TestLines_Minimal(t *testing.T) {
+	ops := Lines(SplitLines("a\nb\nc\nd"), SplitLines("a\nx\nc\nd"))
+
+	changes := 0
+	for _, op := range ops {
+		if op.Kind != Equal {
+			changes++
+		}
+	}
+	if changes != 2 {
+		t.Errorf("Expected 2 changed lines (one delete, one insert), got %d", changes)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	from := "line 1\nline 2\nline 3\n"
+	to := "line 1\nline two\nline 3\nline 4\n"
+
+	got := Unified("registry/example", "prompts/example.txt", from, to, 3)
+	want := `--- registry/example
++++ prompts/example.txt
+@@ -1,3 +1,4 @@
+ line 1
+-line 2
++line two
+ line 3
++line 4
+`
+	if got != want {
+		t.Errorf("Unexpected unified diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnified_SeparateHunks(t *testing.T) {
+	var from, to []string
+	for i := 0; i < 20; i++ {
+		from = append(from, "same")
+		to = append(to, "same")
+	}
+	from[1], to[1] = "old head", "new head"
+	from[18], to[18] = "old tail", "new tail"
+
+	got := Unified("a", "b", strings.Join(from, "\n"), strings.Join(to, "\n"), 2)
+	if strings.Count(got, "@@ ") != 2 {
+		t.Errorf("Expected 2 hunks, got:\n%s", got)
+	}
+	if !strings.Contains(got, "@@ -1,4 +1,4 @@") || !strings.Contains(got, "@@ -17,4 +17,4 @@") {
+		t.Errorf("Unexpected hunk headers:\n%s", got)
+	}
+}
+
+func TestUnified_Identical(t *testing.T) {
+	if got := Unified("a", "b", "same\n", "same", 3); got != "" {
+		t.Errorf("Expected no diff for identical content, got %q", got)
+	}
+}
+
+func TestHunks(t *testing.T) {
+	hunks := Hunks("a\nb\nc\n", "a\nc\nd\n", 0)
+	if len(hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %+v", hunks)
+	}
+	if h := hunks[0]; h.OldStart != 2 || h.OldLines != 1 || h.NewStart != 1 || h.NewLines != 0 || h.Header() != "@@ -2 +1,0 @@" {
+		t.Errorf("Unexpected deletion hunk: %+v %s", h, h.Header())
+	}
+	if h := hunks[1]; h.OldStart != 3 || h.OldLines != 0 || h.NewStart != 3 || h.NewLines != 1 || h.Ops[0] != (Op{Kind: Insert, Text: "d"}) {
+		t.Errorf("Unexpected insertion hunk: %+v", h)
+	}
+	if Hunks("same", "same\n", 3) != nil {
+		t.Error("Expected no hunks for identical content")
+	}
+}
+
+func TestHTML(t *testing.T) {
+	got := HTML("keep\n<b>old</b>\ngone\n", "keep\nnew & improved\n", 3)
+	for _, want := range []string{
+		`<tr class="diff-hunk"><td colspan="4">@@ -1,3 +1,2 @@</td></tr>`,
+		`<td class="diff-equal">1</td><td class="diff-equal">keep</td><td class="diff-equal">1</td><td class="diff-equal">keep</td>`,
+		// A changed line sits next to its replacement, escaped
+		`<td class="diff-delete">2</td><td class="diff-delete">&lt;b&gt;old&lt;/b&gt;</td><td class="diff-insert">2</td><td class="diff-insert">new &amp; improved</td>`,
+		`<td class="diff-delete">3</td><td class="diff-delete">gone</td><td class="diff-empty"></td><td class="diff-empty"></td>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected HTML to contain %s, got:\n%s", want, got)
+		}
+	}
+	if got := HTML("same", "same", 3); got != "" {
+		t.Errorf("Expected no HTML for identical content, got %q", got)
+	}
+}
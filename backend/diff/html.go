@@ -0,0 +1,89 @@
+package diff
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// HTML renders a side-by-side diff between two texts as a table fragment,
+// old text on the left and new on the right, with the given context lines.
+// Rows carry classes for styling: diff-hunk for hunk headers, and
+// diff-equal, diff-delete, diff-insert, or diff-empty on each cell. Text
+// is escaped, so the fragment can be inserted into a page as it is. It
+// returns an empty string when the texts are identical.
+func HTML(from, to string, context int) string {
+	hunks := Hunks(from, to, context)
+	if hunks == nil {
+		return ""
+	}
+
+	var out strings.Builder
+	out.WriteString(`<table class="diff">` + "\n")
+	for _, hunk := range hunks {
+		fmt.Fprintf(&out, `<tbody><tr class="diff-hunk"><td colspan="4">%s</td></tr>`+"\n", html.EscapeString(hunk.Header()))
+		oldLine, newLine := hunk.OldStart, hunk.NewStart
+		if hunk.OldLines == 0 {
+			oldLine++
+		}
+		if hunk.NewLines == 0 {
+			newLine++
+		}
+		for i := 0; i < len(hunk.Ops); {
+			if hunk.Ops[i].Kind == Equal {
+				writeRow(&out, &oldLine, &hunk.Ops[i], &newLine, &hunk.Ops[i])
+				i++
+				continue
+			}
+
+			// Pair a run of deleted lines with the inserted lines after it,
+			// so a changed line sits next to what replaced it
+			var deleted, inserted []Op
+			for ; i < len(hunk.Ops) && hunk.Ops[i].Kind == Delete; i++ {
+				deleted = append(deleted, hunk.Ops[i])
+			}
+			for ; i < len(hunk.Ops) && hunk.Ops[i].Kind == Insert; i++ {
+				inserted = append(inserted, hunk.Ops[i])
+			}
+			for j := range max(len(deleted), len(inserted)) {
+				var left, right *Op
+				if j < len(deleted) {
+					left = &deleted[j]
+				}
+				if j < len(inserted) {
+					right = &inserted[j]
+				}
+				writeRow(&out, &oldLine, left, &newLine, right)
+			}
+		}
+		out.WriteString("</tbody>\n")
+	}
+	out.WriteString("</table>\n")
+	return out.String()
+}
+
+// writeRow writes one table row; a nil side is left empty and doesn't
+// advance its line number
+func writeRow(out *strings.Builder, oldLine *int, left *Op, newLine *int, right *Op) {
+	out.WriteString("<tr>")
+	writeCells(out, oldLine, left)
+	writeCells(out, newLine, right)
+	out.WriteString("</tr>\n")
+}
+
+// writeCells writes the line number and text cells for one side of a row
+func writeCells(out *strings.Builder, line *int, op *Op) {
+	if op == nil {
+		out.WriteString(`<td class="diff-empty"></td><td class="diff-empty"></td>`)
+		return
+	}
+	class := "diff-equal"
+	switch op.Kind {
+	case Delete:
+		class = "diff-delete"
+	case Insert:
+		class = "diff-insert"
+	}
+	fmt.Fprintf(out, `<td class="%s">%d</td><td class="%s">%s</td>`, class, *line, class, html.EscapeString(op.Text))
+	*line++
+}
@@ -0,0 +1,204 @@
+// Package eval runs a prompt version against a stored dataset through a model
+// provider and scores each output against the expected answer.
+package eval
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/providers"
+	"github.com/shahram/prompt-registry/backend/render"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Scorers compare a model output with the expected answer
+const (
+	ScorerExact    = "exact"    // equal after trimming surrounding whitespace
+	ScorerContains = "contains" // expected appears in the output, ignoring case
+)
+
+const (
+	// DefaultConcurrency is the number of parallel provider calls per run
+	DefaultConcurrency = 4
+	// MaxConcurrency bounds parallel provider calls per run
+	MaxConcurrency = 16
+)
+
+// ValidateScorer reports whether name is a known scorer
+func ValidateScorer(name string) error {
+	switch name {
+	case ScorerExact, ScorerContains:
+		return nil
+	}
+	return fmt.Errorf("unknown scorer %q (want exact or contains)", name)
+}
+
+// Score returns 1 when output matches expected under scorer and 0 otherwise
+func Score(scorer, output, expected string) float64 {
+	output, expected = strings.TrimSpace(output), strings.TrimSpace(expected)
+	var match bool
+	switch scorer {
+	case ScorerExact:
+		match = output == expected
+	case ScorerContains:
+		match = strings.Contains(strings.ToLower(output), strings.ToLower(expected))
+	}
+	if match {
+		return 1
+	}
+	return 0
+}
+
+// Job is one evaluation run to execute
+type Job struct {
+	RunID       int64
+	Content     string              // prompt version content
+	Messages    []models.Message    // a chat prompt version's messages, rendered instead of Content
+	ModelConfig *models.ModelConfig // the version's temperature, max tokens, and stop sequences
+	Variables   []models.Variable   // prompt variable schema
+	Items       []models.DatasetItem
+	Provider    providers.Provider
+	Model       string
+	Scorer      string
+	Concurrency int
+}
+
+// Runner executes eval jobs in the background and records results as they finish
+type Runner struct {
+	store   store.Store
+	logger  *slog.Logger
+	pending sync.WaitGroup
+}
+
+// NewRunner creates a Runner that records results in s
+func NewRunner(s store.Store, logger *slog.Logger) *Runner {
+	return &Runner{store: s, logger: logger}
+}
+
+// Start runs job in the background
+func (r *Runner) Start(job Job) {
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		r.run(context.Background(), job)
+	}()
+}
+
+// Wait blocks until every started job has finished
+func (r *Runner) Wait() {
+	r.pending.Wait()
+}
+
+// run evaluates every item with at most job.Concurrency provider calls in
+// flight, then marks the run finished. Item failures are recorded on the item;
+// the run only fails when results can't be stored or every item failed.
+func (r *Runner) run(ctx context.Context, job Job) {
+	start := time.Now()
+	concurrency := job.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		failed     int
+		storeError bool
+	)
+	sem := make(chan struct{}, concurrency)
+	for _, item := range job.Items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			result := r.evaluate(ctx, job, item)
+			err := r.store.AddEvalResult(job.RunID, result)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if result.Error != "" {
+				failed++
+			}
+			if err != nil {
+				storeError = true
+			}
+		}()
+	}
+	wg.Wait()
+
+	var runErr string
+	switch {
+	case storeError:
+		runErr = "failed to record some results"
+	case len(job.Items) > 0 && failed == len(job.Items):
+		runErr = "every item failed; see results for details"
+	}
+	if err := r.store.FinishEvalRun(job.RunID, runErr); err != nil {
+		r.logger.Error("failed to finish eval run", "error", err, "run_id", job.RunID)
+	}
+
+	r.logger.Info("eval run finished",
+		"run_id", job.RunID,
+		"items", len(job.Items),
+		"failed", failed,
+		"error", runErr,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+}
+
+// providerMessages converts rendered chat messages for a provider request
+func providerMessages(messages []models.Message) []providers.Message {
+	result := make([]providers.Message, len(messages))
+	for i, m := range messages {
+		result[i] = providers.Message{Role: m.Role, Content: m.Content}
+	}
+	return result
+}
+
+// evaluate renders the prompt for one item, runs it, and scores the output
+func (r *Runner) evaluate(ctx context.Context, job Job, item models.DatasetItem) models.EvalResult {
+	result := models.EvalResult{ItemID: item.ID}
+
+	if err := render.Validate(job.Variables, item.Input); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req := providers.Request{Model: job.Model}
+	if params := job.ModelConfig; params != nil {
+		req.MaxTokens, req.Temperature, req.Stop = params.MaxTokens, params.Temperature, params.Stop
+	}
+	var err error
+	if job.Messages != nil {
+		var messages []models.Message
+		messages, err = render.RenderMessages(job.Messages, item.Input)
+		req.Messages = providerMessages(messages)
+	} else {
+		req.Prompt, err = render.Render(job.Content, item.Input)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	completion, err := job.Provider.Complete(ctx, req)
+	result.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Output = completion.Text
+	result.Score = Score(job.Scorer, completion.Text, item.Expected)
+	result.Passed = result.Score >= 1
+	return result
+}
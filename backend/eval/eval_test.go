@@ -0,0 +1,34 @@
+package eval
+
+import "testing"
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		scorer, output, expected string
+		want                     float64
+	}{
+		{ScorerExact, "Paris", "Paris", 1},
+		{ScorerExact, "  Paris\n", "Paris", 1},
+		{ScorerExact, "paris", "Paris", 0},
+		{ScorerExact, "The capital is Paris", "Paris", 0},
+		{ScorerContains, "The capital is Paris.", "paris", 1},
+		{ScorerContains, "Lyon", "Paris", 0},
+	}
+
+	for _, tt := range tests {
+		if got := Score(tt.scorer, tt.output, tt.expected); got != tt.want {
+			t.Errorf("Score(%q, %q, %q) = %v, want %v", tt.scorer, tt.output, tt.expected, got, tt.want)
+		}
+	}
+}
+
+func TestValidateScorer(t *testing.T) {
+	for _, name := range []string{ScorerExact, ScorerContains} {
+		if err := ValidateScorer(name); err != nil {
+			t.Errorf("ValidateScorer(%q) = %v", name, err)
+		}
+	}
+	if err := ValidateScorer("fuzzy"); err == nil {
+		t.Error("Expected error for unknown scorer")
+	}
+}
@@ -0,0 +1,24 @@
+// Package gallery holds the HTML templates for the public prompt gallery. The
+// server renders them per request, and promptctl publish-static renders them
+// into a static site.
+package gallery
+
+import (
+	_ "embed"
+	"html/template"
+)
+
+//go:embed gallery.html
+var galleryHTML string
+
+// Templates defines the "index" and "prompt" pages. Both take a map with:
+//
+//	Title      site title
+//	Prefix     path of the gallery root that page links are built on
+//	Ext        suffix of page links: "" when served, ".html" for static files
+//	Canonical  absolute URL of the page, or "" to leave out the canonical link
+//
+// "index" also takes Prompts ([]models.PromptSummary). "prompt" takes Prompt
+// (models.PromptWithCurrentVersion) and, optionally, Versions
+// ([]models.PromptVersion, newest first) to show its version history.
+var Templates = template.Must(template.New("gallery").Parse(galleryHTML))
@@ -0,0 +1,60 @@
+package gallery
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+func TestTemplates_StaticLinks(t *testing.T) {
+	var index strings.Builder
+	err := Templates.ExecuteTemplate(&index, "index", map[string]any{
+		"Title":   "Team Prompts",
+		"Prefix":  ".",
+		"Ext":     ".html",
+		"Prompts": []models.PromptSummary{{Slug: "summarize", Title: "Summarize"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to render index: %v", err)
+	}
+	if !strings.Contains(index.String(), `href="./prompts/summarize.html"`) {
+		t.Error("Expected relative link to the prompt page")
+	}
+	if !strings.Contains(index.String(), "<title>Team Prompts</title>") {
+		t.Error("Expected site title")
+	}
+	if strings.Contains(index.String(), `rel="canonical"`) {
+		t.Error("Expected no canonical link without a canonical URL")
+	}
+
+	var page strings.Builder
+	err = Templates.ExecuteTemplate(&page, "prompt", map[string]any{
+		"Title":  "Team Prompts",
+		"Prefix": "..",
+		"Ext":    ".html",
+		"Prompt": models.PromptWithCurrentVersion{
+			Slug:           "summarize",
+			Title:          "Summarize",
+			CurrentVersion: models.PromptVersion{VersionNumber: 2, Content: "Summarize <text>"},
+		},
+		"Versions": []models.PromptVersion{
+			{VersionNumber: 2, Content: "Summarize <text>", CreatedAt: time.Date(2024, 5, 2, 10, 30, 0, 0, time.UTC)},
+			{VersionNumber: 1, Content: "Summarize", CreatedAt: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC), CreatedBy: "alice"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to render prompt page: %v", err)
+	}
+	for _, want := range []string{
+		`href="../index.html"`,
+		"Version history",
+		"2024-05-01 09:00 UTC · alice",
+		"Summarize &lt;text&gt;",
+	} {
+		if !strings.Contains(page.String(), want) {
+			t.Errorf("Expected prompt page to contain %q", want)
+		}
+	}
+}
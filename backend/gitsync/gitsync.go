@@ -0,0 +1,367 @@
+// Package gitsync mirrors prompts into a Git repository so prompt changes can
+// be reviewed as diffs. Each prompt is one <slug>.txt file holding its content,
+// and each registry version is one commit carrying Prompt-Slug and
+// Prompt-Version trailers, which is how a sync knows what is already mirrored.
+package gitsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Sync directions
+const (
+	DirectionPush = "push" // commit registry versions to the repository
+	DirectionPull = "pull" // import changed files as new versions
+	DirectionBoth = "both" // pull, then push
+)
+
+const (
+	fileExt        = ".txt"
+	slugTrailer    = "Prompt-Slug"
+	versionTrailer = "Prompt-Version"
+)
+
+// ErrInProgress is returned when a sync is requested while one is running
+var ErrInProgress = errors.New("sync already in progress")
+
+// fileSlug matches slugs that are safe to use as file names
+var fileSlug = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Config selects the repository to sync with
+type Config struct {
+	// Dir is the local working tree; it is cloned from Remote or initialised on first sync
+	Dir string
+	// Remote is an optional URL to pull from and push to
+	Remote string
+	// Branch is the branch to commit to and sync with the remote
+	Branch string
+	// AuthorName and AuthorEmail identify sync commits
+	AuthorName  string
+	AuthorEmail string
+}
+
+// Syncer pushes prompts to and pulls prompts from a Git repository. Only one
+// sync runs at a time.
+type Syncer struct {
+	config Config
+	store  store.Store
+	logger *slog.Logger
+	mu     sync.Mutex
+}
+
+// New creates a Syncer, filling in defaults for the branch and author
+func New(config Config, s store.Store, logger *slog.Logger) *Syncer {
+	if config.Branch == "" {
+		config.Branch = "main"
+	}
+	if config.AuthorName == "" {
+		config.AuthorName = "Prompt Registry"
+	}
+	if config.AuthorEmail == "" {
+		config.AuthorEmail = "prompt-registry@localhost"
+	}
+	return &Syncer{config: config, store: s, logger: logger}
+}
+
+// ValidateDirection reports whether direction is a known sync direction
+func ValidateDirection(direction string) error {
+	switch direction {
+	case DirectionPush, DirectionPull, DirectionBoth:
+		return nil
+	}
+	return fmt.Errorf("unknown direction %q (want push, pull, or both)", direction)
+}
+
+// Sync runs one sync in the given direction. Problems with individual prompts
+// or files are reported in the result's errors; the returned error means the
+// repository itself couldn't be synced.
+func (s *Syncer) Sync(direction string) (models.SyncResult, error) {
+	result := models.SyncResult{
+		Direction: direction,
+		Imported:  []models.SyncedVersion{},
+		Committed: []models.SyncedVersion{},
+		Errors:    []string{},
+	}
+	if err := ValidateDirection(direction); err != nil {
+		return result, err
+	}
+	if !s.mu.TryLock() {
+		return result, ErrInProgress
+	}
+	defer s.mu.Unlock()
+
+	start := time.Now()
+	if err := s.prepare(); err != nil {
+		return result, err
+	}
+	if s.config.Remote != "" {
+		if err := s.fetch(); err != nil {
+			return result, err
+		}
+	}
+
+	synced, err := s.syncedVersions()
+	if err != nil {
+		return result, err
+	}
+	if direction != DirectionPush {
+		if err := s.pull(synced, &result); err != nil {
+			return result, err
+		}
+	}
+	if direction != DirectionPull {
+		if err := s.push(synced, &result); err != nil {
+			return result, err
+		}
+		if s.config.Remote != "" && len(result.Committed) > 0 {
+			if _, err := s.git("push", "origin", "HEAD:"+s.config.Branch); err != nil {
+				return result, err
+			}
+			result.Pushed = true
+		}
+	}
+
+	if head, err := s.git("rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		result.Commit = head
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	s.logger.Info("git sync finished",
+		"direction", direction,
+		"imported", len(result.Imported),
+		"committed", len(result.Committed),
+		"errors", len(result.Errors),
+		"pushed", result.Pushed,
+		"commit", result.Commit,
+		"duration_ms", result.DurationMs,
+	)
+	return result, nil
+}
+
+// prepare clones or initialises the working tree when it isn't a repository yet
+func (s *Syncer) prepare() error {
+	if _, err := os.Stat(filepath.Join(s.config.Dir, ".git")); err == nil {
+		return nil
+	}
+	if s.config.Remote != "" {
+		if err := os.MkdirAll(filepath.Dir(s.config.Dir), 0755); err != nil {
+			return fmt.Errorf("failed to create sync directory: %w", err)
+		}
+		if _, err := s.run("", "clone", "--quiet", s.config.Remote, s.config.Dir); err != nil {
+			return err
+		}
+		// The remote's default branch may not be the one we sync
+		if _, err := s.git("rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+s.config.Branch); err == nil {
+			_, err := s.git("checkout", "--quiet", "-B", s.config.Branch, "refs/remotes/origin/"+s.config.Branch)
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(s.config.Dir, 0755); err != nil {
+			return fmt.Errorf("failed to create sync directory: %w", err)
+		}
+		if _, err := s.git("init"); err != nil {
+			return err
+		}
+	}
+	// Point an unborn HEAD at the configured branch; existing history is left alone
+	if _, err := s.git("rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		_, err := s.git("symbolic-ref", "HEAD", "refs/heads/"+s.config.Branch)
+		return err
+	}
+	return nil
+}
+
+// fetch fast-forwards the working tree to the remote branch, if it exists yet
+func (s *Syncer) fetch() error {
+	if _, err := s.git("ls-remote", "--exit-code", "--heads", "origin", s.config.Branch); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 {
+			return nil // empty remote; the first push creates the branch
+		}
+		return err
+	}
+	_, err := s.git("pull", "--ff-only", "origin", s.config.Branch)
+	return err
+}
+
+// syncedVersions maps each slug to the newest version committed for it
+func (s *Syncer) syncedVersions() (map[string]int, error) {
+	synced := make(map[string]int)
+	if _, err := s.git("rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		return synced, nil // no commits yet
+	}
+
+	out, err := s.git("log", "--format=%(trailers:key="+slugTrailer+",valueonly)%x00%(trailers:key="+versionTrailer+",valueonly)%x1e")
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range strings.Split(out, "\x1e") {
+		slug, version, ok := strings.Cut(strings.TrimSpace(record), "\x00")
+		slug = strings.TrimSpace(slug)
+		if !ok || slug == "" {
+			continue
+		}
+		if _, seen := synced[slug]; seen {
+			continue // log is newest first
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(version)); err == nil {
+			synced[slug] = n
+		}
+	}
+	return synced, nil
+}
+
+// pull imports files whose content changed in Git since their last synced
+// version. New files create prompts; deleted files are ignored.
+func (s *Syncer) pull(synced map[string]int, result *models.SyncResult) error {
+	entries, err := os.ReadDir(s.config.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read sync directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		slug, ok := strings.CutSuffix(entry.Name(), fileExt)
+		if entry.IsDir() || !ok || !fileSlug.MatchString(slug) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.config.Dir, entry.Name()))
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", entry.Name(), err))
+			continue
+		}
+		content := string(data)
+
+		prompt, err := s.store.GetPromptBySlug(slug)
+		if err != nil {
+			if !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+			created, err := s.store.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: content})
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", entry.Name(), err))
+				continue
+			}
+			result.Imported = append(result.Imported, models.SyncedVersion{Slug: slug, Version: created.CurrentVersion.VersionNumber})
+			continue
+		}
+
+		// The file changed in Git when it no longer matches the last version
+		// mirrored there. Without any mirrored version, compare to the current one.
+		base := prompt.CurrentVersion.Content
+		if n, ok := synced[slug]; ok && n != prompt.CurrentVersion.VersionNumber {
+			version, err := s.store.GetPromptVersion(slug, n)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", entry.Name(), err))
+				continue
+			}
+			base = version.Content
+		}
+		if content == base || content == prompt.CurrentVersion.Content {
+			continue
+		}
+
+		updated, err := s.store.CreatePromptVersion(slug, models.CreatePromptVersionInput{Content: content})
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", entry.Name(), err))
+			continue
+		}
+		// Under a review policy the new version waits for approval
+		version := updated.CurrentVersion.VersionNumber
+		if updated.PendingVersion != nil {
+			version = updated.PendingVersion.VersionNumber
+		}
+		result.Imported = append(result.Imported, models.SyncedVersion{Slug: slug, Version: version})
+	}
+	return nil
+}
+
+// push commits every registry version newer than its last synced version,
+// oldest first, one commit per version dated when the version was created.
+func (s *Syncer) push(synced map[string]int, result *models.SyncResult) error {
+	_, err := s.store.ExportPrompts(func(prompt models.ExportedPrompt) error {
+		if !fileSlug.MatchString(prompt.Slug) {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: slug can't be used as a file name", prompt.Slug))
+			return nil
+		}
+		for _, version := range prompt.Versions {
+			if version.VersionNumber <= synced[prompt.Slug] {
+				continue
+			}
+			if err := s.commitVersion(prompt.Slug, version); err != nil {
+				return err
+			}
+			result.Committed = append(result.Committed, models.SyncedVersion{Slug: prompt.Slug, Version: version.VersionNumber})
+		}
+		return nil
+	})
+	return err
+}
+
+// commitVersion writes a version's content and commits it. The commit is
+// empty when the file already holds that content, e.g. after a pull.
+func (s *Syncer) commitVersion(slug string, version models.ExportedVersion) error {
+	file := slug + fileExt
+	if err := os.WriteFile(filepath.Join(s.config.Dir, file), []byte(version.Content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	if _, err := s.git("add", "--", file); err != nil {
+		return err
+	}
+
+	created := version.CreatedAt
+	if version.OriginalCreatedAt != nil {
+		created = *version.OriginalCreatedAt
+	}
+	message := fmt.Sprintf("Update %s to version %d\n\n%s: %s\n%s: %d\n",
+		slug, version.VersionNumber, slugTrailer, slug, versionTrailer, version.VersionNumber)
+	cmd := s.command(s.config.Dir, "commit", "--quiet", "--allow-empty", "-F", "-")
+	cmd.Stdin = strings.NewReader(message)
+	cmd.Env = append(cmd.Env, "GIT_AUTHOR_DATE="+created.Format(time.RFC3339))
+	_, err := s.output(cmd)
+	return err
+}
+
+// git runs a git command in the working tree and returns its trimmed output
+func (s *Syncer) git(args ...string) (string, error) {
+	return s.run(s.config.Dir, args...)
+}
+
+func (s *Syncer) run(dir string, args ...string) (string, error) {
+	return s.output(s.command(dir, args...))
+}
+
+func (s *Syncer) command(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_AUTHOR_NAME="+s.config.AuthorName,
+		"GIT_AUTHOR_EMAIL="+s.config.AuthorEmail,
+		"GIT_COMMITTER_NAME="+s.config.AuthorName,
+		"GIT_COMMITTER_EMAIL="+s.config.AuthorEmail,
+	)
+	return cmd
+}
+
+func (s *Syncer) output(cmd *exec.Cmd) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		// Arguments can include the remote URL, which may carry credentials
+		return "", fmt.Errorf("git %s failed: %w: %s", cmd.Args[1], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,168 @@
+package gitsync
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+func setupSyncer(t *testing.T) (*Syncer, *store.SQLiteStore, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	s, err := store.New(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	remote := filepath.Join(dir, "remote.git")
+	gitIn(t, "", "init", "--bare", "--quiet", remote)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	syncer := New(Config{Dir: filepath.Join(dir, "work"), Remote: remote}, s, logger)
+	return syncer, s, remote
+}
+
+func gitIn(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Reviewer", "GIT_AUTHOR_EMAIL=reviewer@example.com",
+		"GIT_COMMITTER_NAME=Reviewer", "GIT_COMMITTER_EMAIL=reviewer@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestSync_PushCommitsEachVersion(t *testing.T) {
+	syncer, s, remote := setupSyncer(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "greet", Title: "Greet", Content: "Hello"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := s.CreatePromptVersion("greet", models.CreatePromptVersionInput{Content: "Hello there"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	result, err := syncer.Sync(DirectionPush)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(result.Committed) != 2 || !result.Pushed || result.Commit == "" {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+
+	log := gitIn(t, remote, "log", "--format=%s", "main")
+	if log != "Update greet to version 2\nUpdate greet to version 1" {
+		t.Errorf("Unexpected remote history:\n%s", log)
+	}
+	if content := gitIn(t, remote, "show", "main:greet.txt"); content != "Hello there" {
+		t.Errorf("Unexpected file content %q", content)
+	}
+
+	// Nothing new to commit
+	result, err = syncer.Sync(DirectionPush)
+	if err != nil || len(result.Committed) != 0 || result.Pushed {
+		t.Errorf("Expected no-op sync, got %+v, %v", result, err)
+	}
+
+	// A fresh registry can be restored from the repository
+	fresh, err := store.New(filepath.Join(t.TempDir(), "fresh.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer fresh.Close()
+	restore := New(Config{Dir: filepath.Join(t.TempDir(), "work"), Remote: remote}, fresh, syncer.logger)
+	result, err = restore.Sync(DirectionPull)
+	if err != nil || len(result.Imported) != 1 {
+		t.Fatalf("Expected one imported prompt, got %+v, %v", result, err)
+	}
+	if greet, _ := fresh.GetPromptBySlug("greet"); greet.CurrentVersion.Content != "Hello there" {
+		t.Errorf("Unexpected restored content %q", greet.CurrentVersion.Content)
+	}
+}
+
+func TestSync_PullImportsChangedFiles(t *testing.T) {
+	syncer, s, remote := setupSyncer(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "greet", Title: "Greet", Content: "Hello"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := syncer.Sync(DirectionBoth); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// A reviewer edits one prompt and adds another through Git
+	clone := filepath.Join(t.TempDir(), "clone")
+	gitIn(t, "", "clone", "--quiet", "--branch", "main", remote, clone)
+	os.WriteFile(filepath.Join(clone, "greet.txt"), []byte("Hi!"), 0644)
+	os.WriteFile(filepath.Join(clone, "farewell.txt"), []byte("Bye"), 0644)
+	os.WriteFile(filepath.Join(clone, "README.md"), []byte("not a prompt"), 0644)
+	gitIn(t, clone, "add", ".")
+	gitIn(t, clone, "commit", "--quiet", "-m", "Reword greeting")
+	gitIn(t, clone, "push", "--quiet", "origin", "HEAD:main")
+
+	// Meanwhile nothing changed in the registry, so the reviewer's edit wins
+	result, err := syncer.Sync(DirectionBoth)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(result.Imported) != 2 || len(result.Errors) != 0 {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+	greet, _ := s.GetPromptBySlug("greet")
+	if greet.CurrentVersion.VersionNumber != 2 || greet.CurrentVersion.Content != "Hi!" {
+		t.Errorf("Expected greet v2 from Git, got %+v", greet.CurrentVersion)
+	}
+	farewell, err := s.GetPromptBySlug("farewell")
+	if err != nil || farewell.CurrentVersion.Content != "Bye" {
+		t.Errorf("Expected farewell to be created, got %+v, %v", farewell, err)
+	}
+	if len(result.Committed) != 2 {
+		t.Errorf("Expected imported versions to be recorded as commits, got %+v", result.Committed)
+	}
+
+	// Imported versions are now mirrored, so another sync changes nothing
+	result, err = syncer.Sync(DirectionBoth)
+	if err != nil || len(result.Imported) != 0 || len(result.Committed) != 0 {
+		t.Errorf("Expected no-op sync, got %+v, %v", result, err)
+	}
+
+	// A registry change that Git hasn't seen yet is pushed, not reverted by pull
+	if _, err := s.CreatePromptVersion("greet", models.CreatePromptVersionInput{Content: "Hey"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	result, err = syncer.Sync(DirectionBoth)
+	if err != nil || len(result.Imported) != 0 || len(result.Committed) != 1 {
+		t.Errorf("Expected only a push, got %+v, %v", result, err)
+	}
+	if content := gitIn(t, remote, "show", "main:greet.txt"); content != "Hey" {
+		t.Errorf("Unexpected file content %q", content)
+	}
+}
+
+func TestValidateDirection(t *testing.T) {
+	for _, direction := range []string{DirectionPush, DirectionPull, DirectionBoth} {
+		if err := ValidateDirection(direction); err != nil {
+			t.Errorf("ValidateDirection(%q) = %v", direction, err)
+		}
+	}
+	if err := ValidateDirection("sideways"); err == nil {
+		t.Error("Expected error for unknown direction")
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+// Optional access log fields; method, path, status, and duration are always logged
+const (
+	AccessLogRemoteAddr = "remote_addr" // client IP, from X-Forwarded-For behind a trusted proxy
+	AccessLogUserAgent  = "user_agent"
+	AccessLogBytes      = "bytes"   // response body bytes written
+	AccessLogAPIKey     = "api_key" // name of the API key the request authenticated with
+)
+
+var accessLogFields = []string{AccessLogRemoteAddr, AccessLogUserAgent, AccessLogBytes, AccessLogAPIKey}
+
+// AccessLogConfig controls the "http request" line logged for each request
+type AccessLogConfig struct {
+	// SampleRate logs 1 in N successful GET and HEAD requests per route;
+	// errors and writes are always logged. 0 or 1 logs every request.
+	SampleRate int
+	// Fields adds optional fields to every line
+	Fields []string
+	// ExcludePaths are request paths, such as /health and /metrics, that are
+	// only logged when they fail
+	ExcludePaths []string
+}
+
+// DefaultAccessLogConfig returns the access log defaults: every request is
+// logged with no optional fields
+func DefaultAccessLogConfig() AccessLogConfig {
+	return AccessLogConfig{SampleRate: 1}
+}
+
+// Validate reports unknown fields
+func (c AccessLogConfig) Validate() error {
+	for _, field := range c.Fields {
+		if !slices.Contains(accessLogFields, field) {
+			return fmt.Errorf("unknown access log field %q (want one of %v)", field, accessLogFields)
+		}
+	}
+	return nil
+}
+
+// excluded reports whether a request's access log line should be skipped
+func (c AccessLogConfig) excluded(r *http.Request, status int) bool {
+	return status < 400 && slices.Contains(c.ExcludePaths, r.URL.Path)
+}
+
+// fieldAttrs returns the configured optional fields as log attributes.
+// remoteAddr is the client IP.
+func (c AccessLogConfig) fieldAttrs(r *http.Request, rw *responseWriter, remoteAddr string) []any {
+	var attrs []any
+	for _, field := range c.Fields {
+		switch field {
+		case AccessLogRemoteAddr:
+			attrs = append(attrs, AccessLogRemoteAddr, remoteAddr)
+		case AccessLogUserAgent:
+			attrs = append(attrs, AccessLogUserAgent, r.UserAgent())
+		case AccessLogBytes:
+			attrs = append(attrs, AccessLogBytes, rw.bytes)
+		case AccessLogAPIKey:
+			if id, ok := reqctx.Identity(r.Context()); ok && id.Method == "apikey" {
+				attrs = append(attrs, AccessLogAPIKey, id.Subject)
+			}
+		}
+	}
+	return attrs
+}
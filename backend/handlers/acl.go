@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Middleware: Per-prompt access control for /prompts/{slug} routes
+// Callers without read access get 404, as if the prompt didn't exist, and
+// callers with read-only access get 403 on writes.
+func (h *Handler) promptAccessMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug := r.PathValue("slug")
+		if err := h.requestStore(r).AuthorizePrompt(slug, requiredAccess(r)); err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				h.respondErrorFrom(w, http.StatusNotFound, err)
+			case errors.Is(err, store.ErrPermission):
+				h.respondErrorFrom(w, http.StatusForbidden, err)
+			default:
+				reqctx.Logger(r.Context()).Error("failed to authorize prompt", "error", err, "slug", slug)
+				h.respondError(w, http.StatusInternalServerError, "Failed to authorize prompt")
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requiredAccess returns the access a prompt route needs. Rendering,
+// executing, and forking are POSTs but only read the prompt, and readers can
+// comment on versions they review, approve them as designated reviewers, and
+// star prompts. Webhook URLs often carry tokens, so listing them needs write
+// access like changing them.
+func requiredAccess(r *http.Request) string {
+	if strings.HasSuffix(r.Pattern, "/webhooks") {
+		return store.AccessWrite
+	}
+	if isReadOnlyMethod(r.Method) || strings.HasSuffix(r.Pattern, "/render") || strings.HasSuffix(r.Pattern, "/execute") ||
+		strings.HasSuffix(r.Pattern, "/fork") || strings.HasSuffix(r.Pattern, "/comments") || strings.HasSuffix(r.Pattern, "/approvals") ||
+		strings.HasSuffix(r.Pattern, "/star") {
+		return store.AccessRead
+	}
+	return store.AccessWrite
+}
+
+// canReadPrompt reports whether s's caller can see project and read slug in
+// it, for signals about a prompt sent outside its own routes. Missing
+// prompts pass, as with AuthorizePrompt.
+func canReadPrompt(s store.Store, project, slug string) (bool, error) {
+	scoped := s.InProject(project)
+	err := scoped.AuthorizeProject()
+	if err == nil {
+		err = scoped.AuthorizePrompt(slug, store.AccessRead)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Handler: Get a prompt's owner and access grants
+func (h *Handler) handleGetACL(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	result, err := h.requestStore(r).GetPromptACL(slug)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to get acl", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get access control list")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Replace a prompt's access grants, optionally transferring ownership
+func (h *Handler) handleSetACL(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	var input models.SetPromptACLInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	result, err := h.requestStore(r).SetPromptACL(slug, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, store.ErrPermission):
+			h.respondErrorFrom(w, http.StatusForbidden, err)
+		case errors.Is(err, store.ErrValidation):
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+		default:
+			reqctx.Logger(r.Context()).Error("failed to set acl", "error", err, "slug", slug)
+			h.respondError(w, http.StatusInternalServerError, "Failed to set access control list")
+		}
+		return
+	}
+
+	reqctx.Logger(r.Context()).Info("prompt acl changed", "slug", slug, "owner", result.Owner, "grants", len(result.Grants))
+	h.respondJSON(w, http.StatusOK, result)
+}
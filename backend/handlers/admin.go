@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/auth"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// mountAdminRoutes registers maintenance endpoints. They are only mounted when
+// AdminToken is set, and every request must present it as a bearer token.
+func (h *Handler) mountAdminRoutes(mux *http.ServeMux) {
+	mux.Handle("POST /api/admin/compact", h.adminMiddleware(http.HandlerFunc(h.handleCompact)))
+	mux.Handle("POST /api/admin/reopen", h.adminMiddleware(http.HandlerFunc(h.handleReopen)))
+	mux.Handle("POST /api/admin/restore", h.adminMiddleware(http.HandlerFunc(h.handleRestore)))
+	mux.Handle("POST /api/admin/capture", h.adminMiddleware(http.HandlerFunc(h.handleStartCapture)))
+	mux.Handle("GET /api/admin/capture", h.adminMiddleware(http.HandlerFunc(h.handleGetCapture)))
+	mux.Handle("DELETE /api/admin/capture", h.adminMiddleware(http.HandlerFunc(h.handleStopCapture)))
+	mux.Handle("GET /api/admin/integration-status", h.adminMiddleware(http.HandlerFunc(h.handleIntegrationStatus)))
+	mux.Handle("PUT /api/admin/prompts/{slug}/legal-hold", h.adminMiddleware(http.HandlerFunc(h.handleSetLegalHold)))
+	mux.Handle("PUT /api/admin/projects/{project}/prompts/{slug}/legal-hold", h.adminMiddleware(http.HandlerFunc(h.handleSetLegalHold)))
+	mux.Handle("PUT /api/admin/prompts/{slug}/review-policy", h.adminMiddleware(http.HandlerFunc(h.handleSetReviewPolicy)))
+	mux.Handle("PUT /api/admin/projects/{project}/prompts/{slug}/review-policy", h.adminMiddleware(http.HandlerFunc(h.handleSetReviewPolicy)))
+	mux.Handle("POST /api/admin/retention/prune", h.adminMiddleware(http.HandlerFunc(h.handlePruneVersions)))
+	mux.Handle("POST /api/admin/prompts/{slug}/versions/{version}/redact", h.adminMiddleware(http.HandlerFunc(h.handleRedactVersion)))
+	mux.Handle("POST /api/admin/projects/{project}/prompts/{slug}/versions/{version}/redact", h.adminMiddleware(http.HandlerFunc(h.handleRedactVersion)))
+	mux.Handle("DELETE /api/admin/prompts/{slug}", h.adminMiddleware(http.HandlerFunc(h.handleDeletePrompt)))
+	mux.Handle("DELETE /api/admin/projects/{project}/prompts/{slug}", h.adminMiddleware(http.HandlerFunc(h.handleDeletePrompt)))
+	mux.Handle("POST /api/admin/archived/purge", h.adminMiddleware(http.HandlerFunc(h.handlePurgeArchived)))
+	mux.Handle("POST /api/admin/recompute", h.adminMiddleware(http.HandlerFunc(h.handleRecompute)))
+	mux.Handle("GET /api/admin/audit", h.adminMiddleware(http.HandlerFunc(h.handleListAdminAudit)))
+	mux.Handle("GET /api/admin/read-only", h.adminMiddleware(http.HandlerFunc(h.handleGetReadOnly)))
+	mux.Handle("PUT /api/admin/read-only", h.adminMiddleware(http.HandlerFunc(h.handleSetReadOnly)))
+}
+
+// adminIdentity attributes admin requests in the audit log
+var adminIdentity = auth.Identity{Subject: "admin", Method: "admin"}
+
+// Middleware: Admin bearer token
+func (h *Handler) adminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			h.respondError(w, http.StatusUnauthorized, "Admin token required")
+			return
+		}
+		reqctx.SetIdentity(r.Context(), adminIdentity)
+		next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), adminIdentity)))
+	})
+}
+
+// Middleware: Maintenance mode
+// While maintenance is active, writes are rejected with 503 so they cannot
+// contend with long-running operations such as VACUUM. Reads keep working.
+// Writes being served are counted so restores can wait for a quiet moment.
+func (h *Handler) maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isReadOnlyMethod(r.Method) || strings.HasPrefix(r.URL.Path, "/api/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Counted before checking the flag, so an operation that sets the flag
+		// and then finds no writes in flight can't race with one starting
+		h.writes.Add(1)
+		defer h.writes.Add(-1)
+		if h.maintenance.Load() {
+			w.Header().Set("Retry-After", "30")
+			h.respondError(w, http.StatusServiceUnavailable, "Registry is in maintenance mode, try again shortly")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isReadOnlyMethod reports whether an HTTP method cannot modify state
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// Handler: Compact database
+// Enters maintenance mode, runs VACUUM and ANALYZE, and reports reclaimed space.
+func (h *Handler) handleCompact(w http.ResponseWriter, r *http.Request) {
+	if !h.maintenance.CompareAndSwap(false, true) {
+		h.respondError(w, http.StatusConflict, "Maintenance operation already in progress")
+		return
+	}
+	defer h.maintenance.Store(false)
+
+	reqctx.Logger(r.Context()).Info("maintenance started", "operation", "compact")
+	result, err := h.requestStore(r).Compact()
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to compact database", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to compact database")
+		return
+	}
+	reqctx.Logger(r.Context()).Info("maintenance finished",
+		"operation", "compact",
+		"reclaimed_bytes", result.ReclaimedBytes,
+		"duration_ms", result.DurationMs,
+	)
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Reopen database
+// Enters maintenance mode and switches the store to another database file,
+// draining in-flight queries first. Used for restores and blue/green swaps.
+func (h *Handler) handleReopen(w http.ResponseWriter, r *http.Request) {
+	var input models.ReopenInput
+	if r.ContentLength != 0 {
+		if !h.decodeJSON(w, r, &input) {
+			return
+		}
+	}
+
+	if !h.maintenance.CompareAndSwap(false, true) {
+		h.respondError(w, http.StatusConflict, "Maintenance operation already in progress")
+		return
+	}
+	defer h.maintenance.Store(false)
+
+	reqctx.Logger(r.Context()).Info("maintenance started", "operation", "reopen")
+	result, err := h.Store.Reopen(input.Path)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to reopen database", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to reopen database")
+		return
+	}
+	reqctx.Logger(r.Context()).Info("maintenance finished",
+		"operation", "reopen",
+		"path", result.Path,
+		"duration_ms", result.DurationMs,
+	)
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Restore database from backup
+// Enters maintenance mode and replaces the database with a validated backup.
+// Refused while writes are in flight, since they would be lost with the old file.
+func (h *Handler) handleRestore(w http.ResponseWriter, r *http.Request) {
+	var input models.RestoreInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	if input.Path == "" {
+		h.respondError(w, http.StatusBadRequest, "Backup path cannot be empty")
+		return
+	}
+	path := input.Path
+	if h.Backups != nil && filepath.Base(path) == path {
+		path = filepath.Join(h.Backups.Dir(), path)
+	}
+
+	if !h.maintenance.CompareAndSwap(false, true) {
+		h.respondError(w, http.StatusConflict, "Maintenance operation already in progress")
+		return
+	}
+	defer h.maintenance.Store(false)
+	if n := h.writes.Load(); n > 0 {
+		w.Header().Set("Retry-After", "5")
+		h.respondError(w, http.StatusConflict, fmt.Sprintf("%d write requests in flight, try again shortly", n))
+		return
+	}
+
+	reqctx.Logger(r.Context()).Info("maintenance started", "operation", "restore", "backup", path)
+	result, err := h.Store.Restore(path)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to restore database", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to restore database")
+		return
+	}
+	reqctx.Logger(r.Context()).Info("maintenance finished",
+		"operation", "restore",
+		"backup", result.BackupPath,
+		"previous_copy", result.PreviousCopy,
+		"duration_ms", result.DurationMs,
+	)
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Place or release a legal hold
+// A held prompt and all its versions are exempt from deletion, purges, and
+// retention pruning until the hold is released. Admin only, so API callers
+// can't lift a hold themselves.
+func (h *Handler) handleSetLegalHold(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	if project := r.PathValue("project"); project != "" {
+		if err := store.ValidateProject(project); err != nil {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	var input models.SetLegalHoldInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	s := h.requestStore(r)
+	if err := s.SetLegalHold(slug, input.Held, input.Reason); err != nil {
+		if errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to set legal hold", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to set legal hold")
+		return
+	}
+	reqctx.Logger(r.Context()).Info("legal hold changed", "slug", slug, "project", r.PathValue("project"), "held", input.Held)
+
+	result, err := s.GetPromptBySlug(slug)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to get prompt", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get prompt")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Redact a version
+// Replaces a version's content with a redaction notice for sensitive data
+// that must not stay in the registry. The version number, the SHA-256 of the
+// original content, and an audit entry remain.
+func (h *Handler) handleRedactVersion(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	if project := r.PathValue("project"); project != "" {
+		if err := store.ValidateProject(project); err != nil {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid version number")
+		return
+	}
+
+	var input models.RedactVersionInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	s := h.requestStore(r)
+	result, err := s.RedactPromptVersion(slug, version, input.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrValidation):
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, store.ErrNotFound):
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, store.ErrConflict):
+			h.respondErrorFrom(w, http.StatusConflict, err)
+		default:
+			reqctx.Logger(r.Context()).Error("failed to redact version", "error", err, "slug", slug, "version", version)
+			h.respondError(w, http.StatusInternalServerError, "Failed to redact version")
+		}
+		return
+	}
+	reqctx.Logger(r.Context()).Info("version redacted", "slug", slug, "project", r.PathValue("project"), "version", version)
+
+	h.notifyWebhooks(s, WebhookVersionRedacted, slug, version)
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Permanently delete a prompt
+// Removes the prompt and its whole history, for data that must not be kept
+// even archived. Prompts under legal hold get 409.
+func (h *Handler) handleDeletePrompt(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	if project := r.PathValue("project"); project != "" {
+		if err := store.ValidateProject(project); err != nil {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	result, err := h.requestStore(r).DeletePromptPermanently(slug)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, store.ErrConflict):
+			h.respondErrorFrom(w, http.StatusConflict, err)
+		default:
+			reqctx.Logger(r.Context()).Error("failed to delete prompt", "error", err, "slug", slug)
+			h.respondError(w, http.StatusInternalServerError, "Failed to delete prompt")
+		}
+		return
+	}
+	reqctx.Logger(r.Context()).Info("prompt deleted", "slug", slug, "project", result.Project, "versions", result.Versions)
+	h.chatDeleted(result)
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Purge archived prompts
+// Permanently deletes prompts archived more than ?older_than_days ago, in
+// every project; all of them by default. With ?dry_run=true, reports what
+// would be deleted without deleting it.
+func (h *Handler) handlePurgeArchived(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	var days int
+	if v := r.URL.Query().Get("older_than_days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			h.respondError(w, http.StatusBadRequest, "invalid older_than_days: use a number of days, 0 or more")
+			return
+		}
+		days = n
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days)
+	result, err := h.requestStore(r).PurgeArchivedPrompts(cutoff, dryRun)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to purge archived prompts", "error", err, "dry_run", dryRun)
+		h.respondError(w, http.StatusInternalServerError, "Failed to purge archived prompts")
+		return
+	}
+	reqctx.Logger(r.Context()).Info("archived prompts purged", "dry_run", dryRun, "prompts", len(result.Prompts))
+	if !dryRun {
+		for _, deleted := range result.Prompts {
+			h.chatDeleted(deleted)
+		}
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Recompute derived columns
+// Repairs current versions that point at missing versions and fills in
+// missing content hashes, for databases edited by hand.
+func (h *Handler) handleRecompute(w http.ResponseWriter, r *http.Request) {
+	result, err := h.requestStore(r).RecomputeDenormalized()
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to recompute derived columns", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to recompute derived columns")
+		return
+	}
+	reqctx.Logger(r.Context()).Info("derived columns recomputed",
+		"current_versions", result.CurrentVersions,
+		"content_hashes", result.ContentHashes,
+		"hash_mismatches", result.HashMismatches,
+	)
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// maxAuditLimit bounds one page of a prompt's audit log or the admin audit log
+const maxAuditLimit = 1000
+
+// Handler: List admin audit entries
+// Maintenance operations, newest first.
+func (h *Handler) handleListAdminAudit(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	offset := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			h.respondFieldError(w, http.StatusBadRequest, "limit", "invalid limit: use a number from 1 to "+strconv.Itoa(maxAuditLimit))
+			return
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			h.respondFieldError(w, http.StatusBadRequest, "offset", "invalid offset: use a number, 0 or more")
+			return
+		}
+		offset = n
+	}
+
+	results, err := h.Store.ListAdminAuditEntries(limit, offset)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to list admin audit entries", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list admin audit entries")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, results)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+const (
+	// defaultStaleDays is how long a prompt can go unfetched before analytics
+	// calls it stale, unless stale_days is set
+	defaultStaleDays = 30
+	// defaultAnalyticsLimit and maxAnalyticsLimit bound each analytics list
+	defaultAnalyticsLimit = 10
+	maxAnalyticsLimit     = 100
+)
+
+// Handler: Registry analytics
+// Flushes pending usage counts first so the lists include recent fetches.
+func (h *Handler) handleGetAnalytics(w http.ResponseWriter, r *http.Request) {
+	staleDays := defaultStaleDays
+	if v := r.URL.Query().Get("stale_days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			h.respondError(w, http.StatusBadRequest, "invalid stale_days: use a positive number")
+			return
+		}
+		staleDays = n
+	}
+	limit := defaultAnalyticsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAnalyticsLimit {
+			h.respondError(w, http.StatusBadRequest, "invalid limit: use a number from 1 to "+strconv.Itoa(maxAnalyticsLimit))
+			return
+		}
+		limit = n
+	}
+
+	if err := h.Usage.Flush(); err != nil {
+		reqctx.Logger(r.Context()).Warn("serving analytics without unflushed fetches", "error", err)
+	}
+	staleSince := time.Now().UTC().AddDate(0, 0, -staleDays)
+	result, err := h.requestStore(r).GetAnalytics(staleSince, limit)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to get analytics", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get analytics")
+		return
+	}
+	result.StaleDays = staleDays
+
+	h.respondJSON(w, http.StatusOK, result)
+}
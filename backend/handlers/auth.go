@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/shahram/prompt-registry/backend/auth"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+// Middleware: Authentication
+// Requires h.Auth to accept every /api/* and /ws request, and stores the
+// caller's identity in the request context. Admin routes keep their own token,
+// and the public gallery, health, metrics, docs, and frontend stay open.
+func (h *Handler) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.Auth == nil || !h.requiresAuth(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id, err := h.Auth.Authenticate(r)
+		if err != nil {
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				reqctx.Logger(r.Context()).Info("authentication failed", "reason", err, "method", r.Method, "path", r.URL.Path)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				h.respondError(w, http.StatusUnauthorized, "Authentication required")
+				return
+			}
+			reqctx.Logger(r.Context()).Error("failed to authenticate request", "error", err, "path", r.URL.Path)
+			w.Header().Set("Retry-After", "30")
+			h.respondError(w, http.StatusServiceUnavailable, "Authentication unavailable, try again shortly")
+			return
+		}
+		reqctx.SetIdentity(r.Context(), id)
+		next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+	})
+}
+
+// requiresAuth reports whether a path is behind the authenticator
+func (h *Handler) requiresAuth(path string) bool {
+	if h.Public.Enabled && (path == h.publicPrefix() || strings.HasPrefix(path, h.publicPrefix()+"/")) {
+		return false
+	}
+	if strings.HasPrefix(path, "/api/admin/") || path == "/api/capabilities" {
+		return false
+	}
+	return strings.HasPrefix(path, "/api/") || path == "/ws"
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// frontendBasePath is the frontend's BASE_PATH declaration, rewritten when
+// the registry is mounted under a prefix
+const frontendBasePath = "const BASE_PATH = '';"
+
+type basePathKey struct{}
+
+// NormalizeBasePath returns p as "/prefix" without a trailing slash, or ""
+// for the root
+func NormalizeBasePath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// basePath returns the normalized BasePath
+func (h *Handler) basePath() string {
+	return NormalizeBasePath(h.BasePath)
+}
+
+// basePathFrom returns the prefix basePathMiddleware stripped from the
+// request, so links written into responses can put it back
+func basePathFrom(ctx context.Context) string {
+	base, _ := ctx.Value(basePathKey{}).(string)
+	return base
+}
+
+// Middleware: Base path
+// Strips BasePath so every route and middleware sees paths relative to the
+// root. The bare prefix redirects to the prefix with a trailing slash, and
+// paths outside it are not found.
+func (h *Handler) basePathMiddleware(next http.Handler) http.Handler {
+	base := h.basePath()
+	if base == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == base {
+			target := base + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+			return
+		}
+		path, ok := strings.CutPrefix(r.URL.Path, base)
+		if !ok || !strings.HasPrefix(path, "/") {
+			h.respondError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		r2 := r.WithContext(context.WithValue(r.Context(), basePathKey{}, base))
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = path
+		r2.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, base)
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// withBasePath points the frontend's API, WebSocket, and navigation URLs at
+// base
+func withBasePath(page []byte, base string) []byte {
+	if base == "" {
+		return page
+	}
+	quoted, _ := json.Marshal(base)
+	return bytes.Replace(page, []byte(frontendBasePath), []byte("const BASE_PATH = "+string(quoted)+";"), 1)
+}
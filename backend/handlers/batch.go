@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+// maxBatchGetSlugs bounds the prompts one batch get can fetch
+const maxBatchGetSlugs = 100
+
+// Handler: Batch get prompts
+// Fetches the current version of several prompts in one query, for services
+// that load their prompts at startup. Slugs that don't exist or that the
+// caller can't read are listed as missing rather than failing the request.
+func (h *Handler) handleBatchGetPrompts(w http.ResponseWriter, r *http.Request) {
+	var input models.BatchGetPromptsInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	if len(input.Slugs) == 0 {
+		h.respondFieldError(w, http.StatusBadRequest, "slugs", "slugs is required")
+		return
+	}
+
+	seen := make(map[string]bool, len(input.Slugs))
+	slugs := make([]string, 0, len(input.Slugs))
+	for _, slug := range input.Slugs {
+		if !seen[slug] {
+			seen[slug] = true
+			slugs = append(slugs, slug)
+		}
+	}
+	if len(slugs) > maxBatchGetSlugs {
+		h.respondFieldError(w, http.StatusBadRequest, "slugs", fmt.Sprintf("too many slugs: at most %d per request", maxBatchGetSlugs))
+		return
+	}
+
+	prompts, err := h.requestStore(r).GetPromptsBySlugs(slugs)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to batch get prompts", "error", err, "slugs", len(slugs))
+		h.respondError(w, http.StatusInternalServerError, "Failed to get prompts")
+		return
+	}
+
+	result := models.BatchGetPromptsResult{Prompts: prompts, Missing: []string{}}
+	found := make(map[string]bool, len(prompts))
+	for _, prompt := range prompts {
+		found[prompt.Slug] = true
+		h.recordUsage(r, prompt.Slug, prompt.CurrentVersion.VersionNumber)
+	}
+	for _, slug := range slugs {
+		if !found[slug] {
+			result.Missing = append(result.Missing, slug)
+		}
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/shahram/prompt-registry/backend/auth"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// APIVersion is the version of the API described by openapi.json
+const APIVersion = "1.0.0"
+
+// DefaultMaxContentBytes is the default limit on a prompt version's content
+const DefaultMaxContentBytes = 1 << 20
+
+// authSchemes says how callers send credentials for each built-in auth method
+var authSchemes = map[string]string{
+	"apikey": "bearer",
+	"oidc":   "bearer",
+	"mtls":   "client_certificate",
+}
+
+// contentTooLarge reports the first content over MaxContentBytes, or nil
+func (h *Handler) contentTooLarge(contents ...string) error {
+	if h.MaxContentBytes <= 0 {
+		return nil
+	}
+	for _, content := range contents {
+		if len(content) > h.MaxContentBytes {
+			return fieldErrorf("content", "content is %d bytes; the limit is %d bytes", len(content), h.MaxContentBytes)
+		}
+	}
+	return nil
+}
+
+// messageText joins a chat version's message contents so contentTooLarge
+// limits the version as a whole
+func messageText(messages []models.Message) string {
+	var b strings.Builder
+	for _, m := range messages {
+		b.WriteString(m.Content)
+	}
+	return b.String()
+}
+
+// Handler: Capabilities
+// Describes enabled features and enforced limits. It is served without
+// authentication so clients can find out how to authenticate.
+func (h *Handler) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	method := h.AuthMethod
+	if method == "" {
+		method = "none"
+	}
+	execution := h.Providers != nil && h.Providers.Len() > 0
+	result := models.Capabilities{
+		APIVersion: APIVersion,
+		Features: models.CapabilityFeatures{
+			Execution:      execution,
+			Providers:      []string{},
+			GitSync:        h.Sync != nil,
+			PublicGallery:  h.Public.Enabled,
+			Admin:          h.AdminToken != "",
+			Backups:        h.Backups != nil,
+			ReadOnly:       h.ReadOnly(),
+			SemanticSearch: h.Embeddings != nil,
+		},
+		Limits: models.CapabilityLimits{
+			MaxContentBytes:      max(h.MaxContentBytes, 0),
+			MaxBodyBytes:         max(h.MaxBodyBytes, 0),
+			MaxTitleLength:       max(h.MaxTitleLength, 0),
+			MaxDescriptionLength: max(h.MaxDescriptionLength, 0),
+			MaxMetadataBytes:     max(h.MaxMetadataBytes, 0),
+			MaxImportVersions:    store.MaxImportVersions,
+			MaxReleaseItems:      store.MaxReleaseItems,
+			MaxCommentLength:     store.MaxCommentLength,
+		},
+		Auth: models.CapabilityAuth{
+			Method:  method,
+			Scheme:  authSchemes[method],
+			Methods: auth.Methods(),
+			CSRF:    h.CSRF.Enabled,
+		},
+	}
+	if execution {
+		result.Features.Providers = h.Providers.Names()
+	}
+	if h.Embeddings != nil {
+		result.Features.SemanticSearchModel = h.Embeddings.Model()
+	}
+	if limit := h.rateLimitConfig(); limit.RequestsPerSecond > 0 {
+		result.Limits.RateLimit = &models.RateLimit{
+			RequestsPerSecond: limit.RequestsPerSecond,
+			Burst:             limit.Burst,
+		}
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+const (
+	// captureDefaultDuration applies when a capture session doesn't set one
+	captureDefaultDuration = 10 * time.Minute
+	// captureMaxDuration bounds how long a session can record
+	captureMaxDuration = time.Hour
+	// captureBufferSize is how many exchanges are kept; older ones are overwritten
+	captureBufferSize = 200
+	// captureMaxBodyBytes is how much of each request and response body is kept
+	captureMaxBodyBytes = 64 * 1024
+)
+
+// redactedHeaders are never stored in captured exchanges
+var redactedHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+	"X-Api-Key":     true,
+	"X-Csrf-Token":  true,
+}
+
+// requestCapture records full request/response pairs matching a filter into a
+// fixed ring buffer while a time-boxed session is active. At most one session
+// exists; starting a new one discards what the previous one recorded.
+type requestCapture struct {
+	mu        sync.Mutex
+	slug      string
+	apiKey    string
+	startedAt time.Time
+	expiresAt time.Time
+	exchanges []models.CapturedExchange
+	next      int
+	now       func() time.Time
+}
+
+func newRequestCapture() *requestCapture {
+	return &requestCapture{now: time.Now}
+}
+
+// start begins a new session, clearing any previous recordings
+func (c *requestCapture) start(slug, apiKey string, duration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.slug, c.apiKey = slug, apiKey
+	c.startedAt = c.now()
+	c.expiresAt = c.startedAt.Add(duration)
+	c.exchanges = make([]models.CapturedExchange, 0, captureBufferSize)
+	c.next = 0
+}
+
+// stop ends the session and discards its recordings
+func (c *requestCapture) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.slug, c.apiKey = "", ""
+	c.startedAt, c.expiresAt = time.Time{}, time.Time{}
+	c.exchanges = nil
+	c.next = 0
+}
+
+// filter returns the session's filter, or ok=false when no session is recording
+func (c *requestCapture) filter() (slug, apiKey string, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.expiresAt.IsZero() || !c.now().Before(c.expiresAt) {
+		return "", "", false
+	}
+	return c.slug, c.apiKey, true
+}
+
+// add stores an exchange, overwriting the oldest once the buffer is full
+func (c *requestCapture) add(exchange models.CapturedExchange) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.exchanges == nil {
+		return // session was stopped while the request ran
+	}
+	if len(c.exchanges) < captureBufferSize {
+		c.exchanges = append(c.exchanges, exchange)
+		return
+	}
+	c.exchanges[c.next] = exchange
+	c.next = (c.next + 1) % captureBufferSize
+}
+
+// status reports the session and its exchanges, oldest first. Recordings stay
+// available after the session expires until it is stopped or replaced.
+func (c *requestCapture) status() models.CaptureStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := models.CaptureStatus{
+		Active:    !c.expiresAt.IsZero() && c.now().Before(c.expiresAt),
+		Slug:      c.slug,
+		APIKey:    maskKey(c.apiKey),
+		Exchanges: make([]models.CapturedExchange, 0, len(c.exchanges)),
+	}
+	if !c.startedAt.IsZero() {
+		startedAt, expiresAt := c.startedAt, c.expiresAt
+		status.StartedAt, status.ExpiresAt = &startedAt, &expiresAt
+	}
+	status.Exchanges = append(status.Exchanges, c.exchanges[c.next:]...)
+	status.Exchanges = append(status.Exchanges, c.exchanges[:c.next]...)
+	return status
+}
+
+// maskKey keeps only the last four characters of a key
+func maskKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) <= 4 {
+		return "..."
+	}
+	return "..." + key[len(key)-4:]
+}
+
+// captureWriter tees the response into a bounded buffer
+type captureWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+	truncated  bool
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	cw.statusCode = code
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(p []byte) (int, error) {
+	if room := captureMaxBodyBytes - cw.body.Len(); room < len(p) {
+		cw.body.Write(p[:max(room, 0)])
+		cw.truncated = true
+	} else {
+		cw.body.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses while they are captured
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Middleware: Request capture
+// While a capture session is active, records requests that match its slug
+// and/or API key. Admin and WebSocket traffic is never captured.
+func (h *Handler) captureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug, apiKey, ok := h.capture.filter()
+		if !ok || strings.HasPrefix(r.URL.Path, "/api/admin/") || r.URL.Path == "/ws" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if apiKey != "" && requestAPIKey(r) != apiKey {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Keep the head of the body and hand the handler an equivalent reader
+		requestBody, _ := io.ReadAll(io.LimitReader(r.Body, captureMaxBodyBytes+1))
+		truncated := len(requestBody) > captureMaxBodyBytes
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(requestBody), r.Body), r.Body}
+		requestHeaders := flattenHeaders(r.Header)
+
+		start := time.Now()
+		cw := &captureWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(cw, r)
+
+		// The route's slug is known by now
+		if slug != "" && reqctx.Slug(r.Context()) != slug {
+			return
+		}
+		h.capture.add(models.CapturedExchange{
+			Time:            start.UTC(),
+			Method:          r.Method,
+			Path:            r.URL.Path,
+			Query:           r.URL.RawQuery,
+			RequestHeaders:  requestHeaders,
+			RequestBody:     string(requestBody[:min(len(requestBody), captureMaxBodyBytes)]),
+			Status:          cw.statusCode,
+			ResponseHeaders: flattenHeaders(cw.Header()),
+			ResponseBody:    cw.body.String(),
+			Truncated:       truncated || cw.truncated,
+			DurationMs:      time.Since(start).Milliseconds(),
+		})
+	})
+}
+
+// requestAPIKey returns the key a request authenticates with: a bearer token,
+// or else the X-API-Key header, the same order the authenticator checks them
+func requestAPIKey(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && strings.TrimSpace(token) != "" {
+		return strings.TrimSpace(token)
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// flattenHeaders joins repeated headers and drops credentials
+func flattenHeaders(header http.Header) map[string]string {
+	flat := make(map[string]string, len(header))
+	for name, values := range header {
+		if redactedHeaders[name] {
+			flat[name] = "[redacted]"
+			continue
+		}
+		flat[name] = strings.Join(values, ", ")
+	}
+	return flat
+}
+
+// Handler: Start request capture
+func (h *Handler) handleStartCapture(w http.ResponseWriter, r *http.Request) {
+	var input models.StartCaptureInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	if input.Slug == "" && input.APIKey == "" {
+		h.respondError(w, http.StatusBadRequest, "slug or api_key is required")
+		return
+	}
+
+	duration := time.Duration(input.DurationSeconds) * time.Second
+	switch {
+	case input.DurationSeconds < 0 || duration > captureMaxDuration:
+		h.respondError(w, http.StatusBadRequest, "duration_seconds must be between 1 and 3600")
+		return
+	case duration == 0:
+		duration = captureDefaultDuration
+	}
+
+	h.capture.start(input.Slug, input.APIKey, duration)
+	reqctx.Logger(r.Context()).Info("request capture started",
+		"slug", input.Slug,
+		"api_key", maskKey(input.APIKey),
+		"duration_seconds", int(duration.Seconds()),
+	)
+	h.respondJSON(w, http.StatusOK, h.capture.status())
+}
+
+// Handler: Get captured requests
+func (h *Handler) handleGetCapture(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, h.capture.status())
+}
+
+// Handler: Stop request capture
+func (h *Handler) handleStopCapture(w http.ResponseWriter, r *http.Request) {
+	h.capture.stop()
+	reqctx.Logger(r.Context()).Info("request capture stopped")
+	w.WriteHeader(http.StatusNoContent)
+}
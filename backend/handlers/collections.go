@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// respondCollectionError maps a collection store error to a status code
+func (h *Handler) respondCollectionError(w http.ResponseWriter, r *http.Request, err error, action string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		h.respondErrorFrom(w, http.StatusNotFound, err)
+	case errors.Is(err, store.ErrValidation):
+		h.respondErrorFrom(w, http.StatusBadRequest, err)
+	case errors.Is(err, store.ErrConflict):
+		h.respondErrorFrom(w, http.StatusConflict, err)
+	default:
+		reqctx.Logger(r.Context()).Error("failed to "+action, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to "+action)
+	}
+}
+
+// Handler: Create collection
+// The parent named by the path must exist, so a tree is built top down.
+func (h *Handler) handleCreateCollection(w http.ResponseWriter, r *http.Request) {
+	var input models.CreateCollectionInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	if err := h.fieldTooLong(input.Title, input.Description); err != nil {
+		h.respondErrorFrom(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	result, err := h.requestStore(r).CreateCollection(input)
+	if err != nil {
+		h.respondCollectionError(w, r, err, "create collection")
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, result)
+}
+
+// Handler: List collections
+func (h *Handler) handleListCollections(w http.ResponseWriter, r *http.Request) {
+	results, err := h.requestStore(r).ListCollections()
+	if err != nil {
+		h.respondCollectionError(w, r, err, "list collections")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// Handler: List the prompts in a collection
+// With recursive=true, includes the prompts in the collections below it.
+func (h *Handler) handleListCollectionPrompts(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid collection id")
+		return
+	}
+
+	results, err := h.requestStore(r).ListCollectionPrompts(id, r.URL.Query().Get("recursive") == "true")
+	if err != nil {
+		h.respondCollectionError(w, r, err, "list collection prompts")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// Handler: Add a prompt to a collection
+func (h *Handler) handleAddCollectionPrompt(w http.ResponseWriter, r *http.Request) {
+	h.setInCollection(w, r, true)
+}
+
+// Handler: Remove a prompt from a collection
+func (h *Handler) handleRemoveCollectionPrompt(w http.ResponseWriter, r *http.Request) {
+	h.setInCollection(w, r, false)
+}
+
+// setInCollection adds a prompt to a collection or removes it
+func (h *Handler) setInCollection(w http.ResponseWriter, r *http.Request, member bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid collection id")
+		return
+	}
+
+	if err := h.requestStore(r).SetPromptInCollection(id, r.PathValue("slug"), member); err != nil {
+		h.respondCollectionError(w, r, err, "update collection")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Handler: List comments on a prompt version
+func (h *Handler) handleListVersionComments(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil || version < 1 {
+		h.respondError(w, http.StatusBadRequest, "Invalid version number")
+		return
+	}
+
+	results, err := h.requestStore(r).ListVersionComments(slug, version)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to list comments", "error", err, "slug", slug, "version", version)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list comments")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// Handler: Comment on a prompt version
+func (h *Handler) handleAddVersionComment(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil || version < 1 {
+		h.respondError(w, http.StatusBadRequest, "Invalid version number")
+		return
+	}
+
+	var input models.AddCommentInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	if n := utf8.RuneCountInString(input.Body); n > store.MaxCommentLength {
+		h.respondFieldError(w, http.StatusUnprocessableEntity, "body", fmt.Sprintf("comment is %d characters; the limit is %d", n, store.MaxCommentLength))
+		return
+	}
+
+	result, err := h.requestStore(r).AddVersionComment(slug, version, input.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, store.ErrValidation):
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+		default:
+			reqctx.Logger(r.Context()).Error("failed to add comment", "error", err, "slug", slug, "version", version)
+			h.respondError(w, http.StatusInternalServerError, "Failed to add comment")
+		}
+		return
+	}
+
+	reqctx.Logger(r.Context()).Info("comment added", "slug", slug, "version", version, "comment_id", result.ID)
+	h.respondJSON(w, http.StatusCreated, result)
+}
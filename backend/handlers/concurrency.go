@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// applyIfMatch turns an If-Match header on a version create into the base
+// version the store checks under its write lock. The header holds either the
+// current version number or the ETag from GET /prompts/{slug}; an ETag is
+// resolved to the version it was served for, so the check itself stays
+// atomic. "*" and a missing header skip the check. Responds and returns false
+// when the request can't go ahead.
+func (h *Handler) applyIfMatch(w http.ResponseWriter, r *http.Request, slug string, input *models.CreatePromptVersionInput) bool {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return true
+	}
+
+	base, err := h.ifMatchVersion(r, slug, header)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, store.ErrVersionConflict):
+			h.respondErrorFrom(w, http.StatusConflict, err)
+		default:
+			reqctx.Logger(r.Context()).Error("failed to check If-Match", "error", err, "slug", slug)
+			h.respondError(w, http.StatusInternalServerError, "Failed to check If-Match")
+		}
+		return false
+	}
+	if input.BaseVersion != nil && *input.BaseVersion != base {
+		h.respondFieldError(w, http.StatusBadRequest, "base_version", "If-Match and base_version disagree")
+		return false
+	}
+	input.BaseVersion = &base
+	return true
+}
+
+// ifMatchVersion resolves an If-Match header to a version number
+func (h *Handler) ifMatchVersion(r *http.Request, slug, header string) (int, error) {
+	if version, err := strconv.Atoi(strings.Trim(header, `"`)); err == nil {
+		return version, nil
+	}
+
+	prompt, err := h.requestStore(r).GetPromptBySlug(slug)
+	if err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(prompt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode prompt: %w", err)
+	}
+	if !etagMatches(header, jsonETag(body)) {
+		return 0, fmt.Errorf("%w: prompt %q has changed since the given ETag", store.ErrVersionConflict, slug)
+	}
+	return prompt.CurrentVersion.VersionNumber, nil
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// CORSConfig controls which browser origins may call the API
+type CORSConfig struct {
+	// AllowedOrigins are origins such as "https://app.example.com"; "*"
+	// allows any origin
+	AllowedOrigins []string
+	// AllowedHeaders are request headers browsers may send cross-origin
+	AllowedHeaders []string
+}
+
+// DefaultCORSConfig returns the CORS defaults: any origin, JSON bodies only
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedHeaders: []string{"Content-Type"},
+	}
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, or ""
+// when it isn't allowed
+func (c CORSConfig) allowOrigin(origin string) string {
+	if slices.Contains(c.AllowedOrigins, "*") {
+		return "*"
+	}
+	if origin != "" && slices.Contains(c.AllowedOrigins, origin) {
+		return origin
+	}
+	return ""
+}
+
+// Middleware: CORS
+func (h *Handler) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cors := h.corsConfig()
+		if !slices.Contains(cors.AllowedOrigins, "*") {
+			w.Header().Add("Vary", "Origin")
+		}
+		if origin := cors.allowOrigin(r.Header.Get("Origin")); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		}
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// CSRF token cookie and header names
+const (
+	csrfCookieName = "prompt_registry_csrf"
+	csrfHeader     = "X-CSRF-Token"
+)
+
+// CSRFConfig protects browser writes from cross-site request forgery
+type CSRFConfig struct {
+	// Enabled requires a token from GET /api/csrf on state-changing requests
+	// sent by a browser without an Authorization or X-API-Key header
+	Enabled bool
+	// Secret signs tokens so they survive restarts and are accepted by every
+	// replica; empty uses a random key, and tokens expire with the process
+	Secret string
+}
+
+// DefaultCSRFConfig enables CSRF protection with a random key
+func DefaultCSRFConfig() CSRFConfig {
+	return CSRFConfig{Enabled: true}
+}
+
+// csrfKey returns the key tokens are signed with
+func (h *Handler) csrfKey() []byte {
+	if h.CSRF.Secret != "" {
+		return []byte(h.CSRF.Secret)
+	}
+	return h.csrfRandomKey
+}
+
+// newCSRFToken returns a random nonce and its signature, base64url encoded
+// and joined with "."
+func (h *Handler) newCSRFToken() string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	return base64.RawURLEncoding.EncodeToString(nonce) + "." + h.csrfSignature(nonce)
+}
+
+func (h *Handler) csrfSignature(nonce []byte) string {
+	mac := hmac.New(sha256.New, h.csrfKey())
+	mac.Write(nonce)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validCSRFToken reports whether token was issued with the current key
+func (h *Handler) validCSRFToken(token string) bool {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	nonce, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(nonce) != 16 {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(h.csrfSignature(nonce)))
+}
+
+// fromBrowser reports whether r looks like a browser request that carries
+// ambient credentials: the browser adds Origin or Sec-Fetch-Site to
+// cross-site writes, and sends the CSRF cookie once it has one. A request
+// with an explicit Authorization or X-API-Key header can't be forged by
+// another site, since browsers never add those on their own.
+func fromBrowser(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" || r.Header.Get("X-API-Key") != "" {
+		return false
+	}
+	if r.Header.Get("Origin") != "" || r.Header.Get("Sec-Fetch-Site") != "" {
+		return true
+	}
+	_, err := r.Cookie(csrfCookieName)
+	return err == nil
+}
+
+// Middleware: CSRF
+// Requires browser POST, PUT, PATCH, and DELETE requests to echo the CSRF
+// cookie's token in X-CSRF-Token. A forged cross-site request can neither
+// read the token nor, with SameSite=Strict, send the cookie.
+func (h *Handler) csrfMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !h.CSRF.Enabled || !fromBrowser(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cookie, err := r.Cookie(csrfCookieName)
+		token := r.Header.Get(csrfHeader)
+		if err != nil || token == "" ||
+			subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) != 1 ||
+			!h.validCSRFToken(token) {
+			h.respondError(w, http.StatusForbidden, "missing or invalid CSRF token: send the token from GET /api/csrf in the "+csrfHeader+" header")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Handler: CSRF token
+// Sets the CSRF cookie and returns the same token for the X-CSRF-Token
+// header. A still-valid cookie is kept, so tabs sharing it don't invalidate
+// each other.
+func (h *Handler) handleCSRFToken(w http.ResponseWriter, r *http.Request) {
+	token := h.newCSRFToken()
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && h.validCSRFToken(cookie.Value) {
+		token = cookie.Value
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     basePathFrom(r.Context()) + "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set("Cache-Control", "no-store")
+	h.respondJSON(w, http.StatusOK, models.CSRFToken{Token: token, Header: csrfHeader})
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/shahram/prompt-registry/backend/markdown"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Handler: Replace a prompt's Markdown description
+func (h *Handler) handleSetDescription(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	var input models.SetDescriptionInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	if err := h.fieldTooLong("", input.Description); err != nil {
+		h.respondErrorFrom(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	result, err := h.requestStore(r).SetPromptDescription(slug, input.Description)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to set description", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to set description")
+		return
+	}
+
+	h.notifyWebhooks(h.requestStore(r), WebhookPromptUpdated, result.Slug, result.CurrentVersion.VersionNumber)
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Get a prompt's description rendered as sanitized HTML
+func (h *Handler) handleGetDescriptionHTML(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	result, err := h.requestStore(r).GetPromptBySlug(slug)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to get prompt", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get prompt")
+		return
+	}
+
+	respondHTMLFragment(w, markdown.ToHTML(result.Description))
+}
+
+// Handler: Render Markdown to sanitized HTML without saving it, so editors
+// can preview a description
+func (h *Handler) handlePreviewMarkdown(w http.ResponseWriter, r *http.Request) {
+	var input models.MarkdownPreviewInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	respondHTMLFragment(w, markdown.ToHTML(input.Markdown))
+}
+
+// respondHTMLFragment writes rendered Markdown or a diff. The policy stops scripts and
+// external loads should the fragment ever be opened on its own.
+func respondHTMLFragment(w http.ResponseWriter, fragment string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fragment))
+}
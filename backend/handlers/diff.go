@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/shahram/prompt-registry/backend/diff"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Diff output formats selected with ?format=
+const (
+	diffFormatUnified = "unified" // plain-text unified diff, the default
+	diffFormatHTML    = "html"    // side-by-side table fragment
+	diffFormatJSON    = "json"    // models.VersionDiff
+)
+
+// defaultDiffContext is how many unchanged lines surround each hunk when
+// ?context= isn't given
+const defaultDiffContext = 3
+
+// Handler: Diff two versions of a prompt
+// ?from= and ?to= take the same selectors as a version lookup. to defaults
+// to the current version and from to the version before to; diffing from
+// version 1 without a from compares against empty content.
+func (h *Handler) handleDiffVersions(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	switch format {
+	case "":
+		format = diffFormatUnified
+	case diffFormatUnified, diffFormatHTML, diffFormatJSON:
+	default:
+		h.respondFieldError(w, http.StatusBadRequest, "format",
+			fmt.Sprintf("invalid format %q: want %q, %q, or %q", format, diffFormatUnified, diffFormatHTML, diffFormatJSON))
+		return
+	}
+	context := defaultDiffContext
+	if v := query.Get("context"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			h.respondFieldError(w, http.StatusBadRequest, "context", "invalid context: use a number of lines, 0 or more")
+			return
+		}
+		context = n
+	}
+
+	s := h.requestStore(r)
+	toSelector := query.Get("to")
+	if toSelector == "" {
+		toSelector = versionCurrent
+	}
+	to, err := selectVersion(s, slug, toSelector)
+	var from models.PromptVersion
+	if err == nil {
+		if fromSelector := query.Get("from"); fromSelector != "" {
+			from, err = selectVersion(s, slug, fromSelector)
+		} else if to.VersionNumber > 1 {
+			from, err = s.GetPromptVersion(slug, to.VersionNumber-1)
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, errInvalidVersion):
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+		default:
+			reqctx.Logger(r.Context()).Error("failed to get versions to diff", "error", err, "slug", slug)
+			h.respondError(w, http.StatusInternalServerError, "Failed to get versions")
+		}
+		return
+	}
+
+	switch format {
+	case diffFormatHTML:
+		respondHTMLFragment(w, diff.HTML(from.Content, to.Content, context))
+	case diffFormatJSON:
+		h.respondJSON(w, http.StatusOK, versionDiff(slug, from, to, context))
+	default:
+		w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(diff.Unified(
+			fmt.Sprintf("%s@v%d", slug, from.VersionNumber),
+			fmt.Sprintf("%s@v%d", slug, to.VersionNumber),
+			from.Content, to.Content, context)))
+	}
+}
+
+// versionDiff describes the hunks between two versions for JSON clients
+func versionDiff(slug string, from, to models.PromptVersion, context int) models.VersionDiff {
+	result := models.VersionDiff{
+		Slug:        slug,
+		FromVersion: from.VersionNumber,
+		ToVersion:   to.VersionNumber,
+		Hunks:       []models.DiffHunk{},
+	}
+	for _, hunk := range diff.Hunks(from.Content, to.Content, context) {
+		out := models.DiffHunk{
+			OldStart: hunk.OldStart,
+			OldLines: hunk.OldLines,
+			NewStart: hunk.NewStart,
+			NewLines: hunk.NewLines,
+			Lines:    make([]models.DiffLine, len(hunk.Ops)),
+		}
+		for i, op := range hunk.Ops {
+			line := models.DiffLine{Op: "equal", Text: op.Text}
+			switch op.Kind {
+			case diff.Delete:
+				line.Op = "delete"
+				result.LinesRemoved++
+			case diff.Insert:
+				line.Op = "insert"
+				result.LinesAdded++
+			}
+			out.Lines[i] = line
+		}
+		result.Hunks = append(result.Hunks, out)
+	}
+	return result
+}
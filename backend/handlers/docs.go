@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/shahram/prompt-registry/backend/markdown"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Handler: Get the latest revision of a prompt's docs
+func (h *Handler) handleGetDocs(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	result, ok := h.lookupDocs(w, r, slug, 0)
+	if !ok {
+		return
+	}
+
+	h.respondJSONWithETag(w, r, result)
+}
+
+// Handler: Get a specific revision of a prompt's docs
+func (h *Handler) handleGetDocsRevision(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	revision, err := strconv.Atoi(r.PathValue("revision"))
+	if err != nil || revision < 1 {
+		h.respondError(w, http.StatusBadRequest, "Invalid revision number")
+		return
+	}
+
+	result, ok := h.lookupDocs(w, r, slug, revision)
+	if !ok {
+		return
+	}
+
+	h.respondJSONWithETag(w, r, result)
+}
+
+// Handler: Get the latest docs rendered as sanitized HTML
+func (h *Handler) handleGetDocsHTML(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	result, ok := h.lookupDocs(w, r, slug, 0)
+	if !ok {
+		return
+	}
+
+	respondHTMLFragment(w, markdown.ToHTML(result.Content))
+}
+
+// Handler: List revisions of a prompt's docs
+func (h *Handler) handleListDocs(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	results, err := h.requestStore(r).ListPromptDocs(slug)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to list docs", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list docs")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// Handler: Save a new revision of a prompt's docs
+func (h *Handler) handleSetDocs(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	var input models.SetDocsInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	result, err := h.requestStore(r).SetPromptDocs(slug, input.Content)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to set docs", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to set docs")
+		return
+	}
+
+	h.notifyWebhooks(h.requestStore(r), WebhookPromptUpdated, slug, 0)
+	reqctx.Logger(r.Context()).Info("docs updated", "slug", slug, "revision", result.Revision)
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// lookupDocs loads a docs revision, or the latest when revision is 0,
+// responding with an error when it can't
+func (h *Handler) lookupDocs(w http.ResponseWriter, r *http.Request, slug string, revision int) (models.PromptDocs, bool) {
+	result, err := h.requestStore(r).GetPromptDocs(slug, revision)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return result, false
+		}
+		reqctx.Logger(r.Context()).Error("failed to get docs", "error", err, "slug", slug, "revision", revision)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get docs")
+		return result, false
+	}
+	return result, true
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/providers"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// DefaultEmbeddingInterval is how often prompts are checked for missing or
+// stale embeddings when the interval isn't configured
+const DefaultEmbeddingInterval = time.Minute
+
+// DefaultEmbeddingBatchSize is how many prompts are embedded per provider call
+const DefaultEmbeddingBatchSize = 32
+
+// DefaultSemanticSearchLimit is how many results semantic search returns
+// when the request doesn't say
+const DefaultSemanticSearchLimit = 10
+
+// semanticSearchTimeout bounds embedding a search query
+const semanticSearchTimeout = 30 * time.Second
+
+// EmbeddingIndexer keeps an embedding of every prompt up to date in the
+// background, re-embedding prompts whose title, description, or current
+// version changed, and embeds search queries with the same model.
+type EmbeddingIndexer struct {
+	// BatchSize is how many prompts are sent to the embedder per call
+	BatchSize int
+
+	embedder providers.Embedder
+	store    store.Store
+	logger   *slog.Logger
+
+	run    sync.Mutex  // held while an indexing run is in progress
+	paused atomic.Bool // skips background runs, e.g. in read-only mode
+	cancel context.CancelFunc
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewEmbeddingIndexer creates an indexer embedding s's prompts with e; call
+// Start to index in the background
+func NewEmbeddingIndexer(e providers.Embedder, s store.Store, logger *slog.Logger) *EmbeddingIndexer {
+	return &EmbeddingIndexer{BatchSize: DefaultEmbeddingBatchSize, embedder: e, store: s, logger: logger}
+}
+
+// Model names the embedding model prompts are indexed with
+func (x *EmbeddingIndexer) Model() string {
+	return x.embedder.Model()
+}
+
+// Run embeds every prompt whose embedding is missing or stale, a batch at a
+// time, and returns how many it embedded
+func (x *EmbeddingIndexer) Run(ctx context.Context) (int, error) {
+	x.run.Lock()
+	defer x.run.Unlock()
+
+	model := x.embedder.Model()
+	var embedded int
+	for {
+		sources, err := x.store.ListStaleEmbeddings(model, max(x.BatchSize, 1))
+		if err != nil {
+			x.logger.Error("embedding indexing failed", "error", err, "model", model)
+			return embedded, err
+		}
+		if len(sources) == 0 {
+			break
+		}
+		texts := make([]string, len(sources))
+		for i, source := range sources {
+			texts[i] = source.Text
+		}
+		vectors, err := x.embedder.Embed(ctx, texts)
+		if err == nil {
+			err = x.store.SaveEmbeddings(model, sources, vectors)
+		}
+		if err != nil {
+			x.logger.Error("embedding indexing failed", "error", err, "model", model, "embedded", embedded)
+			return embedded, err
+		}
+		embedded += len(sources)
+	}
+	if embedded > 0 {
+		x.logger.Info("embedded prompts", "model", model, "count", embedded)
+	}
+	return embedded, nil
+}
+
+// Start indexes now and then every interval in the background until Stop
+// is called
+func (x *EmbeddingIndexer) Start(interval time.Duration) {
+	var ctx context.Context
+	ctx, x.cancel = context.WithCancel(context.Background())
+	x.stop = make(chan struct{})
+	x.done = make(chan struct{})
+	go func() {
+		defer close(x.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			// Failures are logged; the next tick tries again
+			if !x.paused.Load() {
+				x.Run(ctx)
+			}
+			select {
+			case <-x.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Pause skips background runs until it is called again with false
+func (x *EmbeddingIndexer) Pause(paused bool) {
+	x.paused.Store(paused)
+}
+
+// Stop ends background indexing, cancelling a run in progress
+func (x *EmbeddingIndexer) Stop() {
+	if x.stop != nil {
+		x.cancel()
+		close(x.stop)
+		<-x.done
+		x.stop = nil
+	}
+}
+
+// Handler: Semantic search
+// Embeds ?q= and returns the prompts closest to it in meaning, which finds
+// prompts phrased differently from the query. Prompts are searchable once
+// the indexer has embedded them.
+func (h *Handler) handleSemanticSearch(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		h.respondFieldError(w, http.StatusBadRequest, "q", "q is required")
+		return
+	}
+	limit := DefaultSemanticSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			h.respondFieldError(w, http.StatusBadRequest, "limit", "invalid limit: use a number")
+			return
+		}
+		limit = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), semanticSearchTimeout)
+	defer cancel()
+	vectors, err := h.Embeddings.embedder.Embed(ctx, []string{q})
+	if err == nil && len(vectors) != 1 {
+		err = errors.New("embedder returned no vector")
+	}
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to embed search query", "error", err)
+		h.respondError(w, http.StatusBadGateway, "Failed to embed search query")
+		return
+	}
+
+	results, err := h.requestStore(r).SemanticSearch(h.Embeddings.Model(), vectors[0], limit)
+	if err != nil {
+		if errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to search prompts", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to search prompts")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, results)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// Handler: Create environment
+// Adds it to the end of the project's promotion pipeline.
+func (h *Handler) handleCreateEnvironment(w http.ResponseWriter, r *http.Request) {
+	var input models.CreateEnvironmentInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	result, err := h.requestStore(r).CreateEnvironment(input)
+	if err != nil {
+		h.respondReleaseError(w, r, err, "create environment")
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, result)
+}
+
+// Handler: List environments
+func (h *Handler) handleListEnvironments(w http.ResponseWriter, r *http.Request) {
+	results, err := h.requestStore(r).ListEnvironments()
+	if err != nil {
+		h.respondReleaseError(w, r, err, "list environments")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// Handler: Get environment
+// Lists what is live in it: each prompt's version, and who promoted it when.
+func (h *Handler) handleGetEnvironment(w http.ResponseWriter, r *http.Request) {
+	result, err := h.requestStore(r).GetEnvironment(r.PathValue("name"))
+	if err != nil {
+		h.respondReleaseError(w, r, err, "get environment")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Delete environment
+// Its label and release history are kept.
+func (h *Handler) handleDeleteEnvironment(w http.ResponseWriter, r *http.Request) {
+	if err := h.requestStore(r).DeleteEnvironment(r.PathValue("name")); err != nil {
+		h.respondReleaseError(w, r, err, "delete environment")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler: Promote a prompt version into an environment
+// Records the promotion as a release of the environment's label.
+func (h *Handler) handlePromote(w http.ResponseWriter, r *http.Request) {
+	var input models.PromoteInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	s := h.requestStore(r)
+	result, err := s.Promote(r.PathValue("slug"), input)
+	if err != nil {
+		h.respondReleaseError(w, r, err, "promote prompt")
+		return
+	}
+
+	h.notifyRelease(s, result)
+	h.respondJSON(w, http.StatusCreated, result)
+}
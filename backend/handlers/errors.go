@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Error codes in ErrorResponse. Clients branch on the code; the message is
+// for people and may be reworded.
+const (
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeInvalidJSON      = "INVALID_JSON"
+	CodeUnauthenticated  = "UNAUTHENTICATED"
+	CodePermissionDenied = "PERMISSION_DENIED"
+	CodeNotFound         = "NOT_FOUND"
+	CodePromptNotFound   = "PROMPT_NOT_FOUND"
+	CodeVersionNotFound  = "VERSION_NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodeSlugConflict     = "SLUG_CONFLICT"
+	CodeVersionConflict  = "VERSION_CONFLICT"
+	CodePayloadTooLarge  = "PAYLOAD_TOO_LARGE"
+	CodeRateLimited      = "RATE_LIMITED"
+	CodeInternal         = "INTERNAL"
+	CodeUpstreamFailed   = "UPSTREAM_FAILED"
+	CodeUnavailable      = "UNAVAILABLE"
+	CodeReadOnly         = "READ_ONLY"
+)
+
+// statusCodes gives the code for an error known only by its status
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeValidationFailed,
+	http.StatusUnauthorized:          CodeUnauthenticated,
+	http.StatusForbidden:             CodePermissionDenied,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnprocessableEntity:   CodeValidationFailed,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusBadGateway:            CodeUpstreamFailed,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+}
+
+// kindCodes gives the code for store errors of a narrower kind than their
+// status shows
+var kindCodes = []struct {
+	kind error
+	code string
+}{
+	{store.ErrPromptNotFound, CodePromptNotFound},
+	{store.ErrVersionNotFound, CodeVersionNotFound},
+	{store.ErrSlugTaken, CodeSlugConflict},
+	{store.ErrVersionConflict, CodeVersionConflict},
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Field names the request field a validation error is about, when
+	// there is one
+	Field     string `json:"field,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Helper: Respond with error. The request ID the middleware set on the
+// response is repeated in the body, so it survives being pasted into a ticket.
+func (h *Handler) respondError(w http.ResponseWriter, status int, message string) {
+	h.writeError(w, status, ErrorResponse{Code: codeForStatus(status), Message: message})
+}
+
+// respondErrorFrom responds with err's message, coded by its store error kind
+// where that says more than status does
+func (h *Handler) respondErrorFrom(w http.ResponseWriter, status int, err error) {
+	resp := ErrorResponse{Code: codeForStatus(status), Message: err.Error(), Field: errorField(err)}
+	for _, k := range kindCodes {
+		if errors.Is(err, k.kind) {
+			resp.Code = k.code
+			break
+		}
+	}
+	h.writeError(w, status, resp)
+}
+
+// respondFieldError responds to a validation error about one request field
+func (h *Handler) respondFieldError(w http.ResponseWriter, status int, field, message string) {
+	h.writeError(w, status, ErrorResponse{Code: CodeValidationFailed, Message: message, Field: field})
+}
+
+// fieldError is a validation error about one request field raised by the
+// handlers themselves
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (e *fieldError) Error() string { return e.err.Error() }
+func (e *fieldError) Unwrap() error { return e.err }
+
+// fieldErrorf formats a fieldError about field
+func fieldErrorf(field, format string, args ...any) error {
+	return &fieldError{field: field, err: fmt.Errorf(format, args...)}
+}
+
+// errorField returns the request field err is about, or ""
+func errorField(err error) string {
+	var fe *fieldError
+	if errors.As(err, &fe) {
+		return fe.field
+	}
+	return store.Field(err)
+}
+
+// writeError counts and sends an error response
+func (h *Handler) writeError(w http.ResponseWriter, status int, resp ErrorResponse) {
+	h.Metrics.IncrementHTTPErrors()
+	resp.RequestID = w.Header().Get(headerRequestID)
+	h.respondJSON(w, status, resp)
+}
+
+// codeForStatus returns the code for an error response with status
+func codeForStatus(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeValidationFailed
+}
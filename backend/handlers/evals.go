@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/shahram/prompt-registry/backend/eval"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Handler: Create dataset
+func (h *Handler) handleCreateDataset(w http.ResponseWriter, r *http.Request) {
+	var input models.CreateDatasetInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	result, err := h.Store.CreateDataset(input)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			h.respondErrorFrom(w, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to create dataset", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create dataset")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, result)
+}
+
+// Handler: List datasets
+func (h *Handler) handleListDatasets(w http.ResponseWriter, r *http.Request) {
+	results, err := h.Store.ListDatasets()
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to list datasets", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list datasets")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// Handler: Get dataset
+func (h *Handler) handleGetDataset(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	result, err := h.Store.GetDataset(name)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to get dataset", "error", err, "name", name)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get dataset")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Start eval run
+// Creates the run and evaluates the dataset in the background; poll
+// GET /api/evals/{id} for progress and results.
+func (h *Handler) handleStartEval(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	var input models.StartEvalInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	if input.Dataset == "" {
+		h.respondError(w, http.StatusBadRequest, "dataset cannot be empty")
+		return
+	}
+	if input.Scorer == "" {
+		input.Scorer = eval.ScorerExact
+	}
+	if err := eval.ValidateScorer(input.Scorer); err != nil {
+		h.respondErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	if input.Concurrency == 0 {
+		input.Concurrency = eval.DefaultConcurrency
+	}
+	if input.Concurrency < 1 || input.Concurrency > eval.MaxConcurrency {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("concurrency must be between 1 and %d", eval.MaxConcurrency))
+		return
+	}
+
+	prompt, err := h.requestStore(r).GetPromptBySlug(slug)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to get prompt", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get prompt")
+		return
+	}
+	version := prompt.CurrentVersion
+	if input.Version != 0 && input.Version != version.VersionNumber {
+		if version, err = h.requestStore(r).GetPromptVersion(slug, input.Version); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				h.respondErrorFrom(w, http.StatusNotFound, err)
+				return
+			}
+			reqctx.Logger(r.Context()).Error("failed to get version", "error", err, "slug", slug, "version", input.Version)
+			h.respondError(w, http.StatusInternalServerError, "Failed to get version")
+			return
+		}
+	}
+
+	provider, model, err := h.resolveProvider(versionExecution(prompt.Execution, version.ModelConfig), input.Provider, input.Model)
+	if err != nil {
+		h.respondErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	dataset, err := h.Store.GetDataset(input.Dataset)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to get dataset", "error", err, "name", input.Dataset)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get dataset")
+		return
+	}
+
+	run, err := h.requestStore(r).CreateEvalRun(models.EvalRun{
+		Slug:          slug,
+		VersionNumber: version.VersionNumber,
+		Dataset:       dataset.Name,
+		Provider:      provider.Name(),
+		Model:         model,
+		Scorer:        input.Scorer,
+	})
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to create eval run", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create eval run")
+		return
+	}
+
+	h.evals.Start(eval.Job{
+		RunID:       run.ID,
+		Content:     version.Content,
+		Messages:    version.Messages,
+		ModelConfig: version.ModelConfig,
+		Variables:   prompt.Variables,
+		Items:       dataset.Items,
+		Provider:    provider,
+		Model:       model,
+		Scorer:      input.Scorer,
+		Concurrency: input.Concurrency,
+	})
+	reqctx.Logger(r.Context()).Info("eval run started",
+		"run_id", run.ID,
+		"slug", slug,
+		"version", version.VersionNumber,
+		"dataset", dataset.Name,
+		"items", len(dataset.Items),
+		"provider", provider.Name(),
+	)
+	h.respondJSON(w, http.StatusAccepted, run)
+}
+
+// Handler: List eval runs for a prompt
+func (h *Handler) handleListEvals(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	results, err := h.requestStore(r).ListEvalRuns(slug)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to list eval runs", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list eval runs")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// Handler: Get eval run
+func (h *Handler) handleGetEval(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid eval run id")
+		return
+	}
+
+	result, err := h.Store.WithContext(r.Context()).GetEvalRun(id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to get eval run", "error", err, "run_id", id)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get eval run")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
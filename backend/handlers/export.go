@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Handler: Export registry
+// Streams every prompt with its full version history as a models.Export
+// document, one prompt at a time, gzip-compressed when ?gzip=true.
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	compress := false
+	if gzipStr := r.URL.Query().Get("gzip"); gzipStr != "" {
+		var err error
+		if compress, err = strconv.ParseBool(gzipStr); err != nil {
+			h.respondError(w, http.StatusBadRequest, "gzip must be true or false")
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	// Large registries can outlast the server's write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		reqctx.Logger(r.Context()).Warn("failed to clear write deadline", "error", err)
+	}
+
+	exportedAt := time.Now().UTC()
+	filename := "prompt-registry-" + exportedAt.Format("20060102T150405Z") + ".json"
+
+	// The response starts with the first prompt so a store error before then
+	// can still be reported as a normal JSON error
+	var out io.Writer = w
+	var gz *gzip.Writer
+	started := false
+	begin := func() error {
+		started = true
+		if compress {
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".gz"))
+			gz = gzip.NewWriter(w)
+			out = gz
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		}
+		w.WriteHeader(http.StatusOK)
+		_, err := fmt.Fprintf(out, `{"format_version":%d,"exported_at":%q,"prompts":[`,
+			models.ExportFormatVersion, exportedAt.Format(time.RFC3339Nano))
+		return err
+	}
+
+	count, err := h.requestStore(r).ExportPrompts(func(prompt models.ExportedPrompt) error {
+		separator := ","
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+			separator = ""
+		}
+		data, err := json.Marshal(prompt)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(out, separator+"\n"); err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	})
+	if err != nil {
+		if !started {
+			reqctx.Logger(r.Context()).Error("failed to export prompts", "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to export prompts")
+			return
+		}
+		// Headers are gone; cutting the document short leaves invalid JSON
+		// so clients can't mistake a partial export for a complete one
+		reqctx.Logger(r.Context()).Error("export interrupted", "error", err, "prompts_written", count)
+		return
+	}
+
+	if !started {
+		if err := begin(); err != nil {
+			reqctx.Logger(r.Context()).Error("failed to write export", "error", err)
+			return
+		}
+	}
+	if _, err := io.WriteString(out, "\n]}\n"); err != nil {
+		reqctx.Logger(r.Context()).Error("failed to write export", "error", err)
+		return
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			reqctx.Logger(r.Context()).Error("failed to compress export", "error", err)
+			return
+		}
+	}
+	reqctx.Logger(r.Context()).Info("registry exported", "prompts", count, "gzip", compress)
+}
+
+// Handler: Import registry
+// Loads a models.Export document, gzip-compressed when sent with
+// Content-Encoding: gzip. Prompts keep their version numbers and original
+// creation times; slugs that already exist are skipped. MaxBodyBytes also
+// limits the decompressed document.
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "Invalid gzip body")
+			return
+		}
+		defer gz.Close()
+		body = gz
+		if h.MaxBodyBytes > 0 {
+			body = http.MaxBytesReader(w, gz, h.MaxBodyBytes)
+		}
+	}
+
+	var input models.Export
+	if err := json.NewDecoder(body).Decode(&input); err != nil {
+		h.respondDecodeError(w, r, err)
+		return
+	}
+	if input.FormatVersion != models.ExportFormatVersion {
+		h.respondError(w, http.StatusBadRequest,
+			fmt.Sprintf("unsupported format_version %d (want %d)", input.FormatVersion, models.ExportFormatVersion))
+		return
+	}
+
+	for _, prompt := range input.Prompts {
+		if err := h.fieldTooLong(prompt.Title, prompt.Description); err != nil {
+			h.respondError(w, http.StatusUnprocessableEntity, fmt.Sprintf("prompt %q: %v", prompt.Slug, err))
+			return
+		}
+		if err := h.metadataTooLarge(prompt.Metadata); err != nil {
+			h.respondError(w, http.StatusUnprocessableEntity, fmt.Sprintf("prompt %q: %v", prompt.Slug, err))
+			return
+		}
+		for _, version := range prompt.Versions {
+			if err := h.contentTooLarge(version.Content); err != nil {
+				h.respondError(w, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("prompt %q version %d: %v", prompt.Slug, version.VersionNumber, err))
+				return
+			}
+			if err := h.metadataTooLarge(version.Metadata); err != nil {
+				h.respondError(w, http.StatusUnprocessableEntity,
+					fmt.Sprintf("prompt %q version %d: %v", prompt.Slug, version.VersionNumber, err))
+				return
+			}
+		}
+	}
+
+	result, err := h.requestStore(r).ImportPrompts(input.Prompts)
+	if err != nil {
+		if errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to import prompts", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to import prompts")
+		return
+	}
+
+	imported := make(map[string]bool, len(result.Imported))
+	for _, slug := range result.Imported {
+		imported[slug] = true
+	}
+	for _, prompt := range input.Prompts {
+		if !imported[prompt.Slug] {
+			continue
+		}
+		// A slug repeated in the document is only imported once
+		delete(imported, prompt.Slug)
+		current := prompt.Versions[len(prompt.Versions)-1].VersionNumber
+		h.Metrics.IncrementPromptsCreated()
+		for range prompt.Versions {
+			h.Metrics.IncrementPromptVersionsCreated()
+		}
+		h.notifyWebhooks(h.requestStore(r), WebhookPromptCreated, prompt.Slug, current)
+	}
+	reqctx.Logger(r.Context()).Info("registry imported",
+		"imported", len(result.Imported),
+		"skipped", len(result.Skipped),
+		"versions", result.Versions,
+	)
+	h.respondJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// graphQLRequest is the standard GraphQL-over-HTTP request body
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables"`
+	OperationName string         `json:"operationName"`
+}
+
+// graphQLPrompt is the resolver source for the Prompt type. It unifies list
+// summaries and single-prompt lookups, which carry different shapes in the store.
+type graphQLPrompt struct {
+	Slug                 string
+	Title                string
+	Description          string
+	Public               bool
+	CurrentVersionNumber int
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	OriginalCreatedAt    *time.Time
+	ArchivedAt           *time.Time
+	current              *models.PromptVersion
+	store                store.Store // scoped to the prompt's project
+}
+
+func graphQLPromptFromSummary(s store.Store, p models.PromptSummary) graphQLPrompt {
+	return graphQLPrompt{
+		Slug:                 p.Slug,
+		Title:                p.Title,
+		Description:          p.Description,
+		Public:               p.Public,
+		CurrentVersionNumber: p.CurrentVersion,
+		CreatedAt:            p.CreatedAt,
+		UpdatedAt:            p.UpdatedAt,
+		OriginalCreatedAt:    p.OriginalCreatedAt,
+		ArchivedAt:           p.ArchivedAt,
+		store:                s,
+	}
+}
+
+func graphQLPromptFromDetail(s store.Store, p models.PromptWithCurrentVersion) graphQLPrompt {
+	current := p.CurrentVersion
+	return graphQLPrompt{
+		Slug:                 p.Slug,
+		Title:                p.Title,
+		Description:          p.Description,
+		Public:               p.Public,
+		CurrentVersionNumber: current.VersionNumber,
+		CreatedAt:            p.CreatedAt,
+		UpdatedAt:            p.UpdatedAt,
+		OriginalCreatedAt:    p.OriginalCreatedAt,
+		ArchivedAt:           p.ArchivedAt,
+		current:              &current,
+		store:                s,
+	}
+}
+
+// newGraphQLSchema builds the read-only query schema over the store.
+// Field names mirror the REST JSON (snake_case) so clients can share models.
+func newGraphQLSchema(s store.Store) (graphql.Schema, error) {
+	versionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Version",
+		Fields: graphql.Fields{
+			"version_number":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"content":             &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"created_at":          &graphql.Field{Type: graphql.DateTime},
+			"original_created_at": &graphql.Field{Type: graphql.DateTime},
+			"pinned":              &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"redacted_at":         &graphql.Field{Type: graphql.DateTime},
+			"original_sha256":     &graphql.Field{Type: graphql.String},
+			"content_sha256":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	promptField := func(typ graphql.Output, get func(p graphQLPrompt) any) *graphql.Field {
+		return &graphql.Field{
+			Type: typ,
+			Resolve: func(rp graphql.ResolveParams) (any, error) {
+				return get(rp.Source.(graphQLPrompt)), nil
+			},
+		}
+	}
+
+	promptType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Prompt",
+		Fields: graphql.Fields{
+			"project":                promptField(graphql.NewNonNull(graphql.String), func(p graphQLPrompt) any { return p.store.Project() }),
+			"slug":                   promptField(graphql.NewNonNull(graphql.String), func(p graphQLPrompt) any { return p.Slug }),
+			"title":                  promptField(graphql.NewNonNull(graphql.String), func(p graphQLPrompt) any { return p.Title }),
+			"description":            promptField(graphql.String, func(p graphQLPrompt) any { return p.Description }),
+			"public":                 promptField(graphql.NewNonNull(graphql.Boolean), func(p graphQLPrompt) any { return p.Public }),
+			"current_version_number": promptField(graphql.NewNonNull(graphql.Int), func(p graphQLPrompt) any { return p.CurrentVersionNumber }),
+			"created_at":             promptField(graphql.DateTime, func(p graphQLPrompt) any { return p.CreatedAt }),
+			"updated_at":             promptField(graphql.DateTime, func(p graphQLPrompt) any { return p.UpdatedAt }),
+			"original_created_at": promptField(graphql.DateTime, func(p graphQLPrompt) any {
+				if p.OriginalCreatedAt == nil {
+					return nil
+				}
+				return *p.OriginalCreatedAt
+			}),
+			"archived_at": promptField(graphql.DateTime, func(p graphQLPrompt) any {
+				if p.ArchivedAt == nil {
+					return nil
+				}
+				return *p.ArchivedAt
+			}),
+			"current_version": &graphql.Field{
+				Type: versionType,
+				Resolve: func(rp graphql.ResolveParams) (any, error) {
+					p := rp.Source.(graphQLPrompt)
+					if p.current != nil {
+						return *p.current, nil
+					}
+					return p.store.GetPromptVersion(p.Slug, p.CurrentVersionNumber)
+				},
+			},
+			"versions": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(versionType))),
+				Args: graphql.FieldConfigArgument{
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 100},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: func(rp graphql.ResolveParams) (any, error) {
+					p := rp.Source.(graphQLPrompt)
+					return p.store.ListPromptVersions(p.Slug, rp.Args["limit"].(int), rp.Args["offset"].(int))
+				},
+			},
+			"version_count": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(rp graphql.ResolveParams) (any, error) {
+					p := rp.Source.(graphQLPrompt)
+					return p.store.CountPromptVersions(p.Slug)
+				},
+			},
+			"version": &graphql.Field{
+				Type: versionType,
+				Args: graphql.FieldConfigArgument{
+					"number": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(rp graphql.ResolveParams) (any, error) {
+					p := rp.Source.(graphQLPrompt)
+					return p.store.GetPromptVersion(p.Slug, rp.Args["number"].(int))
+				},
+			},
+		},
+	})
+
+	statsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Stats",
+		Fields: graphql.Fields{
+			"total_prompts":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"total_prompt_versions": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"prompts": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(promptType))),
+				Args: graphql.FieldConfigArgument{
+					"limit":            &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 100},
+					"offset":           &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+					"include_archived": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					"sort":             &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+					"order":            &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+					"created_after":    &graphql.ArgumentConfig{Type: graphql.String},
+					"created_before":   &graphql.ArgumentConfig{Type: graphql.String},
+					"updated_after":    &graphql.ArgumentConfig{Type: graphql.String},
+					"updated_before":   &graphql.ArgumentConfig{Type: graphql.String},
+					"min_versions":     &graphql.ArgumentConfig{Type: graphql.Int},
+					"max_versions":     &graphql.ArgumentConfig{Type: graphql.Int},
+					"starred":          &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					"q":                &graphql.ArgumentConfig{Type: graphql.String},
+					"project":          &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: store.DefaultProject},
+				},
+				Resolve: func(rp graphql.ResolveParams) (any, error) {
+					ps, err := projectArg(s, rp)
+					if err != nil {
+						return nil, err
+					}
+					list := ps.ListPrompts
+					if rp.Args["include_archived"].(bool) {
+						list = ps.ListAllPrompts
+					}
+					sort := store.PromptSort{Field: rp.Args["sort"].(string), Order: rp.Args["order"].(string)}
+					filter, err := parsePromptFilter(func(name string) string {
+						switch v := rp.Args[name].(type) {
+						case string:
+							return v
+						case int:
+							return strconv.Itoa(v)
+						case bool:
+							return strconv.FormatBool(v)
+						}
+						return ""
+					})
+					if err != nil {
+						return nil, err
+					}
+					summaries, err := list(rp.Args["limit"].(int), rp.Args["offset"].(int), sort, filter)
+					if err != nil {
+						return nil, err
+					}
+					prompts := make([]graphQLPrompt, len(summaries))
+					for i, summary := range summaries {
+						prompts[i] = graphQLPromptFromSummary(ps, summary)
+					}
+					return prompts, nil
+				},
+			},
+			"prompt": &graphql.Field{
+				Type: promptType,
+				Args: graphql.FieldConfigArgument{
+					"slug":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"project": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: store.DefaultProject},
+				},
+				Resolve: func(rp graphql.ResolveParams) (any, error) {
+					ps, err := projectArg(s, rp)
+					if err != nil {
+						return nil, err
+					}
+					slug := rp.Args["slug"].(string)
+					if err := ps.AuthorizePrompt(slug, store.AccessRead); err != nil {
+						return nil, err
+					}
+					prompt, err := ps.GetPromptBySlug(slug)
+					if err != nil {
+						return nil, err
+					}
+					return graphQLPromptFromDetail(ps, prompt), nil
+				},
+			},
+			"stats": &graphql.Field{
+				Type: graphql.NewNonNull(statsType),
+				Args: graphql.FieldConfigArgument{
+					"project": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: store.DefaultProject},
+				},
+				Resolve: func(rp graphql.ResolveParams) (any, error) {
+					ps, err := projectArg(s, rp)
+					if err != nil {
+						return nil, err
+					}
+					return ps.GetProjectStats()
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// projectArg scopes s to the resolver's project argument and the caller,
+// hiding projects owned by orgs the caller doesn't belong to
+func projectArg(s store.Store, rp graphql.ResolveParams) (store.Store, error) {
+	project := rp.Args["project"].(string)
+	if err := store.ValidateProject(project); err != nil {
+		return nil, err
+	}
+	ps := s.WithContext(rp.Context).InProject(project)
+	if err := ps.AuthorizeProject(); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// Handler: GraphQL queries
+func (h *Handler) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				h.respondError(w, http.StatusBadRequest, "Invalid variables JSON")
+				return
+			}
+		}
+	} else if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Query == "" {
+		h.respondError(w, http.StatusBadRequest, "query cannot be empty")
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         h.graphQLSchema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        r.Context(),
+	})
+	if result.HasErrors() {
+		reqctx.Logger(r.Context()).Warn("graphql query returned errors", "errors", result.Errors)
+	}
+
+	// GraphQL reports field errors in the body alongside partial data
+	h.respondJSON(w, http.StatusOK, result)
+}
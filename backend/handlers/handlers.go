@@ -1,78 +1,364 @@
 package handlers
 
 import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/graphql-go/graphql"
+	"github.com/shahram/prompt-registry/backend/auth"
+	"github.com/shahram/prompt-registry/backend/backup"
+	"github.com/shahram/prompt-registry/backend/eval"
+	"github.com/shahram/prompt-registry/backend/gitsync"
 	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/providers"
+	"github.com/shahram/prompt-registry/backend/reqctx"
 	"github.com/shahram/prompt-registry/backend/store"
 )
 
 //go:embed frontend.html
 var frontendHTML []byte
 
+// headerRequestID carries a request's ID, from clients that set one and back
+// in every response
+const headerRequestID = "X-Request-ID"
+
 // Handler holds dependencies for HTTP handlers
 type Handler struct {
-	Store   store.Store
-	Logger  *slog.Logger
-	Metrics *Metrics
+	Store         store.Store
+	Logger        *slog.Logger
+	Metrics       *Metrics
+	Hub           *Hub
+	Integrations  *Integrations
+	Webhooks      *Webhooks
+	Notifications *Notifications
+	Usage         *UsageTracker
+	Retention     *RetentionJanitor
+	Public        PublicConfig
+	RateLimit     RateLimitConfig
+	CORS          CORSConfig
+	CSRF          CSRFConfig
+	BaseURL       string // absolute URL used for canonical links
+	// BasePath mounts every route under this prefix, e.g. "/prompt-registry";
+	// empty serves from the root
+	BasePath string
+
+	// Auth must accept every /api/* request when set; nil leaves the API open
+	Auth auth.Authenticator
+	// AuthMethod names Auth's method for /api/capabilities
+	AuthMethod string
+	// MaxContentBytes rejects prompt versions with larger content; 0 means no limit
+	MaxContentBytes int
+	// MaxBodyBytes rejects request bodies over this size with 413; 0 means no limit
+	MaxBodyBytes int64
+	// MaxTitleLength and MaxDescriptionLength reject prompts whose title or
+	// description has more characters with 422; 0 means no limit
+	MaxTitleLength       int
+	MaxDescriptionLength int
+	// MaxMetadataBytes rejects prompt and version metadata whose JSON is
+	// larger with 422; 0 means no limit
+	MaxMetadataBytes int
+	// AdminToken enables /api/admin/* routes when set; requests must send it as a bearer token
+	AdminToken string
+	// Providers enables prompt execution when it holds at least one provider
+	Providers *providers.Registry
+	// Embeddings enables semantic search when set
+	Embeddings *EmbeddingIndexer
+	// Sync enables POST /api/sync when set
+	Sync *gitsync.Syncer
+	// Backups adds scheduled backup status to /health when set
+	Backups *backup.Scheduler
+	// Readiness controls the checks behind /readyz
+	Readiness ReadinessConfig
+	// LatencyBudget tracks latency SLOs per endpoint, failing fast on render
+	// and resolve routes that opt in once their budget is exhausted
+	LatencyBudget LatencyBudgetConfig
+	// AccessLog controls sampling, optional fields, and excluded paths for
+	// access log lines
+	AccessLog AccessLogConfig
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For header is
+	// believed when telling clients apart by IP, for rate limits and access
+	// logs; other requests are known by their connection's address
+	TrustedProxies []netip.Prefix
+
+	graphQLSchema graphql.Schema
+	maintenance   atomic.Bool
+	readOnlySince atomic.Pointer[time.Time] // nil unless read-only mode is on
+	writes        atomic.Int64              // non-admin write requests currently being served
+	live          *liveStats
+	capture       *requestCapture
+	evals         *eval.Runner
+	logSampler    *logSampler
+	latency       *latencyBudget
+	apiLimiter    *rateLimiter
+	reloadMu      sync.RWMutex // guards CORS and RateLimit
+	csrfRandomKey []byte       // signs CSRF tokens when CSRF.Secret is empty
 }
 
 // New creates a new Handler with initialized metrics
 func New(s store.Store, logger *slog.Logger) *Handler {
-	return &Handler{
-		Store:   s,
-		Logger:  logger,
-		Metrics: NewMetrics(),
+	// The schema is static, so failing to build it is a programming error
+	schema, err := newGraphQLSchema(s)
+	if err != nil {
+		panic(fmt.Sprintf("invalid graphql schema: %v", err))
 	}
+
+	integrations := NewIntegrations(logger)
+	h := &Handler{
+		Store:                s,
+		Logger:               logger,
+		Metrics:              NewMetrics(),
+		Hub:                  NewHub(logger),
+		Integrations:         integrations,
+		Webhooks:             NewWebhooks(integrations, logger),
+		Notifications:        NewNotifications(integrations, logger),
+		Usage:                NewUsageTracker(s, logger),
+		Retention:            NewRetentionJanitor(s, logger),
+		Public:               DefaultPublicConfig(),
+		RateLimit:            DefaultRateLimitConfig(),
+		CORS:                 DefaultCORSConfig(),
+		CSRF:                 DefaultCSRFConfig(),
+		AccessLog:            DefaultAccessLogConfig(),
+		LatencyBudget:        DefaultLatencyBudgetConfig(),
+		Readiness:            DefaultReadinessConfig(),
+		MaxContentBytes:      DefaultMaxContentBytes,
+		MaxBodyBytes:         DefaultMaxBodyBytes,
+		MaxTitleLength:       DefaultMaxTitleLength,
+		MaxDescriptionLength: DefaultMaxDescriptionLength,
+		MaxMetadataBytes:     DefaultMaxMetadataBytes,
+		BaseURL:              "http://localhost:8080",
+		graphQLSchema:        schema,
+		live:                 newLiveStats(),
+		capture:              newRequestCapture(),
+		evals:                eval.NewRunner(s, logger),
+		logSampler:           newLogSampler(),
+		latency:              newLatencyBudget(),
+		csrfRandomKey:        make([]byte, 32),
+	}
+	rand.Read(h.csrfRandomKey)
+	h.Metrics.Register(h.Integrations, h.logSampler, h.latency)
+	return h
 }
 
 // Routes sets up all HTTP routes with middleware
 func (h *Handler) Routes() http.Handler {
 	mux := http.NewServeMux()
 
-	// API routes
-	mux.HandleFunc("POST /api/prompts", h.handleCreatePrompt)
-	mux.HandleFunc("GET /api/prompts", h.handleListPrompts)
-	mux.HandleFunc("GET /api/prompts/{slug}", h.handleGetPrompt)
-	mux.HandleFunc("GET /api/prompts/{slug}/versions", h.handleListVersions)
-	mux.HandleFunc("POST /api/prompts/{slug}/versions", h.handleCreateVersion)
-	mux.HandleFunc("GET /api/prompts/{slug}/versions/{version}", h.handleGetVersion)
+	// API routes. Prompt routes are served for the default project under
+	// /api and for any project under /api/projects/{project}. Routes for a
+	// single prompt also check its access grants.
+	prompts := func(pattern string, handler http.HandlerFunc) {
+		method, path, _ := strings.Cut(pattern, " ")
+		var next http.Handler = handler
+		if strings.Contains(path, "{slug}") {
+			next = h.promptAccessMiddleware(next)
+		}
+		mux.Handle(method+" /api"+path, next)
+		mux.Handle(method+" /api/projects/{project}"+path, h.projectMiddleware(next))
+	}
+	prompts("POST /prompts", h.handleCreatePrompt)
+	prompts("GET /prompts", h.handleListPrompts)
+	if h.Embeddings != nil {
+		prompts("GET /prompts/semantic-search", h.handleSemanticSearch)
+	}
+	prompts("POST /prompts:batchGet", h.handleBatchGetPrompts)
+	prompts("GET /prompts/{slug}", h.handleGetPrompt)
+	prompts("POST /prompts/{slug}/fork", h.handleForkPrompt)
+	prompts("GET /prompts/{slug}/versions", h.handleListVersions)
+	prompts("POST /prompts/{slug}/versions", h.handleCreateVersion)
+	prompts("POST /prompts/{slug}/versions/batch", h.handleImportVersions)
+	prompts("GET /prompts/{slug}/versions/{version}", h.handleGetVersion)
+	prompts("PUT /prompts/{slug}/versions/{version}/pin", h.handleSetVersionPinned)
+	prompts("GET /prompts/{slug}/diff", h.handleDiffVersions)
+	prompts("GET /prompts/{slug}/versions/{version}/comments", h.handleListVersionComments)
+	prompts("POST /prompts/{slug}/versions/{version}/comments", h.handleAddVersionComment)
+	prompts("GET /prompts/{slug}/versions/{version}/approvals", h.handleListApprovals)
+	prompts("POST /prompts/{slug}/versions/{version}/approvals", h.handleApproveVersion)
+	prompts("GET /prompts/{slug}/review-policy", h.handleGetReviewPolicy)
+	prompts("GET /prompts/{slug}/audit", h.handleListAudit)
+	prompts("GET /prompts/{slug}/usage", h.handleGetUsage)
+	prompts("GET /prompts/{slug}/acl", h.handleGetACL)
+	prompts("PUT /prompts/{slug}/acl", h.handleSetACL)
+	prompts("GET /prompts/{slug}/links", h.handleGetLinks)
+	prompts("PUT /prompts/{slug}/links", h.handleSetLinks)
+	prompts("GET /prompts/{slug}/retention", h.handleGetRetention)
+	prompts("PUT /prompts/{slug}/retention", h.handleSetRetention)
+	prompts("DELETE /prompts/{slug}/retention", h.handleClearRetention)
+	prompts("PUT /prompts/{slug}/visibility", h.handleSetVisibility)
+	prompts("PUT /prompts/{slug}/description", h.handleSetDescription)
+	prompts("GET /prompts/{slug}/description.html", h.handleGetDescriptionHTML)
+	prompts("GET /prompts/{slug}/docs", h.handleGetDocs)
+	prompts("PUT /prompts/{slug}/docs", h.handleSetDocs)
+	prompts("GET /prompts/{slug}/docs.html", h.handleGetDocsHTML)
+	prompts("GET /prompts/{slug}/docs/revisions", h.handleListDocs)
+	prompts("GET /prompts/{slug}/docs/revisions/{revision}", h.handleGetDocsRevision)
+	prompts("POST /prompts/{slug}/archive", h.handleArchivePrompt)
+	prompts("POST /prompts/{slug}/unarchive", h.handleUnarchivePrompt)
+	prompts("PUT /prompts/{slug}/star", h.handleStarPrompt)
+	prompts("DELETE /prompts/{slug}/star", h.handleUnstarPrompt)
+	prompts("PUT /prompts/{slug}/variables", h.handleSetVariables)
+	prompts("POST /prompts/{slug}/render", h.handleRender)
+	prompts("GET /prompts/{slug}/labels", h.handleListLabels)
+	prompts("GET /prompts/{slug}/webhooks", h.handleListWebhooks)
+	prompts("POST /prompts/{slug}/webhooks", h.handleAddWebhook)
+	prompts("DELETE /prompts/{slug}/webhooks/{id}", h.handleDeleteWebhook)
+	if h.Providers != nil && h.Providers.Len() > 0 {
+		prompts("POST /prompts/{slug}/execute", h.handleExecute)
+		prompts("PUT /prompts/{slug}/execution", h.handleSetExecution)
+		prompts("POST /prompts/{slug}/evals", h.handleStartEval)
+	}
+	prompts("GET /prompts/{slug}/evals", h.handleListEvals)
+	prompts("POST /releases", h.handleCreateRelease)
+	prompts("GET /releases", h.handleListReleases)
+	prompts("GET /releases/{id}", h.handleGetRelease)
+	prompts("POST /releases/{id}/rollback", h.handleRollbackRelease)
+	prompts("POST /environments", h.handleCreateEnvironment)
+	prompts("GET /environments", h.handleListEnvironments)
+	prompts("GET /environments/{name}", h.handleGetEnvironment)
+	prompts("DELETE /environments/{name}", h.handleDeleteEnvironment)
+	prompts("POST /prompts/{slug}/promote", h.handlePromote)
+	prompts("POST /collections", h.handleCreateCollection)
+	prompts("GET /collections", h.handleListCollections)
+	prompts("GET /collections/{id}/prompts", h.handleListCollectionPrompts)
+	prompts("PUT /collections/{id}/prompts/{slug}", h.handleAddCollectionPrompt)
+	prompts("DELETE /collections/{id}/prompts/{slug}", h.handleRemoveCollectionPrompt)
+	prompts("GET /analytics", h.handleGetAnalytics)
+	prompts("GET /stats", h.handleGetStats)
+	prompts("GET /export", h.handleExport)
+	prompts("POST /import", h.handleImport)
+	mux.HandleFunc("GET /api/capabilities", h.handleCapabilities)
+	mux.HandleFunc("GET /api/csrf", h.handleCSRFToken)
+	mux.HandleFunc("GET /api/projects", h.handleListProjects)
+	mux.HandleFunc("POST /api/orgs", h.handleCreateOrg)
+	mux.HandleFunc("GET /api/orgs", h.handleListOrgs)
+	mux.HandleFunc("GET /api/orgs/{org}", h.handleGetOrg)
+	mux.HandleFunc("POST /api/orgs/{org}/members", h.handleAddOrgMember)
+	mux.HandleFunc("DELETE /api/orgs/{org}/members/{subject}", h.handleRemoveOrgMember)
+	mux.Handle("PUT /api/orgs/{org}/projects/{project}", h.projectMiddleware(http.HandlerFunc(h.handleAssignProject)))
+	mux.HandleFunc("DELETE /api/orgs/{org}/projects/{project}", h.handleReleaseProject)
+	mux.HandleFunc("GET /api/evals/{id}", h.handleGetEval)
+	mux.HandleFunc("POST /api/datasets", h.handleCreateDataset)
+	mux.HandleFunc("GET /api/datasets", h.handleListDatasets)
+	mux.HandleFunc("GET /api/datasets/{name}", h.handleGetDataset)
+	if h.Sync != nil {
+		mux.HandleFunc("POST /api/sync", h.handleSync)
+	}
+	mux.HandleFunc("POST /api/markdown", h.handlePreviewMarkdown)
+	mux.HandleFunc("GET /api/stats/live", h.handleLiveStats)
+	mux.HandleFunc("GET /api/graphql", h.handleGraphQL)
+	mux.HandleFunc("POST /api/graphql", h.handleGraphQL)
+
+	// Public gallery routes (read-only, rate limited)
+	if h.Public.Enabled {
+		h.mountPublicRoutes(mux)
+	}
+
+	// Admin routes (token protected)
+	if h.AdminToken != "" {
+		h.mountAdminRoutes(mux)
+	}
 
 	// System routes
 	mux.HandleFunc("GET /health", h.handleHealth)
+	mux.HandleFunc("GET /healthz", h.handleLiveness)
+	mux.HandleFunc("GET /readyz", h.handleReadiness)
 	mux.HandleFunc("GET /metrics", h.handleMetrics)
+	mux.HandleFunc("GET /ws", h.handleWebSocket)
+	mux.HandleFunc("GET /openapi.json", h.handleOpenAPI)
+	mux.HandleFunc("GET /docs", h.handleAPIDocs)
 
 	// Catch-all: Serve frontend for all other GET requests (client-side routing)
 	mux.HandleFunc("GET /", h.handleFrontend)
 
 	// Apply middleware
-	var handler http.Handler = mux
+	var routed http.Handler = promptRecorder(mux)
+	if len(h.LatencyBudget.SLOs) > 0 {
+		h.latency.configure(h.LatencyBudget)
+		routed = h.latencyBudgetMiddleware(routed)
+	}
+	routed = h.readOnlyMiddleware(routed)
+	var handler http.Handler = routePatternMiddleware(mux, routed)
+	handler = h.maintenanceMiddleware(handler)
+	// Mounted even when disabled, so Reload can turn the limit on
+	limit := h.rateLimitConfig()
+	h.apiLimiter = newRateLimiter(limit.RequestsPerSecond, limit.Burst)
+	handler = h.apiRateLimitMiddleware(h.apiLimiter, handler)
+	handler = h.csrfMiddleware(handler)
+	handler = h.authMiddleware(handler)
 	handler = h.corsMiddleware(handler)
+	handler = h.captureMiddleware(handler)
+	handler = h.bodyLimitMiddleware(handler)
 	handler = h.loggingMiddleware(handler)
 	handler = h.recoverMiddleware(handler)
+	handler = h.basePathMiddleware(handler)
+	handler = h.requestContextMiddleware(handler)
 
 	return handler
 }
 
+// Middleware: Request context
+// Attaches the request ID and logger that reqctx helpers read, reusing a
+// valid X-Request-ID from the client and echoing it in the response.
+func (h *Handler) requestContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(headerRequestID)
+		if !reqctx.ValidRequestID(id) {
+			id = reqctx.NewRequestID()
+		}
+		w.Header().Set(headerRequestID, id)
+		next.ServeHTTP(w, r.WithContext(reqctx.New(r.Context(), id, h.Logger)))
+	})
+}
+
+// routePatternMiddleware records the pattern mux will route the request to,
+// so the reqctx logger carries it from the handler's first line. next is mux
+// or a middleware wrapping it.
+func routePatternMiddleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			reqctx.SetRoutePattern(r.Context(), pattern)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// promptRecorder records the {project} and {slug} mux matched once it has
+// served the request. Middleware outside the authenticator holds the request
+// from before the authenticator copied it, which never gets path values, so
+// it reads them from reqctx instead.
+func promptRecorder(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+		reqctx.SetProject(r.Context(), r.PathValue("project"))
+		reqctx.SetSlug(r.Context(), r.PathValue("slug"))
+	})
+}
+
 // Middleware: Panic recovery
 func (h *Handler) recoverMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				h.Logger.Error("panic recovered",
+				reqctx.Logger(r.Context()).Error("panic recovered",
 					"error", err,
 					"method", r.Method,
 					"path", r.URL.Path,
 				)
-				h.Metrics.IncrementHTTPErrors()
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				h.respondError(w, http.StatusInternalServerError, "Internal Server Error")
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -83,43 +369,50 @@ func (h *Handler) recoverMiddleware(next http.Handler) http.Handler {
 func (h *Handler) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		h.Metrics.IncrementHTTPRequests()
 
 		// Wrap ResponseWriter to capture status code
 		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
 		next.ServeHTTP(wrapped, r)
 
+		route := reqctx.RoutePattern(r.Context())
+		if route == "" {
+			route = "unmatched"
+		}
+		h.Metrics.ObserveHTTPRequest(route, r.Method, wrapped.statusCode, time.Since(start))
+
+		// The route's slug is known by now; dashboard polling is not counted
+		if r.URL.Path != "/api/stats/live" {
+			h.live.record(wrapped.statusCode, reqctx.Project(r.Context()), reqctx.Slug(r.Context()))
+		}
+
+		if h.AccessLog.excluded(r, wrapped.statusCode) {
+			return
+		}
+		logged, weight := h.logSampler.sample(r, wrapped.statusCode, h.AccessLog.SampleRate)
+		if !logged {
+			return
+		}
 		duration := time.Since(start)
-		h.Logger.Info("http request",
+		attrs := []any{
 			"method", r.Method,
 			"path", r.URL.Path,
 			"status", wrapped.statusCode,
 			"duration_ms", duration.Milliseconds(),
-		)
-	})
-}
-
-// Middleware: CORS
-func (h *Handler) corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
 		}
-
-		next.ServeHTTP(w, r)
+		attrs = append(attrs, h.AccessLog.fieldAttrs(r, wrapped, h.clientIP(r))...)
+		if weight > 1 {
+			attrs = append(attrs, "sample_rate", weight)
+		}
+		reqctx.Logger(r.Context()).Info("http request", attrs...)
 	})
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code
+// responseWriter wraps http.ResponseWriter to capture status code and body size
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int64
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -127,32 +420,97 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer to flush streams
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack lets WebSocket upgrades take over the underlying connection
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	rw.statusCode = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
 // Handler: Create prompt
 func (h *Handler) handleCreatePrompt(w http.ResponseWriter, r *http.Request) {
 	var input models.CreatePromptInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		h.Logger.Error("failed to decode request", "error", err)
-		h.respondError(w, http.StatusBadRequest, "Invalid JSON")
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	if err := h.contentTooLarge(input.Content, messageText(input.Messages)); err != nil {
+		h.respondErrorFrom(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if err := h.fieldTooLong(input.Title, input.Description); err != nil {
+		h.respondErrorFrom(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err := h.metadataTooLarge(input.Metadata); err != nil {
+		h.respondErrorFrom(w, http.StatusUnprocessableEntity, err)
 		return
 	}
 
-	result, err := h.Store.CreatePrompt(input)
+	result, err := h.requestStore(r).CreatePrompt(input)
 	if err != nil {
-		if strings.Contains(err.Error(), "already exists") {
-			h.respondError(w, http.StatusConflict, err.Error())
+		if errors.Is(err, store.ErrConflict) {
+			h.respondErrorFrom(w, http.StatusConflict, err)
 			return
 		}
-		if strings.Contains(err.Error(), "cannot be empty") {
-			h.respondError(w, http.StatusBadRequest, err.Error())
+		if errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
 			return
 		}
-		h.Logger.Error("failed to create prompt", "error", err)
+		reqctx.Logger(r.Context()).Error("failed to create prompt", "error", err)
 		h.respondError(w, http.StatusInternalServerError, "Failed to create prompt")
 		return
 	}
 
 	h.Metrics.IncrementPromptsCreated()
 	h.Metrics.IncrementPromptVersionsCreated()
+	h.notifyWebhooks(h.requestStore(r), WebhookPromptCreated, result.Slug, result.CurrentVersion.VersionNumber)
+	h.respondJSON(w, http.StatusCreated, result)
+}
+
+// Handler: Fork prompt
+func (h *Handler) handleForkPrompt(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	var input models.ForkPromptInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	if err := h.fieldTooLong(input.Title, ""); err != nil {
+		h.respondErrorFrom(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	result, err := h.requestStore(r).ForkPrompt(slug, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, store.ErrConflict):
+			h.respondErrorFrom(w, http.StatusConflict, err)
+		case errors.Is(err, store.ErrValidation):
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+		default:
+			reqctx.Logger(r.Context()).Error("failed to fork prompt", "error", err, "slug", slug)
+			h.respondError(w, http.StatusInternalServerError, "Failed to fork prompt")
+		}
+		return
+	}
+
+	h.Metrics.IncrementPromptsCreated()
+	h.notifyWebhooks(h.requestStore(r), WebhookPromptCreated, result.Slug, result.CurrentVersion.VersionNumber)
 	h.respondJSON(w, http.StatusCreated, result)
 }
 
@@ -173,49 +531,220 @@ func (h *Handler) handleListPrompts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	results, err := h.Store.ListPrompts(limit, offset)
+	includeArchived := r.URL.Query().Get("include_archived") == "true"
+	list := h.requestStore(r).ListPrompts
+	if includeArchived {
+		list = h.requestStore(r).ListAllPrompts
+	}
+	sort := store.PromptSort{Field: r.URL.Query().Get("sort"), Order: r.URL.Query().Get("order")}
+	filter, err := parsePromptFilter(r.URL.Query().Get)
+	if err != nil {
+		h.respondErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	results, err := list(limit, offset, sort, filter)
+	if err != nil {
+		if errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to list prompts", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list prompts")
+		return
+	}
+	total, err := h.requestStore(r).CountPrompts(includeArchived, filter)
 	if err != nil {
-		h.Logger.Error("failed to list prompts", "error", err)
+		reqctx.Logger(r.Context()).Error("failed to count prompts", "error", err)
 		h.respondError(w, http.StatusInternalServerError, "Failed to list prompts")
 		return
 	}
 
+	setPaginationHeaders(w, r, total, limit, offset)
 	h.respondJSON(w, http.StatusOK, results)
 }
 
+// parsePromptFilter reads the prompt listing filters: created_after,
+// created_before, updated_after, and updated_before as RFC 3339 times or
+// YYYY-MM-DD dates (midnight UTC), min_versions and max_versions,
+// starred=true for the caller's starred prompts, and q for text in the title,
+// description, or slug
+func parsePromptFilter(get func(string) string) (store.PromptFilter, error) {
+	var filter store.PromptFilter
+	filter.Starred = get("starred") == "true"
+	filter.Query = strings.TrimSpace(get("q"))
+	times := []struct {
+		name  string
+		field *time.Time
+	}{
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+		{"updated_after", &filter.UpdatedAfter},
+		{"updated_before", &filter.UpdatedBefore},
+	}
+	for _, t := range times {
+		name, value := t.name, get(t.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			if parsed, err = time.Parse(time.DateOnly, value); err != nil {
+				return filter, fmt.Errorf("invalid %s %q: use an RFC 3339 time or YYYY-MM-DD", name, value)
+			}
+		}
+		*t.field = parsed
+	}
+	counts := []struct {
+		name  string
+		field *int
+	}{
+		{"min_versions", &filter.MinVersions},
+		{"max_versions", &filter.MaxVersions},
+	}
+	for _, c := range counts {
+		name, value := c.name, get(c.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("invalid %s %q: use a non-negative number", name, value)
+		}
+		*c.field = n
+	}
+	return filter, nil
+}
+
 // Handler: Get prompt by slug
 func (h *Handler) handleGetPrompt(w http.ResponseWriter, r *http.Request) {
 	slug := r.PathValue("slug")
 
-	result, err := h.Store.GetPromptBySlug(slug)
+	result, err := h.requestStore(r).GetPromptBySlug(slug)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			h.respondError(w, http.StatusNotFound, err.Error())
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to get prompt", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get prompt")
+		return
+	}
+
+	h.recordUsage(r, slug, result.CurrentVersion.VersionNumber)
+	setProvenanceHeaders(w, h.provenance(r, slug, result.CurrentVersion))
+	h.respondJSONWithETag(w, r, result)
+}
+
+// Handler: Archive prompt
+// Hides the prompt from listings without deleting anything; it can still be
+// fetched and rendered by slug.
+func (h *Handler) handleArchivePrompt(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, true)
+}
+
+// Handler: Unarchive prompt
+func (h *Handler) handleUnarchivePrompt(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, false)
+}
+
+// setArchived updates the archived state and responds with the prompt
+func (h *Handler) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	slug := r.PathValue("slug")
+
+	if err := h.requestStore(r).SetPromptArchived(slug, archived); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
 			return
 		}
-		h.Logger.Error("failed to get prompt", "error", err, "slug", slug)
+		reqctx.Logger(r.Context()).Error("failed to set archived state", "error", err, "slug", slug, "archived", archived)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update prompt")
+		return
+	}
+
+	result, err := h.requestStore(r).GetPromptBySlug(slug)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to get prompt", "error", err, "slug", slug)
 		h.respondError(w, http.StatusInternalServerError, "Failed to get prompt")
 		return
 	}
 
+	h.notifyWebhooks(h.requestStore(r), WebhookPromptUpdated, slug, 0)
 	h.respondJSON(w, http.StatusOK, result)
 }
 
 // Handler: List versions
 func (h *Handler) handleListVersions(w http.ResponseWriter, r *http.Request) {
 	slug := r.PathValue("slug")
+	limit := 100
+	offset := 0
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if val, err := strconv.Atoi(limitStr); err == nil && val > 0 && val <= 1000 {
+			limit = val
+		}
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if val, err := strconv.Atoi(offsetStr); err == nil && val >= 0 {
+			offset = val
+		}
+	}
 
-	results, err := h.Store.ListPromptVersions(slug)
+	results, err := h.requestStore(r).ListPromptVersions(slug, limit, offset)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			h.respondError(w, http.StatusNotFound, err.Error())
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
 			return
 		}
-		h.Logger.Error("failed to list versions", "error", err, "slug", slug)
+		reqctx.Logger(r.Context()).Error("failed to list versions", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list versions")
+		return
+	}
+	total, err := h.requestStore(r).CountPromptVersions(slug)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to count versions", "error", err, "slug", slug)
 		h.respondError(w, http.StatusInternalServerError, "Failed to list versions")
 		return
 	}
 
+	setPaginationHeaders(w, r, total, limit, offset)
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// Handler: List a prompt's audit log
+func (h *Handler) handleListAudit(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	limit := 100
+	offset := 0
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			h.respondFieldError(w, http.StatusBadRequest, "limit", "invalid limit: use a number from 1 to "+strconv.Itoa(maxAuditLimit))
+			return
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			h.respondFieldError(w, http.StatusBadRequest, "offset", "invalid offset: use a number, 0 or more")
+			return
+		}
+		offset = n
+	}
+
+	results, err := h.requestStore(r).ListAuditEntries(slug, limit, offset)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to list audit entries", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list audit entries")
+		return
+	}
+
 	h.respondJSON(w, http.StatusOK, results)
 }
 
@@ -224,66 +753,207 @@ func (h *Handler) handleCreateVersion(w http.ResponseWriter, r *http.Request) {
 	slug := r.PathValue("slug")
 
 	var input models.CreatePromptVersionInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		h.Logger.Error("failed to decode request", "error", err)
-		h.respondError(w, http.StatusBadRequest, "Invalid JSON")
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	if err := h.contentTooLarge(input.Content, messageText(input.Messages)); err != nil {
+		h.respondErrorFrom(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if err := h.metadataTooLarge(input.Metadata); err != nil {
+		h.respondErrorFrom(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if !h.applyIfMatch(w, r, slug, &input) {
 		return
 	}
 
-	result, err := h.Store.CreatePromptVersion(slug, input)
+	result, err := h.requestStore(r).CreatePromptVersion(slug, input)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			h.respondError(w, http.StatusNotFound, err.Error())
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
 			return
 		}
-		if strings.Contains(err.Error(), "cannot be empty") {
-			h.respondError(w, http.StatusBadRequest, err.Error())
+		if errors.Is(err, store.ErrConflict) {
+			h.respondErrorFrom(w, http.StatusConflict, err)
 			return
 		}
-		h.Logger.Error("failed to create version", "error", err, "slug", slug)
+		if errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to create version", "error", err, "slug", slug)
 		h.respondError(w, http.StatusInternalServerError, "Failed to create version")
 		return
 	}
+	if result.Unchanged {
+		h.respondJSON(w, http.StatusOK, result)
+		return
+	}
 
 	h.Metrics.IncrementPromptVersionsCreated()
+	// A version waiting for review is announced once approval makes it current
+	if result.PendingVersion != nil {
+		h.respondJSON(w, http.StatusAccepted, result)
+		return
+	}
+	h.Hub.Broadcast(Event{
+		Type:    EventUpdated,
+		Project: h.requestStore(r).Project(),
+		Slug:    result.Slug,
+		Version: result.CurrentVersion.VersionNumber,
+	}, nil)
+	h.notifyVersionCreated(h.requestStore(r), result.Slug, result.PreviousVersion, result.CurrentVersion.VersionNumber)
 	h.respondJSON(w, http.StatusCreated, result)
 }
 
+// Handler: Import versions
+// Appends a batch of versions in order within one transaction, for migrating
+// history from another system.
+func (h *Handler) handleImportVersions(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	var input models.ImportVersionsInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	for i, version := range input.Versions {
+		if err := h.contentTooLarge(version.Content); err != nil {
+			h.respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("version %d in batch: %v", i+1, err))
+			return
+		}
+	}
+
+	results, err := h.requestStore(r).ImportPromptVersions(slug, input)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			h.respondErrorFrom(w, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to import versions", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to import versions")
+		return
+	}
+
+	for range results {
+		h.Metrics.IncrementPromptVersionsCreated()
+	}
+	current := results[len(results)-1].VersionNumber
+	h.Hub.Broadcast(Event{
+		Type:    EventUpdated,
+		Project: h.requestStore(r).Project(),
+		Slug:    slug,
+		Version: current,
+	}, nil)
+	h.notifyVersionCreated(h.requestStore(r), slug, results[0].VersionNumber-1, current)
+	reqctx.Logger(r.Context()).Info("versions imported", "slug", slug, "count", len(results), "current_version", current)
+	h.respondJSON(w, http.StatusCreated, results)
+}
+
 // Handler: Get specific version
 func (h *Handler) handleGetVersion(w http.ResponseWriter, r *http.Request) {
 	slug := r.PathValue("slug")
-	versionStr := r.PathValue("version")
+	selector := r.PathValue("version")
+
+	result, err := selectVersion(h.requestStore(r), slug, selector)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, errInvalidVersion):
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+		default:
+			reqctx.Logger(r.Context()).Error("failed to get version", "error", err, "slug", slug, "version", selector)
+			h.respondError(w, http.StatusInternalServerError, "Failed to get version")
+		}
+		return
+	}
+
+	h.recordUsage(r, slug, result.VersionNumber)
+	setProvenanceHeaders(w, h.provenance(r, slug, result))
+	h.respondJSONWithETag(w, r, result)
+}
 
-	version, err := strconv.Atoi(versionStr)
+// Version selectors accepted in place of a version number
+const (
+	versionLatest  = "latest"  // the highest-numbered version
+	versionCurrent = "current" // the version the prompt currently serves
+)
+
+// errInvalidVersion marks a selector that names no version
+var errInvalidVersion = errors.New("invalid version")
+
+// selectVersion fetches the version a selector names: a version number,
+// "latest", or "current"
+func selectVersion(s store.Store, slug, selector string) (models.PromptVersion, error) {
+	switch selector {
+	case versionLatest:
+		return s.GetLatestPromptVersion(slug)
+	case versionCurrent:
+		prompt, err := s.GetPromptBySlug(slug)
+		return prompt.CurrentVersion, err
+	}
+	version, err := strconv.Atoi(selector)
+	if err != nil {
+		return models.PromptVersion{}, fmt.Errorf("%w %q: want a version number, %q, or %q", errInvalidVersion, selector, versionLatest, versionCurrent)
+	}
+	return s.GetPromptVersion(slug, version)
+}
+
+// Handler: Pin or unpin a version so retention never prunes it
+func (h *Handler) handleSetVersionPinned(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	version, err := strconv.Atoi(r.PathValue("version"))
 	if err != nil {
 		h.respondError(w, http.StatusBadRequest, "Invalid version number")
 		return
 	}
 
-	result, err := h.Store.GetPromptVersion(slug, version)
+	var input models.SetPinnedInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	result, err := h.requestStore(r).SetVersionPinned(slug, version, input.Pinned)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			h.respondError(w, http.StatusNotFound, err.Error())
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
 			return
 		}
-		h.Logger.Error("failed to get version", "error", err, "slug", slug, "version", version)
-		h.respondError(w, http.StatusInternalServerError, "Failed to get version")
+		reqctx.Logger(r.Context()).Error("failed to set pin", "error", err, "slug", slug, "version", version)
+		h.respondError(w, http.StatusInternalServerError, "Failed to set pin")
 		return
 	}
 
+	reqctx.Logger(r.Context()).Info("version pin changed", "slug", slug, "version", version, "pinned", input.Pinned)
 	h.respondJSON(w, http.StatusOK, result)
 }
 
 // Handler: Health check
 func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
-	response := map[string]string{
+	response := map[string]any{
 		"status":   "healthy",
 		"database": "connected",
 	}
+	// A failing backup is reported but doesn't make the instance unhealthy
+	if h.Backups != nil {
+		response["backup"] = h.Backups.Status()
+	}
+	if h.ReadOnly() {
+		response["read_only"] = true
+	}
 
 	// Verify database connectivity
 	if _, err := h.Store.GetStats(); err != nil {
-		h.Logger.Error("health check failed", "error", err)
+		reqctx.Logger(r.Context()).Error("health check failed", "error", err)
 		response["database"] = "error"
 		h.respondJSON(w, http.StatusInternalServerError, response)
 		return
@@ -294,9 +964,7 @@ func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 // Handler: Metrics
 func (h *Handler) handleMetrics(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(h.Metrics.ExportPrometheus()))
+	h.Metrics.Handler().ServeHTTP(w, r)
 }
 
 // Helper: Respond with JSON
@@ -309,15 +977,43 @@ func (h *Handler) respondJSON(w http.ResponseWriter, status int, data interface{
 	}
 }
 
-// Helper: Respond with error
-func (h *Handler) respondError(w http.ResponseWriter, status int, message string) {
-	h.Metrics.IncrementHTTPErrors()
-	h.respondJSON(w, status, map[string]string{"error": message})
+// Helper: Respond with JSON tagged by a content hash, answering 304 when the
+// client's If-None-Match already matches
+func (h *Handler) respondJSONWithETag(w http.ResponseWriter, r *http.Request, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to encode response", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+	etag := jsonETag(body)
+
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(body, '\n'))
+}
+
+// jsonETag returns the ETag respondJSONWithETag sends for an encoded body
+func jsonETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
 }
 
-// ErrorResponse wraps error messages
-type ErrorResponse struct {
-	Error string `json:"error"`
+// etagMatches reports whether an If-None-Match header lists etag. Caches may
+// send several tags, or mark them weak; either form matches the same body.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
 }
 
 var (
@@ -329,5 +1025,5 @@ var (
 func (h *Handler) handleFrontend(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	w.Write(frontendHTML)
+	w.Write(withBasePath(frontendHTML, h.basePath()))
 }
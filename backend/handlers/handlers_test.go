@@ -1,14 +1,38 @@
 package handlers
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shahram/prompt-registry/backend/auth"
+	"github.com/shahram/prompt-registry/backend/backup"
+	"github.com/shahram/prompt-registry/backend/gitsync"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/providers"
 	"github.com/shahram/prompt-registry/backend/store"
 )
 
@@ -218,6 +242,123 @@ func TestListPromptsHandler_Pagination(t *testing.T) {
 	}
 }
 
+func TestListPromptsHandler_PaginationHeaders(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	for i := 1; i <= 5; i++ {
+		body := `{"title": "Prompt ` + string(rune('0'+i)) + `", "content": "Content"}`
+		req := httptest.NewRequest("POST", "/api/prompts", strings.NewReader(body))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		query, offset, link string
+	}{
+		{"limit=2", "0", `</api/prompts?limit=2&offset=2>; rel="next"`},
+		{"limit=2&offset=2", "2", `</api/prompts?limit=2&offset=4>; rel="next", </api/prompts?limit=2&offset=0>; rel="prev"`},
+		{"limit=2&offset=4&include_archived=true", "4", `</api/prompts?include_archived=true&limit=2&offset=2>; rel="prev"`},
+		{"limit=10", "0", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/prompts?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", tt.query, w.Code)
+		}
+		if got := w.Header().Get("X-Total-Count"); got != "5" {
+			t.Errorf("%s: expected X-Total-Count 5, got %q", tt.query, got)
+		}
+		if got := w.Header().Get("X-Offset"); got != tt.offset {
+			t.Errorf("%s: expected X-Offset %s, got %q", tt.query, tt.offset, got)
+		}
+		if got := w.Header().Get("Link"); got != tt.link {
+			t.Errorf("%s: expected Link %q, got %q", tt.query, tt.link, got)
+		}
+	}
+}
+
+func TestListPromptsHandler_Sort(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	for _, title := range []string{"Beta", "alpha", "Gamma"} {
+		req := httptest.NewRequest("POST", "/api/prompts", strings.NewReader(`{"title": "`+title+`", "content": "Content"}`))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest("GET", "/api/prompts?sort=title&limit=2", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response []models.PromptSummary
+	json.NewDecoder(w.Body).Decode(&response)
+	if len(response) != 2 || response[0].Title != "alpha" || response[1].Title != "Beta" {
+		t.Errorf("Expected alpha and Beta by title, got %+v", response)
+	}
+	if link := w.Header().Get("Link"); !strings.Contains(link, "sort=title") {
+		t.Errorf("Expected the next link to keep the sort, got %q", link)
+	}
+
+	for _, query := range []string{"sort=slug", "sort=title&order=random"} {
+		req := httptest.NewRequest("GET", "/api/prompts?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestListPromptsHandler_Filter(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	do("POST", "/api/prompts", `{"slug": "quiet", "title": "Quiet", "content": "v1"}`)
+	do("POST", "/api/prompts", `{"slug": "busy", "title": "Busy", "content": "v1"}`)
+	do("POST", "/api/prompts/busy/versions", `{"content": "v2"}`)
+
+	w := do("GET", "/api/prompts?min_versions=2&updated_after=2020-01-01", "")
+	var response []models.PromptSummary
+	json.NewDecoder(w.Body).Decode(&response)
+	if w.Code != http.StatusOK || len(response) != 1 || response[0].Slug != "busy" {
+		t.Errorf("Expected only busy, got %d: %+v", w.Code, response)
+	}
+	if got := w.Header().Get("X-Total-Count"); got != "1" {
+		t.Errorf("Expected the total to respect the filter, got %q", got)
+	}
+
+	w = do("GET", "/api/prompts?updated_before=2020-01-01T00:00:00Z", "")
+	response = nil
+	json.NewDecoder(w.Body).Decode(&response)
+	if w.Code != http.StatusOK || len(response) != 0 {
+		t.Errorf("Expected no prompts updated before 2020, got %d: %+v", w.Code, response)
+	}
+
+	w = do("GET", "/api/prompts?q=BUS", "")
+	response = nil
+	json.NewDecoder(w.Body).Decode(&response)
+	if w.Code != http.StatusOK || len(response) != 1 || response[0].Slug != "busy" {
+		t.Errorf("Expected q to match busy, got %d: %+v", w.Code, response)
+	}
+
+	for _, query := range []string{"created_after=yesterday", "min_versions=-1", "min_versions=3&max_versions=2", "q=" + strings.Repeat("x", store.MaxQueryLength+1)} {
+		if w := do("GET", "/api/prompts?"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
 // Test GET /api/prompts/{slug}
 func TestGetPromptHandler_Success(t *testing.T) {
 	h := setupTestHandler(t)
@@ -336,6 +477,46 @@ func TestListVersionsHandler_NotFound(t *testing.T) {
 	}
 }
 
+func TestListVersionsHandler_Pagination(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	do("POST", "/api/prompts", `{"slug": "long", "title": "Long", "content": "v1"}`)
+	for i := 2; i <= 5; i++ {
+		do("POST", "/api/prompts/long/versions", fmt.Sprintf(`{"content": "v%d"}`, i))
+	}
+
+	w := do("GET", "/api/prompts/long/versions?limit=2&offset=2", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var versions []models.PromptVersion
+	json.NewDecoder(w.Body).Decode(&versions)
+	if len(versions) != 2 || versions[0].VersionNumber != 3 {
+		t.Errorf("Expected versions 3 and 4, got %+v", versions)
+	}
+	if got := w.Header().Get("X-Total-Count"); got != "5" {
+		t.Errorf("Expected X-Total-Count 5, got %q", got)
+	}
+	want := `</api/prompts/long/versions?limit=2&offset=4>; rel="next", </api/prompts/long/versions?limit=2&offset=0>; rel="prev"`
+	if got := w.Header().Get("Link"); got != want {
+		t.Errorf("Expected Link %q, got %q", want, got)
+	}
+
+	w = do("GET", "/api/prompts/long/versions", "")
+	versions = nil
+	json.NewDecoder(w.Body).Decode(&versions)
+	if len(versions) != 5 || w.Header().Get("X-Limit") != "100" || w.Header().Get("Link") != "" {
+		t.Errorf("Expected all 5 versions on one default page, got %d with headers %v", len(versions), w.Header())
+	}
+}
+
 // Test POST /api/prompts/{slug}/versions
 func TestCreateVersionHandler_Success(t *testing.T) {
 	h := setupTestHandler(t)
@@ -524,6 +705,69 @@ func TestHealthHandler_Healthy(t *testing.T) {
 	}
 }
 
+func TestHealthHandler_BackupStatus(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	backups, err := backup.New(backup.Config{Dir: t.TempDir(), Schedule: "0 3 * * *", Keep: 3}, h.Store, h.Logger)
+	if err != nil {
+		t.Fatalf("backup.New failed: %v", err)
+	}
+	h.Backups = backups
+	if _, err := backups.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var response struct {
+		Status string              `json:"status"`
+		Backup models.BackupStatus `json:"backup"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected healthy response, got %d: %v", w.Code, err)
+	}
+	if response.Backup.Status != "ok" || response.Backup.Schedule != "0 3 * * *" || response.Backup.LastFile == "" {
+		t.Errorf("Unexpected backup status: %+v", response.Backup)
+	}
+}
+
+func TestHealthProbes(t *testing.T) {
+	h := setupTestHandler(t)
+	h.Readiness.WriteProbe = true
+	router := h.Routes()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"alive"`) {
+		t.Errorf("Expected alive, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	var result models.Readiness
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || result.Status != "ready" || len(result.Checks) != 3 {
+		t.Errorf("Expected ready with 3 checks, got %d: %+v", w.Code, result)
+	}
+
+	// Liveness doesn't depend on the database; readiness does
+	h.Store.Close()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected liveness to pass without a database, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"not_ready"`) {
+		t.Errorf("Expected not ready, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 // Test GET /metrics
 func TestMetricsHandler_Success(t *testing.T) {
 	h := setupTestHandler(t)
@@ -561,6 +805,213 @@ func TestMetricsHandler_Success(t *testing.T) {
 	}
 }
 
+func TestMetricsHandler_Histograms(t *testing.T) {
+	h := setupTestHandler(t)
+	h.Store.(*store.SQLiteStore).SetOperationObserver(h.Metrics.ObserveDBOperation)
+	router := h.Routes()
+
+	for _, path := range []string{"/api/prompts", "/api/prompts/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PATCH", "/api/prompts", nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="GET /api/prompts",status="200"} 1`,
+		`http_requests_total{method="GET",route="GET /api/prompts/{slug}",status="404"} 1`,
+		`http_requests_total{method="PATCH",route="unmatched",status="405"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="GET /api/prompts",status="200"} 1`,
+		`db_operation_duration_seconds_count{operation="ListPrompts"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	h := setupTestHandler(t)
+	var logs bytes.Buffer
+	h.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	h.AccessLog.SampleRate = 3
+	router := h.Routes()
+
+	send := func(method, path, body string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	requestLines := func() []string {
+		var lines []string
+		for _, line := range strings.Split(logs.String(), "\n") {
+			if strings.Contains(line, `msg="http request"`) {
+				lines = append(lines, line)
+			}
+		}
+		logs.Reset()
+		return lines
+	}
+
+	for range 7 {
+		send("GET", "/api/prompts", "")
+	}
+	lines := requestLines()
+	if len(lines) != 3 || !strings.Contains(lines[0], "sample_rate=3") {
+		t.Errorf("Expected requests 1, 4, and 7 to be logged with their sample rate, got %v", lines)
+	}
+
+	// Errors and writes are always logged
+	for range 3 {
+		send("GET", "/api/prompts/missing", "")
+		send("POST", "/api/prompts", `{"title": "t"}`)
+	}
+	if lines := requestLines(); len(lines) != 6 || strings.Contains(strings.Join(lines, "\n"), "sample_rate") {
+		t.Errorf("Expected every error and write to be logged unsampled, got %v", lines)
+	}
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `http_request_logs_suppressed_total{route="GET /api/prompts"} 4`) {
+		t.Errorf("Expected suppressed count by route, got:\n%s", w.Body.String())
+	}
+}
+
+func TestAccessLogFields(t *testing.T) {
+	h := setupTestHandler(t)
+	var logs bytes.Buffer
+	h.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	keys, _ := auth.NewAPIKeys(map[string]string{"k1": "ci-bot"})
+	h.Auth = keys
+	h.AccessLog.Fields = []string{AccessLogRemoteAddr, AccessLogUserAgent, AccessLogBytes, AccessLogAPIKey}
+	h.AccessLog.ExcludePaths = []string{"/health", "/metrics", "/api/prompts/missing"}
+	router := h.Routes()
+
+	send := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.0.2.7:4312"
+		req.Header.Set("User-Agent", "promptctl/1.0")
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	requestLines := func() []string {
+		var lines []string
+		for _, line := range strings.Split(logs.String(), "\n") {
+			if strings.Contains(line, `msg="http request"`) {
+				lines = append(lines, line)
+			}
+		}
+		logs.Reset()
+		return lines
+	}
+
+	w := send("/api/prompts", "k1")
+	lines := requestLines()
+	if len(lines) != 1 {
+		t.Fatalf("Expected one access log line, got %v", lines)
+	}
+	for _, want := range []string{
+		"remote_addr=192.0.2.7",
+		"user_agent=promptctl/1.0",
+		fmt.Sprintf("bytes=%d", w.Body.Len()),
+		"api_key=ci-bot",
+		"subject=ci-bot",
+	} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("Expected access log line to contain %s, got %q", want, lines[0])
+		}
+	}
+
+	send("/health", "")
+	send("/metrics", "")
+	if lines := requestLines(); len(lines) != 0 {
+		t.Errorf("Expected excluded paths not to be logged, got %v", lines)
+	}
+
+	// Failures on excluded paths are still logged
+	send("/api/prompts/missing", "k1")
+	if lines := requestLines(); len(lines) != 1 || !strings.Contains(lines[0], "status=404") {
+		t.Errorf("Expected a failed request on an excluded path to be logged, got %v", lines)
+	}
+
+	if err := (AccessLogConfig{Fields: []string{"referer"}}).Validate(); err == nil {
+		t.Error("Expected an unknown field to be rejected")
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	h := setupTestHandler(t)
+	var logs bytes.Buffer
+	h.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	keys, _ := auth.NewAPIKeys(map[string]string{"k1": "alice"})
+	h.Auth = keys
+	router := h.Routes()
+
+	req := httptest.NewRequest("POST", "/api/prompts", strings.NewReader("{"))
+	req.Header.Set("Authorization", "Bearer k1")
+	req.Header.Set("X-Request-ID", "trace-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Request-ID"); got != "trace-42" {
+		t.Errorf("Expected the client's request ID echoed, got %q", got)
+	}
+	var handlerLine, accessLine string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		switch {
+		case strings.Contains(line, `msg="failed to decode request"`):
+			handlerLine = line
+		case strings.Contains(line, `msg="http request"`):
+			accessLine = line
+		}
+	}
+	for _, want := range []string{"request_id=trace-42", `route="POST /api/prompts"`, "subject=alice"} {
+		if !strings.Contains(handlerLine, want) {
+			t.Errorf("Expected handler log line to contain %s, got %q", want, handlerLine)
+		}
+	}
+	if !strings.Contains(accessLine, "request_id=trace-42") || !strings.Contains(accessLine, `route="POST /api/prompts"`) {
+		t.Errorf("Expected the access log line to carry the request ID and route, got %q", accessLine)
+	}
+	var errResp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil || errResp.RequestID != "trace-42" {
+		t.Errorf("Expected the request ID in the error body, got %+v, %v", errResp, err)
+	}
+
+	// Store operations served for the request log with its ID too
+	req = httptest.NewRequest("GET", "/api/prompts", nil)
+	req.Header.Set("Authorization", "Bearer k1")
+	req.Header.Set("X-Request-ID", "trace-43")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	var storeLine string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, `msg="database operation"`) && strings.Contains(line, "operation=ListPrompts") {
+			storeLine = line
+		}
+	}
+	if !strings.Contains(storeLine, "request_id=trace-43") || !strings.Contains(storeLine, "subject=alice") {
+		t.Errorf("Expected the store log line to carry the request ID, got %q", storeLine)
+	}
+
+	req = httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("X-Request-ID", "bad id")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if got := w.Header().Get("X-Request-ID"); len(got) != 32 {
+		t.Errorf("Expected a generated request ID in place of an invalid one, got %q", got)
+	}
+}
+
 // Test CORS headers
 func TestCORSHeaders(t *testing.T) {
 	h := setupTestHandler(t)
@@ -595,6 +1046,39 @@ func TestCORSOptions(t *testing.T) {
 	}
 }
 
+func TestCORSAllowedOrigins(t *testing.T) {
+	h := setupTestHandler(t)
+	h.CORS = CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+	}
+	router := h.Routes()
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/api/prompts", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := preflight("https://app.example.com")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Expected the allowed origin echoed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, Authorization" {
+		t.Errorf("Expected the configured headers, got %q", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Expected Vary: Origin, got %q", got)
+	}
+
+	w = preflight("https://evil.example.com")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no CORS headers for another origin, got %q", got)
+	}
+}
+
 // Test panic recovery
 func TestPanicRecovery(t *testing.T) {
 	h := setupTestHandler(t)
@@ -615,3 +1099,5144 @@ func TestPanicRecovery(t *testing.T) {
 		t.Errorf("Expected status 500 after panic, got %d", w.Code)
 	}
 }
+
+// Test WebSocket collaboration signals
+func TestWebSocketHub_BroadcastsUpdates(t *testing.T) {
+	h := setupTestHandler(t)
+	server := httptest.NewServer(h.Routes())
+	t.Cleanup(server.Close)
+
+	body := `{"slug": "ws-prompt", "title": "WS Prompt", "content": "v1"}`
+	resp, err := http.Post(server.URL+"/api/prompts", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+	resp.Body.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	editor, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial websocket: %v", err)
+	}
+	defer editor.Close()
+	watcher, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial websocket: %v", err)
+	}
+	defer watcher.Close()
+
+	// Wait for both connections to register with the hub
+	deadline := time.Now().Add(2 * time.Second)
+	for h.Hub.ClientCount() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Editing signals are relayed to other clients only
+	if err := editor.WriteJSON(Event{Type: EventEditing, Slug: "ws-prompt"}); err != nil {
+		t.Fatalf("Failed to send editing event: %v", err)
+	}
+	var event Event
+	watcher.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := watcher.ReadJSON(&event); err != nil {
+		t.Fatalf("Failed to read editing event: %v", err)
+	}
+	if event.Type != EventEditing || event.Project != store.DefaultProject || event.Slug != "ws-prompt" {
+		t.Errorf("Expected editing event for ws-prompt, got %+v", event)
+	}
+
+	// Creating a version notifies every client
+	resp, err = http.Post(server.URL+"/api/prompts/ws-prompt/versions", "application/json", strings.NewReader(`{"content": "v2"}`))
+	if err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+	resp.Body.Close()
+
+	for _, conn := range []*websocket.Conn{editor, watcher} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("Failed to read updated event: %v", err)
+		}
+		if event.Type != EventUpdated || event.Slug != "ws-prompt" || event.Version != 2 {
+			t.Errorf("Expected updated event for ws-prompt v2, got %+v", event)
+		}
+	}
+}
+
+func TestWebSocketHub_ChecksOrigin(t *testing.T) {
+	h := setupTestHandler(t)
+	h.CORS = CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}
+	server := httptest.NewServer(h.Routes())
+	t.Cleanup(server.Close)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	for _, tc := range []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"https://app.example.com", true},
+		{server.URL, true},
+		{"https://evil.example", false},
+	} {
+		header := http.Header{}
+		if tc.origin != "" {
+			header.Set("Origin", tc.origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if conn != nil {
+			conn.Close()
+		}
+		if tc.want && err != nil {
+			t.Errorf("Origin %q: expected the upgrade to succeed, got %v", tc.origin, err)
+		}
+		if !tc.want && (err == nil || resp == nil || resp.StatusCode != http.StatusForbidden) {
+			t.Errorf("Origin %q: expected 403, got %v", tc.origin, err)
+		}
+	}
+}
+
+func TestWebSocketHub_OnlyReaders(t *testing.T) {
+	h := setupTestHandler(t)
+	keys, err := auth.NewAPIKeys(map[string]string{"key-a": "alice", "key-c": "carol"})
+	if err != nil {
+		t.Fatalf("NewAPIKeys failed: %v", err)
+	}
+	h.Auth = keys
+	server := httptest.NewServer(h.Routes())
+	t.Cleanup(server.Close)
+
+	do := func(method, path, body string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer key-a")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			t.Fatalf("%s %s: status %d", method, path, resp.StatusCode)
+		}
+	}
+	do("POST", "/api/orgs", `{"name":"search"}`)
+	do("POST", "/api/projects/ranking/prompts", `{"slug":"p","title":"P","content":"v1"}`)
+	do("PUT", "/api/orgs/search/projects/ranking", "")
+	do("POST", "/api/prompts", `{"slug":"open","title":"Open","content":"v1"}`)
+	do("POST", "/api/prompts", `{"slug":"secret","title":"Secret","content":"v1"}`)
+	do("PUT", "/api/prompts/secret/acl", `{"grants":[{"type":"user","name":"bob","access":"read"}]}`)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	dial := func(key string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Authorization": {"Bearer " + key}})
+		if err != nil {
+			t.Fatalf("Failed to dial websocket: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	alice, carol := dial("key-a"), dial("key-c")
+	deadline := time.Now().Add(2 * time.Second)
+	for h.Hub.ClientCount() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// carol isn't in the org and has no grant on secret, so she can neither
+	// announce edits to those prompts nor hear about their changes; events
+	// arrive in order, so each socket's first event shows what it skipped
+	for _, event := range []Event{{Type: EventEditing, Project: "ranking", Slug: "p"}, {Type: EventEditing, Slug: "secret"}} {
+		if err := carol.WriteJSON(event); err != nil {
+			t.Fatalf("Failed to send editing event: %v", err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	do("POST", "/api/projects/ranking/prompts/p/versions", `{"content":"v2"}`)
+	do("POST", "/api/prompts/secret/versions", `{"content":"v2"}`)
+	do("POST", "/api/prompts/open/versions", `{"content":"v2"}`)
+
+	next := func(conn *websocket.Conn) Event {
+		t.Helper()
+		var event Event
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		return event
+	}
+	if event := next(alice); event != (Event{Type: EventUpdated, Project: "ranking", Slug: "p", Version: 2}) {
+		t.Errorf("Expected alice to get the org project's update first, got %+v", event)
+	}
+	if event := next(alice); event != (Event{Type: EventUpdated, Project: store.DefaultProject, Slug: "secret", Version: 2}) {
+		t.Errorf("Expected alice to get the restricted prompt's update, got %+v", event)
+	}
+	if event := next(alice); event != (Event{Type: EventUpdated, Project: store.DefaultProject, Slug: "open", Version: 2}) {
+		t.Errorf("Expected alice to get the default project's update, got %+v", event)
+	}
+	if event := next(carol); event != (Event{Type: EventUpdated, Project: store.DefaultProject, Slug: "open", Version: 2}) {
+		t.Errorf("Expected carol to get only the open prompt's update, got %+v", event)
+	}
+}
+
+// Test public gallery
+func setupPublicGallery(t *testing.T) http.Handler {
+	t.Helper()
+	h := setupTestHandler(t)
+	h.Public.Enabled = true
+	h.Public.Burst = 100
+	router := h.Routes()
+
+	for _, body := range []string{
+		`{"slug": "shared", "title": "Shared", "content": "Public content", "public": true}`,
+		`{"slug": "internal", "title": "Internal", "content": "Private content"}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/prompts", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Failed to create prompt: %d", w.Code)
+		}
+	}
+	return router
+}
+
+func TestPublicGallery_OnlyExposesPublicPrompts(t *testing.T) {
+	router := setupPublicGallery(t)
+
+	req := httptest.NewRequest("GET", "/public/api/prompts", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var response []map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response) != 1 || response[0]["slug"] != "shared" {
+		t.Errorf("Expected only 'shared' in gallery, got %v", response)
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/public/api/prompts/shared", http.StatusOK},
+		{"/public/api/prompts/shared/versions", http.StatusOK},
+		{"/public/api/prompts/shared/versions/1", http.StatusOK},
+		{"/public/api/prompts/internal", http.StatusNotFound},
+		{"/public/api/prompts/internal/versions", http.StatusNotFound},
+		{"/public/api/prompts/internal/versions/1", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("GET %s: expected status %d, got %d", tt.path, tt.want, w.Code)
+		}
+	}
+}
+
+func TestPublicGallery_HidesPendingVersions(t *testing.T) {
+	h := setupTestHandler(t)
+	h.Public.Enabled = true
+	h.Public.Burst = 100
+	h.AdminToken = "secret"
+	router := h.Routes()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/api/prompts", `{"slug":"pub","title":"Pub","content":"approved text","public":true}`); w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create prompt: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", "/api/admin/prompts/pub/review-policy", `{"required_approvals":1,"reviewers":["bob"]}`); w.Code != http.StatusOK {
+		t.Fatalf("Failed to set review policy: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/prompts/pub/versions", `{"content":"pending text"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected a pending version, got %d %s", w.Code, w.Body.String())
+	}
+
+	w := do("GET", "/public/api/prompts/pub/versions", "")
+	var versions []models.PromptVersion
+	json.NewDecoder(w.Body).Decode(&versions)
+	if w.Code != http.StatusOK || len(versions) != 1 || versions[0].VersionNumber != 1 {
+		t.Errorf("Expected only version 1 listed, got %d: %+v", w.Code, versions)
+	}
+	if total := w.Header().Get("X-Total-Count"); total != "1" {
+		t.Errorf("Expected X-Total-Count 1, got %q", total)
+	}
+
+	w = do("GET", "/public/api/prompts/pub/versions/latest", "")
+	var latest models.PromptVersion
+	json.NewDecoder(w.Body).Decode(&latest)
+	if w.Code != http.StatusOK || latest.VersionNumber != 1 || latest.Content != "approved text" {
+		t.Errorf("Expected latest to resolve to approved version 1, got %d: %+v", w.Code, latest)
+	}
+	if w := do("GET", "/public/api/prompts/pub/versions/2", ""); w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "pending text") {
+		t.Errorf("Expected pending version 2 to be not found, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/public/api/prompts/pub/versions/1", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected approved version 1, got %d", w.Code)
+	}
+}
+
+func TestPublicGallery_HidesInternalFields(t *testing.T) {
+	h := setupTestHandler(t)
+	h.Public.Enabled = true
+	h.Public.Burst = 100
+	h.AdminToken = "secret"
+	keys, err := auth.NewAPIKeys(map[string]string{"key-a": "alice@example.com"})
+	if err != nil {
+		t.Fatalf("NewAPIKeys failed: %v", err)
+	}
+	h.Auth = keys
+	router := h.Routes()
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/api/prompts", "key-a", `{"slug":"pub","title":"Pub","content":"Hello","public":true,"metadata":{"team":"legal-ops"}}`); w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create prompt: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", "/api/admin/prompts/pub/legal-hold", "secret", `{"held":true,"reason":"Litigation with Acme Corp"}`); w.Code != http.StatusOK {
+		t.Fatalf("Failed to place legal hold: %d %s", w.Code, w.Body.String())
+	}
+
+	w := do("GET", "/public/api/prompts/pub", "", "")
+	var prompt models.PublicPrompt
+	if err := json.Unmarshal(w.Body.Bytes(), &prompt); err != nil || w.Code != http.StatusOK || prompt.Slug != "pub" || prompt.CurrentVersion.Content != "Hello" {
+		t.Fatalf("Unexpected public prompt %d: %s", w.Code, w.Body.String())
+	}
+	for _, path := range []string{"/public/api/prompts/pub", "/public/api/prompts/pub/versions", "/public/api/prompts/pub/versions/1", "/public/prompts/pub"} {
+		body := do("GET", path, "", "").Body.String()
+		for _, leak := range []string{"legal_hold", "Acme", "alice@example.com", "owner", "created_by", "updated_by", "metadata", "legal-ops"} {
+			if strings.Contains(body, leak) {
+				t.Errorf("GET %s exposes %q: %s", path, leak, body)
+			}
+		}
+	}
+}
+
+func TestPublicGallery_NoWriteEndpoints(t *testing.T) {
+	router := setupPublicGallery(t)
+
+	req := httptest.NewRequest("POST", "/public/api/prompts/shared/versions", strings.NewReader(`{"content": "x"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+func TestPublicGallery_Disabled(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	req := httptest.NewRequest("GET", "/public/api/prompts", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Falls through to the frontend catch-all rather than the API
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected frontend HTML when gallery disabled, got %q", ct)
+	}
+}
+
+func TestPublicGallery_RateLimited(t *testing.T) {
+	h := setupTestHandler(t)
+	h.Public.Enabled = true
+	h.Public.Burst = 2
+	router := h.Routes()
+
+	codes := make([]int, 3)
+	for i := range codes {
+		req := httptest.NewRequest("GET", "/public/api/prompts", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		codes[i] = w.Code
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Error("Expected Retry-After header on 429")
+		}
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("Expected [200 200 429], got %v", codes)
+	}
+}
+
+func TestAPIRateLimit(t *testing.T) {
+	h := setupTestHandler(t)
+	keys, err := auth.NewAPIKeys(map[string]string{"key-batch": "batch", "key-ci": "ci"})
+	if err != nil {
+		t.Fatalf("NewAPIKeys failed: %v", err)
+	}
+	h.Auth = keys
+	h.AdminToken = "secret"
+	h.RateLimit = RateLimitConfig{RequestsPerSecond: 0.01, Burst: 2}
+	router := h.Routes()
+
+	do := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	codes := make([]int, 3)
+	for i := range codes {
+		w := do("/api/prompts", "key-batch")
+		codes[i] = w.Code
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Error("Expected Retry-After header on 429")
+		}
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("Expected [200 200 429], got %v", codes)
+	}
+
+	// Each API key has its own bucket, even from the same IP
+	if w := do("/api/prompts", "key-ci"); w.Code != http.StatusOK {
+		t.Errorf("Expected another key to be allowed, got %d", w.Code)
+	}
+	// Admin routes and health checks aren't limited
+	for range 3 {
+		if w := do("/api/admin/integration-status", "secret"); w.Code != http.StatusOK {
+			t.Errorf("Expected admin routes to be exempt, got %d", w.Code)
+		}
+	}
+	if w := do("/health", "key-batch"); w.Code != http.StatusOK {
+		t.Errorf("Expected /health to be exempt, got %d", w.Code)
+	}
+}
+
+func TestAPIRateLimit_IgnoresSpoofedForwardedFor(t *testing.T) {
+	h := setupTestHandler(t)
+	h.RateLimit = RateLimitConfig{RequestsPerSecond: 0.01, Burst: 1}
+	router := h.Routes()
+
+	do := func(forwardedFor string) int {
+		req := httptest.NewRequest("GET", "/api/prompts", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := do("198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if code := do("198.51.100.2"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected a new X-Forwarded-For from the same connection to be limited, got %d", code)
+	}
+
+	// Behind a trusted proxy, each forwarded client gets its own bucket
+	h.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}
+	if code := do("198.51.100.2"); code != http.StatusOK {
+		t.Errorf("Expected another client behind a trusted proxy to be allowed, got %d", code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	h := &Handler{TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+	for _, tc := range []struct {
+		remoteAddr, forwardedFor, want string
+	}{
+		{"203.0.113.9:5000", "", "203.0.113.9"},
+		{"203.0.113.9:5000", "198.51.100.1", "203.0.113.9"},
+		{"10.0.0.2:5000", "", "10.0.0.2"},
+		{"10.0.0.2:5000", "198.51.100.1", "198.51.100.1"},
+		{"10.0.0.2:5000", "6.6.6.6, 198.51.100.1, 10.0.0.3", "198.51.100.1"},
+		{"[::ffff:10.0.0.2]:5000", "198.51.100.1", "198.51.100.1"},
+	} {
+		req := httptest.NewRequest("GET", "/api/prompts", nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+		}
+		if got := h.clientIP(req); got != tc.want {
+			t.Errorf("clientIP(%s, X-Forwarded-For %q) = %s, want %s", tc.remoteAddr, tc.forwardedFor, got, tc.want)
+		}
+	}
+}
+
+func TestRateLimiter_EvictsIdleBuckets(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newRateLimiter(1, 5)
+	l.now = func() time.Time { return now }
+
+	l.allow("a")
+	now = now.Add(time.Minute)
+	l.allow("b")
+	if _, ok := l.buckets["a"]; ok || len(l.buckets) != 1 {
+		t.Errorf("Expected the refilled bucket to be swept, got %v", l.buckets)
+	}
+
+	l.configure(0, 5)
+	if len(l.buckets) != 0 {
+		t.Errorf("Expected turning the limit off to drop every bucket, got %v", l.buckets)
+	}
+}
+
+func TestDescriptionHandlers(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "Content"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("PUT", "/api/prompts/summarize/description", `{"description": "Ask **#search-oncall**. <script>alert(1)</script> [runbook](https://wiki.example.com/r) [bad](javascript:alert(1))"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var prompt models.PromptWithCurrentVersion
+	if err := json.NewDecoder(w.Body).Decode(&prompt); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.HasPrefix(prompt.Description, "Ask **#search-oncall**") {
+		t.Errorf("Expected the Markdown source to be stored, got %q", prompt.Description)
+	}
+
+	w = do("GET", "/api/prompts/summarize/description.html", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("Expected 200 text/html, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	html := w.Body.String()
+	if !strings.Contains(html, "<strong>#search-oncall</strong>") || !strings.Contains(html, `<a href="https://wiki.example.com/r"`) {
+		t.Errorf("Expected rendered Markdown, got %q", html)
+	}
+	if strings.Contains(html, "<script") || strings.Contains(html, `href="javascript:`) {
+		t.Errorf("Expected sanitized HTML, got %q", html)
+	}
+	if w.Header().Get("Content-Security-Policy") == "" {
+		t.Error("Expected a Content-Security-Policy header")
+	}
+
+	w = do("POST", "/api/markdown", `{"markdown": "- unsaved _draft_"}`)
+	if w.Code != http.StatusOK || w.Body.String() != "<ul>\n<li>unsaved <em>draft</em></li>\n</ul>\n" {
+		t.Errorf("Expected preview HTML, got %d %q", w.Code, w.Body.String())
+	}
+
+	if w := do("PUT", "/api/prompts/summarize/description", `{"description": "short"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a short description, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/prompts/missing/description", `{"description": ""}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+	if w := do("GET", "/api/prompts/missing/description.html", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestDocsHandlers(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "Content"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, content := range []string{"## Intent\nFirst draft.", "## Intent\nTerse summaries.\n\n- `text`: the input"} {
+		if w := do("PUT", "/api/prompts/summarize/docs", fmt.Sprintf(`{"content": %q}`, content)); w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	w := do("GET", "/api/prompts/summarize/docs", "")
+	var docs models.PromptDocs
+	if err := json.NewDecoder(w.Body).Decode(&docs); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected docs, got %d: %v", w.Code, err)
+	}
+	if docs.Revision != 2 || !strings.Contains(docs.Content, "Terse") {
+		t.Errorf("Expected latest docs at revision 2, got %+v", docs)
+	}
+
+	w = do("GET", "/api/prompts/summarize/docs/revisions/1", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "First draft.") {
+		t.Errorf("Expected revision 1, got %d: %s", w.Code, w.Body.String())
+	}
+	var revisions []models.PromptDocs
+	w = do("GET", "/api/prompts/summarize/docs/revisions", "")
+	if err := json.NewDecoder(w.Body).Decode(&revisions); err != nil || len(revisions) != 2 {
+		t.Errorf("Expected 2 revisions, got %+v, %v", revisions, err)
+	}
+
+	w = do("GET", "/api/prompts/summarize/docs.html", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<h2>Intent</h2>") || !strings.Contains(w.Body.String(), "<code>text</code>") {
+		t.Errorf("Expected rendered docs, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Docs don't create content versions
+	w = do("GET", "/api/prompts/summarize", "")
+	var prompt models.PromptWithCurrentVersion
+	if err := json.NewDecoder(w.Body).Decode(&prompt); err != nil || prompt.CurrentVersion.VersionNumber != 1 || prompt.DocsRevision != 2 {
+		t.Errorf("Expected version 1 with docs revision 2, got %+v, %v", prompt, err)
+	}
+
+	if w := do("GET", "/api/prompts/summarize/docs/revisions/3", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing revision, got %d", w.Code)
+	}
+	if w := do("GET", "/api/prompts/summarize/docs/revisions/zero", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a bad revision, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/prompts/missing/docs", `{"content": "x"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestSetVisibilityHandler(t *testing.T) {
+	router := setupPublicGallery(t)
+
+	req := httptest.NewRequest("PUT", "/api/prompts/internal/visibility", strings.NewReader(`{"public": true}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/public/api/prompts/internal", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected newly public prompt to be visible, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/prompts/missing/visibility", strings.NewReader(`{"public": true}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestArchivePromptHandler(t *testing.T) {
+	router := setupPublicGallery(t)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	listed := func(path string) []string {
+		t.Helper()
+		var prompts []models.PromptSummary
+		if err := json.NewDecoder(do("GET", path).Body).Decode(&prompts); err != nil {
+			t.Fatalf("Failed to decode %s: %v", path, err)
+		}
+		var slugs []string
+		for _, p := range prompts {
+			slugs = append(slugs, p.Slug)
+		}
+		sort.Strings(slugs)
+		return slugs
+	}
+
+	w := do("POST", "/api/prompts/shared/archive")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var prompt models.PromptWithCurrentVersion
+	if err := json.NewDecoder(w.Body).Decode(&prompt); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if prompt.Slug != "shared" || prompt.ArchivedAt == nil {
+		t.Errorf("Expected archived prompt in response, got %+v", prompt)
+	}
+
+	if got := listed("/api/prompts"); !reflect.DeepEqual(got, []string{"internal"}) {
+		t.Errorf("Expected archived prompt to be hidden, got %v", got)
+	}
+	if got := listed("/api/prompts?include_archived=true"); !reflect.DeepEqual(got, []string{"internal", "shared"}) {
+		t.Errorf("Expected include_archived to list every prompt, got %v", got)
+	}
+	// Deployments that pinned the prompt keep resolving it
+	if w := do("GET", "/api/prompts/shared"); w.Code != http.StatusOK {
+		t.Errorf("Expected archived prompt to stay fetchable, got %d", w.Code)
+	}
+	if w := do("GET", "/public/api/prompts/shared"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected archived prompt to leave the public gallery, got %d", w.Code)
+	}
+
+	if w := do("POST", "/api/prompts/shared/unarchive"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if got := listed("/api/prompts"); len(got) != 2 {
+		t.Errorf("Expected unarchived prompt to be listed, got %v", got)
+	}
+	if w := do("POST", "/api/prompts/missing/archive"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestProjectRoutes(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/api/prompts", `{"slug":"greeting","title":"Default","content":"hi"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	// The same slug is free in another project
+	if w := do("POST", "/api/projects/search/prompts", `{"slug":"greeting","title":"Search","content":"hello {{name}}"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/projects/search/prompts/greeting/versions", `{"content":"hello again {{name}}"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	get := func(path string) models.PromptWithCurrentVersion {
+		t.Helper()
+		w := do("GET", path, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected status 200, got %d", path, w.Code)
+		}
+		var prompt models.PromptWithCurrentVersion
+		if err := json.NewDecoder(w.Body).Decode(&prompt); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return prompt
+	}
+	if p := get("/api/prompts/greeting"); p.Title != "Default" || p.CurrentVersion.VersionNumber != 1 {
+		t.Errorf("Expected default project prompt, got %+v", p)
+	}
+	// Unprefixed routes are the default project
+	if p := get("/api/projects/default/prompts/greeting"); p.Title != "Default" {
+		t.Errorf("Expected default project prompt, got %+v", p)
+	}
+	if p := get("/api/projects/search/prompts/greeting"); p.Title != "Search" || p.CurrentVersion.VersionNumber != 2 {
+		t.Errorf("Expected search project prompt at v2, got %+v", p)
+	}
+
+	w := do("POST", "/api/projects/search/prompts/greeting/render", `{"variables":{"name":"Ada"}}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "hello again Ada") {
+		t.Errorf("Expected render within the project, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/api/projects/other/prompts/greeting", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 in a project without the prompt, got %d", w.Code)
+	}
+	if w := do("GET", "/api/projects/Not_Valid/prompts", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid project name, got %d", w.Code)
+	}
+
+	w = do("GET", "/api/projects/search/export", "")
+	var export models.Export
+	if err := json.NewDecoder(w.Body).Decode(&export); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	if len(export.Prompts) != 1 || len(export.Prompts[0].Versions) != 2 {
+		t.Errorf("Expected export scoped to the project, got %+v", export.Prompts)
+	}
+
+	w = do("GET", "/api/projects", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var projects []models.Project
+	if err := json.NewDecoder(w.Body).Decode(&projects); err != nil {
+		t.Fatalf("Failed to decode projects: %v", err)
+	}
+	want := []models.Project{{Name: "default", Prompts: 1}, {Name: "search", Prompts: 1}}
+	if !reflect.DeepEqual(projects, want) {
+		t.Errorf("Expected projects %+v, got %+v", want, projects)
+	}
+}
+
+// Test GraphQL
+func TestGraphQLHandler_NestedQuery(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	for _, body := range []string{
+		`{"slug": "alpha", "title": "Alpha", "content": "a1"}`,
+		`{"slug": "beta", "title": "Beta", "content": "b1"}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/prompts", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+	}
+	req := httptest.NewRequest("POST", "/api/prompts/alpha/versions", strings.NewReader(`{"content": "a2"}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	query := `{"query": "query($slug: String!) { prompt(slug: $slug) { title current_version { version_number content } versions { version_number } } prompts { slug current_version { content } } stats { total_prompts total_prompt_versions } }", "variables": {"slug": "alpha"}}`
+	req = httptest.NewRequest("POST", "/api/graphql", strings.NewReader(query))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Data struct {
+			Prompt struct {
+				Title          string
+				CurrentVersion struct {
+					VersionNumber int    `json:"version_number"`
+					Content       string `json:"content"`
+				} `json:"current_version"`
+				Versions []struct {
+					VersionNumber int `json:"version_number"`
+				}
+			}
+			Prompts []struct {
+				Slug           string
+				CurrentVersion struct {
+					Content string
+				} `json:"current_version"`
+			}
+			Stats struct {
+				TotalPrompts        int `json:"total_prompts"`
+				TotalPromptVersions int `json:"total_prompt_versions"`
+			}
+		}
+		Errors []map[string]interface{}
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(response.Errors) > 0 {
+		t.Fatalf("Unexpected GraphQL errors: %v", response.Errors)
+	}
+	if response.Data.Prompt.Title != "Alpha" || response.Data.Prompt.CurrentVersion.Content != "a2" {
+		t.Errorf("Unexpected prompt: %+v", response.Data.Prompt)
+	}
+	if len(response.Data.Prompt.Versions) != 2 {
+		t.Errorf("Expected 2 versions, got %d", len(response.Data.Prompt.Versions))
+	}
+	if len(response.Data.Prompts) != 2 {
+		t.Errorf("Expected 2 prompts, got %d", len(response.Data.Prompts))
+	}
+	for _, p := range response.Data.Prompts {
+		if p.Slug == "alpha" && p.CurrentVersion.Content != "a2" {
+			t.Errorf("Expected alpha current content a2, got %q", p.CurrentVersion.Content)
+		}
+	}
+	if response.Data.Stats.TotalPrompts != 2 || response.Data.Stats.TotalPromptVersions != 3 {
+		t.Errorf("Unexpected stats: %+v", response.Data.Stats)
+	}
+}
+
+func TestGraphQLHandler_StatsScopedToCaller(t *testing.T) {
+	h := setupTestHandler(t)
+	keys, err := auth.NewAPIKeys(map[string]string{"key-a": "alice", "key-b": "bob"})
+	if err != nil {
+		t.Fatalf("NewAPIKeys failed: %v", err)
+	}
+	h.Auth = keys
+	router := h.Routes()
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	for _, path := range []string{"/api/prompts", "/api/projects/other/prompts"} {
+		if w := do("POST", path, "key-a", `{"slug":"open","title":"Open","content":"v1"}`); w.Code != http.StatusCreated {
+			t.Fatalf("Failed to create prompt: %d %s", w.Code, w.Body.String())
+		}
+	}
+	do("POST", "/api/prompts", "key-a", `{"slug":"secret","title":"Secret","content":"v1"}`)
+	if w := do("PUT", "/api/prompts/secret/acl", "key-a", `{"grants":[{"type":"user","name":"carol","access":"read"}]}`); w.Code != http.StatusOK {
+		t.Fatalf("Failed to restrict prompt: %d %s", w.Code, w.Body.String())
+	}
+
+	stats := func(key, query string) (int, int) {
+		t.Helper()
+		w := do("POST", "/api/graphql", key, `{"query": "`+query+`"}`)
+		var response struct {
+			Data struct {
+				Stats struct {
+					TotalPrompts        int `json:"total_prompts"`
+					TotalPromptVersions int `json:"total_prompt_versions"`
+				}
+			}
+			Errors []map[string]any
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil || len(response.Errors) > 0 {
+			t.Fatalf("Unexpected GraphQL response: %v, %v", response.Errors, err)
+		}
+		return response.Data.Stats.TotalPrompts, response.Data.Stats.TotalPromptVersions
+	}
+	// bob can't read secret, and the other project is counted on its own
+	if prompts, versions := stats("key-b", "{ stats { total_prompts total_prompt_versions } }"); prompts != 1 || versions != 1 {
+		t.Errorf("Expected bob to count 1 prompt and 1 version, got %d and %d", prompts, versions)
+	}
+	if prompts, _ := stats("key-a", "{ stats { total_prompts } }"); prompts != 2 {
+		t.Errorf("Expected the owner to count 2 prompts, got %d", prompts)
+	}
+	if prompts, _ := stats("key-b", `{ stats(project: \"other\") { total_prompts } }`); prompts != 1 {
+		t.Errorf("Expected 1 prompt in the other project, got %d", prompts)
+	}
+}
+
+func TestGraphQLHandler_Errors(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	req := httptest.NewRequest("GET", `/api/graphql?query={prompt(slug:"missing"){title}}`, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if _, ok := response["errors"]; !ok {
+		t.Error("Expected errors for missing prompt")
+	}
+
+	req = httptest.NewRequest("POST", "/api/graphql", strings.NewReader(`{"query": ""}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for empty query, got %d", w.Code)
+	}
+}
+
+func TestPublicGallery_SitemapAndPages(t *testing.T) {
+	router := setupPublicGallery(t)
+
+	req := httptest.NewRequest("GET", "/sitemap.xml", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	sitemap := w.Body.String()
+	if !strings.Contains(sitemap, "<loc>http://localhost:8080/public/prompts/shared</loc>") {
+		t.Errorf("Expected sitemap to list public prompt, got %s", sitemap)
+	}
+	if strings.Contains(sitemap, "internal") {
+		t.Error("Expected sitemap to exclude private prompts")
+	}
+
+	req = httptest.NewRequest("GET", "/public/prompts/shared", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	page := w.Body.String()
+	if !strings.Contains(page, `<link rel="canonical" href="http://localhost:8080/public/prompts/shared">`) {
+		t.Error("Expected canonical link on prompt page")
+	}
+	if !strings.Contains(page, "Public content") {
+		t.Error("Expected prompt content on prompt page")
+	}
+
+	req = httptest.NewRequest("GET", "/public/prompts/internal", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for private prompt page, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/robots.txt", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "Sitemap: http://localhost:8080/sitemap.xml") {
+		t.Errorf("Expected robots.txt to reference sitemap, got %q", w.Body.String())
+	}
+}
+
+// Test OpenAPI specification
+func TestOpenAPIHandler_ServesSpec(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var spec struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&spec); err != nil {
+		t.Fatalf("Spec is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("Expected OpenAPI 3.x, got %q", spec.OpenAPI)
+	}
+
+	routes := map[string][]string{
+		"/api/prompts":                           {"get", "post"},
+		"/api/prompts/{slug}":                    {"get"},
+		"/api/prompts/{slug}/versions":           {"get", "post"},
+		"/api/prompts/{slug}/versions/{version}": {"get"},
+		"/api/prompts/{slug}/visibility":         {"put"},
+		"/api/graphql":                           {"post"},
+		"/health":                                {"get"},
+		"/healthz":                               {"get"},
+		"/readyz":                                {"get"},
+		"/metrics":                               {"get"},
+	}
+	for path, methods := range routes {
+		for _, method := range methods {
+			if _, ok := spec.Paths[path][method]; !ok {
+				t.Errorf("Spec is missing %s %s", strings.ToUpper(method), path)
+			}
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/docs", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "/openapi.json") {
+		t.Error("Expected Swagger UI page to load /openapi.json")
+	}
+}
+
+func TestOpenAPISpec_SchemasMatchModels(t *testing.T) {
+	var spec struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("Spec is not valid JSON: %v", err)
+	}
+
+	types := map[string]any{
+		"PromptVersion":            models.PromptVersion{},
+		"PromptSummary":            models.PromptSummary{},
+		"PromptWithCurrentVersion": models.PromptWithCurrentVersion{},
+		"PublicPrompt":             models.PublicPrompt{},
+		"PublicPromptVersion":      models.PublicPromptVersion{},
+		"CreatePromptInput":        models.CreatePromptInput{},
+		"CreatePromptVersionInput": models.CreatePromptVersionInput{},
+		"SetVisibilityInput":       models.SetVisibilityInput{},
+		"SetDescriptionInput":      models.SetDescriptionInput{},
+		"MarkdownPreviewInput":     models.MarkdownPreviewInput{},
+		"PromptDocs":               models.PromptDocs{},
+		"SetDocsInput":             models.SetDocsInput{},
+		"CompactResult":            models.CompactResult{},
+		"ReopenInput":              models.ReopenInput{},
+		"Release":                  models.Release{},
+		"ReleaseItem":              models.ReleaseItem{},
+		"CreateReleaseInput":       models.CreateReleaseInput{},
+		"Collection":               models.Collection{},
+		"CreateCollectionInput":    models.CreateCollectionInput{},
+		"RetentionPolicy":          models.RetentionPolicy{},
+		"PromptRetention":          models.PromptRetention{},
+		"PrunedPrompt":             models.PrunedPrompt{},
+		"PruneResult":              models.PruneResult{},
+		"DeletedPrompt":            models.DeletedPrompt{},
+		"ReadOnlyStatus":           models.ReadOnlyStatus{},
+		"SemanticSearchResult":     models.SemanticSearchResult{},
+		"VersionDiff":              models.VersionDiff{},
+		"DiffHunk":                 models.DiffHunk{},
+		"DiffLine":                 models.DiffLine{},
+		"Environment":              models.Environment{},
+		"Deployment":               models.Deployment{},
+		"CreateEnvironmentInput":   models.CreateEnvironmentInput{},
+		"PromoteInput":             models.PromoteInput{},
+		"ReviewPolicy":             models.ReviewPolicy{},
+		"VersionReview":            models.VersionReview{},
+		"Approval":                 models.Approval{},
+		"ApproveVersionInput":      models.ApproveVersionInput{},
+		"SetReadOnlyInput":         models.SetReadOnlyInput{},
+		"PurgeResult":              models.PurgeResult{},
+		"RecomputeResult":          models.RecomputeResult{},
+		"PromptLabel":              models.PromptLabel{},
+		"Capabilities":             models.Capabilities{},
+		"CSRFToken":                models.CSRFToken{},
+		"ForkOrigin":               models.ForkOrigin{},
+		"ForkPromptInput":          models.ForkPromptInput{},
+		"Message":                  models.Message{},
+		"ModelConfig":              models.ModelConfig{},
+		"CapabilityFeatures":       models.CapabilityFeatures{},
+		"CapabilityLimits":         models.CapabilityLimits{},
+		"RateLimit":                models.RateLimit{},
+		"CapabilityAuth":           models.CapabilityAuth{},
+		"Webhook":                  models.Webhook{},
+		"CreateWebhookInput":       models.CreateWebhookInput{},
+		"WebhookEvent":             models.WebhookEvent{},
+		"VersionChanges":           models.VersionChanges{},
+		"Export":                   models.Export{},
+		"ExportedPrompt":           models.ExportedPrompt{},
+		"ExportedVersion":          models.ExportedVersion{},
+		"IntegrationStatus":        models.IntegrationStatus{},
+		"ImportVersionsInput":      models.ImportVersionsInput{},
+		"ImportVersion":            models.ImportVersion{},
+		"SyncInput":                models.SyncInput{},
+		"SyncResult":               models.SyncResult{},
+		"SyncedVersion":            models.SyncedVersion{},
+		"ImportResult":             models.ImportResult{},
+		"BatchGetPromptsInput":     models.BatchGetPromptsInput{},
+		"BatchGetPromptsResult":    models.BatchGetPromptsResult{},
+		"BackupStatus":             models.BackupStatus{},
+		"Readiness":                models.Readiness{},
+		"ReadinessCheck":           models.ReadinessCheck{},
+		"Dataset":                  models.Dataset{},
+		"DatasetItem":              models.DatasetItem{},
+		"CreateDatasetInput":       models.CreateDatasetInput{},
+		"StartEvalInput":           models.StartEvalInput{},
+		"EvalRun":                  models.EvalRun{},
+		"EvalResult":               models.EvalResult{},
+		"ReopenResult":             models.ReopenResult{},
+		"RestoreInput":             models.RestoreInput{},
+		"RestoreResult":            models.RestoreResult{},
+		"Project":                  models.Project{},
+		"AuditEntry":               models.AuditEntry{},
+		"Org":                      models.Org{},
+		"OrgMember":                models.OrgMember{},
+		"CreateOrgInput":           models.CreateOrgInput{},
+		"AddOrgMemberInput":        models.AddOrgMemberInput{},
+		"SetPinnedInput":           models.SetPinnedInput{},
+		"VersionComment":           models.VersionComment{},
+		"AddCommentInput":          models.AddCommentInput{},
+		"PromptUsage":              models.PromptUsage{},
+		"VersionUsage":             models.VersionUsage{},
+		"Analytics":                models.Analytics{},
+		"PromptActivity":           models.PromptActivity{},
+		"RegistryStats":            models.RegistryStats{},
+		"LabelCount":               models.LabelCount{},
+		"VersionCountBucket":       models.VersionCountBucket{},
+		"PromptSize":               models.PromptSize{},
+		"CreationCount":            models.CreationCount{},
+		"SetLegalHoldInput":        models.SetLegalHoldInput{},
+		"RedactVersionInput":       models.RedactVersionInput{},
+		"PromptGrant":              models.PromptGrant{},
+		"PromptACL":                models.PromptACL{},
+		"SetPromptACLInput":        models.SetPromptACLInput{},
+		"PromptLink":               models.PromptLink{},
+		"PromptLinks":              models.PromptLinks{},
+		"SetPromptLinksInput":      models.SetPromptLinksInput{},
+		"StartCaptureInput":        models.StartCaptureInput{},
+		"CaptureStatus":            models.CaptureStatus{},
+		"CapturedExchange":         models.CapturedExchange{},
+		"Variable":                 models.Variable{},
+		"SetVariablesInput":        models.SetVariablesInput{},
+		"RenderInput":              models.RenderInput{},
+		"RenderResult":             models.RenderResult{},
+		"LiveStats":                models.LiveStats{},
+		"LiveStatsPoint":           models.LiveStatsPoint{},
+		"PromptHits":               models.PromptHits{},
+		"ExecuteInput":             models.ExecuteInput{},
+		"ExecuteResult":            models.ExecuteResult{},
+		"ExecuteDelta":             models.ExecuteDelta{},
+		"ExecutionConfig":          models.ExecutionConfig{},
+		"TokenUsage":               models.TokenUsage{},
+		"ErrorResponse":            ErrorResponse{},
+	}
+	for name, value := range types {
+		schema, ok := spec.Components.Schemas[name]
+		if !ok {
+			t.Errorf("Spec is missing schema %s", name)
+			continue
+		}
+
+		fields := map[string]bool{}
+		typ := reflect.TypeOf(value)
+		for i := 0; i < typ.NumField(); i++ {
+			tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if tag != "" && tag != "-" {
+				fields[tag] = true
+			}
+		}
+
+		for field := range fields {
+			if _, ok := schema.Properties[field]; !ok {
+				t.Errorf("Schema %s is missing property %q", name, field)
+			}
+		}
+		for property := range schema.Properties {
+			if !fields[property] {
+				t.Errorf("Schema %s has property %q not present on the Go type", name, property)
+			}
+		}
+	}
+}
+
+func TestGetPromptHandler_ETag(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "tagged", Title: "Tagged", Content: "Hello"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/prompts/tagged", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with ETag, got %d and %q", w.Code, etag)
+	}
+
+	req = httptest.NewRequest("GET", "/api/prompts/tagged", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w.Code)
+	}
+
+	if _, err := h.Store.CreatePromptVersion("tagged", models.CreatePromptVersionInput{Content: "Changed"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("Expected 200 with a new ETag after update, got %d", w.Code)
+	}
+}
+
+func TestGetVersionHandler_ETag(t *testing.T) {
+	h := setupTestHandler(t)
+	h.Public.Enabled = true
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "tagged", Title: "Tagged", Content: "Hello", Public: true}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, path := range []string{"/api/prompts/tagged/versions/1", "/public/api/prompts/tagged", "/public/api/prompts/tagged/versions/1"} {
+		w := get(path, "")
+		etag := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || etag == "" {
+			t.Fatalf("%s: expected 200 with ETag, got %d and %q", path, w.Code, etag)
+		}
+		// Lists and weak tags from intermediate caches match too
+		for _, match := range []string{etag, `"stale", ` + etag, "W/" + etag} {
+			if w := get(path, match); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+				t.Errorf("%s: expected 304 for If-None-Match %s, got %d", path, match, w.Code)
+			}
+		}
+		if w := get(path, `"stale"`); w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200 for a stale ETag, got %d", path, w.Code)
+		}
+	}
+
+	etag := get("/api/prompts/tagged/versions/1", "").Header().Get("ETag")
+	if _, err := h.Store.SetVersionPinned("tagged", 1, true); err != nil {
+		t.Fatalf("SetVersionPinned failed: %v", err)
+	}
+	if w := get("/api/prompts/tagged/versions/1", etag); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 after pinning the version, got %d", w.Code)
+	}
+}
+
+// Test POST /api/admin/compact
+func TestCompactHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	h.AdminToken = "secret"
+	router := h.Routes()
+
+	req := httptest.NewRequest("POST", "/api/admin/compact", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/admin/compact", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var result models.CompactResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.SizeAfterBytes <= 0 {
+		t.Errorf("Expected a database size, got %+v", result)
+	}
+}
+
+// Test PUT /api/admin/prompts/{slug}/legal-hold
+func TestLegalHoldHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	h.AdminToken = "secret"
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "terms", Title: "Terms", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := h.Store.InProject("legal").CreatePrompt(models.CreatePromptInput{Slug: "terms", Title: "Terms", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	hold := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	req := httptest.NewRequest("PUT", "/api/admin/prompts/terms/legal-hold", strings.NewReader(`{"held": true, "reason": "case 42"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", w.Code)
+	}
+
+	w = hold("/api/admin/projects/legal/prompts/terms/legal-hold", `{"held": true, "reason": "case 42"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var prompt models.PromptWithCurrentVersion
+	if err := json.NewDecoder(w.Body).Decode(&prompt); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if prompt.LegalHoldAt == nil || prompt.LegalHoldReason != "case 42" {
+		t.Errorf("Expected held prompt, got %+v", prompt)
+	}
+	// The hold only covers the prompt in its own project
+	if prompt, err := h.Store.GetPromptBySlug("terms"); err != nil || prompt.LegalHoldAt != nil {
+		t.Errorf("Expected default project prompt not to be held, got %+v, %v", prompt, err)
+	}
+	entries, err := h.Store.InProject("legal").ListAuditEntries("terms", 1, 0)
+	if err != nil || len(entries) != 1 || entries[0].Action != "prompt.legal_hold_placed" || entries[0].Actor != "admin" || entries[0].Detail != "case 42" {
+		t.Errorf("Expected audit entry for the hold, got %+v, %v", entries, err)
+	}
+
+	for _, tc := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/admin/prompts/terms/legal-hold", `{"held": true}`, http.StatusBadRequest},
+		{"/api/admin/prompts/terms/legal-hold", `{`, http.StatusBadRequest},
+		{"/api/admin/projects/Bad_Name/prompts/terms/legal-hold", `{"held": false}`, http.StatusBadRequest},
+		{"/api/admin/prompts/missing/legal-hold", `{"held": true, "reason": "case 42"}`, http.StatusNotFound},
+		{"/api/admin/projects/legal/prompts/terms/legal-hold", `{"held": false}`, http.StatusOK},
+	} {
+		if w := hold(tc.path, tc.body); w.Code != tc.want {
+			t.Errorf("PUT %s %s: expected status %d, got %d", tc.path, tc.body, tc.want, w.Code)
+		}
+	}
+	if prompt, err := h.Store.InProject("legal").GetPromptBySlug("terms"); err != nil || prompt.LegalHoldAt != nil || prompt.LegalHoldReason != "" {
+		t.Errorf("Expected hold to be released, got %+v, %v", prompt, err)
+	}
+}
+
+func TestRedactVersionHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	h.AdminToken = "secret"
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "support", Title: "Support", Content: "api key sk-live-123"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := h.Store.CreatePromptVersion("support", models.CreatePromptVersionInput{Content: "api key {{key}}"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	redact := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	req := httptest.NewRequest("POST", "/api/admin/prompts/support/versions/1/redact", strings.NewReader(`{"reason": "SEC-7"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", w.Code)
+	}
+
+	w = redact("/api/admin/prompts/support/versions/1/redact", `{"reason": "SEC-7"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var version models.PromptVersion
+	if err := json.NewDecoder(w.Body).Decode(&version); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if version.VersionNumber != 1 || version.RedactedAt == nil || strings.Contains(version.Content, "sk-live") || version.OriginalSHA256 == "" {
+		t.Errorf("Expected redacted version 1, got %+v", version)
+	}
+
+	// The redaction is what every read path serves from now on
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/prompts/support/versions/1", nil))
+	if strings.Contains(w.Body.String(), "sk-live") {
+		t.Errorf("Expected redacted content, got %s", w.Body.String())
+	}
+	entries, err := h.Store.ListAuditEntries("support", 1, 0)
+	if err != nil || len(entries) != 1 || entries[0].Action != "prompt.version_redacted" || entries[0].Actor != "admin" || entries[0].Detail != "SEC-7" {
+		t.Errorf("Expected audit entry for the redaction, got %+v, %v", entries, err)
+	}
+
+	if err := h.Store.SetLegalHold("support", true, "case 42"); err != nil {
+		t.Fatalf("SetLegalHold failed: %v", err)
+	}
+	for _, tc := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/admin/prompts/support/versions/1/redact", `{"reason": "again"}`, http.StatusConflict},
+		{"/api/admin/prompts/support/versions/2/redact", `{"reason": "SEC-8"}`, http.StatusConflict},
+		{"/api/admin/prompts/support/versions/2/redact", `{}`, http.StatusBadRequest},
+		{"/api/admin/prompts/support/versions/x/redact", `{"reason": "SEC-8"}`, http.StatusBadRequest},
+		{"/api/admin/prompts/support/versions/9/redact", `{"reason": "SEC-8"}`, http.StatusNotFound},
+		{"/api/admin/projects/Bad_Name/prompts/support/versions/2/redact", `{"reason": "SEC-8"}`, http.StatusBadRequest},
+	} {
+		if w := redact(tc.path, tc.body); w.Code != tc.want {
+			t.Errorf("POST %s %s: expected status %d, got %d", tc.path, tc.body, tc.want, w.Code)
+		}
+	}
+}
+
+func TestReopenHandler(t *testing.T) {
+	dir := t.TempDir()
+	next, err := store.New(filepath.Join(dir, "next.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if _, err := next.CreatePrompt(models.CreatePromptInput{Slug: "restored", Title: "Restored", Content: "r"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	next.Close()
+
+	h := setupTestHandler(t)
+	h.AdminToken = "secret"
+	router := h.Routes()
+
+	reopen := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/admin/reopen", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := reopen(`{"path": "` + filepath.Join(dir, "missing.db") + `"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for missing file, got %d", w.Code)
+	}
+
+	w := reopen(`{"path": "` + filepath.Join(dir, "next.db") + `"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result models.ReopenResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.PreviousPath != ":memory:" || result.Path != filepath.Join(dir, "next.db") {
+		t.Errorf("Unexpected reopen result: %+v", result)
+	}
+
+	req := httptest.NewRequest("GET", "/api/prompts/restored", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected restored prompt to be served after reopen, got %d", w.Code)
+	}
+	if h.maintenance.Load() {
+		t.Error("Expected maintenance mode to end after reopen")
+	}
+}
+
+func TestRestoreHandler(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.New(filepath.Join(dir, "prompts.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	h := New(s, logger)
+	h.AdminToken = "secret"
+	h.Backups, err = backup.New(backup.Config{Dir: filepath.Join(dir, "backups"), Schedule: "@daily"}, s, logger)
+	if err != nil {
+		t.Fatalf("backup.New failed: %v", err)
+	}
+	router := h.Routes()
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "backed-up", Title: "Backed up", Content: "b"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	backupPath, err := h.Backups.Run()
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "after", Title: "After", Content: "a"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	restore := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/admin/restore", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := restore(`{"path": "missing.db"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for missing backup, got %d", w.Code)
+	}
+	if w := restore(`{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a path, got %d", w.Code)
+	}
+
+	// Refused while a write is being served
+	h.writes.Add(1)
+	if w := restore(`{"path": "` + filepath.Base(backupPath) + `"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 with writes in flight, got %d", w.Code)
+	}
+	h.writes.Add(-1)
+	if h.maintenance.Load() {
+		t.Fatal("Expected maintenance mode to end after a refused restore")
+	}
+
+	// Bare file names are looked up in the backup directory
+	w := restore(`{"path": "` + filepath.Base(backupPath) + `"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result models.RestoreResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.BackupPath != backupPath || result.PreviousCopy == "" {
+		t.Errorf("Unexpected restore result: %+v", result)
+	}
+
+	for slug, want := range map[string]int{"backed-up": http.StatusOK, "after": http.StatusNotFound} {
+		req := httptest.NewRequest("GET", "/api/prompts/"+slug, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("Expected status %d for %s after restore, got %d", want, slug, w.Code)
+		}
+	}
+	if h.maintenance.Load() || h.writes.Load() != 0 {
+		t.Error("Expected maintenance mode to end after restore")
+	}
+}
+
+func TestAuditAttribution(t *testing.T) {
+	h := setupTestHandler(t)
+	keys, err := auth.NewAPIKeys(map[string]string{"key-ci": "ci", "key-ops": "ops"})
+	if err != nil {
+		t.Fatalf("NewAPIKeys failed: %v", err)
+	}
+	h.Auth = keys
+	router := h.Routes()
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/api/projects/search/prompts", "key-ci", `{"slug":"greeting","title":"Greeting","content":"hi"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	w := do("POST", "/api/projects/search/prompts/greeting/versions", "key-ops", `{"content":"hello"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var prompt models.PromptWithCurrentVersion
+	if err := json.NewDecoder(w.Body).Decode(&prompt); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if prompt.UpdatedBy != "ops" || prompt.CurrentVersion.CreatedBy != "ops" {
+		t.Errorf("Expected version attributed to ops, got %+v", prompt)
+	}
+
+	w = do("GET", "/api/projects/search/prompts/greeting/audit", "key-ci", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var entries []models.AuditEntry
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil {
+		t.Fatalf("Failed to decode audit log: %v", err)
+	}
+	if len(entries) != 2 ||
+		entries[0].Actor != "ops" || entries[0].Action != "prompt.version_created" ||
+		entries[1].Actor != "ci" || entries[1].Action != "prompt.created" || entries[1].Project != "search" {
+		t.Errorf("Unexpected audit log: %+v", entries)
+	}
+	if w := do("GET", "/api/prompts/greeting/audit", "key-ci", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 outside the prompt's project, got %d", w.Code)
+	}
+
+	w = do("GET", "/api/projects/search/prompts/greeting/audit?limit=1&offset=1", "key-ci", "")
+	entries = nil
+	json.NewDecoder(w.Body).Decode(&entries)
+	if w.Code != http.StatusOK || len(entries) != 1 || entries[0].Action != "prompt.created" {
+		t.Errorf("Expected the second entry alone, got %d: %+v", w.Code, entries)
+	}
+	for _, query := range []string{"limit=-1", "limit=0", "limit=1001", "limit=x", "offset=-1", "offset=x"} {
+		if w := do("GET", "/api/projects/search/prompts/greeting/audit?"+query, "key-ci", ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestOrgProjectVisibility(t *testing.T) {
+	h := setupTestHandler(t)
+	keys, err := auth.NewAPIKeys(map[string]string{"key-a": "alice", "key-b": "bob", "key-c": "carol"})
+	if err != nil {
+		t.Fatalf("NewAPIKeys failed: %v", err)
+	}
+	h.Auth = keys
+	router := h.Routes()
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	expect := func(w *httptest.ResponseRecorder, want int) {
+		t.Helper()
+		if w.Code != want {
+			t.Errorf("Expected status %d, got %d: %s", want, w.Code, w.Body.String())
+		}
+	}
+
+	expect(do("POST", "/api/orgs", "key-a", `{"name":"search"}`), http.StatusCreated)
+	expect(do("POST", "/api/orgs", "key-b", `{"name":"search"}`), http.StatusConflict)
+	expect(do("POST", "/api/orgs/search/members", "key-a", `{"subject":"bob"}`), http.StatusCreated)
+	expect(do("POST", "/api/orgs/search/members", "key-b", `{"subject":"carol"}`), http.StatusForbidden)
+	expect(do("GET", "/api/orgs/search", "key-c", ""), http.StatusNotFound)
+
+	expect(do("POST", "/api/projects/ranking/prompts", "key-a", `{"slug":"p","title":"P","content":"x"}`), http.StatusCreated)
+	expect(do("PUT", "/api/orgs/search/projects/ranking", "key-b", ""), http.StatusForbidden)
+	expect(do("PUT", "/api/orgs/search/projects/default", "key-a", ""), http.StatusBadRequest)
+	expect(do("PUT", "/api/orgs/search/projects/ranking", "key-a", ""), http.StatusNoContent)
+
+	expect(do("GET", "/api/projects/ranking/prompts/p", "key-b", ""), http.StatusOK)
+	expect(do("GET", "/api/projects/ranking/prompts/p", "key-c", ""), http.StatusNotFound)
+	expect(do("POST", "/api/projects/ranking/prompts", "key-c", `{"slug":"q","title":"Q","content":"x"}`), http.StatusNotFound)
+	expect(do("GET", "/api/projects/ranking/export", "key-c", ""), http.StatusNotFound)
+
+	w := do("POST", "/api/graphql", "key-c", `{"query":"{ prompts(project: \"ranking\") { slug } }"}`)
+	if !strings.Contains(w.Body.String(), "not found") {
+		t.Errorf("Expected GraphQL to hide the project, got %s", w.Body.String())
+	}
+
+	var projects []models.Project
+	if err := json.NewDecoder(do("GET", "/api/projects", "key-c", "").Body).Decode(&projects); err != nil {
+		t.Fatalf("Failed to decode projects: %v", err)
+	}
+	if len(projects) != 1 || projects[0].Name != "default" {
+		t.Errorf("Expected only the default project for a non-member, got %+v", projects)
+	}
+
+	w = do("GET", "/api/orgs/search", "key-b", "")
+	expect(w, http.StatusOK)
+	var org models.Org
+	if err := json.NewDecoder(w.Body).Decode(&org); err != nil {
+		t.Fatalf("Failed to decode org: %v", err)
+	}
+	if len(org.Members) != 2 || !reflect.DeepEqual(org.Projects, []string{"ranking"}) {
+		t.Errorf("Unexpected org: %+v", org)
+	}
+
+	expect(do("DELETE", "/api/orgs/search/members/alice", "key-a", ""), http.StatusConflict)
+	expect(do("DELETE", "/api/orgs/search/members/bob", "key-a", ""), http.StatusNoContent)
+	expect(do("GET", "/api/projects/ranking/prompts/p", "key-b", ""), http.StatusNotFound)
+	expect(do("DELETE", "/api/orgs/search/projects/ranking", "key-a", ""), http.StatusNoContent)
+	expect(do("GET", "/api/projects/ranking/prompts/p", "key-c", ""), http.StatusOK)
+}
+
+func TestPromptACL(t *testing.T) {
+	h := setupTestHandler(t)
+	keys, err := auth.NewAPIKeys(map[string]string{"key-a": "alice", "key-b": "bob", "key-c": "carol", "key-d": "dave"})
+	if err != nil {
+		t.Fatalf("NewAPIKeys failed: %v", err)
+	}
+	h.Auth = keys
+	router := h.Routes()
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	expect := func(w *httptest.ResponseRecorder, want int) {
+		t.Helper()
+		if w.Code != want {
+			t.Errorf("Expected status %d, got %d: %s", want, w.Code, w.Body.String())
+		}
+	}
+
+	expect(do("POST", "/api/prompts", "key-a", `{"slug":"system","title":"System","content":"Be careful"}`), http.StatusCreated)
+	expect(do("POST", "/api/orgs", "key-a", `{"name":"safety"}`), http.StatusCreated)
+	expect(do("POST", "/api/orgs/safety/members", "key-a", `{"subject":"carol"}`), http.StatusCreated)
+
+	// Without grants the prompt is open to everyone, but only the owner manages access
+	expect(do("POST", "/api/prompts/system/versions", "key-d", `{"content":"v2"}`), http.StatusCreated)
+	expect(do("PUT", "/api/prompts/system/acl", "key-d", `{"grants":[]}`), http.StatusForbidden)
+	expect(do("PUT", "/api/prompts/system/acl", "key-a", `{"grants":[{"type":"team","name":"x","access":"read"}]}`), http.StatusBadRequest)
+	expect(do("PUT", "/api/prompts/system/acl", "key-a", `{"grants":[{"type":"org","name":"missing","access":"read"}]}`), http.StatusBadRequest)
+	expect(do("PUT", "/api/prompts/system/acl", "key-a",
+		`{"grants":[{"type":"user","name":"bob","access":"read"},{"type":"org","name":"safety","access":"write"}]}`), http.StatusOK)
+
+	// bob reads, carol writes through the org, dave can't see the prompt at all
+	expect(do("GET", "/api/prompts/system", "key-b", ""), http.StatusOK)
+	expect(do("POST", "/api/prompts/system/render", "key-b", `{}`), http.StatusOK)
+	expect(do("POST", "/api/prompts/system/versions/1/comments", "key-b", `{"body":"Looks good"}`), http.StatusCreated)
+	expect(do("POST", "/api/prompts/system/versions/1/comments", "key-d", `{"body":"Hi"}`), http.StatusNotFound)
+	expect(do("POST", "/api/prompts/system/versions", "key-b", `{"content":"v3"}`), http.StatusForbidden)
+	expect(do("POST", "/api/prompts/system/versions", "key-c", `{"content":"v3"}`), http.StatusCreated)
+	expect(do("PUT", "/api/prompts/system/acl", "key-c", `{"grants":[]}`), http.StatusForbidden)
+	expect(do("GET", "/api/prompts/system/webhooks", "key-b", ""), http.StatusForbidden)
+	expect(do("GET", "/api/prompts/system/webhooks", "key-c", ""), http.StatusOK)
+	for _, path := range []string{"/api/prompts/system", "/api/prompts/system/versions", "/api/prompts/system/acl", "/api/prompts/system/audit"} {
+		expect(do("GET", path, "key-d", ""), http.StatusNotFound)
+	}
+	expect(do("POST", "/api/prompts/system/archive", "key-d", ""), http.StatusNotFound)
+
+	var summaries []models.PromptSummary
+	if err := json.NewDecoder(do("GET", "/api/prompts", "key-d", "").Body).Decode(&summaries); err != nil {
+		t.Fatalf("Failed to decode prompts: %v", err)
+	}
+	if len(summaries) != 0 {
+		t.Errorf("Expected restricted prompt to be left out of listings, got %+v", summaries)
+	}
+	if w := do("GET", "/api/export", "key-d", ""); strings.Contains(w.Body.String(), "system") {
+		t.Errorf("Expected restricted prompt to be left out of exports, got %s", w.Body.String())
+	}
+	if w := do("POST", "/api/graphql", "key-d", `{"query":"{ prompt(slug: \"system\") { slug } }"}`); !strings.Contains(w.Body.String(), "not found") {
+		t.Errorf("Expected GraphQL to hide the prompt, got %s", w.Body.String())
+	}
+	for key, want := range map[string]bool{"key-b": true, "key-d": false} {
+		var stats models.LiveStats
+		if err := json.NewDecoder(do("GET", "/api/stats/live", key, "").Body).Decode(&stats); err != nil {
+			t.Fatalf("Failed to decode live stats: %v", err)
+		}
+		listed := slices.ContainsFunc(stats.TopPrompts, func(hit models.PromptHits) bool { return hit.Slug == "system" })
+		if listed != want {
+			t.Errorf("Expected %s to see the prompt in top prompts: %v, got %+v", key, want, stats.TopPrompts)
+		}
+	}
+
+	// Transferring ownership hands over access management
+	expect(do("PUT", "/api/prompts/system/acl", "key-a", `{"owner":"bob","grants":[]}`), http.StatusOK)
+	var acl models.PromptACL
+	if err := json.NewDecoder(do("GET", "/api/prompts/system/acl", "key-d", "").Body).Decode(&acl); err != nil {
+		t.Fatalf("Failed to decode acl: %v", err)
+	}
+	if acl.Owner != "bob" || len(acl.Grants) != 0 {
+		t.Errorf("Expected bob to own an open prompt, got %+v", acl)
+	}
+	expect(do("PUT", "/api/prompts/system/acl", "key-a", `{"grants":[]}`), http.StatusForbidden)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	h := setupTestHandler(t)
+	h.AdminToken = "admin-secret"
+	h.Public.Enabled = true
+	keys, err := auth.NewAPIKeys(map[string]string{"key-ci": "ci"})
+	if err != nil {
+		t.Fatalf("NewAPIKeys failed: %v", err)
+	}
+	h.Auth = keys
+	router := h.Routes()
+
+	tests := []struct {
+		method, path, header string
+		want                 int
+	}{
+		{"GET", "/api/prompts", "", http.StatusUnauthorized},
+		{"GET", "/api/prompts", "Bearer wrong", http.StatusUnauthorized},
+		{"GET", "/api/prompts", "Bearer key-ci", http.StatusOK},
+		{"POST", "/api/prompts", "", http.StatusUnauthorized},
+		{"GET", "/ws", "", http.StatusUnauthorized},
+		// Left open: admin routes check their own token, and the rest are meant to be public
+		{"POST", "/api/admin/compact", "Bearer admin-secret", http.StatusOK},
+		{"GET", "/public/", "", http.StatusOK},
+		{"GET", "/health", "", http.StatusOK},
+		{"GET", "/openapi.json", "", http.StatusOK},
+		{"OPTIONS", "/api/prompts", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s %s with %q: expected status %d, got %d", tt.method, tt.path, tt.header, tt.want, w.Code)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s %s: expected WWW-Authenticate header", tt.method, tt.path)
+		}
+	}
+
+	// An identity provider outage is not reported as bad credentials
+	h.Auth = unavailableAuth{}
+	req := httptest.NewRequest("GET", "/api/prompts", nil)
+	w := httptest.NewRecorder()
+	h.Routes().ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 when authentication is unavailable, got %d", w.Code)
+	}
+}
+
+type unavailableAuth struct{}
+
+func (unavailableAuth) Authenticate(*http.Request) (auth.Identity, error) {
+	return auth.Identity{}, fmt.Errorf("%w: provider down", auth.ErrUnavailable)
+}
+
+func TestCompactHandler_DisabledWithoutToken(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	req := httptest.NewRequest("POST", "/api/admin/compact", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code == http.StatusOK {
+		t.Errorf("Expected admin routes to be unmounted without ADMIN_TOKEN")
+	}
+}
+
+func TestMaintenanceMode_RejectsWrites(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+	h.maintenance.Store(true)
+
+	req := httptest.NewRequest("POST", "/api/prompts", strings.NewReader(`{"title": "Blocked", "content": "x"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 during maintenance, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected Retry-After header")
+	}
+
+	req = httptest.NewRequest("GET", "/api/prompts", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected reads to succeed during maintenance, got %d", w.Code)
+	}
+}
+
+// Test POST /api/prompts/{slug}/render
+func TestRenderHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	_, err := h.Store.CreatePrompt(models.CreatePromptInput{
+		Slug:    "greet",
+		Title:   "Greet",
+		Content: "Hello {{name}}, you have {{count}} messages",
+		Variables: []models.Variable{
+			{Name: "name", Type: "string", Required: true},
+			{Name: "count", Type: "integer", Required: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantText   string
+	}{
+		{"valid", `{"variables": {"name": "Ada", "count": 3}}`, http.StatusOK, "Hello Ada, you have 3 messages"},
+		{"missing required", `{"variables": {"name": "Ada"}}`, http.StatusBadRequest, "count is required"},
+		{"wrong type", `{"variables": {"name": "Ada", "count": "three"}}`, http.StatusBadRequest, "count must be of type integer"},
+		{"unknown version", `{"variables": {"name": "Ada", "count": 3}, "version": 9}`, http.StatusNotFound, "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/prompts/greet/render", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantText) {
+				t.Errorf("Expected body to contain %q, got %s", tt.wantText, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRenderHandler_Provenance(t *testing.T) {
+	h := setupTestHandler(t)
+	h.BaseURL = "https://prompts.example.com/"
+	router := h.Routes()
+
+	if _, err := h.Store.InProject("search").CreatePrompt(models.CreatePromptInput{Slug: "greet", Title: "Greet", Content: "Hello {{name}}"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	sum := sha256.Sum256([]byte("Hello {{name}}"))
+	hash := "sha256:" + hex.EncodeToString(sum[:])
+
+	req := httptest.NewRequest("POST", "/api/projects/search/prompts/greet/render", strings.NewReader(`{"variables": {"name": "Ada"}, "provenance": true}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	for header, want := range map[string]string{
+		"X-Prompt-Registry":     "https://prompts.example.com",
+		"X-Prompt-Project":      "search",
+		"X-Prompt-Slug":         "greet",
+		"X-Prompt-Version":      "1",
+		"X-Prompt-Content-Hash": hash,
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("Expected %s %q, got %q", header, want, got)
+		}
+	}
+	var result models.RenderResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	wantContent := "Hello Ada\n\n<!-- prompt-registry: https://prompts.example.com project=search slug=greet version=1 " + hash + " -->"
+	if result.Content != wantContent {
+		t.Errorf("Expected content %q, got %q", wantContent, result.Content)
+	}
+	if result.Provenance == nil || result.Provenance.ContentHash != hash || result.Provenance.Project != "search" {
+		t.Errorf("Unexpected provenance: %+v", result.Provenance)
+	}
+
+	// Without the flag the content is untouched, and raw version lookups carry the headers too
+	req = httptest.NewRequest("POST", "/api/projects/search/prompts/greet/render", strings.NewReader(`{"variables": {"name": "Ada"}}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if strings.Contains(w.Body.String(), "<!--") {
+		t.Errorf("Expected no provenance comment, got %s", w.Body.String())
+	}
+	req = httptest.NewRequest("GET", "/api/projects/search/prompts/greet/versions/1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if got := w.Header().Get("X-Prompt-Content-Hash"); got != hash {
+		t.Errorf("Expected content hash on version lookup, got %q", got)
+	}
+	var version models.PromptVersion
+	if err := json.NewDecoder(w.Body).Decode(&version); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if "sha256:"+version.ContentSHA256 != hash {
+		t.Errorf("Expected content_sha256 to match the header, got %q", version.ContentSHA256)
+	}
+}
+
+func TestLatencyBudget(t *testing.T) {
+	h := setupTestHandler(t)
+	slo, err := ParseLatencySLO("POST /api/prompts/{slug}/render=1ns:50:fast-fail")
+	if err != nil {
+		t.Fatalf("ParseLatencySLO failed: %v", err)
+	}
+	h.LatencyBudget.SLOs = []LatencySLO{slo}
+	h.LatencyBudget.MinRequests = 3
+	if err := h.LatencyBudget.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	router := h.Routes()
+	now := time.Now()
+	h.latency.now = func() time.Time { return now }
+
+	if _, err := h.Store.InProject("search").CreatePrompt(models.CreatePromptInput{Slug: "greet", Title: "Greet", Content: "Hello"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	render := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/projects/search/prompts/greet/render", strings.NewReader(`{}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Every request misses a 1ns threshold, but the budget needs 3 requests to judge
+	for range 3 {
+		if w := render(); w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 before the budget is exhausted, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	w := render()
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected 503 with Retry-After once the budget is exhausted, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	mw := httptest.NewRecorder()
+	router.ServeHTTP(mw, req)
+	for _, want := range []string{
+		`latency_slo_requests_total{result="bad",route="POST /api/prompts/{slug}/render"} 3`,
+		`latency_slo_burn_rate{route="POST /api/prompts/{slug}/render",window="1h"} 2`,
+		`latency_slo_burn_rate{route="POST /api/prompts/{slug}/render",window="5m"} 2`,
+		`latency_slo_fast_failed_total{route="POST /api/prompts/{slug}/render"} 1`,
+	} {
+		if !strings.Contains(mw.Body.String(), want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+
+	// Once the short window has drained, requests are let through again
+	now = now.Add(5 * time.Minute)
+	if w := render(); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 after the short window drained, got %d", w.Code)
+	}
+
+	for _, spec := range []string{
+		"POST /api/prompts/{slug}/render=250ms",
+		"POST /api/prompts/{slug}/render=fast:99",
+		"POST /api/prompts/{slug}/render=250ms:100",
+		"POST /api/prompts=250ms:99:fast-fail",
+	} {
+		slo, err := ParseLatencySLO(spec)
+		if err == nil {
+			err = LatencyBudgetConfig{SLOs: []LatencySLO{slo}, Window: time.Hour}.Validate()
+		}
+		if err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+// Test PUT /api/prompts/{slug}/variables
+func TestSetVariablesHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "greet", Title: "Greet", Content: "Hello {{name}}"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	req := httptest.NewRequest("PUT", "/api/prompts/greet/variables", strings.NewReader(`{"variables": [{"name": "other", "type": "string"}]}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for schema missing a placeholder, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/prompts/greet/variables", strings.NewReader(`{"variables": [{"name": "name", "type": "string", "required": true}]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/prompts/greet/versions", strings.NewReader(`{"content": "Hi {{nickname}}"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for undeclared placeholder, got %d", w.Code)
+	}
+}
+
+// Test PUT /api/prompts/{slug}/versions/{version}/pin
+func TestSetVersionPinnedHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "terms", Title: "Terms", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	req := httptest.NewRequest("PUT", "/api/prompts/terms/versions/1/pin", strings.NewReader(`{"pinned": true}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var version models.PromptVersion
+	if err := json.NewDecoder(w.Body).Decode(&version); err != nil || !version.Pinned || version.VersionNumber != 1 {
+		t.Errorf("Expected pinned version 1, got %+v, %v", version, err)
+	}
+
+	req = httptest.NewRequest("GET", "/api/prompts/terms/versions", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"pinned":true`) {
+		t.Errorf("Expected pin in version listing, got %s", w.Body.String())
+	}
+
+	for _, tc := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/prompts/terms/versions/abc/pin", `{"pinned": true}`, http.StatusBadRequest},
+		{"/api/prompts/terms/versions/1/pin", `{`, http.StatusBadRequest},
+		{"/api/prompts/terms/versions/2/pin", `{"pinned": true}`, http.StatusNotFound},
+		{"/api/prompts/missing/versions/1/pin", `{"pinned": true}`, http.StatusNotFound},
+	} {
+		req = httptest.NewRequest("PUT", tc.path, strings.NewReader(tc.body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("PUT %s %s: expected status %d, got %d", tc.path, tc.body, tc.want, w.Code)
+		}
+	}
+}
+
+// Test GET /api/stats/live
+func TestLiveStatsHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "popular", Title: "Popular", Content: "x"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	for _, path := range []string{"/api/prompts/popular", "/api/prompts/popular", "/api/prompts/missing", "/api/prompts"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	req := httptest.NewRequest("GET", "/api/stats/live", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var stats models.LiveStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats.Requests != 4 {
+		t.Errorf("Expected 4 requests, got %d", stats.Requests)
+	}
+	if stats.ClientErrors != 1 {
+		t.Errorf("Expected 1 client error, got %d", stats.ClientErrors)
+	}
+	if len(stats.TopPrompts) == 0 || stats.TopPrompts[0] != (models.PromptHits{Project: store.DefaultProject, Slug: "popular", Requests: 2}) {
+		t.Errorf("Expected popular to lead top prompts, got %+v", stats.TopPrompts)
+	}
+	if len(stats.Series) != 30 {
+		t.Errorf("Expected 30 series points, got %d", len(stats.Series))
+	}
+}
+
+func TestLiveStats_ExpiresOldBuckets(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	ls := newLiveStats()
+	ls.now = func() time.Time { return now }
+
+	all := func(project, slug string) bool { return true }
+	ls.record(http.StatusOK, "", "a")
+	ls.record(http.StatusInternalServerError, "", "")
+	if s := ls.snapshot(all); s.Requests != 2 || s.Errors != 1 || s.ErrorRate != 0.5 {
+		t.Errorf("Expected 2 requests with 1 error, got %+v", s)
+	}
+
+	now = now.Add(liveStatsWindow)
+	ls.record(http.StatusOK, "", "b")
+	ls.record(http.StatusOK, "search", "b")
+	s := ls.snapshot(all)
+	if s.Requests != 2 || s.Errors != 0 {
+		t.Errorf("Expected only the recent requests, got %+v", s)
+	}
+	want := []models.PromptHits{{Project: store.DefaultProject, Slug: "b", Requests: 1}, {Project: "search", Slug: "b", Requests: 1}}
+	if !slices.Equal(s.TopPrompts, want) {
+		t.Errorf("Expected b in each project in top prompts, got %+v", s.TopPrompts)
+	}
+
+	// Hidden prompts are left out of top prompts but still counted
+	s = ls.snapshot(func(project, slug string) bool { return project != "search" })
+	if s.Requests != 2 || !slices.Equal(s.TopPrompts, want[:1]) {
+		t.Errorf("Expected only the visible prompt, got %+v", s)
+	}
+}
+
+// Test POST /api/prompts/{slug}/execute
+func TestExecuteHandler(t *testing.T) {
+	var gotPrompt, gotAuth string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Messages) > 0 {
+			gotPrompt = req.Messages[0].Content
+		}
+		if gotPrompt == "fail" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "quota exceeded"}}`))
+			return
+		}
+		w.Write([]byte(`{
+			"model": "test-model",
+			"choices": [{"message": {"role": "assistant", "content": "Bonjour"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 5, "completion_tokens": 1, "total_tokens": 6}
+		}`))
+	}))
+	defer provider.Close()
+
+	h := setupTestHandler(t)
+	h.Providers = providers.NewRegistry()
+	h.Providers.Register(providers.NewOpenAICompatible("openai", provider.URL, "sk-test", "test-model"))
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "translate", Title: "Translate", Content: "Translate {{word}}"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "failing", Title: "Failing", Content: "fail"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/prompts/translate/execute", strings.NewReader(`{"variables": {"word": "hello"}}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var result models.ExecuteResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if gotPrompt != "Translate hello" || gotAuth != "Bearer sk-test" {
+		t.Errorf("Provider got prompt %q with auth %q", gotPrompt, gotAuth)
+	}
+	if result.Completion != "Bonjour" || result.Usage.TotalTokens != 6 || result.Model != "test-model" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	req = httptest.NewRequest("POST", "/api/prompts/translate/execute", strings.NewReader(`{"variables": {}}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for missing variable, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/prompts/failing/execute", strings.NewReader(`{}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "quota exceeded") {
+		t.Errorf("Expected 502 with provider message, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/prompts/translate/execute", strings.NewReader(`{"variables": {"word": "hi"}, "provider": "anthropic"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unconfigured provider, got %d", w.Code)
+	}
+}
+
+func TestSetExecutionHandler(t *testing.T) {
+	var gotModels []string
+	fake := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				Model string `json:"model"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			gotModels = append(gotModels, name+":"+req.Model)
+			w.Write([]byte(`{"choices": [{"message": {"content": "ok"}, "finish_reason": "stop"}]}`))
+		}))
+	}
+	openai, local := fake("openai"), fake("local")
+	defer openai.Close()
+	defer local.Close()
+
+	h := setupTestHandler(t)
+	h.Providers = providers.NewRegistry()
+	h.Providers.Register(providers.NewOpenAICompatible("openai", openai.URL, "sk-test", "gpt"))
+	h.Providers.Register(providers.NewOpenAICompatible("local", local.URL, "", "llama"))
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "Summarize"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	req := httptest.NewRequest("PUT", "/api/prompts/summarize/execution", strings.NewReader(`{"provider": "local", "model": "qwen"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	prompt, err := h.Store.GetPromptBySlug("summarize")
+	if err != nil {
+		t.Fatalf("GetPromptBySlug failed: %v", err)
+	}
+	if prompt.Execution == nil || prompt.Execution.Provider != "local" || prompt.Execution.Model != "qwen" {
+		t.Errorf("Unexpected execution config: %+v", prompt.Execution)
+	}
+
+	for _, body := range []string{`{}`, `{"model": "mistral"}`, `{"provider": "openai"}`} {
+		req = httptest.NewRequest("POST", "/api/prompts/summarize/execute", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+	want := []string{"local:qwen", "local:mistral", "openai:gpt"}
+	if strings.Join(gotModels, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected provider calls %v, got %v", want, gotModels)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/prompts/summarize/execution", strings.NewReader(`{"provider": "anthropic"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unconfigured provider, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/prompts/missing/execution", strings.NewReader(`{"provider": "local"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for missing prompt, got %d", w.Code)
+	}
+}
+
+func TestRequestCapture(t *testing.T) {
+	h := setupTestHandler(t)
+	h.AdminToken = "secret"
+	router := h.Routes()
+
+	admin := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/admin/capture", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	call := func(method, path, token, body string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	for _, body := range []string{`{}`, `{"slug": "greeting", "duration_seconds": 7200}`} {
+		if w := admin("POST", body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", body, w.Code)
+		}
+	}
+
+	w := admin("POST", `{"slug": "greeting", "duration_seconds": 60}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	call("POST", "/api/prompts", "client-key", `{"slug": "greeting", "title": "Greeting", "content": "Hi {{name}}"}`)
+	call("POST", "/api/prompts", "", `{"slug": "other", "title": "Other", "content": "x"}`)
+	call("POST", "/api/prompts/greeting/render", "client-key", `{"variables": {}}`)
+	call("GET", "/api/prompts/other", "", "")
+
+	var status models.CaptureStatus
+	if err := json.NewDecoder(admin("GET", "").Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !status.Active || status.Slug != "greeting" || status.ExpiresAt == nil {
+		t.Errorf("Unexpected capture status: %+v", status)
+	}
+	// Creating a prompt has no slug in the path, so only the render call matches
+	if len(status.Exchanges) != 1 {
+		t.Fatalf("Expected 1 captured exchange, got %d", len(status.Exchanges))
+	}
+	exchange := status.Exchanges[0]
+	if exchange.Path != "/api/prompts/greeting/render" || exchange.Status != http.StatusBadRequest {
+		t.Errorf("Unexpected exchange: %+v", exchange)
+	}
+	if exchange.RequestBody != `{"variables": {}}` || !strings.Contains(exchange.ResponseBody, "missing values for name") {
+		t.Errorf("Expected request and response bodies, got %q and %q", exchange.RequestBody, exchange.ResponseBody)
+	}
+	if exchange.RequestHeaders["Authorization"] != "[redacted]" {
+		t.Errorf("Expected Authorization to be redacted, got %q", exchange.RequestHeaders["Authorization"])
+	}
+
+	admin("POST", `{"api_key": "client-key"}`)
+	call("GET", "/api/prompts/greeting", "client-key", "")
+	call("GET", "/api/prompts/greeting", "other-key", "")
+	// A key sent in X-API-Key matches the filter too and is redacted like a bearer token
+	req := httptest.NewRequest("GET", "/api/prompts/greeting", nil)
+	req.Header.Set("X-API-Key", "client-key")
+	req.Header.Set("X-CSRF-Token", "csrf-token")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	status = models.CaptureStatus{}
+	json.NewDecoder(admin("GET", "").Body).Decode(&status)
+	if len(status.Exchanges) != 2 || status.APIKey != "...-key" {
+		t.Fatalf("Expected 2 exchanges for the masked key, got %d (%q)", len(status.Exchanges), status.APIKey)
+	}
+	for _, name := range []string{"X-Api-Key", "X-Csrf-Token"} {
+		if got := status.Exchanges[1].RequestHeaders[name]; got != "[redacted]" {
+			t.Errorf("Expected %s to be redacted, got %q", name, got)
+		}
+	}
+
+	if w := admin("DELETE", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+	call("GET", "/api/prompts/greeting", "client-key", "")
+	status = models.CaptureStatus{}
+	json.NewDecoder(admin("GET", "").Body).Decode(&status)
+	if status.Active || len(status.Exchanges) != 0 {
+		t.Errorf("Expected capture to be stopped and cleared, got %+v", status)
+	}
+}
+
+// With auth on, middleware outside the authenticator never sees the mux's
+// path values, so the live stats and capture filters read the slug from reqctx
+func TestSlugMiddleware_WithAuth(t *testing.T) {
+	h := setupTestHandler(t)
+	keys, _ := auth.NewAPIKeys(map[string]string{"k1": "ci-bot"})
+	h.Auth = keys
+	h.AdminToken = "secret"
+	router := h.Routes()
+
+	req := httptest.NewRequest("POST", "/api/admin/capture", strings.NewReader(`{"slug": "popular", "duration_seconds": 60}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	call := func(method, path, body string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer k1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: status %d: %s", method, path, w.Code, w.Body.String())
+		}
+	}
+	call("POST", "/api/prompts", `{"slug": "popular", "title": "Popular", "content": "x"}`)
+	call("GET", "/api/prompts/popular", "")
+	call("GET", "/api/prompts/popular", "")
+
+	req = httptest.NewRequest("GET", "/api/stats/live", nil)
+	req.Header.Set("Authorization", "Bearer k1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var stats models.LiveStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(stats.TopPrompts) != 1 || stats.TopPrompts[0] != (models.PromptHits{Project: store.DefaultProject, Slug: "popular", Requests: 2}) {
+		t.Errorf("Expected popular in top prompts with auth on, got %+v", stats.TopPrompts)
+	}
+
+	req = httptest.NewRequest("GET", "/api/admin/capture", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var status models.CaptureStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(status.Exchanges) != 2 || status.Exchanges[0].Path != "/api/prompts/popular" {
+		t.Errorf("Expected the 2 reads of popular to be captured with auth on, got %+v", status.Exchanges)
+	}
+}
+
+func TestRequestCapture_RingBufferAndExpiry(t *testing.T) {
+	c := newRequestCapture()
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	c.start("greeting", "", time.Minute)
+
+	for i := 0; i < captureBufferSize+5; i++ {
+		c.add(models.CapturedExchange{Status: i})
+	}
+	status := c.status()
+	if len(status.Exchanges) != captureBufferSize || status.Exchanges[0].Status != 5 || status.Exchanges[captureBufferSize-1].Status != captureBufferSize+4 {
+		t.Errorf("Expected the newest %d exchanges oldest first, got %d starting at %d",
+			captureBufferSize, len(status.Exchanges), status.Exchanges[0].Status)
+	}
+
+	now = now.Add(time.Minute)
+	if _, _, ok := c.filter(); ok {
+		t.Error("Expected capture to stop recording once expired")
+	}
+	if status := c.status(); status.Active || len(status.Exchanges) != captureBufferSize {
+		t.Errorf("Expected expired session to keep its exchanges, got active=%v with %d", status.Active, len(status.Exchanges))
+	}
+}
+
+func TestExecuteHandler_Stream(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream   bool `json:"stream"`
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Errorf("Expected a streaming provider request")
+		}
+		switch req.Messages[0].Content {
+		case "refuse":
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "quota exceeded"}}`))
+			return
+		case "break":
+			w.Write([]byte("data: {\"choices\": [{\"delta\": {\"content\": \"Par\"}}]}\n\ndata: {not json\n\n"))
+			return
+		}
+		w.Write([]byte("data: {\"model\": \"test-model\", \"choices\": [{\"delta\": {\"content\": \"Bon\"}}]}\n\n" +
+			"data: {\"model\": \"test-model\", \"choices\": [{\"delta\": {\"content\": \"jour\"}, \"finish_reason\": \"stop\"}]}\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer provider.Close()
+
+	h := setupTestHandler(t)
+	h.Providers = providers.NewRegistry()
+	h.Providers.Register(providers.NewOpenAICompatible("openai", provider.URL, "sk-test", "test-model"))
+	router := h.Routes()
+
+	for slug, content := range map[string]string{"greet": "Say hi", "refusing": "refuse", "breaking": "break"} {
+		if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: content}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	execute := func(slug string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/prompts/"+slug+"/execute", strings.NewReader(`{"stream": true}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := execute("greet")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	if !w.Flushed {
+		t.Error("Expected the stream to be flushed")
+	}
+	events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	if len(events) != 3 {
+		t.Fatalf("Expected 2 deltas and done, got %q", w.Body.String())
+	}
+	if events[0] != "event: delta\ndata: {\"text\":\"Bon\"}" || events[1] != "event: delta\ndata: {\"text\":\"jour\"}" {
+		t.Errorf("Unexpected delta events: %q", events[:2])
+	}
+	done, found := strings.CutPrefix(events[2], "event: done\ndata: ")
+	if !found {
+		t.Fatalf("Expected a done event, got %q", events[2])
+	}
+	var result models.ExecuteResult
+	if err := json.Unmarshal([]byte(done), &result); err != nil {
+		t.Fatalf("Failed to decode done event: %v", err)
+	}
+	if result.Completion != "Bonjour" || result.FinishReason != "stop" || result.Provider != "openai" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	w = execute("refusing")
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "quota exceeded") {
+		t.Errorf("Expected 502 JSON before the stream starts, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = execute("breaking")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "event: error\ndata: {\"code\":\"UPSTREAM_FAILED\",\"message\":\"Model provider stream interrupted\",\"request_id\":\""+w.Header().Get("X-Request-ID")+"\"}") {
+		t.Errorf("Expected an error event after the stream started, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPromptWebhooks(t *testing.T) {
+	type delivery struct {
+		receiver  string
+		event     models.WebhookEvent
+		signature string
+	}
+	var mu sync.Mutex
+	var deliveries []delivery
+	receiver := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var event models.WebhookEvent
+			json.NewDecoder(r.Body).Decode(&event)
+			mu.Lock()
+			deliveries = append(deliveries, delivery{name, event, r.Header.Get("X-Webhook-Signature")})
+			mu.Unlock()
+		}))
+	}
+	global, team := receiver("global"), receiver("team")
+	defer global.Close()
+	defer team.Close()
+
+	h := setupTestHandler(t)
+	h.Webhooks.GlobalURLs = []string{global.URL}
+	h.Webhooks.Secret = "shh"
+	h.Webhooks.allowPrivate = true // the receivers listen on loopback
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	received := func() []string {
+		h.Webhooks.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		var got []string
+		for _, d := range deliveries {
+			got = append(got, fmt.Sprintf("%s:%s:%s:%d", d.receiver, d.event.Event, d.event.Slug, d.event.Version))
+		}
+		sort.Strings(got)
+		deliveries = nil
+		return got
+	}
+
+	do("POST", "/api/prompts", `{"slug": "owned", "title": "Owned", "content": "v1"}`)
+	do("POST", "/api/prompts", `{"slug": "other", "title": "Other", "content": "v1"}`)
+	if got := received(); strings.Join(got, ",") != "global:prompt.created:other:1,global:prompt.created:owned:1" {
+		t.Errorf("Unexpected deliveries after create: %v", got)
+	}
+
+	w := do("POST", "/api/prompts/owned/webhooks", `{"url": "`+team.URL+`"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var hook models.Webhook
+	json.NewDecoder(w.Body).Decode(&hook)
+
+	do("POST", "/api/prompts/owned/versions", `{"content": "v2"}`)
+	do("POST", "/api/prompts/other/versions", `{"content": "v2"}`)
+	want := "global:prompt.version_created:other:2,global:prompt.version_created:owned:2,team:prompt.version_created:owned:2"
+	if got := received(); strings.Join(got, ",") != want {
+		t.Errorf("Expected %s, got %v", want, got)
+	}
+
+	do("PUT", "/api/prompts/owned/visibility", `{"public": true}`)
+	h.Webhooks.Wait()
+	mu.Lock()
+	for _, d := range deliveries {
+		body, _ := json.Marshal(d.event)
+		mac := hmac.New(sha256.New, []byte("shh"))
+		mac.Write(body)
+		if d.signature != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("Unexpected signature %q for %s", d.signature, d.receiver)
+		}
+	}
+	mu.Unlock()
+	if got := received(); len(got) != 2 || got[1] != "team:prompt.updated:owned:0" {
+		t.Errorf("Expected global and team updates, got %v", got)
+	}
+
+	if w := do("POST", "/api/prompts/owned/webhooks", `{"url": "`+team.URL+`"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for duplicate webhook, got %d", w.Code)
+	}
+	if w := do("POST", "/api/prompts/owned/webhooks", `{"url": "ftp://example.com"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid URL, got %d", w.Code)
+	}
+	h.Webhooks.allowPrivate = false
+	for _, target := range []string{"http://169.254.169.254/latest/meta-data", "http://localhost:8080/hook", "https://10.0.0.7/hook", "http://[::ffff:127.0.0.1]/hook"} {
+		if w := do("POST", "/api/prompts/owned/webhooks", `{"url": "`+target+`"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for private URL %s, got %d", target, w.Code)
+		}
+	}
+	h.Webhooks.allowPrivate = true
+	if w := do("GET", "/api/prompts/owned/webhooks", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), team.URL) {
+		t.Errorf("Expected webhook in list, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("DELETE", fmt.Sprintf("/api/prompts/other/webhooks/%d", hook.ID), ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting through another prompt, got %d", w.Code)
+	}
+	if w := do("DELETE", fmt.Sprintf("/api/prompts/owned/webhooks/%d", hook.ID), ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+
+	do("POST", "/api/prompts/owned/versions", `{"content": "v3"}`)
+	if got := received(); strings.Join(got, ",") != "global:prompt.version_created:owned:3" {
+		t.Errorf("Expected only the global delivery after removal, got %v", got)
+	}
+}
+
+func TestWebhooks_VersionChanges(t *testing.T) {
+	events := make(chan models.WebhookEvent, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event models.WebhookEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer receiver.Close()
+
+	h := setupTestHandler(t)
+	h.Webhooks.GlobalURLs = []string{receiver.URL}
+	router := h.Routes()
+
+	do := func(method, path, body string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: status %d: %s", method, path, w.Code, w.Body.String())
+		}
+		h.Webhooks.Wait()
+	}
+
+	do("POST", "/api/prompts", `{"slug": "greet", "title": "Greet", "content": "Hello {{name}}.\nBe {{style}}."}`)
+	if event := <-events; event.Changes != nil || event.Text != "prompt.created default/greet v1" {
+		t.Errorf("Expected no changes and a plain text line on create, got %+v", event)
+	}
+
+	do("POST", "/api/prompts/greet/versions", `{"content": "Hello {{name}}.\nUse a {{tone}} tone.\nSign off."}`)
+	event := <-events
+	if event.Changes == nil {
+		t.Fatal("Expected changes on prompt.version_created")
+	}
+	changes := *event.Changes
+	if changes.FromVersion != 1 || changes.LinesAdded != 2 || changes.LinesRemoved != 1 {
+		t.Errorf("Unexpected line counts: %+v", changes)
+	}
+	if !slices.Equal(changes.VariablesAdded, []string{"tone"}) || !slices.Equal(changes.VariablesRemoved, []string{"style"}) {
+		t.Errorf("Unexpected variable changes: %+v", changes)
+	}
+	if changes.TokenDelta != changes.TokensAfter-changes.TokensBefore || changes.TokenDelta <= 0 {
+		t.Errorf("Unexpected token counts: %+v", changes)
+	}
+	want := fmt.Sprintf("+2 -1 lines, variables +tone -style, %+d tokens", changes.TokenDelta)
+	if changes.Summary != want || event.Text != "prompt.version_created default/greet v2: "+want {
+		t.Errorf("Expected summary %q, got %q and text %q", want, changes.Summary, event.Text)
+	}
+
+	do("POST", "/api/prompts/greet/versions/batch", `{"versions": [{"content": "a"}, {"content": "Hello {{name}}.\nUse a {{tone}} tone.\nSign off.\nThanks."}]}`)
+	if event := <-events; event.Version != 4 || event.Changes == nil || event.Changes.FromVersion != 2 || event.Changes.LinesAdded != 1 || event.Changes.LinesRemoved != 0 {
+		t.Errorf("Expected import changes against the version before the batch, got %+v", event)
+	}
+}
+
+// Versions left pending review are skipped: changes are summarized against
+// the version that was current before the new one
+func TestWebhooks_VersionChangesSkipPendingVersions(t *testing.T) {
+	events := make(chan models.WebhookEvent, 16)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event models.WebhookEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer receiver.Close()
+
+	h := setupTestHandler(t)
+	h.AdminToken = "secret"
+	h.Webhooks.GlobalURLs = []string{receiver.URL}
+	keys, err := auth.NewAPIKeys(map[string]string{"key-a": "alice", "key-b": "bob"})
+	if err != nil {
+		t.Fatalf("NewAPIKeys failed: %v", err)
+	}
+	h.Auth = keys
+	router := h.Routes()
+
+	do := func(method, path, key, body string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: status %d: %s", method, path, w.Code, w.Body.String())
+		}
+		h.Webhooks.Wait()
+	}
+	versionCreated := func() models.WebhookEvent {
+		t.Helper()
+		for {
+			select {
+			case event := <-events:
+				if event.Event == WebhookVersionCreated {
+					return event
+				}
+			default:
+				t.Fatal("Expected a prompt.version_created webhook")
+			}
+		}
+	}
+
+	do("POST", "/api/prompts", "key-a", `{"slug": "refunds", "title": "Refunds", "content": "v1"}`)
+	do("PUT", "/api/admin/prompts/refunds/review-policy", "secret", `{"required_approvals": 1, "reviewers": ["bob"]}`)
+	do("POST", "/api/prompts/refunds/versions", "key-a", `{"content": "v2"}`)
+	do("PUT", "/api/admin/prompts/refunds/review-policy", "secret", `{"required_approvals": 0}`)
+
+	// Version 2 is left pending, so version 3 replaces version 1
+	do("POST", "/api/prompts/refunds/versions", "key-a", `{"content": "v3"}`)
+	if event := versionCreated(); event.Version != 3 || event.Changes == nil || event.Changes.FromVersion != 1 {
+		t.Errorf("Expected version 3 changes from version 1, got %+v", event)
+	}
+
+	do("PUT", "/api/admin/prompts/refunds/review-policy", "secret", `{"required_approvals": 1, "reviewers": ["bob"]}`)
+	do("POST", "/api/prompts/refunds/versions", "key-a", `{"content": "v4"}`)
+	do("POST", "/api/prompts/refunds/versions", "key-a", `{"content": "v5"}`)
+	do("POST", "/api/prompts/refunds/versions/5/approvals", "key-b", `{}`)
+	if event := versionCreated(); event.Version != 5 || event.Changes == nil || event.Changes.FromVersion != 3 {
+		t.Errorf("Expected version 5 changes from version 3, got %+v", event)
+	}
+}
+
+func TestWebhooks_RetriesServerErrors(t *testing.T) {
+	var attempts atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer receiver.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	in := NewIntegrations(logger)
+	in.retryDelay = time.Millisecond
+	wh := NewWebhooks(in, logger)
+	wh.allowPrivate = true
+	wh.Send(models.WebhookEvent{Event: WebhookPromptCreated, Slug: "x"}, []string{receiver.URL, receiver.URL})
+	wh.Wait()
+
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("Expected 3 attempts for a single deduplicated URL, got %d", n)
+	}
+	status := in.Status()
+	if len(status) != 1 || status[0].Delivered != 1 || status[0].Retries != 2 || status[0].Status != "ok" {
+		t.Errorf("Unexpected integration status: %+v", status)
+	}
+}
+
+func TestWebhooks_PromptURLsStayPublic(t *testing.T) {
+	var attempts atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+	}))
+	defer receiver.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	in := NewIntegrations(logger)
+	in.retryDelay = time.Millisecond
+	wh := NewWebhooks(in, logger)
+
+	// A per-prompt URL that now points at a private address isn't dialed or
+	// retried, while the same address configured globally is delivered
+	wh.Send(models.WebhookEvent{Event: WebhookPromptCreated, Slug: "x"}, []string{receiver.URL})
+	wh.Wait()
+	if n := attempts.Load(); n != 0 {
+		t.Errorf("Expected no attempts at a private per-prompt URL, got %d", n)
+	}
+	if status := in.Status(); len(status) != 1 || status[0].Failed != 1 || status[0].Retries != 0 {
+		t.Errorf("Expected one failure without retries, got %+v", status)
+	}
+
+	wh.Configure([]string{receiver.URL}, "")
+	wh.Send(models.WebhookEvent{Event: WebhookPromptCreated, Slug: "x"}, []string{receiver.URL})
+	wh.Wait()
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("Expected one delivery to the global URL, got %d", n)
+	}
+}
+
+func TestIntegrations_SoftFail(t *testing.T) {
+	h := setupTestHandler(t)
+	h.AdminToken = "secret"
+	h.Integrations.retryDelay = time.Millisecond
+	router := h.Routes()
+
+	// A webhook receiver that is down must not affect the write that triggered it
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	h.Webhooks.GlobalURLs = []string{down.URL}
+
+	req := httptest.NewRequest("POST", "/api/prompts", strings.NewReader(`{"slug": "soft", "title": "Soft", "content": "x"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 despite failing webhook, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var attempts atomic.Int32
+	h.Integrations.Go("analytics", "test", func() error {
+		attempts.Add(1)
+		return Permanent(fmt.Errorf("bad request"))
+	})
+	h.Integrations.Go("analytics", "test", func() error {
+		panic("boom")
+	})
+	h.Integrations.Wait()
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("Expected a permanent failure not to be retried, got %d attempts", n)
+	}
+
+	req = httptest.NewRequest("GET", "/api/admin/integration-status", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var statuses []models.IntegrationStatus
+	if err := json.NewDecoder(w.Body).Decode(&statuses); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].Name != "analytics" || statuses[1].Name != IntegrationWebhooks {
+		t.Fatalf("Unexpected statuses: %+v", statuses)
+	}
+	if s := statuses[0]; s.Failed != 2 || s.Retries != 0 || s.Status != "failing" || s.LastError == "" {
+		t.Errorf("Unexpected analytics status: %+v", s)
+	}
+	if s := statuses[1]; s.Failed != 1 || s.Retries != 2 || s.Status != "failing" || s.LastFailureAt == nil {
+		t.Errorf("Unexpected webhooks status: %+v", s)
+	}
+
+	req = httptest.NewRequest("GET", "/metrics", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `integration_deliveries_total{integration="webhooks",outcome="failure"} 1`) {
+		t.Errorf("Expected integration metrics, got:\n%s", w.Body.String())
+	}
+}
+
+func TestEvalHandlers(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		answer := "Paris"
+		if strings.Contains(req.Messages[0].Content, "Japan") {
+			answer = "Kyoto"
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"model":   "test-model",
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": answer}}},
+		})
+	}))
+	defer provider.Close()
+
+	h := setupTestHandler(t)
+	h.Providers = providers.NewRegistry()
+	h.Providers.Register(providers.NewOpenAICompatible("openai", provider.URL, "sk-test", "test-model"))
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "capital", Title: "Capital", Content: "Capital of {{country}}?"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	body := `{"name": "capitals", "items": [
+		{"input": {"country": "France"}, "expected": "Paris"},
+		{"input": {"country": "Japan"}, "expected": "Tokyo"},
+		{"input": {}, "expected": "Rome"}
+	]}`
+	req := httptest.NewRequest("POST", "/api/datasets", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/datasets", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for duplicate dataset, got %d", w.Code)
+	}
+
+	for _, tt := range []struct {
+		name, body string
+		want       int
+	}{
+		{"missing dataset", `{}`, http.StatusBadRequest},
+		{"unknown scorer", `{"dataset": "capitals", "scorer": "fuzzy"}`, http.StatusBadRequest},
+		{"concurrency too high", `{"dataset": "capitals", "concurrency": 100}`, http.StatusBadRequest},
+		{"unknown dataset", `{"dataset": "missing"}`, http.StatusNotFound},
+		{"unknown version", `{"dataset": "capitals", "version": 9}`, http.StatusNotFound},
+	} {
+		req = httptest.NewRequest("POST", "/api/prompts/capital/evals", strings.NewReader(tt.body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	req = httptest.NewRequest("POST", "/api/prompts/capital/evals", strings.NewReader(`{"dataset": "capitals"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var run models.EvalRun
+	if err := json.NewDecoder(w.Body).Decode(&run); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if run.Status != "running" || run.Total != 3 || run.Provider != "openai" || run.Scorer != "exact" {
+		t.Errorf("Unexpected run: %+v", run)
+	}
+
+	h.evals.Wait()
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("/api/evals/%d", run.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := json.NewDecoder(w.Body).Decode(&run); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if run.Status != "completed" || run.Completed != 3 || run.Passed != 1 || len(run.Results) != 3 {
+		t.Errorf("Unexpected finished run: %+v", run)
+	}
+	if run.Results[2].Error == "" || run.Results[2].Passed {
+		t.Errorf("Expected missing variable to fail the item, got %+v", run.Results[2])
+	}
+
+	req = httptest.NewRequest("GET", "/api/prompts/capital/evals", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var runs []models.EvalRun
+	json.NewDecoder(w.Body).Decode(&runs)
+	if w.Code != http.StatusOK || len(runs) != 1 || runs[0].Results != nil {
+		t.Errorf("Unexpected runs: %d %+v", w.Code, runs)
+	}
+
+	req = httptest.NewRequest("GET", "/api/evals/999", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	req := httptest.NewRequest("GET", "/api/export", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var empty models.Export
+	if err := json.NewDecoder(w.Body).Decode(&empty); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected empty export, got %d: %v", w.Code, err)
+	}
+	if empty.FormatVersion != models.ExportFormatVersion || len(empty.Prompts) != 0 || empty.ExportedAt.IsZero() {
+		t.Errorf("Unexpected empty export: %+v", empty)
+	}
+
+	for _, slug := range []string{"alpha", "beta"} {
+		if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: slug + " v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	if _, err := h.Store.CreatePromptVersion("alpha", models.CreatePromptVersionInput{Content: "alpha v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	req = httptest.NewRequest("GET", "/api/export", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Disposition"), ".json") {
+		t.Fatalf("Expected JSON attachment, got %d %v", w.Code, w.Header())
+	}
+	var export models.Export
+	if err := json.NewDecoder(w.Body).Decode(&export); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	if len(export.Prompts) != 2 || export.Prompts[0].Slug != "alpha" || len(export.Prompts[0].Versions) != 2 {
+		t.Errorf("Unexpected export: %+v", export)
+	}
+
+	req = httptest.NewRequest("GET", "/api/export?gzip=true", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("Expected gzip export, got %d %v", w.Code, w.Header())
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to decompress export: %v", err)
+	}
+	var compressed models.Export
+	if err := json.NewDecoder(gz).Decode(&compressed); err != nil {
+		t.Fatalf("Failed to decode compressed export: %v", err)
+	}
+	if !reflect.DeepEqual(compressed.Prompts, export.Prompts) {
+		t.Errorf("Compressed export differs: %+v", compressed.Prompts)
+	}
+
+	req = httptest.NewRequest("GET", "/api/export?gzip=maybe", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid gzip flag, got %d", w.Code)
+	}
+}
+
+func TestImportHandler(t *testing.T) {
+	source := setupTestHandler(t)
+	if _, err := source.Store.CreatePrompt(models.CreatePromptInput{Slug: "alpha", Title: "Alpha", Content: "alpha v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := source.Store.CreatePromptVersion("alpha", models.CreatePromptVersionInput{Content: "alpha v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/api/export?gzip=true", nil)
+	w := httptest.NewRecorder()
+	source.Routes().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected export, got %d", w.Code)
+	}
+	exported := w.Body.Bytes()
+
+	h := setupTestHandler(t)
+	router := h.Routes()
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "local", Title: "Local", Content: "mine"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/api/import", bytes.NewReader(exported))
+	req.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result models.ImportResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !reflect.DeepEqual(result.Imported, []string{"alpha"}) || len(result.Skipped) != 0 || result.Versions != 2 {
+		t.Errorf("Unexpected import result: %+v", result)
+	}
+
+	// Creation times from the source registry survive the round trip
+	original, _ := source.Store.GetPromptBySlug("alpha")
+	imported, err := h.Store.GetPromptBySlug("alpha")
+	if err != nil {
+		t.Fatalf("GetPromptBySlug failed: %v", err)
+	}
+	if imported.OriginalCreatedAt == nil || !imported.OriginalCreatedAt.Equal(original.CreatedAt) {
+		t.Errorf("Expected original_created_at %v, got %v", original.CreatedAt, imported.OriginalCreatedAt)
+	}
+	if imported.CurrentVersion.VersionNumber != 2 || imported.CurrentVersion.OriginalCreatedAt == nil ||
+		!imported.CurrentVersion.OriginalCreatedAt.Equal(original.CurrentVersion.CreatedAt) {
+		t.Errorf("Unexpected imported version: %+v", imported.CurrentVersion)
+	}
+	if got := testutil.ToFloat64(h.Metrics.promptVersionsCreated); testutil.ToFloat64(h.Metrics.promptsCreated) != 1 || got != 2 {
+		t.Errorf("Expected 1 prompt and 2 versions counted, got %v and %v", testutil.ToFloat64(h.Metrics.promptsCreated), got)
+	}
+
+	req = httptest.NewRequest("GET", "/api/prompts", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var summaries []models.PromptSummary
+	if err := json.NewDecoder(w.Body).Decode(&summaries); err != nil {
+		t.Fatalf("Failed to decode list: %v", err)
+	}
+	listed := false
+	for _, summary := range summaries {
+		if summary.Slug == "alpha" {
+			listed = summary.OriginalCreatedAt != nil
+		}
+	}
+	if len(summaries) != 2 || !listed {
+		t.Errorf("Expected imported prompt with its original time, got %+v", summaries)
+	}
+
+	// Importing the same export again skips what already exists
+	gz, _ := gzip.NewReader(bytes.NewReader(exported))
+	plain, _ := io.ReadAll(gz)
+	req = httptest.NewRequest("POST", "/api/import", bytes.NewReader(plain))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"skipped":["alpha"]`) {
+		t.Errorf("Expected alpha to be skipped, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, tt := range []struct {
+		name, body string
+	}{
+		{"unknown format", `{"format_version": 99, "prompts": []}`},
+		{"invalid JSON", `{"prompts": `},
+		{"empty versions", `{"format_version": 1, "prompts": [{"slug": "x", "title": "X", "versions": []}]}`},
+	} {
+		req = httptest.NewRequest("POST", "/api/import", strings.NewReader(tt.body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", tt.name, w.Code)
+		}
+	}
+}
+
+func TestImportVersionsHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "migrated", Title: "Migrated", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	body := `{"versions": [
+		{"content": "v2", "created_at": "2023-03-14T09:30:00Z"},
+		{"content": "v3", "created_at": "2023-06-01T12:00:00Z"}
+	]}`
+	req := httptest.NewRequest("POST", "/api/prompts/migrated/versions/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var versions []models.PromptVersion
+	if err := json.NewDecoder(w.Body).Decode(&versions); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(versions) != 2 || versions[1].VersionNumber != 3 || versions[1].OriginalCreatedAt == nil ||
+		versions[1].OriginalCreatedAt.Format(time.RFC3339) != "2023-06-01T12:00:00Z" {
+		t.Errorf("Unexpected imported versions: %+v", versions)
+	}
+
+	req = httptest.NewRequest("GET", "/api/prompts/migrated/versions/2", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"original_created_at":"2023-03-14T09:30:00Z"`) {
+		t.Errorf("Expected original_created_at on version 2, got %s", w.Body.String())
+	}
+
+	for _, tt := range []struct {
+		name, slug, body string
+		want             int
+	}{
+		{"empty batch", "migrated", `{"versions": []}`, http.StatusBadRequest},
+		{"empty content", "migrated", `{"versions": [{"content": "ok"}, {"content": ""}]}`, http.StatusBadRequest},
+		{"invalid JSON", "migrated", `{"versions": `, http.StatusBadRequest},
+		{"missing prompt", "missing", `{"versions": [{"content": "x"}]}`, http.StatusNotFound},
+	} {
+		req = httptest.NewRequest("POST", "/api/prompts/"+tt.slug+"/versions/batch", strings.NewReader(tt.body))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+
+	prompt, _ := h.Store.GetPromptBySlug("migrated")
+	if prompt.CurrentVersion.VersionNumber != 3 {
+		t.Errorf("Expected failed imports to add nothing, current version is %d", prompt.CurrentVersion.VersionNumber)
+	}
+}
+
+func TestSyncHandler(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	h := setupTestHandler(t)
+	req := httptest.NewRequest("POST", "/api/sync", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	h.Routes().ServeHTTP(w, req)
+	if w.Code == http.StatusOK {
+		t.Error("Expected /api/sync to be unmounted without Git sync configured")
+	}
+
+	dir := filepath.Join(t.TempDir(), "prompts")
+	h.Sync = gitsync.New(gitsync.Config{Dir: dir}, h.Store, h.Logger)
+	router := h.Routes()
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "synced", Title: "Synced", Content: "from registry"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	req = httptest.NewRequest("POST", "/api/sync", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result models.SyncResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Direction != gitsync.DirectionBoth || len(result.Committed) != 1 || result.Pushed {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "synced.txt")); err != nil || string(data) != "from registry" {
+		t.Errorf("Expected prompt file in the sync repository, got %q, %v", data, err)
+	}
+
+	req = httptest.NewRequest("POST", "/api/sync", strings.NewReader(`{"direction": "sideways"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown direction, got %d", w.Code)
+	}
+}
+
+func TestReleaseHandlers(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	for _, slug := range []string{"planner", "critic"} {
+		if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: slug + " v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+		if _, err := h.Store.CreatePromptVersion(slug, models.CreatePromptVersionInput{Content: slug + " v2"}); err != nil {
+			t.Fatalf("CreatePromptVersion failed: %v", err)
+		}
+	}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	renderLabel := func(slug string) string {
+		w := do("POST", "/api/prompts/"+slug+"/render", `{"label": "production"}`)
+		if w.Code != http.StatusOK {
+			return fmt.Sprintf("status %d", w.Code)
+		}
+		var result models.RenderResult
+		json.NewDecoder(w.Body).Decode(&result)
+		return result.Content
+	}
+
+	w := do("POST", "/api/releases", `{"label": "production", "items": [{"slug": "planner", "version": 1}, {"slug": "critic", "version": 1}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	w = do("POST", "/api/releases", `{"label": "production", "items": [{"slug": "planner", "version": 2}, {"slug": "critic", "version": 2}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var release models.Release
+	if err := json.NewDecoder(w.Body).Decode(&release); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if got := renderLabel("planner") + ", " + renderLabel("critic"); got != "planner v2, critic v2" {
+		t.Errorf("Expected both prompts at v2, got %q", got)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/api/releases", `{"label": "production", "items": [{"slug": "planner", "version": 1}, {"slug": "critic", "version": 7}]}`, http.StatusNotFound},
+		{"POST", "/api/releases", `{"label": "production", "items": []}`, http.StatusBadRequest},
+		{"POST", "/api/releases/1/rollback", "", http.StatusConflict},
+		{"GET", "/api/releases/99", "", http.StatusNotFound},
+		{"POST", "/api/prompts/planner/render", `{"label": "production", "version": 1}`, http.StatusBadRequest},
+		{"POST", "/api/prompts/planner/render", `{"label": "staging"}`, http.StatusNotFound},
+	} {
+		if w := do(tc.method, tc.path, tc.body); w.Code != tc.want {
+			t.Errorf("%s %s %s: expected status %d, got %d: %s", tc.method, tc.path, tc.body, tc.want, w.Code, w.Body.String())
+		}
+	}
+	// The failed release left the labels alone
+	if got := renderLabel("planner"); got != "planner v2" {
+		t.Errorf("Expected planner still at v2, got %q", got)
+	}
+
+	w = do("POST", fmt.Sprintf("/api/releases/%d/rollback", release.ID), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := renderLabel("planner") + ", " + renderLabel("critic"); got != "planner v1, critic v1" {
+		t.Errorf("Expected both prompts back at v1, got %q", got)
+	}
+
+	w = do("GET", "/api/prompts/critic/labels", "")
+	var labels []models.PromptLabel
+	if err := json.NewDecoder(w.Body).Decode(&labels); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(labels) != 1 || labels[0].Label != "production" || labels[0].Version != 1 {
+		t.Errorf("Unexpected labels: %+v", labels)
+	}
+
+	w = do("GET", "/api/releases?label=production", "")
+	var releases []models.Release
+	if err := json.NewDecoder(w.Body).Decode(&releases); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(releases) != 2 || releases[0].RolledBackAt == nil || releases[1].RolledBackAt != nil {
+		t.Errorf("Unexpected releases: %+v", releases)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	h := setupTestHandler(t)
+	keys, _ := auth.NewAPIKeys(map[string]string{"k1": "ci-bot"})
+	h.Auth = keys
+	h.AuthMethod = "apikey"
+	h.MaxContentBytes = 16
+	h.RateLimit = RateLimitConfig{RequestsPerSecond: 5, Burst: 10}
+	router := h.Routes()
+
+	// No credentials: capabilities are how clients learn to authenticate
+	req := httptest.NewRequest("GET", "/api/capabilities", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var caps models.Capabilities
+	if err := json.NewDecoder(w.Body).Decode(&caps); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	var spec struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("Spec is not valid JSON: %v", err)
+	}
+	if caps.APIVersion != spec.Info.Version {
+		t.Errorf("Expected api_version %q to match the spec, got %q", spec.Info.Version, caps.APIVersion)
+	}
+	if caps.Auth.Method != "apikey" || caps.Auth.Scheme != "bearer" || !slices.Contains(caps.Auth.Methods, "oidc") {
+		t.Errorf("Unexpected auth: %+v", caps.Auth)
+	}
+	if caps.Limits.MaxContentBytes != 16 || caps.Limits.MaxImportVersions != store.MaxImportVersions {
+		t.Errorf("Unexpected limits: %+v", caps.Limits)
+	}
+	if caps.Limits.RateLimit == nil || caps.Limits.RateLimit.RequestsPerSecond != 5 || caps.Limits.RateLimit.Burst != 10 {
+		t.Errorf("Unexpected rate limit: %+v", caps.Limits.RateLimit)
+	}
+	if caps.Features.Execution || caps.Features.GitSync || caps.Features.Providers == nil {
+		t.Errorf("Unexpected features: %+v", caps.Features)
+	}
+
+	// Content over the advertised limit is rejected before it reaches the store
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer k1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	if w := post("/api/prompts", `{"slug": "big", "title": "Big", "content": "this is over sixteen bytes"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := post("/api/prompts", `{"slug": "small", "title": "Small", "content": "short"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := post("/api/prompts/small/versions", `{"content": "this is over sixteen bytes"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d: %s", w.Code, w.Body.String())
+	}
+	w = post("/api/prompts/small/versions/batch", `{"versions": [{"content": "ok"}, {"content": "this is over sixteen bytes"}]}`)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "version 2") {
+		t.Errorf("Expected 413 naming version 2, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	h := setupTestHandler(t)
+	keys, _ := auth.NewAPIKeys(map[string]string{"k1": "ci-bot"})
+	h.Auth = keys
+	h.BasePath = "/prompt-registry/"
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer k1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, slug := range []string{"one", "two"} {
+		if w := do("POST", "/prompt-registry/api/prompts", `{"slug": "`+slug+`", "title": "T", "content": "c"}`); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if w := do("GET", "/api/prompts", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected routes outside the base path to 404, got %d", w.Code)
+	}
+	if w := do("GET", "/prompt-registry-other/api/prompts", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a sibling path to 404, got %d", w.Code)
+	}
+
+	w := do("GET", "/prompt-registry/api/prompts?limit=1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if link := w.Header().Get("Link"); !strings.HasPrefix(link, "</prompt-registry/api/prompts?") {
+		t.Errorf("Expected Link to keep the base path, got %q", link)
+	}
+
+	// Auth exemptions still apply to paths under the prefix
+	req := httptest.NewRequest("GET", "/prompt-registry/api/capabilities", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected capabilities to stay open, got %d", w.Code)
+	}
+
+	w = do("GET", "/prompt-registry?x=1", "")
+	if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != "/prompt-registry/?x=1" {
+		t.Errorf("Expected redirect to the trailing slash, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w = do("GET", "/prompt-registry/prompts/one", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `const BASE_PATH = "/prompt-registry";`) {
+		t.Errorf("Expected the frontend to use the base path, got %d", w.Code)
+	}
+	w = do("GET", "/prompt-registry/docs", "")
+	if !strings.Contains(w.Body.String(), "'/prompt-registry/openapi.json'") {
+		t.Errorf("Expected API docs to load the spec under the base path")
+	}
+}
+
+func TestReload(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	get := func(path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	if w := get("/api/prompts", "https://other.example.com"); w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("Expected any origin before reload, got %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	changes := h.Reload(ReloadConfig{
+		CORS:          CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowedHeaders: []string{"Content-Type"}},
+		RateLimit:     RateLimitConfig{RequestsPerSecond: 0.01, Burst: 1},
+		WebhookURLs:   []string{"https://hooks.example.com/registry?token=secret"},
+		WebhookSecret: "s3cret",
+	})
+	want := []string{
+		"cors.allowed_origins: [*] -> [https://app.example.com]",
+		"rate_limit: off -> 0.01/s burst 1",
+		"webhooks.urls: 1 URLs (+1 -0)",
+		"webhooks.secret: changed",
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("Expected changes %q, got %q", want, changes)
+	}
+	if urls, secret := h.Webhooks.settings(); len(urls) != 1 || secret != "s3cret" {
+		t.Errorf("Expected webhook settings to be applied, got %v %q", urls, secret)
+	}
+
+	if w := get("/api/prompts", "https://other.example.com"); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected other origins to be refused after reload, got %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if w := get("/api/prompts", "https://app.example.com"); w.Code != http.StatusTooManyRequests {
+		// The first request used the only token
+		t.Errorf("Expected the reloaded rate limit to apply, got %d", w.Code)
+	}
+
+	// Straight to the handler, as the API is now out of tokens
+	w := httptest.NewRecorder()
+	h.handleCapabilities(w, httptest.NewRequest("GET", "/api/capabilities", nil))
+	var caps models.Capabilities
+	json.NewDecoder(w.Body).Decode(&caps)
+	if caps.Limits.RateLimit == nil || caps.Limits.RateLimit.Burst != 1 {
+		t.Errorf("Expected capabilities to report the reloaded limit, got %+v", caps.Limits.RateLimit)
+	}
+
+	if changes := h.Reload(ReloadConfig{
+		CORS:          h.corsConfig(),
+		RateLimit:     h.rateLimitConfig(),
+		WebhookURLs:   []string{"https://hooks.example.com/registry?token=secret"},
+		WebhookSecret: "s3cret",
+	}); len(changes) != 0 {
+		t.Errorf("Expected no changes for the same settings, got %q", changes)
+	}
+}
+
+func TestRequestLimits(t *testing.T) {
+	h := setupTestHandler(t)
+	h.MaxBodyBytes = 1024
+	h.MaxTitleLength = 10
+	h.MaxDescriptionLength = 20
+	router := h.Routes()
+
+	send := func(req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	errorOf := func(w *httptest.ResponseRecorder) string {
+		t.Helper()
+		var resp ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode error: %v", err)
+		}
+		return resp.Message
+	}
+
+	// A declared length over the limit is refused before the body is read
+	big := `{"slug": "big", "title": "Big", "content": "` + strings.Repeat("x", 2000) + `"}`
+	w := send(httptest.NewRequest("POST", "/api/prompts", strings.NewReader(big)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status 413, got %d: %s", w.Code, w.Body.String())
+	}
+	if msg := errorOf(w); !strings.Contains(msg, "request body is 2046 bytes; the limit is 1024 bytes") {
+		t.Errorf("Unexpected error: %q", msg)
+	}
+
+	// A body without a length is cut off once it passes the limit
+	req := httptest.NewRequest("POST", "/api/prompts", strings.NewReader(big))
+	req.ContentLength = -1
+	w = send(req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status 413 for a streamed body, got %d: %s", w.Code, w.Body.String())
+	}
+	if msg := errorOf(w); !strings.Contains(msg, "over the limit of 1024 bytes") {
+		t.Errorf("Unexpected error: %q", msg)
+	}
+
+	// Malformed JSON under the limit is still a 400
+	w = send(httptest.NewRequest("POST", "/api/prompts", strings.NewReader(`{"slug":`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	// Titles and descriptions over their limits are 422, counted in characters
+	w = send(httptest.NewRequest("POST", "/api/prompts", strings.NewReader(`{"slug": "long", "title": "ééééééééééé", "content": "v1"}`)))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status 422, got %d: %s", w.Code, w.Body.String())
+	}
+	if msg := errorOf(w); msg != "title is 11 characters; the limit is 10" {
+		t.Errorf("Unexpected error: %q", msg)
+	}
+	w = send(httptest.NewRequest("POST", "/api/prompts", strings.NewReader(`{"slug": "fits", "title": "éééééééééé", "content": "v1"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 for a title at the limit, got %d: %s", w.Code, w.Body.String())
+	}
+	w = send(httptest.NewRequest("PUT", "/api/prompts/fits/description", strings.NewReader(`{"description": "`+strings.Repeat("d", 21)+`"}`)))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for a long description, got %d: %s", w.Code, w.Body.String())
+	}
+
+	export := models.Export{
+		FormatVersion: models.ExportFormatVersion,
+		Prompts: []models.ExportedPrompt{{
+			Slug:     "imported",
+			Title:    "A title that is too long",
+			Versions: []models.ExportedVersion{{VersionNumber: 1, Content: "v1"}},
+		}},
+	}
+	body, _ := json.Marshal(export)
+	w = send(httptest.NewRequest("POST", "/api/import", bytes.NewReader(body)))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for a long imported title, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The limit also applies to a gzip import once decompressed
+	export.Prompts[0].Title = "Imported"
+	export.Prompts[0].Versions[0].Content = strings.Repeat("x", 4000)
+	body, _ = json.Marshal(export)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(body)
+	gz.Close()
+	req = httptest.NewRequest("POST", "/api/import", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	w = send(req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for a large decompressed import, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = send(httptest.NewRequest("GET", "/api/capabilities", nil))
+	var caps models.Capabilities
+	if err := json.NewDecoder(w.Body).Decode(&caps); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if caps.Limits.MaxBodyBytes != 1024 || caps.Limits.MaxTitleLength != 10 || caps.Limits.MaxDescriptionLength != 20 {
+		t.Errorf("Unexpected limits: %+v", caps.Limits)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	n := 0
+	create := func(header http.Header, cookie *http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		n++
+		body := fmt.Sprintf(`{"slug": "csrf-%d", "title": "CSRF", "content": "v1"}`, n)
+		req := httptest.NewRequest("POST", "/api/prompts", strings.NewReader(body))
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	getToken := func(handler http.Handler, cookie *http.Cookie) (models.CSRFToken, *http.Cookie) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/csrf", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var token models.CSRFToken
+		if err := json.NewDecoder(w.Body).Decode(&token); err != nil {
+			t.Fatalf("Failed to decode token: %v", err)
+		}
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteStrictMode || cookies[0].Value != token.Token {
+			t.Fatalf("Expected an HttpOnly SameSite=Strict cookie holding the token, got %+v", cookies)
+		}
+		return token, cookies[0]
+	}
+
+	// Clients that aren't browsers, or that send explicit credentials, need no token
+	if w := create(nil, nil); w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 without browser headers, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := create(http.Header{"Origin": {"https://evil.example.com"}, "Authorization": {"Bearer k1"}}, nil); w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 with an Authorization header, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A cross-site form post carries Origin but can't read the token
+	w := create(http.Header{"Origin": {"https://evil.example.com"}}, nil)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "CSRF token") {
+		t.Fatalf("Expected status 403 for a browser write without a token, got %d: %s", w.Code, w.Body.String())
+	}
+
+	token, cookie := getToken(router, nil)
+	if token.Header != "X-CSRF-Token" {
+		t.Errorf("Expected the X-CSRF-Token header, got %q", token.Header)
+	}
+	browser := http.Header{"Sec-Fetch-Site": {"same-origin"}, "X-Csrf-Token": {token.Token}}
+	if w := create(browser, cookie); w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 with the token, got %d: %s", w.Code, w.Body.String())
+	}
+	if again, _ := getToken(router, cookie); again.Token != token.Token {
+		t.Error("Expected a valid cookie to keep its token")
+	}
+
+	// The header must match the cookie, and both must be signed by this server
+	other, _ := getToken(router, nil)
+	if w := create(http.Header{"X-Csrf-Token": {other.Token}}, cookie); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a token that doesn't match the cookie, got %d", w.Code)
+	}
+	forged := "AAAAAAAAAAAAAAAAAAAAAA.c2lnbmF0dXJl"
+	if w := create(http.Header{"X-Csrf-Token": {forged}}, &http.Cookie{Name: cookie.Name, Value: forged}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a forged token, got %d", w.Code)
+	}
+
+	// Replicas sharing a secret accept each other's tokens
+	h.CSRF.Secret = "shared"
+	replica := setupTestHandler(t)
+	replica.CSRF.Secret = "shared"
+	shared, sharedCookie := getToken(replica.Routes(), nil)
+	if w := create(http.Header{"X-Csrf-Token": {shared.Token}}, sharedCookie); w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 with a token from a replica, got %d: %s", w.Code, w.Body.String())
+	}
+
+	h.CSRF.Enabled = false
+	if w := create(http.Header{"Origin": {"https://evil.example.com"}}, nil); w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 with CSRF protection off, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCreatePromptHandler_InvalidSlug(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	body := `{"slug": "My Prompt", "title": "Test", "content": "Test Content"}`
+	req := httptest.NewRequest("POST", "/api/prompts", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "my-prompt") {
+		t.Errorf("Expected status 400 suggesting a slug, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestForkPromptHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	fork := func(slug, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/prompts/"+slug+"/fork", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := fork("summarize", `{"slug": "summarize-short", "title": "Short summary"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var result models.PromptWithCurrentVersion
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Slug != "summarize-short" || result.CurrentVersion.Content != "v1" || result.ForkedFrom == nil || result.ForkedFrom.Slug != "summarize" {
+		t.Errorf("Unexpected fork: %+v", result)
+	}
+
+	if w := fork("summarize", `{"slug": "summarize-short"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a taken slug, got %d", w.Code)
+	}
+	if w := fork("summarize", `{"slug": "Not Valid"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid slug, got %d", w.Code)
+	}
+	if w := fork("missing", `{"slug": "copy"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing prompt, got %d", w.Code)
+	}
+}
+
+func TestGetVersionHandler_Selectors(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := h.Store.CreatePromptVersion("summarize", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	for _, selector := range []string{"latest", "current"} {
+		w := get("/api/prompts/summarize/versions/" + selector)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d: %s", selector, w.Code, w.Body.String())
+		}
+		var version models.PromptVersion
+		if err := json.NewDecoder(w.Body).Decode(&version); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if version.VersionNumber != 2 || version.Content != "v2" || w.Header().Get("X-Prompt-Version") != "2" {
+			t.Errorf("Expected version 2 for %s, got %+v", selector, version)
+		}
+	}
+
+	if w := get("/api/prompts/summarize/versions/newest"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown selector, got %d", w.Code)
+	}
+	if w := get("/api/prompts/missing/versions/latest"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing prompt, got %d", w.Code)
+	}
+}
+
+func TestChatPromptHandlers(t *testing.T) {
+	var gotRoles []string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Role string `json:"role"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		gotRoles = nil
+		for _, m := range req.Messages {
+			gotRoles = append(gotRoles, m.Role)
+		}
+		w.Write([]byte(`{"model": "test-model", "choices": [{"message": {"role": "assistant", "content": "Hi"}, "finish_reason": "stop"}]}`))
+	}))
+	defer provider.Close()
+
+	h := setupTestHandler(t)
+	h.Providers = providers.NewRegistry()
+	h.Providers.Register(providers.NewOpenAICompatible("openai", provider.URL, "", "test-model"))
+	router := h.Routes()
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return w
+	}
+
+	w := post("/api/prompts", `{"slug": "support", "title": "Support", "format": "chat", "messages": [
+		{"role": "system", "content": "You help with {{product}}."},
+		{"role": "user", "content": "{{question}}"}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = post("/api/prompts/support/render", `{"variables": {"product": "billing", "question": "Where is my invoice?"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var rendered models.RenderResult
+	if err := json.NewDecoder(w.Body).Decode(&rendered); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(rendered.Messages) != 2 || rendered.Messages[0].Content != "You help with billing." || rendered.Messages[1].Content != "Where is my invoice?" {
+		t.Errorf("Unexpected rendered messages: %+v", rendered.Messages)
+	}
+	if !strings.Contains(rendered.Content, `"You help with billing."`) {
+		t.Errorf("Expected content to hold the rendered messages as JSON, got %q", rendered.Content)
+	}
+
+	w = post("/api/prompts/support/execute", `{"variables": {"product": "billing", "question": "Hi"}}`)
+	if w.Code != http.StatusOK || strings.Join(gotRoles, ",") != "system,user" {
+		t.Errorf("Expected the provider to get system and user messages, got %v (%d: %s)", gotRoles, w.Code, w.Body.String())
+	}
+
+	if w := post("/api/prompts/support/versions", `{"messages": [{"role": "narrator", "content": "Hi"}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown role, got %d", w.Code)
+	}
+	if w := post("/api/prompts", `{"title": "Odd", "format": "xml", "content": "Hi"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown format, got %d", w.Code)
+	}
+}
+
+func TestExecuteHandler_VersionModelConfig(t *testing.T) {
+	var got struct {
+		Model       string   `json:"model"`
+		MaxTokens   int      `json:"max_tokens"`
+		Temperature *float64 `json:"temperature"`
+		Stop        []string `json:"stop"`
+	}
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"model": "m", "choices": [{"message": {"role": "assistant", "content": "Hi"}, "finish_reason": "stop"}]}`))
+	}))
+	defer provider.Close()
+
+	h := setupTestHandler(t)
+	h.Providers = providers.NewRegistry()
+	h.Providers.Register(providers.NewOpenAICompatible("openai", provider.URL, "", "default-model"))
+	router := h.Routes()
+
+	temperature := 0.2
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "Summarize",
+		ModelConfig: &models.ModelConfig{Model: "gpt-4o", Temperature: &temperature, MaxTokens: 300, Stop: []string{"END"}}}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	execute := func(body string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/prompts/summarize/execute", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	execute(`{}`)
+	if got.Model != "gpt-4o" || got.MaxTokens != 300 || got.Temperature == nil || *got.Temperature != 0.2 || strings.Join(got.Stop, ",") != "END" {
+		t.Errorf("Expected the version's model config, got %+v", got)
+	}
+
+	execute(`{"model": "gpt-4o-mini", "max_tokens": 50, "temperature": 1}`)
+	if got.Model != "gpt-4o-mini" || got.MaxTokens != 50 || *got.Temperature != 1 {
+		t.Errorf("Expected the request to override the version's model config, got %+v", got)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/prompts/summarize/versions", strings.NewReader(`{"content": "v2", "model_config": {"temperature": 5}}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid model config, got %d", w.Code)
+	}
+}
+
+func TestPromptMetadataHandlers(t *testing.T) {
+	h := setupTestHandler(t)
+	h.MaxMetadataBytes = 64
+	router := h.Routes()
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return w
+	}
+
+	w := post("/api/prompts", `{"slug": "summarize", "title": "Summarize", "content": "v1", "metadata": {"team": "search"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var result models.PromptWithCurrentVersion
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Metadata["team"] != "search" {
+		t.Errorf("Expected metadata in the response, got %v", result.Metadata)
+	}
+
+	large := `{"notes": "` + strings.Repeat("x", 100) + `"}`
+	if w := post("/api/prompts/summarize/versions", `{"content": "v2", "metadata": `+large+`}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for large version metadata, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := post("/api/prompts", `{"title": "Other", "content": "v1", "metadata": `+large+`}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for large prompt metadata, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := post("/api/prompts/summarize/versions", `{"content": "v2", "metadata": ["not", "an", "object"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for metadata that isn't an object, got %d", w.Code)
+	}
+}
+
+func TestVersionCommentHandlers(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do("POST", "/api/prompts", `{"slug": "summarize", "title": "Summarize", "content": "v1"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := do("POST", "/api/prompts/summarize/versions/1/comments", `{"body": "Too terse"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var comment models.VersionComment
+	if err := json.NewDecoder(w.Body).Decode(&comment); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if comment.ID == 0 || comment.Body != "Too terse" || comment.VersionNumber != 1 {
+		t.Errorf("Unexpected comment: %+v", comment)
+	}
+
+	w = do("GET", "/api/prompts/summarize/versions/1/comments", "")
+	var comments []models.VersionComment
+	if err := json.NewDecoder(w.Body).Decode(&comments); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || len(comments) != 1 || comments[0].ID != comment.ID {
+		t.Errorf("Expected the comment back, got %d: %+v", w.Code, comments)
+	}
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/api/prompts/summarize/versions/1/comments", `{"body": ""}`, http.StatusBadRequest},
+		{"POST", "/api/prompts/summarize/versions/1/comments", `{"body": "` + strings.Repeat("x", store.MaxCommentLength+1) + `"}`, http.StatusUnprocessableEntity},
+		{"POST", "/api/prompts/summarize/versions/2/comments", `{"body": "hi"}`, http.StatusNotFound},
+		{"POST", "/api/prompts/summarize/versions/0/comments", `{"body": "hi"}`, http.StatusBadRequest},
+		{"GET", "/api/prompts/missing/versions/1/comments", "", http.StatusNotFound},
+		{"GET", "/api/prompts/summarize/versions/x/comments", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := do(tt.method, tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tt.method, tt.path, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestPromptStarHandlers(t *testing.T) {
+	h := setupTestHandler(t)
+	keys, err := auth.NewAPIKeys(map[string]string{"key-a": "alice", "key-b": "bob"})
+	if err != nil {
+		t.Fatalf("NewAPIKeys failed: %v", err)
+	}
+	h.Auth = keys
+	router := h.Routes()
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	starred := func(key string) []string {
+		t.Helper()
+		w := do("GET", "/api/prompts?starred=true", key, "")
+		var summaries []models.PromptSummary
+		if err := json.NewDecoder(w.Body).Decode(&summaries); err != nil {
+			t.Fatalf("Failed to decode prompts: %v", err)
+		}
+		var slugs []string
+		for _, s := range summaries {
+			slugs = append(slugs, s.Slug)
+		}
+		return slugs
+	}
+
+	for _, slug := range []string{"summarize", "translate"} {
+		if w := do("POST", "/api/prompts", "key-a", `{"slug": "`+slug+`", "title": "T", "content": "v1"}`); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	// Readers can star a prompt they can't change
+	if w := do("PUT", "/api/prompts/translate/acl", "key-a", `{"grants":[{"type":"user","name":"bob","access":"read"}]}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", "/api/prompts/translate/star", "key-b", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", "/api/prompts/summarize/star", "key-a", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if got := starred("key-a"); len(got) != 1 || got[0] != "summarize" {
+		t.Errorf("Expected alice to have starred summarize, got %v", got)
+	}
+	if got := starred("key-b"); len(got) != 1 || got[0] != "translate" {
+		t.Errorf("Expected bob to have starred translate, got %v", got)
+	}
+
+	if w := do("DELETE", "/api/prompts/translate/star", "key-b", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := starred("key-b"); len(got) != 0 {
+		t.Errorf("Expected no starred prompts after unstarring, got %v", got)
+	}
+	if w := do("PUT", "/api/prompts/missing/star", "key-a", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestUsageHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do("POST", "/api/prompts", `{"slug": "summarize", "title": "Summarize", "content": "v1"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/prompts/summarize/versions", `{"content": "v2"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	do("GET", "/api/prompts/summarize", "")
+	do("GET", "/api/prompts/summarize/versions/1", "")
+	do("POST", "/api/prompts/summarize/render", `{}`)
+	do("POST", "/api/prompts/summarize/render", `{"version": 1}`)
+	do("GET", "/api/prompts/summarize/versions/9", "")
+
+	w := do("GET", "/api/prompts/summarize/usage", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var usage models.PromptUsage
+	if err := json.NewDecoder(w.Body).Decode(&usage); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if usage.Fetches != 4 || len(usage.Versions) != 2 || usage.Versions[0].Fetches != 2 || usage.Versions[1].Fetches != 2 {
+		t.Errorf("Expected two fetches of each version, got %+v", usage)
+	}
+	if usage.LastFetchedAt == nil {
+		t.Error("Expected a last fetch time")
+	}
+
+	if w := do("GET", "/api/prompts/missing/usage", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestAnalyticsHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	for _, slug := range []string{"summarize", "translate"} {
+		if w := do("POST", "/api/prompts", `{"slug": "`+slug+`", "title": "T", "content": "v1"}`); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	do("GET", "/api/prompts/summarize", "")
+	do("GET", "/api/prompts/summarize", "")
+
+	w := do("GET", "/api/analytics", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result models.Analytics
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Prompts != 2 || result.Fetches != 2 || result.StaleDays != 30 {
+		t.Errorf("Unexpected totals: %+v", result)
+	}
+	if len(result.MostFetched) != 1 || result.MostFetched[0].Slug != "summarize" || result.MostFetched[0].Fetches != 2 {
+		t.Errorf("Expected summarize to be the most fetched, got %+v", result.MostFetched)
+	}
+	if len(result.Stale) != 0 {
+		t.Errorf("Expected new prompts not to be stale, got %+v", result.Stale)
+	}
+
+	for _, query := range []string{"stale_days=0", "stale_days=x", "limit=0", "limit=101"} {
+		if w := do("GET", "/api/analytics?"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	for _, slug := range []string{"summarize", "translate"} {
+		if w := do("POST", "/api/prompts", `{"slug": "`+slug+`", "title": "T", "content": "v1"}`); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	w := do("GET", "/api/stats?days=7&limit=1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result models.RegistryStats
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Prompts != 2 || result.Versions != 2 || result.Days != 7 || result.DatabaseBytes == 0 {
+		t.Errorf("Unexpected totals: %+v", result)
+	}
+	if len(result.Largest) != 1 || len(result.Created) != 7 {
+		t.Errorf("Expected 1 largest prompt and 7 days, got %+v", result)
+	}
+	if today := result.Created[6]; today.Prompts != 2 || today.Versions != 2 {
+		t.Errorf("Expected today's creations to be counted, got %+v", today)
+	}
+
+	for _, query := range []string{"days=0", "days=367", "days=x", "limit=0", "limit=101"} {
+		if w := do("GET", "/api/stats?"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestCreateVersionHandler_Deduplicate(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do("POST", "/api/prompts", `{"slug": "ci", "title": "CI", "content": "v1"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := do("POST", "/api/prompts/ci/versions", `{"content": "v1", "deduplicate": true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result models.PromptWithCurrentVersion
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !result.Unchanged || result.CurrentVersion.VersionNumber != 1 {
+		t.Errorf("Expected version 1 unchanged, got %+v", result)
+	}
+
+	if w := do("POST", "/api/prompts/ci/versions", `{"content": "v2", "deduplicate": true}`); w.Code != http.StatusCreated || strings.Contains(w.Body.String(), "unchanged") {
+		t.Errorf("Expected a new version, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCreateVersionHandler_IfMatch(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	do := func(body, ifMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/prompts/edit/versions", strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/prompts", strings.NewReader(`{"slug": "edit", "title": "Edit", "content": "v1"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/prompts/edit", nil))
+	etag := w.Header().Get("ETag")
+
+	// The ETag of version 1 is accepted once, then is stale
+	if w := do(`{"content": "v2"}`, etag); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(`{"content": "v3"}`, etag); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a stale ETag, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A version number works as well, quoted or not
+	if w := do(`{"content": "v3"}`, "1"); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a stale version, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(`{"content": "v3"}`, `"2"`); w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := do(`{"content": "v4", "base_version": 2}`, ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a stale base_version, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(`{"content": "v4", "base_version": 2}`, "3"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 when If-Match and base_version disagree, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(`{"content": "v4"}`, "*"); w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 for If-Match: *, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBatchGetPromptsHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	do := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/prompts:batchGet", strings.NewReader(body)))
+		return w
+	}
+
+	for _, slug := range []string{"alpha", "beta"} {
+		if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: slug}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+
+	w := do(`{"slugs": ["beta", "gone", "alpha", "beta"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result models.BatchGetPromptsResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(result.Prompts) != 2 || result.Prompts[0].Slug != "beta" || result.Prompts[1].Slug != "alpha" {
+		t.Errorf("Expected beta then alpha, got %+v", result.Prompts)
+	}
+	if len(result.Missing) != 1 || result.Missing[0] != "gone" {
+		t.Errorf("Expected gone to be missing, got %v", result.Missing)
+	}
+
+	if w := do(`{"slugs": []}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for no slugs, got %d", w.Code)
+	}
+	slugs := make([]string, maxBatchGetSlugs+1)
+	for i := range slugs {
+		slugs[i] = fmt.Sprintf("p%d", i)
+	}
+	body, _ := json.Marshal(models.BatchGetPromptsInput{Slugs: slugs})
+	if w := do(string(body)); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for too many slugs, got %d", w.Code)
+	}
+}
+
+func TestCollectionHandlers(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "refund-reply", Title: "Refund Reply", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	w := do("POST", "/api/collections", `{"path": "support", "title": "Support"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var support models.Collection
+	json.NewDecoder(w.Body).Decode(&support)
+	w = do("POST", "/api/collections", `{"path": "support/refunds"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var refunds models.Collection
+	json.NewDecoder(w.Body).Decode(&refunds)
+
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{`{"path": "support"}`, http.StatusConflict},
+		{`{"path": "billing/invoices"}`, http.StatusNotFound},
+		{`{"path": "Bad Path"}`, http.StatusBadRequest},
+	} {
+		if w := do("POST", "/api/collections", tt.body); w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.body, tt.want, w.Code)
+		}
+	}
+
+	refundPrompts := fmt.Sprintf("/api/collections/%d/prompts", refunds.ID)
+	if w := do("PUT", refundPrompts+"/refund-reply", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", refundPrompts+"/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing prompt, got %d", w.Code)
+	}
+
+	w = do("GET", fmt.Sprintf("/api/collections/%d/prompts?recursive=true", support.ID), "")
+	var summaries []models.PromptSummary
+	json.NewDecoder(w.Body).Decode(&summaries)
+	if w.Code != http.StatusOK || len(summaries) != 1 || summaries[0].Slug != "refund-reply" {
+		t.Errorf("Expected refund-reply under support, got %d: %+v", w.Code, summaries)
+	}
+	w = do("GET", fmt.Sprintf("/api/collections/%d/prompts", support.ID), "")
+	summaries = nil
+	json.NewDecoder(w.Body).Decode(&summaries)
+	if w.Code != http.StatusOK || len(summaries) != 0 {
+		t.Errorf("Expected nothing directly in support, got %d: %+v", w.Code, summaries)
+	}
+
+	if w := do("DELETE", refundPrompts+"/refund-reply", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+	w = do("GET", "/api/collections", "")
+	var collections []models.Collection
+	json.NewDecoder(w.Body).Decode(&collections)
+	if w.Code != http.StatusOK || len(collections) != 2 || collections[1].Prompts != 0 {
+		t.Errorf("Expected two empty collections, got %d: %+v", w.Code, collections)
+	}
+	if w := do("GET", "/api/collections/999/prompts", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing collection, got %d", w.Code)
+	}
+}
+
+func TestPromptLinksHandlers(t *testing.T) {
+	h := setupTestHandler(t)
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	for _, slug := range []string{"preamble", "support"} {
+		if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+
+	w := do("PUT", "/api/prompts/support/links", `{"links": [{"slug": "preamble", "kind": "includes"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = do("GET", "/api/prompts/preamble/links", "")
+	var links models.PromptLinks
+	json.NewDecoder(w.Body).Decode(&links)
+	if w.Code != http.StatusOK || len(links.Dependents) != 1 || links.Dependents[0].Slug != "support" {
+		t.Errorf("Expected support to depend on preamble, got %d: %+v", w.Code, links)
+	}
+
+	for _, tt := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/prompts/support/links", `{"links": [{"slug": "missing", "kind": "includes"}]}`, http.StatusBadRequest},
+		{"/api/prompts/preamble/links", `{"links": [{"slug": "support", "kind": "includes"}]}`, http.StatusBadRequest},
+		{"/api/prompts/missing/links", `{"links": []}`, http.StatusNotFound},
+	} {
+		if w := do("PUT", tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("%s %s: expected status %d, got %d", tt.path, tt.body, tt.want, w.Code)
+		}
+	}
+}
+
+func TestRetentionHandlers(t *testing.T) {
+	h := setupTestHandler(t)
+	h.AdminToken = "secret"
+	h.Retention.Default = models.RetentionPolicy{KeepVersions: 10}
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "notes", Title: "Notes", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	for _, content := range []string{"v2", "v3"} {
+		if _, err := h.Store.CreatePromptVersion("notes", models.CreatePromptVersionInput{Content: content}); err != nil {
+			t.Fatalf("CreatePromptVersion failed: %v", err)
+		}
+	}
+
+	w := do("GET", "/api/prompts/notes/retention", "")
+	var retention models.PromptRetention
+	json.NewDecoder(w.Body).Decode(&retention)
+	if w.Code != http.StatusOK || retention.Policy != nil || retention.Effective.KeepVersions != 10 || len(retention.Prunable) != 0 {
+		t.Errorf("Expected the default policy with nothing prunable, got %d: %+v", w.Code, retention)
+	}
+
+	w = do("PUT", "/api/prompts/notes/retention", `{"keep_versions": 1}`)
+	retention = models.PromptRetention{}
+	json.NewDecoder(w.Body).Decode(&retention)
+	if w.Code != http.StatusOK || retention.Policy == nil || fmt.Sprint(retention.Prunable) != "[1 2]" {
+		t.Fatalf("Expected versions 1 and 2 prunable, got %d: %+v", w.Code, retention)
+	}
+	if w := do("PUT", "/api/prompts/notes/retention", `{"keep_days": -1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a negative rule, got %d", w.Code)
+	}
+	if w := do("GET", "/api/prompts/missing/retention", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+
+	w = do("POST", "/api/admin/retention/prune?dry_run=true", "")
+	var result models.PruneResult
+	json.NewDecoder(w.Body).Decode(&result)
+	if w.Code != http.StatusOK || !result.DryRun || result.Versions != 2 {
+		t.Errorf("Expected a dry run reporting 2 versions, got %d: %+v", w.Code, result)
+	}
+	if count, _ := h.Store.CountPromptVersions("notes"); count != 3 {
+		t.Errorf("Expected a dry run to keep every version, got %d", count)
+	}
+
+	w = do("POST", "/api/admin/retention/prune", "")
+	result = models.PruneResult{}
+	json.NewDecoder(w.Body).Decode(&result)
+	if w.Code != http.StatusOK || result.DryRun || result.Versions != 2 {
+		t.Errorf("Expected 2 versions pruned, got %d: %+v", w.Code, result)
+	}
+	if count, _ := h.Store.CountPromptVersions("notes"); count != 1 {
+		t.Errorf("Expected only the current version left, got %d", count)
+	}
+
+	if w := do("DELETE", "/api/prompts/notes/retention", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestErrorResponseCodes(t *testing.T) {
+	h := setupTestHandler(t)
+	h.MaxTitleLength = 10
+	router := h.Routes()
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Greeting", Content: "Hello"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	tests := []struct {
+		name, method, path, body string
+		status                   int
+		code, field              string
+	}{
+		{"missing prompt", "GET", "/api/prompts/missing", "", http.StatusNotFound, CodePromptNotFound, ""},
+		{"missing version", "GET", "/api/prompts/greeting/versions/9", "", http.StatusNotFound, CodeVersionNotFound, ""},
+		{"taken slug", "POST", "/api/prompts", `{"slug": "greeting", "title": "Again", "content": "Hi"}`, http.StatusConflict, CodeSlugConflict, ""},
+		{"stale base", "POST", "/api/prompts/greeting/versions", `{"content": "Hi", "base_version": 7}`, http.StatusConflict, CodeVersionConflict, ""},
+		{"bad slug", "POST", "/api/prompts", `{"slug": "Not A Slug", "title": "Bad", "content": "Hi"}`, http.StatusBadRequest, CodeValidationFailed, "slug"},
+		{"long title", "POST", "/api/prompts", `{"title": "Much too long", "content": "Hi"}`, http.StatusUnprocessableEntity, CodeValidationFailed, "title"},
+		{"bad JSON", "POST", "/api/prompts", `{`, http.StatusBadRequest, CodeInvalidJSON, ""},
+		{"missing dataset", "GET", "/api/datasets/missing", "", http.StatusNotFound, CodeNotFound, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("X-Request-ID", "trace-errors")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: failed to decode error: %v", tt.name, err)
+		}
+		if w.Code != tt.status || resp.Code != tt.code || resp.Field != tt.field {
+			t.Errorf("%s: expected %d %s %q, got %d %+v", tt.name, tt.status, tt.code, tt.field, w.Code, resp)
+		}
+		if resp.Message == "" || resp.RequestID != "trace-errors" {
+			t.Errorf("%s: expected a message and the request ID, got %+v", tt.name, resp)
+		}
+	}
+}
+
+func TestAdminMaintenanceHandlers(t *testing.T) {
+	h := setupTestHandler(t)
+	h.AdminToken = "secret"
+	router := h.Routes()
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, slug := range []string{"stale", "doomed"} {
+		if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	if err := h.Store.SetPromptArchived("stale", true); err != nil {
+		t.Fatalf("SetPromptArchived failed: %v", err)
+	}
+
+	req := httptest.NewRequest("DELETE", "/api/admin/prompts/doomed", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without the admin token, got %d", w.Code)
+	}
+
+	w = do("POST", "/api/admin/archived/purge?older_than_days=-1")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+	w = do("POST", "/api/admin/archived/purge?dry_run=true")
+	var purge models.PurgeResult
+	json.NewDecoder(w.Body).Decode(&purge)
+	if w.Code != http.StatusOK || !purge.DryRun || len(purge.Prompts) != 1 || purge.Prompts[0].Slug != "stale" {
+		t.Errorf("Expected a dry run listing stale, got %d: %+v", w.Code, purge)
+	}
+	if w = do("POST", "/api/admin/archived/purge?older_than_days=30"); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "stale") {
+		t.Errorf("Expected nothing archived 30 days ago, got %d: %s", w.Code, w.Body.String())
+	}
+	if w = do("POST", "/api/admin/archived/purge"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "stale") {
+		t.Errorf("Expected stale purged, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = do("DELETE", "/api/admin/projects/default/prompts/doomed")
+	var deleted models.DeletedPrompt
+	json.NewDecoder(w.Body).Decode(&deleted)
+	if w.Code != http.StatusOK || deleted.Slug != "doomed" || deleted.Versions != 1 {
+		t.Errorf("Expected doomed deleted, got %d: %+v", w.Code, deleted)
+	}
+	if w = do("DELETE", "/api/admin/prompts/doomed"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting twice, got %d", w.Code)
+	}
+
+	w = do("POST", "/api/admin/recompute")
+	var recomputed models.RecomputeResult
+	json.NewDecoder(w.Body).Decode(&recomputed)
+	if w.Code != http.StatusOK || recomputed.CurrentVersions != 0 || recomputed.ContentHashes != 0 {
+		t.Errorf("Expected nothing to repair, got %d: %+v", w.Code, recomputed)
+	}
+	if w = do("POST", "/api/admin/compact"); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	w = do("GET", "/api/admin/audit")
+	var entries []models.AuditEntry
+	json.NewDecoder(w.Body).Decode(&entries)
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	want := "[admin.compacted admin.recomputed admin.prompt_deleted admin.archived_purged admin.archived_purged]"
+	if w.Code != http.StatusOK || fmt.Sprint(actions) != want {
+		t.Errorf("Expected %s, got %d: %v", want, w.Code, actions)
+	}
+	if len(entries) > 0 && entries[0].Actor != "admin" {
+		t.Errorf("Expected admin as the actor, got %q", entries[0].Actor)
+	}
+	w = do("GET", "/api/admin/audit?limit=2&offset=1")
+	entries = nil
+	json.NewDecoder(w.Body).Decode(&entries)
+	if w.Code != http.StatusOK || len(entries) != 2 || entries[0].Action != "admin.recomputed" {
+		t.Errorf("Expected the second page entry first, got %d: %+v", w.Code, entries)
+	}
+	for _, query := range []string{"limit=-1", "limit=0", "limit=1001", "limit=x", "offset=-1", "offset=x"} {
+		if w = do("GET", "/api/admin/audit?"+query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	h := setupTestHandler(t)
+	h.AdminToken = "secret"
+	router := h.Routes()
+
+	do := func(method, path, body string, admin bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if admin {
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Greeting", Content: "Hello {{name}}"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	w := do("PUT", "/api/admin/read-only", `{"read_only": true}`, true)
+	var status models.ReadOnlyStatus
+	json.NewDecoder(w.Body).Decode(&status)
+	if w.Code != http.StatusOK || !status.ReadOnly || status.Since == nil {
+		t.Fatalf("Expected read-only mode on, got %d: %+v", w.Code, status)
+	}
+
+	w = do("POST", "/api/prompts", `{"slug": "farewell", "title": "Farewell", "content": "Bye"}`, false)
+	var resp ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusServiceUnavailable || resp.Code != CodeReadOnly {
+		t.Errorf("Expected 503 READ_ONLY creating a prompt, got %d: %s", w.Code, w.Body.String())
+	}
+	if w = do("PUT", "/api/projects/search/prompts/greeting/visibility", `{"public": true}`, false); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 under a project prefix, got %d", w.Code)
+	}
+	if w = do("GET", "/api/prompts/greeting", "", false); w.Code != http.StatusOK {
+		t.Errorf("Expected reads to keep working, got %d", w.Code)
+	}
+	if w = do("POST", "/api/prompts/greeting/render", `{"variables": {"name": "Ada"}}`, false); w.Code != http.StatusOK {
+		t.Errorf("Expected render to keep working, got %d: %s", w.Code, w.Body.String())
+	}
+	if w = do("GET", "/api/capabilities", "", false); !strings.Contains(w.Body.String(), `"read_only":true`) {
+		t.Errorf("Expected capabilities to report read-only mode, got %s", w.Body.String())
+	}
+
+	// Turning it on again keeps the original time
+	since := *status.Since
+	w = do("PUT", "/api/admin/read-only", `{"read_only": true}`, true)
+	json.NewDecoder(w.Body).Decode(&status)
+	if status.Since == nil || !status.Since.Equal(since) {
+		t.Errorf("Expected since %v to be kept, got %v", since, status.Since)
+	}
+
+	w = do("PUT", "/api/admin/read-only", `{"read_only": false}`, true)
+	status = models.ReadOnlyStatus{}
+	json.NewDecoder(w.Body).Decode(&status)
+	if w.Code != http.StatusOK || status.ReadOnly || status.Since != nil {
+		t.Errorf("Expected read-only mode off, got %d: %+v", w.Code, status)
+	}
+	if w = do("POST", "/api/prompts", `{"slug": "farewell", "title": "Farewell", "content": "Bye"}`, false); w.Code != http.StatusCreated {
+		t.Errorf("Expected writes to work again, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSemanticSearchHandler(t *testing.T) {
+	// Texts about money point one way and everything else another
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var data []map[string]any
+		for i, text := range req.Input {
+			vector := []float32{0, 1}
+			if strings.Contains(text, "refund") || strings.Contains(text, "money") {
+				vector = []float32{1, 0.2}
+			}
+			if strings.Contains(text, "fail") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			data = append(data, map[string]any{"index": i, "embedding": vector})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer provider.Close()
+
+	h := setupTestHandler(t)
+	for _, p := range []models.CreatePromptInput{
+		{Slug: "refund", Title: "Refund request", Content: "Process a refund"},
+		{Slug: "greeting", Title: "Greeting", Content: "Hello {{name}}"},
+	} {
+		if _, err := h.Store.CreatePrompt(p); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	h.Embeddings = NewEmbeddingIndexer(providers.NewOpenAIEmbedder("local", provider.URL, "", "test-embed"), h.Store, h.Logger)
+	if n, err := h.Embeddings.Run(context.Background()); err != nil || n != 2 {
+		t.Fatalf("Expected 2 prompts embedded, got %d, %v", n, err)
+	}
+	router := h.Routes()
+
+	req := httptest.NewRequest("GET", "/api/prompts/semantic-search?q=give+the+customer+their+money+back", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var results []models.SemanticSearchResult
+	json.NewDecoder(w.Body).Decode(&results)
+	if w.Code != http.StatusOK || len(results) != 2 || results[0].Slug != "refund" || results[0].Score <= results[1].Score {
+		t.Errorf("Expected refund ranked first, got %d: %+v", w.Code, results)
+	}
+
+	for path, want := range map[string]int{
+		"/api/prompts/semantic-search":                  http.StatusBadRequest,
+		"/api/prompts/semantic-search?q=money&limit=x":  http.StatusBadRequest,
+		"/api/prompts/semantic-search?q=money&limit=99": http.StatusBadRequest,
+		"/api/prompts/semantic-search?q=fail":           http.StatusBadGateway,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/capabilities", nil))
+	if !strings.Contains(w.Body.String(), `"semantic_search":true,"semantic_search_model":"test-embed"`) {
+		t.Errorf("Expected capabilities to report semantic search, got %s", w.Body.String())
+	}
+}
+
+func TestDiffVersionsHandler(t *testing.T) {
+	h := setupTestHandler(t)
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "greet", Title: "Greet", Content: "Hello\n{{name}}\n"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := h.Store.CreatePromptVersion("greet", models.CreatePromptVersionInput{Content: "Hello\n<b>{{name}}</b>\nBye\n"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	router := h.Routes()
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	// Defaults to the current version against the one before it
+	w := get("/api/prompts/greet/diff")
+	want := "--- greet@v1\n+++ greet@v2\n@@ -1,2 +1,3 @@\n Hello\n-{{name}}\n+<b>{{name}}</b>\n+Bye\n"
+	if w.Code != http.StatusOK || w.Body.String() != want || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/x-diff") {
+		t.Errorf("Unexpected unified diff %d %s:\n%s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	w = get("/api/projects/default/prompts/greet/diff?from=1&to=latest&format=html")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") ||
+		!strings.Contains(w.Body.String(), `<td class="diff-insert">&lt;b&gt;{{name}}&lt;/b&gt;</td>`) {
+		t.Errorf("Unexpected HTML diff %d:\n%s", w.Code, w.Body.String())
+	}
+
+	w = get("/api/prompts/greet/diff?format=json&context=0")
+	var result models.VersionDiff
+	json.NewDecoder(w.Body).Decode(&result)
+	if w.Code != http.StatusOK || result.FromVersion != 1 || result.ToVersion != 2 || result.LinesAdded != 2 || result.LinesRemoved != 1 ||
+		len(result.Hunks) != 1 || result.Hunks[0].OldStart != 2 || len(result.Hunks[0].Lines) != 3 || result.Hunks[0].Lines[0].Op != "delete" {
+		t.Errorf("Unexpected JSON diff %d: %+v", w.Code, result)
+	}
+
+	// Version 1 is compared against empty content
+	w = get("/api/prompts/greet/diff?to=1&format=json")
+	result = models.VersionDiff{}
+	json.NewDecoder(w.Body).Decode(&result)
+	if w.Code != http.StatusOK || result.FromVersion != 0 || result.LinesAdded != 2 || result.LinesRemoved != 0 {
+		t.Errorf("Unexpected diff of version 1 %d: %+v", w.Code, result)
+	}
+
+	w = get("/api/prompts/greet/diff?from=2&to=2&format=json")
+	result = models.VersionDiff{}
+	json.NewDecoder(w.Body).Decode(&result)
+	if w.Code != http.StatusOK || result.Hunks == nil || len(result.Hunks) != 0 {
+		t.Errorf("Expected no hunks for the same version, got %d: %+v", w.Code, result)
+	}
+
+	for path, want := range map[string]int{
+		"/api/prompts/greet/diff?format=xml": http.StatusBadRequest,
+		"/api/prompts/greet/diff?context=-1": http.StatusBadRequest,
+		"/api/prompts/greet/diff?from=first": http.StatusBadRequest,
+		"/api/prompts/greet/diff?from=9":     http.StatusNotFound,
+		"/api/prompts/missing/diff":          http.StatusNotFound,
+	} {
+		if w := get(path); w.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, w.Code)
+		}
+	}
+}
+
+func TestEnvironmentHandlers(t *testing.T) {
+	h := setupTestHandler(t)
+	if _, err := h.Store.CreatePrompt(models.CreatePromptInput{Slug: "greet", Title: "Greet", Content: "Hello"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	router := h.Routes()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	for _, name := range []string{"dev", "prod"} {
+		if w := do("POST", "/api/environments", `{"name":"`+name+`"}`); w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 creating %s, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+	if w := do("POST", "/api/environments", `{"name":"dev"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a duplicate environment, got %d", w.Code)
+	}
+	if w := do("POST", "/api/prompts/greet/promote", `{"environment":"prod"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 promoting past dev, got %d", w.Code)
+	}
+	if w := do("POST", "/api/prompts/greet/promote", `{"environment":"dev"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 promoting to dev, got %d: %s", w.Code, w.Body.String())
+	}
+	w := do("POST", "/api/projects/default/prompts/greet/promote", `{"environment":"prod","note":"ship it"}`)
+	var release models.Release
+	json.NewDecoder(w.Body).Decode(&release)
+	if w.Code != http.StatusCreated || release.Label != "prod" || release.Note != "ship it" || len(release.Items) != 1 || release.Items[0].Version != 1 {
+		t.Fatalf("Unexpected promotion to prod %d: %+v", w.Code, release)
+	}
+
+	w = do("GET", "/api/environments/prod", "")
+	var env models.Environment
+	json.NewDecoder(w.Body).Decode(&env)
+	if w.Code != http.StatusOK || env.Previous != "dev" || len(env.Deployments) != 1 || env.Deployments[0].ReleaseID != release.ID {
+		t.Errorf("Unexpected prod %d: %+v", w.Code, env)
+	}
+	w = do("GET", "/api/environments", "")
+	var envs []models.Environment
+	json.NewDecoder(w.Body).Decode(&envs)
+	if w.Code != http.StatusOK || len(envs) != 2 || envs[0].Next != "prod" || envs[1].Prompts != 1 {
+		t.Errorf("Unexpected environments %d: %+v", w.Code, envs)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/api/environments", `{"name":"Bad Name"}`, http.StatusBadRequest},
+		{"POST", "/api/prompts/greet/promote", `{}`, http.StatusBadRequest},
+		{"POST", "/api/prompts/greet/promote", `{"environment":"qa"}`, http.StatusNotFound},
+		{"POST", "/api/prompts/missing/promote", `{"environment":"dev"}`, http.StatusNotFound},
+		{"GET", "/api/environments/qa", "", http.StatusNotFound},
+		{"DELETE", "/api/environments/dev", "", http.StatusNoContent},
+		{"DELETE", "/api/environments/dev", "", http.StatusNotFound},
+	} {
+		if w := do(tc.method, tc.path, tc.body); w.Code != tc.want {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.want, w.Code)
+		}
+	}
+}
+
+// Test version approvals under a review policy
+func TestVersionReviewHandlers(t *testing.T) {
+	h := setupTestHandler(t)
+	h.AdminToken = "secret"
+	keys, err := auth.NewAPIKeys(map[string]string{"key-a": "alice", "key-b": "bob", "key-c": "carol"})
+	if err != nil {
+		t.Fatalf("NewAPIKeys failed: %v", err)
+	}
+	h.Auth = keys
+	router := h.Routes()
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	expect := func(w *httptest.ResponseRecorder, want int) {
+		t.Helper()
+		if w.Code != want {
+			t.Errorf("Expected status %d, got %d: %s", want, w.Code, w.Body.String())
+		}
+	}
+
+	expect(do("POST", "/api/prompts", "key-a", `{"slug":"refunds","title":"Refunds","content":"v1"}`), http.StatusCreated)
+	expect(do("PUT", "/api/admin/prompts/refunds/review-policy", "key-a", `{"required_approvals":1,"reviewers":["bob"]}`), http.StatusUnauthorized)
+	expect(do("PUT", "/api/admin/prompts/refunds/review-policy", "secret", `{"required_approvals":2,"reviewers":["bob"]}`), http.StatusBadRequest)
+	expect(do("PUT", "/api/admin/projects/default/prompts/refunds/review-policy", "secret", `{"required_approvals":1,"reviewers":["bob","carol"]}`), http.StatusOK)
+
+	w := do("GET", "/api/prompts/refunds/review-policy", "key-a", "")
+	var policy models.ReviewPolicy
+	json.NewDecoder(w.Body).Decode(&policy)
+	if w.Code != http.StatusOK || policy.RequiredApprovals != 1 || len(policy.Reviewers) != 2 {
+		t.Errorf("Unexpected policy %d: %+v", w.Code, policy)
+	}
+
+	w = do("POST", "/api/prompts/refunds/versions", "key-a", `{"content":"v2"}`)
+	var created models.PromptWithCurrentVersion
+	json.NewDecoder(w.Body).Decode(&created)
+	if w.Code != http.StatusAccepted || created.CurrentVersion.VersionNumber != 1 || created.PendingVersion == nil || created.PendingVersion.VersionNumber != 2 {
+		t.Fatalf("Unexpected pending create %d: %+v", w.Code, created)
+	}
+	expect(do("POST", "/api/releases", "key-a", `{"label":"prod","items":[{"slug":"refunds","version":2}]}`), http.StatusConflict)
+	expect(do("POST", "/api/prompts/refunds/versions/2/approvals", "key-a", `{}`), http.StatusForbidden)
+	expect(do("POST", "/api/prompts/refunds/versions/9/approvals", "key-b", `{}`), http.StatusNotFound)
+	expect(do("POST", "/api/prompts/refunds/versions/x/approvals", "key-b", `{}`), http.StatusBadRequest)
+
+	w = do("POST", "/api/prompts/refunds/versions/2/approvals", "key-b", `{"comment":"ok"}`)
+	var review models.VersionReview
+	json.NewDecoder(w.Body).Decode(&review)
+	if w.Code != http.StatusOK || review.Status != "approved" || len(review.Approvals) != 1 || review.Approvals[0].Reviewer != "bob" {
+		t.Fatalf("Unexpected approval %d: %+v", w.Code, review)
+	}
+	expect(do("POST", "/api/prompts/refunds/versions/2/approvals", "key-c", `{}`), http.StatusConflict)
+	w = do("GET", "/api/prompts/refunds", "key-a", "")
+	var prompt models.PromptWithCurrentVersion
+	json.NewDecoder(w.Body).Decode(&prompt)
+	if prompt.CurrentVersion.VersionNumber != 2 {
+		t.Errorf("Expected approved version 2 current, got %d", prompt.CurrentVersion.VersionNumber)
+	}
+	w = do("GET", "/api/prompts/refunds/versions/2/approvals", "key-c", "")
+	review = models.VersionReview{}
+	json.NewDecoder(w.Body).Decode(&review)
+	if w.Code != http.StatusOK || review.Version != 2 || review.RequiredApprovals != 1 || len(review.Approvals) != 1 {
+		t.Errorf("Unexpected review %d: %+v", w.Code, review)
+	}
+	expect(do("POST", "/api/releases", "key-a", `{"label":"prod","items":[{"slug":"refunds","version":2}]}`), http.StatusCreated)
+}
+
+func TestSlackNotifications(t *testing.T) {
+	type slackMessage struct {
+		Text   string `json:"text"`
+		Blocks []struct {
+			Text struct {
+				Text string `json:"text"`
+			} `json:"text"`
+		} `json:"blocks"`
+	}
+	receive := func() (*httptest.Server, chan slackMessage) {
+		messages := make(chan slackMessage, 8)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var msg slackMessage
+			json.NewDecoder(r.Body).Decode(&msg)
+			messages <- msg
+		}))
+		t.Cleanup(server.Close)
+		return server, messages
+	}
+	defaultChannel, messages := receive()
+	billingChannel, billingMessages := receive()
+
+	h := setupTestHandler(t)
+	h.AdminToken = "secret"
+	h.Notifications.Configure(SlackConfig{
+		WebhookURL:         defaultChannel.URL,
+		ProjectWebhookURLs: map[string]string{"billing": billingChannel.URL},
+	})
+	router := h.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: status %d: %s", method, path, w.Code, w.Body.String())
+		}
+		h.Integrations.Wait()
+		return w
+	}
+
+	do("POST", "/api/prompts", `{"slug": "greet", "title": "Greet", "content": "Hello {{name}}."}`)
+	do("POST", "/api/prompts/greet/versions", `{"content": "Hello {{name}}.\nBe brief."}`)
+	msg := <-messages
+	if msg.Text != "New version of default/greet: v2: +1 -0 lines, +3 tokens" || len(msg.Blocks) != 2 {
+		t.Fatalf("Unexpected new version message: %+v", msg)
+	}
+	if !strings.HasPrefix(msg.Blocks[0].Text.Text, "*<http://localhost:8080/prompts/greet|") {
+		t.Errorf("Expected a link to the prompt page, got %q", msg.Blocks[0].Text.Text)
+	}
+	if !strings.Contains(msg.Blocks[1].Text.Text, "+++ greet@v2") || !strings.Contains(msg.Blocks[1].Text.Text, "+Be brief.") {
+		t.Errorf("Expected a diff snippet, got %q", msg.Blocks[1].Text.Text)
+	}
+
+	do("POST", "/api/releases", `{"label": "prod", "items": [{"slug": "greet", "version": 1}]}`)
+	var release models.Release
+	json.NewDecoder(do("POST", "/api/releases", `{"label": "prod", "items": [{"slug": "greet", "version": 2}]}`).Body).Decode(&release)
+	do("POST", fmt.Sprintf("/api/releases/%d/rollback", release.ID), "")
+	if msg := <-messages; !strings.HasPrefix(msg.Text, "Rolled back prod of default/greet to v1 from v2: +0 -1 lines") ||
+		len(msg.Blocks) != 2 || !strings.Contains(msg.Blocks[1].Text.Text, "-Be brief.") {
+		t.Errorf("Unexpected rollback message: %+v", msg)
+	}
+
+	do("POST", "/api/projects/billing/prompts", `{"slug": "invoice", "title": "Invoice", "content": "a"}`)
+	do("POST", "/api/projects/billing/prompts/invoice/versions", `{"content": "b"}`)
+	if msg := <-billingMessages; !strings.HasPrefix(msg.Text, "New version of billing/invoice: v2") ||
+		!strings.Contains(msg.Blocks[0].Text.Text, "http://localhost:8080/api/projects/billing/prompts/invoice|") {
+		t.Errorf("Expected the billing project's own channel to get its version, got %+v", msg)
+	}
+
+	do("DELETE", "/api/admin/prompts/greet", "")
+	if msg := <-messages; msg.Text != "Deleted default/greet and its 2 versions" || len(msg.Blocks) != 1 {
+		t.Errorf("Unexpected deletion message: %+v", msg)
+	}
+	if len(messages) != 0 || len(billingMessages) != 0 {
+		t.Errorf("Expected no other messages, got %d and %d", len(messages), len(billingMessages))
+	}
+}
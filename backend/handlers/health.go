@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// ReadinessConfig controls the checks behind GET /readyz
+type ReadinessConfig struct {
+	// Timeout bounds the checks, so a locked database fails the probe rather
+	// than hanging it
+	Timeout time.Duration
+	// WriteProbe also checks that the write lock can be taken. A long write,
+	// such as a backup or an import, then takes the instance out of rotation
+	// while it runs.
+	WriteProbe bool
+}
+
+// DefaultReadinessConfig checks reads only, within two seconds
+func DefaultReadinessConfig() ReadinessConfig {
+	return ReadinessConfig{Timeout: 2 * time.Second}
+}
+
+// Handler: Liveness probe
+// Answers as long as the process can serve HTTP. It doesn't touch the
+// database, so an orchestrator doesn't restart the instance over a database
+// that is briefly locked or unreachable.
+func (h *Handler) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// Handler: Readiness probe
+// Reports whether the instance should receive traffic: the database answers
+// and its schema is current, and, with WriteProbe, it can be written. Fails
+// with 503 so a load balancer takes the instance out of rotation until the
+// checks pass again.
+func (h *Handler) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if h.Readiness.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Readiness.Timeout)
+		defer cancel()
+	}
+
+	result := models.Readiness{
+		Status: "ready",
+		Checks: h.Store.CheckReadiness(ctx, h.Readiness.WriteProbe),
+	}
+	status := http.StatusOK
+	for _, check := range result.Checks {
+		if !check.OK {
+			result.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	h.respondJSON(w, status, result)
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+const (
+	// Time allowed to write a message to a client
+	wsWriteWait = 10 * time.Second
+	// Time allowed between pongs before a client is considered gone
+	wsPongWait = 60 * time.Second
+	// Ping period, must be shorter than wsPongWait
+	wsPingPeriod = (wsPongWait * 9) / 10
+	// Maximum size of an incoming client message
+	wsMaxMessageSize = 1024
+	// Buffered outgoing messages per client before it is dropped
+	wsSendBuffer = 16
+)
+
+// Event types broadcast over the WebSocket hub
+const (
+	EventEditing = "editing"
+	EventUpdated = "updated"
+)
+
+// Event is a collaboration signal exchanged over /ws
+type Event struct {
+	Type    string `json:"type"`
+	Project string `json:"project"` // empty from clients means the default project
+	Slug    string `json:"slug"`
+	Version int    `json:"version,omitempty"`
+	Client  string `json:"client,omitempty"`
+}
+
+// Hub fans out collaboration events to the connected WebSocket clients that
+// can read the event's prompt. Each client checks access in its own write
+// loop, so broadcasting never waits on the store.
+type Hub struct {
+	mu      sync.Mutex
+	clients map[*wsClient]struct{}
+	logger  *slog.Logger
+}
+
+// wsClient is a single WebSocket connection registered with the hub
+type wsClient struct {
+	hub   *Hub
+	conn  *websocket.Conn
+	send  chan hubMessage
+	store store.Store // scoped to the caller who connected, for access checks
+}
+
+// hubMessage is an event queued for a client, encoded once for every client
+type hubMessage struct {
+	event   Event
+	payload []byte
+}
+
+// NewHub creates an empty Hub
+func NewHub(logger *slog.Logger) *Hub {
+	return &Hub{
+		clients: make(map[*wsClient]struct{}),
+		logger:  logger,
+	}
+}
+
+// Broadcast queues an event for every connected client except the sender;
+// each client drops the events for prompts it can't read before writing.
+// Clients whose send buffer is full are disconnected rather than blocking the
+// caller.
+func (hub *Hub) Broadcast(event Event, sender *wsClient) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		hub.logger.Error("failed to encode hub event", "error", err)
+		return
+	}
+	msg := hubMessage{event: event, payload: payload}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for c := range hub.clients {
+		if c == sender {
+			continue
+		}
+		select {
+		case c.send <- msg:
+		default:
+			delete(hub.clients, c)
+			close(c.send)
+		}
+	}
+}
+
+// ClientCount returns the number of connected clients
+func (hub *Hub) ClientCount() int {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return len(hub.clients)
+}
+
+func (hub *Hub) register(c *wsClient) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.clients[c] = struct{}{}
+}
+
+func (hub *Hub) unregister(c *wsClient) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if _, ok := hub.clients[c]; ok {
+		delete(hub.clients, c)
+		close(c.send)
+	}
+}
+
+// checkWebSocketOrigin accepts upgrades from the registry's own pages and the
+// allowed CORS origins. CORS doesn't cover WebSockets, and browsers send
+// client certificates with the upgrade from any site, so without this check
+// other sites could listen for events as the user. Requests without an Origin
+// header don't come from a web page.
+func (h *Handler) checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || h.corsConfig().allowOrigin(origin) != "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// Handler: WebSocket collaboration signals
+func (h *Handler) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     h.checkWebSocketOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response
+		reqctx.Logger(r.Context()).Error("failed to upgrade websocket", "error", err)
+		return
+	}
+
+	c := &wsClient{hub: h.Hub, conn: conn, send: make(chan hubMessage, wsSendBuffer), store: h.Store.WithContext(r.Context())}
+	h.Hub.register(c)
+
+	go c.writePump()
+	c.readPump()
+}
+
+// canSee reports whether the client's caller can see event's project and
+// read its prompt
+func (c *wsClient) canSee(event Event) bool {
+	ok, err := canReadPrompt(c.store, event.Project, event.Slug)
+	if err != nil {
+		c.hub.logger.Error("failed to authorize hub event", "error", err, "project", event.Project, "slug", event.Slug)
+	}
+	return ok
+}
+
+// readPump relays "editing" signals from this client to everyone else
+func (c *wsClient) readPump() {
+	defer func() {
+		c.hub.unregister(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var event Event
+		if err := c.conn.ReadJSON(&event); err != nil {
+			return
+		}
+		// Clients may only announce edits; updates come from the server
+		if event.Type != EventEditing || event.Slug == "" {
+			continue
+		}
+		if event.Project == "" {
+			event.Project = store.DefaultProject
+		}
+		if store.ValidateProject(event.Project) != nil || !c.canSee(event) {
+			continue
+		}
+		c.hub.Broadcast(Event{Type: EventEditing, Project: event.Project, Slug: event.Slug, Client: event.Client}, c)
+	}
+}
+
+// writePump delivers the queued events the client can see and keeps the
+// connection alive with pings
+func (c *wsClient) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			if !ok {
+				c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if !c.canSee(msg.event) {
+				continue
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg.payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
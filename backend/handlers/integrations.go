@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// Integration names
+const (
+	IntegrationWebhooks = "webhooks"
+	IntegrationSlack    = "slack"
+)
+
+const (
+	// integrationMaxAttempts bounds tries per delivery, including the first
+	integrationMaxAttempts = 3
+)
+
+// Integrations runs deliveries for optional subsystems such as webhooks.
+// Deliveries always run in the background, so a slow or failing third party
+// can never fail or delay the API request that triggered it. Failed
+// deliveries are retried with backoff, and every outcome is counted per
+// integration for /metrics and /api/admin/integration-status.
+//
+// New integrations should hand their outbound calls to Go rather than making
+// them inline in a handler.
+type Integrations struct {
+	logger     *slog.Logger
+	retryDelay time.Duration
+	pending    sync.WaitGroup
+
+	mu    sync.Mutex
+	stats map[string]*integrationStats
+}
+
+// integrationStats is the running tally for one integration
+type integrationStats struct {
+	delivered     int64
+	failed        int64
+	retries       int64
+	inFlight      int64
+	lastSuccessAt time.Time
+	lastFailureAt time.Time
+	lastError     string
+}
+
+// NewIntegrations creates a runner with the built-in integrations registered
+func NewIntegrations(logger *slog.Logger) *Integrations {
+	in := &Integrations{
+		logger:     logger,
+		retryDelay: time.Second,
+		stats:      make(map[string]*integrationStats),
+	}
+	in.Register(IntegrationWebhooks)
+	return in
+}
+
+// Register makes an integration show up in status and metrics before its
+// first delivery
+func (in *Integrations) Register(name string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.statsFor(name)
+}
+
+// permanentError marks a delivery failure that retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so Go records the failure without retrying it
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// Go runs deliver in the background on behalf of integration name. target
+// identifies the destination in logs. deliver is retried with exponential
+// backoff until it succeeds, returns a Permanent error, or runs out of
+// attempts. Panics are recovered and recorded as failures.
+func (in *Integrations) Go(name, target string, deliver func() error) {
+	in.update(name, func(s *integrationStats) { s.inFlight++ })
+	in.pending.Add(1)
+	go func() {
+		defer in.pending.Done()
+		err := in.attempt(name, target, deliver)
+		in.update(name, func(s *integrationStats) {
+			s.inFlight--
+			if err == nil {
+				s.delivered++
+				s.lastSuccessAt = time.Now().UTC()
+				return
+			}
+			s.failed++
+			s.lastFailureAt = time.Now().UTC()
+			s.lastError = err.Error()
+		})
+	}()
+}
+
+// attempt calls deliver until it succeeds or gives up, returning the last error
+func (in *Integrations) attempt(name, target string, deliver func() error) error {
+	delay := in.retryDelay
+	for attempt := 1; ; attempt++ {
+		err := in.call(deliver)
+		if err == nil {
+			return nil
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempt == integrationMaxAttempts {
+			in.logger.Error("integration delivery failed",
+				"integration", name,
+				"target", target,
+				"error", err,
+				"attempts", attempt,
+			)
+			return err
+		}
+		in.update(name, func(s *integrationStats) { s.retries++ })
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// call runs deliver, turning a panic into an error
+func (in *Integrations) call(deliver func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = Permanent(fmt.Errorf("panic: %v", p))
+		}
+	}()
+	return deliver()
+}
+
+// Wait blocks until every in-flight delivery has finished or given up
+func (in *Integrations) Wait() {
+	in.pending.Wait()
+}
+
+// Status reports every integration, ordered by name. An integration is
+// "failing" when its most recent delivery failed.
+func (in *Integrations) Status() []models.IntegrationStatus {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	results := make([]models.IntegrationStatus, 0, len(in.stats))
+	for name, s := range in.stats {
+		status := models.IntegrationStatus{
+			Name:      name,
+			Status:    "ok",
+			Delivered: s.delivered,
+			Failed:    s.failed,
+			Retries:   s.retries,
+			InFlight:  s.inFlight,
+			LastError: s.lastError,
+		}
+		if !s.lastSuccessAt.IsZero() {
+			at := s.lastSuccessAt
+			status.LastSuccessAt = &at
+		}
+		if !s.lastFailureAt.IsZero() {
+			at := s.lastFailureAt
+			status.LastFailureAt = &at
+			if s.lastFailureAt.After(s.lastSuccessAt) {
+				status.Status = "failing"
+			}
+		}
+		results = append(results, status)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+var (
+	integrationDeliveriesDesc = prometheus.NewDesc("integration_deliveries_total",
+		"Total number of optional integration deliveries by outcome", []string{"integration", "outcome"}, nil)
+	integrationRetriesDesc = prometheus.NewDesc("integration_retries_total",
+		"Total number of retried integration delivery attempts", []string{"integration"}, nil)
+	integrationInFlightDesc = prometheus.NewDesc("integration_in_flight",
+		"Number of integration deliveries not yet finished", []string{"integration"}, nil)
+)
+
+// Describe implements prometheus.Collector
+func (in *Integrations) Describe(ch chan<- *prometheus.Desc) {
+	ch <- integrationDeliveriesDesc
+	ch <- integrationRetriesDesc
+	ch <- integrationInFlightDesc
+}
+
+// Collect implements prometheus.Collector with per-integration counters
+func (in *Integrations) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range in.Status() {
+		ch <- prometheus.MustNewConstMetric(integrationDeliveriesDesc, prometheus.CounterValue, float64(s.Delivered), s.Name, "success")
+		ch <- prometheus.MustNewConstMetric(integrationDeliveriesDesc, prometheus.CounterValue, float64(s.Failed), s.Name, "failure")
+		ch <- prometheus.MustNewConstMetric(integrationRetriesDesc, prometheus.CounterValue, float64(s.Retries), s.Name)
+		ch <- prometheus.MustNewConstMetric(integrationInFlightDesc, prometheus.GaugeValue, float64(s.InFlight), s.Name)
+	}
+}
+
+func (in *Integrations) update(name string, fn func(*integrationStats)) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	fn(in.statsFor(name))
+}
+
+// statsFor returns name's tally, creating it on first use. Callers hold mu.
+func (in *Integrations) statsFor(name string) *integrationStats {
+	s, ok := in.stats[name]
+	if !ok {
+		s = &integrationStats{}
+		in.stats[name] = s
+	}
+	return s
+}
+
+// Handler: Integration status
+func (h *Handler) handleIntegrationStatus(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, h.Integrations.Status())
+}
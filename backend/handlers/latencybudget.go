@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+const (
+	// latencyBudgetBuckets is how many slices the SLO window is counted in;
+	// requests age out of the window one slice at a time
+	latencyBudgetBuckets = 60
+	// latencyBudgetShortBuckets is the trailing slices that make up the short
+	// window, 1/12 of the full one (5 minutes of an hour)
+	latencyBudgetShortBuckets = 5
+)
+
+// fastFailRoutes are the routes that may shed load when their budget runs
+// out: rendering and resolving a prompt, which callers have on their own
+// request path and would rather fail fast on than wait for
+var fastFailRoutes = []string{
+	"POST /api/prompts/{slug}/render",
+	"GET /api/prompts/{slug}",
+	"GET /api/prompts/{slug}/versions/{version}",
+}
+
+// LatencySLO is a latency objective for one endpoint
+type LatencySLO struct {
+	// Route is the mux route pattern, such as "POST /api/prompts/{slug}/render".
+	// Project routes share the SLO of the matching /api route.
+	Route string
+	// Threshold is the latency a request must finish within to count as good
+	Threshold time.Duration
+	// Objective is the fraction of requests that must be good, such as 0.99
+	Objective float64
+	// FastFail rejects requests with 503 while the budget is exhausted. Only
+	// render and resolve routes may set it.
+	FastFail bool
+}
+
+// LatencyBudgetConfig tracks error budgets for endpoint latency SLOs
+type LatencyBudgetConfig struct {
+	SLOs []LatencySLO
+	// Window is how far back the budget is counted
+	Window time.Duration
+	// MinRequests is how many requests the window needs before its budget
+	// can count as exhausted
+	MinRequests int
+}
+
+// DefaultLatencyBudgetConfig returns the latency budget defaults: no SLOs,
+// counted over an hour
+func DefaultLatencyBudgetConfig() LatencyBudgetConfig {
+	return LatencyBudgetConfig{
+		Window:      time.Hour,
+		MinRequests: 100,
+	}
+}
+
+// ParseLatencySLO parses an SLO written as route=threshold:objective, with
+// the objective as a percentage and an optional :fast-fail suffix, such as
+// "POST /api/prompts/{slug}/render=250ms:99:fast-fail"
+func ParseLatencySLO(spec string) (LatencySLO, error) {
+	route, target, ok := strings.Cut(spec, "=")
+	if !ok {
+		return LatencySLO{}, fmt.Errorf("invalid latency SLO %q: want route=threshold:objective", spec)
+	}
+	parts := strings.Split(target, ":")
+	if len(parts) < 2 || len(parts) > 3 || (len(parts) == 3 && parts[2] != "fast-fail") {
+		return LatencySLO{}, fmt.Errorf("invalid latency SLO %q: want route=threshold:objective[:fast-fail]", spec)
+	}
+	threshold, err := time.ParseDuration(parts[0])
+	if err != nil {
+		return LatencySLO{}, fmt.Errorf("invalid latency SLO %q: %w", spec, err)
+	}
+	percent, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return LatencySLO{}, fmt.Errorf("invalid latency SLO %q: invalid objective %q", spec, parts[1])
+	}
+	return LatencySLO{
+		Route:     strings.TrimSpace(route),
+		Threshold: threshold,
+		Objective: percent / 100,
+		FastFail:  len(parts) == 3,
+	}, nil
+}
+
+// Validate reports SLOs that can't be tracked
+func (c LatencyBudgetConfig) Validate() error {
+	if c.Window < latencyBudgetBuckets*time.Second {
+		return fmt.Errorf("latency budget window must be at least %s", latencyBudgetBuckets*time.Second)
+	}
+	seen := make(map[string]bool)
+	for _, slo := range c.SLOs {
+		route := sloRoute(slo.Route)
+		switch {
+		case route == "":
+			return fmt.Errorf("latency SLO route cannot be empty")
+		case seen[route]:
+			return fmt.Errorf("duplicate latency SLO for %q", slo.Route)
+		case slo.Threshold <= 0:
+			return fmt.Errorf("latency SLO for %q: threshold must be positive", slo.Route)
+		case slo.Objective <= 0 || slo.Objective >= 1:
+			return fmt.Errorf("latency SLO for %q: objective must be between 0 and 100%%", slo.Route)
+		case slo.FastFail && !slices.Contains(fastFailRoutes, route):
+			return fmt.Errorf("latency SLO for %q: fast-fail is only supported on %s", slo.Route, strings.Join(fastFailRoutes, ", "))
+		}
+		seen[route] = true
+	}
+	return nil
+}
+
+// sloRoute maps a project route pattern onto the /api route it mirrors
+func sloRoute(pattern string) string {
+	return strings.Replace(strings.TrimSpace(pattern), " /api/projects/{project}/", " /api/", 1)
+}
+
+// latencyBudget counts good and bad requests per SLO in a ring of time
+// slices covering the window, so burn rates are cheap to read on every
+// request
+type latencyBudget struct {
+	mu          sync.Mutex
+	trackers    map[string]*sloTracker
+	width       time.Duration // time covered by one slice
+	minRequests int64
+	now         func() time.Time
+}
+
+type sloTracker struct {
+	slo        LatencySLO
+	buckets    [latencyBudgetBuckets]sloBucket
+	good       int64 // totals since startup, exported as counters
+	bad        int64
+	fastFailed int64
+}
+
+// sloBucket counts the requests seen during one slice
+type sloBucket struct {
+	slice int64 // index of the slice the bucket currently holds
+	good  int64
+	bad   int64
+}
+
+func newLatencyBudget() *latencyBudget {
+	return &latencyBudget{
+		trackers: make(map[string]*sloTracker),
+		now:      time.Now,
+	}
+}
+
+// configure replaces the tracked SLOs, resetting their budgets
+func (b *latencyBudget) configure(config LatencyBudgetConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.width = config.Window / latencyBudgetBuckets
+	b.minRequests = int64(config.MinRequests)
+	b.trackers = make(map[string]*sloTracker)
+	for _, slo := range config.SLOs {
+		b.trackers[sloRoute(slo.Route)] = &sloTracker{slo: slo}
+	}
+}
+
+// slice returns the index of the slice t falls in
+func (b *latencyBudget) slice(t time.Time) int64 {
+	return t.UnixNano() / int64(b.width)
+}
+
+// observe counts a finished request against its route's SLO
+func (b *latencyBudget) observe(route string, duration time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.trackers[route]
+	if t == nil {
+		return
+	}
+	slice := b.slice(b.now())
+	bucket := &t.buckets[slice%latencyBudgetBuckets]
+	if bucket.slice != slice {
+		*bucket = sloBucket{slice: slice}
+	}
+	if duration <= t.slo.Threshold {
+		bucket.good++
+		t.good++
+	} else {
+		bucket.bad++
+		t.bad++
+	}
+}
+
+// burnRates returns how fast the budget is being spent over the full and
+// short windows: 1 spends exactly the budget, higher runs out early. total
+// is the number of requests in the full window.
+func (b *latencyBudget) burnRates(t *sloTracker, now time.Time) (long, short float64, total int64) {
+	current := b.slice(now)
+	var good, bad, shortGood, shortBad int64
+	for _, bucket := range t.buckets {
+		age := current - bucket.slice
+		if age < 0 || age >= latencyBudgetBuckets {
+			continue
+		}
+		good += bucket.good
+		bad += bucket.bad
+		if age < latencyBudgetShortBuckets {
+			shortGood += bucket.good
+			shortBad += bucket.bad
+		}
+	}
+	burn := func(good, bad int64) float64 {
+		if good+bad == 0 {
+			return 0
+		}
+		return float64(bad) / float64(good+bad) / (1 - t.slo.Objective)
+	}
+	return burn(good, bad), burn(shortGood, shortBad), good + bad
+}
+
+// shed reports whether a request on route should fail fast, counting it if
+// so. The budget is exhausted when both windows are burning at 1 or more;
+// requiring the short window too means shedding stops once it has drained,
+// instead of lasting until the slow requests leave the full window.
+func (b *latencyBudget) shed(route string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.trackers[route]
+	if t == nil || !t.slo.FastFail {
+		return false
+	}
+	long, short, total := b.burnRates(t, b.now())
+	if total < b.minRequests || long < 1 || short < 1 {
+		return false
+	}
+	t.fastFailed++
+	return true
+}
+
+// tracks reports whether route has an SLO
+func (b *latencyBudget) tracks(route string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.trackers[route] != nil
+}
+
+// Middleware: Latency budget
+// Times requests on routes with an SLO and fails fast on render and resolve
+// routes whose budget is exhausted. It runs after routing so the route
+// pattern is known.
+func (h *Handler) latencyBudgetMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := sloRoute(reqctx.RoutePattern(r.Context()))
+		if !h.latency.tracks(route) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if h.latency.shed(route) {
+			seconds := int(math.Ceil(h.LatencyBudget.Window.Seconds() / latencyBudgetBuckets))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			h.respondError(w, http.StatusServiceUnavailable, "Latency budget exhausted for this endpoint, try again shortly")
+			return
+		}
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		h.latency.observe(route, time.Since(start))
+	})
+}
+
+var (
+	sloRequestsDesc = prometheus.NewDesc("latency_slo_requests_total",
+		"Requests on routes with a latency SLO, by whether they met its threshold", []string{"route", "result"}, nil)
+	sloBurnRateDesc = prometheus.NewDesc("latency_slo_burn_rate",
+		"Rate the latency error budget is being spent, where 1 spends exactly the budget", []string{"route", "window"}, nil)
+	sloFastFailedDesc = prometheus.NewDesc("latency_slo_fast_failed_total",
+		"Requests rejected because the route's latency budget was exhausted", []string{"route"}, nil)
+)
+
+// Describe implements prometheus.Collector
+func (b *latencyBudget) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sloRequestsDesc
+	ch <- sloBurnRateDesc
+	ch <- sloFastFailedDesc
+}
+
+// Collect implements prometheus.Collector with request counts and burn rates per SLO
+func (b *latencyBudget) Collect(ch chan<- prometheus.Metric) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	longWindow := windowLabel(b.width * latencyBudgetBuckets)
+	shortWindow := windowLabel(b.width * latencyBudgetShortBuckets)
+	for route, t := range b.trackers {
+		long, short, _ := b.burnRates(t, now)
+		ch <- prometheus.MustNewConstMetric(sloRequestsDesc, prometheus.CounterValue, float64(t.good), route, "good")
+		ch <- prometheus.MustNewConstMetric(sloRequestsDesc, prometheus.CounterValue, float64(t.bad), route, "bad")
+		ch <- prometheus.MustNewConstMetric(sloBurnRateDesc, prometheus.GaugeValue, long, route, longWindow)
+		ch <- prometheus.MustNewConstMetric(sloBurnRateDesc, prometheus.GaugeValue, short, route, shortWindow)
+		ch <- prometheus.MustNewConstMetric(sloFastFailedDesc, prometheus.CounterValue, float64(t.fastFailed), route)
+	}
+}
+
+// windowLabel formats a window without trailing zero units: "1h", not "1h0m0s"
+func windowLabel(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+// Defaults for the request size limits
+const (
+	DefaultMaxBodyBytes         = 10 << 20
+	DefaultMaxTitleLength       = 200
+	DefaultMaxDescriptionLength = 10000
+	DefaultMaxMetadataBytes     = 8 << 10
+)
+
+// Middleware: Body limit
+// Rejects a request that declares a body over MaxBodyBytes before reading
+// any of it, and cuts off one that grows past the limit while it is read, so
+// an oversized upload is never buffered whole.
+func (h *Handler) bodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := h.MaxBodyBytes
+		if limit > 0 && r.Body != nil && r.Body != http.NoBody {
+			if r.ContentLength > limit {
+				h.respondError(w, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("request body is %d bytes; the limit is %d bytes", r.ContentLength, limit))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSON decodes the request body into v. When that fails it responds
+// and returns false.
+func (h *Handler) decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		h.respondDecodeError(w, r, err)
+		return false
+	}
+	return true
+}
+
+// respondDecodeError responds 413 to a body cut off at MaxBodyBytes and 400
+// to any other body that isn't valid JSON
+func (h *Handler) respondDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		h.respondError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("request body is over the limit of %d bytes", tooLarge.Limit))
+		return
+	}
+	reqctx.Logger(r.Context()).Error("failed to decode request", "error", err)
+	h.writeError(w, http.StatusBadRequest, ErrorResponse{Code: CodeInvalidJSON, Message: "Invalid JSON"})
+}
+
+// fieldTooLong reports a title or description over MaxTitleLength or
+// MaxDescriptionLength characters, or nil
+func (h *Handler) fieldTooLong(title, description string) error {
+	if n := utf8.RuneCountInString(title); h.MaxTitleLength > 0 && n > h.MaxTitleLength {
+		return fieldErrorf("title", "title is %d characters; the limit is %d", n, h.MaxTitleLength)
+	}
+	if n := utf8.RuneCountInString(description); h.MaxDescriptionLength > 0 && n > h.MaxDescriptionLength {
+		return fieldErrorf("description", "description is %d characters; the limit is %d", n, h.MaxDescriptionLength)
+	}
+	return nil
+}
+
+// metadataTooLarge reports metadata whose JSON encoding is over
+// MaxMetadataBytes, or nil
+func (h *Handler) metadataTooLarge(metadata map[string]any) error {
+	if h.MaxMetadataBytes <= 0 || len(metadata) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fieldErrorf("metadata", "metadata can't be encoded: %v", err)
+	}
+	if len(data) > h.MaxMetadataBytes {
+		return fieldErrorf("metadata", "metadata is %d bytes; the limit is %d bytes", len(data), h.MaxMetadataBytes)
+	}
+	return nil
+}
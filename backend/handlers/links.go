@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Handler: Get a prompt's links
+// Lists the prompts it depends on and, for the reverse lookup, the prompts
+// that depend on it.
+func (h *Handler) handleGetLinks(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	result, err := h.requestStore(r).GetPromptLinks(slug)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to get links", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get links")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Replace a prompt's links
+func (h *Handler) handleSetLinks(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	var input models.SetPromptLinksInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	result, err := h.requestStore(r).SetPromptLinks(slug, input)
+	if err != nil {
+		// Checked first: a link to a missing prompt is a bad request, not a
+		// missing prompt at this URL
+		if errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to set links", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to set links")
+		return
+	}
+
+	reqctx.Logger(r.Context()).Info("prompt links changed", "slug", slug, "links", len(result.Links))
+	h.respondJSON(w, http.StatusOK, result)
+}
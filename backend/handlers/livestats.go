@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+const (
+	// liveStatsWindow is how far back GET /api/stats/live looks
+	liveStatsWindow = 5 * time.Minute
+	// liveStatsResolution is the width of each point in the returned series
+	liveStatsResolution = 10 * time.Second
+	// liveStatsTopPrompts is how many prompts are listed in top_prompts
+	liveStatsTopPrompts = 5
+)
+
+// liveStats keeps per-second request counters for the last few minutes in a
+// fixed ring buffer, so the dashboard works without an external metrics stack
+type liveStats struct {
+	mu      sync.Mutex
+	buckets []liveBucket
+	now     func() time.Time
+}
+
+// liveBucket aggregates the requests seen during one second
+type liveBucket struct {
+	second       int64 // unix second the bucket currently holds
+	requests     int
+	errors       int // 5xx responses
+	clientErrors int // 4xx responses
+	prompts      map[liveStatsPrompt]int
+}
+
+// liveStatsPrompt identifies a prompt across projects
+type liveStatsPrompt struct {
+	project, slug string
+}
+
+func newLiveStats() *liveStats {
+	return &liveStats{
+		buckets: make([]liveBucket, int(liveStatsWindow/time.Second)),
+		now:     time.Now,
+	}
+}
+
+// record counts one finished request. slug is empty for non-prompt routes,
+// and project is empty for routes in the default project.
+func (ls *liveStats) record(status int, project, slug string) {
+	second := ls.now().Unix()
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	b := &ls.buckets[second%int64(len(ls.buckets))]
+	if b.second != second {
+		*b = liveBucket{second: second}
+	}
+	b.requests++
+	switch {
+	case status >= 500:
+		b.errors++
+	case status >= 400:
+		b.clientErrors++
+	}
+	if slug != "" {
+		if b.prompts == nil {
+			b.prompts = make(map[liveStatsPrompt]int)
+		}
+		if project == "" {
+			project = store.DefaultProject
+		}
+		b.prompts[liveStatsPrompt{project, slug}]++
+	}
+}
+
+// snapshot summarizes the buckets that fall inside the window. top_prompts
+// only lists prompts visible reports true for.
+func (ls *liveStats) snapshot(visible func(project, slug string) bool) models.LiveStats {
+	now := ls.now().Unix()
+	window := int64(len(ls.buckets))
+	step := int64(liveStatsResolution / time.Second)
+	oldest := now - window + 1
+
+	stats := models.LiveStats{
+		WindowSeconds: int(window),
+		Series:        make([]models.LiveStatsPoint, window/step),
+	}
+	for i := range stats.Series {
+		stats.Series[i].Time = time.Unix(oldest+int64(i)*step, 0).UTC()
+	}
+	hits := make(map[liveStatsPrompt]int)
+
+	ls.mu.Lock()
+	for _, b := range ls.buckets {
+		if b.second < oldest || b.second > now {
+			continue
+		}
+		stats.Requests += b.requests
+		stats.Errors += b.errors
+		stats.ClientErrors += b.clientErrors
+		point := &stats.Series[(b.second-oldest)/step]
+		point.Requests += b.requests
+		point.Errors += b.errors
+		for prompt, n := range b.prompts {
+			hits[prompt] += n
+		}
+	}
+	ls.mu.Unlock()
+
+	stats.RequestsPerSecond = float64(stats.Requests) / float64(window)
+	if stats.Requests > 0 {
+		stats.ErrorRate = float64(stats.Errors) / float64(stats.Requests)
+	}
+
+	top := make([]models.PromptHits, 0, len(hits))
+	for prompt, n := range hits {
+		top = append(top, models.PromptHits{Project: prompt.project, Slug: prompt.slug, Requests: n})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Requests != top[j].Requests {
+			return top[i].Requests > top[j].Requests
+		}
+		if top[i].Project != top[j].Project {
+			return top[i].Project < top[j].Project
+		}
+		return top[i].Slug < top[j].Slug
+	})
+	stats.TopPrompts = make([]models.PromptHits, 0, liveStatsTopPrompts)
+	for _, hit := range top {
+		if len(stats.TopPrompts) == liveStatsTopPrompts {
+			break
+		}
+		if visible(hit.Project, hit.Slug) {
+			stats.TopPrompts = append(stats.TopPrompts, hit)
+		}
+	}
+	return stats
+}
+
+// Handler: Live stats
+// Counts cover every request, but top_prompts leaves out prompts the caller
+// can't read.
+func (h *Handler) handleLiveStats(w http.ResponseWriter, r *http.Request) {
+	s := h.Store.WithContext(r.Context())
+	h.respondJSON(w, http.StatusOK, h.live.snapshot(func(project, slug string) bool {
+		ok, err := canReadPrompt(s, project, slug)
+		if err != nil {
+			reqctx.Logger(r.Context()).Error("failed to authorize prompt", "error", err, "project", project, "slug", slug)
+		}
+		return ok
+	}))
+}
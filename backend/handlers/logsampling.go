@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+// logSampler decides which requests get an access log line. Successful reads
+// are logged 1 in rate per route; writes and errors are always logged.
+// Counters are keyed by the mux route pattern rather than the raw path, so
+// slugs and versions can't grow them without bound.
+type logSampler struct {
+	mu         sync.Mutex
+	seen       map[string]int64
+	suppressed map[string]int64
+}
+
+func newLogSampler() *logSampler {
+	return &logSampler{
+		seen:       make(map[string]int64),
+		suppressed: make(map[string]int64),
+	}
+}
+
+// sample reports whether a request should be logged, and how many requests
+// the logged line stands for
+func (s *logSampler) sample(r *http.Request, status, rate int) (bool, int) {
+	if rate <= 1 || status >= 400 || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return true, 1
+	}
+	route := reqctx.RoutePattern(r.Context())
+	if route == "" {
+		route = "unmatched"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen[route]++
+	// The first request on each route is logged so new traffic is visible at once
+	if s.seen[route]%int64(rate) == 1 {
+		return true, rate
+	}
+	s.suppressed[route]++
+	return false, rate
+}
+
+var logsSuppressedDesc = prometheus.NewDesc("http_request_logs_suppressed_total",
+	"Access log lines skipped by sampling, by route", []string{"route"}, nil)
+
+// Describe implements prometheus.Collector
+func (s *logSampler) Describe(ch chan<- *prometheus.Desc) {
+	ch <- logsSuppressedDesc
+}
+
+// Collect implements prometheus.Collector with suppressed access log lines per route
+func (s *logSampler) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for route, count := range s.suppressed {
+		ch <- prometheus.MustNewConstMetric(logsSuppressedDesc, prometheus.CounterValue, float64(count), route)
+	}
+}
@@ -1,64 +1,113 @@
 package handlers
 
 import (
-	"fmt"
-	"sync/atomic"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// Metrics holds application metrics using atomic counters
+// Metrics holds application metrics in a Prometheus registry of its own, so
+// handlers created side by side (as in tests) don't share series
 type Metrics struct {
-	promptsCreated        atomic.Int64
-	promptVersionsCreated atomic.Int64
-	httpRequests          atomic.Int64
-	httpErrors            atomic.Int64
+	registry *prometheus.Registry
+
+	promptsCreated        prometheus.Counter
+	promptVersionsCreated prometheus.Counter
+	httpRequests          *prometheus.CounterVec
+	httpErrors            prometheus.Counter
+	httpDuration          *prometheus.HistogramVec
+	dbDuration            *prometheus.HistogramVec
+	handler               http.Handler
 }
 
-// NewMetrics creates a new Metrics instance
+// NewMetrics creates a new Metrics instance with Go runtime and process
+// metrics included
 func NewMetrics() *Metrics {
-	return &Metrics{}
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		promptsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prompts_created_total",
+			Help: "Total number of prompts created",
+		}),
+		promptVersionsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prompt_versions_created_total",
+			Help: "Total number of prompt versions created",
+		}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests by route, method, and status",
+		}, []string{"route", "method", "status"}),
+		httpErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "http_errors_total",
+			Help: "Total number of HTTP errors",
+		}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route, method, and status",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		// SQLite operations mostly finish well under a millisecond, so the
+		// buckets start at 100µs and double up to about 3s
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_operation_duration_seconds",
+			Help:    "Database operation latency by operation",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 16),
+		}, []string{"operation"}),
+	}
+	m.registry.MustRegister(
+		m.promptsCreated,
+		m.promptVersionsCreated,
+		m.httpRequests,
+		m.httpErrors,
+		m.httpDuration,
+		m.dbDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	m.handler = promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	return m
+}
+
+// Register adds collectors to the registry served on /metrics
+func (m *Metrics) Register(cs ...prometheus.Collector) {
+	m.registry.MustRegister(cs...)
 }
 
 // IncrementPromptsCreated increments the prompts created counter
 func (m *Metrics) IncrementPromptsCreated() {
-	m.promptsCreated.Add(1)
+	m.promptsCreated.Inc()
 }
 
 // IncrementPromptVersionsCreated increments the prompt versions created counter
 func (m *Metrics) IncrementPromptVersionsCreated() {
-	m.promptVersionsCreated.Add(1)
+	m.promptVersionsCreated.Inc()
 }
 
-// IncrementHTTPRequests increments the HTTP requests counter
-func (m *Metrics) IncrementHTTPRequests() {
-	m.httpRequests.Add(1)
+// ObserveHTTPRequest counts a finished request and records its latency. route
+// is the mux pattern it matched, so slugs and versions can't grow the series
+// without bound.
+func (m *Metrics) ObserveHTTPRequest(route, method string, status int, duration time.Duration) {
+	labels := prometheus.Labels{"route": route, "method": method, "status": strconv.Itoa(status)}
+	m.httpRequests.With(labels).Inc()
+	m.httpDuration.With(labels).Observe(duration.Seconds())
 }
 
 // IncrementHTTPErrors increments the HTTP errors counter
 func (m *Metrics) IncrementHTTPErrors() {
-	m.httpErrors.Add(1)
+	m.httpErrors.Inc()
 }
 
-// ExportPrometheus returns metrics in Prometheus text format
-func (m *Metrics) ExportPrometheus() string {
-	return fmt.Sprintf(`# HELP prompts_created_total Total number of prompts created
-# TYPE prompts_created_total counter
-prompts_created_total %d
-
-# HELP prompt_versions_created_total Total number of prompt versions created
-# TYPE prompt_versions_created_total counter
-prompt_versions_created_total %d
-
-# HELP http_requests_total Total number of HTTP requests
-# TYPE http_requests_total counter
-http_requests_total %d
+// ObserveDBOperation records how long a database operation took. It matches
+// store.OperationObserver.
+func (m *Metrics) ObserveDBOperation(operation string, duration time.Duration) {
+	m.dbDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
 
-# HELP http_errors_total Total number of HTTP errors
-# TYPE http_errors_total counter
-http_errors_total %d
-`,
-		m.promptsCreated.Load(),
-		m.promptVersionsCreated.Load(),
-		m.httpRequests.Load(),
-		m.httpErrors.Load(),
-	)
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return m.handler
 }
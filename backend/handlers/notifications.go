@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/shahram/prompt-registry/backend/diff"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/notify"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// SlackConfig chooses the Slack channels notifications go to
+type SlackConfig struct {
+	// WebhookURL is the incoming webhook for projects without their own
+	WebhookURL string
+	// ProjectWebhookURLs maps a project to its own incoming webhook
+	ProjectWebhookURLs map[string]string
+}
+
+// Notifications posts chat messages about new versions, rollbacks, and
+// deletions, so teams see prompt changes that affect their services. A
+// project's messages go to its own Slack webhook when it has one, and to
+// the default webhook otherwise. Deliveries go through Integrations, like
+// webhooks.
+type Notifications struct {
+	mu        sync.RWMutex // guards config and notifiers
+	config    SlackConfig
+	notifiers map[string]notify.Notifier // by project; "" is the default
+
+	logger       *slog.Logger
+	integrations *Integrations
+}
+
+// NewNotifications creates a dispatcher with no channels that delivers through in
+func NewNotifications(in *Integrations, logger *slog.Logger) *Notifications {
+	return &Notifications{
+		notifiers:    make(map[string]notify.Notifier),
+		logger:       logger,
+		integrations: in,
+	}
+}
+
+// Configure replaces the Slack webhooks. Messages already queued still go
+// where they were sent.
+func (n *Notifications) Configure(cfg SlackConfig) {
+	notifiers := make(map[string]notify.Notifier)
+	if cfg.WebhookURL != "" {
+		notifiers[""] = notify.NewSlack(cfg.WebhookURL)
+	}
+	for project, webhookURL := range cfg.ProjectWebhookURLs {
+		notifiers[project] = notify.NewSlack(webhookURL)
+	}
+	if len(notifiers) > 0 {
+		n.integrations.Register(IntegrationSlack)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.config = SlackConfig{WebhookURL: cfg.WebhookURL, ProjectWebhookURLs: maps.Clone(cfg.ProjectWebhookURLs)}
+	n.notifiers = notifiers
+}
+
+// settings returns the Slack webhooks in effect
+func (n *Notifications) settings() SlackConfig {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.config
+}
+
+// notifier returns where project's messages go, or nil if nowhere
+func (n *Notifications) notifier(project string) notify.Notifier {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if target, ok := n.notifiers[project]; ok {
+		return target
+	}
+	return n.notifiers[""]
+}
+
+// Send posts msg to the channel for its project. Rate limits and server
+// errors are retried by Integrations; other rejections are permanent.
+func (n *Notifications) Send(msg notify.Message) {
+	target := n.notifier(msg.Project)
+	if target == nil {
+		return
+	}
+	n.integrations.Go(IntegrationSlack, "project "+msg.Project, func() error {
+		err := target.Notify(context.Background(), msg)
+		var rejected *notify.StatusError
+		if errors.As(err, &rejected) && !rejected.Temporary() {
+			n.logger.Warn("slack notification rejected", "project", msg.Project, "event", msg.Event,
+				"slug", msg.Slug, "status", rejected.StatusCode)
+			return Permanent(err)
+		}
+		return err
+	})
+}
+
+// chatVersionCreated announces version to of slug, with a summary and diff
+// of the changes since version from
+func (h *Handler) chatVersionCreated(s store.Store, slug string, from, to int) {
+	if h.Notifications.notifier(s.Project()) == nil {
+		return
+	}
+	msg := notify.Message{
+		Event:   notify.EventVersionCreated,
+		Project: s.Project(),
+		Slug:    slug,
+		Version: to,
+		Title:   fmt.Sprintf("New version of %s/%s: v%d", s.Project(), slug, to),
+		URL:     h.promptLink(s.Project(), slug),
+	}
+	h.chatChanges(s, &msg, from, to)
+	h.Notifications.Send(msg)
+}
+
+// chatRolledBack announces each label a release rollback moved back, with
+// the diff from the version it left to the version it restored
+func (h *Handler) chatRolledBack(s store.Store, release models.Release) {
+	if h.Notifications.notifier(s.Project()) == nil {
+		return
+	}
+	for _, item := range release.Items {
+		msg := notify.Message{
+			Event:   notify.EventRolledBack,
+			Project: s.Project(),
+			Slug:    item.Slug,
+			Version: item.PreviousVersion,
+			Actor:   release.RolledBackBy,
+			URL:     h.promptLink(s.Project(), item.Slug),
+		}
+		if item.PreviousVersion > 0 {
+			msg.Title = fmt.Sprintf("Rolled back %s of %s/%s to v%d from v%d",
+				release.Label, s.Project(), item.Slug, item.PreviousVersion, item.Version)
+			h.chatChanges(s, &msg, item.Version, item.PreviousVersion)
+		} else {
+			msg.Title = fmt.Sprintf("Rolled back %s of %s/%s: the label no longer points at v%d",
+				release.Label, s.Project(), item.Slug, item.Version)
+		}
+		h.Notifications.Send(msg)
+	}
+}
+
+// chatDeleted announces a prompt deleted with its whole history
+func (h *Handler) chatDeleted(deleted models.DeletedPrompt) {
+	h.Notifications.Send(notify.Message{
+		Event:   notify.EventDeleted,
+		Project: deleted.Project,
+		Slug:    deleted.Slug,
+		Title:   fmt.Sprintf("Deleted %s/%s and its %d versions", deleted.Project, deleted.Slug, deleted.Versions),
+	})
+}
+
+// chatChanges adds the change summary and diff between two versions to msg,
+// and the author of the later one when it was created by this change
+func (h *Handler) chatChanges(s store.Store, msg *notify.Message, from, to int) {
+	after, err := s.GetPromptVersion(msg.Slug, to)
+	if err != nil {
+		h.Logger.Error("failed to load version for notification", "error", err, "slug", msg.Slug, "version", to)
+		return
+	}
+	if msg.Event == notify.EventVersionCreated {
+		msg.Actor = after.CreatedBy
+	}
+	var before models.PromptVersion
+	if from > 0 {
+		if before, err = s.GetPromptVersion(msg.Slug, from); err != nil {
+			h.Logger.Error("failed to load version for notification", "error", err, "slug", msg.Slug, "version", from)
+			return
+		}
+	}
+	msg.Summary = versionChanges(before, after).Summary
+	msg.Diff = diff.Unified(
+		fmt.Sprintf("%s@v%d", msg.Slug, before.VersionNumber),
+		fmt.Sprintf("%s@v%d", msg.Slug, after.VersionNumber),
+		before.Content, after.Content, defaultDiffContext)
+}
+
+// promptLink returns the web UI page for a prompt. The UI shows the default
+// project, so prompts in other projects link to their API resource instead.
+func (h *Handler) promptLink(project, slug string) string {
+	base := strings.TrimRight(h.BaseURL, "/") + h.basePath()
+	if project == store.DefaultProject {
+		return base + "/prompts/" + url.PathEscape(slug)
+	}
+	return base + "/api/projects/" + url.PathEscape(project) + "/prompts/" + url.PathEscape(slug)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+)
+
+// openAPISpec is maintained alongside the models; handlers_test.go checks
+// that its schemas stay in sync with the Go types' JSON fields.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// swaggerUIHTML renders the spec with Swagger UI loaded from a CDN (no build step)
+const swaggerUIHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Prompt Registry API</title>
+    <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+    <div id="swagger-ui"></div>
+    <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+    <script>
+        window.onload = () => {
+            window.ui = SwaggerUIBundle({ url: '{{BASE_PATH}}/openapi.json', dom_id: '#swagger-ui' });
+        };
+    </script>
+</body>
+</html>
+`
+
+// Handler: OpenAPI specification
+func (h *Handler) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
+
+// Handler: Swagger UI
+func (h *Handler) handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(strings.Replace(swaggerUIHTML, "{{BASE_PATH}}", h.basePath(), 1)))
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Handler: Create organization
+func (h *Handler) handleCreateOrg(w http.ResponseWriter, r *http.Request) {
+	var input models.CreateOrgInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	result, err := h.requestStore(r).CreateOrg(input)
+	if err != nil {
+		h.respondOrgError(w, err, "Failed to create org")
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, result)
+}
+
+// Handler: List the caller's organizations
+func (h *Handler) handleListOrgs(w http.ResponseWriter, r *http.Request) {
+	results, err := h.requestStore(r).ListOrgs()
+	if err != nil {
+		h.respondOrgError(w, err, "Failed to list orgs")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// Handler: Get organization
+func (h *Handler) handleGetOrg(w http.ResponseWriter, r *http.Request) {
+	result, err := h.requestStore(r).GetOrg(r.PathValue("org"))
+	if err != nil {
+		h.respondOrgError(w, err, "Failed to get org")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Invite organization member
+func (h *Handler) handleAddOrgMember(w http.ResponseWriter, r *http.Request) {
+	var input models.AddOrgMemberInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	result, err := h.requestStore(r).AddOrgMember(r.PathValue("org"), input)
+	if err != nil {
+		h.respondOrgError(w, err, "Failed to add org member")
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, result)
+}
+
+// Handler: Remove organization member
+func (h *Handler) handleRemoveOrgMember(w http.ResponseWriter, r *http.Request) {
+	if err := h.requestStore(r).RemoveOrgMember(r.PathValue("org"), r.PathValue("subject")); err != nil {
+		h.respondOrgError(w, err, "Failed to remove org member")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler: Move a project into an organization
+func (h *Handler) handleAssignProject(w http.ResponseWriter, r *http.Request) {
+	if err := h.requestStore(r).AssignProject(r.PathValue("org"), r.PathValue("project")); err != nil {
+		h.respondOrgError(w, err, "Failed to assign project")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler: Release a project from an organization
+func (h *Handler) handleReleaseProject(w http.ResponseWriter, r *http.Request) {
+	if err := h.requestStore(r).ReleaseProject(r.PathValue("org"), r.PathValue("project")); err != nil {
+		h.respondOrgError(w, err, "Failed to release project")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondOrgError maps an org store error to a status code. Orgs the caller
+// isn't a member of are reported as not found so their names don't leak.
+func (h *Handler) respondOrgError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		h.respondErrorFrom(w, http.StatusNotFound, err)
+	case errors.Is(err, store.ErrPermission):
+		h.respondErrorFrom(w, http.StatusForbidden, err)
+	case errors.Is(err, store.ErrConflict):
+		h.respondErrorFrom(w, http.StatusConflict, err)
+	case errors.Is(err, store.ErrValidation):
+		h.respondErrorFrom(w, http.StatusBadRequest, err)
+	default:
+		h.Logger.Error(strings.ToLower(message), "error", err)
+		h.respondError(w, http.StatusInternalServerError, message)
+	}
+}
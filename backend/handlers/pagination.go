@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Pagination response headers, set on paged list endpoints. Bodies stay bare
+// arrays so existing clients keep working.
+const (
+	headerTotalCount = "X-Total-Count"
+	headerLimit      = "X-Limit"
+	headerOffset     = "X-Offset"
+)
+
+// setPaginationHeaders describes the page of a list at limit and offset out of
+// total items, with an RFC 8288 Link header pointing at the next and previous
+// pages. Links keep the request's other query parameters.
+func setPaginationHeaders(w http.ResponseWriter, r *http.Request, total, limit, offset int) {
+	offset = max(offset, 0)
+	w.Header().Set(headerTotalCount, strconv.Itoa(total))
+	w.Header().Set(headerLimit, strconv.Itoa(limit))
+	w.Header().Set(headerOffset, strconv.Itoa(offset))
+	w.Header().Set("Access-Control-Expose-Headers", strings.Join([]string{
+		headerTotalCount, headerLimit, headerOffset, "Link",
+	}, ", "))
+
+	// A non-positive limit returns everything after offset, so there are no pages
+	if limit <= 0 {
+		return
+	}
+	var links []string
+	if offset+limit < total {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(r, limit, offset+limit)))
+	}
+	if offset > 0 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(r, limit, max(offset-limit, 0))))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageURL returns the request's path, including any base path, and query with
+// limit and offset replaced
+func pageURL(r *http.Request, limit, offset int) string {
+	query := r.URL.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	return basePathFrom(r.Context()) + r.URL.Path + "?" + query.Encode()
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Middleware: Project name validation and access for /api/projects/{project} routes
+// Projects owned by an org the caller doesn't belong to are reported as not found.
+func (h *Handler) projectMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := store.ValidateProject(r.PathValue("project")); err != nil {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := h.requestStore(r).AuthorizeProject(); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				h.respondErrorFrom(w, http.StatusNotFound, err)
+				return
+			}
+			reqctx.Logger(r.Context()).Error("failed to authorize project", "error", err, "project", r.PathValue("project"))
+			h.respondError(w, http.StatusInternalServerError, "Failed to authorize project")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestStore returns the store scoped to the request's project, or the
+// default project for routes without one, with writes attributed to the
+// authenticated caller
+func (h *Handler) requestStore(r *http.Request) store.Store {
+	s := h.Store.WithContext(r.Context())
+	if project := r.PathValue("project"); project != "" {
+		return s.InProject(project)
+	}
+	return s
+}
+
+// Handler: List projects
+func (h *Handler) handleListProjects(w http.ResponseWriter, r *http.Request) {
+	projects, err := h.requestStore(r).ListProjects()
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to list projects", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list projects")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, projects)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// Provenance response headers, set on render, execute, and version lookups
+const (
+	headerPromptRegistry    = "X-Prompt-Registry"
+	headerPromptProject     = "X-Prompt-Project"
+	headerPromptSlug        = "X-Prompt-Slug"
+	headerPromptVersion     = "X-Prompt-Version"
+	headerPromptContentHash = "X-Prompt-Content-Hash"
+)
+
+// provenance identifies the exact version behind a response. The hash is the
+// one stored with the version's template, so it is the same however the
+// prompt was rendered.
+func (h *Handler) provenance(r *http.Request, slug string, version models.PromptVersion) models.Provenance {
+	return models.Provenance{
+		Registry:    strings.TrimRight(h.BaseURL, "/"),
+		Project:     h.requestStore(r).Project(),
+		Slug:        slug,
+		Version:     version.VersionNumber,
+		ContentHash: "sha256:" + version.ContentSHA256,
+	}
+}
+
+// setProvenanceHeaders adds provenance headers, exposed to browser clients
+func setProvenanceHeaders(w http.ResponseWriter, p models.Provenance) {
+	w.Header().Set(headerPromptRegistry, p.Registry)
+	w.Header().Set(headerPromptProject, p.Project)
+	w.Header().Set(headerPromptSlug, p.Slug)
+	w.Header().Set(headerPromptVersion, strconv.Itoa(p.Version))
+	w.Header().Set(headerPromptContentHash, p.ContentHash)
+	w.Header().Set("Access-Control-Expose-Headers", strings.Join([]string{
+		"ETag", headerPromptRegistry, headerPromptProject, headerPromptSlug, headerPromptVersion, headerPromptContentHash,
+	}, ", "))
+}
+
+// provenanceComment is the marker appended to rendered content on request,
+// so the version travels with the text into downstream logs
+func provenanceComment(p models.Provenance) string {
+	return fmt.Sprintf("<!-- prompt-registry: %s project=%s slug=%s version=%d %s -->",
+		p.Registry, p.Project, p.Slug, p.Version, p.ContentHash)
+}
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/shahram/prompt-registry/backend/gallery"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+const (
+	// galleryTitle is the title of the gallery's HTML pages
+	galleryTitle = "Public Prompt Library"
+	// sitemapPageSize is how many prompts are fetched per store call when building the sitemap
+	sitemapPageSize = 500
+	// sitemapMaxURLs is the sitemap protocol's per-file URL limit
+	sitemapMaxURLs = 50000
+)
+
+// PublicConfig controls the anonymous read-only public gallery
+type PublicConfig struct {
+	Enabled       bool
+	Prefix        string // route prefix, e.g. "/public"
+	RatePerMinute int    // sustained requests per minute per client IP
+	Burst         int    // requests allowed in a single burst per client IP
+}
+
+// DefaultPublicConfig returns the gallery defaults (disabled)
+func DefaultPublicConfig() PublicConfig {
+	return PublicConfig{
+		Enabled:       false,
+		Prefix:        "/public",
+		RatePerMinute: 60,
+		Burst:         20,
+	}
+}
+
+// mountPublicRoutes registers the read-only gallery routes under the configured prefix.
+// Only GET endpoints are mounted; every route is rate limited per client IP.
+func (h *Handler) mountPublicRoutes(mux *http.ServeMux) {
+	prefix := h.publicPrefix()
+	limiter := newRateLimiter(float64(h.Public.RatePerMinute)/60, h.Public.Burst)
+	limited := func(fn http.HandlerFunc) http.Handler {
+		return h.rateLimitMiddleware(limiter, fn)
+	}
+
+	mux.Handle("GET "+prefix+"/api/prompts", limited(h.handlePublicListPrompts))
+	mux.Handle("GET "+prefix+"/api/prompts/{slug}", limited(h.handlePublicGetPrompt))
+	mux.Handle("GET "+prefix+"/api/prompts/{slug}/versions", limited(h.handlePublicListVersions))
+	mux.Handle("GET "+prefix+"/api/prompts/{slug}/versions/{version}", limited(h.handlePublicGetVersion))
+
+	// Crawlable pages and SEO metadata
+	mux.Handle("GET "+prefix+"/{$}", limited(h.handleGalleryIndex))
+	mux.Handle("GET "+prefix+"/prompts/{slug}", limited(h.handleGalleryPrompt))
+	mux.Handle("GET /sitemap.xml", limited(h.handleSitemap))
+	mux.Handle("GET /robots.txt", limited(h.handleRobots))
+}
+
+// publicPrefix returns the normalized gallery route prefix ("/public")
+func (h *Handler) publicPrefix() string {
+	return "/" + strings.Trim(h.Public.Prefix, "/")
+}
+
+// canonicalURL builds an absolute URL on BaseURL for a gallery path
+func (h *Handler) canonicalURL(path string) string {
+	return strings.TrimRight(h.BaseURL, "/") + path
+}
+
+// Handler: Set prompt visibility
+func (h *Handler) handleSetVisibility(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	var input models.SetVisibilityInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	if err := h.requestStore(r).SetPromptVisibility(slug, input.Public); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to set visibility", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to set visibility")
+		return
+	}
+
+	h.notifyWebhooks(h.requestStore(r), WebhookPromptUpdated, slug, 0)
+	h.respondJSON(w, http.StatusOK, map[string]any{"slug": slug, "public": input.Public})
+}
+
+// Handler: List public prompts
+func (h *Handler) handlePublicListPrompts(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	offset := 0
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if val, err := strconv.Atoi(limitStr); err == nil && val > 0 && val <= 100 {
+			limit = val
+		}
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if val, err := strconv.Atoi(offsetStr); err == nil && val >= 0 {
+			offset = val
+		}
+	}
+
+	results, err := h.Store.ListPublicPrompts(limit, offset)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to list public prompts", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list prompts")
+		return
+	}
+	total, err := h.Store.CountPublicPrompts()
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to count public prompts", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list prompts")
+		return
+	}
+
+	setPaginationHeaders(w, r, total, limit, offset)
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// Handler: Get public prompt by slug
+func (h *Handler) handlePublicGetPrompt(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	result, ok := h.lookupPublicPrompt(w, r, slug)
+	if !ok {
+		return
+	}
+
+	h.respondJSONWithETag(w, r, result)
+}
+
+// Handler: List versions of a public prompt
+func (h *Handler) handlePublicListVersions(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	if _, ok := h.lookupPublicPrompt(w, r, slug); !ok {
+		return
+	}
+
+	limit := 100
+	offset := 0
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if val, err := strconv.Atoi(limitStr); err == nil && val > 0 && val <= 100 {
+			limit = val
+		}
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if val, err := strconv.Atoi(offsetStr); err == nil && val >= 0 {
+			offset = val
+		}
+	}
+
+	results, err := h.Store.ListPublicPromptVersions(slug, limit, offset)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to list versions", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list versions")
+		return
+	}
+	total, err := h.Store.CountPublicPromptVersions(slug)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to count versions", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list versions")
+		return
+	}
+
+	versions := make([]models.PublicPromptVersion, len(results))
+	for i, v := range results {
+		versions[i] = publicVersion(v)
+	}
+
+	setPaginationHeaders(w, r, total, limit, offset)
+	h.respondJSON(w, http.StatusOK, versions)
+}
+
+// Handler: Get specific version of a public prompt
+func (h *Handler) handlePublicGetVersion(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	selector := r.PathValue("version")
+
+	if _, ok := h.lookupPublicPrompt(w, r, slug); !ok {
+		return
+	}
+
+	result, err := selectPublicVersion(h.Store, slug, selector)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, errInvalidVersion):
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+		default:
+			reqctx.Logger(r.Context()).Error("failed to get version", "error", err, "slug", slug, "version", selector)
+			h.respondError(w, http.StatusInternalServerError, "Failed to get version")
+		}
+		return
+	}
+
+	h.respondJSONWithETag(w, r, publicVersion(result))
+}
+
+// selectPublicVersion resolves a version selector like selectVersion, but
+// as if versions pending review didn't exist: "latest" is the newest version
+// that isn't pending, and a pending version number is not found. The current
+// version is never pending.
+func selectPublicVersion(s store.Store, slug, selector string) (models.PromptVersion, error) {
+	switch selector {
+	case versionLatest:
+		return s.GetLatestPublicPromptVersion(slug)
+	case versionCurrent:
+		return selectVersion(s, slug, selector)
+	}
+	version, err := strconv.Atoi(selector)
+	if err != nil {
+		return models.PromptVersion{}, fmt.Errorf("%w %q: want a version number, %q, or %q", errInvalidVersion, selector, versionLatest, versionCurrent)
+	}
+	return s.GetPublicPromptVersion(slug, version)
+}
+
+// lookupPublicPrompt fetches a prompt and responds 404 unless it is public and not archived.
+// Private prompts are reported exactly like missing ones so their slugs don't leak.
+func (h *Handler) lookupPublicPrompt(w http.ResponseWriter, r *http.Request, slug string) (models.PublicPrompt, bool) {
+	result, err := h.Store.GetPromptBySlug(slug)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		reqctx.Logger(r.Context()).Error("failed to get prompt", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get prompt")
+		return models.PublicPrompt{}, false
+	}
+	if err != nil || !result.Public || result.ArchivedAt != nil {
+		h.writeError(w, http.StatusNotFound, ErrorResponse{Code: CodePromptNotFound, Message: fmt.Sprintf("prompt with slug %q not found", slug)})
+		return models.PublicPrompt{}, false
+	}
+	return models.PublicPrompt{
+		Slug:           result.Slug,
+		Title:          result.Title,
+		Description:    result.Description,
+		Format:         result.Format,
+		CurrentVersion: publicVersion(result.CurrentVersion),
+	}, true
+}
+
+// publicVersion keeps the fields of a version the public gallery shows
+func publicVersion(v models.PromptVersion) models.PublicPromptVersion {
+	return models.PublicPromptVersion{
+		VersionNumber: v.VersionNumber,
+		Content:       v.Content,
+		Messages:      v.Messages,
+		ContentSHA256: v.ContentSHA256,
+		CreatedAt:     v.CreatedAt,
+	}
+}
+
+// Handler: Public gallery index page
+func (h *Handler) handleGalleryIndex(w http.ResponseWriter, r *http.Request) {
+	prompts, err := h.Store.ListPublicPrompts(sitemapPageSize, 0)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to list public prompts", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list prompts")
+		return
+	}
+
+	h.renderGallery(w, r, "index", map[string]any{
+		"Title":     galleryTitle,
+		"Prefix":    h.basePath() + h.publicPrefix(),
+		"Canonical": h.canonicalURL(h.publicPrefix() + "/"),
+		"Prompts":   prompts,
+	})
+}
+
+// Handler: Public gallery prompt page
+func (h *Handler) handleGalleryPrompt(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	prompt, ok := h.lookupPublicPrompt(w, r, slug)
+	if !ok {
+		return
+	}
+
+	h.renderGallery(w, r, "prompt", map[string]any{
+		"Title":     galleryTitle,
+		"Prefix":    h.basePath() + h.publicPrefix(),
+		"Canonical": h.canonicalURL(h.publicPrefix() + "/prompts/" + prompt.Slug),
+		"Prompt":    prompt,
+	})
+}
+
+// renderGallery executes a gallery template, buffering so errors still produce a clean 500
+func (h *Handler) renderGallery(w http.ResponseWriter, r *http.Request, name string, data map[string]any) {
+	var buf strings.Builder
+	if err := gallery.Templates.ExecuteTemplate(&buf, name, data); err != nil {
+		reqctx.Logger(r.Context()).Error("failed to render gallery page", "error", err, "template", name)
+		h.respondError(w, http.StatusInternalServerError, "Failed to render page")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(buf.String()))
+}
+
+// sitemapURLSet is the root element of a sitemap.xml document
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Handler: Sitemap of public gallery pages
+func (h *Handler) handleSitemap(w http.ResponseWriter, r *http.Request) {
+	prefix := h.publicPrefix()
+	urlset := sitemapURLSet{
+		Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		URLs:  []sitemapURL{{Loc: h.canonicalURL(prefix + "/")}},
+	}
+
+	for offset := 0; len(urlset.URLs) < sitemapMaxURLs; offset += sitemapPageSize {
+		prompts, err := h.Store.ListPublicPrompts(sitemapPageSize, offset)
+		if err != nil {
+			reqctx.Logger(r.Context()).Error("failed to list public prompts", "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to build sitemap")
+			return
+		}
+		for _, p := range prompts {
+			urlset.URLs = append(urlset.URLs, sitemapURL{
+				Loc:     h.canonicalURL(prefix + "/prompts/" + p.Slug),
+				LastMod: p.UpdatedAt.UTC().Format("2006-01-02"),
+			})
+		}
+		if len(prompts) < sitemapPageSize {
+			break
+		}
+	}
+	if len(urlset.URLs) > sitemapMaxURLs {
+		urlset.URLs = urlset.URLs[:sitemapMaxURLs]
+	}
+
+	body, err := xml.MarshalIndent(urlset, "", "  ")
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to encode sitemap", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to build sitemap")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+// Handler: robots.txt allowing only the public gallery
+func (h *Handler) handleRobots(w http.ResponseWriter, r *http.Request) {
+	prefix := h.publicPrefix()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "User-agent: *\nAllow: %s%s/\nDisallow: /\nSitemap: %s\n", h.basePath(), prefix, h.canonicalURL("/sitemap.xml"))
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+// bucketIdleTTL is the longest an untouched bucket is kept before being
+// swept, even when it would take longer to refill
+const bucketIdleTTL = 10 * time.Minute
+
+// RateLimitConfig throttles /api/* requests with a token bucket per API
+// caller. Authenticated callers are keyed by subject, others by client IP
+// (see Handler.TrustedProxies).
+type RateLimitConfig struct {
+	RequestsPerSecond float64 // sustained rate; 0 disables the limit
+	Burst             int     // requests allowed in a single burst
+}
+
+// DefaultRateLimitConfig returns the API rate limit defaults (disabled)
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		RequestsPerSecond: 0,
+		Burst:             20,
+	}
+}
+
+// rateLimiter is a per-key token bucket limiter
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// newRateLimiter creates a limiter refilling at rate tokens per second up to burst
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// configure changes the rate and burst. Buckets keep their tokens, capped at
+// the new burst, and are dropped when the limit is turned off.
+func (l *rateLimiter) configure(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = float64(max(burst, 1))
+	if rate <= 0 {
+		clear(l.buckets)
+		return
+	}
+	for _, b := range l.buckets {
+		b.tokens = math.Min(b.tokens, l.burst)
+	}
+}
+
+// allow consumes a token for key, returning how long to wait when none are
+// left. A non-positive rate allows every request.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.lastSeen).Seconds()*l.rate)
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have been idle long enough to be full again,
+// which a new bucket would be too, so callers that stop sending don't hold
+// memory
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	idle := min(bucketIdleTTL, time.Duration(l.burst/l.rate*float64(time.Second)))
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= idle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware: Rate limiting keyed by client IP
+func (h *Handler) rateLimitMiddleware(limiter *rateLimiter, next http.Handler) http.Handler {
+	return h.rateLimitBy(limiter, h.clientIP, next)
+}
+
+// Middleware: API rate limiting
+// Applies to /api/* except admin routes, which have their own token. Runs
+// after authentication so each API key gets its own bucket; with no
+// authenticator, or AUTH_METHOD=none, callers are told apart by IP.
+func (h *Handler) apiRateLimitMiddleware(limiter *rateLimiter, next http.Handler) http.Handler {
+	limited := h.rateLimitBy(limiter, h.apiRateLimitKey, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/api/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limited.ServeHTTP(w, r)
+	})
+}
+
+// apiRateLimitKey identifies the caller a request is counted against
+func (h *Handler) apiRateLimitKey(r *http.Request) string {
+	if id, ok := reqctx.Identity(r.Context()); ok && id.Method != "none" {
+		return "subject:" + id.Subject
+	}
+	return "ip:" + h.clientIP(r)
+}
+
+// rateLimitBy rejects requests with 429 and Retry-After once key's bucket is empty
+func (h *Handler) rateLimitBy(limiter *rateLimiter, key func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := limiter.allow(key(r))
+		if !ok {
+			seconds := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			h.respondError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the originating client address. X-Forwarded-For can be
+// set by anyone, so it is only believed from a trusted proxy, and then read
+// from the end: each trusted proxy appends the address it got the request
+// from, so the client is the last hop that isn't a trusted proxy itself.
+func (h *Handler) clientIP(r *http.Request) string {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	if !h.trustedProxy(client) {
+		return client
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		client = hop
+		if !h.trustedProxy(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// trustedProxy reports whether addr is in TrustedProxies
+func (h *Handler) trustedProxy(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range h.TrustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
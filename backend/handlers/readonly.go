@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+// readOnlyRoutes use POST but only read the registry, so they keep working
+// in read-only mode. Patterns are as registered, without the project prefix.
+var readOnlyRoutes = map[string]bool{
+	"POST /api/prompts:batchGet":       true,
+	"POST /api/prompts/{slug}/render":  true,
+	"POST /api/prompts/{slug}/execute": true,
+	"POST /api/markdown":               true,
+	"POST /api/graphql":                true,
+}
+
+// SetReadOnly turns read-only mode on or off. While it is on, requests that
+// would change the registry get 503, fetch counting stops, and background
+// version pruning and embedding are skipped. Admin routes keep working so
+// it can be turned off again.
+func (h *Handler) SetReadOnly(on bool) {
+	if !on {
+		h.readOnlySince.Store(nil)
+	} else if h.readOnlySince.Load() == nil {
+		now := time.Now().UTC()
+		h.readOnlySince.CompareAndSwap(nil, &now)
+	}
+	h.Retention.Pause(on)
+	if h.Embeddings != nil {
+		h.Embeddings.Pause(on)
+	}
+}
+
+// ReadOnly reports whether read-only mode is on
+func (h *Handler) ReadOnly() bool {
+	return h.readOnlySince.Load() != nil
+}
+
+// readOnlyStatus describes read-only mode for the admin endpoints
+func (h *Handler) readOnlyStatus() models.ReadOnlyStatus {
+	since := h.readOnlySince.Load()
+	return models.ReadOnlyStatus{ReadOnly: since != nil, Since: since}
+}
+
+// Middleware: Read-only mode
+// Rejects writes with 503 while read-only mode is on. It runs after the
+// route is matched, so POST routes that only read can be let through.
+func (h *Handler) readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.ReadOnly() || isReadOnlyMethod(r.Method) || strings.HasPrefix(r.URL.Path, "/api/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		pattern := strings.Replace(reqctx.RoutePattern(r.Context()), "/api/projects/{project}/", "/api/", 1)
+		if readOnlyRoutes[pattern] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.writeError(w, http.StatusServiceUnavailable, ErrorResponse{
+			Code:    CodeReadOnly,
+			Message: "Registry is in read-only mode; changes are not accepted until it is turned off",
+		})
+	})
+}
+
+// Handler: Get read-only mode
+func (h *Handler) handleGetReadOnly(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, h.readOnlyStatus())
+}
+
+// Handler: Turn read-only mode on or off
+func (h *Handler) handleSetReadOnly(w http.ResponseWriter, r *http.Request) {
+	var input models.SetReadOnlyInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	h.SetReadOnly(input.ReadOnly)
+	reqctx.Logger(r.Context()).Info("read-only mode changed", "read_only", input.ReadOnly)
+	h.respondJSON(w, http.StatusOK, h.readOnlyStatus())
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// respondReleaseError maps a release store error onto a response
+func (h *Handler) respondReleaseError(w http.ResponseWriter, r *http.Request, err error, action string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		h.respondErrorFrom(w, http.StatusNotFound, err)
+	case errors.Is(err, store.ErrPermission):
+		h.respondErrorFrom(w, http.StatusForbidden, err)
+	case errors.Is(err, store.ErrValidation):
+		h.respondErrorFrom(w, http.StatusBadRequest, err)
+	case errors.Is(err, store.ErrConflict):
+		h.respondErrorFrom(w, http.StatusConflict, err)
+	default:
+		reqctx.Logger(r.Context()).Error("failed to "+action, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to "+action)
+	}
+}
+
+// notifyRelease sends prompt.updated for every prompt a release moved
+func (h *Handler) notifyRelease(s store.Store, release models.Release) {
+	for _, item := range release.Items {
+		h.notifyWebhooks(s, WebhookPromptUpdated, item.Slug, item.Version)
+	}
+}
+
+// Handler: Create release
+// Points the release's label at every listed version in one transaction.
+func (h *Handler) handleCreateRelease(w http.ResponseWriter, r *http.Request) {
+	var input models.CreateReleaseInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	s := h.requestStore(r)
+	result, err := s.CreateRelease(input)
+	if err != nil {
+		h.respondReleaseError(w, r, err, "create release")
+		return
+	}
+
+	h.notifyRelease(s, result)
+	h.respondJSON(w, http.StatusCreated, result)
+}
+
+// Handler: List releases
+func (h *Handler) handleListReleases(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	offset := 0
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if val, err := strconv.Atoi(limitStr); err == nil {
+			limit = val
+		}
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if val, err := strconv.Atoi(offsetStr); err == nil {
+			offset = val
+		}
+	}
+
+	results, err := h.requestStore(r).ListReleases(store.ReleaseFilter{
+		Label: r.URL.Query().Get("label"),
+		Slug:  r.URL.Query().Get("slug"),
+	}, limit, offset)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to list releases", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list releases")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// Handler: Get release
+func (h *Handler) handleGetRelease(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid release id")
+		return
+	}
+
+	result, err := h.requestStore(r).GetRelease(id)
+	if err != nil {
+		h.respondReleaseError(w, r, err, "get release")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Roll back release
+// Restores every label the release moved, or none if any has moved since.
+func (h *Handler) handleRollbackRelease(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid release id")
+		return
+	}
+
+	s := h.requestStore(r)
+	result, err := s.RollbackRelease(id)
+	if err != nil {
+		h.respondReleaseError(w, r, err, "roll back release")
+		return
+	}
+
+	for _, item := range result.Items {
+		h.notifyWebhooks(s, WebhookPromptUpdated, item.Slug, item.PreviousVersion)
+	}
+	h.chatRolledBack(s, result)
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: List prompt labels
+func (h *Handler) handleListLabels(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	results, err := h.requestStore(r).ListPromptLabels(slug)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to list labels", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list labels")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, results)
+}
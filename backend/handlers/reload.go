@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// ReloadConfig holds the settings Reload can change while the server runs
+type ReloadConfig struct {
+	CORS          CORSConfig
+	RateLimit     RateLimitConfig
+	WebhookURLs   []string
+	WebhookSecret string
+	Slack         SlackConfig
+}
+
+// corsConfig returns the CORS settings in effect
+func (h *Handler) corsConfig() CORSConfig {
+	h.reloadMu.RLock()
+	defer h.reloadMu.RUnlock()
+	return h.CORS
+}
+
+// rateLimitConfig returns the API rate limit in effect
+func (h *Handler) rateLimitConfig() RateLimitConfig {
+	h.reloadMu.RLock()
+	defer h.reloadMu.RUnlock()
+	return h.RateLimit
+}
+
+// Reload applies cfg to a serving handler and describes each setting it
+// changed, e.g. "cors.allowed_origins: [*] -> [https://app.example.com]".
+// Webhook URLs, the secret, and Slack webhooks are summarized rather than
+// printed, since URLs often carry tokens.
+func (h *Handler) Reload(cfg ReloadConfig) []string {
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+
+	var changes []string
+	if !slices.Equal(h.CORS.AllowedOrigins, cfg.CORS.AllowedOrigins) {
+		changes = append(changes, fmt.Sprintf("cors.allowed_origins: %v -> %v", h.CORS.AllowedOrigins, cfg.CORS.AllowedOrigins))
+	}
+	if !slices.Equal(h.CORS.AllowedHeaders, cfg.CORS.AllowedHeaders) {
+		changes = append(changes, fmt.Sprintf("cors.allowed_headers: %v -> %v", h.CORS.AllowedHeaders, cfg.CORS.AllowedHeaders))
+	}
+	h.CORS = CORSConfig{
+		AllowedOrigins: slices.Clone(cfg.CORS.AllowedOrigins),
+		AllowedHeaders: slices.Clone(cfg.CORS.AllowedHeaders),
+	}
+
+	if h.RateLimit != cfg.RateLimit {
+		if h.RateLimit.String() != cfg.RateLimit.String() {
+			changes = append(changes, fmt.Sprintf("rate_limit: %s -> %s", h.RateLimit, cfg.RateLimit))
+		}
+		h.RateLimit = cfg.RateLimit
+		if h.apiLimiter != nil {
+			h.apiLimiter.configure(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)
+		}
+	}
+
+	urls, secret := h.Webhooks.settings()
+	if !slices.Equal(urls, cfg.WebhookURLs) {
+		added, removed := 0, 0
+		for _, u := range cfg.WebhookURLs {
+			if !slices.Contains(urls, u) {
+				added++
+			}
+		}
+		for _, u := range urls {
+			if !slices.Contains(cfg.WebhookURLs, u) {
+				removed++
+			}
+		}
+		changes = append(changes, fmt.Sprintf("webhooks.urls: %d URLs (+%d -%d)", len(cfg.WebhookURLs), added, removed))
+	}
+	if secret != cfg.WebhookSecret {
+		changes = append(changes, "webhooks.secret: changed")
+	}
+	h.Webhooks.Configure(cfg.WebhookURLs, cfg.WebhookSecret)
+
+	slack := h.Notifications.settings()
+	if slack.WebhookURL != cfg.Slack.WebhookURL {
+		changes = append(changes, "notifications.slack_webhook_url: changed")
+	}
+	if !maps.Equal(slack.ProjectWebhookURLs, cfg.Slack.ProjectWebhookURLs) {
+		changes = append(changes, fmt.Sprintf("notifications.project_slack_webhook_urls: %d projects", len(cfg.Slack.ProjectWebhookURLs)))
+	}
+	h.Notifications.Configure(cfg.Slack)
+
+	return changes
+}
+
+// String describes the limit, e.g. "5/s burst 10" or "off"
+func (c RateLimitConfig) String() string {
+	if c.RequestsPerSecond <= 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%g/s burst %d", c.RequestsPerSecond, c.Burst)
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/providers"
+	"github.com/shahram/prompt-registry/backend/render"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Handler: Set prompt variable schema
+func (h *Handler) handleSetVariables(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	var input models.SetVariablesInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	result, err := h.requestStore(r).SetPromptVariables(slug, input.Variables)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, store.ErrValidation) {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to set variables", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to set variables")
+		return
+	}
+
+	h.notifyWebhooks(h.requestStore(r), WebhookPromptUpdated, result.Slug, result.CurrentVersion.VersionNumber)
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Render prompt
+func (h *Handler) handleRender(w http.ResponseWriter, r *http.Request) {
+	var input models.RenderInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	slug := r.PathValue("slug")
+	if input.Label != "" {
+		if input.Version != 0 {
+			h.respondError(w, http.StatusBadRequest, "Set either version or label, not both")
+			return
+		}
+		label, err := h.requestStore(r).GetPromptLabel(slug, input.Label)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				h.respondErrorFrom(w, http.StatusNotFound, err)
+				return
+			}
+			reqctx.Logger(r.Context()).Error("failed to get label", "error", err, "slug", slug, "label", input.Label)
+			h.respondError(w, http.StatusInternalServerError, "Failed to get label")
+			return
+		}
+		input.Version = label.Version
+	}
+
+	_, result, ok := h.renderPrompt(w, r, slug, input.Version, input.Variables)
+	if !ok {
+		return
+	}
+	if input.Provenance {
+		if last := len(result.Messages) - 1; last >= 0 {
+			result.Messages[last].Content += "\n\n" + provenanceComment(*result.Provenance)
+			result.Content, _ = render.EncodeMessages(result.Messages)
+		} else {
+			result.Content += "\n\n" + provenanceComment(*result.Provenance)
+		}
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Set prompt execution config
+func (h *Handler) handleSetExecution(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	var input models.ExecutionConfig
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	if input.Provider != "" {
+		if _, err := h.Providers.Get(input.Provider); err != nil {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := h.requestStore(r).SetPromptExecution(slug, input); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to set execution config", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to set execution config")
+		return
+	}
+
+	h.notifyWebhooks(h.requestStore(r), WebhookPromptUpdated, slug, 0)
+	h.respondJSON(w, http.StatusOK, input)
+}
+
+// Handler: Execute prompt
+// Renders the prompt and sends it to the requested, prompt-configured, or default provider.
+func (h *Handler) handleExecute(w http.ResponseWriter, r *http.Request) {
+	var input models.ExecuteInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	prompt, rendered, ok := h.renderPrompt(w, r, r.PathValue("slug"), input.Version, input.Variables)
+	if !ok {
+		return
+	}
+
+	config := versionExecution(prompt.Execution, rendered.ModelConfig)
+	provider, model, err := h.resolveProvider(config, input.Provider, input.Model)
+	if err != nil {
+		h.respondErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := providers.Request{
+		Model:       model,
+		Prompt:      rendered.Content,
+		Messages:    providerMessages(rendered.Messages),
+		MaxTokens:   input.MaxTokens,
+		Temperature: input.Temperature,
+	}
+	if params := rendered.ModelConfig; params != nil {
+		if req.MaxTokens == 0 {
+			req.MaxTokens = params.MaxTokens
+		}
+		if req.Temperature == nil {
+			req.Temperature = params.Temperature
+		}
+		req.Stop = params.Stop
+	}
+	if input.Stream {
+		h.streamExecute(w, r, rendered, provider, req)
+		return
+	}
+
+	start := time.Now()
+	completion, err := provider.Complete(r.Context(), req)
+	duration := time.Since(start)
+	if err != nil {
+		h.respondProviderError(w, r, err, provider.Name(), rendered.Slug)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, h.executeResult(r, rendered, provider.Name(), model, completion, duration))
+}
+
+// streamExecute sends the completion as server-sent events: a "delta" event
+// per text fragment, then "done" with the full ExecuteResult. Failures before
+// the first fragment get a normal JSON error; later ones end with an "error" event.
+func (h *Handler) streamExecute(w http.ResponseWriter, r *http.Request, rendered models.RenderResult, provider providers.Provider, req providers.Request) {
+	rc := http.NewResponseController(w)
+	// Completions can outlast the server's write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		reqctx.Logger(r.Context()).Warn("failed to clear write deadline", "error", err)
+	}
+
+	started := false
+	send := func(event string, data any) error {
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	start := time.Now()
+	completion, err := provider.Stream(r.Context(), req, func(text string) error {
+		return send("delta", models.ExecuteDelta{Text: text})
+	})
+	duration := time.Since(start)
+	if err != nil {
+		if !started {
+			h.respondProviderError(w, r, err, provider.Name(), rendered.Slug)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to stream prompt execution", "error", err, "provider", provider.Name(), "slug", rendered.Slug)
+		h.Metrics.IncrementHTTPErrors()
+		message := "Model provider stream interrupted"
+		var apiErr *providers.APIError
+		if errors.As(err, &apiErr) {
+			message = "Model provider error: " + apiErr.Message
+		}
+		send("error", ErrorResponse{Code: CodeUpstreamFailed, Message: message, RequestID: reqctx.RequestID(r.Context())})
+		return
+	}
+
+	result := h.executeResult(r, rendered, provider.Name(), req.Model, completion, duration)
+	if err := send("done", result); err != nil {
+		reqctx.Logger(r.Context()).Error("failed to send stream result", "error", err, "slug", rendered.Slug)
+	}
+}
+
+// executeResult logs a finished execution and builds its response
+func (h *Handler) executeResult(r *http.Request, rendered models.RenderResult, provider, model string, completion providers.Completion, duration time.Duration) models.ExecuteResult {
+	if completion.Model == "" {
+		completion.Model = model
+	}
+	reqctx.Logger(r.Context()).Info("prompt executed",
+		"slug", rendered.Slug,
+		"version", rendered.VersionNumber,
+		"provider", provider,
+		"model", completion.Model,
+		"total_tokens", completion.Usage.TotalTokens,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return models.ExecuteResult{
+		Slug:          rendered.Slug,
+		VersionNumber: rendered.VersionNumber,
+		Provider:      provider,
+		Model:         completion.Model,
+		Prompt:        rendered.Content,
+		Messages:      rendered.Messages,
+		Completion:    completion.Text,
+		FinishReason:  completion.FinishReason,
+		Usage:         models.TokenUsage(completion.Usage),
+		Provenance:    rendered.Provenance,
+		DurationMs:    duration.Milliseconds(),
+	}
+}
+
+// providerMessages converts a chat prompt's rendered messages for a provider
+// request; a text prompt has none
+func providerMessages(messages []models.Message) []providers.Message {
+	if messages == nil {
+		return nil
+	}
+	result := make([]providers.Message, len(messages))
+	for i, m := range messages {
+		result[i] = providers.Message{Role: m.Role, Content: m.Content}
+	}
+	return result
+}
+
+// versionExecution returns the prompt's execution config with the model a
+// version's model config names, if any. Like the prompt's model, it only
+// applies when running on the prompt's provider.
+func versionExecution(config *models.ExecutionConfig, params *models.ModelConfig) *models.ExecutionConfig {
+	if params == nil || params.Model == "" {
+		return config
+	}
+	merged := models.ExecutionConfig{Model: params.Model}
+	if config != nil {
+		merged.Provider = config.Provider
+	}
+	return &merged
+}
+
+// resolveProvider picks the provider and model for an execution. The request
+// wins over the prompt's config, which wins over the server default. A prompt's
+// model only applies when running on the prompt's provider.
+func (h *Handler) resolveProvider(config *models.ExecutionConfig, providerName, model string) (providers.Provider, string, error) {
+	if config == nil {
+		config = &models.ExecutionConfig{}
+	}
+	if providerName == "" {
+		providerName = config.Provider
+	}
+	provider, err := h.Providers.Get(providerName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if model == "" && config.Model != "" && (config.Provider == "" || config.Provider == provider.Name()) {
+		model = config.Model
+	}
+	if model == "" {
+		model = provider.DefaultModel()
+	}
+	return provider, model, nil
+}
+
+// respondProviderError logs a failed provider call and answers 502
+func (h *Handler) respondProviderError(w http.ResponseWriter, r *http.Request, err error, provider, slug string) {
+	reqctx.Logger(r.Context()).Error("failed to execute prompt", "error", err, "provider", provider, "slug", slug)
+	var apiErr *providers.APIError
+	if errors.As(err, &apiErr) {
+		h.respondError(w, http.StatusBadGateway, "Model provider error: "+apiErr.Message)
+		return
+	}
+	h.respondError(w, http.StatusBadGateway, "Model provider unavailable")
+}
+
+// renderPrompt validates variables against the prompt's schema and substitutes
+// placeholders in the requested version (0 means current). A chat prompt's
+// messages are rendered one by one and returned both as Messages and, encoded,
+// as Content. On failure it writes the error response and returns false.
+func (h *Handler) renderPrompt(w http.ResponseWriter, r *http.Request, slug string, versionNumber int, variables map[string]any) (models.PromptWithCurrentVersion, models.RenderResult, bool) {
+	var rendered models.RenderResult
+	prompt, err := h.requestStore(r).GetPromptBySlug(slug)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return prompt, rendered, false
+		}
+		reqctx.Logger(r.Context()).Error("failed to get prompt", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get prompt")
+		return prompt, rendered, false
+	}
+
+	version := prompt.CurrentVersion
+	if versionNumber > 0 && versionNumber != version.VersionNumber {
+		version, err = h.requestStore(r).GetPromptVersion(slug, versionNumber)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				h.respondErrorFrom(w, http.StatusNotFound, err)
+				return prompt, rendered, false
+			}
+			reqctx.Logger(r.Context()).Error("failed to get version", "error", err, "slug", slug, "version", versionNumber)
+			h.respondError(w, http.StatusInternalServerError, "Failed to get version")
+			return prompt, rendered, false
+		}
+	}
+
+	if err := render.Validate(prompt.Variables, variables); err != nil {
+		h.respondErrorFrom(w, http.StatusBadRequest, err)
+		return prompt, rendered, false
+	}
+	var content string
+	var messages []models.Message
+	if prompt.Format == render.FormatChat && version.Messages != nil {
+		messages, err = render.RenderMessages(version.Messages, variables)
+		if err == nil {
+			content, err = render.EncodeMessages(messages)
+		}
+	} else {
+		content, err = render.Render(version.Content, variables)
+	}
+	if err != nil {
+		h.respondErrorFrom(w, http.StatusBadRequest, err)
+		return prompt, rendered, false
+	}
+
+	h.recordUsage(r, prompt.Slug, version.VersionNumber)
+	provenance := h.provenance(r, prompt.Slug, version)
+	setProvenanceHeaders(w, provenance)
+	return prompt, models.RenderResult{
+		Slug:          prompt.Slug,
+		VersionNumber: version.VersionNumber,
+		Content:       content,
+		Messages:      messages,
+		ModelConfig:   version.ModelConfig,
+		Provenance:    &provenance,
+	}, true
+}
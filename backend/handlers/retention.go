@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// DefaultRetentionInterval is how often old versions are pruned when the
+// interval isn't configured
+const DefaultRetentionInterval = time.Hour
+
+// RetentionJanitor prunes old prompt versions in the background. Prompts
+// without a retention policy of their own follow Default, which keeps
+// everything unless configured.
+type RetentionJanitor struct {
+	// Default applies to prompts without their own policy
+	Default models.RetentionPolicy
+	// DryRun makes background runs log what they would prune without
+	// deleting anything
+	DryRun bool
+
+	store  store.Store
+	logger *slog.Logger
+
+	run    sync.Mutex  // held while a pruning run is in progress
+	paused atomic.Bool // skips background runs, e.g. in read-only mode
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewRetentionJanitor creates a janitor pruning s; call Start to prune in
+// the background
+func NewRetentionJanitor(s store.Store, logger *slog.Logger) *RetentionJanitor {
+	return &RetentionJanitor{store: s, logger: logger}
+}
+
+// Run prunes every project now. With dryRun it only reports what it would
+// delete.
+func (j *RetentionJanitor) Run(dryRun bool) (models.PruneResult, error) {
+	j.run.Lock()
+	defer j.run.Unlock()
+
+	result, err := j.store.PruneVersions(j.Default, dryRun)
+	if err != nil {
+		j.logger.Error("version pruning failed", "error", err, "dry_run", dryRun)
+		return result, err
+	}
+	for _, p := range result.Prompts {
+		j.logger.Info("pruned versions",
+			"project", p.Project,
+			"slug", p.Slug,
+			"versions", p.Versions,
+			"dry_run", dryRun,
+		)
+	}
+	return result, nil
+}
+
+// Start prunes every interval in the background until Stop is called
+func (j *RetentionJanitor) Start(interval time.Duration) {
+	j.stop = make(chan struct{})
+	j.done = make(chan struct{})
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.stop:
+				return
+			case <-ticker.C:
+				if j.paused.Load() {
+					continue
+				}
+				// Failures are logged; the next tick tries again
+				j.Run(j.DryRun)
+			}
+		}
+	}()
+}
+
+// Pause skips background runs until it is called again with false. Run
+// still prunes when called directly.
+func (j *RetentionJanitor) Pause(paused bool) {
+	j.paused.Store(paused)
+}
+
+// Stop ends background pruning, waiting for a running pass to finish
+func (j *RetentionJanitor) Stop() {
+	if j.stop != nil {
+		close(j.stop)
+		<-j.done
+		j.stop = nil
+	}
+}
+
+// Handler: Get a prompt's retention policy
+// Includes the versions the next pruning run would delete.
+func (h *Handler) handleGetRetention(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	result, err := h.requestStore(r).GetPromptRetention(slug, h.Retention.Default)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to get retention policy", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get retention policy")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Set a prompt's retention policy
+func (h *Handler) handleSetRetention(w http.ResponseWriter, r *http.Request) {
+	var policy models.RetentionPolicy
+	if !h.decodeJSON(w, r, &policy) {
+		return
+	}
+	h.setRetention(w, r, &policy)
+}
+
+// Handler: Clear a prompt's retention policy so it follows the default
+func (h *Handler) handleClearRetention(w http.ResponseWriter, r *http.Request) {
+	h.setRetention(w, r, nil)
+}
+
+// setRetention sets or clears a prompt's policy and responds with the result
+func (h *Handler) setRetention(w http.ResponseWriter, r *http.Request, policy *models.RetentionPolicy) {
+	slug := r.PathValue("slug")
+
+	s := h.requestStore(r)
+	if err := s.SetPromptRetention(slug, policy); err != nil {
+		switch {
+		case errors.Is(err, store.ErrValidation):
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, store.ErrNotFound):
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+		default:
+			reqctx.Logger(r.Context()).Error("failed to set retention policy", "error", err, "slug", slug)
+			h.respondError(w, http.StatusInternalServerError, "Failed to set retention policy")
+		}
+		return
+	}
+	reqctx.Logger(r.Context()).Info("retention policy changed", "slug", slug, "cleared", policy == nil)
+
+	result, err := s.GetPromptRetention(slug, h.Retention.Default)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to get retention policy", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get retention policy")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Prune old versions now
+// With ?dry_run=true, reports what would be pruned without deleting it.
+func (h *Handler) handlePruneVersions(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	result, err := h.Retention.Run(dryRun)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to prune versions", "error", err, "dry_run", dryRun)
+		h.respondError(w, http.StatusInternalServerError, "Failed to prune versions")
+		return
+	}
+	reqctx.Logger(r.Context()).Info("versions pruned", "dry_run", dryRun, "prompts", len(result.Prompts), "versions", result.Versions)
+	h.respondJSON(w, http.StatusOK, result)
+}
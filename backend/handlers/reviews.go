@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Handler: Get a prompt's review policy
+func (h *Handler) handleGetReviewPolicy(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	result, err := h.requestStore(r).GetReviewPolicy(slug)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to get review policy", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get review policy")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Set a prompt's review policy
+// Admin only: prompt writers could otherwise turn off the review of their
+// own versions.
+func (h *Handler) handleSetReviewPolicy(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	if project := r.PathValue("project"); project != "" {
+		if err := store.ValidateProject(project); err != nil {
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	var input models.ReviewPolicy
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	result, err := h.requestStore(r).SetReviewPolicy(slug, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrValidation):
+			h.respondErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, store.ErrNotFound):
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+		default:
+			reqctx.Logger(r.Context()).Error("failed to set review policy", "error", err, "slug", slug)
+			h.respondError(w, http.StatusInternalServerError, "Failed to set review policy")
+		}
+		return
+	}
+	reqctx.Logger(r.Context()).Info("review policy changed", "slug", slug, "project", r.PathValue("project"),
+		"required_approvals", result.RequiredApprovals)
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Get a version's review status and approvals
+func (h *Handler) handleListApprovals(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil || version < 1 {
+		h.respondError(w, http.StatusBadRequest, "Invalid version number")
+		return
+	}
+
+	result, err := h.requestStore(r).GetVersionReview(slug, version)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to get version review", "error", err, "slug", slug, "version", version)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get version review")
+		return
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
+
+// Handler: Approve a pending version
+// The approval that completes the review makes the version current, which
+// is announced like a newly created version.
+func (h *Handler) handleApproveVersion(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil || version < 1 {
+		h.respondError(w, http.StatusBadRequest, "Invalid version number")
+		return
+	}
+
+	var input models.ApproveVersionInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+
+	s := h.requestStore(r)
+	result, err := s.ApproveVersion(slug, version, input.Comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, store.ErrPermission):
+			h.respondErrorFrom(w, http.StatusForbidden, err)
+		case errors.Is(err, store.ErrConflict):
+			h.respondErrorFrom(w, http.StatusConflict, err)
+		default:
+			reqctx.Logger(r.Context()).Error("failed to approve version", "error", err, "slug", slug, "version", version)
+			h.respondError(w, http.StatusInternalServerError, "Failed to approve version")
+		}
+		return
+	}
+	reqctx.Logger(r.Context()).Info("version approved", "slug", slug, "version", version,
+		"approvals", len(result.Approvals), "status", result.Status)
+
+	// Announced only when the approval made the version current
+	if result.PreviousVersion > 0 {
+		h.Hub.Broadcast(Event{Type: EventUpdated, Project: s.Project(), Slug: slug, Version: version}, nil)
+		h.notifyVersionCreated(s, slug, result.PreviousVersion, version)
+	}
+	h.respondJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Handler: Star a prompt for the caller
+func (h *Handler) handleStarPrompt(w http.ResponseWriter, r *http.Request) {
+	h.setStarred(w, r, true)
+}
+
+// Handler: Unstar a prompt for the caller
+func (h *Handler) handleUnstarPrompt(w http.ResponseWriter, r *http.Request) {
+	h.setStarred(w, r, false)
+}
+
+// setStarred updates the caller's star on a prompt
+func (h *Handler) setStarred(w http.ResponseWriter, r *http.Request, starred bool) {
+	slug := r.PathValue("slug")
+
+	if err := h.requestStore(r).SetPromptStarred(slug, starred); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to set star", "error", err, "slug", slug, "starred", starred)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update star")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+const (
+	// defaultStatsDays and maxStatsDays bound how many days of creation
+	// counts stats returns
+	defaultStatsDays = 30
+	maxStatsDays     = 366
+)
+
+// Handler: Registry stats for capacity planning
+func (h *Handler) handleGetStats(w http.ResponseWriter, r *http.Request) {
+	days := defaultStatsDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxStatsDays {
+			h.respondError(w, http.StatusBadRequest, "invalid days: use a number from 1 to "+strconv.Itoa(maxStatsDays))
+			return
+		}
+		days = n
+	}
+	limit := defaultAnalyticsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAnalyticsLimit {
+			h.respondError(w, http.StatusBadRequest, "invalid limit: use a number from 1 to "+strconv.Itoa(maxAnalyticsLimit))
+			return
+		}
+		limit = n
+	}
+
+	// Today counts as the last of the days
+	since := time.Now().UTC().AddDate(0, 0, 1-days)
+	result, err := h.requestStore(r).GetRegistryStats(since, limit)
+	if err != nil {
+		reqctx.Logger(r.Context()).Error("failed to get stats", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get stats")
+		return
+	}
+	result.Days = days
+
+	h.respondJSON(w, http.StatusOK, result)
+}
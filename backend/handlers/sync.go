@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/shahram/prompt-registry/backend/gitsync"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+// Handler: Git sync
+// Pulls changed prompt files from the sync repository and/or commits new
+// registry versions to it. Only mounted when Git sync is configured.
+func (h *Handler) handleSync(w http.ResponseWriter, r *http.Request) {
+	var input models.SyncInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		h.respondDecodeError(w, r, err)
+		return
+	}
+	if input.Direction == "" {
+		input.Direction = gitsync.DirectionBoth
+	}
+	if err := gitsync.ValidateDirection(input.Direction); err != nil {
+		h.respondErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.Sync.Sync(input.Direction)
+	if err != nil {
+		if errors.Is(err, gitsync.ErrInProgress) {
+			h.respondError(w, http.StatusConflict, "Sync already in progress")
+			return
+		}
+		// Git output can mention the remote URL, so details stay in the logs
+		reqctx.Logger(r.Context()).Error("git sync failed", "error", err, "direction", input.Direction)
+		h.respondError(w, http.StatusBadGateway, "Git sync failed; see server logs")
+		return
+	}
+
+	// Versions created from the repository are announced like any other change
+	for _, imported := range result.Imported {
+		if imported.Version == 1 {
+			h.Metrics.IncrementPromptsCreated()
+			h.notifyWebhooks(h.Store, WebhookPromptCreated, imported.Slug, imported.Version)
+		} else {
+			h.Metrics.IncrementPromptVersionsCreated()
+			h.notifyVersionCreated(h.Store, imported.Slug, imported.Version-1, imported.Version)
+		}
+		h.Hub.Broadcast(Event{
+			Type:    EventUpdated,
+			Project: h.Store.Project(),
+			Slug:    imported.Slug,
+			Version: imported.Version,
+		}, nil)
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
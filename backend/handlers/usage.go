@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// DefaultUsageFlushInterval is how often counted fetches are written when
+// the interval isn't configured
+const DefaultUsageFlushInterval = 30 * time.Second
+
+// UsageTracker counts prompt fetches in memory and writes them to the store
+// in batches, so serving a prompt never waits on a write and a hot prompt
+// costs one row update per flush rather than one per request. Counts not yet
+// flushed are lost if the process crashes.
+type UsageTracker struct {
+	store  store.Store
+	logger *slog.Logger
+
+	flush sync.Mutex // held while a batch is being written
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu      sync.Mutex
+	pending map[usageKey]*store.UsageCount
+}
+
+// usageKey identifies a prompt version across projects
+type usageKey struct {
+	project string
+	slug    string
+	version int
+}
+
+// NewUsageTracker creates a tracker writing to s; call Start to flush in
+// the background
+func NewUsageTracker(s store.Store, logger *slog.Logger) *UsageTracker {
+	return &UsageTracker{
+		store:   s,
+		logger:  logger,
+		pending: make(map[usageKey]*store.UsageCount),
+	}
+}
+
+// Record counts one fetch of a version of the prompt at slug in project
+func (u *UsageTracker) Record(project, slug string, version int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	key := usageKey{project: project, slug: slug, version: version}
+	count, ok := u.pending[key]
+	if !ok {
+		count = &store.UsageCount{Slug: slug, Version: version}
+		u.pending[key] = count
+	}
+	count.Fetches++
+	count.LastFetchedAt = time.Now().UTC()
+}
+
+// Flush writes the pending counts, one transaction per project. Counts for a
+// project that fails to write are kept for the next flush.
+func (u *UsageTracker) Flush() error {
+	u.flush.Lock()
+	defer u.flush.Unlock()
+
+	u.mu.Lock()
+	pending := u.pending
+	u.pending = make(map[usageKey]*store.UsageCount)
+	u.mu.Unlock()
+
+	byProject := make(map[string][]store.UsageCount)
+	for key, count := range pending {
+		byProject[key.project] = append(byProject[key.project], *count)
+	}
+	var firstErr error
+	for project, counts := range byProject {
+		if err := u.store.InProject(project).RecordPromptUsage(counts); err != nil {
+			u.logger.Error("failed to flush usage", "error", err, "project", project)
+			u.requeue(project, counts)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// requeue adds counts that failed to write back to the pending batch
+func (u *UsageTracker) requeue(project string, counts []store.UsageCount) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, c := range counts {
+		key := usageKey{project: project, slug: c.Slug, version: c.Version}
+		if count, ok := u.pending[key]; ok {
+			count.Fetches += c.Fetches
+			if c.LastFetchedAt.After(count.LastFetchedAt) {
+				count.LastFetchedAt = c.LastFetchedAt
+			}
+			continue
+		}
+		u.pending[key] = &c
+	}
+}
+
+// Start flushes every interval in the background until Stop is called
+func (u *UsageTracker) Start(interval time.Duration) {
+	u.stop = make(chan struct{})
+	u.done = make(chan struct{})
+	go func() {
+		defer close(u.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-u.stop:
+				return
+			case <-ticker.C:
+				u.Flush()
+			}
+		}
+	}()
+}
+
+// Stop ends background flushing and writes whatever is still pending
+func (u *UsageTracker) Stop() {
+	if u.stop != nil {
+		close(u.stop)
+		<-u.done
+		u.stop = nil
+	}
+	u.Flush()
+}
+
+// recordUsage counts a successful fetch of a prompt version for r's project.
+// Fetches aren't counted in read-only mode, since counting writes.
+func (h *Handler) recordUsage(r *http.Request, slug string, version int) {
+	if h.ReadOnly() {
+		return
+	}
+	h.Usage.Record(h.requestStore(r).Project(), slug, version)
+}
+
+// Handler: Get a prompt's usage
+// Flushes pending counts first so the response includes fetches made just
+// before it.
+func (h *Handler) handleGetUsage(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	if err := h.Usage.Flush(); err != nil {
+		reqctx.Logger(r.Context()).Warn("serving usage without unflushed fetches", "error", err)
+	}
+	result, err := h.requestStore(r).GetPromptUsage(slug)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to get usage", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get usage")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shahram/prompt-registry/backend/diff"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/render"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// Webhook event types
+const (
+	WebhookPromptCreated   = "prompt.created"
+	WebhookVersionCreated  = "prompt.version_created"
+	WebhookPromptUpdated   = "prompt.updated" // visibility, variables, execution config, or archived state changed
+	WebhookVersionRedacted = "prompt.version_redacted"
+)
+
+// webhookSignatureHeader carries the HMAC of the body when a secret is set
+const webhookSignatureHeader = "X-Webhook-Signature"
+
+// errPrivateWebhookHost is returned when a per-prompt webhook would reach a
+// host that isn't on the public internet
+var errPrivateWebhookHost = errors.New("webhook host is not a public address")
+
+// Webhooks delivers prompt change events to the global webhook URLs and to
+// the URLs registered on the changed prompt. Deliveries go through
+// Integrations, so they never block or fail the request that caused them.
+// Global URLs come from the operator and may be internal, but per-prompt URLs
+// come from API callers, so they may only reach public addresses; this is
+// checked when a URL is registered and again when each delivery connects.
+type Webhooks struct {
+	// GlobalURLs receive every event. Set it and Secret before serving, and
+	// use Configure afterwards.
+	GlobalURLs []string
+	// Secret signs each body with HMAC-SHA256 in X-Webhook-Signature when set
+	Secret string
+
+	mu           sync.RWMutex // guards GlobalURLs and Secret
+	client       *http.Client // for global URLs
+	promptClient *http.Client // for per-prompt URLs; refuses private addresses
+	allowPrivate bool         // lets per-prompt URLs reach any address, for tests
+	logger       *slog.Logger
+	integrations *Integrations
+}
+
+// NewWebhooks creates a dispatcher with no global URLs that delivers through in
+func NewWebhooks(in *Integrations, logger *slog.Logger) *Webhooks {
+	wh := &Webhooks{
+		client:       &http.Client{Timeout: 10 * time.Second},
+		logger:       logger,
+		integrations: in,
+	}
+	// Checking the address being dialed, rather than the host name, also
+	// catches names that resolved to a public address when registered and
+	// were later pointed somewhere private.
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: func(network, address string, _ syscall.RawConn) error {
+		if wh.allowPrivate {
+			return nil
+		}
+		if addr, err := netip.ParseAddrPort(address); err != nil || !publicAddr(addr.Addr()) {
+			return errPrivateWebhookHost
+		}
+		return nil
+	}}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	wh.promptClient = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+	return wh
+}
+
+// Configure replaces the global URLs and signing secret. Deliveries already
+// queued keep the settings they were sent with.
+func (wh *Webhooks) Configure(urls []string, secret string) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	wh.GlobalURLs = slices.Clone(urls)
+	wh.Secret = secret
+}
+
+// settings returns the global URLs and signing secret
+func (wh *Webhooks) settings() ([]string, string) {
+	wh.mu.RLock()
+	defer wh.mu.RUnlock()
+	return wh.GlobalURLs, wh.Secret
+}
+
+// Send delivers event to the global URLs plus promptURLs, each URL once
+func (wh *Webhooks) Send(event models.WebhookEvent, promptURLs []string) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		wh.logger.Error("failed to encode webhook event", "error", err)
+		return
+	}
+
+	globalURLs, secret := wh.settings()
+	seen := make(map[string]bool)
+	send := func(targets []string, client *http.Client) {
+		for _, target := range targets {
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+
+			wh.integrations.Go(IntegrationWebhooks, target, func() error {
+				return wh.deliver(client, target, secret, event, body)
+			})
+		}
+	}
+	send(globalURLs, wh.client)
+	send(promptURLs, wh.promptClient)
+}
+
+// Wait blocks until every in-flight delivery has finished or given up
+func (wh *Webhooks) Wait() {
+	wh.integrations.Wait()
+}
+
+// deliver POSTs body to target once. Network errors and 5xx responses are
+// retried by Integrations; other rejections, and targets that resolve to a
+// private address, are permanent.
+func (wh *Webhooks) deliver(client *http.Client, target, secret string, event models.WebhookEvent, body []byte) error {
+	status, err := wh.post(client, target, secret, body)
+	if errors.Is(err, errPrivateWebhookHost) {
+		wh.logger.Warn("webhook blocked", "url", target, "event", event.Event, "slug", event.Slug)
+		return Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	if status >= 500 {
+		return fmt.Errorf("%s returned status %d", event.Event, status)
+	}
+	if status >= 400 {
+		wh.logger.Warn("webhook rejected", "url", target, "event", event.Event, "slug", event.Slug, "status", status)
+		return Permanent(fmt.Errorf("%s rejected with status %d", event.Event, status))
+	}
+	return nil
+}
+
+func (wh *Webhooks) post(client *http.Client, target, secret string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "prompt-registry-webhooks")
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// notifyWebhooks sends an event for slug in s's project to the global and
+// per-prompt webhooks
+func (h *Handler) notifyWebhooks(s store.Store, eventType, slug string, version int) {
+	h.sendWebhookEvent(s, eventType, slug, version, nil)
+}
+
+// notifyVersionCreated sends prompt.version_created for version to, with a
+// summary of the changes since version from. Batch imports pass the version
+// before the batch, so the summary covers all of it. The version is also
+// announced in the project's Slack channel.
+func (h *Handler) notifyVersionCreated(s store.Store, slug string, from, to int) {
+	h.sendWebhookEvent(s, WebhookVersionCreated, slug, to, func(event *models.WebhookEvent) {
+		if from < 1 {
+			return
+		}
+		before, err := s.GetPromptVersion(slug, from)
+		if err != nil {
+			h.Logger.Error("failed to load version for webhook summary", "error", err, "slug", slug, "version", from)
+			return
+		}
+		after, err := s.GetPromptVersion(slug, to)
+		if err != nil {
+			h.Logger.Error("failed to load version for webhook summary", "error", err, "slug", slug, "version", to)
+			return
+		}
+		event.Changes = versionChanges(before, after)
+		event.Text += ": " + event.Changes.Summary
+	})
+	h.chatVersionCreated(s, slug, from, to)
+}
+
+// sendWebhookEvent builds and sends an event. prepare, if set, can add to the
+// event; it only runs when some webhook will receive it.
+func (h *Handler) sendWebhookEvent(s store.Store, eventType, slug string, version int, prepare func(*models.WebhookEvent)) {
+	hooks, err := s.ListPromptWebhooks(slug)
+	if err != nil {
+		h.Logger.Error("failed to load prompt webhooks", "error", err, "slug", slug)
+	}
+	urls := make([]string, 0, len(hooks))
+	for _, hook := range hooks {
+		urls = append(urls, hook.URL)
+	}
+	if globalURLs, _ := h.Webhooks.settings(); len(urls) == 0 && len(globalURLs) == 0 {
+		return
+	}
+
+	event := models.WebhookEvent{
+		Event:   eventType,
+		Project: s.Project(),
+		Slug:    slug,
+		Version: version,
+		Time:    time.Now().UTC(),
+		Text:    fmt.Sprintf("%s %s/%s", eventType, s.Project(), slug),
+	}
+	if version > 0 {
+		event.Text += fmt.Sprintf(" v%d", version)
+	}
+	if prepare != nil {
+		prepare(&event)
+	}
+	h.Webhooks.Send(event, urls)
+}
+
+// versionChanges summarizes the difference between two versions of a prompt
+func versionChanges(before, after models.PromptVersion) *models.VersionChanges {
+	changes := &models.VersionChanges{
+		FromVersion:  before.VersionNumber,
+		TokensBefore: estimateTokens(before.Content),
+		TokensAfter:  estimateTokens(after.Content),
+	}
+	changes.TokenDelta = changes.TokensAfter - changes.TokensBefore
+	for _, op := range diff.Lines(diff.SplitLines(before.Content), diff.SplitLines(after.Content)) {
+		switch op.Kind {
+		case diff.Insert:
+			changes.LinesAdded++
+		case diff.Delete:
+			changes.LinesRemoved++
+		}
+	}
+	changes.VariablesAdded = missingFrom(render.Placeholders(after.Content), render.Placeholders(before.Content))
+	changes.VariablesRemoved = missingFrom(render.Placeholders(before.Content), render.Placeholders(after.Content))
+
+	parts := []string{fmt.Sprintf("+%d -%d lines", changes.LinesAdded, changes.LinesRemoved)}
+	if len(changes.VariablesAdded) > 0 || len(changes.VariablesRemoved) > 0 {
+		var vars []string
+		for _, name := range changes.VariablesAdded {
+			vars = append(vars, "+"+name)
+		}
+		for _, name := range changes.VariablesRemoved {
+			vars = append(vars, "-"+name)
+		}
+		parts = append(parts, "variables "+strings.Join(vars, " "))
+	}
+	parts = append(parts, fmt.Sprintf("%+d tokens", changes.TokenDelta))
+	changes.Summary = strings.Join(parts, ", ")
+	return changes
+}
+
+// missingFrom returns the names in names that aren't in other, in order
+func missingFrom(names, other []string) []string {
+	var missing []string
+	for _, name := range names {
+		if !slices.Contains(other, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// estimateTokens approximates a text's token count at about 4 characters per
+// token, close enough for common tokenizers on English prompts to spot a
+// prompt growing or shrinking
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// validateURL requires an absolute http or https URL whose host resolves
+// only to public addresses, so a prompt's webhooks can't be used to reach
+// the registry's own network or cloud metadata endpoints
+func (wh *Webhooks) validateURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if wh.allowPrivate {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("url host %q does not resolve", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return fmt.Errorf("url host %q is not a public address", u.Hostname())
+		}
+	}
+	return nil
+}
+
+// publicAddr reports whether addr is reachable on the public internet, as
+// opposed to loopback, private, link-local, multicast, or unspecified
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddrSpace.Contains(addr)
+}
+
+// sharedAddrSpace is the carrier-grade NAT range, private in practice
+var sharedAddrSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Handler: List prompt webhooks
+func (h *Handler) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	results, err := h.requestStore(r).ListPromptWebhooks(slug)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to list webhooks", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to list webhooks")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, results)
+}
+
+// Handler: Add prompt webhook
+func (h *Handler) handleAddWebhook(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	var input models.CreateWebhookInput
+	if !h.decodeJSON(w, r, &input) {
+		return
+	}
+	if err := h.Webhooks.validateURL(r.Context(), input.URL); err != nil {
+		h.respondErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.requestStore(r).AddPromptWebhook(slug, input.URL)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			h.respondErrorFrom(w, http.StatusConflict, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to add webhook", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to add webhook")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, result)
+}
+
+// Handler: Delete prompt webhook
+func (h *Handler) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid webhook id")
+		return
+	}
+
+	if err := h.requestStore(r).DeletePromptWebhook(slug, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		reqctx.Logger(r.Context()).Error("failed to delete webhook", "error", err, "slug", slug)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
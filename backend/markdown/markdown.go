@@ -0,0 +1,299 @@
+// Package markdown renders the Markdown subset used in prompt descriptions to
+// HTML. Raw HTML in the source is escaped rather than passed through and links
+// are limited to safe schemes, so the output can be inserted into a page as is.
+//
+// Supported: ATX headings, paragraphs, emphasis, inline code, fenced code
+// blocks, bullet and numbered lists, block quotes, horizontal rules, links,
+// and bare or <bracketed> http(s) URLs.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingRe = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))?[ \t#]*$`)
+	bulletRe  = regexp.MustCompile(`^[ ]{0,3}[-*+][ \t]+(.*)$`)
+	orderedRe = regexp.MustCompile(`^[ ]{0,3}(\d{1,9})[.)][ \t]+(.*)$`)
+	fenceRe   = regexp.MustCompile("^[ ]{0,3}(```+|~~~+)[ \t]*([A-Za-z0-9_+-]*)")
+	ruleRe    = regexp.MustCompile(`^[ ]{0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	quoteRe   = regexp.MustCompile(`^[ ]{0,3}>[ ]?(.*)$`)
+	bareURLRe = regexp.MustCompile(`^https?://[^\s<>"]+`)
+)
+
+// trailingURL is punctuation that ends a sentence rather than a bare URL
+const trailingURL = ".,:;!?'\")"
+
+// ToHTML renders src to sanitized HTML
+func ToHTML(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"))
+	return b.String()
+}
+
+// renderBlocks writes the block elements in lines
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case fenceRe.MatchString(line):
+			i = renderFence(b, lines, i)
+		case headingRe.MatchString(line):
+			m := headingRe.FindStringSubmatch(line)
+			level := string(rune('0' + len(m[1])))
+			b.WriteString("<h" + level + ">" + inline(m[2]) + "</h" + level + ">\n")
+			i++
+		case ruleRe.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+		case quoteRe.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && quoteRe.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quoteRe.FindStringSubmatch(lines[i])[1])
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+		case bulletRe.MatchString(line):
+			i = renderList(b, lines, i, bulletRe, "ul")
+		case orderedRe.MatchString(line):
+			i = renderList(b, lines, i, orderedRe, "ol")
+		default:
+			var para []string
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && !startsBlock(lines[i]); i++ {
+				para = append(para, strings.TrimSpace(lines[i]))
+			}
+			b.WriteString("<p>" + inline(strings.Join(para, "\n")) + "</p>\n")
+		}
+	}
+}
+
+// startsBlock reports whether line interrupts a paragraph
+func startsBlock(line string) bool {
+	return fenceRe.MatchString(line) || headingRe.MatchString(line) || ruleRe.MatchString(line) ||
+		quoteRe.MatchString(line) || bulletRe.MatchString(line) || orderedRe.MatchString(line)
+}
+
+// renderFence writes the code block starting at lines[i] and returns the index
+// after it. An unclosed fence runs to the end of the text.
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	m := fenceRe.FindStringSubmatch(lines[i])
+	fence, lang := m[1], m[2]
+	var code []string
+	for i++; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+	b.WriteString("<pre><code")
+	if lang != "" {
+		b.WriteString(` class="language-` + lang + `"`)
+	}
+	b.WriteString(">")
+	for _, line := range code {
+		b.WriteString(html.EscapeString(line) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+// renderList writes the list starting at lines[i] and returns the index after
+// it. Indented lines continue the previous item.
+func renderList(b *strings.Builder, lines []string, i int, item *regexp.Regexp, tag string) int {
+	b.WriteString("<" + tag)
+	if tag == "ol" {
+		if start, _ := strconv.Atoi(orderedRe.FindStringSubmatch(lines[i])[1]); start != 1 {
+			b.WriteString(` start="` + strconv.Itoa(start) + `"`)
+		}
+	}
+	b.WriteString(">\n")
+	var items [][]string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if m := item.FindStringSubmatch(line); m != nil {
+			items = append(items, []string{m[len(m)-1]})
+			continue
+		}
+		if strings.TrimSpace(line) == "" || !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			break
+		}
+		last := len(items) - 1
+		items[last] = append(items[last], strings.TrimSpace(line))
+	}
+	for _, text := range items {
+		b.WriteString("<li>" + inline(strings.Join(text, "\n")) + "</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// inline renders emphasis, code spans, and links within a block, escaping
+// everything else
+func inline(text string) string {
+	return inlineLinks(text, true)
+}
+
+// inlineLinks renders inline markup; links are left as text when false, since
+// anchors can't nest
+func inlineLinks(text string, links bool) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		rest := text[i:]
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte("\\`*_[]()<>#+-.!", text[i+1]) >= 0:
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			n := len(rest) - len(strings.TrimLeft(rest, "`"))
+			if end := strings.Index(rest[n:], rest[:n]); end >= 0 {
+				code := strings.TrimSpace(rest[n : n+end])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += n + end + n
+				continue
+			}
+		case c == '*' || c == '_':
+			if out, n := emphasis(text, i, links); n > 0 {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+		case c == '[' && links:
+			if out, n := link(rest); n > 0 {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+		case c == '<' && links:
+			if end := strings.IndexByte(rest, '>'); end > 0 && bareURLRe.MatchString(rest[1:end]) && bareURLRe.FindString(rest[1:end]) == rest[1:end] {
+				b.WriteString(anchor(rest[1:end], html.EscapeString(rest[1:end])))
+				i += end + 1
+				continue
+			}
+		case c == 'h' && links && (i == 0 || !isWordByte(text[i-1])):
+			if u := bareURLRe.FindString(rest); u != "" {
+				u = strings.TrimRight(u, trailingURL)
+				b.WriteString(anchor(u, html.EscapeString(u)))
+				i += len(u)
+				continue
+			}
+		case c == '\n':
+			b.WriteString("\n")
+			i++
+			continue
+		}
+		b.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// emphasis renders *em*, _em_, **strong**, or __strong__ opening at text[i],
+// returning the HTML and the bytes consumed, or 0 if the delimiter is literal
+func emphasis(text string, i int, links bool) (string, int) {
+	delim := text[i : i+1]
+	if strings.HasPrefix(text[i:], delim+delim) {
+		delim += delim
+	}
+	// Underscores inside words, as in snake_case names, stay literal
+	if delim[0] == '_' && i > 0 && isWordByte(text[i-1]) {
+		return "", 0
+	}
+	start := i + len(delim)
+	if start >= len(text) || text[start] == ' ' {
+		return "", 0
+	}
+	for j := start + 1; j+len(delim) <= len(text); j++ {
+		if text[j:j+len(delim)] != delim || text[j-1] == ' ' || text[j-1] == '\\' {
+			continue
+		}
+		end := j + len(delim)
+		if delim[0] == '_' && end < len(text) && isWordByte(text[end]) {
+			continue
+		}
+		tag := "em"
+		if len(delim) == 2 {
+			tag = "strong"
+		}
+		return "<" + tag + ">" + inlineLinks(text[start:j], links) + "</" + tag + ">", end - i
+	}
+	return "", 0
+}
+
+// link renders [text](url) at the start of s, returning the HTML and the bytes
+// consumed. Links to unsafe URLs keep their text but lose the link.
+func link(s string) (string, int) {
+	depth := 0
+	closeText := -1
+	for j := 1; j < len(s) && closeText < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			if depth == 0 {
+				closeText = j
+			}
+			depth--
+		}
+	}
+	if closeText < 0 || closeText+1 >= len(s) || s[closeText+1] != '(' {
+		return "", 0
+	}
+	closeURL := strings.IndexByte(s[closeText+2:], ')')
+	if closeURL < 0 {
+		return "", 0
+	}
+	href := strings.TrimSpace(s[closeText+2 : closeText+2+closeURL])
+	text := inlineLinks(s[1:closeText], false)
+	n := closeText + 2 + closeURL + 1
+	if !SafeURL(href) {
+		return text, n
+	}
+	return anchor(href, text), n
+}
+
+// anchor builds a link that opens without passing on the referrer
+func anchor(href, text string) string {
+	return `<a href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer">` + text + "</a>"
+}
+
+// SafeURL reports whether href may be used as a link target: http, https, and
+// mailto URLs, and relative references. javascript:, data:, and other schemes
+// are rejected.
+func SafeURL(href string) bool {
+	if href == "" || strings.ContainsAny(href, " \t\n") {
+		return false
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "":
+		// A colon before any slash would be read as a scheme by browsers
+		if colon := strings.IndexByte(href, ':'); colon >= 0 {
+			slash := strings.IndexAny(href, "/?#")
+			return slash >= 0 && slash < colon
+		}
+		return true
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,85 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"paragraph", "Summarizes tickets.\nKeep it short.", "<p>Summarizes tickets.\nKeep it short.</p>\n"},
+		{"heading", "## Runbook ##", "<h2>Runbook</h2>\n"},
+		{"hashtag is not a heading", "#support", "<p>#support</p>\n"},
+		{"emphasis", "**Owner:** _search_ team, *on call*", "<p><strong>Owner:</strong> <em>search</em> team, <em>on call</em></p>\n"},
+		{"snake_case stays literal", "set max_tokens_limit", "<p>set max_tokens_limit</p>\n"},
+		{"spaced asterisks stay literal", "2 * 3 * 4", "<p>2 * 3 * 4</p>\n"},
+		{"code span", "Use `{{name}} <b>`", "<p>Use <code>{{name}} &lt;b&gt;</code></p>\n"},
+		{"escape", `\*not em\*`, "<p>*not em*</p>\n"},
+		{"link", "See [the runbook](https://wiki.example.com/runbooks/search?x=1&y=2).",
+			`<p>See <a href="https://wiki.example.com/runbooks/search?x=1&amp;y=2" rel="nofollow noopener noreferrer">the runbook</a>.</p>` + "\n"},
+		{"relative link", "[docs](/docs#render)", `<p><a href="/docs#render" rel="nofollow noopener noreferrer">docs</a></p>` + "\n"},
+		{"mailto link", "[owner](mailto:search@example.com)", `<p><a href="mailto:search@example.com" rel="nofollow noopener noreferrer">owner</a></p>` + "\n"},
+		{"bare URL", "Dashboard: https://grafana.example.com/d/abc.", `<p>Dashboard: <a href="https://grafana.example.com/d/abc" rel="nofollow noopener noreferrer">https://grafana.example.com/d/abc</a>.</p>` + "\n"},
+		{"bracketed URL", "<https://example.com>", `<p><a href="https://example.com" rel="nofollow noopener noreferrer">https://example.com</a></p>` + "\n"},
+		{"link text is not relinked", "[https://a.example](https://b.example)", `<p><a href="https://b.example" rel="nofollow noopener noreferrer">https://a.example</a></p>` + "\n"},
+		{"bullets", "- one\n- two\n  continued\n\nafter", "<ul>\n<li>one</li>\n<li>two\ncontinued</li>\n</ul>\n<p>after</p>\n"},
+		{"numbered", "3. three\n4. four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{"quote", "> **Note:** deprecated\n> use v2", "<blockquote>\n<p><strong>Note:</strong> deprecated\nuse v2</p>\n</blockquote>\n"},
+		{"rule", "above\n\n---\n\nbelow", "<p>above</p>\n<hr>\n<p>below</p>\n"},
+		{"fence", "```json\n{\"a\": \"<b>\"}\n```\nafter", "<pre><code class=\"language-json\">{&#34;a&#34;: &#34;&lt;b&gt;&#34;}\n</code></pre>\n<p>after</p>\n"},
+		{"unclosed fence", "```\ncode", "<pre><code>code\n</code></pre>\n"},
+		{"CRLF", "a\r\nb", "<p>a\nb</p>\n"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := ToHTML(tt.src); got != tt.want {
+			t.Errorf("%s: ToHTML(%q)\n got %q\nwant %q", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestToHTML_Sanitizes(t *testing.T) {
+	for _, src := range []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror="alert(1)">`,
+		`[click](javascript:alert(1))`,
+		`[click](JavaScript:alert(1))`,
+		`[click](data:text/html;base64,PHNjcmlwdD4=)`,
+		`[click](vbscript:msgbox)`,
+		`[x](https://example.com" onmouseover="alert(1))`,
+		"```\"><script>alert(1)</script>\n```",
+		`**<iframe src="https://evil.example">**`,
+	} {
+		got := strings.ToLower(ToHTML(src))
+		for _, bad := range []string{"<script", "<img", "<iframe", `href="javascript:`, `href="data:`, `href="vbscript:`, `" on`} {
+			if strings.Contains(got, bad) {
+				t.Errorf("ToHTML(%q) = %q contains %q", src, got, bad)
+			}
+		}
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	for href, want := range map[string]bool{
+		"https://example.com":     true,
+		"http://example.com/a?b":  true,
+		"mailto:team@example.com": true,
+		"/docs":                   true,
+		"#section":                true,
+		"runbooks/search.md":      true,
+		"./a:b":                   true,
+		"javascript:alert(1)":     false,
+		" javascript:alert(1)":    false,
+		"data:text/html,x":        false,
+		"ftp://example.com":       false,
+		"":                        false,
+	} {
+		if got := SafeURL(href); got != want {
+			t.Errorf("SafeURL(%q) = %v, want %v", href, got, want)
+		}
+	}
+}
@@ -8,11 +8,49 @@ type Prompt struct {
 	Slug           string    `json:"slug"`
 	Title          string    `json:"title"`
 	Description    string    `json:"description"`
+	Public         bool      `json:"public"`
 	CurrentVersion int       `json:"current_version"`
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 }
 
+// Project groups prompts so teams can use the same slugs without colliding
+type Project struct {
+	Name            string `json:"name"`
+	Org             string `json:"org,omitempty"` // owning organization; empty when open to everyone
+	Prompts         int    `json:"prompts"`       // readable by the caller, including archived prompts
+	ArchivedPrompts int    `json:"archived_prompts"`
+}
+
+// Org is an organization, such as a business unit. Projects it owns are only
+// visible to its members.
+type Org struct {
+	Name      string      `json:"name"`
+	CreatedBy string      `json:"created_by"`
+	CreatedAt time.Time   `json:"created_at"`
+	Projects  []string    `json:"projects"`
+	Members   []OrgMember `json:"members,omitempty"` // only on single-org lookups
+}
+
+// OrgMember is one authenticated subject's membership in an org
+type OrgMember struct {
+	Subject   string    `json:"subject"`
+	Role      string    `json:"role"` // "owner" or "member"
+	InvitedBy string    `json:"invited_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateOrgInput represents the request body for creating an org
+type CreateOrgInput struct {
+	Name string `json:"name"`
+}
+
+// AddOrgMemberInput represents the request body for inviting a member
+type AddOrgMemberInput struct {
+	Subject string `json:"subject"`
+	Role    string `json:"role,omitempty"` // defaults to "member"
+}
+
 // PromptVersion represents an immutable version of a prompt
 type PromptVersion struct {
 	ID            int64     `json:"id"`
@@ -20,6 +58,36 @@ type PromptVersion struct {
 	VersionNumber int       `json:"version_number"`
 	Content       string    `json:"content"`
 	CreatedAt     time.Time `json:"created_at"`
+	CreatedBy     string    `json:"created_by,omitempty"`
+	// Pinned versions are kept forever: retention and pruning must skip them
+	Pinned bool `json:"pinned"`
+	// OriginalCreatedAt is when the version was created in the system it was imported from
+	OriginalCreatedAt *time.Time `json:"original_created_at,omitempty"`
+	// RedactedAt is set once an admin has replaced the content with a
+	// redaction notice; OriginalSHA256 is the hex SHA-256 of the content it
+	// replaced, so a copy can still be matched against the original
+	RedactedAt     *time.Time `json:"redacted_at,omitempty"`
+	OriginalSHA256 string     `json:"original_sha256,omitempty"`
+	// ContentSHA256 is the hex SHA-256 of Content, computed when the version
+	// is stored, so deployed text can be matched to the reviewed version
+	ContentSHA256 string `json:"content_sha256"`
+	// Messages is the decoded content of a chat prompt's version; Content
+	// holds the same messages as JSON
+	Messages []Message `json:"messages,omitempty"`
+	// ModelConfig holds the model parameters the version was written for
+	ModelConfig *ModelConfig `json:"model_config,omitempty"`
+	// Metadata holds custom fields set when the version was created
+	Metadata map[string]any `json:"metadata,omitempty"`
+	// ReviewStatus is "pending" while a version created under a review
+	// policy waits for approvals and "approved" once it has them; empty for
+	// versions that needed no review
+	ReviewStatus string `json:"review_status,omitempty"`
+}
+
+// Message is one role-tagged message in a chat prompt
+type Message struct {
+	Role    string `json:"role"` // "system", "user", or "assistant"
+	Content string `json:"content"`
 }
 
 // PromptSummary represents a prompt in list view
@@ -27,17 +95,404 @@ type PromptSummary struct {
 	Slug           string    `json:"slug"`
 	Title          string    `json:"title"`
 	Description    string    `json:"description"`
+	Public         bool      `json:"public"`
 	CurrentVersion int       `json:"current_version"`
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
+	// OriginalCreatedAt is when the prompt was created in the system it was imported from
+	OriginalCreatedAt *time.Time `json:"original_created_at,omitempty"`
+	// ArchivedAt is set while the prompt is archived and hidden from listings
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
 }
 
 // PromptWithCurrentVersion represents a prompt with its current version
 type PromptWithCurrentVersion struct {
-	Slug           string        `json:"slug"`
-	Title          string        `json:"title"`
-	Description    string        `json:"description"`
-	CurrentVersion PromptVersion `json:"current_version"`
+	Slug           string           `json:"slug"`
+	Title          string           `json:"title"`
+	Description    string           `json:"description"`
+	Format         string           `json:"format"` // "text" or "chat"
+	Public         bool             `json:"public"`
+	CurrentVersion PromptVersion    `json:"current_version"`
+	Variables      []Variable       `json:"variables,omitempty"`
+	Execution      *ExecutionConfig `json:"execution,omitempty"`
+	CreatedAt      time.Time        `json:"created_at,omitzero"`
+	UpdatedAt      time.Time        `json:"updated_at,omitzero"`
+	CreatedBy      string           `json:"created_by,omitempty"`
+	UpdatedBy      string           `json:"updated_by,omitempty"`
+	// OriginalCreatedAt is when the prompt was created in the system it was imported from
+	OriginalCreatedAt *time.Time `json:"original_created_at,omitempty"`
+	// ArchivedAt is set while the prompt is archived and hidden from listings
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	// LegalHoldAt is set while the prompt and all its versions are under legal
+	// hold and must not be deleted, purged, or pruned
+	LegalHoldAt     *time.Time `json:"legal_hold_at,omitempty"`
+	LegalHoldReason string     `json:"legal_hold_reason,omitempty"`
+	// Owner manages the prompt's access grants; empty for prompts created
+	// before ownership or outside an API request
+	Owner string `json:"owner,omitempty"`
+	// DocsRevision is the latest revision of the prompt's docs; 0 if undocumented
+	DocsRevision int `json:"docs_revision,omitempty"`
+	// ForkedFrom is set when the prompt was forked from another in its project
+	ForkedFrom *ForkOrigin `json:"forked_from,omitempty"`
+	// Metadata holds custom fields, such as an owning team or a ticket, set
+	// when the prompt was created
+	Metadata map[string]any `json:"metadata,omitempty"`
+	// Unchanged is set when a deduplicated version create found the content
+	// already current and created nothing
+	Unchanged bool `json:"unchanged,omitempty"`
+	// PendingVersion is set when a version create under a review policy
+	// stored a version that becomes current only once it is approved
+	PendingVersion *PromptVersion `json:"pending_version,omitempty"`
+	// PreviousVersion is the version a version create replaced as current;
+	// 0 when nothing was replaced
+	PreviousVersion int `json:"previous_version,omitempty"`
+}
+
+// PublicPrompt is what the public gallery shows of a prompt: its content and
+// what it's for, without owners, authors, legal holds, or metadata
+type PublicPrompt struct {
+	Slug           string              `json:"slug"`
+	Title          string              `json:"title"`
+	Description    string              `json:"description"`
+	Format         string              `json:"format"` // "text" or "chat"
+	CurrentVersion PublicPromptVersion `json:"current_version"`
+}
+
+// PublicPromptVersion is what the public gallery shows of a version
+type PublicPromptVersion struct {
+	VersionNumber int       `json:"version_number"`
+	Content       string    `json:"content"`
+	Messages      []Message `json:"messages,omitempty"`
+	ContentSHA256 string    `json:"content_sha256"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ForkOrigin is the prompt and version a fork was copied from
+type ForkOrigin struct {
+	Slug    string `json:"slug"`
+	Version int    `json:"version"`
+}
+
+// ForkPromptInput represents the request body for forking a prompt
+type ForkPromptInput struct {
+	Slug    string `json:"slug"`
+	Title   string `json:"title,omitempty"`   // defaults to the source prompt's title
+	History bool   `json:"history,omitempty"` // copy every version, not just the current one
+}
+
+// PromptDocs is one revision of a prompt's long-form Markdown documentation.
+// Docs are revised separately from the prompt's content versions.
+type PromptDocs struct {
+	Revision  int       `json:"revision"`
+	Content   string    `json:"content"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// SetDocsInput represents the request body for saving a prompt's docs
+type SetDocsInput struct {
+	Content string `json:"content"`
+}
+
+// VersionComment is a reviewer's comment on one version of a prompt
+type VersionComment struct {
+	ID            int64     `json:"id"`
+	VersionNumber int       `json:"version_number"`
+	Body          string    `json:"body"`
+	CreatedBy     string    `json:"created_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AddCommentInput represents the request body for commenting on a version
+type AddCommentInput struct {
+	Body string `json:"body"`
+}
+
+// PromptUsage counts how often a prompt's versions have been fetched,
+// rendered, or executed through the API
+type PromptUsage struct {
+	Slug          string         `json:"slug"`
+	Fetches       int64          `json:"fetches"`
+	LastFetchedAt *time.Time     `json:"last_fetched_at,omitempty"`
+	Versions      []VersionUsage `json:"versions"`
+}
+
+// VersionUsage counts fetches of one version; LastFetchedAt is unset for a
+// version that has never been fetched
+type VersionUsage struct {
+	VersionNumber int        `json:"version_number"`
+	Fetches       int64      `json:"fetches"`
+	LastFetchedAt *time.Time `json:"last_fetched_at,omitempty"`
+}
+
+// Analytics summarizes a project's prompts for a registry health dashboard
+type Analytics struct {
+	Prompts         int              `json:"prompts"`
+	Versions        int              `json:"versions"`
+	Fetches         int64            `json:"fetches"`
+	StaleDays       int              `json:"stale_days"`
+	MostFetched     []PromptActivity `json:"most_fetched"`
+	RecentlyChanged []PromptActivity `json:"recently_changed"`
+	Stale           []PromptActivity `json:"stale"` // not fetched in StaleDays, never-fetched first
+}
+
+// PromptActivity is one prompt in an analytics list
+type PromptActivity struct {
+	Slug           string     `json:"slug"`
+	Title          string     `json:"title"`
+	CurrentVersion int        `json:"current_version"`
+	Versions       int        `json:"versions"`
+	Fetches        int64      `json:"fetches"`
+	LastFetchedAt  *time.Time `json:"last_fetched_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// RegistryStats breaks a project's prompts down for capacity planning
+type RegistryStats struct {
+	Prompts           int                  `json:"prompts"`
+	Versions          int                  `json:"versions"`
+	ContentBytes      int64                `json:"content_bytes"`  // version content, summed
+	DatabaseBytes     int64                `json:"database_bytes"` // the whole database, every project
+	Labels            []LabelCount         `json:"labels"`
+	VersionsPerPrompt []VersionCountBucket `json:"versions_per_prompt"`
+	Largest           []PromptSize         `json:"largest"`
+	Days              int                  `json:"days"`
+	Created           []CreationCount      `json:"created"` // one per day, oldest first
+}
+
+// LabelCount is how many prompts have a label
+type LabelCount struct {
+	Label   string `json:"label"`
+	Prompts int    `json:"prompts"`
+}
+
+// VersionCountBucket is how many prompts have between Min and Max versions
+type VersionCountBucket struct {
+	Min     int `json:"min"`
+	Max     int `json:"max,omitempty"` // 0 for no upper bound
+	Prompts int `json:"prompts"`
+}
+
+// PromptSize is one prompt in the largest prompts list
+type PromptSize struct {
+	Slug         string `json:"slug"`
+	Title        string `json:"title"`
+	Versions     int    `json:"versions"`
+	ContentBytes int64  `json:"content_bytes"`
+}
+
+// CreationCount is how many prompts and versions were created on a day
+type CreationCount struct {
+	Date     string `json:"date"` // YYYY-MM-DD, UTC
+	Prompts  int    `json:"prompts"`
+	Versions int    `json:"versions"`
+}
+
+// PromptGrant gives a user, or every member of an org, access to a prompt
+type PromptGrant struct {
+	Type      string    `json:"type"`   // "user" or "org"
+	Name      string    `json:"name"`   // authenticated subject or org name
+	Access    string    `json:"access"` // "read" or "write"; write includes read
+	GrantedBy string    `json:"granted_by,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// PromptACL is a prompt's owner and access grants. A prompt without grants
+// is open to every caller; with grants, only the owner and grantees see it.
+type PromptACL struct {
+	Owner  string        `json:"owner"`
+	Grants []PromptGrant `json:"grants"`
+}
+
+// SetPromptACLInput replaces a prompt's grants
+type SetPromptACLInput struct {
+	// Owner transfers ownership; empty keeps the current owner
+	Owner  string        `json:"owner,omitempty"`
+	Grants []PromptGrant `json:"grants"`
+}
+
+// PromptLink is a relationship between two prompts, named from the prompt
+// at the other end
+type PromptLink struct {
+	Slug      string    `json:"slug"`
+	Kind      string    `json:"kind"` // "includes" or "derived_from"
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// PromptLinks is a prompt's links to the prompts it depends on, and the
+// links from prompts that depend on it
+type PromptLinks struct {
+	Links      []PromptLink `json:"links"`
+	Dependents []PromptLink `json:"dependents"`
+}
+
+// SetPromptLinksInput replaces the links a prompt declares
+type SetPromptLinksInput struct {
+	Links []PromptLink `json:"links"`
+}
+
+// RetentionPolicy bounds how long a prompt keeps old versions. A version is
+// pruned only when every rule that is set would drop it; a policy with no
+// rules set keeps everything.
+type RetentionPolicy struct {
+	KeepVersions int `json:"keep_versions"` // newest versions to keep; 0 for no limit
+	KeepDays     int `json:"keep_days"`     // keep versions created within this many days; 0 for no limit
+}
+
+// PromptRetention reports the retention policy applying to a prompt and the
+// versions the next pruning run would delete
+type PromptRetention struct {
+	Policy    *RetentionPolicy `json:"policy"` // set on the prompt; null when it follows the default
+	Effective RetentionPolicy  `json:"effective"`
+	Prunable  []int            `json:"prunable"`
+}
+
+// ReviewPolicy says how many designated reviewers must approve a prompt's
+// new versions before one becomes current. Authors can't approve their own
+// versions, so any policy means two-person review.
+type ReviewPolicy struct {
+	RequiredApprovals int      `json:"required_approvals"` // 0 when versions need no review
+	Reviewers         []string `json:"reviewers"`
+}
+
+// VersionReview is the review state of one version and its approvals
+type VersionReview struct {
+	Version           int        `json:"version"`
+	Status            string     `json:"status"` // "pending", "approved", or empty when no review was needed
+	RequiredApprovals int        `json:"required_approvals"`
+	Approvals         []Approval `json:"approvals"`
+	// PreviousVersion is the version that was current before this approval
+	// made the version current; 0 when it didn't become current
+	PreviousVersion int `json:"previous_version,omitempty"`
+}
+
+// Approval is one reviewer's sign-off on a version
+type Approval struct {
+	Reviewer  string    `json:"reviewer"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ApproveVersionInput represents the request body for approving a version
+type ApproveVersionInput struct {
+	Comment string `json:"comment,omitempty"`
+}
+
+// PrunedPrompt lists the versions pruned, or that would be, from one prompt
+type PrunedPrompt struct {
+	Project  string `json:"project"`
+	Slug     string `json:"slug"`
+	Versions []int  `json:"versions"`
+}
+
+// PruneResult reports a retention pruning run
+type PruneResult struct {
+	DryRun     bool           `json:"dry_run"`
+	Versions   int            `json:"versions"` // total across prompts
+	Prompts    []PrunedPrompt `json:"prompts"`
+	DurationMs int64          `json:"duration_ms"`
+}
+
+// AuditEntry records one change to a prompt and who made it
+type AuditEntry struct {
+	ID      int64  `json:"id"`
+	Project string `json:"project"`
+	Slug    string `json:"slug"`
+	Actor   string `json:"actor"`  // authenticated subject; empty outside a request
+	Action  string `json:"action"` // e.g. "prompt.version_created"
+	Version int    `json:"version,omitempty"`
+	// Detail holds the new value for settings changes, e.g. "public" or a webhook URL
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExecutionConfig selects the provider and model a prompt runs on by default
+type ExecutionConfig struct {
+	Provider string `json:"provider,omitempty"` // e.g. "openai", "anthropic", "local"
+	Model    string `json:"model,omitempty"`
+}
+
+// ModelConfig holds model parameters saved with a prompt version. Execute
+// uses them unless the request overrides them; empty fields are left to the
+// prompt's execution config and the provider.
+type ModelConfig struct {
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"` // stop sequences
+}
+
+// Variable declares one input a prompt expects
+type Variable struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // string, number, integer, or boolean
+	Required    bool   `json:"required"`
+	Description string `json:"description,omitempty"`
+}
+
+// Capabilities describes what this registry has enabled and the limits it
+// enforces, so clients can adapt before a request is rejected
+type Capabilities struct {
+	APIVersion string             `json:"api_version"`
+	Features   CapabilityFeatures `json:"features"`
+	Limits     CapabilityLimits   `json:"limits"`
+	Auth       CapabilityAuth     `json:"auth"`
+}
+
+// CapabilityFeatures lists the optional features and whether they are enabled
+type CapabilityFeatures struct {
+	Execution     bool     `json:"execution"` // POST /prompts/{slug}/execute and evals
+	Providers     []string `json:"providers"` // LLM providers execution can use
+	GitSync       bool     `json:"git_sync"`
+	PublicGallery bool     `json:"public_gallery"`
+	Admin         bool     `json:"admin"`
+	Backups       bool     `json:"backups"`   // scheduled backups
+	ReadOnly      bool     `json:"read_only"` // changes are rejected with 503
+	// SemanticSearch is GET /prompts/semantic-search, with prompts embedded
+	// by SemanticSearchModel
+	SemanticSearch      bool   `json:"semantic_search"`
+	SemanticSearchModel string `json:"semantic_search_model,omitempty"`
+}
+
+// CapabilityLimits lists the limits requests are checked against
+type CapabilityLimits struct {
+	// MaxContentBytes is the largest prompt version content accepted; 0 means no limit
+	MaxContentBytes int `json:"max_content_bytes"`
+	// MaxBodyBytes is the largest request body accepted; 0 means no limit
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+	// MaxTitleLength and MaxDescriptionLength are in characters; 0 means no limit
+	MaxTitleLength       int        `json:"max_title_length"`
+	MaxDescriptionLength int        `json:"max_description_length"`
+	MaxMetadataBytes     int        `json:"max_metadata_bytes"`  // JSON size of prompt or version metadata; 0 means no limit
+	MaxImportVersions    int        `json:"max_import_versions"` // versions per batch or imported prompt
+	MaxReleaseItems      int        `json:"max_release_items"`
+	MaxCommentLength     int        `json:"max_comment_length"`   // characters in a version comment
+	RateLimit            *RateLimit `json:"rate_limit,omitempty"` // omitted when requests aren't limited
+}
+
+// RateLimit is a token bucket limit applied per caller
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+// CapabilityAuth describes how API requests authenticate
+type CapabilityAuth struct {
+	Method string `json:"method"` // the method this registry uses
+	// Scheme is how to send credentials: bearer or client_certificate.
+	// Empty for none and for custom methods.
+	Scheme  string   `json:"scheme,omitempty"`
+	Methods []string `json:"methods"` // methods this build supports
+	// CSRF is set when browser writes without an Authorization or X-API-Key
+	// header must send the token from GET /api/csrf
+	CSRF bool `json:"csrf"`
+}
+
+// CSRFToken is the token a browser sends with state-changing requests
+type CSRFToken struct {
+	Token  string `json:"token"`
+	Header string `json:"header"` // the request header to send it in
 }
 
 // Stats represents system-wide statistics
@@ -46,15 +501,670 @@ type Stats struct {
 	TotalPromptVersions int `json:"total_prompt_versions"`
 }
 
+// Readiness reports whether the instance can serve traffic
+type Readiness struct {
+	Status string           `json:"status"` // "ready" or "not_ready"
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+// ReadinessCheck is the result of one readiness check
+type ReadinessCheck struct {
+	Name       string `json:"name"` // "database", "schema", or "write"
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// CompactResult reports the effect of compacting the database
+type CompactResult struct {
+	SizeBeforeBytes int64 `json:"size_before_bytes"`
+	SizeAfterBytes  int64 `json:"size_after_bytes"`
+	ReclaimedBytes  int64 `json:"reclaimed_bytes"`
+	DurationMs      int64 `json:"duration_ms"`
+}
+
+// SemanticSearchResult is a prompt found by semantic search
+type SemanticSearchResult struct {
+	Slug           string `json:"slug"`
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	CurrentVersion int    `json:"current_version"`
+	// Score is the cosine similarity between the query and the prompt, up to 1
+	Score float64 `json:"score"`
+}
+
+// EmbeddingSource is a prompt whose embedding is missing or out of date,
+// with the text to embed
+type EmbeddingSource struct {
+	PromptID     int64
+	Project      string
+	Slug         string
+	Text         string
+	SourceSHA256 string // digest of Text, recorded with the vector
+}
+
+// ReadOnlyStatus reports whether the registry is rejecting changes
+type ReadOnlyStatus struct {
+	ReadOnly bool       `json:"read_only"`
+	Since    *time.Time `json:"since,omitempty"` // when read-only mode was turned on
+}
+
+// DeletedPrompt identifies a prompt deleted with all its history
+type DeletedPrompt struct {
+	Project  string `json:"project"`
+	Slug     string `json:"slug"`
+	Versions int    `json:"versions"`
+}
+
+// PurgeResult reports archived prompts deleted by a purge
+type PurgeResult struct {
+	DryRun     bool            `json:"dry_run"`
+	Prompts    []DeletedPrompt `json:"prompts"`
+	DurationMs int64           `json:"duration_ms"`
+}
+
+// RecomputeResult reports derived columns corrected by a recompute
+type RecomputeResult struct {
+	CurrentVersions int `json:"current_versions"` // prompts whose current version no longer existed
+	ContentHashes   int `json:"content_hashes"`   // versions whose missing hash was filled in
+	// HashMismatches counts versions whose stored hash doesn't match their
+	// content. They are logged and left alone, since the hash vouches for
+	// what the content was.
+	HashMismatches int   `json:"hash_mismatches"`
+	DurationMs     int64 `json:"duration_ms"`
+}
+
+// BackupStatus reports scheduled database backups in /health
+type BackupStatus struct {
+	Status        string     `json:"status"` // "ok", "failing", or "pending" before the first run
+	Schedule      string     `json:"schedule"`
+	Keep          int        `json:"keep"`
+	LastFile      string     `json:"last_file,omitempty"` // most recent successful backup
+	LastSizeBytes int64      `json:"last_size_bytes,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextRunAt     *time.Time `json:"next_run_at,omitempty"`
+}
+
+// Webhook is a URL notified about changes to a single prompt
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateWebhookInput represents input for registering a prompt webhook
+type CreateWebhookInput struct {
+	URL string `json:"url"`
+}
+
+// WebhookEvent is the JSON body POSTed to webhooks when a prompt changes
+type WebhookEvent struct {
+	Event   string    `json:"event"`
+	Project string    `json:"project,omitempty"`
+	Slug    string    `json:"slug"`
+	Version int       `json:"version,omitempty"` // current version after the change
+	Time    time.Time `json:"time"`
+	// Changes summarizes a prompt.version_created event against the version before it
+	Changes *VersionChanges `json:"changes,omitempty"`
+	// Text is a one-line description for chat webhooks such as Slack's
+	Text string `json:"text"`
+}
+
+// VersionChanges is a compact diff summary for notifications, so reviewers
+// can triage a new version without opening the registry
+type VersionChanges struct {
+	FromVersion      int      `json:"from_version"`
+	LinesAdded       int      `json:"lines_added"`
+	LinesRemoved     int      `json:"lines_removed"`
+	VariablesAdded   []string `json:"variables_added,omitempty"`
+	VariablesRemoved []string `json:"variables_removed,omitempty"`
+	// Token counts are estimates, about 4 characters per token
+	TokensBefore int    `json:"tokens_before"`
+	TokensAfter  int    `json:"tokens_after"`
+	TokenDelta   int    `json:"token_delta"`
+	Summary      string `json:"summary"` // e.g. "+3 -1 lines, variables +tone -style, +12 tokens"
+}
+
+// VersionDiff is the difference between two versions of a prompt, as
+// hunks of changed lines with unchanged lines around them for context
+type VersionDiff struct {
+	Slug         string     `json:"slug"`
+	FromVersion  int        `json:"from_version"` // 0 compares against empty content
+	ToVersion    int        `json:"to_version"`
+	LinesAdded   int        `json:"lines_added"`
+	LinesRemoved int        `json:"lines_removed"`
+	Hunks        []DiffHunk `json:"hunks"` // empty when the versions match
+}
+
+// DiffHunk is a run of changed lines. Starts are 1-based line numbers; a
+// side with no lines starts at the line before.
+type DiffHunk struct {
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// DiffLine is one line of a hunk
+type DiffLine struct {
+	Op   string `json:"op"` // "equal", "delete", or "insert"
+	Text string `json:"text"`
+}
+
+// ExportFormatVersion is the current registry export format
+const ExportFormatVersion = 1
+
+// Export is a full registry export as served by GET /api/export. IDs are
+// left out so an export can be loaded into another environment.
+type Export struct {
+	FormatVersion int              `json:"format_version"`
+	ExportedAt    time.Time        `json:"exported_at"`
+	Prompts       []ExportedPrompt `json:"prompts"`
+}
+
+// ExportedPrompt is a prompt with its full version history, oldest version first
+type ExportedPrompt struct {
+	Slug              string            `json:"slug"`
+	Title             string            `json:"title"`
+	Description       string            `json:"description"`
+	Format            string            `json:"format,omitempty"` // empty means "text"
+	Public            bool              `json:"public"`
+	Variables         []Variable        `json:"variables,omitempty"`
+	Execution         *ExecutionConfig  `json:"execution,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+	OriginalCreatedAt *time.Time        `json:"original_created_at,omitempty"`
+	ArchivedAt        *time.Time        `json:"archived_at,omitempty"`
+	Docs              string            `json:"docs,omitempty"` // latest docs revision
+	Metadata          map[string]any    `json:"metadata,omitempty"`
+	Versions          []ExportedVersion `json:"versions"`
+}
+
+// ExportedVersion is one version of an exported prompt
+type ExportedVersion struct {
+	VersionNumber     int            `json:"version_number"`
+	Content           string         `json:"content"`
+	CreatedAt         time.Time      `json:"created_at"`
+	OriginalCreatedAt *time.Time     `json:"original_created_at,omitempty"`
+	Pinned            bool           `json:"pinned,omitempty"`
+	ModelConfig       *ModelConfig   `json:"model_config,omitempty"`
+	Metadata          map[string]any `json:"metadata,omitempty"`
+}
+
+// BatchGetPromptsInput names the prompts to fetch in one request
+type BatchGetPromptsInput struct {
+	Slugs []string `json:"slugs"`
+}
+
+// BatchGetPromptsResult holds the prompts a batch get found, in the order
+// requested
+type BatchGetPromptsResult struct {
+	Prompts []PromptWithCurrentVersion `json:"prompts"`
+	Missing []string                   `json:"missing"` // slugs that don't exist or can't be read
+}
+
+// ImportResult reports which prompts a registry import created
+type ImportResult struct {
+	Imported []string `json:"imported"` // slugs created
+	Skipped  []string `json:"skipped"`  // slugs that already existed and were left untouched
+	Versions int      `json:"versions"` // versions created across all imported prompts
+}
+
+// Release promotes a set of prompt versions to a label, such as
+// "production", all at once. Rolling it back restores every label it moved.
+type Release struct {
+	ID           int64         `json:"id"`
+	Label        string        `json:"label"`
+	Note         string        `json:"note,omitempty"`
+	Items        []ReleaseItem `json:"items"`
+	CreatedBy    string        `json:"created_by,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+	RolledBackAt *time.Time    `json:"rolled_back_at,omitempty"`
+	RolledBackBy string        `json:"rolled_back_by,omitempty"`
+}
+
+// ReleaseItem is one prompt version in a release
+type ReleaseItem struct {
+	Slug    string `json:"slug"`
+	Version int    `json:"version"`
+	// PreviousVersion is the version the label pointed at before the
+	// release; 0 if the prompt didn't have the label. Ignored on input.
+	PreviousVersion int `json:"previous_version,omitempty"`
+}
+
+// CreateReleaseInput represents the request body for creating a release
+type CreateReleaseInput struct {
+	Label string        `json:"label"`
+	Note  string        `json:"note,omitempty"`
+	Items []ReleaseItem `json:"items"`
+}
+
+// PromptLabel is a label pointing at one of a prompt's versions
+type PromptLabel struct {
+	Label     string    `json:"label"`
+	Version   int       `json:"version"`
+	ReleaseID int64     `json:"release_id"` // the release that set it
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Environment is a stage of a project's promotion pipeline, such as dev,
+// staging, or prod. Each is backed by the release label of the same name,
+// and versions are promoted into it from the environment before it.
+type Environment struct {
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`           // 1 for the first environment
+	Previous  string    `json:"previous,omitempty"` // the environment versions are promoted from
+	Next      string    `json:"next,omitempty"`     // the environment versions are promoted to
+	Prompts   int       `json:"prompts"`            // prompts with a version in the environment
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Deployments lists what is live in the environment; only set when
+	// getting a single environment
+	Deployments []Deployment `json:"deployments,omitempty"`
+}
+
+// Deployment is the version of a prompt live in an environment
+type Deployment struct {
+	Slug       string    `json:"slug"`
+	Version    int       `json:"version"`
+	ReleaseID  int64     `json:"release_id"` // the release that put it there
+	PromotedBy string    `json:"promoted_by,omitempty"`
+	PromotedAt time.Time `json:"promoted_at"`
+}
+
+// CreateEnvironmentInput represents the request body for adding an
+// environment to the end of the pipeline
+type CreateEnvironmentInput struct {
+	Name string `json:"name"`
+}
+
+// PromoteInput represents the request body for promoting a prompt version
+// into an environment
+type PromoteInput struct {
+	Environment string `json:"environment"`
+	// Version defaults to the version live in the previous environment, or
+	// the current version when promoting into the first one
+	Version int    `json:"version,omitempty"`
+	Note    string `json:"note,omitempty"`
+}
+
+// Collection groups prompts in a folder-like hierarchy, such as product area,
+// then feature. Its path names it and its ancestors.
+type Collection struct {
+	ID          int64     `json:"id"`
+	Path        string    `json:"path"`
+	Parent      string    `json:"parent,omitempty"` // path of the parent collection
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Prompts     int       `json:"prompts"` // prompts directly in the collection
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateCollectionInput represents the request body for creating a collection
+type CreateCollectionInput struct {
+	Path        string `json:"path"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Dataset is a named set of input/expected pairs used to evaluate prompts
+type Dataset struct {
+	ID          int64         `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	ItemCount   int           `json:"item_count"`
+	Items       []DatasetItem `json:"items,omitempty"` // only returned for a single dataset
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// DatasetItem is one evaluation case: variables to render the prompt with
+// and the output the model is expected to produce
+type DatasetItem struct {
+	ID       int64          `json:"id,omitempty"`
+	Input    map[string]any `json:"input"`
+	Expected string         `json:"expected"`
+}
+
+// CreateDatasetInput represents input for creating a dataset
+type CreateDatasetInput struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Items       []DatasetItem `json:"items"`
+}
+
+// StartEvalInput represents input for evaluating a prompt version against a dataset
+type StartEvalInput struct {
+	Dataset     string `json:"dataset"`
+	Version     int    `json:"version,omitempty"`     // optional, defaults to the current version
+	Provider    string `json:"provider,omitempty"`    // optional, defaults to the prompt's then the server's provider
+	Model       string `json:"model,omitempty"`       // optional, defaults to the prompt's then the provider's model
+	Scorer      string `json:"scorer,omitempty"`      // optional, "exact" (default) or "contains"
+	Concurrency int    `json:"concurrency,omitempty"` // optional, parallel provider calls
+}
+
+// EvalRun is one evaluation of a prompt version against a dataset. Counts and
+// score are computed from the results recorded so far, so they show progress
+// while the run is still going.
+type EvalRun struct {
+	ID            int64        `json:"id"`
+	Slug          string       `json:"slug"`
+	VersionNumber int          `json:"version_number"`
+	Dataset       string       `json:"dataset"`
+	Provider      string       `json:"provider"`
+	Model         string       `json:"model"`
+	Scorer        string       `json:"scorer"`
+	Status        string       `json:"status"` // running, completed, or failed
+	Error         string       `json:"error,omitempty"`
+	Total         int          `json:"total"`
+	Completed     int          `json:"completed"`
+	Passed        int          `json:"passed"`
+	Score         float64      `json:"score"`             // mean item score, 0 to 1
+	Results       []EvalResult `json:"results,omitempty"` // only returned for a single run
+	CreatedAt     time.Time    `json:"created_at"`
+	FinishedAt    *time.Time   `json:"finished_at,omitempty"`
+}
+
+// EvalResult is the scored outcome of one dataset item
+type EvalResult struct {
+	ItemID     int64          `json:"item_id"`
+	Input      map[string]any `json:"input"`
+	Expected   string         `json:"expected"`
+	Output     string         `json:"output"`
+	Score      float64        `json:"score"`
+	Passed     bool           `json:"passed"`
+	Error      string         `json:"error,omitempty"`
+	DurationMs int64          `json:"duration_ms"`
+}
+
+// IntegrationStatus reports delivery health for one optional integration
+type IntegrationStatus struct {
+	Name          string     `json:"name"`
+	Status        string     `json:"status"` // ok, or failing when the latest delivery failed
+	Delivered     int64      `json:"delivered"`
+	Failed        int64      `json:"failed"`
+	Retries       int64      `json:"retries"`
+	InFlight      int64      `json:"in_flight"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+// SyncInput represents input for a Git sync
+type SyncInput struct {
+	Direction string `json:"direction,omitempty"` // optional, "push", "pull", or "both" (default)
+}
+
+// SyncResult reports what a Git sync changed
+type SyncResult struct {
+	Direction  string          `json:"direction"`
+	Imported   []SyncedVersion `json:"imported"`  // versions created from changed files
+	Committed  []SyncedVersion `json:"committed"` // versions committed to the repository
+	Errors     []string        `json:"errors"`    // prompts or files that were skipped
+	Pushed     bool            `json:"pushed"`
+	Commit     string          `json:"commit,omitempty"` // HEAD after the sync
+	DurationMs int64           `json:"duration_ms"`
+}
+
+// SyncedVersion identifies one prompt version moved by a sync
+type SyncedVersion struct {
+	Slug    string `json:"slug"`
+	Version int    `json:"version"`
+}
+
+// ReopenInput represents input for switching the registry to another database file
+type ReopenInput struct {
+	Path string `json:"path,omitempty"` // optional, defaults to the current file
+}
+
+// RestoreInput selects the backup to restore
+type RestoreInput struct {
+	Path string `json:"path"` // backup file; a bare file name is looked up in the backup directory
+}
+
+// RestoreResult reports a completed restore
+type RestoreResult struct {
+	BackupPath   string `json:"backup_path"`
+	Path         string `json:"path"`          // database file now holding the restored data
+	PreviousCopy string `json:"previous_copy"` // where the replaced database was moved, empty if there was none
+	DurationMs   int64  `json:"duration_ms"`
+}
+
+// ReopenResult reports a completed database switch
+type ReopenResult struct {
+	PreviousPath string `json:"previous_path"`
+	Path         string `json:"path"`
+	DurationMs   int64  `json:"duration_ms"`
+}
+
+// SchemaMigration reports one schema migration and whether a database has it
+type SchemaMigration struct {
+	Version    int        `json:"version"`
+	Name       string     `json:"name"`
+	AppliedAt  *time.Time `json:"applied_at,omitempty"` // nil while pending
+	Reversible bool       `json:"reversible"`
+}
+
+// MigrateResult reports a completed migration run
+type MigrateResult struct {
+	Path        string `json:"path"`
+	FromVersion int    `json:"from_version"`
+	ToVersion   int    `json:"to_version"`
+	DurationMs  int64  `json:"duration_ms"`
+}
+
+// StartCaptureInput represents input for starting a request capture session.
+// At least one of Slug or APIKey must be set.
+type StartCaptureInput struct {
+	Slug            string `json:"slug,omitempty"`             // only capture requests for this prompt
+	APIKey          string `json:"api_key,omitempty"`          // only capture requests sending this bearer token
+	DurationSeconds int    `json:"duration_seconds,omitempty"` // optional, defaults to 10 minutes
+}
+
+// CaptureStatus describes the current capture session and what it has recorded
+type CaptureStatus struct {
+	Active    bool               `json:"active"`
+	Slug      string             `json:"slug,omitempty"`
+	APIKey    string             `json:"api_key,omitempty"` // masked to its last four characters
+	StartedAt *time.Time         `json:"started_at,omitempty"`
+	ExpiresAt *time.Time         `json:"expires_at,omitempty"`
+	Exchanges []CapturedExchange `json:"exchanges"`
+}
+
+// CapturedExchange is one recorded request/response pair
+type CapturedExchange struct {
+	Time            time.Time         `json:"time"`
+	Method          string            `json:"method"`
+	Path            string            `json:"path"`
+	Query           string            `json:"query,omitempty"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	RequestBody     string            `json:"request_body"`
+	Status          int               `json:"status"`
+	ResponseHeaders map[string]string `json:"response_headers"`
+	ResponseBody    string            `json:"response_body"`
+	Truncated       bool              `json:"truncated"` // a body exceeded the capture limit
+	DurationMs      int64             `json:"duration_ms"`
+}
+
+// ExecuteInput represents input for running a prompt against a language model
+type ExecuteInput struct {
+	Variables   map[string]any `json:"variables"`
+	Version     int            `json:"version,omitempty"`     // optional, defaults to the current version
+	Provider    string         `json:"provider,omitempty"`    // optional, defaults to the prompt's then the server's provider
+	Model       string         `json:"model,omitempty"`       // optional, defaults to the version's, the prompt's, then the provider's model
+	MaxTokens   int            `json:"max_tokens,omitempty"`  // optional, defaults to the version's
+	Temperature *float64       `json:"temperature,omitempty"` // optional, defaults to the version's
+	Stream      bool           `json:"stream,omitempty"`      // optional, respond with server-sent events
+}
+
+// ExecuteDelta is one streamed fragment of a completion
+type ExecuteDelta struct {
+	Text string `json:"text"`
+}
+
+// ExecuteResult represents a model completion for a rendered prompt
+type ExecuteResult struct {
+	Slug          string      `json:"slug"`
+	VersionNumber int         `json:"version_number"`
+	Provider      string      `json:"provider"`
+	Model         string      `json:"model"`
+	Prompt        string      `json:"prompt"`
+	Messages      []Message   `json:"messages,omitempty"` // chat prompts only
+	Completion    string      `json:"completion"`
+	FinishReason  string      `json:"finish_reason"`
+	Usage         TokenUsage  `json:"usage"`
+	DurationMs    int64       `json:"duration_ms"`
+	Provenance    *Provenance `json:"provenance,omitempty"`
+}
+
+// TokenUsage reports token counts for a completion
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// LiveStats summarizes recent traffic from the server's in-memory ring buffer
+type LiveStats struct {
+	WindowSeconds     int              `json:"window_seconds"`
+	Requests          int              `json:"requests"`
+	RequestsPerSecond float64          `json:"requests_per_second"`
+	Errors            int              `json:"errors"`        // 5xx responses
+	ClientErrors      int              `json:"client_errors"` // 4xx responses
+	ErrorRate         float64          `json:"error_rate"`    // errors / requests
+	Series            []LiveStatsPoint `json:"series"`
+	TopPrompts        []PromptHits     `json:"top_prompts"`
+}
+
+// LiveStatsPoint is one interval of the live stats series
+type LiveStatsPoint struct {
+	Time     time.Time `json:"time"`
+	Requests int       `json:"requests"`
+	Errors   int       `json:"errors"`
+}
+
+// PromptHits counts requests that touched a prompt
+type PromptHits struct {
+	Project  string `json:"project"`
+	Slug     string `json:"slug"`
+	Requests int    `json:"requests"`
+}
+
 // CreatePromptInput represents input for creating a new prompt
 type CreatePromptInput struct {
-	Slug        string `json:"slug"`        // optional, auto-generated from title if empty
+	Slug        string `json:"slug"` // optional, auto-generated from title if empty
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	Content     string `json:"content"`
+	Public      bool   `json:"public"` // optional, exposes the prompt in the public gallery
+	// Variables optionally declares the prompt's inputs; when set, every placeholder must be declared
+	Variables []Variable `json:"variables,omitempty"`
+	// Format is "text" (the default) or "chat"; a chat prompt's versions are
+	// lists of messages, given in Messages or as their JSON in Content
+	Format   string    `json:"format,omitempty"`
+	Messages []Message `json:"messages,omitempty"`
+	// ModelConfig optionally saves model parameters with the first version
+	ModelConfig *ModelConfig `json:"model_config,omitempty"`
+	// Metadata optionally attaches custom fields to the prompt
+	Metadata map[string]any `json:"metadata,omitempty"`
 }
 
 // CreatePromptVersionInput represents input for creating a new version
 type CreatePromptVersionInput struct {
-	Content string `json:"content"`
+	Content     string         `json:"content"`
+	Messages    []Message      `json:"messages,omitempty"`     // chat prompts only, in place of Content
+	ModelConfig *ModelConfig   `json:"model_config,omitempty"` // optional model parameters for the version
+	Metadata    map[string]any `json:"metadata,omitempty"`     // optional custom fields for the version
+	// Deduplicate returns the current version instead of creating one when
+	// its content and model config are identical
+	Deduplicate bool `json:"deduplicate,omitempty"`
+	// BaseVersion, when set, is the current version the new one was written
+	// against; the create fails if another version became current since
+	BaseVersion *int `json:"base_version,omitempty"`
+}
+
+// ImportVersionsInput represents input for appending a batch of versions
+type ImportVersionsInput struct {
+	Versions []ImportVersion `json:"versions"`
+}
+
+// ImportVersion is one version in an import batch
+type ImportVersion struct {
+	Content   string     `json:"content"`
+	CreatedAt *time.Time `json:"created_at,omitempty"` // optional, kept as original_created_at
+}
+
+// SetVariablesInput represents input for replacing a prompt's variable schema
+type SetVariablesInput struct {
+	Variables []Variable `json:"variables"`
+}
+
+// RenderInput represents input for rendering a prompt
+type RenderInput struct {
+	Variables map[string]any `json:"variables"`
+	Version   int            `json:"version,omitempty"` // optional, defaults to the current version
+	Label     string         `json:"label,omitempty"`   // optional, renders the version a release gave this label
+	// Provenance appends a comment identifying the version to the rendered content
+	Provenance bool `json:"provenance,omitempty"`
+}
+
+// RenderResult represents a rendered prompt
+type RenderResult struct {
+	Slug          string       `json:"slug"`
+	VersionNumber int          `json:"version_number"`
+	Content       string       `json:"content"`
+	Messages      []Message    `json:"messages,omitempty"`     // chat prompts only; Content holds them as JSON
+	ModelConfig   *ModelConfig `json:"model_config,omitempty"` // the version's model parameters
+	Provenance    *Provenance  `json:"provenance,omitempty"`
+}
+
+// Provenance identifies the exact prompt version behind an output
+type Provenance struct {
+	Registry    string `json:"registry"` // the registry's BASE_URL
+	Project     string `json:"project"`
+	Slug        string `json:"slug"`
+	Version     int    `json:"version"`
+	ContentHash string `json:"content_hash"` // "sha256:" and the hex digest of the version's template
+}
+
+// SetDescriptionInput represents input for replacing a prompt's Markdown description
+type SetDescriptionInput struct {
+	Description string `json:"description"`
+}
+
+// MarkdownPreviewInput represents the request body for previewing Markdown
+type MarkdownPreviewInput struct {
+	Markdown string `json:"markdown"`
+}
+
+// SetVisibilityInput represents input for changing a prompt's gallery visibility
+type SetVisibilityInput struct {
+	Public bool `json:"public"`
+}
+
+// SetLegalHoldInput represents the request body for placing or releasing a legal hold
+type SetLegalHoldInput struct {
+	Held bool `json:"held"`
+	// Reason is required when placing a hold, e.g. a case reference
+	Reason string `json:"reason,omitempty"`
+}
+
+// SetPinnedInput represents the request body for pinning a version
+type SetPinnedInput struct {
+	Pinned bool `json:"pinned"`
+}
+
+// SetReadOnlyInput represents the request body for turning read-only mode on or off
+type SetReadOnlyInput struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+// RedactVersionInput represents the request body for redacting a version
+type RedactVersionInput struct {
+	// Reason is required and recorded in the audit log and the redaction
+	// notice, e.g. a ticket reference; it must not repeat the sensitive data
+	Reason string `json:"reason"`
 }
@@ -0,0 +1,54 @@
+// Package notify posts human-readable messages about registry changes to
+// chat tools, so teams see prompt changes that affect their services.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Event types a Message can describe
+const (
+	EventVersionCreated = "prompt.version_created"
+	EventRolledBack     = "prompt.rolled_back"
+	EventDeleted        = "prompt.deleted"
+)
+
+// Notifier delivers messages to one chat destination
+type Notifier interface {
+	// Notify posts msg once. A *StatusError reports a rejection by the
+	// chat service; other errors are network failures.
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Message describes one change to a prompt
+type Message struct {
+	Event   string // one of the Event constants
+	Project string
+	Slug    string
+	Version int    // the version the prompt is at after the change; 0 if none
+	Title   string // one line saying what happened
+	Actor   string // who made the change, if known
+	Summary string // optional detail, e.g. "+3 -1 lines, +12 tokens"
+	Diff    string // optional unified diff of the change
+	URL     string // optional link to the prompt
+}
+
+// StatusError is a chat service rejecting a message with an HTTP status
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("rejected with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("rejected with status %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether sending the message again later may succeed
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSlack_Notify(t *testing.T) {
+	var got slackPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected %s request with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	err := NewSlack(server.URL).Notify(context.Background(), Message{
+		Event:   EventVersionCreated,
+		Project: "default",
+		Slug:    "greet",
+		Version: 2,
+		Title:   "New version of default/greet: v2",
+		Actor:   "alice",
+		Summary: "+1 -1 lines, +0 tokens",
+		Diff:    "--- greet@v1\n+++ greet@v2\n@@ -1 +1 @@\n-Hi <name>\n+Hello & welcome\n",
+		URL:     "https://prompts.example.com/prompts/greet",
+	})
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if got.Text != "New version of default/greet: v2: +1 -1 lines, +0 tokens" {
+		t.Errorf("Unexpected fallback text %q", got.Text)
+	}
+	if len(got.Blocks) != 2 {
+		t.Fatalf("Expected a title block and a diff block, got %+v", got.Blocks)
+	}
+	want := "*<https://prompts.example.com/prompts/greet|New version of default/greet: v2>*\nby alice\n+1 -1 lines, +0 tokens"
+	if got.Blocks[0].Text.Text != want {
+		t.Errorf("Expected title block %q, got %q", want, got.Blocks[0].Text.Text)
+	}
+	if diff := got.Blocks[1].Text.Text; !strings.HasPrefix(diff, "```---") || !strings.Contains(diff, "-Hi &lt;name&gt;\n+Hello &amp; welcome```") {
+		t.Errorf("Expected an escaped diff code block, got %q", diff)
+	}
+}
+
+func TestSlack_Rejected(t *testing.T) {
+	for _, tt := range []struct {
+		status    int
+		temporary bool
+	}{
+		{http.StatusNotFound, false},
+		{http.StatusBadRequest, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusBadGateway, true},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte("no_service"))
+		}))
+		err := NewSlack(server.URL).Notify(context.Background(), Message{Title: "Deleted default/greet and its 2 versions"})
+		server.Close()
+
+		var rejected *StatusError
+		if !errors.As(err, &rejected) {
+			t.Fatalf("Expected a StatusError for status %d, got %v", tt.status, err)
+		}
+		if rejected.StatusCode != tt.status || rejected.Body != "no_service" || rejected.Temporary() != tt.temporary {
+			t.Errorf("Unexpected error for status %d: %+v, temporary %v", tt.status, rejected, rejected.Temporary())
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	var lines []string
+	for i := range 50 {
+		lines = append(lines, fmt.Sprintf("+line %d", i))
+	}
+	long := strings.Join(lines, "\n") + "\n"
+
+	if got := Snippet("a\nb\n", 30, 100); got != "a\nb" {
+		t.Errorf("Expected a short diff unchanged, got %q", got)
+	}
+	if got := Snippet(long, 3, 1000); got != "+line 0\n+line 1\n+line 2\n… 47 more lines" {
+		t.Errorf("Expected a line limit, got %q", got)
+	}
+	if got := Snippet(long, 30, 20); got != "+line 0\n+line 1\n… 48 more lines" {
+		t.Errorf("Expected a character limit cut between lines, got %q", got)
+	}
+	if got := Snippet(strings.Repeat("x", 50), 30, 10); got != strings.Repeat("x", 10) {
+		t.Errorf("Expected an overlong first line to be cut, got %q", got)
+	}
+}
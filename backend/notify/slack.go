@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// slackMaxDiffLines and slackMaxDiffChars cut long diffs down to a
+	// snippet; Slack rejects section text over 3000 characters
+	slackMaxDiffLines = 30
+	slackMaxDiffChars = 2500
+)
+
+// Slack posts messages to a Slack incoming webhook
+type Slack struct {
+	WebhookURL string
+	HTTPClient *http.Client
+}
+
+// NewSlack creates a notifier for one incoming webhook URL, which picks the
+// channel messages go to
+func NewSlack(webhookURL string) *Slack {
+	return &Slack{
+		WebhookURL: webhookURL,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type string    `json:"type"`
+	Text slackText `json:"text"`
+}
+
+type slackPayload struct {
+	Text   string       `json:"text"` // shown in notifications and clients without blocks
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// Notify implements Notifier
+func (s *Slack) Notify(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(slackMessage(msg))
+	if err != nil {
+		return fmt.Errorf("failed to encode slack message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "prompt-registry-notify")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(reason))}
+	}
+	return nil
+}
+
+// slackMessage lays out msg as a linked title with the actor and summary
+// under it, followed by the diff snippet in a code block
+func slackMessage(msg Message) slackPayload {
+	title := "*" + slackEscape(msg.Title) + "*"
+	if msg.URL != "" {
+		title = fmt.Sprintf("*<%s|%s>*", msg.URL, slackEscape(msg.Title))
+	}
+	lines := []string{title}
+	if msg.Actor != "" {
+		lines = append(lines, "by "+slackEscape(msg.Actor))
+	}
+	if msg.Summary != "" {
+		lines = append(lines, slackEscape(msg.Summary))
+	}
+
+	text := msg.Title
+	if msg.Summary != "" {
+		text += ": " + msg.Summary
+	}
+	payload := slackPayload{
+		Text:   text,
+		Blocks: []slackBlock{{Type: "section", Text: slackText{Type: "mrkdwn", Text: strings.Join(lines, "\n")}}},
+	}
+	if msg.Diff != "" {
+		payload.Blocks = append(payload.Blocks, slackBlock{
+			Type: "section",
+			Text: slackText{Type: "mrkdwn", Text: "```" + slackEscape(Snippet(msg.Diff, slackMaxDiffLines, slackMaxDiffChars)) + "```"},
+		})
+	}
+	return payload
+}
+
+// Snippet shortens a diff to at most maxLines lines and about maxChars
+// characters, saying how many lines were left out. It cuts between lines,
+// unless the first line alone is too long.
+func Snippet(diff string, maxLines, maxChars int) string {
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	var out strings.Builder
+	kept := 0
+	for _, line := range lines {
+		if kept == maxLines || (kept > 0 && out.Len()+len(line)+1 > maxChars) {
+			break
+		}
+		if kept > 0 {
+			out.WriteByte('\n')
+		} else if runes := []rune(line); len(line) > maxChars {
+			line = string(runes[:min(len(runes), maxChars)])
+		}
+		out.WriteString(line)
+		kept++
+	}
+	if omitted := len(lines) - kept; omitted > 0 {
+		fmt.Fprintf(&out, "\n… %d more lines", omitted)
+	}
+	return out.String()
+}
+
+// slackEscape escapes the characters Slack treats as markup in message text
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
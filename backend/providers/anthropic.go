@@ -0,0 +1,231 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// AnthropicBaseURL is the Anthropic API root
+	AnthropicBaseURL = "https://api.anthropic.com"
+	// anthropicVersion is the Messages API version sent with every request
+	anthropicVersion = "2023-06-01"
+	// anthropicDefaultMaxTokens is used when the request doesn't set one; the API requires it
+	anthropicDefaultMaxTokens = 1024
+)
+
+// Anthropic talks to the Anthropic Messages API
+type Anthropic struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	HTTPClient *http.Client
+}
+
+// NewAnthropic creates a provider for the Anthropic API
+func NewAnthropic(baseURL, apiKey, model string) *Anthropic {
+	return &Anthropic{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		Model:      model,
+		HTTPClient: defaultHTTPClient(),
+	}
+}
+
+// Name implements Provider
+func (p *Anthropic) Name() string { return "anthropic" }
+
+// DefaultModel implements Provider
+func (p *Anthropic) DefaultModel() string { return p.Model }
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature *float64           `json:"temperature,omitempty"`
+	Stop        []string           `json:"stop_sequences,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      anthropicUsage `json:"usage"`
+}
+
+// anthropicEvent covers the stream event shapes we read
+type anthropicEvent struct {
+	Type    string            `json:"type"`
+	Message anthropicResponse `json:"message"` // message_start
+	Delta   struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"` // content_block_delta, message_delta
+	Usage anthropicUsage `json:"usage"` // message_delta
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"` // error
+}
+
+// Complete implements Provider
+func (p *Anthropic) Complete(ctx context.Context, req Request) (Completion, error) {
+	var result Completion
+
+	resp, err := p.send(ctx, req, false)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	var body anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return result, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var text strings.Builder
+	for _, block := range body.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return Completion{
+		Model:        body.Model,
+		Text:         text.String(),
+		FinishReason: body.StopReason,
+		Usage:        anthropicToUsage(body.Usage),
+	}, nil
+}
+
+// Stream implements Provider
+func (p *Anthropic) Stream(ctx context.Context, req Request, onDelta func(text string) error) (Completion, error) {
+	var result Completion
+
+	resp, err := p.send(ctx, req, true)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	var text strings.Builder
+	var usage anthropicUsage
+	err = readSSE(resp.Body, func(_, data string) error {
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("failed to decode stream event: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			result.Model = event.Message.Model
+			usage.InputTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				text.WriteString(event.Delta.Text)
+				return onDelta(event.Delta.Text)
+			}
+		case "message_delta":
+			result.FinishReason = event.Delta.StopReason
+			usage.OutputTokens = event.Usage.OutputTokens
+		case "error":
+			return &APIError{Provider: p.Name(), StatusCode: http.StatusBadGateway, Message: event.Error.Message}
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.Text = text.String()
+	result.Usage = anthropicToUsage(usage)
+	return result, nil
+}
+
+// send posts a Messages API request and checks the response status
+func (p *Anthropic) send(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	model := req.Model
+	if model == "" {
+		model = p.Model
+	}
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+	payload := anthropicRequest{
+		Model:       model,
+		Messages:    []anthropicMessage{{Role: "user", Content: req.Prompt}},
+		MaxTokens:   maxTokens,
+		Temperature: req.Temperature,
+		Stop:        req.Stop,
+		Stream:      stream,
+	}
+	if len(req.Messages) > 0 {
+		// The Messages API takes system instructions separately from the turns
+		var system []string
+		payload.Messages = nil
+		for _, m := range req.Messages {
+			if m.Role == "system" {
+				system = append(system, m.Content)
+				continue
+			}
+			payload.Messages = append(payload.Messages, anthropicMessage{Role: m.Role, Content: m.Content})
+		}
+		payload.System = strings.Join(system, "\n\n")
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.APIKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := p.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		var errResp struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error.Message == "" {
+			errResp.Error.Message = http.StatusText(resp.StatusCode)
+		}
+		return nil, &APIError{Provider: p.Name(), StatusCode: resp.StatusCode, Message: errResp.Error.Message}
+	}
+	return resp, nil
+}
+
+func anthropicToUsage(u anthropicUsage) Usage {
+	return Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
@@ -0,0 +1,104 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAIEmbeddingModel is the default OpenAI embedding model
+const OpenAIEmbeddingModel = "text-embedding-3-small"
+
+// Embedder turns text into vectors that are close together when the texts
+// are close in meaning
+type Embedder interface {
+	// Model names the embedding model. Vectors from different models can't
+	// be compared, so stored vectors are tagged with it.
+	Model() string
+	// Embed returns one vector per text, in the same order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// OpenAIEmbedder calls the OpenAI embeddings API or any compatible server
+// (vLLM, Ollama, LM Studio, ...). APIKey may be empty for local servers.
+type OpenAIEmbedder struct {
+	ProviderName   string
+	BaseURL        string
+	APIKey         string
+	EmbeddingModel string
+	HTTPClient     *http.Client
+}
+
+// NewOpenAIEmbedder creates an embedder for a server implementing /embeddings
+func NewOpenAIEmbedder(name, baseURL, apiKey, model string) *OpenAIEmbedder {
+	return &OpenAIEmbedder{
+		ProviderName:   name,
+		BaseURL:        strings.TrimRight(baseURL, "/"),
+		APIKey:         apiKey,
+		EmbeddingModel: model,
+		HTTPClient:     defaultHTTPClient(),
+	}
+}
+
+// Model implements Embedder
+func (e *OpenAIEmbedder) Model() string { return e.EmbeddingModel }
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed implements Embedder
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	body, err := json.Marshal(openAIEmbeddingRequest{Model: e.EmbeddingModel, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.BaseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	resp, err := e.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, openAIError(e.ProviderName, resp)
+	}
+
+	var result openAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("provider returned %d embeddings for %d texts", len(result.Data), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(texts) || vectors[d.Index] != nil {
+			return nil, fmt.Errorf("provider returned an embedding with invalid index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,212 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAIBaseURL is the OpenAI API root
+const OpenAIBaseURL = "https://api.openai.com/v1"
+
+// errStreamDone stops SSE parsing at OpenAI's [DONE] sentinel
+var errStreamDone = errors.New("stream done")
+
+// OpenAI talks to the OpenAI chat completions API or any compatible server
+// (vLLM, Ollama, LM Studio, ...). APIKey may be empty for local servers.
+type OpenAI struct {
+	ProviderName string
+	BaseURL      string
+	APIKey       string
+	Model        string
+	HTTPClient   *http.Client
+}
+
+// NewOpenAICompatible creates a provider for a server implementing /chat/completions
+func NewOpenAICompatible(name, baseURL, apiKey, model string) *OpenAI {
+	return &OpenAI{
+		ProviderName: name,
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		APIKey:       apiKey,
+		Model:        model,
+		HTTPClient:   defaultHTTPClient(),
+	}
+}
+
+// Name implements Provider
+func (p *OpenAI) Name() string { return p.ProviderName }
+
+// DefaultModel implements Provider
+func (p *OpenAI) DefaultModel() string { return p.Model }
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model         string          `json:"model"`
+	Messages      []openAIMessage `json:"messages"`
+	MaxTokens     int             `json:"max_tokens,omitempty"`
+	Temperature   *float64        `json:"temperature,omitempty"`
+	Stop          []string        `json:"stop,omitempty"`
+	Stream        bool            `json:"stream,omitempty"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		Delta        openAIMessage `json:"delta"`
+		FinishReason *string       `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+// Complete implements Provider
+func (p *OpenAI) Complete(ctx context.Context, req Request) (Completion, error) {
+	var result Completion
+
+	resp, err := p.send(ctx, req, false)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	var body openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return result, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(body.Choices) == 0 {
+		return result, errors.New("provider returned no choices")
+	}
+
+	result = Completion{Model: body.Model, Text: body.Choices[0].Message.Content}
+	if reason := body.Choices[0].FinishReason; reason != nil {
+		result.FinishReason = *reason
+	}
+	if body.Usage != nil {
+		result.Usage = Usage(*body.Usage)
+	}
+	return result, nil
+}
+
+// Stream implements Provider
+func (p *OpenAI) Stream(ctx context.Context, req Request, onDelta func(text string) error) (Completion, error) {
+	var result Completion
+
+	resp, err := p.send(ctx, req, true)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	var text strings.Builder
+	err = readSSE(resp.Body, func(_, data string) error {
+		if data == "[DONE]" {
+			return errStreamDone
+		}
+		var chunk openAIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = Usage(*chunk.Usage)
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+		if reason := chunk.Choices[0].FinishReason; reason != nil {
+			result.FinishReason = *reason
+		}
+		if delta := chunk.Choices[0].Delta.Content; delta != "" {
+			text.WriteString(delta)
+			return onDelta(delta)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStreamDone) {
+		return result, err
+	}
+	result.Text = text.String()
+	return result, nil
+}
+
+// send posts a chat completion request and checks the response status
+func (p *OpenAI) send(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	model := req.Model
+	if model == "" {
+		model = p.Model
+	}
+	payload := openAIRequest{
+		Model:       model,
+		Messages:    []openAIMessage{{Role: "user", Content: req.Prompt}},
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stop:        req.Stop,
+		Stream:      stream,
+	}
+	if len(req.Messages) > 0 {
+		payload.Messages = make([]openAIMessage, len(req.Messages))
+		for i, m := range req.Messages {
+			payload.Messages[i] = openAIMessage{Role: m.Role, Content: m.Content}
+		}
+	}
+	if stream {
+		payload.StreamOptions = &struct {
+			IncludeUsage bool `json:"include_usage"`
+		}{IncludeUsage: true}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	resp, err := p.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, openAIError(p.ProviderName, resp)
+	}
+	return resp, nil
+}
+
+// openAIError reads an OpenAI-style error body from a non-2xx response
+func openAIError(provider string, resp *http.Response) error {
+	var errResp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error.Message == "" {
+		errResp.Error.Message = http.StatusText(resp.StatusCode)
+	}
+	return &APIError{Provider: provider, StatusCode: resp.StatusCode, Message: errResp.Error.Message}
+}
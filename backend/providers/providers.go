@@ -0,0 +1,163 @@
+// Package providers abstracts LLM vendors behind a common interface so prompt
+// execution can target Anthropic, OpenAI, or any OpenAI-compatible local server.
+package providers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Provider runs completions against one LLM vendor
+type Provider interface {
+	// Name identifies the provider in requests and prompt config (e.g. "openai")
+	Name() string
+	// DefaultModel is used when neither the request nor the prompt names a model
+	DefaultModel() string
+	// Complete returns the whole completion once it is finished
+	Complete(ctx context.Context, req Request) (Completion, error)
+	// Stream calls onDelta with each text fragment as it arrives and returns the
+	// assembled completion. Returning an error from onDelta aborts the stream.
+	Stream(ctx context.Context, req Request, onDelta func(text string) error) (Completion, error)
+}
+
+// Request is a completion request: a single-turn Prompt, or a conversation
+// in Messages
+type Request struct {
+	Model       string
+	Prompt      string
+	Messages    []Message // when set, sent instead of Prompt
+	MaxTokens   int       // 0 uses the provider default
+	Temperature *float64  // nil uses the provider default
+	Stop        []string  // stop sequences; empty uses none
+}
+
+// Message is one turn of a conversation; Role is "system", "user", or "assistant"
+type Message struct {
+	Role    string
+	Content string
+}
+
+// Usage reports token counts for a completion
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// Completion is a provider's answer
+type Completion struct {
+	Model        string
+	Text         string
+	FinishReason string
+	Usage        Usage
+}
+
+// APIError is returned when a provider responds with a non-2xx status
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Registry holds the configured providers by name
+type Registry struct {
+	providers   map[string]Provider
+	defaultName string
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{providers: make(map[string]Provider)}
+}
+
+// Register adds a provider; the first one registered becomes the default
+func (r *Registry) Register(p Provider) {
+	if len(r.providers) == 0 {
+		r.defaultName = p.Name()
+	}
+	r.providers[p.Name()] = p
+}
+
+// SetDefault selects the provider used when none is requested
+func (r *Registry) SetDefault(name string) error {
+	if _, ok := r.providers[name]; !ok {
+		return fmt.Errorf("provider %q is not configured", name)
+	}
+	r.defaultName = name
+	return nil
+}
+
+// Get returns the named provider, or the default when name is empty
+func (r *Registry) Get(name string) (Provider, error) {
+	if name == "" {
+		name = r.defaultName
+	}
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("provider %q is not configured (available: %s)", name, strings.Join(r.Names(), ", "))
+	}
+	return p, nil
+}
+
+// Names lists configured providers in alphabetical order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Len returns the number of configured providers
+func (r *Registry) Len() int {
+	return len(r.providers)
+}
+
+// defaultHTTPClient is shared by providers that aren't given one. Streams can
+// legitimately run for minutes, so cancellation is left to the request context.
+func defaultHTTPClient() *http.Client {
+	return &http.Client{Timeout: 5 * time.Minute}
+}
+
+// readSSE calls onEvent for each server-sent event's type and data
+func readSSE(body io.Reader, onEvent func(event, data string) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				if err := onEvent(event, strings.Join(data, "\n")); err != nil {
+					return err
+				}
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	if len(data) > 0 {
+		return onEvent(event, strings.Join(data, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAI_Complete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" || r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("Unexpected request %s with auth %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["model"] != "default-model" {
+			t.Errorf("Expected default model, got %v", req["model"])
+		}
+		w.Write([]byte(`{"model": "default-model", "choices": [{"message": {"content": "Hi"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4}}`))
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatible("openai", server.URL, "key", "default-model")
+	got, err := p.Complete(context.Background(), Request{Prompt: "Hello"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if got.Text != "Hi" || got.FinishReason != "stop" || got.Usage.TotalTokens != 4 {
+		t.Errorf("Unexpected completion: %+v", got)
+	}
+}
+
+func TestOpenAI_Stream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"model": "m", "choices": [{"delta": {"content": "Hel"}}]}`,
+			`{"model": "m", "choices": [{"delta": {"content": "lo"}, "finish_reason": "stop"}]}`,
+			`{"model": "m", "choices": [], "usage": {"prompt_tokens": 2, "completion_tokens": 2, "total_tokens": 4}}`,
+			`[DONE]`,
+		} {
+			w.Write([]byte("data: " + chunk + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatible("local", server.URL, "", "m")
+	var deltas []string
+	got, err := p.Stream(context.Background(), Request{Prompt: "Hi"}, func(text string) error {
+		deltas = append(deltas, text)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if strings.Join(deltas, "|") != "Hel|lo" || got.Text != "Hello" {
+		t.Errorf("Unexpected deltas %v and text %q", deltas, got.Text)
+	}
+	if got.FinishReason != "stop" || got.Usage.TotalTokens != 4 {
+		t.Errorf("Unexpected completion: %+v", got)
+	}
+}
+
+func TestAnthropic_CompleteAndError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("Missing Anthropic headers")
+		}
+		var req struct {
+			MaxTokens int `json:"max_tokens"`
+			Messages  []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.MaxTokens != anthropicDefaultMaxTokens {
+			t.Errorf("Expected default max_tokens, got %d", req.MaxTokens)
+		}
+		if req.Messages[0].Content == "overloaded" {
+			w.WriteHeader(529)
+			w.Write([]byte(`{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`))
+			return
+		}
+		w.Write([]byte(`{"model": "claude", "content": [{"type": "text", "text": "Hi"}], "stop_reason": "end_turn",
+			"usage": {"input_tokens": 5, "output_tokens": 1}}`))
+	}))
+	defer server.Close()
+
+	p := NewAnthropic(server.URL, "key", "claude")
+	got, err := p.Complete(context.Background(), Request{Prompt: "Hello"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if got.Text != "Hi" || got.FinishReason != "end_turn" || got.Usage.TotalTokens != 6 {
+		t.Errorf("Unexpected completion: %+v", got)
+	}
+
+	_, err = p.Complete(context.Background(), Request{Prompt: "overloaded"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 529 || apiErr.Message != "Overloaded" {
+		t.Errorf("Expected APIError 529, got %v", err)
+	}
+}
+
+func TestAnthropic_Messages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			System   string             `json:"system"`
+			Messages []anthropicMessage `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.System != "Be brief." {
+			t.Errorf("Expected system instructions, got %q", req.System)
+		}
+		if len(req.Messages) != 2 || req.Messages[0].Role != "user" || req.Messages[1].Role != "assistant" {
+			t.Errorf("Expected the user and assistant turns, got %+v", req.Messages)
+		}
+		w.Write([]byte(`{"model": "claude", "content": [{"type": "text", "text": "Hi"}], "stop_reason": "end_turn"}`))
+	}))
+	defer server.Close()
+
+	p := NewAnthropic(server.URL, "key", "claude")
+	_, err := p.Complete(context.Background(), Request{Messages: []Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hello"},
+		{Role: "assistant", Content: "Hi"},
+	}})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+}
+
+func TestAnthropic_Stream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`event: message_start
+data: {"type": "message_start", "message": {"model": "claude", "usage": {"input_tokens": 7}}}
+
+event: content_block_delta
+data: {"type": "content_block_delta", "delta": {"type": "text_delta", "text": "Bon"}}
+
+event: ping
+data: {"type": "ping"}
+
+event: content_block_delta
+data: {"type": "content_block_delta", "delta": {"type": "text_delta", "text": "jour"}}
+
+event: message_delta
+data: {"type": "message_delta", "delta": {"stop_reason": "end_turn"}, "usage": {"output_tokens": 2}}
+
+event: message_stop
+data: {"type": "message_stop"}
+
+`))
+	}))
+	defer server.Close()
+
+	p := NewAnthropic(server.URL, "key", "claude")
+	var deltas []string
+	got, err := p.Stream(context.Background(), Request{Prompt: "Hi"}, func(text string) error {
+		deltas = append(deltas, text)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if strings.Join(deltas, "|") != "Bon|jour" || got.Text != "Bonjour" {
+		t.Errorf("Unexpected deltas %v and text %q", deltas, got.Text)
+	}
+	if got.Model != "claude" || got.FinishReason != "end_turn" || got.Usage.TotalTokens != 9 {
+		t.Errorf("Unexpected completion: %+v", got)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Register(NewOpenAICompatible("openai", "http://x", "k", "gpt"))
+	r.Register(NewAnthropic("http://y", "k", "claude"))
+
+	if p, err := r.Get(""); err != nil || p.Name() != "openai" {
+		t.Errorf("Expected first registered provider as default, got %v, %v", p, err)
+	}
+	if err := r.SetDefault("anthropic"); err != nil {
+		t.Fatalf("SetDefault failed: %v", err)
+	}
+	if p, _ := r.Get(""); p.Name() != "anthropic" {
+		t.Errorf("Expected anthropic as default, got %s", p.Name())
+	}
+	if _, err := r.Get("local"); err == nil || !strings.Contains(err.Error(), "anthropic, openai") {
+		t.Errorf("Expected unknown provider error listing available providers, got %v", err)
+	}
+	if err := r.SetDefault("local"); err == nil {
+		t.Error("Expected SetDefault to reject an unknown provider")
+	}
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" || r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("Unexpected request %s with auth %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var req openAIEmbeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "embed-model" {
+			t.Errorf("Unexpected model %q", req.Model)
+		}
+		if len(req.Input) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "bad input"}}`))
+			return
+		}
+		// Out of order, as the API allows
+		w.Write([]byte(`{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`))
+	}))
+	defer server.Close()
+
+	e := NewOpenAIEmbedder("openai", server.URL+"/", "key", "embed-model")
+	got, err := e.Embed(context.Background(), []string{"first", "second"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(got) != 2 || got[0][0] != 1 || got[1][1] != 1 {
+		t.Errorf("Expected vectors in input order, got %v", got)
+	}
+
+	_, err = e.Embed(context.Background(), []string{"first"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "bad input" {
+		t.Errorf("Expected an APIError, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// Prompt formats: plain text, or a list of role-tagged chat messages
+const (
+	FormatText = "text"
+	FormatChat = "chat"
+)
+
+// Message roles in a chat prompt
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// ValidateFormat checks a prompt format; empty means FormatText
+func ValidateFormat(format string) (string, error) {
+	switch format {
+	case "":
+		return FormatText, nil
+	case FormatText, FormatChat:
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid format %q: want %s or %s", format, FormatText, FormatChat)
+}
+
+// ValidateMessages checks that a chat prompt has at least one message and
+// that each has a known role and some content
+func ValidateMessages(messages []models.Message) error {
+	if len(messages) == 0 {
+		return errors.New("invalid messages: a chat prompt needs at least one message")
+	}
+	for i, m := range messages {
+		switch m.Role {
+		case RoleSystem, RoleUser, RoleAssistant:
+		default:
+			return fmt.Errorf("invalid messages: message %d has unknown role %q (want system, user, or assistant)", i+1, m.Role)
+		}
+		if strings.TrimSpace(m.Content) == "" {
+			return fmt.Errorf("invalid messages: message %d has no content", i+1)
+		}
+	}
+	return nil
+}
+
+// ParseMessages decodes and validates the stored content of a chat prompt's version
+func ParseMessages(content string) ([]models.Message, error) {
+	var messages []models.Message
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&messages); err != nil {
+		return nil, fmt.Errorf("invalid messages: content must be a JSON array of {role, content} objects: %v", err)
+	}
+	if err := ValidateMessages(messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// EncodeMessages validates messages and returns the content to store for
+// them: indented JSON, so diffs and git sync show each message on its own lines
+func EncodeMessages(messages []models.Message) (string, error) {
+	if err := ValidateMessages(messages); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(messages, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode messages: %w", err)
+	}
+	return string(data), nil
+}
+
+// RenderMessages replaces the placeholders in each message's content. Every
+// placeholder in any message must have a value.
+func RenderMessages(messages []models.Message, values map[string]any) ([]models.Message, error) {
+	var names []string
+	for _, m := range messages {
+		names = append(names, Placeholders(m.Content)...)
+	}
+	if err := checkValues(dedupe(names), values); err != nil {
+		return nil, err
+	}
+	rendered := make([]models.Message, len(messages))
+	for i, m := range messages {
+		rendered[i] = models.Message{Role: m.Role, Content: substitute(m.Content, values)}
+	}
+	return rendered, nil
+}
+
+// dedupe drops repeated names, keeping the first of each
+func dedupe(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var result []string
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,185 @@
+// Package render substitutes {{variable}} placeholders in prompt content and
+// validates variables against a prompt's declared variable schema.
+package render
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// Variable types accepted in a schema
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+)
+
+// placeholderPattern matches {{name}} with optional inner whitespace
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// namePattern is the set of valid variable names
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Placeholders returns the distinct variable names used in content, in order of first use
+func Placeholders(content string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(content, -1) {
+		if name := match[1]; !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ValidateSchema checks that variable names are valid and unique and types are known
+func ValidateSchema(vars []models.Variable) error {
+	seen := make(map[string]bool, len(vars))
+	for _, v := range vars {
+		if !namePattern.MatchString(v.Name) {
+			return fmt.Errorf("invalid variables: name %q must match %s", v.Name, namePattern)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("invalid variables: %q is declared more than once", v.Name)
+		}
+		seen[v.Name] = true
+
+		switch v.Type {
+		case TypeString, TypeNumber, TypeInteger, TypeBoolean:
+		default:
+			return fmt.Errorf("invalid variables: %q has unknown type %q (want string, number, integer, or boolean)", v.Name, v.Type)
+		}
+	}
+	return nil
+}
+
+// CheckDeclared reports placeholders in content that the schema does not declare.
+// An empty schema declares nothing and disables the check.
+func CheckDeclared(content string, vars []models.Variable) error {
+	if len(vars) == 0 {
+		return nil
+	}
+	declared := make(map[string]bool, len(vars))
+	for _, v := range vars {
+		declared[v.Name] = true
+	}
+
+	var undeclared []string
+	for _, name := range Placeholders(content) {
+		if !declared[name] {
+			undeclared = append(undeclared, name)
+		}
+	}
+	if len(undeclared) > 0 {
+		return fmt.Errorf("invalid variables: content uses undeclared variables: %s", strings.Join(undeclared, ", "))
+	}
+	return nil
+}
+
+// Validate checks supplied values against the schema: every required variable
+// must be present and every declared variable must have the declared type.
+// Values for variables the schema does not mention are left to Render.
+func Validate(vars []models.Variable, values map[string]any) error {
+	var problems []string
+	for _, v := range vars {
+		value, ok := values[v.Name]
+		if !ok || value == nil {
+			if v.Required {
+				problems = append(problems, fmt.Sprintf("%s is required", v.Name))
+			}
+			continue
+		}
+		if !hasType(value, v.Type) {
+			problems = append(problems, fmt.Sprintf("%s must be of type %s", v.Name, v.Type))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid variables: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Render replaces each placeholder with its value. Every placeholder must have a value.
+func Render(content string, values map[string]any) (string, error) {
+	if err := checkValues(Placeholders(content), values); err != nil {
+		return "", err
+	}
+	return substitute(content, values), nil
+}
+
+// checkValues reports the placeholders in names that have no value
+func checkValues(names []string, values map[string]any) error {
+	var missing []string
+	for _, name := range names {
+		if value, ok := values[name]; !ok || value == nil {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("invalid variables: missing values for %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// substitute replaces each placeholder in content with its value
+func substitute(content string, values map[string]any) string {
+	return placeholderPattern.ReplaceAllStringFunc(content, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		return formatValue(values[name])
+	})
+}
+
+// hasType reports whether a JSON-decoded value matches a schema type
+func hasType(value any, typ string) bool {
+	switch typ {
+	case TypeString:
+		_, ok := value.(string)
+		return ok
+	case TypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case TypeNumber:
+		_, ok := toFloat(value)
+		return ok
+	case TypeInteger:
+		f, ok := toFloat(value)
+		return ok && f == math.Trunc(f)
+	}
+	return false
+}
+
+// toFloat converts numeric values decoded from JSON or built in Go
+func toFloat(value any) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// formatValue renders a value as text; whole numbers print without a decimal point
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
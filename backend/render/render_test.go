@@ -0,0 +1,135 @@
+package render
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+func TestPlaceholders(t *testing.T) {
+	got := Placeholders("Hi {{name}}, {{ topic }} and {{name}} again. {{not valid}} {{2bad}}")
+	want := []string{"name", "topic"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	var values map[string]any
+	if err := json.Unmarshal([]byte(`{"name": "Ada", "count": 3, "ratio": 0.5, "ok": true}`), &values); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Render("{{name}}: {{ count }} x {{ratio}} ({{ok}})", values)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if want := "Ada: 3 x 0.5 (true)"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if _, err := Render("{{name}} {{missing}}", values); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Expected missing variable error, got %v", err)
+	}
+}
+
+func TestRenderMessages(t *testing.T) {
+	messages := []models.Message{
+		{Role: RoleSystem, Content: "You summarize {{topic}}."},
+		{Role: RoleUser, Content: "Summarize \"{{text}}\" about {{ topic }}."},
+	}
+	got, err := RenderMessages(messages, map[string]any{"topic": "birds", "text": "Crows \"talk\""})
+	if err != nil {
+		t.Fatalf("RenderMessages failed: %v", err)
+	}
+	if got[0].Content != "You summarize birds." || got[1].Content != `Summarize "Crows "talk"" about birds.` {
+		t.Errorf("Unexpected messages: %+v", got)
+	}
+	if messages[0].Content != "You summarize {{topic}}." {
+		t.Errorf("Expected the template to be left alone, got %q", messages[0].Content)
+	}
+
+	if _, err := RenderMessages(messages, map[string]any{"topic": "birds"}); err == nil || !strings.Contains(err.Error(), "text") {
+		t.Errorf("Expected missing variable error, got %v", err)
+	}
+}
+
+func TestParseMessages(t *testing.T) {
+	content, err := EncodeMessages([]models.Message{{Role: RoleUser, Content: "Hi {{name}}"}})
+	if err != nil {
+		t.Fatalf("EncodeMessages failed: %v", err)
+	}
+	messages, err := ParseMessages(content)
+	if err != nil || len(messages) != 1 || messages[0].Content != "Hi {{name}}" {
+		t.Errorf("Expected the message back, got %+v, %v", messages, err)
+	}
+
+	for _, bad := range []string{
+		`Hello`,
+		`[]`,
+		`[{"role": "narrator", "content": "Hi"}]`,
+		`[{"role": "user", "content": " "}]`,
+		`[{"role": "user", "content": "Hi", "name": "x"}]`,
+	} {
+		if _, err := ParseMessages(bad); err == nil || !strings.Contains(err.Error(), "invalid messages") {
+			t.Errorf("ParseMessages(%s): expected invalid messages error, got %v", bad, err)
+		}
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		vars    []models.Variable
+		wantErr bool
+	}{
+		{"valid", []models.Variable{{Name: "a", Type: TypeString}, {Name: "b_2", Type: TypeInteger}}, false},
+		{"bad name", []models.Variable{{Name: "a-b", Type: TypeString}}, true},
+		{"duplicate", []models.Variable{{Name: "a", Type: TypeString}, {Name: "a", Type: TypeNumber}}, true},
+		{"unknown type", []models.Variable{{Name: "a", Type: "date"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateSchema(tt.vars); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckDeclared(t *testing.T) {
+	vars := []models.Variable{{Name: "name", Type: TypeString}}
+	if err := CheckDeclared("Hello {{name}}", vars); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := CheckDeclared("Hello {{name}} {{extra}}", vars); err == nil || !strings.Contains(err.Error(), "extra") {
+		t.Errorf("Expected undeclared variable error, got %v", err)
+	}
+	if err := CheckDeclared("Hello {{anything}}", nil); err != nil {
+		t.Errorf("Expected no check without a schema, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	vars := []models.Variable{
+		{Name: "name", Type: TypeString, Required: true},
+		{Name: "count", Type: TypeInteger},
+		{Name: "strict", Type: TypeBoolean},
+	}
+
+	if err := Validate(vars, map[string]any{"name": "Ada", "count": float64(2)}); err != nil {
+		t.Errorf("Expected valid values, got %v", err)
+	}
+
+	err := Validate(vars, map[string]any{"count": 2.5, "strict": "yes"})
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+	for _, want := range []string{"name is required", "count must be of type integer", "strict must be of type boolean"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+}
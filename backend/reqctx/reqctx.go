@@ -0,0 +1,181 @@
+// Package reqctx carries per-request metadata through a request's context:
+// its ID, the route pattern, project, and prompt slug it matched, the
+// caller's identity, and a logger bound to the ID, route, and caller. The
+// HTTP middleware attaches it once per request, so handlers and the code
+// they call log consistently without threading fields through every call.
+package reqctx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+
+	"github.com/shahram/prompt-registry/backend/auth"
+)
+
+// info is shared by every context derived from the request's, so the route
+// and identity can be filled in after routing and authentication
+type info struct {
+	requestID string
+	logger    *slog.Logger
+
+	mu       sync.RWMutex
+	route    string
+	project  string
+	slug     string
+	identity *auth.Identity
+}
+
+type contextKey struct{}
+
+// New returns a copy of ctx carrying a request's ID and base logger
+func New(ctx context.Context, requestID string, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &info{requestID: requestID, logger: logger})
+}
+
+func from(ctx context.Context) *info {
+	i, _ := ctx.Value(contextKey{}).(*info)
+	return i
+}
+
+// RequestID returns the request's ID, or "" outside a request
+func RequestID(ctx context.Context) string {
+	if i := from(ctx); i != nil {
+		return i.requestID
+	}
+	return ""
+}
+
+// SetRoutePattern records the route pattern the request matched, such as
+// "GET /api/prompts/{slug}". It does nothing outside a request.
+func SetRoutePattern(ctx context.Context, pattern string) {
+	if i := from(ctx); i != nil {
+		i.mu.Lock()
+		i.route = pattern
+		i.mu.Unlock()
+	}
+}
+
+// RoutePattern returns the route pattern the request matched, or "" before
+// routing, when nothing matched, or outside a request
+func RoutePattern(ctx context.Context) string {
+	if i := from(ctx); i != nil {
+		i.mu.RLock()
+		defer i.mu.RUnlock()
+		return i.route
+	}
+	return ""
+}
+
+// SetProject records the {project} path value of the route the request
+// matched, which is "" for routes in the default project. It does nothing
+// outside a request.
+func SetProject(ctx context.Context, project string) {
+	if i := from(ctx); i != nil {
+		i.mu.Lock()
+		i.project = project
+		i.mu.Unlock()
+	}
+}
+
+// Project returns the {project} path value the request's route matched, or
+// "" when the route has none, before routing, or outside a request
+func Project(ctx context.Context) string {
+	if i := from(ctx); i != nil {
+		i.mu.RLock()
+		defer i.mu.RUnlock()
+		return i.project
+	}
+	return ""
+}
+
+// SetSlug records the {slug} path value of the route the request matched.
+// Middleware that runs before the authenticator only holds a copy of the
+// request without path values, so it reads the slug from here. It does
+// nothing outside a request.
+func SetSlug(ctx context.Context, slug string) {
+	if i := from(ctx); i != nil {
+		i.mu.Lock()
+		i.slug = slug
+		i.mu.Unlock()
+	}
+}
+
+// Slug returns the prompt slug the request's route matched, or "" when the
+// route has none, before routing, or outside a request
+func Slug(ctx context.Context) string {
+	if i := from(ctx); i != nil {
+		i.mu.RLock()
+		defer i.mu.RUnlock()
+		return i.slug
+	}
+	return ""
+}
+
+// SetIdentity records the authenticated caller, so middleware wrapping the
+// authenticator can see it too. It does nothing outside a request.
+func SetIdentity(ctx context.Context, id auth.Identity) {
+	if i := from(ctx); i != nil {
+		i.mu.Lock()
+		i.identity = &id
+		i.mu.Unlock()
+	}
+}
+
+// Identity returns the authenticated caller, if the request was authenticated
+func Identity(ctx context.Context) (auth.Identity, bool) {
+	if id, ok := auth.FromContext(ctx); ok {
+		return id, true
+	}
+	if i := from(ctx); i != nil {
+		i.mu.RLock()
+		defer i.mu.RUnlock()
+		if i.identity != nil {
+			return *i.identity, true
+		}
+	}
+	return auth.Identity{}, false
+}
+
+// Logger returns the request's logger with request_id, route, and subject
+// attached as far as they are known. Outside a request it returns the
+// default logger.
+func Logger(ctx context.Context) *slog.Logger {
+	i := from(ctx)
+	if i == nil {
+		return slog.Default()
+	}
+	attrs := []any{"request_id", i.requestID}
+	if route := RoutePattern(ctx); route != "" {
+		attrs = append(attrs, "route", route)
+	}
+	if id, ok := Identity(ctx); ok {
+		attrs = append(attrs, "subject", id.Subject)
+	}
+	return i.logger.With(attrs...)
+}
+
+// NewRequestID returns a random 16-byte hex ID
+func NewRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ValidRequestID reports whether a client-supplied ID is safe to reuse and
+// log: 1 to 128 letters, digits, dots, dashes, and underscores
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range []byte(id) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
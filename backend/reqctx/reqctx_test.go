@@ -0,0 +1,74 @@
+package reqctx
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/shahram/prompt-registry/backend/auth"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := New(context.Background(), "req-1", slog.New(slog.NewTextHandler(&buf, nil)))
+
+	Logger(ctx).Info("before routing")
+	SetRoutePattern(ctx, "GET /api/prompts/{slug}")
+	ctx = auth.WithIdentity(ctx, auth.Identity{Subject: "alice", Method: "apikey"})
+	Logger(ctx).Info("in handler")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log lines, got %q", buf.String())
+	}
+	if !strings.Contains(lines[0], "request_id=req-1") || strings.Contains(lines[0], "route=") || strings.Contains(lines[0], "subject=") {
+		t.Errorf("Expected only the request ID before routing, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], `route="GET /api/prompts/{slug}"`) || !strings.Contains(lines[1], "subject=alice") {
+		t.Errorf("Expected route and subject in handler, got %q", lines[1])
+	}
+	if RequestID(ctx) != "req-1" || RoutePattern(ctx) != "GET /api/prompts/{slug}" {
+		t.Errorf("Unexpected metadata: %q %q", RequestID(ctx), RoutePattern(ctx))
+	}
+
+	// The project and slug are shared by every context derived from the request's
+	SetProject(ctx, "search")
+	SetSlug(ctx, "greeting")
+	if project, slug := Project(context.WithoutCancel(ctx)), Slug(context.WithoutCancel(ctx)); project != "search" || slug != "greeting" {
+		t.Errorf("Expected the recorded project and slug, got %q and %q", project, slug)
+	}
+}
+
+func TestOutsideRequest(t *testing.T) {
+	ctx := context.Background()
+	SetRoutePattern(ctx, "GET /")
+	SetProject(ctx, "search")
+	SetSlug(ctx, "greeting")
+	if RequestID(ctx) != "" || RoutePattern(ctx) != "" || Project(ctx) != "" || Slug(ctx) != "" {
+		t.Error("Expected no metadata outside a request")
+	}
+	if _, ok := Identity(ctx); ok {
+		t.Error("Expected no identity outside a request")
+	}
+	if Logger(ctx) != slog.Default() {
+		t.Error("Expected the default logger outside a request")
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"":                       false,
+		"abc-123_x.y":            true,
+		"has space":              false,
+		"line\nbreak":            false,
+		strings.Repeat("a", 128): true,
+		strings.Repeat("a", 129): false,
+		NewRequestID():           true,
+	} {
+		if got := ValidRequestID(id); got != want {
+			t.Errorf("ValidRequestID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// Prompt access levels. Write access includes read access.
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+// Grantee types: a single authenticated subject, or every member of an org
+const (
+	GranteeUser = "user"
+	GranteeOrg  = "org"
+)
+
+// aclSchema holds per-prompt access grants. A prompt without grants is open
+// to every caller; once it has any, only its owner and grantees can see it.
+const aclSchema = `
+	CREATE TABLE IF NOT EXISTS prompt_grants (
+		prompt_id    INTEGER NOT NULL,
+		grantee_type TEXT NOT NULL,
+		grantee      TEXT NOT NULL,
+		access       TEXT NOT NULL,
+		granted_by   TEXT NOT NULL DEFAULT '',
+		created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id),
+		PRIMARY KEY(prompt_id, grantee_type, grantee)
+	);
+`
+
+// granteeMatch matches grants g held by the caller directly or through an
+// org. It takes the caller twice.
+const granteeMatch = `(
+	(g.grantee_type = 'user' AND g.grantee = ?)
+	OR (g.grantee_type = 'org' AND g.grantee IN (
+		SELECT o.name FROM orgs o JOIN org_members m ON m.org_id = o.id WHERE m.subject = ?
+	))
+)`
+
+// readableByCaller restricts a query over prompts p to the prompts the caller
+// can read. Bind it with readableArgs.
+const readableByCaller = `(
+	? = '' OR p.owner = ?
+	OR NOT EXISTS (SELECT 1 FROM prompt_grants g WHERE g.prompt_id = p.id)
+	OR EXISTS (SELECT 1 FROM prompt_grants g WHERE g.prompt_id = p.id AND ` + granteeMatch + `)
+)`
+
+// readableArgs returns the arguments for readableByCaller
+func (s *SQLiteStore) readableArgs() []any {
+	return []any{s.actor, s.actor, s.actor, s.actor}
+}
+
+// promptAccess describes the caller's access to one prompt
+type promptAccess struct {
+	id         int64
+	owner      string
+	restricted bool // the prompt has grants
+	level      int  // 0 none, 1 read, 2 write, from the caller's grants
+}
+
+// allows reports whether the caller has the given access. Callers without an
+// identity are internal (git sync, the eval runner) and always allowed.
+func (a promptAccess) allows(actor, access string) bool {
+	if actor == "" || !a.restricted || a.owner == actor {
+		return true
+	}
+	if access == AccessWrite {
+		return a.level >= 2
+	}
+	return a.level >= 1
+}
+
+// access looks up the caller's access to a prompt
+func (s *SQLiteStore) access(q queryRower, slug string) (promptAccess, error) {
+	var a promptAccess
+	err := q.QueryRow(`
+		SELECT p.id, p.owner,
+			EXISTS (SELECT 1 FROM prompt_grants g WHERE g.prompt_id = p.id),
+			(SELECT COALESCE(MAX(CASE g.access WHEN 'write' THEN 2 ELSE 1 END), 0)
+				FROM prompt_grants g WHERE g.prompt_id = p.id AND `+granteeMatch+`)
+		FROM prompts p
+		WHERE p.project = ? AND p.slug = ?
+	`, s.actor, s.actor, s.project, slug).Scan(&a.id, &a.owner, &a.restricted, &a.level)
+	if err == sql.ErrNoRows {
+		return a, promptNotFound(slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to check prompt access", "error", err, "slug", slug)
+		return a, fmt.Errorf("failed to check prompt access: %w", err)
+	}
+	return a, nil
+}
+
+// AuthorizePrompt checks that the caller has read or write access to a
+// prompt. Prompts the caller can't read are reported as not found; missing
+// prompts pass, so callers report them the way they normally would.
+func (s *SQLiteStore) AuthorizePrompt(slug, access string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, err := s.access(s.db, slug)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if a.allows(s.actor, access) {
+		return nil
+	}
+	if a.allows(s.actor, AccessRead) {
+		return denied("permission denied: no write access to prompt %q", slug)
+	}
+	return promptNotFound(slug)
+}
+
+// GetPromptACL returns a prompt's owner and grants
+func (s *SQLiteStore) GetPromptACL(slug string) (models.PromptACL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	result := models.PromptACL{Grants: []models.PromptGrant{}}
+	a, err := s.access(s.db, slug)
+	if err != nil {
+		return result, err
+	}
+	if !a.allows(s.actor, AccessRead) {
+		return result, promptNotFound(slug)
+	}
+	result.Owner = a.owner
+	if result.Grants, err = s.promptGrants(s.db, a.id); err != nil {
+		return result, err
+	}
+
+	duration := time.Since(start)
+	s.observe("GetPromptACL", duration)
+	s.logger.Info("database operation",
+		"operation", "GetPromptACL",
+		"slug", slug,
+		"grants", len(result.Grants),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// SetPromptACL replaces a prompt's grants and optionally transfers ownership.
+// Only the owner can change them; prompts without an owner, such as those
+// created before ownership existed, accept changes from anyone with write
+// access, who then becomes the owner unless another is named.
+func (s *SQLiteStore) SetPromptACL(slug string, input models.SetPromptACLInput) (models.PromptACL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	result := models.PromptACL{Owner: strings.TrimSpace(input.Owner), Grants: []models.PromptGrant{}}
+	seen := make(map[string]bool)
+	for _, grant := range input.Grants {
+		switch grant.Type {
+		case GranteeUser:
+			if strings.TrimSpace(grant.Name) == "" {
+				return result, invalidField("grants", "grant name cannot be empty")
+			}
+		case GranteeOrg:
+			if err := ValidateOrg(grant.Name); err != nil {
+				return result, err
+			}
+		default:
+			return result, invalidField("grants", "invalid grant type %q: use %q or %q", grant.Type, GranteeUser, GranteeOrg)
+		}
+		if grant.Access != AccessRead && grant.Access != AccessWrite {
+			return result, invalidField("grants", "invalid access %q: use %q or %q", grant.Access, AccessRead, AccessWrite)
+		}
+		key := grant.Type + ":" + grant.Name
+		if seen[key] {
+			return result, invalidField("grants", "invalid grants: %s is listed more than once", key)
+		}
+		seen[key] = true
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	a, err := s.access(tx, slug)
+	if err != nil {
+		return result, err
+	}
+	switch {
+	case !a.allows(s.actor, AccessRead):
+		return result, promptNotFound(slug)
+	case s.actor != "" && a.owner != "" && a.owner != s.actor:
+		return result, denied("permission denied: only the owner can change access to prompt %q", slug)
+	case !a.allows(s.actor, AccessWrite):
+		return result, denied("permission denied: no write access to prompt %q", slug)
+	}
+	if result.Owner == "" {
+		result.Owner = a.owner
+	}
+	if result.Owner == "" {
+		result.Owner = s.actor
+	}
+
+	for _, grant := range input.Grants {
+		if grant.Type == GranteeOrg {
+			var exists bool
+			if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM orgs WHERE name = ?)`, grant.Name).Scan(&exists); err != nil {
+				s.logger.Error("failed to get org", "error", err, "org", grant.Name)
+				return result, fmt.Errorf("failed to get org: %w", err)
+			}
+			if !exists {
+				return result, invalidField("grants", "invalid grant: org %q does not exist", grant.Name)
+			}
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM prompt_grants WHERE prompt_id = ?`, a.id); err != nil {
+		s.logger.Error("failed to clear grants", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to clear grants: %w", err)
+	}
+	detail := []string{"owner=" + result.Owner}
+	for _, grant := range input.Grants {
+		if _, err := tx.Exec(
+			`INSERT INTO prompt_grants (prompt_id, grantee_type, grantee, access, granted_by) VALUES (?, ?, ?, ?, ?)`,
+			a.id, grant.Type, grant.Name, grant.Access, s.actor,
+		); err != nil {
+			s.logger.Error("failed to insert grant", "error", err, "slug", slug)
+			return result, fmt.Errorf("failed to insert grant: %w", err)
+		}
+		detail = append(detail, grant.Type+":"+grant.Name+"="+grant.Access)
+	}
+	if _, err := tx.Exec(
+		`UPDATE prompts SET owner = ?, updated_at = CURRENT_TIMESTAMP, updated_by = ? WHERE id = ?`,
+		result.Owner, s.actor, a.id,
+	); err != nil {
+		s.logger.Error("failed to update prompt", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to update prompt: %w", err)
+	}
+	if err := s.audit(tx, a.id, auditACLChanged, 0, strings.Join(detail, " ")); err != nil {
+		return result, err
+	}
+	if result.Grants, err = s.promptGrants(tx, a.id); err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("SetPromptACL", duration)
+	s.logger.Info("database operation",
+		"operation", "SetPromptACL",
+		"slug", slug,
+		"owner", result.Owner,
+		"grants", len(result.Grants),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// promptGrants lists a prompt's grants, users before orgs, alphabetically
+func (s *SQLiteStore) promptGrants(q querier, promptID int64) ([]models.PromptGrant, error) {
+	rows, err := q.Query(`
+		SELECT grantee_type, grantee, access, granted_by, created_at
+		FROM prompt_grants
+		WHERE prompt_id = ?
+		ORDER BY grantee_type DESC, grantee
+	`, promptID)
+	if err != nil {
+		s.logger.Error("failed to list grants", "error", err, "prompt_id", promptID)
+		return nil, fmt.Errorf("failed to list grants: %w", err)
+	}
+	defer rows.Close()
+
+	grants := []models.PromptGrant{}
+	for rows.Next() {
+		var grant models.PromptGrant
+		if err := rows.Scan(&grant.Type, &grant.Name, &grant.Access, &grant.GrantedBy, &grant.CreatedAt); err != nil {
+			s.logger.Error("failed to scan grant", "error", err)
+			return nil, fmt.Errorf("failed to scan grant: %w", err)
+		}
+		grants = append(grants, grant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list grants: %w", err)
+	}
+	return grants, nil
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// fetchCount counts fetches of prompts p across versions
+const fetchCount = `(SELECT COALESCE(SUM(u.fetches), 0) FROM prompt_usage u WHERE u.prompt_id = p.id)`
+
+// lastFetched is the Unix time of the last fetch of prompts p, NULL if never.
+// It's in seconds since an aggregate loses the column's DATETIME type.
+const lastFetched = `(SELECT CAST(strftime('%s', MAX(u.last_fetched_at)) AS INTEGER) FROM prompt_usage u WHERE u.prompt_id = p.id)`
+
+// activityColumns selects a models.PromptActivity for prompts p
+const activityColumns = `p.slug, p.title, p.current_version, ` + versionCount + `, ` + fetchCount + `, ` + lastFetched + `, p.updated_at`
+
+// GetAnalytics summarizes the prompts the caller can read, leaving out
+// archived ones: totals, and up to limit of the most-fetched prompts, the most
+// recently changed, and the stale ones. A prompt is stale when it was created
+// before staleSince and hasn't been fetched since.
+func (s *SQLiteStore) GetAnalytics(staleSince time.Time, limit int) (models.Analytics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.Analytics
+
+	scope := `FROM prompts p WHERE p.project = ? AND p.archived_at IS NULL AND ` + readableByCaller
+	scopeArgs := append([]any{s.project}, s.readableArgs()...)
+	err := s.db.QueryRow(`
+		SELECT COUNT(*),
+			COALESCE(SUM(`+versionCount+`), 0),
+			COALESCE(SUM(`+fetchCount+`), 0)
+		`+scope, scopeArgs...,
+	).Scan(&result.Prompts, &result.Versions, &result.Fetches)
+	if err != nil {
+		s.logger.Error("failed to count prompts", "error", err)
+		return result, fmt.Errorf("failed to count prompts: %w", err)
+	}
+
+	lists := []struct {
+		dst   *[]models.PromptActivity
+		where string
+		order string
+		args  []any
+	}{
+		{&result.MostFetched, ` AND ` + lastFetched + ` IS NOT NULL`, fetchCount + ` DESC, p.slug`, nil},
+		{&result.RecentlyChanged, ``, `p.updated_at DESC, p.id DESC`, nil},
+		{&result.Stale, ` AND ` + sortColumns[SortCreatedAt] + ` < ? AND COALESCE(` + lastFetched + `, 0) < ?`,
+			`COALESCE(` + lastFetched + `, 0), ` + sortColumns[SortCreatedAt] + `, p.id`,
+			[]any{timestampValue(&staleSince), staleSince.Unix()}},
+	}
+	for _, l := range lists {
+		args := append(append(append([]any{}, scopeArgs...), l.args...), limit)
+		*l.dst, err = s.queryActivity(`SELECT `+activityColumns+` `+scope+l.where+` ORDER BY `+l.order+` LIMIT ?`, args...)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	duration := time.Since(start)
+	s.observe("GetAnalytics", duration)
+	s.logger.Info("database operation",
+		"operation", "GetAnalytics",
+		"prompts", result.Prompts,
+		"stale", len(result.Stale),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// queryActivity runs a query selecting activityColumns
+func (s *SQLiteStore) queryActivity(query string, args ...any) ([]models.PromptActivity, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		s.logger.Error("failed to query analytics", "error", err)
+		return nil, fmt.Errorf("failed to query analytics: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.PromptActivity{}
+	for rows.Next() {
+		var activity models.PromptActivity
+		var lastFetchedAt sql.NullInt64
+		if err := rows.Scan(&activity.Slug, &activity.Title, &activity.CurrentVersion, &activity.Versions,
+			&activity.Fetches, &lastFetchedAt, &activity.UpdatedAt); err != nil {
+			s.logger.Error("failed to scan analytics", "error", err)
+			return nil, fmt.Errorf("failed to scan analytics: %w", err)
+		}
+		if lastFetchedAt.Valid {
+			t := time.Unix(lastFetchedAt.Int64, 0).UTC()
+			activity.LastFetchedAt = &t
+		}
+		results = append(results, activity)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate analytics", "error", err)
+		return nil, fmt.Errorf("failed to iterate analytics: %w", err)
+	}
+	return results, nil
+}
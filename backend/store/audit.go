@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/auth"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/reqctx"
+)
+
+// Audit actions
+const (
+	auditPromptCreated       = "prompt.created"
+	auditPromptImported      = "prompt.imported"
+	auditPromptForked        = "prompt.forked"
+	auditVersionCreated      = "prompt.version_created"
+	auditVersionsImported    = "prompt.versions_imported"
+	auditVersionPinned       = "prompt.version_pinned"
+	auditVersionUnpinned     = "prompt.version_unpinned"
+	auditVersionRedacted     = "prompt.version_redacted"
+	auditLegalHoldPlaced     = "prompt.legal_hold_placed"
+	auditLegalHoldReleased   = "prompt.legal_hold_released"
+	auditACLChanged          = "prompt.acl_changed"
+	auditVisibilityChanged   = "prompt.visibility_changed"
+	auditDescriptionChanged  = "prompt.description_changed"
+	auditDocsUpdated         = "prompt.docs_updated"
+	auditArchived            = "prompt.archived"
+	auditUnarchived          = "prompt.unarchived"
+	auditVariablesChanged    = "prompt.variables_changed"
+	auditExecutionChanged    = "prompt.execution_changed"
+	auditLabelPromoted       = "prompt.label_promoted"
+	auditLabelRolledBack     = "prompt.label_rolled_back"
+	auditLinksChanged        = "prompt.links_changed"
+	auditRetentionChanged    = "prompt.retention_changed"
+	auditVersionsPruned      = "prompt.versions_pruned"
+	auditReviewPolicyChanged = "prompt.review_policy_changed"
+	auditVersionApproved     = "prompt.version_approved"
+	auditWebhookAdded        = "webhook.added"
+	auditWebhookDeleted      = "webhook.deleted"
+)
+
+// Admin audit actions, recorded in admin_audit_log
+const (
+	auditAdminPromptDeleted  = "admin.prompt_deleted"
+	auditAdminArchivedPurged = "admin.archived_purged"
+	auditAdminRecomputed     = "admin.recomputed"
+	auditAdminCompacted      = "admin.compacted"
+)
+
+// auditSchema records who changed which prompt. Rows are written in the same
+// transaction as the change they describe.
+const auditSchema = `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		prompt_id      INTEGER NOT NULL,
+		actor          TEXT NOT NULL,
+		action         TEXT NOT NULL,
+		version_number INTEGER,
+		detail         TEXT NOT NULL DEFAULT '',
+		created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_log_prompt ON audit_log(prompt_id, id);
+`
+
+// WithContext returns a store whose writes are attributed to the identity the
+// auth middleware put in ctx: created_by, updated_by, and audit rows record
+// its subject. Without an identity the actor is empty. Within a request, the
+// store logs with the request's logger.
+func (s *SQLiteStore) WithContext(ctx context.Context) Store {
+	var actor string
+	if id, ok := auth.FromContext(ctx); ok {
+		actor = id.Subject
+	}
+	logger := s.logger
+	if reqctx.RequestID(ctx) != "" {
+		logger = reqctx.Logger(ctx)
+	}
+	return &SQLiteStore{database: s.database, project: s.project, actor: actor, logger: logger}
+}
+
+// audit records an action on a prompt within tx. A zero version is stored as NULL.
+func (s *SQLiteStore) audit(tx *sql.Tx, promptID int64, action string, version int, detail string) error {
+	var versionNumber any
+	if version > 0 {
+		versionNumber = version
+	}
+	_, err := tx.Exec(
+		`INSERT INTO audit_log (prompt_id, actor, action, version_number, detail) VALUES (?, ?, ?, ?, ?)`,
+		promptID, s.actor, action, versionNumber, detail,
+	)
+	if err != nil {
+		s.logger.Error("failed to write audit entry", "error", err, "prompt_id", promptID, "action", action)
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEntries returns the changes made to a prompt, newest first
+func (s *SQLiteStore) ListAuditEntries(slug string, limit, offset int) ([]models.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	promptID, err := s.promptID(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, actor, action, COALESCE(version_number, 0), detail, created_at
+		FROM audit_log
+		WHERE prompt_id = ?
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, promptID, limit, offset)
+	if err != nil {
+		s.logger.Error("failed to list audit entries", "error", err, "slug", slug)
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.AuditEntry{}
+	for rows.Next() {
+		entry := models.AuditEntry{Project: s.project, Slug: slug}
+		if err := rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.Version, &entry.Detail, &entry.CreatedAt); err != nil {
+			s.logger.Error("failed to scan audit entry", "error", err)
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		results = append(results, entry)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate audit entries", "error", err)
+		return nil, fmt.Errorf("failed to iterate audit entries: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("ListAuditEntries", duration)
+	s.logger.Info("database operation",
+		"operation", "ListAuditEntries",
+		"slug", slug,
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultCacheSize is how many prompts a CachedStore keeps unless configured
+const DefaultCacheSize = 1000
+
+// cacheTTL bounds how long a cached prompt is served, as a backstop for
+// writes this process can't see, such as a restore run from the command line
+const cacheTTL = time.Minute
+
+// CachedStore serves GetPromptBySlug from an in-process LRU cache, since
+// prompts are read far more often than they change. Concurrent misses for
+// the same prompt share one query. Every method that changes a prompt drops
+// it from the cache once the change is committed, so a new version is served
+// immediately; methods that change many prompts, like registry import and
+// restore, drop everything. Methods that change what GetPromptBySlug returns
+// must be overridden here.
+//
+// Cached prompts are shared between callers and must not be modified.
+type CachedStore struct {
+	Store
+	cache *promptCache
+}
+
+// NewCachedStore puts a cache of up to size prompts in front of s
+func NewCachedStore(s Store, size int) *CachedStore {
+	return &CachedStore{Store: s, cache: newPromptCache(size)}
+}
+
+// promptCache is an LRU cache of prompts keyed by project and slug, shared by
+// a CachedStore's project and context views
+type promptCache struct {
+	size   int
+	now    func() time.Time
+	flight singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+	// gen changes with every invalidation, so a lookup that started before a
+	// write doesn't cache what it read
+	gen uint64
+}
+
+type cacheEntry struct {
+	key     string
+	prompt  models.PromptWithCurrentVersion
+	expires time.Time
+}
+
+func newPromptCache(size int) *promptCache {
+	return &promptCache{
+		size:    size,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns a live entry, marking it recently used, and the current
+// generation
+func (c *promptCache) get(key string) (models.PromptWithCurrentVersion, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		if c.now().Before(entry.expires) {
+			c.order.MoveToFront(el)
+			return entry.prompt, c.gen, true
+		}
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+	return models.PromptWithCurrentVersion{}, c.gen, false
+}
+
+// put caches a prompt read at generation gen, unless something was
+// invalidated since
+func (c *promptCache) put(key string, gen uint64, prompt models.PromptWithCurrentVersion) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	entry := &cacheEntry{key: key, prompt: prompt, expires: c.now().Add(cacheTTL)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// invalidate drops one prompt
+func (c *promptCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+// purge drops every prompt
+func (c *promptCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// key identifies the prompt at slug in the store's project
+func (s *CachedStore) key(slug string) string {
+	return s.Project() + "/" + slug
+}
+
+// InProject returns a cached store for another project's prompts
+func (s *CachedStore) InProject(project string) Store {
+	return &CachedStore{Store: s.Store.InProject(project), cache: s.cache}
+}
+
+// WithContext returns a cached store attributed to ctx's identity
+func (s *CachedStore) WithContext(ctx context.Context) Store {
+	return &CachedStore{Store: s.Store.WithContext(ctx), cache: s.cache}
+}
+
+// GetPromptBySlug returns the cached prompt, loading it on a miss. Missing
+// prompts aren't cached.
+func (s *CachedStore) GetPromptBySlug(slug string) (models.PromptWithCurrentVersion, error) {
+	key := s.key(slug)
+	prompt, gen, ok := s.cache.get(key)
+	if ok {
+		return prompt, nil
+	}
+	// Lookups only share a query with others from the same generation
+	v, err, _ := s.cache.flight.Do(key+"@"+strconv.FormatUint(gen, 10), func() (any, error) {
+		prompt, err := s.Store.GetPromptBySlug(slug)
+		if err != nil {
+			return prompt, err
+		}
+		s.cache.put(key, gen, prompt)
+		return prompt, nil
+	})
+	return v.(models.PromptWithCurrentVersion), err
+}
+
+// The methods below change prompts, and drop them from the cache once the
+// change is committed
+
+func (s *CachedStore) SetPromptACL(slug string, input models.SetPromptACLInput) (models.PromptACL, error) {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.SetPromptACL(slug, input)
+}
+
+func (s *CachedStore) CreatePromptVersion(slug string, input models.CreatePromptVersionInput) (models.PromptWithCurrentVersion, error) {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.CreatePromptVersion(slug, input)
+}
+
+func (s *CachedStore) ImportPromptVersions(slug string, input models.ImportVersionsInput) ([]models.PromptVersion, error) {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.ImportPromptVersions(slug, input)
+}
+
+func (s *CachedStore) SetReviewPolicy(slug string, policy models.ReviewPolicy) (models.ReviewPolicy, error) {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.SetReviewPolicy(slug, policy)
+}
+
+func (s *CachedStore) ApproveVersion(slug string, version int, comment string) (models.VersionReview, error) {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.ApproveVersion(slug, version, comment)
+}
+
+func (s *CachedStore) ImportPrompts(prompts []models.ExportedPrompt) (models.ImportResult, error) {
+	defer s.cache.purge()
+	return s.Store.ImportPrompts(prompts)
+}
+
+func (s *CachedStore) SetPromptVisibility(slug string, public bool) error {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.SetPromptVisibility(slug, public)
+}
+
+func (s *CachedStore) SetPromptDescription(slug, description string) (models.PromptWithCurrentVersion, error) {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.SetPromptDescription(slug, description)
+}
+
+func (s *CachedStore) SetPromptDocs(slug, content string) (models.PromptDocs, error) {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.SetPromptDocs(slug, content)
+}
+
+func (s *CachedStore) SetPromptArchived(slug string, archived bool) error {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.SetPromptArchived(slug, archived)
+}
+
+func (s *CachedStore) SetLegalHold(slug string, held bool, reason string) error {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.SetLegalHold(slug, held, reason)
+}
+
+func (s *CachedStore) SetPromptRetention(slug string, policy *models.RetentionPolicy) error {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.SetPromptRetention(slug, policy)
+}
+
+func (s *CachedStore) SetPromptVariables(slug string, vars []models.Variable) (models.PromptWithCurrentVersion, error) {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.SetPromptVariables(slug, vars)
+}
+
+func (s *CachedStore) SetPromptExecution(slug string, config models.ExecutionConfig) error {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.SetPromptExecution(slug, config)
+}
+
+func (s *CachedStore) SetVersionPinned(slug string, version int, pinned bool) (models.PromptVersion, error) {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.SetVersionPinned(slug, version, pinned)
+}
+
+func (s *CachedStore) RedactPromptVersion(slug string, version int, reason string) (models.PromptVersion, error) {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.RedactPromptVersion(slug, version, reason)
+}
+
+func (s *CachedStore) DeletePromptPermanently(slug string) (models.DeletedPrompt, error) {
+	defer s.cache.invalidate(s.key(slug))
+	return s.Store.DeletePromptPermanently(slug)
+}
+
+func (s *CachedStore) PurgeArchivedPrompts(cutoff time.Time, dryRun bool) (models.PurgeResult, error) {
+	defer s.cache.purge()
+	return s.Store.PurgeArchivedPrompts(cutoff, dryRun)
+}
+
+func (s *CachedStore) RecomputeDenormalized() (models.RecomputeResult, error) {
+	defer s.cache.purge()
+	return s.Store.RecomputeDenormalized()
+}
+
+func (s *CachedStore) Reopen(dbPath string) (models.ReopenResult, error) {
+	defer s.cache.purge()
+	return s.Store.Reopen(dbPath)
+}
+
+func (s *CachedStore) Restore(backupPath string) (models.RestoreResult, error) {
+	defer s.cache.purge()
+	return s.Store.Restore(backupPath)
+}
@@ -0,0 +1,294 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// MaxCollectionDepth bounds how deeply collections nest
+const MaxCollectionDepth = 8
+
+// collectionSchema holds each project's collections and the prompts in them.
+// A collection's path names it and its ancestors, e.g. "support/refunds";
+// its parent must exist before it can be created. A prompt can be in any
+// number of collections.
+const collectionSchema = `
+	CREATE TABLE IF NOT EXISTS collections (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		project     TEXT NOT NULL,
+		path        TEXT NOT NULL,
+		parent_id   INTEGER,
+		title       TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		created_by  TEXT NOT NULL DEFAULT '',
+		created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(parent_id) REFERENCES collections(id),
+		UNIQUE(project, path)
+	);
+
+	CREATE TABLE IF NOT EXISTS collection_prompts (
+		collection_id INTEGER NOT NULL,
+		prompt_id     INTEGER NOT NULL,
+		added_by      TEXT NOT NULL DEFAULT '',
+		created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(collection_id) REFERENCES collections(id),
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id),
+		PRIMARY KEY(collection_id, prompt_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_collection_prompts_prompt ON collection_prompts(prompt_id);
+`
+
+// ValidateCollectionPath checks that a path is 1 to MaxCollectionDepth
+// slash-separated names of 1-63 lowercase letters, digits, and hyphens
+func ValidateCollectionPath(path string) error {
+	names := strings.Split(path, "/")
+	if len(names) > MaxCollectionDepth {
+		return invalidField("path", "invalid collection path %q: at most %d levels", path, MaxCollectionDepth)
+	}
+	for _, name := range names {
+		if err := ValidateProject(name); err != nil {
+			return invalidField("path", "invalid collection path %q: use names of 1-63 lowercase letters, digits, and hyphens separated by /", path)
+		}
+	}
+	return nil
+}
+
+// collectionColumns selects a collection c with the number of prompts
+// directly in it that the caller can read. Bind readableArgs first.
+const collectionColumns = `c.id, c.path, COALESCE(pc.path, ''), c.title, c.description,
+	(SELECT COUNT(*) FROM collection_prompts cp JOIN prompts p ON p.id = cp.prompt_id
+		WHERE cp.collection_id = c.id AND p.archived_at IS NULL AND ` + readableByCaller + `),
+	c.created_by, c.created_at`
+
+// scanCollection scans a row selected with collectionColumns
+func scanCollection(row interface{ Scan(...any) error }) (models.Collection, error) {
+	var c models.Collection
+	err := row.Scan(&c.ID, &c.Path, &c.Parent, &c.Title, &c.Description, &c.Prompts, &c.CreatedBy, &c.CreatedAt)
+	return c, err
+}
+
+// CreateCollection creates a collection under its parent, which must already
+// exist. The title defaults to the last name in the path.
+func (s *SQLiteStore) CreateCollection(input models.CreateCollectionInput) (models.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.Collection
+	if err := ValidateCollectionPath(input.Path); err != nil {
+		return result, err
+	}
+	title := input.Title
+	if title == "" {
+		title = input.Path[strings.LastIndex(input.Path, "/")+1:]
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var parentID *int64
+	if i := strings.LastIndex(input.Path, "/"); i >= 0 {
+		parent := input.Path[:i]
+		var id int64
+		err := tx.QueryRow(`SELECT id FROM collections WHERE project = ? AND path = ?`, s.project, parent).Scan(&id)
+		if err == sql.ErrNoRows {
+			return result, notFound("parent collection %q not found", parent)
+		}
+		if err != nil {
+			s.logger.Error("failed to get parent collection", "error", err, "path", parent)
+			return result, fmt.Errorf("failed to get parent collection: %w", err)
+		}
+		parentID = &id
+	}
+
+	var id int64
+	err = tx.QueryRow(`
+		INSERT INTO collections (project, path, parent_id, title, description, created_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, s.project, input.Path, parentID, title, input.Description, s.actor).Scan(&id)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint") {
+			return result, conflict("collection %q already exists", input.Path)
+		}
+		s.logger.Error("failed to insert collection", "error", err, "path", input.Path)
+		return result, fmt.Errorf("failed to insert collection: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if result, err = s.getCollection(id); err != nil {
+		return result, err
+	}
+
+	duration := time.Since(start)
+	s.observe("CreateCollection", duration)
+	s.logger.Info("database operation",
+		"operation", "CreateCollection",
+		"path", input.Path,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// ListCollections returns every collection in the project, ordered by path
+// so each one follows its parent
+func (s *SQLiteStore) ListCollections() ([]models.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	rows, err := s.db.Query(`
+		SELECT `+collectionColumns+`
+		FROM collections c
+		LEFT JOIN collections pc ON pc.id = c.parent_id
+		WHERE c.project = ?
+		ORDER BY c.path
+	`, append(s.readableArgs(), s.project)...)
+	if err != nil {
+		s.logger.Error("failed to list collections", "error", err)
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.Collection{}
+	for rows.Next() {
+		collection, err := scanCollection(rows)
+		if err != nil {
+			s.logger.Error("failed to scan collection", "error", err)
+			return nil, fmt.Errorf("failed to scan collection: %w", err)
+		}
+		results = append(results, collection)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate collections", "error", err)
+		return nil, fmt.Errorf("failed to iterate collections: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("ListCollections", duration)
+	s.logger.Info("database operation",
+		"operation", "ListCollections",
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// getCollection returns a collection in the project by ID
+func (s *SQLiteStore) getCollection(id int64) (models.Collection, error) {
+	result, err := scanCollection(s.db.QueryRow(`
+		SELECT `+collectionColumns+`
+		FROM collections c
+		LEFT JOIN collections pc ON pc.id = c.parent_id
+		WHERE c.project = ? AND c.id = ?
+	`, append(s.readableArgs(), s.project, id)...))
+	if err == sql.ErrNoRows {
+		return result, notFound("collection %d not found", id)
+	}
+	if err != nil {
+		s.logger.Error("failed to get collection", "error", err, "collection_id", id)
+		return result, fmt.Errorf("failed to get collection: %w", err)
+	}
+	return result, nil
+}
+
+// ListCollectionPrompts returns the prompts in a collection, by title. With
+// recursive, it includes the prompts in every collection below it, each
+// once. Archived prompts and those the caller can't read are left out.
+func (s *SQLiteStore) ListCollectionPrompts(id int64, recursive bool) ([]models.PromptSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	collection, err := s.getCollection(id)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := `c.id = ?`
+	args := []any{id}
+	if recursive {
+		scope = `(c.path = ? OR c.path LIKE ? ESCAPE '\')`
+		args = []any{collection.Path, likeEscaper.Replace(collection.Path) + "/%"}
+	}
+	args = append(append([]any{s.project}, args...), s.readableArgs()...)
+	results, err := s.queryPromptSummaries(`
+		SELECT slug, title, description, public, current_version, created_at, updated_at, original_created_at, archived_at
+		FROM prompts p
+		WHERE p.archived_at IS NULL AND p.id IN (
+			SELECT cp.prompt_id FROM collection_prompts cp
+			JOIN collections c ON c.id = cp.collection_id
+			WHERE c.project = ? AND `+scope+`
+		) AND `+readableByCaller+`
+		ORDER BY p.title COLLATE NOCASE, p.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Since(start)
+	s.observe("ListCollectionPrompts", duration)
+	s.logger.Info("database operation",
+		"operation", "ListCollectionPrompts",
+		"collection", collection.Path,
+		"recursive", recursive,
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// SetPromptInCollection adds a prompt to a collection or removes it. Adding
+// a prompt that is already there, or removing one that isn't, does nothing.
+func (s *SQLiteStore) SetPromptInCollection(id int64, slug string, member bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM collections WHERE project = ? AND id = ?)`, s.project, id).Scan(&exists)
+	if err != nil {
+		s.logger.Error("failed to get collection", "error", err, "collection_id", id)
+		return fmt.Errorf("failed to get collection: %w", err)
+	}
+	if !exists {
+		return notFound("collection %d not found", id)
+	}
+	promptID, err := s.promptID(slug)
+	if err != nil {
+		return err
+	}
+
+	if member {
+		_, err = s.db.Exec(`INSERT OR IGNORE INTO collection_prompts (collection_id, prompt_id, added_by) VALUES (?, ?, ?)`, id, promptID, s.actor)
+	} else {
+		_, err = s.db.Exec(`DELETE FROM collection_prompts WHERE collection_id = ? AND prompt_id = ?`, id, promptID)
+	}
+	if err != nil {
+		s.logger.Error("failed to update collection", "error", err, "collection_id", id, "slug", slug, "member", member)
+		return fmt.Errorf("failed to update collection: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("SetPromptInCollection", duration)
+	s.logger.Info("database operation",
+		"operation", "SetPromptInCollection",
+		"collection_id", id,
+		"slug", slug,
+		"member", member,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
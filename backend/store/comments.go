@@ -0,0 +1,145 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// MaxCommentLength is the most characters a version comment can have
+const MaxCommentLength = 10000
+
+// commentSchema holds reviewers' comments on prompt versions. Comments are
+// kept with the version they discuss and never change what is sent to the
+// model.
+const commentSchema = `
+	CREATE TABLE IF NOT EXISTS version_comments (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		prompt_id      INTEGER NOT NULL,
+		version_number INTEGER NOT NULL,
+		body           TEXT NOT NULL,
+		created_by     TEXT NOT NULL DEFAULT '',
+		created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_version_comments_version ON version_comments(prompt_id, version_number, id);
+`
+
+// AddVersionComment adds a comment to a version of a prompt
+func (s *SQLiteStore) AddVersionComment(slug string, version int, body string) (models.VersionComment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.VersionComment
+
+	if strings.TrimSpace(body) == "" {
+		return result, invalidField("body", "comment cannot be empty")
+	}
+	if n := utf8.RuneCountInString(body); n > MaxCommentLength {
+		return result, invalidField("body", "comment is %d characters; the limit is %d", n, MaxCommentLength)
+	}
+
+	promptID, err := s.versionPromptID(slug, version)
+	if err != nil {
+		return result, err
+	}
+
+	err = s.db.QueryRow(`
+		INSERT INTO version_comments (prompt_id, version_number, body, created_by)
+		VALUES (?, ?, ?, ?)
+		RETURNING id, version_number, body, created_by, created_at
+	`, promptID, version, body, s.actor).Scan(&result.ID, &result.VersionNumber, &result.Body, &result.CreatedBy, &result.CreatedAt)
+	if err != nil {
+		s.logger.Error("failed to insert comment", "error", err, "slug", slug, "version", version)
+		return result, fmt.Errorf("failed to insert comment: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("AddVersionComment", duration)
+	s.logger.Info("database operation",
+		"operation", "AddVersionComment",
+		"slug", slug,
+		"version", version,
+		"comment_id", result.ID,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// ListVersionComments returns the comments on a version of a prompt, oldest first
+func (s *SQLiteStore) ListVersionComments(slug string, version int) ([]models.VersionComment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	promptID, err := s.versionPromptID(slug, version)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, version_number, body, created_by, created_at
+		FROM version_comments
+		WHERE prompt_id = ? AND version_number = ?
+		ORDER BY id ASC
+	`, promptID, version)
+	if err != nil {
+		s.logger.Error("failed to list comments", "error", err, "slug", slug, "version", version)
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.VersionComment{}
+	for rows.Next() {
+		var comment models.VersionComment
+		if err := rows.Scan(&comment.ID, &comment.VersionNumber, &comment.Body, &comment.CreatedBy, &comment.CreatedAt); err != nil {
+			s.logger.Error("failed to scan comment", "error", err)
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		results = append(results, comment)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate comments", "error", err)
+		return nil, fmt.Errorf("failed to iterate comments: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("ListVersionComments", duration)
+	s.logger.Info("database operation",
+		"operation", "ListVersionComments",
+		"slug", slug,
+		"version", version,
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// versionPromptID returns the ID of the prompt at slug, checking that it has
+// the given version
+func (s *SQLiteStore) versionPromptID(slug string, version int) (int64, error) {
+	var promptID int64
+	err := s.db.QueryRow(`
+		SELECT p.id
+		FROM prompts p
+		JOIN prompt_versions pv ON pv.prompt_id = p.id AND pv.version_number = ?
+		WHERE p.project = ? AND p.slug = ?
+	`, version, s.project, slug).Scan(&promptID)
+	if err == sql.ErrNoRows {
+		if _, err := s.promptID(slug); err != nil {
+			return 0, err
+		}
+		return 0, versionNotFound(slug, version)
+	}
+	if err != nil {
+		s.logger.Error("failed to get version", "error", err, "slug", slug, "version", version)
+		return 0, fmt.Errorf("failed to get version: %w", err)
+	}
+	return promptID, nil
+}
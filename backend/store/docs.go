@@ -0,0 +1,206 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// docsSchema holds long-form prompt documentation. Each save adds a revision,
+// numbered per prompt and independent of content versions, so documenting a
+// prompt never changes what is sent to the model.
+const docsSchema = `
+	CREATE TABLE IF NOT EXISTS prompt_docs (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		prompt_id  INTEGER NOT NULL,
+		revision   INTEGER NOT NULL,
+		content    TEXT NOT NULL,
+		created_by TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id),
+		UNIQUE(prompt_id, revision)
+	);
+`
+
+// latestDocs selects the content of the newest docs revision of prompts p
+const latestDocs = `COALESCE((SELECT d.content FROM prompt_docs d WHERE d.prompt_id = p.id ORDER BY d.revision DESC LIMIT 1), '')`
+
+// GetPromptDocs returns a revision of a prompt's docs, or the latest when
+// revision is 0. A prompt that was never documented has revision 0 and no
+// content.
+func (s *SQLiteStore) GetPromptDocs(slug string, revision int) (models.PromptDocs, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	promptID, err := s.promptID(slug)
+	if err != nil {
+		return models.PromptDocs{}, err
+	}
+	result, err := s.docsRevision(s.db, promptID, revision)
+	if err == sql.ErrNoRows {
+		if revision == 0 {
+			return models.PromptDocs{}, nil
+		}
+		return result, notFound("docs revision %d of prompt %q not found", revision, slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to get docs", "error", err, "slug", slug, "revision", revision)
+		return result, fmt.Errorf("failed to get docs: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("GetPromptDocs", duration)
+	s.logger.Info("database operation",
+		"operation", "GetPromptDocs",
+		"slug", slug,
+		"revision", result.Revision,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// ListPromptDocs returns every revision of a prompt's docs, newest first
+func (s *SQLiteStore) ListPromptDocs(slug string) ([]models.PromptDocs, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	promptID, err := s.promptID(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT revision, content, created_by, created_at
+		FROM prompt_docs
+		WHERE prompt_id = ?
+		ORDER BY revision DESC
+	`, promptID)
+	if err != nil {
+		s.logger.Error("failed to list docs", "error", err, "slug", slug)
+		return nil, fmt.Errorf("failed to list docs: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.PromptDocs{}
+	for rows.Next() {
+		var docs models.PromptDocs
+		if err := rows.Scan(&docs.Revision, &docs.Content, &docs.CreatedBy, &docs.CreatedAt); err != nil {
+			s.logger.Error("failed to scan docs", "error", err)
+			return nil, fmt.Errorf("failed to scan docs: %w", err)
+		}
+		results = append(results, docs)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate docs", "error", err)
+		return nil, fmt.Errorf("failed to iterate docs: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("ListPromptDocs", duration)
+	s.logger.Info("database operation",
+		"operation", "ListPromptDocs",
+		"slug", slug,
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// SetPromptDocs saves a new revision of a prompt's docs. Saving the same
+// content as the latest revision returns it without adding another; saving
+// empty content clears the docs.
+func (s *SQLiteStore) SetPromptDocs(slug, content string) (models.PromptDocs, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.PromptDocs
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var promptID int64
+	err = tx.QueryRow(`SELECT id FROM prompts WHERE project = ? AND slug = ?`, s.project, slug).Scan(&promptID)
+	if err == sql.ErrNoRows {
+		return result, promptNotFound(slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to get prompt", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to get prompt: %w", err)
+	}
+
+	latest, err := s.docsRevision(tx, promptID, 0)
+	if err != nil && err != sql.ErrNoRows {
+		s.logger.Error("failed to get docs", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to get docs: %w", err)
+	}
+	if latest.Content == content {
+		return latest, nil
+	}
+
+	if err := s.insertDocs(tx, promptID, latest.Revision+1, content); err != nil {
+		return result, err
+	}
+	if _, err := tx.Exec(
+		`UPDATE prompts SET updated_at = CURRENT_TIMESTAMP, updated_by = ? WHERE id = ?`,
+		s.actor, promptID,
+	); err != nil {
+		s.logger.Error("failed to update prompt", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to update prompt: %w", err)
+	}
+	if err := s.audit(tx, promptID, auditDocsUpdated, 0, fmt.Sprintf("revision %d", latest.Revision+1)); err != nil {
+		return result, err
+	}
+	if result, err = s.docsRevision(tx, promptID, 0); err != nil {
+		s.logger.Error("failed to get docs", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to get docs: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("SetPromptDocs", duration)
+	s.logger.Info("database operation",
+		"operation", "SetPromptDocs",
+		"slug", slug,
+		"revision", result.Revision,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// insertDocs adds a docs revision
+func (s *SQLiteStore) insertDocs(tx *sql.Tx, promptID int64, revision int, content string) error {
+	if _, err := tx.Exec(
+		`INSERT INTO prompt_docs (prompt_id, revision, content, created_by) VALUES (?, ?, ?, ?)`,
+		promptID, revision, content, s.actor,
+	); err != nil {
+		s.logger.Error("failed to insert docs", "error", err, "prompt_id", promptID)
+		return fmt.Errorf("failed to insert docs: %w", err)
+	}
+	return nil
+}
+
+// docsRevision loads one docs revision, or the latest when revision is 0. It
+// returns sql.ErrNoRows when there is no such revision.
+func (s *SQLiteStore) docsRevision(q queryRower, promptID int64, revision int) (models.PromptDocs, error) {
+	var docs models.PromptDocs
+	err := q.QueryRow(`
+		SELECT revision, content, created_by, created_at
+		FROM prompt_docs
+		WHERE prompt_id = ? AND (? = 0 OR revision = ?)
+		ORDER BY revision DESC
+		LIMIT 1
+	`, promptID, revision, revision).Scan(&docs.Revision, &docs.Content, &docs.CreatedBy, &docs.CreatedAt)
+	return docs, err
+}
@@ -0,0 +1,261 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/render"
+)
+
+// MaxSemanticSearchResults bounds how many prompts one semantic search returns
+const MaxSemanticSearchResults = 50
+
+// embeddingSchema stores one vector per prompt, embedded from its title,
+// description, and current version. source_sha256 is the digest of the
+// embedded text, so a prompt is embedded again once any of them changes.
+// Vectors are unit length, packed as little-endian float32s.
+const embeddingSchema = `
+	CREATE TABLE IF NOT EXISTS prompt_embeddings (
+		prompt_id     INTEGER PRIMARY KEY,
+		model         TEXT NOT NULL,
+		source_sha256 TEXT NOT NULL,
+		dimensions    INTEGER NOT NULL,
+		vector        BLOB NOT NULL,
+		updated_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id)
+	);
+`
+
+// ListStaleEmbeddings returns up to limit prompts, in every project, that
+// have no embedding from model or whose text changed since it was embedded.
+// Archived prompts are left out, as search doesn't return them.
+func (s *SQLiteStore) ListStaleEmbeddings(model string, limit int) ([]models.EmbeddingSource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	rows, err := s.db.Query(`
+		SELECT p.id, p.project, p.slug, p.title, p.description, p.format, v.content,
+			COALESCE(e.model, ''), COALESCE(e.source_sha256, '')
+		FROM prompts p
+		JOIN prompt_versions v ON v.prompt_id = p.id AND v.version_number = p.current_version
+		LEFT JOIN prompt_embeddings e ON e.prompt_id = p.id
+		WHERE p.archived_at IS NULL
+		ORDER BY p.id
+	`)
+	if err != nil {
+		s.logger.Error("failed to list prompts for embedding", "error", err)
+		return nil, fmt.Errorf("failed to list prompts for embedding: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.EmbeddingSource{}
+	for rows.Next() && len(results) < limit {
+		var source models.EmbeddingSource
+		var title, description, format, content, embeddedModel, embeddedSHA string
+		if err := rows.Scan(&source.PromptID, &source.Project, &source.Slug, &title, &description, &format, &content, &embeddedModel, &embeddedSHA); err != nil {
+			s.logger.Error("failed to scan prompt for embedding", "error", err)
+			return nil, fmt.Errorf("failed to scan prompt for embedding: %w", err)
+		}
+		source.Text = embeddingText(title, description, format, content)
+		source.SourceSHA256 = contentSHA256(source.Text)
+		if embeddedModel != model || embeddedSHA != source.SourceSHA256 {
+			results = append(results, source)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate prompts for embedding", "error", err)
+		return nil, fmt.Errorf("failed to iterate prompts for embedding: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("ListStaleEmbeddings", duration)
+	s.logger.Info("database operation",
+		"operation", "ListStaleEmbeddings",
+		"model", model,
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// SaveEmbeddings stores the vector model computed for each source, in one
+// transaction. Prompts deleted since they were listed are skipped.
+func (s *SQLiteStore) SaveEmbeddings(model string, sources []models.EmbeddingSource, vectors [][]float32) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	if len(sources) != len(vectors) {
+		return invalid("invalid embeddings: %d vectors for %d prompts", len(vectors), len(sources))
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, source := range sources {
+		_, err := tx.Exec(`
+			INSERT INTO prompt_embeddings (prompt_id, model, source_sha256, dimensions, vector)
+			SELECT id, ?, ?, ?, ? FROM prompts WHERE id = ?
+			ON CONFLICT(prompt_id) DO UPDATE SET
+				model = excluded.model,
+				source_sha256 = excluded.source_sha256,
+				dimensions = excluded.dimensions,
+				vector = excluded.vector,
+				updated_at = CURRENT_TIMESTAMP
+		`, model, source.SourceSHA256, len(vectors[i]), encodeVector(normalize(vectors[i])), source.PromptID)
+		if err != nil {
+			s.logger.Error("failed to save embedding", "error", err, "project", source.Project, "slug", source.Slug)
+			return fmt.Errorf("failed to save embedding: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("SaveEmbeddings", duration)
+	s.logger.Info("database operation",
+		"operation", "SaveEmbeddings",
+		"model", model,
+		"count", len(sources),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// SemanticSearch returns the prompts in the project whose embeddings from
+// model are most similar to query, best first. Only prompts the caller can
+// read and that have been embedded are considered.
+func (s *SQLiteStore) SemanticSearch(model string, query []float32, limit int) ([]models.SemanticSearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	if limit < 1 || limit > MaxSemanticSearchResults {
+		return nil, invalidField("limit", "invalid limit %d: want 1 to %d", limit, MaxSemanticSearchResults)
+	}
+	query = normalize(query)
+
+	rows, err := s.db.Query(`
+		SELECT p.slug, p.title, p.description, p.current_version, e.vector
+		FROM prompt_embeddings e
+		JOIN prompts p ON p.id = e.prompt_id
+		WHERE p.project = ? AND p.archived_at IS NULL AND e.model = ? AND e.dimensions = ? AND `+readableByCaller,
+		append([]any{s.project, model, len(query)}, s.readableArgs()...)...,
+	)
+	if err != nil {
+		s.logger.Error("failed to search embeddings", "error", err)
+		return nil, fmt.Errorf("failed to search embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.SemanticSearchResult{}
+	for rows.Next() {
+		var result models.SemanticSearchResult
+		var blob []byte
+		if err := rows.Scan(&result.Slug, &result.Title, &result.Description, &result.CurrentVersion, &blob); err != nil {
+			s.logger.Error("failed to scan embedding", "error", err)
+			return nil, fmt.Errorf("failed to scan embedding: %w", err)
+		}
+		result.Score = dot(query, decodeVector(blob))
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate embeddings", "error", err)
+		return nil, fmt.Errorf("failed to iterate embeddings: %w", err)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Slug < results[j].Slug
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	duration := time.Since(start)
+	s.observe("SemanticSearch", duration)
+	s.logger.Info("database operation",
+		"operation", "SemanticSearch",
+		"model", model,
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// embeddingText is what a prompt is embedded from. Chat prompts contribute
+// their message contents; content that doesn't parse is used as it is.
+func embeddingText(title, description, format, content string) string {
+	parts := []string{title}
+	if description != "" {
+		parts = append(parts, description)
+	}
+	if format == render.FormatChat {
+		if messages, err := render.ParseMessages(content); err == nil {
+			for _, m := range messages {
+				parts = append(parts, m.Content)
+			}
+			return strings.Join(parts, "\n\n")
+		}
+	}
+	return strings.Join(append(parts, content), "\n\n")
+}
+
+// normalize scales v to unit length so cosine similarity is a dot product.
+// A zero vector is returned as it is.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := math.Sqrt(sum)
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(float64(x) / norm)
+	}
+	return out
+}
+
+// dot returns the dot product of two vectors of the same length
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range min(len(a), len(b)) {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// encodeVector packs v as little-endian float32s
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+// decodeVector unpacks a vector written by encodeVector
+func decodeVector(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v
+}
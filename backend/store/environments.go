@@ -0,0 +1,352 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// environmentSchema holds each project's promotion pipeline, in position
+// order. What is live in an environment is the prompt_labels row for the
+// label of the same name, so promotions are releases and can be rolled back.
+const environmentSchema = `
+	CREATE TABLE IF NOT EXISTS environments (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		project    TEXT NOT NULL,
+		name       TEXT NOT NULL,
+		position   INTEGER NOT NULL,
+		created_by TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(project, name)
+	);
+`
+
+// CreateEnvironment adds an environment to the end of the project's
+// pipeline. A label of the same name that releases already set becomes
+// what is live in it.
+func (s *SQLiteStore) CreateEnvironment(input models.CreateEnvironmentInput) (models.Environment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	if err := ValidateLabel(input.Name); err != nil {
+		return models.Environment{}, invalidField("name", "invalid environment name %q: use 1-63 lowercase letters, digits, and hyphens", input.Name)
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO environments (project, name, position, created_by)
+		SELECT ?, ?, COALESCE(MAX(position), 0) + 1, ? FROM environments WHERE project = ?
+	`, s.project, input.Name, s.actor, s.project)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint") {
+			return models.Environment{}, conflict("environment %q already exists", input.Name)
+		}
+		s.logger.Error("failed to insert environment", "error", err, "environment", input.Name)
+		return models.Environment{}, fmt.Errorf("failed to insert environment: %w", err)
+	}
+	result, err := s.environment(s.db, input.Name)
+	if err != nil {
+		return result, err
+	}
+
+	duration := time.Since(start)
+	s.observe("CreateEnvironment", duration)
+	s.logger.Info("database operation",
+		"operation", "CreateEnvironment",
+		"environment", input.Name,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// ListEnvironments returns the project's pipeline in promotion order
+func (s *SQLiteStore) ListEnvironments() ([]models.Environment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	results, err := s.environments(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Since(start)
+	s.observe("ListEnvironments", duration)
+	s.logger.Info("database operation",
+		"operation", "ListEnvironments",
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// GetEnvironment returns an environment with the version of each prompt
+// live in it, by slug, and who promoted it there and when. Archived prompts
+// and prompts the caller can't read are left out.
+func (s *SQLiteStore) GetEnvironment(name string) (models.Environment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	result, err := s.environment(s.db, name)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT p.slug, l.version_number, l.release_id, COALESCE(r.created_by, ''), l.updated_at
+		FROM prompt_labels l
+		JOIN prompts p ON p.id = l.prompt_id
+		LEFT JOIN releases r ON r.id = l.release_id
+		WHERE p.project = ? AND l.label = ? AND p.archived_at IS NULL AND `+readableByCaller+`
+		ORDER BY p.slug
+	`, append([]any{s.project, name}, s.readableArgs()...)...)
+	if err != nil {
+		s.logger.Error("failed to list deployments", "error", err, "environment", name)
+		return result, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	defer rows.Close()
+
+	result.Deployments = []models.Deployment{}
+	for rows.Next() {
+		var d models.Deployment
+		if err := rows.Scan(&d.Slug, &d.Version, &d.ReleaseID, &d.PromotedBy, &d.PromotedAt); err != nil {
+			s.logger.Error("failed to scan deployment", "error", err)
+			return result, fmt.Errorf("failed to scan deployment: %w", err)
+		}
+		result.Deployments = append(result.Deployments, d)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate deployments", "error", err)
+		return result, fmt.Errorf("failed to iterate deployments: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("GetEnvironment", duration)
+	s.logger.Info("database operation",
+		"operation", "GetEnvironment",
+		"environment", name,
+		"rows_returned", len(result.Deployments),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// DeleteEnvironment removes an environment from the pipeline. Its label is
+// kept, so renders by label keep working; creating the environment again
+// picks it back up.
+func (s *SQLiteStore) DeleteEnvironment(name string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	res, err := s.db.Exec(`DELETE FROM environments WHERE project = ? AND name = ?`, s.project, name)
+	if err != nil {
+		s.logger.Error("failed to delete environment", "error", err, "environment", name)
+		return fmt.Errorf("failed to delete environment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return notFound("environment %q not found", name)
+	}
+
+	duration := time.Since(start)
+	s.observe("DeleteEnvironment", duration)
+	s.logger.Info("database operation",
+		"operation", "DeleteEnvironment",
+		"environment", name,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// Promote puts a version of a prompt live in an environment, as a release
+// of the environment's label recording who promoted it and when. Any
+// version can go into the first environment; later ones only take the
+// version live in the environment before them, so versions move through
+// the pipeline in order.
+func (s *SQLiteStore) Promote(slug string, input models.PromoteInput) (models.Release, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	if input.Environment == "" {
+		return models.Release{}, invalidField("environment", "environment is required")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return models.Release{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	target, err := s.environment(tx, input.Environment)
+	if err != nil {
+		return models.Release{}, err
+	}
+	a, err := s.access(tx, slug)
+	if err != nil {
+		return models.Release{}, err
+	}
+	if !a.allows(s.actor, AccessRead) {
+		return models.Release{}, promptNotFound(slug)
+	}
+
+	version, note := input.Version, input.Note
+	if target.Previous == "" {
+		if version == 0 {
+			if err := tx.QueryRow(`SELECT current_version FROM prompts WHERE id = ?`, a.id).Scan(&version); err != nil {
+				s.logger.Error("failed to get current version", "error", err, "slug", slug)
+				return models.Release{}, fmt.Errorf("failed to get current version: %w", err)
+			}
+		}
+	} else {
+		live, err := labelVersion(tx, a.id, target.Previous)
+		if err != nil {
+			s.logger.Error("failed to get label", "error", err, "slug", slug, "label", target.Previous)
+			return models.Release{}, err
+		}
+		if live == 0 {
+			return models.Release{}, conflict("prompt %q isn't in %s; promote it there first", slug, target.Previous)
+		}
+		if version == 0 {
+			version = live
+		}
+		if version != live {
+			return models.Release{}, conflict("version %d of prompt %q isn't in %s, which has version %d; promote it there first",
+				version, slug, target.Previous, live)
+		}
+		if note == "" {
+			note = "promoted from " + target.Previous
+		}
+	}
+	current, err := labelVersion(tx, a.id, target.Name)
+	if err != nil {
+		s.logger.Error("failed to get label", "error", err, "slug", slug, "label", target.Name)
+		return models.Release{}, err
+	}
+	if current == version {
+		return models.Release{}, conflict("version %d of prompt %q is already in %s", version, slug, target.Name)
+	}
+
+	result, err := s.createRelease(tx, models.CreateReleaseInput{
+		Label: target.Name,
+		Note:  note,
+		Items: []models.ReleaseItem{{Slug: slug, Version: version}},
+	})
+	if err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("Promote", duration)
+	s.logger.Info("database operation",
+		"operation", "Promote",
+		"slug", slug,
+		"environment", target.Name,
+		"version", version,
+		"release_id", result.ID,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// environmentNamed returns the project's environment called name, if there
+// is one
+func (s *SQLiteStore) environmentNamed(q querier, name string) (models.Environment, bool, error) {
+	env, err := s.environment(q, name)
+	if errors.Is(err, ErrNotFound) {
+		return env, false, nil
+	}
+	return env, err == nil, err
+}
+
+// wasLabelled reports whether a release ever gave a prompt's version the
+// label, even if it has since moved on or been rolled back
+func wasLabelled(q queryRower, promptID int64, label string, version int) (bool, error) {
+	var found bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM release_items ri JOIN releases r ON r.id = ri.release_id
+			WHERE ri.prompt_id = ? AND r.label = ? AND ri.version_number = ?
+		)
+	`, promptID, label, version).Scan(&found)
+	if err != nil {
+		return false, fmt.Errorf("failed to check label history: %w", err)
+	}
+	return found, nil
+}
+
+// labelVersion returns the version a prompt's label points at, or 0
+func labelVersion(q queryRower, promptID int64, label string) (int, error) {
+	var version int
+	err := q.QueryRow(`SELECT version_number FROM prompt_labels WHERE prompt_id = ? AND label = ?`, promptID, label).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get label: %w", err)
+	}
+	return version, nil
+}
+
+// environment returns one environment of the project with its neighbours
+// in the pipeline
+func (s *SQLiteStore) environment(q querier, name string) (models.Environment, error) {
+	envs, err := s.environments(q)
+	if err != nil {
+		return models.Environment{}, err
+	}
+	for _, env := range envs {
+		if env.Name == name {
+			return env, nil
+		}
+	}
+	return models.Environment{}, notFound("environment %q not found", name)
+}
+
+// environments lists the project's pipeline in order, counting the prompts
+// the caller can read that are live in each environment
+func (s *SQLiteStore) environments(q querier) ([]models.Environment, error) {
+	rows, err := q.Query(`
+		SELECT e.name, e.created_by, e.created_at,
+			(SELECT COUNT(*) FROM prompt_labels l JOIN prompts p ON p.id = l.prompt_id
+				WHERE p.project = e.project AND l.label = e.name AND p.archived_at IS NULL AND `+readableByCaller+`)
+		FROM environments e
+		WHERE e.project = ?
+		ORDER BY e.position, e.id
+	`, append(s.readableArgs(), s.project)...)
+	if err != nil {
+		s.logger.Error("failed to list environments", "error", err)
+		return nil, fmt.Errorf("failed to list environments: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.Environment{}
+	for rows.Next() {
+		var env models.Environment
+		if err := rows.Scan(&env.Name, &env.CreatedBy, &env.CreatedAt, &env.Prompts); err != nil {
+			s.logger.Error("failed to scan environment", "error", err)
+			return nil, fmt.Errorf("failed to scan environment: %w", err)
+		}
+		env.Position = len(results) + 1
+		if n := len(results); n > 0 {
+			env.Previous = results[n-1].Name
+			results[n-1].Next = env.Name
+		}
+		results = append(results, env)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate environments", "error", err)
+		return nil, fmt.Errorf("failed to iterate environments: %w", err)
+	}
+	return results, nil
+}
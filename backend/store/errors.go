@@ -0,0 +1,109 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Store methods return errors that wrap one of these, so callers can choose
+// a response with errors.Is rather than by matching messages. Other errors
+// are failures of the database itself.
+var (
+	// ErrNotFound: the prompt, version, or other record doesn't exist or
+	// isn't visible to the caller
+	ErrNotFound = errors.New("not found")
+	// ErrConflict: the request is valid but clashes with the current state,
+	// e.g. a slug that is taken or a stale base version
+	ErrConflict = errors.New("conflict")
+	// ErrValidation: the input is malformed or out of range
+	ErrValidation = errors.New("invalid input")
+	// ErrPermission: the caller can see the record but not change it
+	ErrPermission = errors.New("permission denied")
+)
+
+// Narrower kinds for the cases clients most often tell apart. Each also
+// matches the general kind it narrows.
+var (
+	ErrPromptNotFound  = fmt.Errorf("prompt %w", ErrNotFound)
+	ErrVersionNotFound = fmt.Errorf("version %w", ErrNotFound)
+	ErrSlugTaken       = fmt.Errorf("slug taken: %w", ErrConflict)
+	ErrVersionConflict = fmt.Errorf("version %w", ErrConflict)
+)
+
+// kindError gives an error one of the kinds above while keeping its message.
+// For ErrValidation, field names the input field at fault, as spelled in
+// JSON, when there is one.
+type kindError struct {
+	kind  error
+	field string
+	err   error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// withKind marks err as kind; nil stays nil
+func withKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// Field returns the input field a validation error is about, or "" when
+// it isn't about a single field
+func Field(err error) string {
+	var k *kindError
+	if errors.As(err, &k) {
+		return k.field
+	}
+	return ""
+}
+
+// withField marks err as an ErrValidation error about field
+func withField(field string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: ErrValidation, field: field, err: err}
+}
+
+// notFound formats an ErrNotFound error
+func notFound(format string, args ...any) error {
+	return withKind(ErrNotFound, fmt.Errorf(format, args...))
+}
+
+// conflict formats an ErrConflict error
+func conflict(format string, args ...any) error {
+	return withKind(ErrConflict, fmt.Errorf(format, args...))
+}
+
+// invalid formats an ErrValidation error
+func invalid(format string, args ...any) error {
+	return withKind(ErrValidation, fmt.Errorf(format, args...))
+}
+
+// invalidField formats an ErrValidation error about field
+func invalidField(field, format string, args ...any) error {
+	return withField(field, fmt.Errorf(format, args...))
+}
+
+// promptNotFound reports a slug with no prompt the caller can see
+func promptNotFound(slug string) error {
+	return withKind(ErrPromptNotFound, fmt.Errorf("prompt with slug %q not found", slug))
+}
+
+// versionNotFound reports a version number the prompt doesn't have
+func versionNotFound(slug string, version int) error {
+	return withKind(ErrVersionNotFound, fmt.Errorf("version %d not found for prompt %q", version, slug))
+}
+
+// slugTaken reports a slug already used by a prompt in the project
+func slugTaken(slug string) error {
+	return withKind(ErrSlugTaken, fmt.Errorf("prompt with slug %q already exists", slug))
+}
+
+// denied formats an ErrPermission error
+func denied(format string, args ...any) error {
+	return withKind(ErrPermission, fmt.Errorf(format, args...))
+}
@@ -0,0 +1,506 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// Eval run statuses
+const (
+	evalRunning   = "running"
+	evalCompleted = "completed"
+	evalFailed    = "failed"
+)
+
+// evalSchema holds the dataset and evaluation tables
+const evalSchema = `
+	CREATE TABLE IF NOT EXISTS datasets (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		name        TEXT UNIQUE NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS dataset_items (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		dataset_id INTEGER NOT NULL,
+		input      TEXT NOT NULL,
+		expected   TEXT NOT NULL,
+		FOREIGN KEY(dataset_id) REFERENCES datasets(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS eval_runs (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		prompt_id      INTEGER NOT NULL,
+		version_number INTEGER NOT NULL,
+		dataset_id     INTEGER NOT NULL,
+		provider       TEXT NOT NULL,
+		model          TEXT NOT NULL,
+		scorer         TEXT NOT NULL,
+		status         TEXT NOT NULL,
+		error          TEXT NOT NULL DEFAULT '',
+		total          INTEGER NOT NULL,
+		created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		finished_at    DATETIME,
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id),
+		FOREIGN KEY(dataset_id) REFERENCES datasets(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS eval_results (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id      INTEGER NOT NULL,
+		item_id     INTEGER NOT NULL,
+		output      TEXT NOT NULL,
+		score       REAL NOT NULL,
+		passed      BOOLEAN NOT NULL,
+		error       TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER NOT NULL,
+		FOREIGN KEY(run_id) REFERENCES eval_runs(id),
+		FOREIGN KEY(item_id) REFERENCES dataset_items(id),
+		UNIQUE(run_id, item_id)
+	);
+	`
+
+// evalRunQuery selects runs with counts and score aggregated from their results
+const evalRunQuery = `
+	SELECT
+		r.id, p.slug, r.version_number, d.name, r.provider, r.model, r.scorer,
+		r.status, r.error, r.total,
+		COUNT(er.id), COALESCE(SUM(er.passed), 0), COALESCE(AVG(er.score), 0),
+		r.created_at, r.finished_at
+	FROM eval_runs r
+	JOIN prompts p ON p.id = r.prompt_id
+	JOIN datasets d ON d.id = r.dataset_id
+	LEFT JOIN eval_results er ON er.run_id = r.id
+`
+
+// CreateDataset stores a dataset and its items
+func (s *SQLiteStore) CreateDataset(input models.CreateDatasetInput) (models.Dataset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.Dataset
+
+	if strings.TrimSpace(input.Name) == "" {
+		return result, invalidField("name", "name cannot be empty")
+	}
+	if len(input.Items) == 0 {
+		return result, invalidField("items", "items cannot be empty")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO datasets (name, description) VALUES (?, ?) RETURNING id, created_at`,
+		input.Name, input.Description,
+	).Scan(&result.ID, &result.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint") {
+			return result, conflict("dataset %q already exists", input.Name)
+		}
+		s.logger.Error("failed to insert dataset", "error", err, "name", input.Name)
+		return result, fmt.Errorf("failed to insert dataset: %w", err)
+	}
+
+	result.Items = make([]models.DatasetItem, 0, len(input.Items))
+	for _, item := range input.Items {
+		data, err := json.Marshal(item.Input)
+		if err != nil {
+			return result, fmt.Errorf("failed to encode dataset item: %w", err)
+		}
+		itemResult, err := tx.Exec(
+			`INSERT INTO dataset_items (dataset_id, input, expected) VALUES (?, ?, ?)`,
+			result.ID, string(data), item.Expected,
+		)
+		if err != nil {
+			s.logger.Error("failed to insert dataset item", "error", err, "name", input.Name)
+			return result, fmt.Errorf("failed to insert dataset item: %w", err)
+		}
+		if item.ID, err = itemResult.LastInsertId(); err != nil {
+			return result, fmt.Errorf("failed to get dataset item ID: %w", err)
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	result.Name = input.Name
+	result.Description = input.Description
+	result.ItemCount = len(result.Items)
+
+	duration := time.Since(start)
+	s.observe("CreateDataset", duration)
+	s.logger.Info("database operation",
+		"operation", "CreateDataset",
+		"name", input.Name,
+		"items", result.ItemCount,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// ListDatasets returns every dataset without its items, by name
+func (s *SQLiteStore) ListDatasets() ([]models.Dataset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	rows, err := s.db.Query(`
+		SELECT d.id, d.name, d.description, COUNT(i.id), d.created_at
+		FROM datasets d
+		LEFT JOIN dataset_items i ON i.dataset_id = d.id
+		GROUP BY d.id
+		ORDER BY d.name
+	`)
+	if err != nil {
+		s.logger.Error("failed to list datasets", "error", err)
+		return nil, fmt.Errorf("failed to list datasets: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.Dataset{}
+	for rows.Next() {
+		var dataset models.Dataset
+		if err := rows.Scan(&dataset.ID, &dataset.Name, &dataset.Description, &dataset.ItemCount, &dataset.CreatedAt); err != nil {
+			s.logger.Error("failed to scan dataset", "error", err)
+			return nil, fmt.Errorf("failed to scan dataset: %w", err)
+		}
+		results = append(results, dataset)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate datasets", "error", err)
+		return nil, fmt.Errorf("failed to iterate datasets: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("ListDatasets", duration)
+	s.logger.Info("database operation",
+		"operation", "ListDatasets",
+		"count", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// GetDataset retrieves a dataset with its items
+func (s *SQLiteStore) GetDataset(name string) (models.Dataset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.Dataset
+
+	err := s.db.QueryRow(
+		`SELECT id, name, description, created_at FROM datasets WHERE name = ?`, name,
+	).Scan(&result.ID, &result.Name, &result.Description, &result.CreatedAt)
+	if err == sql.ErrNoRows {
+		return result, notFound("dataset %q not found", name)
+	}
+	if err != nil {
+		s.logger.Error("failed to get dataset", "error", err, "name", name)
+		return result, fmt.Errorf("failed to get dataset: %w", err)
+	}
+
+	rows, err := s.db.Query(
+		`SELECT id, input, expected FROM dataset_items WHERE dataset_id = ? ORDER BY id`, result.ID,
+	)
+	if err != nil {
+		s.logger.Error("failed to get dataset items", "error", err, "name", name)
+		return result, fmt.Errorf("failed to get dataset items: %w", err)
+	}
+	defer rows.Close()
+
+	result.Items = []models.DatasetItem{}
+	for rows.Next() {
+		var item models.DatasetItem
+		var input string
+		if err := rows.Scan(&item.ID, &input, &item.Expected); err != nil {
+			s.logger.Error("failed to scan dataset item", "error", err)
+			return result, fmt.Errorf("failed to scan dataset item: %w", err)
+		}
+		if err := json.Unmarshal([]byte(input), &item.Input); err != nil {
+			return result, fmt.Errorf("failed to decode dataset item: %w", err)
+		}
+		result.Items = append(result.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate dataset items", "error", err)
+		return result, fmt.Errorf("failed to iterate dataset items: %w", err)
+	}
+	result.ItemCount = len(result.Items)
+
+	duration := time.Since(start)
+	s.observe("GetDataset", duration)
+	s.logger.Info("database operation",
+		"operation", "GetDataset",
+		"name", name,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// CreateEvalRun records a new running evaluation of run.Slug at
+// run.VersionNumber against run.Dataset
+func (s *SQLiteStore) CreateEvalRun(run models.EvalRun) (models.EvalRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	promptID, err := s.promptID(run.Slug)
+	if err != nil {
+		return run, err
+	}
+
+	var datasetID int64
+	err = s.db.QueryRow(`
+		SELECT d.id, COUNT(i.id)
+		FROM datasets d
+		LEFT JOIN dataset_items i ON i.dataset_id = d.id
+		WHERE d.name = ?
+		GROUP BY d.id
+	`, run.Dataset).Scan(&datasetID, &run.Total)
+	if err == sql.ErrNoRows {
+		return run, notFound("dataset %q not found", run.Dataset)
+	}
+	if err != nil {
+		s.logger.Error("failed to get dataset", "error", err, "name", run.Dataset)
+		return run, fmt.Errorf("failed to get dataset: %w", err)
+	}
+
+	run.Status = evalRunning
+	err = s.db.QueryRow(`
+		INSERT INTO eval_runs (prompt_id, version_number, dataset_id, provider, model, scorer, status, total)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id, created_at
+	`, promptID, run.VersionNumber, datasetID, run.Provider, run.Model, run.Scorer, run.Status, run.Total,
+	).Scan(&run.ID, &run.CreatedAt)
+	if err != nil {
+		s.logger.Error("failed to insert eval run", "error", err, "slug", run.Slug)
+		return run, fmt.Errorf("failed to insert eval run: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("CreateEvalRun", duration)
+	s.logger.Info("database operation",
+		"operation", "CreateEvalRun",
+		"slug", run.Slug,
+		"dataset", run.Dataset,
+		"run_id", run.ID,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return run, nil
+}
+
+// AddEvalResult records the outcome of one dataset item in a run
+func (s *SQLiteStore) AddEvalResult(runID int64, result models.EvalResult) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	_, err := s.db.Exec(`
+		INSERT INTO eval_results (run_id, item_id, output, score, passed, error, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, runID, result.ItemID, result.Output, result.Score, result.Passed, result.Error, result.DurationMs)
+	if err != nil {
+		s.logger.Error("failed to insert eval result", "error", err, "run_id", runID)
+		return fmt.Errorf("failed to insert eval result: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("AddEvalResult", duration)
+	s.logger.Debug("database operation",
+		"operation", "AddEvalResult",
+		"run_id", runID,
+		"item_id", result.ItemID,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// FinishEvalRun marks a run completed, or failed when runErr is not empty
+func (s *SQLiteStore) FinishEvalRun(runID int64, runErr string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	status := evalCompleted
+	if runErr != "" {
+		status = evalFailed
+	}
+
+	result, err := s.db.Exec(
+		`UPDATE eval_runs SET status = ?, error = ?, finished_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		status, runErr, runID,
+	)
+	if err != nil {
+		s.logger.Error("failed to finish eval run", "error", err, "run_id", runID)
+		return fmt.Errorf("failed to finish eval run: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		s.logger.Error("failed to get affected rows", "error", err)
+		return fmt.Errorf("failed to finish eval run: %w", err)
+	}
+	if affected == 0 {
+		return notFound("eval run %d not found", runID)
+	}
+
+	duration := time.Since(start)
+	s.observe("FinishEvalRun", duration)
+	s.logger.Info("database operation",
+		"operation", "FinishEvalRun",
+		"run_id", runID,
+		"status", status,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// GetEvalRun retrieves a run with its results in dataset order
+func (s *SQLiteStore) GetEvalRun(id int64) (models.EvalRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	// Runs of prompts the caller can't read are reported as not found
+	run, err := scanEvalRun(s.db.QueryRow(evalRunQuery+` WHERE r.id = ? AND `+readableByCaller+` GROUP BY r.id`,
+		append([]any{id}, s.readableArgs()...)...))
+	if err == sql.ErrNoRows {
+		return run, notFound("eval run %d not found", id)
+	}
+	if err != nil {
+		s.logger.Error("failed to get eval run", "error", err, "run_id", id)
+		return run, fmt.Errorf("failed to get eval run: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT er.item_id, i.input, i.expected, er.output, er.score, er.passed, er.error, er.duration_ms
+		FROM eval_results er
+		JOIN dataset_items i ON i.id = er.item_id
+		WHERE er.run_id = ?
+		ORDER BY er.item_id
+	`, id)
+	if err != nil {
+		s.logger.Error("failed to get eval results", "error", err, "run_id", id)
+		return run, fmt.Errorf("failed to get eval results: %w", err)
+	}
+	defer rows.Close()
+
+	run.Results = []models.EvalResult{}
+	for rows.Next() {
+		var result models.EvalResult
+		var input string
+		if err := rows.Scan(
+			&result.ItemID, &input, &result.Expected, &result.Output,
+			&result.Score, &result.Passed, &result.Error, &result.DurationMs,
+		); err != nil {
+			s.logger.Error("failed to scan eval result", "error", err)
+			return run, fmt.Errorf("failed to scan eval result: %w", err)
+		}
+		if err := json.Unmarshal([]byte(input), &result.Input); err != nil {
+			return run, fmt.Errorf("failed to decode dataset item: %w", err)
+		}
+		run.Results = append(run.Results, result)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate eval results", "error", err)
+		return run, fmt.Errorf("failed to iterate eval results: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("GetEvalRun", duration)
+	s.logger.Info("database operation",
+		"operation", "GetEvalRun",
+		"run_id", id,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return run, nil
+}
+
+// ListEvalRuns returns a prompt's runs without results, newest first
+func (s *SQLiteStore) ListEvalRuns(slug string) ([]models.EvalRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	if _, err := s.promptID(slug); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(evalRunQuery+` WHERE p.project = ? AND p.slug = ? GROUP BY r.id ORDER BY r.id DESC`, s.project, slug)
+	if err != nil {
+		s.logger.Error("failed to list eval runs", "error", err, "slug", slug)
+		return nil, fmt.Errorf("failed to list eval runs: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.EvalRun{}
+	for rows.Next() {
+		run, err := scanEvalRun(rows)
+		if err != nil {
+			s.logger.Error("failed to scan eval run", "error", err)
+			return nil, fmt.Errorf("failed to scan eval run: %w", err)
+		}
+		results = append(results, run)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate eval runs", "error", err)
+		return nil, fmt.Errorf("failed to iterate eval runs: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("ListEvalRuns", duration)
+	s.logger.Info("database operation",
+		"operation", "ListEvalRuns",
+		"slug", slug,
+		"count", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// failInterruptedEvalRuns marks runs left running by a previous process as failed
+func (s *SQLiteStore) failInterruptedEvalRuns() error {
+	result, err := s.db.Exec(
+		`UPDATE eval_runs SET status = ?, error = ?, finished_at = CURRENT_TIMESTAMP WHERE status = ?`,
+		evalFailed, "interrupted by server restart", evalRunning,
+	)
+	if err != nil {
+		s.logger.Error("failed to clean up eval runs", "error", err)
+		return fmt.Errorf("failed to clean up eval runs: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		s.logger.Warn("marked interrupted eval runs as failed", "count", n)
+	}
+	return nil
+}
+
+// scanEvalRun reads one row of evalRunQuery
+func scanEvalRun(row interface{ Scan(...any) error }) (models.EvalRun, error) {
+	var run models.EvalRun
+	var finishedAt sql.NullTime
+	err := row.Scan(
+		&run.ID, &run.Slug, &run.VersionNumber, &run.Dataset, &run.Provider, &run.Model, &run.Scorer,
+		&run.Status, &run.Error, &run.Total,
+		&run.Completed, &run.Passed, &run.Score,
+		&run.CreatedAt, &finishedAt,
+	)
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return run, err
+}
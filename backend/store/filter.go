@@ -0,0 +1,85 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// PromptFilter narrows a prompt listing. Zero fields don't filter; bounds are
+// inclusive.
+type PromptFilter struct {
+	// Creation time, the original one for imported prompts
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Time of the last change to the prompt or its versions
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	// Number of versions
+	MinVersions int
+	MaxVersions int
+	// Only prompts the caller has starred
+	Starred bool
+	// Text the title, description, or slug contains, ignoring case
+	Query string
+}
+
+// MaxQueryLength is the longest text a listing can search for
+const MaxQueryLength = 200
+
+// versionCount counts the versions of prompts p
+const versionCount = `(SELECT COUNT(*) FROM prompt_versions v WHERE v.prompt_id = p.id)`
+
+// where returns the filter's conditions on prompts p, each preceded by AND,
+// and their arguments. Starred prompts are the ones actor starred.
+func (f PromptFilter) where(actor string) (string, []any, error) {
+	if f.MinVersions < 0 || f.MaxVersions < 0 {
+		return "", nil, invalidField("min_versions", "invalid version count filter: must not be negative")
+	}
+	if f.MaxVersions > 0 && f.MinVersions > f.MaxVersions {
+		return "", nil, invalidField("min_versions", "invalid version count filter: min_versions is above max_versions")
+	}
+	if len(f.Query) > MaxQueryLength {
+		return "", nil, invalidField("q", "invalid query: longer than %d bytes", MaxQueryLength)
+	}
+
+	var b strings.Builder
+	var args []any
+	times := []struct {
+		column string
+		op     string
+		value  time.Time
+	}{
+		{sortColumns[SortCreatedAt], ">=", f.CreatedAfter},
+		{sortColumns[SortCreatedAt], "<=", f.CreatedBefore},
+		{sortColumns[SortUpdatedAt], ">=", f.UpdatedAfter},
+		{sortColumns[SortUpdatedAt], "<=", f.UpdatedBefore},
+	}
+	for _, t := range times {
+		if !t.value.IsZero() {
+			b.WriteString(" AND " + t.column + " " + t.op + " ?")
+			args = append(args, timestampValue(&t.value))
+		}
+	}
+	if f.MinVersions > 0 {
+		b.WriteString(" AND " + versionCount + " >= ?")
+		args = append(args, f.MinVersions)
+	}
+	if f.MaxVersions > 0 {
+		b.WriteString(" AND " + versionCount + " <= ?")
+		args = append(args, f.MaxVersions)
+	}
+	if f.Starred {
+		b.WriteString(" AND " + starredByCaller)
+		args = append(args, actor)
+	}
+	if f.Query != "" {
+		// LIKE ignores case for ASCII letters only, like the title sort
+		b.WriteString(` AND (p.title LIKE ? ESCAPE '\' OR p.description LIKE ? ESCAPE '\' OR p.slug LIKE ? ESCAPE '\')`)
+		pattern := "%" + likeEscaper.Replace(f.Query) + "%"
+		args = append(args, pattern, pattern, pattern)
+	}
+	return b.String(), args, nil
+}
+
+// likeEscaper escapes LIKE wildcards so a query matches them literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// ForkPrompt copies the prompt at slug into a new prompt at input.Slug and
+// records where it came from. The fork gets the source's current content,
+// description, format, variables, metadata, execution config, and access grants, so a
+// restricted prompt's content stays restricted. With input.History every
+// version is copied with its number, author, creation time, and review status,
+// so versions pending review stay unpublished in the fork; otherwise the fork
+// starts at version 1. The caller owns the fork, which starts private.
+func (s *SQLiteStore) ForkPrompt(slug string, input models.ForkPromptInput) (models.PromptWithCurrentVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.PromptWithCurrentVersion
+
+	if err := ValidateSlug(input.Slug); err != nil {
+		return result, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sourceID int64
+	var title, description, format, variables, metadata, execProvider, execModel string
+	var currentVersion int
+	err = tx.QueryRow(
+		`SELECT id, title, description, format, variables, metadata, exec_provider, exec_model, current_version FROM prompts WHERE project = ? AND slug = ?`,
+		s.project, slug,
+	).Scan(&sourceID, &title, &description, &format, &variables, &metadata, &execProvider, &execModel, &currentVersion)
+	if err == sql.ErrNoRows {
+		return result, promptNotFound(slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to get prompt", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to get prompt: %w", err)
+	}
+	if strings.TrimSpace(input.Title) != "" {
+		title = input.Title
+	}
+	forkVersion := 1
+	if input.History {
+		forkVersion = currentVersion
+	}
+
+	var promptID int64
+	err = tx.QueryRow(`
+		INSERT INTO prompts (project, slug, title, description, format, variables, metadata, exec_provider, exec_model, current_version,
+			forked_from_id, forked_from_version, created_by, updated_by, owner)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, s.project, input.Slug, title, description, format, variables, metadata, execProvider, execModel, forkVersion,
+		sourceID, currentVersion, s.actor, s.actor, s.actor,
+	).Scan(&promptID)
+	if err != nil {
+		s.logger.Error("failed to insert prompt", "error", err, "slug", input.Slug)
+		if strings.Contains(err.Error(), "UNIQUE constraint") {
+			return result, slugTaken(input.Slug)
+		}
+		return result, fmt.Errorf("failed to insert prompt: %w", err)
+	}
+
+	if input.History {
+		_, err = tx.Exec(`
+			INSERT INTO prompt_versions (prompt_id, version_number, content, content_sha256, model_config, metadata, original_created_at, created_by, redacted_at, original_sha256, review_status)
+			SELECT ?, version_number, content, content_sha256, model_config, metadata, COALESCE(original_created_at, created_at), created_by, redacted_at, original_sha256, review_status
+			FROM prompt_versions WHERE prompt_id = ?
+		`, promptID, sourceID)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO prompt_versions (prompt_id, version_number, content, content_sha256, model_config, metadata, created_by)
+			SELECT ?, 1, content, content_sha256, model_config, metadata, ? FROM prompt_versions WHERE prompt_id = ? AND version_number = ?
+		`, promptID, s.actor, sourceID, currentVersion)
+	}
+	if err != nil {
+		s.logger.Error("failed to copy versions", "error", err, "prompt_id", promptID)
+		return result, fmt.Errorf("failed to copy versions: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO prompt_grants (prompt_id, grantee_type, grantee, access, granted_by)
+		SELECT ?, grantee_type, grantee, access, ? FROM prompt_grants WHERE prompt_id = ?
+	`, promptID, s.actor, sourceID); err != nil {
+		s.logger.Error("failed to copy grants", "error", err, "prompt_id", promptID)
+		return result, fmt.Errorf("failed to copy grants: %w", err)
+	}
+	if err := s.audit(tx, promptID, auditPromptForked, forkVersion, fmt.Sprintf("from %s version %d", slug, currentVersion)); err != nil {
+		return result, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	result, err = s.getPromptBySlug(input.Slug)
+	if err != nil {
+		return result, err
+	}
+
+	duration := time.Since(start)
+	s.observe("ForkPrompt", duration)
+	s.logger.Info("database operation",
+		"operation", "ForkPrompt",
+		"slug", slug,
+		"fork", input.Slug,
+		"history", input.History,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+)
+
+// contentSHA256 returns the hex SHA-256 digest of a version's content, as
+// stored in prompt_versions.content_sha256
+func contentSHA256(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// migrateContentHashes adds prompt_versions.content_sha256 and fills it in
+// for existing versions, then locks it like the content. SQLite has no
+// SHA-256 function, so the digests are computed here.
+func migrateContentHashes(tx *sql.Tx) error {
+	if _, err := tx.Exec(`ALTER TABLE prompt_versions ADD COLUMN content_sha256 TEXT NOT NULL DEFAULT ''`); err != nil {
+		return err
+	}
+	rows, err := tx.Query(`SELECT id, content FROM prompt_versions`)
+	if err != nil {
+		return fmt.Errorf("failed to list versions: %w", err)
+	}
+	hashes := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan version: %w", err)
+		}
+		hashes[id] = contentSHA256(content)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("failed to iterate versions: %w", err)
+	}
+	for id, hash := range hashes {
+		if _, err := tx.Exec(`UPDATE prompt_versions SET content_sha256 = ? WHERE id = ?`, hash, id); err != nil {
+			return fmt.Errorf("failed to hash version %d: %w", id, err)
+		}
+	}
+	_, err = tx.Exec(contentHashImmutableTrigger)
+	return err
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// immutabilitySchema makes published version content read-only in the
+// database itself, so no code path or hand-written SQL can change what a
+// version number was. The only way through is to list the version in
+// version_content_unlocks inside the same transaction as the UPDATE, which is
+// what rewriteVersionContent does for the redaction flow. Operators editing
+// the database by hand must do the same:
+//
+//	BEGIN;
+//	INSERT INTO version_content_unlocks (version_id) VALUES (42);
+//	UPDATE prompt_versions SET content = '...', content_sha256 = '<hex SHA-256 of the new content>' WHERE id = 42;
+//	DELETE FROM version_content_unlocks WHERE version_id = 42;
+//	COMMIT;
+const immutabilitySchema = `
+	CREATE TABLE IF NOT EXISTS version_content_unlocks (
+		version_id INTEGER PRIMARY KEY
+	);
+
+	CREATE TRIGGER IF NOT EXISTS prompt_versions_content_immutable
+	BEFORE UPDATE OF content ON prompt_versions
+	WHEN NEW.content IS NOT OLD.content
+		AND NOT EXISTS (SELECT 1 FROM version_content_unlocks WHERE version_id = OLD.id)
+	BEGIN
+		SELECT RAISE(ABORT, 'prompt version content is immutable');
+	END;
+`
+
+// contentHashImmutableTrigger extends the same protection to the content
+// hash, so the hash can't be made to vouch for different content
+const contentHashImmutableTrigger = `
+	CREATE TRIGGER IF NOT EXISTS prompt_versions_content_hash_immutable
+	BEFORE UPDATE OF content_sha256 ON prompt_versions
+	WHEN NEW.content_sha256 IS NOT OLD.content_sha256
+		AND NOT EXISTS (SELECT 1 FROM version_content_unlocks WHERE version_id = OLD.id)
+	BEGIN
+		SELECT RAISE(ABORT, 'prompt version content is immutable');
+	END;
+`
+
+// rewriteVersionContent replaces a version's content and its hash within tx,
+// unlocking it only for the duration of the update. Writers are serialized,
+// so no other transaction ever sees the unlock.
+func rewriteVersionContent(tx *sql.Tx, versionID int64, content string) error {
+	if _, err := tx.Exec(`INSERT INTO version_content_unlocks (version_id) VALUES (?)`, versionID); err != nil {
+		return fmt.Errorf("failed to unlock version: %w", err)
+	}
+	if _, err := tx.Exec(
+		`UPDATE prompt_versions SET content = ?, content_sha256 = ? WHERE id = ?`, content, contentSHA256(content), versionID,
+	); err != nil {
+		return fmt.Errorf("failed to rewrite version: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM version_content_unlocks WHERE version_id = ?`, versionID); err != nil {
+		return fmt.Errorf("failed to lock version: %w", err)
+	}
+	return nil
+}
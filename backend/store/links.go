@@ -0,0 +1,216 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// Link kinds: a prompt includes another, such as a shared system preamble,
+// or was derived from it
+const (
+	LinkIncludes    = "includes"
+	LinkDerivedFrom = "derived_from"
+)
+
+// MaxPromptLinks bounds how many links one prompt can declare
+const MaxPromptLinks = 100
+
+// linkSchema records the links each prompt declares to other prompts in its
+// project. The index on target_id serves the reverse lookup: which prompts
+// depend on this one.
+const linkSchema = `
+	CREATE TABLE IF NOT EXISTS prompt_links (
+		prompt_id  INTEGER NOT NULL,
+		target_id  INTEGER NOT NULL,
+		kind       TEXT NOT NULL,
+		created_by TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id),
+		FOREIGN KEY(target_id) REFERENCES prompts(id),
+		PRIMARY KEY(prompt_id, target_id, kind)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_prompt_links_target ON prompt_links(target_id);
+`
+
+// GetPromptLinks returns the prompts a prompt links to and the prompts that
+// link to it. Prompts the caller can't read are left out of both.
+func (s *SQLiteStore) GetPromptLinks(slug string) (models.PromptLinks, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.PromptLinks
+	promptID, err := s.promptID(slug)
+	if err != nil {
+		return result, err
+	}
+	if result, err = s.promptLinks(promptID); err != nil {
+		return result, err
+	}
+
+	duration := time.Since(start)
+	s.observe("GetPromptLinks", duration)
+	s.logger.Info("database operation",
+		"operation", "GetPromptLinks",
+		"slug", slug,
+		"links", len(result.Links),
+		"dependents", len(result.Dependents),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// SetPromptLinks replaces the links a prompt declares. Each target must be a
+// prompt in the same project that the caller can read, and a link can't
+// close a cycle of links of the same kind.
+func (s *SQLiteStore) SetPromptLinks(slug string, input models.SetPromptLinksInput) (models.PromptLinks, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.PromptLinks
+	if len(input.Links) > MaxPromptLinks {
+		return result, invalidField("links", "invalid links: at most %d per prompt", MaxPromptLinks)
+	}
+	seen := make(map[string]bool)
+	for _, link := range input.Links {
+		if link.Kind != LinkIncludes && link.Kind != LinkDerivedFrom {
+			return result, invalidField("links", "invalid link kind %q: use %q or %q", link.Kind, LinkIncludes, LinkDerivedFrom)
+		}
+		if link.Slug == slug {
+			return result, invalidField("links", "invalid link: prompt %q can't link to itself", slug)
+		}
+		key := link.Kind + ":" + link.Slug
+		if seen[key] {
+			return result, invalidField("links", "invalid links: %s is listed more than once", key)
+		}
+		seen[key] = true
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	a, err := s.access(tx, slug)
+	if err != nil {
+		return result, err
+	}
+	if _, err := tx.Exec(`DELETE FROM prompt_links WHERE prompt_id = ?`, a.id); err != nil {
+		s.logger.Error("failed to clear links", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to clear links: %w", err)
+	}
+	var detail []string
+	for _, link := range input.Links {
+		target, err := s.access(tx, link.Slug)
+		if err == nil && !target.allows(s.actor, AccessRead) {
+			err = promptNotFound(link.Slug)
+		}
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return result, invalidField("links", "invalid link: prompt %q not found", link.Slug)
+			}
+			return result, err
+		}
+
+		// A cycle exists if the target already reaches this prompt
+		var cycle bool
+		err = tx.QueryRow(`
+			WITH RECURSIVE reach(id) AS (
+				SELECT ?
+				UNION
+				SELECT l.target_id FROM prompt_links l JOIN reach r ON l.prompt_id = r.id WHERE l.kind = ?
+			)
+			SELECT EXISTS (SELECT 1 FROM reach WHERE id = ?)
+		`, target.id, link.Kind, a.id).Scan(&cycle)
+		if err != nil {
+			s.logger.Error("failed to check links", "error", err, "slug", slug)
+			return result, fmt.Errorf("failed to check links: %w", err)
+		}
+		if cycle {
+			return result, invalidField("links", "invalid link: %s %s would form a cycle back to %s", link.Kind, link.Slug, slug)
+		}
+
+		if _, err := tx.Exec(
+			`INSERT INTO prompt_links (prompt_id, target_id, kind, created_by) VALUES (?, ?, ?, ?)`,
+			a.id, target.id, link.Kind, s.actor,
+		); err != nil {
+			s.logger.Error("failed to insert link", "error", err, "slug", slug)
+			return result, fmt.Errorf("failed to insert link: %w", err)
+		}
+		detail = append(detail, link.Kind+":"+link.Slug)
+	}
+	if err := s.audit(tx, a.id, auditLinksChanged, 0, strings.Join(detail, " ")); err != nil {
+		return result, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if result, err = s.promptLinks(a.id); err != nil {
+		return result, err
+	}
+
+	duration := time.Since(start)
+	s.observe("SetPromptLinks", duration)
+	s.logger.Info("database operation",
+		"operation", "SetPromptLinks",
+		"slug", slug,
+		"links", len(result.Links),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// promptLinks loads a prompt's links in both directions, by kind then slug
+func (s *SQLiteStore) promptLinks(promptID int64) (models.PromptLinks, error) {
+	result := models.PromptLinks{}
+	var err error
+	if result.Links, err = s.queryLinks("prompt_id", "target_id", promptID); err != nil {
+		return result, err
+	}
+	if result.Dependents, err = s.queryLinks("target_id", "prompt_id", promptID); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// queryLinks lists the links whose column end is promptID, naming the
+// prompt at their other end
+func (s *SQLiteStore) queryLinks(end, other string, promptID int64) ([]models.PromptLink, error) {
+	rows, err := s.db.Query(`
+		SELECT p.slug, l.kind, l.created_by, l.created_at
+		FROM prompt_links l
+		JOIN prompts p ON p.id = l.`+other+`
+		WHERE l.`+end+` = ? AND `+readableByCaller+`
+		ORDER BY l.kind, p.slug
+	`, append([]any{promptID}, s.readableArgs()...)...)
+	if err != nil {
+		s.logger.Error("failed to list links", "error", err)
+		return nil, fmt.Errorf("failed to list links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []models.PromptLink{}
+	for rows.Next() {
+		var link models.PromptLink
+		if err := rows.Scan(&link.Slug, &link.Kind, &link.CreatedBy, &link.CreatedAt); err != nil {
+			s.logger.Error("failed to scan link", "error", err)
+			return nil, fmt.Errorf("failed to scan link: %w", err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate links", "error", err)
+		return nil, fmt.Errorf("failed to iterate links: %w", err)
+	}
+	return links, nil
+}
@@ -0,0 +1,352 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// adminAuditSchema records destructive maintenance operations. It is kept
+// apart from audit_log because the prompts they act on may be gone
+// afterwards, taking their own audit entries with them.
+const adminAuditSchema = `
+	CREATE TABLE IF NOT EXISTS admin_audit_log (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor      TEXT NOT NULL,
+		action     TEXT NOT NULL,
+		project    TEXT NOT NULL DEFAULT '',
+		slug       TEXT NOT NULL DEFAULT '',
+		detail     TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+`
+
+// promptHistoryDeletes remove everything recorded about prompt ?1, children
+// before parents so foreign keys hold throughout. Forks of it are kept but
+// no longer point at it.
+var promptHistoryDeletes = []string{
+	`DELETE FROM eval_results WHERE run_id IN (SELECT id FROM eval_runs WHERE prompt_id = ?1)`,
+	`DELETE FROM eval_runs WHERE prompt_id = ?1`,
+	`DELETE FROM release_items WHERE prompt_id = ?1`,
+	`DELETE FROM prompt_labels WHERE prompt_id = ?1`,
+	`DELETE FROM prompt_links WHERE prompt_id = ?1 OR target_id = ?1`,
+	`DELETE FROM collection_prompts WHERE prompt_id = ?1`,
+	`DELETE FROM prompt_grants WHERE prompt_id = ?1`,
+	`DELETE FROM prompt_stars WHERE prompt_id = ?1`,
+	`DELETE FROM prompt_usage WHERE prompt_id = ?1`,
+	`DELETE FROM prompt_docs WHERE prompt_id = ?1`,
+	`DELETE FROM prompt_webhooks WHERE prompt_id = ?1`,
+	`DELETE FROM prompt_embeddings WHERE prompt_id = ?1`,
+	`DELETE FROM version_comments WHERE prompt_id = ?1`,
+	`DELETE FROM version_approvals WHERE prompt_id = ?1`,
+	`DELETE FROM audit_log WHERE prompt_id = ?1`,
+	`DELETE FROM prompt_versions WHERE prompt_id = ?1`,
+	`UPDATE prompts SET forked_from_id = NULL, forked_from_version = NULL WHERE forked_from_id = ?1`,
+	`DELETE FROM prompts WHERE id = ?1`,
+}
+
+// DeletePromptPermanently deletes a prompt and its whole history: versions,
+// comments, docs, labels, release entries, eval runs, and audit entries.
+// Unlike archiving it can't be undone. A prompt under legal hold can't be
+// deleted. An entry in the admin audit log records the deletion.
+func (s *SQLiteStore) DeletePromptPermanently(slug string) (models.DeletedPrompt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	result := models.DeletedPrompt{Project: s.project, Slug: slug}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var held *time.Time
+	err = tx.QueryRow(`
+		SELECT id, legal_hold_at, (SELECT COUNT(*) FROM prompt_versions v WHERE v.prompt_id = p.id)
+		FROM prompts p WHERE project = ? AND slug = ?
+	`, s.project, slug).Scan(&id, &held, &result.Versions)
+	if err == sql.ErrNoRows {
+		return result, promptNotFound(slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to get prompt", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to get prompt: %w", err)
+	}
+	if held != nil {
+		return result, conflict("prompt %q is under legal hold and can't be deleted", slug)
+	}
+
+	if err := s.deletePromptHistory(tx, id); err != nil {
+		return result, err
+	}
+	detail := fmt.Sprintf("%d versions", result.Versions)
+	if err := s.adminAudit(tx, auditAdminPromptDeleted, s.project, slug, detail); err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("DeletePromptPermanently", duration)
+	s.logger.Info("database operation",
+		"operation", "DeletePromptPermanently",
+		"slug", slug,
+		"versions", result.Versions,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// PurgeArchivedPrompts permanently deletes prompts in every project that were
+// archived before cutoff, as DeletePromptPermanently does, skipping prompts
+// under legal hold. The purge is one transaction and one admin audit entry.
+// With dryRun nothing is deleted or recorded and the result lists what would be.
+func (s *SQLiteStore) PurgeArchivedPrompts(cutoff time.Time, dryRun bool) (models.PurgeResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	result := models.PurgeResult{DryRun: dryRun, Prompts: []models.DeletedPrompt{}}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, project, slug, (SELECT COUNT(*) FROM prompt_versions v WHERE v.prompt_id = p.id)
+		FROM prompts p
+		WHERE archived_at IS NOT NULL AND archived_at < ? AND legal_hold_at IS NULL
+		ORDER BY project, slug
+	`, timestampValue(&cutoff))
+	if err != nil {
+		s.logger.Error("failed to list archived prompts", "error", err)
+		return result, fmt.Errorf("failed to list archived prompts: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var p models.DeletedPrompt
+		if err := rows.Scan(&id, &p.Project, &p.Slug, &p.Versions); err != nil {
+			rows.Close()
+			s.logger.Error("failed to scan archived prompt", "error", err)
+			return result, fmt.Errorf("failed to scan archived prompt: %w", err)
+		}
+		ids = append(ids, id)
+		result.Prompts = append(result.Prompts, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate archived prompts", "error", err)
+		return result, fmt.Errorf("failed to iterate archived prompts: %w", err)
+	}
+
+	if !dryRun {
+		names := make([]string, len(result.Prompts))
+		for i, id := range ids {
+			if err := s.deletePromptHistory(tx, id); err != nil {
+				return result, err
+			}
+			names[i] = result.Prompts[i].Project + "/" + result.Prompts[i].Slug
+		}
+		detail := fmt.Sprintf("%d prompts", len(names))
+		if len(names) > 0 {
+			detail += ": " + strings.Join(names, ", ")
+		}
+		if err := s.adminAudit(tx, auditAdminArchivedPurged, "", "", detail); err != nil {
+			return result, err
+		}
+		if err := tx.Commit(); err != nil {
+			s.logger.Error("failed to commit transaction", "error", err)
+			return result, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+	}
+
+	duration := time.Since(start)
+	result.DurationMs = duration.Milliseconds()
+	s.observe("PurgeArchivedPrompts", duration)
+	s.logger.Info("database operation",
+		"operation", "PurgeArchivedPrompts",
+		"dry_run", dryRun,
+		"prompts", len(result.Prompts),
+		"duration_ms", result.DurationMs,
+	)
+	return result, nil
+}
+
+// deletePromptHistory removes a prompt and everything recorded about it within tx
+func (s *SQLiteStore) deletePromptHistory(tx *sql.Tx, promptID int64) error {
+	for _, stmt := range promptHistoryDeletes {
+		if _, err := tx.Exec(stmt, promptID); err != nil {
+			s.logger.Error("failed to delete prompt history", "error", err, "prompt_id", promptID)
+			return fmt.Errorf("failed to delete prompt history: %w", err)
+		}
+	}
+	return nil
+}
+
+// RecomputeDenormalized repairs columns derived from other data, in every
+// project: a prompt's current version when that version no longer exists
+// falls back to its newest one not pending review, and versions missing a
+// content hash get one. Hashes that disagree with their content are counted
+// and logged, never rewritten. An admin audit entry records the counts.
+func (s *SQLiteStore) RecomputeDenormalized() (models.RecomputeResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.RecomputeResult
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE prompts
+		SET current_version = COALESCE((SELECT MAX(v.version_number) FROM prompt_versions v WHERE v.prompt_id = prompts.id AND v.review_status != 'pending'), 0)
+		WHERE current_version != COALESCE((SELECT MAX(v.version_number) FROM prompt_versions v WHERE v.prompt_id = prompts.id AND v.review_status != 'pending'), 0)
+			AND NOT EXISTS (SELECT 1 FROM prompt_versions v WHERE v.prompt_id = prompts.id AND v.version_number = prompts.current_version)
+	`)
+	if err != nil {
+		s.logger.Error("failed to recompute current versions", "error", err)
+		return result, fmt.Errorf("failed to recompute current versions: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	result.CurrentVersions = int(n)
+
+	type version struct {
+		id      int64
+		content string
+	}
+	rows, err := tx.Query(`SELECT id, content, content_sha256 FROM prompt_versions ORDER BY id`)
+	if err != nil {
+		s.logger.Error("failed to list versions", "error", err)
+		return result, fmt.Errorf("failed to list versions: %w", err)
+	}
+	var missing []version
+	for rows.Next() {
+		var v version
+		var hash string
+		if err := rows.Scan(&v.id, &v.content, &hash); err != nil {
+			rows.Close()
+			s.logger.Error("failed to scan version", "error", err)
+			return result, fmt.Errorf("failed to scan version: %w", err)
+		}
+		switch {
+		case hash == "":
+			missing = append(missing, v)
+		case hash != contentSHA256(v.content):
+			result.HashMismatches++
+			s.logger.Warn("version content doesn't match its hash", "version_id", v.id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate versions", "error", err)
+		return result, fmt.Errorf("failed to iterate versions: %w", err)
+	}
+	for _, v := range missing {
+		if err := rewriteVersionContent(tx, v.id, v.content); err != nil {
+			s.logger.Error("failed to fill in content hash", "error", err, "version_id", v.id)
+			return result, err
+		}
+	}
+	result.ContentHashes = len(missing)
+
+	detail := fmt.Sprintf("current_versions=%d content_hashes=%d hash_mismatches=%d",
+		result.CurrentVersions, result.ContentHashes, result.HashMismatches)
+	if err := s.adminAudit(tx, auditAdminRecomputed, "", "", detail); err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	result.DurationMs = duration.Milliseconds()
+	s.observe("RecomputeDenormalized", duration)
+	s.logger.Info("database operation",
+		"operation", "RecomputeDenormalized",
+		"current_versions", result.CurrentVersions,
+		"content_hashes", result.ContentHashes,
+		"hash_mismatches", result.HashMismatches,
+		"duration_ms", result.DurationMs,
+	)
+	return result, nil
+}
+
+// execer runs a statement on a database or within a transaction
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// adminAudit records a maintenance operation. project and slug are empty
+// for operations on the whole registry.
+func (s *SQLiteStore) adminAudit(e execer, action, project, slug, detail string) error {
+	_, err := e.Exec(
+		`INSERT INTO admin_audit_log (actor, action, project, slug, detail) VALUES (?, ?, ?, ?, ?)`,
+		s.actor, action, project, slug, detail,
+	)
+	if err != nil {
+		s.logger.Error("failed to write admin audit entry", "error", err, "action", action)
+		return fmt.Errorf("failed to write admin audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAdminAuditEntries returns maintenance operations, newest first
+func (s *SQLiteStore) ListAdminAuditEntries(limit, offset int) ([]models.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	rows, err := s.db.Query(`
+		SELECT id, project, slug, actor, action, detail, created_at
+		FROM admin_audit_log
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, limit, offset)
+	if err != nil {
+		s.logger.Error("failed to list admin audit entries", "error", err)
+		return nil, fmt.Errorf("failed to list admin audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.Project, &entry.Slug, &entry.Actor, &entry.Action, &entry.Detail, &entry.CreatedAt); err != nil {
+			s.logger.Error("failed to scan admin audit entry", "error", err)
+			return nil, fmt.Errorf("failed to scan admin audit entry: %w", err)
+		}
+		results = append(results, entry)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate admin audit entries", "error", err)
+		return nil, fmt.Errorf("failed to iterate admin audit entries: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("ListAdminAuditEntries", duration)
+	s.logger.Info("database operation",
+		"operation", "ListAdminAuditEntries",
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
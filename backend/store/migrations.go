@@ -0,0 +1,381 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// schemaMigrationsSchema records the migrations applied to a database
+const schemaMigrationsSchema = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+
+// migration is one numbered schema change. up and down each run in a single
+// transaction with foreign keys off, so they can rebuild tables; down is nil
+// when the change can't be undone.
+type migration struct {
+	version  int
+	name     string
+	up, down func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change in order, numbered from 1. Add a
+// change by appending the next version; never edit or renumber a migration
+// that has shipped, since databases record which ones they already have.
+var migrations = []migration{
+	{version: 1, name: "baseline", up: migrateBaseline},
+	{
+		version: 2,
+		name:    "releases",
+		up:      execMigration(releaseSchema),
+		down: execMigration(`
+			DROP TABLE prompt_labels;
+			DROP TABLE release_items;
+			DROP TABLE releases;
+		`),
+	},
+	{
+		version: 3,
+		name:    "prompt forks",
+		up: execMigration(`
+			ALTER TABLE prompts ADD COLUMN forked_from_id INTEGER;
+			ALTER TABLE prompts ADD COLUMN forked_from_version INTEGER;
+		`),
+		down: execMigration(`
+			ALTER TABLE prompts DROP COLUMN forked_from_version;
+			ALTER TABLE prompts DROP COLUMN forked_from_id;
+		`),
+	},
+	{
+		version: 4,
+		name:    "chat prompts",
+		up:      execMigration(`ALTER TABLE prompts ADD COLUMN format TEXT NOT NULL DEFAULT 'text'`),
+		down:    execMigration(`ALTER TABLE prompts DROP COLUMN format`),
+	},
+	{
+		version: 5,
+		name:    "version model config",
+		up:      execMigration(`ALTER TABLE prompt_versions ADD COLUMN model_config TEXT NOT NULL DEFAULT ''`),
+		down:    execMigration(`ALTER TABLE prompt_versions DROP COLUMN model_config`),
+	},
+	{
+		version: 6,
+		name:    "metadata",
+		up: execMigration(`
+			ALTER TABLE prompts ADD COLUMN metadata TEXT NOT NULL DEFAULT '';
+			ALTER TABLE prompt_versions ADD COLUMN metadata TEXT NOT NULL DEFAULT '';
+		`),
+		down: execMigration(`
+			ALTER TABLE prompts DROP COLUMN metadata;
+			ALTER TABLE prompt_versions DROP COLUMN metadata;
+		`),
+	},
+	{
+		version: 7,
+		name:    "version comments",
+		up:      execMigration(commentSchema),
+		down:    execMigration(`DROP TABLE version_comments`),
+	},
+	{
+		version: 8,
+		name:    "prompt stars",
+		up:      execMigration(starSchema),
+		down:    execMigration(`DROP TABLE prompt_stars`),
+	},
+	{
+		version: 9,
+		name:    "prompt usage",
+		up:      execMigration(usageSchema),
+		down:    execMigration(`DROP TABLE prompt_usage`),
+	},
+	{
+		version: 10,
+		name:    "version content hashes",
+		up:      migrateContentHashes,
+		down: execMigration(`
+			DROP TRIGGER prompt_versions_content_hash_immutable;
+			ALTER TABLE prompt_versions DROP COLUMN content_sha256;
+		`),
+	},
+	{
+		version: 11,
+		name:    "collections",
+		up:      execMigration(collectionSchema),
+		down: execMigration(`
+			DROP TABLE collection_prompts;
+			DROP TABLE collections;
+		`),
+	},
+	{
+		version: 12,
+		name:    "prompt links",
+		up:      execMigration(linkSchema),
+		down:    execMigration(`DROP TABLE prompt_links`),
+	},
+	{
+		version: 13,
+		name:    "retention policies",
+		up: execMigration(`
+			ALTER TABLE prompts ADD COLUMN retention_keep_versions INTEGER;
+			ALTER TABLE prompts ADD COLUMN retention_keep_days INTEGER;
+		`),
+		down: execMigration(`
+			ALTER TABLE prompts DROP COLUMN retention_keep_days;
+			ALTER TABLE prompts DROP COLUMN retention_keep_versions;
+		`),
+	},
+	{
+		version: 14,
+		name:    "admin audit log",
+		up:      execMigration(adminAuditSchema),
+		down:    execMigration(`DROP TABLE admin_audit_log`),
+	},
+	{
+		version: 15,
+		name:    "prompt embeddings",
+		up:      execMigration(embeddingSchema),
+		down:    execMigration(`DROP TABLE prompt_embeddings`),
+	},
+	{
+		version: 16,
+		name:    "environments",
+		up:      execMigration(environmentSchema),
+		down:    execMigration(`DROP TABLE environments`),
+	},
+	{
+		version: 17,
+		name:    "version reviews",
+		up: execMigration(`
+			ALTER TABLE prompts ADD COLUMN review_approvals INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE prompts ADD COLUMN review_reviewers TEXT NOT NULL DEFAULT '';
+			ALTER TABLE prompt_versions ADD COLUMN review_status TEXT NOT NULL DEFAULT '';
+		` + reviewSchema),
+		down: execMigration(`
+			DROP TABLE version_approvals;
+			ALTER TABLE prompt_versions DROP COLUMN review_status;
+			ALTER TABLE prompts DROP COLUMN review_reviewers;
+			ALTER TABLE prompts DROP COLUMN review_approvals;
+		`),
+	},
+}
+
+// execMigration returns a migration step that runs stmts
+func execMigration(stmts string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(stmts)
+		return err
+	}
+}
+
+// LatestSchemaVersion returns the schema version this build brings databases to
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate applies or reverts migrations, one transaction each, until the
+// database is at version target. It returns the version the database was at.
+func migrate(db *sql.DB, logger *slog.Logger, target int) (int, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, schemaMigrationsSchema); err != nil {
+		logger.Error("failed to initialize schema", "error", err)
+		return 0, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+	var from int
+	if err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&from); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	latest := LatestSchemaVersion()
+	if from > latest {
+		return from, fmt.Errorf("database schema version %d is newer than this build supports (%d)", from, latest)
+	}
+	if target < 0 || target > latest {
+		return from, invalid("invalid schema version %d: want 0 to %d", target, latest)
+	}
+	if from == target {
+		return from, nil
+	}
+
+	// foreign_keys can't change inside a transaction
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return from, fmt.Errorf("failed to disable foreign keys: %w", err)
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+
+	for current := from; current != target; {
+		if current < target {
+			if err := runMigration(ctx, conn, logger, migrations[current], true); err != nil {
+				return from, err
+			}
+			current++
+		} else {
+			if err := runMigration(ctx, conn, logger, migrations[current-1], false); err != nil {
+				return from, err
+			}
+			current--
+		}
+	}
+	return from, nil
+}
+
+// runMigration applies m, or reverts it when up is false, and records the
+// result in schema_migrations in the same transaction
+func runMigration(ctx context.Context, conn *sql.Conn, logger *slog.Logger, m migration, up bool) error {
+	start := time.Now()
+	step, verb := m.up, "apply"
+	if !up {
+		step, verb = m.down, "revert"
+	}
+	if step == nil {
+		return invalid("migration %d (%s) can't be reverted", m.version, m.name)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := step(tx); err != nil {
+		logger.Error("schema migration failed", "error", err, "version", m.version, "name", m.name, "direction", verb)
+		return fmt.Errorf("failed to %s migration %d (%s): %w", verb, m.version, m.name, err)
+	}
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name)
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+	}
+	// Rows orphaned before foreign keys were enforced are reported, not fatal
+	var violations int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_foreign_key_check`).Scan(&violations); err != nil {
+		return fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	if violations > 0 {
+		logger.Warn("database has rows referencing missing parents", "count", violations, "version", m.version)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to %s migration %d (%s): %w", verb, m.version, m.name, err)
+	}
+
+	logger.Info("schema migration", "version", m.version, "name", m.name, "direction", verb,
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// MigrationStatus lists the migrations this build knows, plus any newer ones
+// recorded in the database at dbPath, with when each was applied. It doesn't
+// change the database.
+func MigrationStatus(dbPath string, opts Options) ([]models.SchemaMigration, error) {
+	db, err := openExisting(dbPath, opts, opts.logger())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	applied := make(map[int]models.SchemaMigration)
+	var hasTable bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`).Scan(&hasTable); err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if hasTable {
+		rows, err := db.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema version: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var m models.SchemaMigration
+			var appliedAt time.Time
+			if err := rows.Scan(&m.Version, &m.Name, &appliedAt); err != nil {
+				return nil, fmt.Errorf("failed to read schema version: %w", err)
+			}
+			m.AppliedAt = &appliedAt
+			applied[m.Version] = m
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read schema version: %w", err)
+		}
+	}
+
+	var results []models.SchemaMigration
+	for _, m := range migrations {
+		result := models.SchemaMigration{Version: m.version, Name: m.name, Reversible: m.down != nil}
+		if a, ok := applied[m.version]; ok {
+			result.AppliedAt = a.AppliedAt
+		}
+		results = append(results, result)
+	}
+	for version := LatestSchemaVersion() + 1; ; version++ {
+		a, ok := applied[version]
+		if !ok {
+			break
+		}
+		results = append(results, a)
+	}
+	return results, nil
+}
+
+// MigrateFile applies or reverts migrations until the database at dbPath is
+// at schema version target; 0 reverts every migration. Nothing may have the
+// database open; the server uses this for its --migrate mode.
+func MigrateFile(dbPath string, opts Options, target int) (models.MigrateResult, error) {
+	start := time.Now()
+	var result models.MigrateResult
+	logger := opts.logger()
+
+	db, err := openExisting(dbPath, opts, logger)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	from, err := migrate(db, logger, target)
+	if err != nil {
+		return result, err
+	}
+
+	duration := time.Since(start)
+	result = models.MigrateResult{
+		Path:        dbPath,
+		FromVersion: from,
+		ToVersion:   target,
+		DurationMs:  duration.Milliseconds(),
+	}
+	logger.Info("database operation",
+		"operation", "MigrateFile",
+		"path", dbPath,
+		"from_version", from,
+		"to_version", target,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// openExisting opens the database at dbPath without migrating it. Opening a
+// missing file would create an empty one, so that's an error.
+func openExisting(dbPath string, opts Options, logger *slog.Logger) (*sql.DB, error) {
+	if cleanPath := strings.TrimPrefix(dbPath, "sqlite3://"); cleanPath != ":memory:" && !strings.HasPrefix(cleanPath, "file:") {
+		if _, err := os.Stat(cleanPath); err != nil {
+			return nil, notFound("database file %q not found", dbPath)
+		}
+	}
+	return openConnection(dbPath, opts, logger)
+}
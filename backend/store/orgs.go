@@ -0,0 +1,531 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// Org member roles. Owners manage members and the org's projects; members
+// can see and change the org's prompts.
+const (
+	OrgOwner  = "owner"
+	OrgMember = "member"
+)
+
+// orgSchema holds organizations, their members, and the projects they own.
+// Members are authenticated subjects. Projects not listed in org_projects are
+// open to every caller.
+const orgSchema = `
+	CREATE TABLE IF NOT EXISTS orgs (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		name       TEXT UNIQUE NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS org_members (
+		org_id     INTEGER NOT NULL,
+		subject    TEXT NOT NULL,
+		role       TEXT NOT NULL,
+		invited_by TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(org_id) REFERENCES orgs(id),
+		PRIMARY KEY(org_id, subject)
+	);
+
+	CREATE TABLE IF NOT EXISTS org_projects (
+		project    TEXT PRIMARY KEY,
+		org_id     INTEGER NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(org_id) REFERENCES orgs(id)
+	);
+`
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// ValidateOrg checks an org name with the same rules as project names
+func ValidateOrg(name string) error {
+	if err := ValidateProject(name); err != nil {
+		return invalidField("name", "invalid org name %q: use 1-63 lowercase letters, digits, and hyphens", name)
+	}
+	return nil
+}
+
+// CreateOrg creates an organization with the caller as its first owner
+func (s *SQLiteStore) CreateOrg(input models.CreateOrgInput) (models.Org, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	result := models.Org{Name: input.Name, CreatedBy: s.actor, Projects: []string{}}
+
+	if err := ValidateOrg(input.Name); err != nil {
+		return result, err
+	}
+	if s.actor == "" {
+		return result, denied("permission denied: creating an org requires an authenticated caller")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var orgID int64
+	err = tx.QueryRow(
+		`INSERT INTO orgs (name, created_by) VALUES (?, ?) RETURNING id, created_at`,
+		input.Name, s.actor,
+	).Scan(&orgID, &result.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint") {
+			return result, conflict("org %q already exists", input.Name)
+		}
+		s.logger.Error("failed to insert org", "error", err, "org", input.Name)
+		return result, fmt.Errorf("failed to insert org: %w", err)
+	}
+	owner := models.OrgMember{Subject: s.actor, Role: OrgOwner}
+	err = tx.QueryRow(
+		`INSERT INTO org_members (org_id, subject, role) VALUES (?, ?, ?) RETURNING created_at`,
+		orgID, owner.Subject, owner.Role,
+	).Scan(&owner.CreatedAt)
+	if err != nil {
+		s.logger.Error("failed to insert org member", "error", err, "org", input.Name)
+		return result, fmt.Errorf("failed to insert org member: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	result.Members = []models.OrgMember{owner}
+
+	duration := time.Since(start)
+	s.observe("CreateOrg", duration)
+	s.logger.Info("database operation",
+		"operation", "CreateOrg",
+		"org", input.Name,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// ListOrgs returns the orgs the caller belongs to, by name, without members
+func (s *SQLiteStore) ListOrgs() ([]models.Org, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	rows, err := s.db.Query(`
+		SELECT o.id, o.name, o.created_by, o.created_at
+		FROM orgs o
+		JOIN org_members m ON m.org_id = o.id AND m.subject = ?
+		ORDER BY o.name
+	`, s.actor)
+	if err != nil {
+		s.logger.Error("failed to list orgs", "error", err)
+		return nil, fmt.Errorf("failed to list orgs: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.Org{}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var org models.Org
+		if err := rows.Scan(&id, &org.Name, &org.CreatedBy, &org.CreatedAt); err != nil {
+			s.logger.Error("failed to scan org", "error", err)
+			return nil, fmt.Errorf("failed to scan org: %w", err)
+		}
+		ids = append(ids, id)
+		results = append(results, org)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate orgs", "error", err)
+		return nil, fmt.Errorf("failed to iterate orgs: %w", err)
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		if results[i].Projects, err = s.orgProjects(id); err != nil {
+			return nil, err
+		}
+	}
+
+	duration := time.Since(start)
+	s.observe("ListOrgs", duration)
+	s.logger.Info("database operation",
+		"operation", "ListOrgs",
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// GetOrg returns an org with its members and projects. Orgs the caller
+// doesn't belong to are reported as not found.
+func (s *SQLiteStore) GetOrg(name string) (models.Org, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	result := models.Org{Name: name}
+	orgID, _, err := s.orgRole(s.db, name)
+	if err != nil {
+		return result, err
+	}
+	err = s.db.QueryRow(`SELECT created_by, created_at FROM orgs WHERE id = ?`, orgID).Scan(&result.CreatedBy, &result.CreatedAt)
+	if err != nil {
+		s.logger.Error("failed to get org", "error", err, "org", name)
+		return result, fmt.Errorf("failed to get org: %w", err)
+	}
+	if result.Projects, err = s.orgProjects(orgID); err != nil {
+		return result, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT subject, role, invited_by, created_at
+		FROM org_members
+		WHERE org_id = ?
+		ORDER BY role != ?, subject
+	`, orgID, OrgOwner)
+	if err != nil {
+		s.logger.Error("failed to list org members", "error", err, "org", name)
+		return result, fmt.Errorf("failed to list org members: %w", err)
+	}
+	defer rows.Close()
+	result.Members = []models.OrgMember{}
+	for rows.Next() {
+		var member models.OrgMember
+		if err := rows.Scan(&member.Subject, &member.Role, &member.InvitedBy, &member.CreatedAt); err != nil {
+			s.logger.Error("failed to scan org member", "error", err)
+			return result, fmt.Errorf("failed to scan org member: %w", err)
+		}
+		result.Members = append(result.Members, member)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate org members", "error", err)
+		return result, fmt.Errorf("failed to iterate org members: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("GetOrg", duration)
+	s.logger.Info("database operation",
+		"operation", "GetOrg",
+		"org", name,
+		"members", len(result.Members),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// AddOrgMember invites a subject into an org. Only owners can invite.
+func (s *SQLiteStore) AddOrgMember(org string, input models.AddOrgMemberInput) (models.OrgMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	result := models.OrgMember{Subject: strings.TrimSpace(input.Subject), Role: input.Role, InvitedBy: s.actor}
+	if result.Subject == "" {
+		return result, invalidField("subject", "subject cannot be empty")
+	}
+	if result.Role == "" {
+		result.Role = OrgMember
+	}
+	if result.Role != OrgOwner && result.Role != OrgMember {
+		return result, invalidField("role", "invalid role %q: must be %q or %q", result.Role, OrgOwner, OrgMember)
+	}
+
+	orgID, role, err := s.orgRole(s.db, org)
+	if err != nil {
+		return result, err
+	}
+	if role != OrgOwner {
+		return result, denied("permission denied: only owners can manage members of org %q", org)
+	}
+
+	err = s.db.QueryRow(`
+		INSERT INTO org_members (org_id, subject, role, invited_by) VALUES (?, ?, ?, ?)
+		RETURNING created_at
+	`, orgID, result.Subject, result.Role, s.actor).Scan(&result.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint") {
+			return result, conflict("%q is already a member of org %q", result.Subject, org)
+		}
+		s.logger.Error("failed to insert org member", "error", err, "org", org)
+		return result, fmt.Errorf("failed to insert org member: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("AddOrgMember", duration)
+	s.logger.Info("database operation",
+		"operation", "AddOrgMember",
+		"org", org,
+		"subject", result.Subject,
+		"role", result.Role,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// RemoveOrgMember removes a subject from an org. Owners can remove anyone and
+// members can remove themselves, but an org always keeps at least one owner.
+func (s *SQLiteStore) RemoveOrgMember(org, subject string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	orgID, role, err := s.orgRole(tx, org)
+	if err != nil {
+		return err
+	}
+	if role != OrgOwner && subject != s.actor {
+		return denied("permission denied: only owners can manage members of org %q", org)
+	}
+
+	var removed string
+	err = tx.QueryRow(
+		`DELETE FROM org_members WHERE org_id = ? AND subject = ? RETURNING role`, orgID, subject,
+	).Scan(&removed)
+	if err == sql.ErrNoRows {
+		return notFound("member %q not found in org %q", subject, org)
+	}
+	if err != nil {
+		s.logger.Error("failed to delete org member", "error", err, "org", org)
+		return fmt.Errorf("failed to delete org member: %w", err)
+	}
+	if removed == OrgOwner {
+		var owners int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM org_members WHERE org_id = ? AND role = ?`, orgID, OrgOwner).Scan(&owners)
+		if err != nil {
+			s.logger.Error("failed to count org owners", "error", err, "org", org)
+			return fmt.Errorf("failed to count org owners: %w", err)
+		}
+		if owners == 0 {
+			return conflict("cannot remove the last owner of org %q", org)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("RemoveOrgMember", duration)
+	s.logger.Info("database operation",
+		"operation", "RemoveOrgMember",
+		"org", org,
+		"subject", subject,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// AssignProject moves a project into an org, hiding it from non-members. The
+// caller must own the org, and also the project's current org when
+// transferring it. The default project stays open to everyone.
+func (s *SQLiteStore) AssignProject(org, project string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	if project == DefaultProject {
+		return invalid("invalid project: the default project cannot belong to an org")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	orgID, role, err := s.orgRole(tx, org)
+	if err != nil {
+		return err
+	}
+	if role != OrgOwner {
+		return denied("permission denied: only owners can manage projects of org %q", org)
+	}
+	if err := s.checkProjectOwner(tx, project); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO org_projects (project, org_id) VALUES (?, ?)
+		ON CONFLICT(project) DO UPDATE SET org_id = excluded.org_id, created_at = CURRENT_TIMESTAMP
+	`, project, orgID); err != nil {
+		s.logger.Error("failed to assign project", "error", err, "org", org, "project", project)
+		return fmt.Errorf("failed to assign project: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("AssignProject", duration)
+	s.logger.Info("database operation",
+		"operation", "AssignProject",
+		"org", org,
+		"project", project,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// ReleaseProject removes a project from an org, opening it to every caller.
+// Only the org's owners can release it.
+func (s *SQLiteStore) ReleaseProject(org, project string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	orgID, role, err := s.orgRole(s.db, org)
+	if err != nil {
+		return err
+	}
+	if role != OrgOwner {
+		return denied("permission denied: only owners can manage projects of org %q", org)
+	}
+
+	result, err := s.db.Exec(`DELETE FROM org_projects WHERE project = ? AND org_id = ?`, project, orgID)
+	if err != nil {
+		s.logger.Error("failed to release project", "error", err, "org", org, "project", project)
+		return fmt.Errorf("failed to release project: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		s.logger.Error("failed to get affected rows", "error", err)
+		return fmt.Errorf("failed to release project: %w", err)
+	}
+	if affected == 0 {
+		return notFound("project %q not found in org %q", project, org)
+	}
+
+	duration := time.Since(start)
+	s.observe("ReleaseProject", duration)
+	s.logger.Info("database operation",
+		"operation", "ReleaseProject",
+		"org", org,
+		"project", project,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// AuthorizeProject checks that the caller can see the store's project: it is
+// open to everyone or owned by an org the caller belongs to. Hidden projects
+// are reported as not found.
+func (s *SQLiteStore) AuthorizeProject() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var visible bool
+	err := s.db.QueryRow(`
+		SELECT NOT EXISTS (SELECT 1 FROM org_projects WHERE project = ?)
+			OR EXISTS (
+				SELECT 1 FROM org_projects op
+				JOIN org_members m ON m.org_id = op.org_id
+				WHERE op.project = ? AND m.subject = ?
+			)
+	`, s.project, s.project, s.actor).Scan(&visible)
+	if err != nil {
+		s.logger.Error("failed to check project access", "error", err, "project", s.project)
+		return fmt.Errorf("failed to check project access: %w", err)
+	}
+	if !visible {
+		return notFound("project %q not found", s.project)
+	}
+	return nil
+}
+
+// orgRole returns an org's id and the caller's role in it. Orgs the caller
+// doesn't belong to are reported as not found.
+func (s *SQLiteStore) orgRole(q queryRower, org string) (int64, string, error) {
+	var id int64
+	var role string
+	err := q.QueryRow(`
+		SELECT o.id, m.role
+		FROM orgs o
+		JOIN org_members m ON m.org_id = o.id AND m.subject = ?
+		WHERE o.name = ?
+	`, s.actor, org).Scan(&id, &role)
+	if err == sql.ErrNoRows {
+		return 0, "", notFound("org %q not found", org)
+	}
+	if err != nil {
+		s.logger.Error("failed to get org", "error", err, "org", org)
+		return 0, "", fmt.Errorf("failed to get org: %w", err)
+	}
+	return id, role, nil
+}
+
+// checkProjectOwner requires the caller to own the project's current org, if
+// it has one. Projects in an org the caller can't see are reported as not found.
+func (s *SQLiteStore) checkProjectOwner(q queryRower, project string) error {
+	var current string
+	err := q.QueryRow(`
+		SELECT o.name FROM org_projects op JOIN orgs o ON o.id = op.org_id WHERE op.project = ?
+	`, project).Scan(&current)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		s.logger.Error("failed to get project org", "error", err, "project", project)
+		return fmt.Errorf("failed to get project org: %w", err)
+	}
+	_, role, err := s.orgRole(q, current)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return notFound("project %q not found", project)
+		}
+		return err
+	}
+	if role != OrgOwner {
+		return denied("permission denied: only owners of org %q can move project %q", current, project)
+	}
+	return nil
+}
+
+// orgProjects returns the names of the projects an org owns
+func (s *SQLiteStore) orgProjects(orgID int64) ([]string, error) {
+	rows, err := s.db.Query(`SELECT project FROM org_projects WHERE org_id = ? ORDER BY project`, orgID)
+	if err != nil {
+		s.logger.Error("failed to list org projects", "error", err, "org_id", orgID)
+		return nil, fmt.Errorf("failed to list org projects: %w", err)
+	}
+	defer rows.Close()
+
+	projects := []string{}
+	for rows.Next() {
+		var project string
+		if err := rows.Scan(&project); err != nil {
+			s.logger.Error("failed to scan org project", "error", err)
+			return nil, fmt.Errorf("failed to scan org project: %w", err)
+		}
+		projects = append(projects, project)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate org projects", "error", err)
+		return nil, fmt.Errorf("failed to iterate org projects: %w", err)
+	}
+	return projects, nil
+}
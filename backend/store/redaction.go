@@ -0,0 +1,101 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// redactionNotice is the content a redacted version is left with
+func redactionNotice(reason string, at time.Time) string {
+	return fmt.Sprintf("[Redacted on %s: %s]", at.UTC().Format("2006-01-02"), reason)
+}
+
+// RedactPromptVersion replaces a version's content with a redaction notice,
+// the compliant alternative to deleting history: the version number stays,
+// the SHA-256 of the original content is kept, and the audit log records who
+// redacted it and why. Versions of prompts under legal hold can't be redacted.
+func (s *SQLiteStore) RedactPromptVersion(slug string, version int, reason string) (models.PromptVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result models.PromptVersion
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return result, invalidField("reason", "redaction reason cannot be empty")
+	}
+
+	start := time.Now()
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var legalHoldAt *time.Time
+	err = tx.QueryRow(`
+		SELECT `+versionColumns+`, p.legal_hold_at
+		FROM prompt_versions pv
+		JOIN prompts p ON p.id = pv.prompt_id
+		WHERE p.project = ? AND p.slug = ? AND pv.version_number = ?
+	`, s.project, slug, version).Scan(append(versionFields(&result), &legalHoldAt)...)
+	if err == sql.ErrNoRows {
+		return result, versionNotFound(slug, version)
+	}
+	if err != nil {
+		s.logger.Error("failed to get version", "error", err, "slug", slug, "version", version)
+		return result, fmt.Errorf("failed to get version: %w", err)
+	}
+	if legalHoldAt != nil {
+		return result, conflict("prompt %q is under legal hold and can't be redacted", slug)
+	}
+	if result.RedactedAt != nil {
+		return result, conflict("version %d of prompt %q is already redacted", version, slug)
+	}
+
+	originalSHA256 := contentSHA256(result.Content)
+	if err := rewriteVersionContent(tx, result.ID, redactionNotice(reason, start)); err != nil {
+		s.logger.Error("failed to redact version", "error", err, "slug", slug, "version", version)
+		return result, err
+	}
+	if _, err := tx.Exec(
+		`UPDATE prompt_versions SET redacted_at = CURRENT_TIMESTAMP, original_sha256 = ? WHERE id = ?`,
+		originalSHA256, result.ID,
+	); err != nil {
+		s.logger.Error("failed to redact version", "error", err, "slug", slug, "version", version)
+		return result, fmt.Errorf("failed to redact version: %w", err)
+	}
+	if _, err := tx.Exec(
+		`UPDATE prompts SET updated_at = CURRENT_TIMESTAMP, updated_by = ? WHERE id = ?`,
+		s.actor, result.PromptID,
+	); err != nil {
+		s.logger.Error("failed to update prompt", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to update prompt: %w", err)
+	}
+	if err := s.audit(tx, result.PromptID, auditVersionRedacted, version, reason); err != nil {
+		return result, err
+	}
+	if err := tx.QueryRow(
+		`SELECT `+versionColumns+` FROM prompt_versions pv WHERE pv.id = ?`, result.ID,
+	).Scan(versionFields(&result)...); err != nil {
+		return result, fmt.Errorf("failed to get version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("RedactPromptVersion", duration)
+	s.logger.Info("database operation",
+		"operation", "RedactPromptVersion",
+		"slug", slug,
+		"version", version,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
@@ -0,0 +1,574 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// MaxReleaseItems bounds how many prompts one release promotes
+const MaxReleaseItems = 500
+
+// releaseSchema records releases and the labels they set. Each item keeps
+// the label's previous version and release so a rollback can put it back.
+const releaseSchema = `
+	CREATE TABLE IF NOT EXISTS releases (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		project        TEXT NOT NULL,
+		label          TEXT NOT NULL,
+		note           TEXT NOT NULL DEFAULT '',
+		created_by     TEXT NOT NULL DEFAULT '',
+		created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		rolled_back_at DATETIME,
+		rolled_back_by TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_releases_project ON releases(project, id);
+
+	CREATE TABLE IF NOT EXISTS release_items (
+		release_id          INTEGER NOT NULL,
+		prompt_id           INTEGER NOT NULL,
+		version_number      INTEGER NOT NULL,
+		previous_version    INTEGER,
+		previous_release_id INTEGER,
+		FOREIGN KEY(release_id) REFERENCES releases(id),
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id),
+		PRIMARY KEY(release_id, prompt_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS prompt_labels (
+		prompt_id      INTEGER NOT NULL,
+		label          TEXT NOT NULL,
+		version_number INTEGER NOT NULL,
+		release_id     INTEGER NOT NULL,
+		updated_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id),
+		FOREIGN KEY(release_id) REFERENCES releases(id),
+		PRIMARY KEY(prompt_id, label)
+	);
+`
+
+// ValidateLabel checks that a label is 1-63 lowercase letters, digits, and hyphens
+func ValidateLabel(label string) error {
+	if err := ValidateProject(label); err != nil {
+		return invalid("invalid label %q: use 1-63 lowercase letters, digits, and hyphens", label)
+	}
+	return nil
+}
+
+// CreateRelease points a label at a set of prompt versions in one
+// transaction: either every label moves or none does. The caller needs write
+// access to every prompt, and redacted or pending versions and archived
+// prompts can't be released. Environment labels can't be released directly;
+// they move through Promote.
+func (s *SQLiteStore) CreateRelease(input models.CreateReleaseInput) (models.Release, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return models.Release{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Environment labels only move through Promote, which keeps versions
+	// going through the pipeline in order
+	if _, ok, err := s.environmentNamed(tx, input.Label); err != nil {
+		return models.Release{}, err
+	} else if ok {
+		return models.Release{}, conflict("label %q is an environment; promote versions into it instead", input.Label)
+	}
+
+	result, err := s.createRelease(tx, input)
+	if err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("CreateRelease", duration)
+	s.logger.Info("database operation",
+		"operation", "CreateRelease",
+		"release_id", result.ID,
+		"label", input.Label,
+		"prompts", len(result.Items),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// createRelease validates and records a release within tx, moving its label
+// on every item
+func (s *SQLiteStore) createRelease(tx *sql.Tx, input models.CreateReleaseInput) (models.Release, error) {
+	result := models.Release{Label: input.Label, Note: input.Note, CreatedBy: s.actor, Items: []models.ReleaseItem{}}
+	if err := ValidateLabel(input.Label); err != nil {
+		return result, err
+	}
+	if len(input.Items) == 0 {
+		return result, invalidField("items", "release items cannot be empty")
+	}
+	if len(input.Items) > MaxReleaseItems {
+		return result, invalidField("items", "invalid release: at most %d prompts can be released together", MaxReleaseItems)
+	}
+	seen := make(map[string]bool)
+	for _, item := range input.Items {
+		if seen[item.Slug] {
+			return result, invalidField("items", "invalid release: prompt %q is listed more than once", item.Slug)
+		}
+		seen[item.Slug] = true
+	}
+
+	err := tx.QueryRow(
+		`INSERT INTO releases (project, label, note, created_by) VALUES (?, ?, ?, ?) RETURNING id, created_at`,
+		s.project, input.Label, input.Note, s.actor,
+	).Scan(&result.ID, &result.CreatedAt)
+	if err != nil {
+		s.logger.Error("failed to insert release", "error", err, "label", input.Label)
+		return result, fmt.Errorf("failed to insert release: %w", err)
+	}
+
+	for _, item := range input.Items {
+		a, err := s.access(tx, item.Slug)
+		if err != nil {
+			return result, err
+		}
+		if !a.allows(s.actor, AccessWrite) {
+			if a.allows(s.actor, AccessRead) {
+				return result, denied("permission denied: no write access to prompt %q", item.Slug)
+			}
+			return result, promptNotFound(item.Slug)
+		}
+
+		var archivedAt, redactedAt *time.Time
+		var reviewStatus string
+		err = tx.QueryRow(`
+			SELECT p.archived_at, pv.redacted_at, pv.review_status
+			FROM prompts p
+			JOIN prompt_versions pv ON pv.prompt_id = p.id
+			WHERE p.id = ? AND pv.version_number = ?
+		`, a.id, item.Version).Scan(&archivedAt, &redactedAt, &reviewStatus)
+		if err == sql.ErrNoRows {
+			return result, versionNotFound(item.Slug, item.Version)
+		}
+		if err != nil {
+			s.logger.Error("failed to get version", "error", err, "slug", item.Slug, "version", item.Version)
+			return result, fmt.Errorf("failed to get version: %w", err)
+		}
+		if archivedAt != nil {
+			return result, conflict("prompt %q is archived and can't be released", item.Slug)
+		}
+		if redactedAt != nil {
+			return result, conflict("version %d of prompt %q is redacted and can't be released", item.Version, item.Slug)
+		}
+		if reviewStatus == ReviewPending {
+			return result, conflict("version %d of prompt %q is pending review; only approved versions can be released", item.Version, item.Slug)
+		}
+
+		var previousVersion, previousRelease sql.NullInt64
+		err = tx.QueryRow(
+			`SELECT version_number, release_id FROM prompt_labels WHERE prompt_id = ? AND label = ?`,
+			a.id, input.Label,
+		).Scan(&previousVersion, &previousRelease)
+		if err != nil && err != sql.ErrNoRows {
+			s.logger.Error("failed to get label", "error", err, "slug", item.Slug, "label", input.Label)
+			return result, fmt.Errorf("failed to get label: %w", err)
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO release_items (release_id, prompt_id, version_number, previous_version, previous_release_id)
+			VALUES (?, ?, ?, ?, ?)
+		`, result.ID, a.id, item.Version, previousVersion, previousRelease); err != nil {
+			s.logger.Error("failed to insert release item", "error", err, "slug", item.Slug)
+			return result, fmt.Errorf("failed to insert release item: %w", err)
+		}
+		if err := setLabel(tx, a.id, input.Label, item.Version, result.ID); err != nil {
+			s.logger.Error("failed to set label", "error", err, "slug", item.Slug, "label", input.Label)
+			return result, err
+		}
+		if err := s.audit(tx, a.id, auditLabelPromoted, item.Version, fmt.Sprintf("label %s, release %d", input.Label, result.ID)); err != nil {
+			return result, err
+		}
+		result.Items = append(result.Items, models.ReleaseItem{
+			Slug:            item.Slug,
+			Version:         item.Version,
+			PreviousVersion: int(previousVersion.Int64),
+		})
+	}
+	return result, nil
+}
+
+// setLabel points a prompt's label at a version, or removes the label when
+// version is 0
+func setLabel(tx *sql.Tx, promptID int64, label string, version int, releaseID int64) error {
+	var err error
+	if version == 0 {
+		_, err = tx.Exec(`DELETE FROM prompt_labels WHERE prompt_id = ? AND label = ?`, promptID, label)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO prompt_labels (prompt_id, label, version_number, release_id) VALUES (?, ?, ?, ?)
+			ON CONFLICT(prompt_id, label) DO UPDATE SET
+				version_number = excluded.version_number,
+				release_id = excluded.release_id,
+				updated_at = CURRENT_TIMESTAMP
+		`, promptID, label, version, releaseID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set label: %w", err)
+	}
+	return nil
+}
+
+// RollbackRelease puts back every label a release moved, in one transaction.
+// It fails without changing anything if a later release has since moved any
+// of those labels again; roll that one back first. Rolling back an
+// environment only restores versions that have been in the environment
+// before it, so versions still reach it only through the pipeline.
+func (s *SQLiteStore) RollbackRelease(id int64) (models.Release, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return models.Release{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := s.release(tx, id)
+	if err != nil {
+		return result, err
+	}
+	if result.RolledBackAt != nil {
+		return result, conflict("release %d is already rolled back", id)
+	}
+	env, isEnvironment, err := s.environmentNamed(tx, result.Label)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT ri.prompt_id, p.slug, COALESCE(ri.previous_version, 0), COALESCE(ri.previous_release_id, 0),
+			COALESCE(l.release_id, 0)
+		FROM release_items ri
+		JOIN prompts p ON p.id = ri.prompt_id
+		LEFT JOIN prompt_labels l ON l.prompt_id = ri.prompt_id AND l.label = ?
+		WHERE ri.release_id = ?
+	`, result.Label, id)
+	if err != nil {
+		s.logger.Error("failed to list release items", "error", err, "release_id", id)
+		return result, fmt.Errorf("failed to list release items: %w", err)
+	}
+	type restore struct {
+		promptID        int64
+		slug            string
+		previousVersion int
+		previousRelease int64
+		currentRelease  int64
+	}
+	var restores []restore
+	for rows.Next() {
+		var r restore
+		if err := rows.Scan(&r.promptID, &r.slug, &r.previousVersion, &r.previousRelease, &r.currentRelease); err != nil {
+			rows.Close()
+			return result, fmt.Errorf("failed to scan release item: %w", err)
+		}
+		restores = append(restores, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("failed to list release items: %w", err)
+	}
+
+	for _, r := range restores {
+		a, err := s.access(tx, r.slug)
+		if err != nil {
+			return result, err
+		}
+		if !a.allows(s.actor, AccessWrite) {
+			if a.allows(s.actor, AccessRead) {
+				return result, denied("permission denied: no write access to prompt %q", r.slug)
+			}
+			// Don't name prompts the caller can't see
+			return result, denied("permission denied: no write access to every prompt in release %d", id)
+		}
+		if r.currentRelease != id {
+			return result, conflict("label %q of prompt %q has changed since release %d", result.Label, r.slug, id)
+		}
+		if isEnvironment && env.Previous != "" && r.previousVersion > 0 {
+			ok, err := wasLabelled(tx, r.promptID, env.Previous, r.previousVersion)
+			if err != nil {
+				s.logger.Error("failed to check label history", "error", err, "slug", r.slug, "label", env.Previous)
+				return result, err
+			}
+			if !ok {
+				return result, conflict("version %d of prompt %q was never in %s, so it can't go back into %s",
+					r.previousVersion, r.slug, env.Previous, env.Name)
+			}
+		}
+		if err := setLabel(tx, r.promptID, result.Label, r.previousVersion, r.previousRelease); err != nil {
+			s.logger.Error("failed to restore label", "error", err, "slug", r.slug, "label", result.Label)
+			return result, err
+		}
+		if err := s.audit(tx, r.promptID, auditLabelRolledBack, r.previousVersion, fmt.Sprintf("label %s, release %d", result.Label, id)); err != nil {
+			return result, err
+		}
+	}
+	if _, err := tx.Exec(
+		`UPDATE releases SET rolled_back_at = CURRENT_TIMESTAMP, rolled_back_by = ? WHERE id = ?`,
+		s.actor, id,
+	); err != nil {
+		s.logger.Error("failed to roll back release", "error", err, "release_id", id)
+		return result, fmt.Errorf("failed to roll back release: %w", err)
+	}
+	if result, err = s.release(tx, id); err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("RollbackRelease", duration)
+	s.logger.Info("database operation",
+		"operation", "RollbackRelease",
+		"release_id", id,
+		"label", result.Label,
+		"prompts", len(result.Items),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// GetRelease returns a release of the store's project with its items
+func (s *SQLiteStore) GetRelease(id int64) (models.Release, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return models.Release{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := s.release(tx, id)
+	if err != nil {
+		return result, err
+	}
+
+	duration := time.Since(start)
+	s.observe("GetRelease", duration)
+	s.logger.Info("database operation",
+		"operation", "GetRelease",
+		"release_id", id,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// release reads a release of the store's project and its items within tx
+func (s *SQLiteStore) release(tx *sql.Tx, id int64) (models.Release, error) {
+	var result models.Release
+	err := tx.QueryRow(`
+		SELECT id, label, note, created_by, created_at, rolled_back_at, rolled_back_by
+		FROM releases WHERE id = ? AND project = ?
+	`, id, s.project).Scan(&result.ID, &result.Label, &result.Note, &result.CreatedBy,
+		&result.CreatedAt, &result.RolledBackAt, &result.RolledBackBy)
+	if err == sql.ErrNoRows {
+		return result, notFound("release %d not found", id)
+	}
+	if err != nil {
+		s.logger.Error("failed to get release", "error", err, "release_id", id)
+		return result, fmt.Errorf("failed to get release: %w", err)
+	}
+
+	result.Items, err = s.releaseItems(tx, id)
+	return result, err
+}
+
+// releaseItems lists the prompt versions a release promoted, by slug,
+// leaving out prompts the caller can't read
+func (s *SQLiteStore) releaseItems(q querier, id int64) ([]models.ReleaseItem, error) {
+	rows, err := q.Query(`
+		SELECT p.slug, ri.version_number, COALESCE(ri.previous_version, 0)
+		FROM release_items ri
+		JOIN prompts p ON p.id = ri.prompt_id
+		WHERE ri.release_id = ? AND `+readableByCaller+`
+		ORDER BY p.slug
+	`, append([]any{id}, s.readableArgs()...)...)
+	if err != nil {
+		s.logger.Error("failed to list release items", "error", err, "release_id", id)
+		return nil, fmt.Errorf("failed to list release items: %w", err)
+	}
+	defer rows.Close()
+	items := []models.ReleaseItem{}
+	for rows.Next() {
+		var item models.ReleaseItem
+		if err := rows.Scan(&item.Slug, &item.Version, &item.PreviousVersion); err != nil {
+			return nil, fmt.Errorf("failed to scan release item: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list release items: %w", err)
+	}
+	return items, nil
+}
+
+// ReleaseFilter narrows a release listing. Zero fields don't filter.
+type ReleaseFilter struct {
+	Label string
+	// Slug keeps releases that promoted this prompt
+	Slug string
+}
+
+// ListReleases returns the project's release history newest first, each with
+// the versions it promoted
+func (s *SQLiteStore) ListReleases(filter ReleaseFilter, limit, offset int) ([]models.Release, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	query := `
+		SELECT id, label, note, created_by, created_at, rolled_back_at, rolled_back_by
+		FROM releases r WHERE project = ?`
+	args := []any{s.project}
+	if filter.Label != "" {
+		query += ` AND label = ?`
+		args = append(args, filter.Label)
+	}
+	if filter.Slug != "" {
+		query += ` AND EXISTS (
+			SELECT 1 FROM release_items ri JOIN prompts p ON p.id = ri.prompt_id
+			WHERE ri.release_id = r.id AND p.project = r.project AND p.slug = ? AND ` + readableByCaller + `)`
+		args = append(append(args, filter.Slug), s.readableArgs()...)
+	}
+	query += ` ORDER BY id DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		s.logger.Error("failed to list releases", "error", err)
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+	defer rows.Close()
+
+	releases := []models.Release{}
+	for rows.Next() {
+		var r models.Release
+		if err := rows.Scan(&r.ID, &r.Label, &r.Note, &r.CreatedBy, &r.CreatedAt, &r.RolledBackAt, &r.RolledBackBy); err != nil {
+			s.logger.Error("failed to scan release", "error", err)
+			return nil, fmt.Errorf("failed to scan release: %w", err)
+		}
+		releases = append(releases, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+	rows.Close()
+	for i := range releases {
+		if releases[i].Items, err = s.releaseItems(s.db, releases[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	duration := time.Since(start)
+	s.observe("ListReleases", duration)
+	s.logger.Info("database operation",
+		"operation", "ListReleases",
+		"label", filter.Label,
+		"slug", filter.Slug,
+		"rows_returned", len(releases),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return releases, nil
+}
+
+// ListPromptLabels returns the labels pointing at a prompt's versions, by name
+func (s *SQLiteStore) ListPromptLabels(slug string) ([]models.PromptLabel, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	promptID, err := s.promptID(slug)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`
+		SELECT label, version_number, release_id, updated_at
+		FROM prompt_labels WHERE prompt_id = ? ORDER BY label
+	`, promptID)
+	if err != nil {
+		s.logger.Error("failed to list labels", "error", err, "slug", slug)
+		return nil, fmt.Errorf("failed to list labels: %w", err)
+	}
+	defer rows.Close()
+
+	labels := []models.PromptLabel{}
+	for rows.Next() {
+		var l models.PromptLabel
+		if err := rows.Scan(&l.Label, &l.Version, &l.ReleaseID, &l.UpdatedAt); err != nil {
+			s.logger.Error("failed to scan label", "error", err)
+			return nil, fmt.Errorf("failed to scan label: %w", err)
+		}
+		labels = append(labels, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list labels: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("ListPromptLabels", duration)
+	s.logger.Info("database operation",
+		"operation", "ListPromptLabels",
+		"slug", slug,
+		"rows_returned", len(labels),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return labels, nil
+}
+
+// GetPromptLabel returns the version a label points at for a prompt
+func (s *SQLiteStore) GetPromptLabel(slug, label string) (models.PromptLabel, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	result := models.PromptLabel{Label: label}
+	err := s.db.QueryRow(`
+		SELECT l.version_number, l.release_id, l.updated_at
+		FROM prompt_labels l
+		JOIN prompts p ON p.id = l.prompt_id
+		WHERE p.project = ? AND p.slug = ? AND l.label = ?
+	`, s.project, slug, label).Scan(&result.Version, &result.ReleaseID, &result.UpdatedAt)
+	if err == sql.ErrNoRows {
+		if _, err := s.promptID(slug); err != nil {
+			return result, err
+		}
+		return result, notFound("label %q not found for prompt %q", label, slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to get label", "error", err, "slug", slug, "label", label)
+		return result, fmt.Errorf("failed to get label: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("GetPromptLabel", duration)
+	s.logger.Info("database operation",
+		"operation", "GetPromptLabel",
+		"slug", slug,
+		"label", label,
+		"version", result.Version,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
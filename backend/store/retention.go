@@ -0,0 +1,291 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// ValidateRetentionPolicy checks that a policy's rules aren't negative
+func ValidateRetentionPolicy(policy models.RetentionPolicy) error {
+	if policy.KeepVersions < 0 || policy.KeepDays < 0 {
+		return invalid("invalid retention policy: keep_versions and keep_days must be 0 or more")
+	}
+	return nil
+}
+
+// GetPromptRetention returns the retention policy set on a prompt, the
+// policy in effect once defaults applies, and the versions a pruning run
+// would delete now. Nothing is prunable while the prompt is under legal hold.
+func (s *SQLiteStore) GetPromptRetention(slug string, defaults models.RetentionPolicy) (models.PromptRetention, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.PromptRetention
+	var id int64
+	var keepVersions, keepDays *int
+	var held *time.Time
+	err := s.db.QueryRow(`
+		SELECT id, retention_keep_versions, retention_keep_days, legal_hold_at
+		FROM prompts WHERE project = ? AND slug = ?
+	`, s.project, slug).Scan(&id, &keepVersions, &keepDays, &held)
+	if err == sql.ErrNoRows {
+		return result, promptNotFound(slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to get retention policy", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to get retention policy: %w", err)
+	}
+
+	result.Effective = defaults
+	if keepVersions != nil && keepDays != nil {
+		result.Policy = &models.RetentionPolicy{KeepVersions: *keepVersions, KeepDays: *keepDays}
+		result.Effective = *result.Policy
+	}
+	result.Prunable = []int{}
+	if held == nil {
+		versions, err := s.prunableVersions(s.db, id, result.Effective, time.Now())
+		if err != nil {
+			return result, err
+		}
+		for _, v := range versions {
+			result.Prunable = append(result.Prunable, v.number)
+		}
+	}
+
+	duration := time.Since(start)
+	s.observe("GetPromptRetention", duration)
+	s.logger.Info("database operation",
+		"operation", "GetPromptRetention",
+		"slug", slug,
+		"prunable", len(result.Prunable),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// SetPromptRetention sets the retention policy for a prompt; nil clears it
+// so the prompt follows the default again
+func (s *SQLiteStore) SetPromptRetention(slug string, policy *models.RetentionPolicy) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var keepVersions, keepDays any
+	detail := "default"
+	if policy != nil {
+		if err := ValidateRetentionPolicy(*policy); err != nil {
+			return err
+		}
+		keepVersions, keepDays = policy.KeepVersions, policy.KeepDays
+		detail = fmt.Sprintf("keep_versions=%d keep_days=%d", policy.KeepVersions, policy.KeepDays)
+	}
+	err := s.updatePrompt(slug, auditRetentionChanged, detail, `
+		UPDATE prompts
+		SET retention_keep_versions = ?, retention_keep_days = ?, updated_at = CURRENT_TIMESTAMP, updated_by = ?
+		WHERE project = ? AND slug = ?
+		RETURNING id
+	`, keepVersions, keepDays, s.actor, s.project, slug)
+	if err != nil {
+		return err
+	}
+
+	duration := time.Since(start)
+	s.observe("SetPromptRetention", duration)
+	s.logger.Info("database operation",
+		"operation", "SetPromptRetention",
+		"slug", slug,
+		"policy", detail,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// PruneVersions deletes old versions of prompts in every project, applying
+// each prompt's own policy or else defaults. It never deletes a current,
+// pinned, labeled, or pending version, one recorded in a release, or one a
+// fork was made from, and skips prompts under legal hold. Comments and
+// approvals of a pruned version go with it. With dryRun nothing is deleted
+// and the result lists what would be. Each prompt is pruned in its own
+// transaction.
+func (s *SQLiteStore) PruneVersions(defaults models.RetentionPolicy, dryRun bool) (models.PruneResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	result := models.PruneResult{DryRun: dryRun, Prompts: []models.PrunedPrompt{}}
+	if err := ValidateRetentionPolicy(defaults); err != nil {
+		return result, err
+	}
+
+	type candidate struct {
+		id            int64
+		project, slug string
+		policy        models.RetentionPolicy
+	}
+	rows, err := s.db.Query(`
+		SELECT id, project, slug, retention_keep_versions, retention_keep_days
+		FROM prompts
+		WHERE legal_hold_at IS NULL
+		ORDER BY project, slug
+	`)
+	if err != nil {
+		s.logger.Error("failed to list prompts for pruning", "error", err)
+		return result, fmt.Errorf("failed to list prompts for pruning: %w", err)
+	}
+	var candidates []candidate
+	for rows.Next() {
+		c := candidate{policy: defaults}
+		var keepVersions, keepDays *int
+		if err := rows.Scan(&c.id, &c.project, &c.slug, &keepVersions, &keepDays); err != nil {
+			rows.Close()
+			s.logger.Error("failed to scan prompt for pruning", "error", err)
+			return result, fmt.Errorf("failed to scan prompt for pruning: %w", err)
+		}
+		if keepVersions != nil && keepDays != nil {
+			c.policy = models.RetentionPolicy{KeepVersions: *keepVersions, KeepDays: *keepDays}
+		}
+		if c.policy != (models.RetentionPolicy{}) {
+			candidates = append(candidates, c)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate prompts for pruning", "error", err)
+		return result, fmt.Errorf("failed to iterate prompts for pruning: %w", err)
+	}
+
+	now := time.Now()
+	for _, c := range candidates {
+		versions, err := s.pruneCandidate(c.id, c.policy, now, dryRun)
+		if err != nil {
+			return result, fmt.Errorf("failed to prune %s/%s: %w", c.project, c.slug, err)
+		}
+		if len(versions) == 0 {
+			continue
+		}
+		result.Prompts = append(result.Prompts, models.PrunedPrompt{Project: c.project, Slug: c.slug, Versions: versions})
+		result.Versions += len(versions)
+	}
+
+	duration := time.Since(start)
+	result.DurationMs = duration.Milliseconds()
+	s.observe("PruneVersions", duration)
+	s.logger.Info("database operation",
+		"operation", "PruneVersions",
+		"dry_run", dryRun,
+		"prompts", len(result.Prompts),
+		"versions", result.Versions,
+		"duration_ms", result.DurationMs,
+	)
+	return result, nil
+}
+
+// pruneCandidate prunes one prompt's versions in a transaction, returning
+// the version numbers pruned, oldest first
+func (s *SQLiteStore) pruneCandidate(promptID int64, policy models.RetentionPolicy, now time.Time, dryRun bool) ([]int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	versions, err := s.prunableVersions(tx, promptID, policy, now)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	numbers := make([]int, len(versions))
+	detail := make([]string, len(versions))
+	for i, v := range versions {
+		numbers[i] = v.number
+		detail[i] = strconv.Itoa(v.number)
+	}
+	if dryRun {
+		return numbers, nil
+	}
+
+	for _, v := range versions {
+		if _, err := tx.Exec(`DELETE FROM version_comments WHERE prompt_id = ? AND version_number = ?`, promptID, v.number); err != nil {
+			s.logger.Error("failed to delete version comments", "error", err, "prompt_id", promptID, "version", v.number)
+			return nil, fmt.Errorf("failed to delete version comments: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM version_approvals WHERE prompt_id = ? AND version_number = ?`, promptID, v.number); err != nil {
+			s.logger.Error("failed to delete version approvals", "error", err, "prompt_id", promptID, "version", v.number)
+			return nil, fmt.Errorf("failed to delete version approvals: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM prompt_versions WHERE id = ?`, v.id); err != nil {
+			s.logger.Error("failed to delete version", "error", err, "prompt_id", promptID, "version", v.number)
+			return nil, fmt.Errorf("failed to delete version: %w", err)
+		}
+	}
+	if err := s.audit(tx, promptID, auditVersionsPruned, 0, "versions "+strings.Join(detail, ",")); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return numbers, nil
+}
+
+// prunableVersion identifies a version a policy drops
+type prunableVersion struct {
+	id     int64
+	number int
+}
+
+// prunableVersions returns the versions of a prompt that policy drops as of
+// now, oldest first. Versions that something still refers to are kept
+// whatever their age, and none are returned while the prompt is under legal
+// hold: PruneVersions picks its candidates before pruning each one, so the
+// hold is checked again in the transaction that deletes.
+func (s *SQLiteStore) prunableVersions(q querier, promptID int64, policy models.RetentionPolicy, now time.Time) ([]prunableVersion, error) {
+	if policy == (models.RetentionPolicy{}) {
+		return nil, nil
+	}
+	cutoff := now.AddDate(0, 0, -policy.KeepDays)
+	rows, err := q.Query(`
+		SELECT pv.id, pv.version_number,
+			COALESCE(pv.original_created_at, pv.created_at) >= ?,
+			pv.version_number = p.current_version OR pv.pinned OR pv.review_status = 'pending'
+				OR EXISTS (SELECT 1 FROM prompt_labels l WHERE l.prompt_id = pv.prompt_id AND l.version_number = pv.version_number)
+				OR EXISTS (SELECT 1 FROM release_items ri WHERE ri.prompt_id = pv.prompt_id AND pv.version_number IN (ri.version_number, ri.previous_version))
+				OR EXISTS (SELECT 1 FROM prompts f WHERE f.forked_from_id = pv.prompt_id AND f.forked_from_version = pv.version_number)
+		FROM prompt_versions pv
+		JOIN prompts p ON p.id = pv.prompt_id
+		WHERE pv.prompt_id = ? AND p.legal_hold_at IS NULL
+		ORDER BY pv.version_number DESC
+	`, timestampValue(&cutoff), promptID)
+	if err != nil {
+		s.logger.Error("failed to list versions for pruning", "error", err, "prompt_id", promptID)
+		return nil, fmt.Errorf("failed to list versions for pruning: %w", err)
+	}
+	defer rows.Close()
+
+	var results []prunableVersion
+	for i := 0; rows.Next(); i++ {
+		var v prunableVersion
+		var recent, referenced bool
+		if err := rows.Scan(&v.id, &v.number, &recent, &referenced); err != nil {
+			s.logger.Error("failed to scan version for pruning", "error", err)
+			return nil, fmt.Errorf("failed to scan version for pruning: %w", err)
+		}
+		kept := referenced ||
+			(policy.KeepVersions > 0 && i < policy.KeepVersions) ||
+			(policy.KeepDays > 0 && recent)
+		if !kept {
+			results = append([]prunableVersion{v}, results...)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate versions for pruning", "error", err)
+		return nil, fmt.Errorf("failed to iterate versions for pruning: %w", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,322 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// Version review statuses
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+)
+
+// MaxReviewers bounds how many reviewers a prompt's review policy names
+const MaxReviewers = 50
+
+// reviewSchema records reviewers' approvals of versions. A prompt's review
+// policy is kept on the prompt, in review_approvals and review_reviewers.
+const reviewSchema = `
+	CREATE TABLE IF NOT EXISTS version_approvals (
+		prompt_id      INTEGER NOT NULL,
+		version_number INTEGER NOT NULL,
+		reviewer       TEXT NOT NULL,
+		comment        TEXT NOT NULL DEFAULT '',
+		created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id),
+		PRIMARY KEY(prompt_id, version_number, reviewer)
+	);
+`
+
+// GetReviewPolicy returns a prompt's review policy; RequiredApprovals is 0
+// when its versions need no review
+func (s *SQLiteStore) GetReviewPolicy(slug string) (models.ReviewPolicy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	result, _, err := s.reviewPolicy(s.db, slug)
+	if err != nil {
+		return result, err
+	}
+
+	duration := time.Since(start)
+	s.observe("GetReviewPolicy", duration)
+	s.logger.Info("database operation",
+		"operation", "GetReviewPolicy",
+		"slug", slug,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// SetReviewPolicy replaces a prompt's review policy. While a prompt has one,
+// new versions wait as pending until RequiredApprovals of the reviewers
+// approve them; 0 turns review off for versions created from then on.
+func (s *SQLiteStore) SetReviewPolicy(slug string, policy models.ReviewPolicy) (models.ReviewPolicy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	reviewers := []string{}
+	for _, reviewer := range policy.Reviewers {
+		reviewer = strings.TrimSpace(reviewer)
+		if reviewer == "" {
+			return models.ReviewPolicy{}, invalidField("reviewers", "reviewer cannot be empty")
+		}
+		if !slices.Contains(reviewers, reviewer) {
+			reviewers = append(reviewers, reviewer)
+		}
+	}
+	if len(reviewers) > MaxReviewers {
+		return models.ReviewPolicy{}, invalidField("reviewers", "too many reviewers: at most %d", MaxReviewers)
+	}
+	if policy.RequiredApprovals < 0 || policy.RequiredApprovals > len(reviewers) {
+		return models.ReviewPolicy{}, invalidField("required_approvals",
+			"invalid required_approvals %d: use 0 to turn review off, or up to the %d reviewers named", policy.RequiredApprovals, len(reviewers))
+	}
+	if policy.RequiredApprovals == 0 {
+		reviewers = []string{}
+	}
+	data, err := json.Marshal(reviewers)
+	if err != nil {
+		return models.ReviewPolicy{}, fmt.Errorf("failed to encode reviewers: %w", err)
+	}
+
+	detail := "review off"
+	if policy.RequiredApprovals > 0 {
+		detail = fmt.Sprintf("%d of %s", policy.RequiredApprovals, strings.Join(reviewers, ", "))
+	}
+	err = s.updatePrompt(slug, auditReviewPolicyChanged, detail, `
+		UPDATE prompts
+		SET review_approvals = ?, review_reviewers = ?, updated_at = CURRENT_TIMESTAMP, updated_by = ?
+		WHERE project = ? AND slug = ?
+		RETURNING id
+	`, policy.RequiredApprovals, string(data), s.actor, s.project, slug)
+	if err != nil {
+		return models.ReviewPolicy{}, err
+	}
+
+	duration := time.Since(start)
+	s.observe("SetReviewPolicy", duration)
+	s.logger.Info("database operation",
+		"operation", "SetReviewPolicy",
+		"slug", slug,
+		"required_approvals", policy.RequiredApprovals,
+		"reviewers", len(reviewers),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return models.ReviewPolicy{RequiredApprovals: policy.RequiredApprovals, Reviewers: reviewers}, nil
+}
+
+// GetVersionReview returns a version's review status and approvals
+func (s *SQLiteStore) GetVersionReview(slug string, version int) (models.VersionReview, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	policy, promptID, err := s.reviewPolicy(s.db, slug)
+	if err != nil {
+		return models.VersionReview{}, err
+	}
+	result, err := s.versionReview(s.db, promptID, slug, version, policy.RequiredApprovals)
+	if err != nil {
+		return result, err
+	}
+
+	duration := time.Since(start)
+	s.observe("GetVersionReview", duration)
+	s.logger.Info("database operation",
+		"operation", "GetVersionReview",
+		"slug", slug,
+		"version", version,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// ApproveVersion records the caller's approval of a pending version. The
+// caller must be one of the prompt's reviewers and not the version's author.
+// The approval that brings a version to the required count approves it and,
+// unless a newer version is already current, makes it current.
+func (s *SQLiteStore) ApproveVersion(slug string, version int, comment string) (models.VersionReview, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return models.VersionReview{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	a, err := s.access(tx, slug)
+	if err != nil {
+		return models.VersionReview{}, err
+	}
+	if !a.allows(s.actor, AccessRead) {
+		return models.VersionReview{}, promptNotFound(slug)
+	}
+	policy, _, err := s.reviewPolicy(tx, slug)
+	if err != nil {
+		return models.VersionReview{}, err
+	}
+
+	var status, author string
+	var currentVersion int
+	err = tx.QueryRow(`
+		SELECT pv.review_status, pv.created_by, p.current_version
+		FROM prompt_versions pv
+		JOIN prompts p ON p.id = pv.prompt_id
+		WHERE p.id = ? AND pv.version_number = ?
+	`, a.id, version).Scan(&status, &author, &currentVersion)
+	if err == sql.ErrNoRows {
+		return models.VersionReview{}, versionNotFound(slug, version)
+	}
+	if err != nil {
+		s.logger.Error("failed to get version", "error", err, "slug", slug, "version", version)
+		return models.VersionReview{}, fmt.Errorf("failed to get version: %w", err)
+	}
+	if status != ReviewPending {
+		return models.VersionReview{}, conflict("version %d of prompt %q isn't pending review", version, slug)
+	}
+	if policy.RequiredApprovals == 0 {
+		return models.VersionReview{}, conflict("prompt %q has no review policy; set one to approve its pending versions", slug)
+	}
+	if !slices.Contains(policy.Reviewers, s.actor) {
+		return models.VersionReview{}, denied("permission denied: %q is not a reviewer of prompt %q", s.actor, slug)
+	}
+	if author == s.actor {
+		return models.VersionReview{}, denied("permission denied: versions can't be approved by their author")
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO version_approvals (prompt_id, version_number, reviewer, comment) VALUES (?, ?, ?, ?)`,
+		a.id, version, s.actor, strings.TrimSpace(comment),
+	); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint") {
+			return models.VersionReview{}, conflict("version %d of prompt %q is already approved by %q", version, slug, s.actor)
+		}
+		s.logger.Error("failed to insert approval", "error", err, "slug", slug, "version", version)
+		return models.VersionReview{}, fmt.Errorf("failed to insert approval: %w", err)
+	}
+
+	result, err := s.versionReview(tx, a.id, slug, version, policy.RequiredApprovals)
+	if err != nil {
+		return result, err
+	}
+	detail := fmt.Sprintf("%d of %d approvals", len(result.Approvals), policy.RequiredApprovals)
+	if len(result.Approvals) >= policy.RequiredApprovals {
+		result.Status = ReviewApproved
+		if _, err := tx.Exec(
+			`UPDATE prompt_versions SET review_status = ? WHERE prompt_id = ? AND version_number = ?`,
+			ReviewApproved, a.id, version,
+		); err != nil {
+			s.logger.Error("failed to approve version", "error", err, "slug", slug, "version", version)
+			return result, fmt.Errorf("failed to approve version: %w", err)
+		}
+		// An older version approved after a newer one stays out of the way
+		if version > currentVersion {
+			if _, err := tx.Exec(
+				`UPDATE prompts SET current_version = ?, updated_at = CURRENT_TIMESTAMP, updated_by = ? WHERE id = ?`,
+				version, s.actor, a.id,
+			); err != nil {
+				s.logger.Error("failed to update prompt", "error", err, "slug", slug)
+				return result, fmt.Errorf("failed to update prompt: %w", err)
+			}
+			result.PreviousVersion = currentVersion
+			detail += ", now current"
+		}
+	}
+	if err := s.audit(tx, a.id, auditVersionApproved, version, detail); err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("ApproveVersion", duration)
+	s.logger.Info("database operation",
+		"operation", "ApproveVersion",
+		"slug", slug,
+		"version", version,
+		"status", result.Status,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// reviewPolicy reads a prompt's review policy and returns its primary key
+func (s *SQLiteStore) reviewPolicy(q queryRower, slug string) (models.ReviewPolicy, int64, error) {
+	var result models.ReviewPolicy
+	var promptID int64
+	var reviewers string
+	err := q.QueryRow(
+		`SELECT id, review_approvals, review_reviewers FROM prompts WHERE project = ? AND slug = ?`, s.project, slug,
+	).Scan(&promptID, &result.RequiredApprovals, &reviewers)
+	if err == sql.ErrNoRows {
+		return result, 0, promptNotFound(slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to get review policy", "error", err, "slug", slug)
+		return result, 0, fmt.Errorf("failed to get review policy: %w", err)
+	}
+	result.Reviewers = []string{}
+	if reviewers != "" {
+		if err := json.Unmarshal([]byte(reviewers), &result.Reviewers); err != nil {
+			return result, 0, fmt.Errorf("failed to decode reviewers: %w", err)
+		}
+	}
+	return result, promptID, nil
+}
+
+// versionReview reads a version's review status and approvals, oldest first
+func (s *SQLiteStore) versionReview(q interface {
+	querier
+	queryRower
+}, promptID int64, slug string, version, required int) (models.VersionReview, error) {
+	result := models.VersionReview{Version: version, RequiredApprovals: required, Approvals: []models.Approval{}}
+	err := q.QueryRow(
+		`SELECT review_status FROM prompt_versions WHERE prompt_id = ? AND version_number = ?`, promptID, version,
+	).Scan(&result.Status)
+	if err == sql.ErrNoRows {
+		return result, versionNotFound(slug, version)
+	}
+	if err != nil {
+		s.logger.Error("failed to get version", "error", err, "slug", slug, "version", version)
+		return result, fmt.Errorf("failed to get version: %w", err)
+	}
+
+	rows, err := q.Query(`
+		SELECT reviewer, comment, created_at FROM version_approvals
+		WHERE prompt_id = ? AND version_number = ?
+		ORDER BY created_at, rowid
+	`, promptID, version)
+	if err != nil {
+		s.logger.Error("failed to list approvals", "error", err, "slug", slug, "version", version)
+		return result, fmt.Errorf("failed to list approvals: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var approval models.Approval
+		if err := rows.Scan(&approval.Reviewer, &approval.Comment, &approval.CreatedAt); err != nil {
+			s.logger.Error("failed to scan approval", "error", err)
+			return result, fmt.Errorf("failed to scan approval: %w", err)
+		}
+		result.Approvals = append(result.Approvals, approval)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate approvals", "error", err)
+		return result, fmt.Errorf("failed to iterate approvals: %w", err)
+	}
+	return result, nil
+}
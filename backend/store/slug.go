@@ -0,0 +1,109 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxSlugLength is the longest slug a prompt can have
+const MaxSlugLength = 100
+
+// reservedSlugs can't be used as prompt slugs because they name routes or
+// read as paths rather than prompts
+var reservedSlugs = map[string]bool{
+	"admin":           true,
+	"api":             true,
+	"docs":            true,
+	"export":          true,
+	"gallery":         true,
+	"health":          true,
+	"healthz":         true,
+	"import":          true,
+	"metrics":         true,
+	"new":             true,
+	"openapi-json":    true,
+	"prompts":         true,
+	"readyz":          true,
+	"semantic-search": true,
+	"static":          true,
+	"ws":              true,
+}
+
+// transliterations spells letters that don't decompose into an ASCII base
+// letter plus accents
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d",
+	'þ': "th", 'ł': "l", 'ı': "i", 'ħ': "h", 'ŋ': "ng", 'ĸ': "k",
+}
+
+// generateSlug creates a URL-friendly slug from a title. Accented letters
+// lose their accents, runs of anything but letters and digits become one
+// hyphen, and long titles are cut at a word boundary. A title with no Latin
+// letters or digits gives an empty slug.
+func generateSlug(title string) string {
+	var result strings.Builder
+	hyphen := false
+	for _, r := range norm.NFKD.String(strings.ToLower(title)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		spelled := string(r)
+		if t, ok := transliterations[r]; ok {
+			spelled = t
+		} else if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') {
+			hyphen = result.Len() > 0
+			continue
+		}
+		if hyphen {
+			result.WriteByte('-')
+			hyphen = false
+		}
+		result.WriteString(spelled)
+	}
+
+	slug := result.String()
+	if len(slug) > MaxSlugLength {
+		slug = slug[:MaxSlugLength]
+		if i := strings.LastIndexByte(slug, '-'); i > 0 {
+			slug = slug[:i]
+		}
+		slug = strings.TrimSuffix(slug, "-")
+	}
+	if reservedSlugs[slug] {
+		slug += "-prompt"
+	}
+	return slug
+}
+
+// ValidateSlug checks a slug chosen by a caller: 1 to MaxSlugLength lowercase
+// ASCII letters and digits, in words joined by single hyphens, and not a
+// reserved word
+func ValidateSlug(slug string) error {
+	if slug == "" {
+		return invalidField("slug", "invalid slug: cannot be empty")
+	}
+	if len(slug) > MaxSlugLength {
+		return invalidField("slug", "invalid slug: %d characters; the limit is %d", len(slug), MaxSlugLength)
+	}
+	valid := !strings.HasPrefix(slug, "-") && !strings.HasSuffix(slug, "-") && !strings.Contains(slug, "--")
+	for _, r := range slug {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '-' {
+			valid = false
+		}
+	}
+	if !valid {
+		msg := fmt.Sprintf("invalid slug %q: use lowercase letters and digits, with single hyphens between words", slug)
+		if suggested := generateSlug(slug); suggested != "" {
+			msg += fmt.Sprintf(", like %q", suggested)
+		}
+		return withField("slug", errors.New(msg))
+	}
+	if reservedSlugs[slug] {
+		return invalidField("slug", "invalid slug %q: reserved", slug)
+	}
+	return nil
+}
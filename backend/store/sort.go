@@ -0,0 +1,65 @@
+package store
+
+// Prompt listing sort fields
+const (
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+	SortTitle     = "title"
+)
+
+// Sort orders
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// sortColumns maps each sort field to the expression it orders prompts p by.
+// Only these expressions ever reach the query, so callers can pass user input.
+var sortColumns = map[string]string{
+	SortCreatedAt: "COALESCE(p.original_created_at, p.created_at)",
+	SortUpdatedAt: "p.updated_at",
+	SortTitle:     "p.title COLLATE NOCASE",
+}
+
+// PromptSort orders a prompt listing. The zero value lists newest first.
+type PromptSort struct {
+	// Field is created_at (the default), updated_at, or title
+	Field string
+	// Order is asc or desc. It defaults to desc for times and asc for title.
+	Order string
+}
+
+// String describes the sort for logs, e.g. "updated_at desc"
+func (ps PromptSort) String() string {
+	field, order := ps.resolve()
+	return field + " " + order
+}
+
+// resolve fills in the defaults
+func (ps PromptSort) resolve() (string, string) {
+	field, order := ps.Field, ps.Order
+	if field == "" {
+		field = SortCreatedAt
+	}
+	if order == "" {
+		order = OrderDesc
+		if field == SortTitle {
+			order = OrderAsc
+		}
+	}
+	return field, order
+}
+
+// orderBy returns the ORDER BY clause for the sort. Ties are broken by id in
+// the same direction so pages don't overlap.
+func (ps PromptSort) orderBy() (string, error) {
+	field, order := ps.resolve()
+	column, ok := sortColumns[field]
+	if !ok {
+		return "", invalidField("sort", "invalid sort %q: use %s, %s, or %s", field, SortCreatedAt, SortUpdatedAt, SortTitle)
+	}
+	if order != OrderAsc && order != OrderDesc {
+		return "", invalidField("order", "invalid order %q: use %s or %s", order, OrderAsc, OrderDesc)
+	}
+	return column + " " + order + ", p.id " + order, nil
+}
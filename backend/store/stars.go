@@ -0,0 +1,59 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// starSchema records which prompts each caller has starred. Stars are
+// personal shortlists: they don't change the prompt or show up in its audit
+// log.
+const starSchema = `
+	CREATE TABLE IF NOT EXISTS prompt_stars (
+		prompt_id  INTEGER NOT NULL,
+		subject    TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(prompt_id, subject),
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_prompt_stars_subject ON prompt_stars(subject);
+`
+
+// starredByCaller restricts prompts p to those starred by the caller; it takes
+// the caller's subject as its argument
+const starredByCaller = `EXISTS (SELECT 1 FROM prompt_stars st WHERE st.prompt_id = p.id AND st.subject = ?)`
+
+// SetPromptStarred stars or unstars a prompt for the caller. Starring a
+// starred prompt, or unstarring one that isn't, does nothing. Without an
+// identity, every caller shares one set of stars.
+func (s *SQLiteStore) SetPromptStarred(slug string, starred bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	promptID, err := s.promptID(slug)
+	if err != nil {
+		return err
+	}
+
+	if starred {
+		_, err = s.db.Exec(`INSERT OR IGNORE INTO prompt_stars (prompt_id, subject) VALUES (?, ?)`, promptID, s.actor)
+	} else {
+		_, err = s.db.Exec(`DELETE FROM prompt_stars WHERE prompt_id = ? AND subject = ?`, promptID, s.actor)
+	}
+	if err != nil {
+		s.logger.Error("failed to update star", "error", err, "slug", slug, "starred", starred)
+		return fmt.Errorf("failed to update star: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("SetPromptStarred", duration)
+	s.logger.Info("database operation",
+		"operation", "SetPromptStarred",
+		"slug", slug,
+		"starred", starred,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
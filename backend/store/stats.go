@@ -0,0 +1,231 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// contentBytes sums the content size of the versions of prompts p
+const contentBytes = `(SELECT COALESCE(SUM(LENGTH(CAST(v.content AS BLOB))), 0) FROM prompt_versions v WHERE v.prompt_id = p.id)`
+
+// versionBuckets are the lower bounds of the versions-per-prompt ranges; each
+// range ends before the next bound, and the last has no upper bound
+var versionBuckets = []int{1, 2, 6, 11, 26, 51, 101}
+
+// GetRegistryStats breaks down the prompts the caller can read, archived ones
+// included, for capacity planning: totals and size, prompts per label, how
+// many versions prompts have, up to limit of the largest prompts by content,
+// and how many prompts and versions were created each day from since to today.
+func (s *SQLiteStore) GetRegistryStats(since time.Time, limit int) (models.RegistryStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.RegistryStats
+
+	scope := `FROM prompts p WHERE p.project = ? AND ` + readableByCaller
+	scopeArgs := append([]any{s.project}, s.readableArgs()...)
+	err := s.db.QueryRow(`
+		SELECT COUNT(*),
+			COALESCE(SUM(`+versionCount+`), 0),
+			COALESCE(SUM(`+contentBytes+`), 0)
+		`+scope, scopeArgs...,
+	).Scan(&result.Prompts, &result.Versions, &result.ContentBytes)
+	if err != nil {
+		s.logger.Error("failed to count prompts", "error", err)
+		return result, fmt.Errorf("failed to count prompts: %w", err)
+	}
+	if result.DatabaseBytes, err = s.databaseSize(); err != nil {
+		s.logger.Error("failed to read database size", "error", err)
+		return result, err
+	}
+
+	if result.Labels, err = s.labelCounts(scope, scopeArgs); err != nil {
+		return result, err
+	}
+	if result.VersionsPerPrompt, err = s.versionDistribution(scope, scopeArgs); err != nil {
+		return result, err
+	}
+	if result.Largest, err = s.largestPrompts(scope, scopeArgs, limit); err != nil {
+		return result, err
+	}
+	if result.Created, err = s.creationCounts(scope, scopeArgs, since); err != nil {
+		return result, err
+	}
+
+	duration := time.Since(start)
+	s.observe("GetRegistryStats", duration)
+	s.logger.Info("database operation",
+		"operation", "GetRegistryStats",
+		"prompts", result.Prompts,
+		"content_bytes", result.ContentBytes,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// labelCounts counts the prompts in scope per label, most used first
+func (s *SQLiteStore) labelCounts(scope string, scopeArgs []any) ([]models.LabelCount, error) {
+	rows, err := s.db.Query(`
+		SELECT l.label, COUNT(*)
+		FROM prompt_labels l
+		WHERE l.prompt_id IN (SELECT p.id `+scope+`)
+		GROUP BY l.label
+		ORDER BY COUNT(*) DESC, l.label
+	`, scopeArgs...)
+	if err != nil {
+		s.logger.Error("failed to count labels", "error", err)
+		return nil, fmt.Errorf("failed to count labels: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.LabelCount{}
+	for rows.Next() {
+		var count models.LabelCount
+		if err := rows.Scan(&count.Label, &count.Prompts); err != nil {
+			s.logger.Error("failed to scan label count", "error", err)
+			return nil, fmt.Errorf("failed to scan label count: %w", err)
+		}
+		results = append(results, count)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate label counts", "error", err)
+		return nil, fmt.Errorf("failed to iterate label counts: %w", err)
+	}
+	return results, nil
+}
+
+// versionDistribution counts the prompts in scope in each of versionBuckets.
+// Prompts without versions aren't counted.
+func (s *SQLiteStore) versionDistribution(scope string, scopeArgs []any) ([]models.VersionCountBucket, error) {
+	results := make([]models.VersionCountBucket, len(versionBuckets))
+	for i, low := range versionBuckets {
+		results[i].Min = low
+		if i+1 < len(versionBuckets) {
+			results[i].Max = versionBuckets[i+1] - 1
+		}
+	}
+
+	rows, err := s.db.Query(`
+		SELECT n, COUNT(*)
+		FROM (SELECT `+versionCount+` AS n `+scope+`)
+		WHERE n > 0
+		GROUP BY n
+	`, scopeArgs...)
+	if err != nil {
+		s.logger.Error("failed to count versions per prompt", "error", err)
+		return nil, fmt.Errorf("failed to count versions per prompt: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var versions, prompts int
+		if err := rows.Scan(&versions, &prompts); err != nil {
+			s.logger.Error("failed to scan version count", "error", err)
+			return nil, fmt.Errorf("failed to scan version count: %w", err)
+		}
+		for i := len(results) - 1; i >= 0; i-- {
+			if versions >= results[i].Min {
+				results[i].Prompts += prompts
+				break
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate version counts", "error", err)
+		return nil, fmt.Errorf("failed to iterate version counts: %w", err)
+	}
+	return results, nil
+}
+
+// largestPrompts returns up to limit of the prompts in scope with the most
+// version content
+func (s *SQLiteStore) largestPrompts(scope string, scopeArgs []any, limit int) ([]models.PromptSize, error) {
+	args := append(append([]any{}, scopeArgs...), limit)
+	rows, err := s.db.Query(`
+		SELECT p.slug, p.title, `+versionCount+`, `+contentBytes+`
+		`+scope+`
+		ORDER BY `+contentBytes+` DESC, p.slug
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		s.logger.Error("failed to list largest prompts", "error", err)
+		return nil, fmt.Errorf("failed to list largest prompts: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.PromptSize{}
+	for rows.Next() {
+		var size models.PromptSize
+		if err := rows.Scan(&size.Slug, &size.Title, &size.Versions, &size.ContentBytes); err != nil {
+			s.logger.Error("failed to scan prompt size", "error", err)
+			return nil, fmt.Errorf("failed to scan prompt size: %w", err)
+		}
+		results = append(results, size)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate prompt sizes", "error", err)
+		return nil, fmt.Errorf("failed to iterate prompt sizes: %w", err)
+	}
+	return results, nil
+}
+
+// creationCounts counts the prompts in scope, and their versions, created on
+// each UTC day from since to today. Imported prompts and versions count on
+// their original creation day.
+func (s *SQLiteStore) creationCounts(scope string, scopeArgs []any, since time.Time) ([]models.CreationCount, error) {
+	first := since.UTC().Truncate(24 * time.Hour)
+	results := []models.CreationCount{}
+	index := make(map[string]int)
+	for day := first; !day.After(time.Now().UTC()); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		index[date] = len(results)
+		results = append(results, models.CreationCount{Date: date})
+	}
+
+	from := timestampValue(&first)
+	queries := []struct {
+		query string
+		count func(c *models.CreationCount) *int
+	}{
+		{`SELECT date(` + sortColumns[SortCreatedAt] + `) AS day, COUNT(*)
+			` + scope + ` AND ` + sortColumns[SortCreatedAt] + ` >= ?
+			GROUP BY day`,
+			func(c *models.CreationCount) *int { return &c.Prompts }},
+		{`SELECT date(COALESCE(v.original_created_at, v.created_at)) AS day, COUNT(*)
+			FROM prompt_versions v
+			WHERE v.prompt_id IN (SELECT p.id ` + scope + `) AND COALESCE(v.original_created_at, v.created_at) >= ?
+			GROUP BY day`,
+			func(c *models.CreationCount) *int { return &c.Versions }},
+	}
+	for _, q := range queries {
+		args := append(append([]any{}, scopeArgs...), from)
+		rows, err := s.db.Query(q.query, args...)
+		if err != nil {
+			s.logger.Error("failed to count creations", "error", err)
+			return nil, fmt.Errorf("failed to count creations: %w", err)
+		}
+		for rows.Next() {
+			var date string
+			var n int
+			if err := rows.Scan(&date, &n); err != nil {
+				rows.Close()
+				s.logger.Error("failed to scan creation count", "error", err)
+				return nil, fmt.Errorf("failed to scan creation count: %w", err)
+			}
+			// Days after today, from clock skew, have no entry
+			if i, ok := index[date]; ok {
+				*q.count(&results[i]) += n
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			s.logger.Error("failed to iterate creation counts", "error", err)
+			return nil, fmt.Errorf("failed to iterate creation counts: %w", err)
+		}
+	}
+	return results, nil
+}
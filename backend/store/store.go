@@ -1,53 +1,415 @@
 package store
 
 import (
+	"cmp"
+	"context"
 	"database/sql"
-	"errors"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/render"
 )
 
+// MaxImportVersions bounds how many versions one ImportPromptVersions call appends
+const MaxImportVersions = 1000
+
+// DefaultProject holds prompts created without naming a project, including
+// every prompt from before projects existed
+const DefaultProject = "default"
+
 // Store defines the interface for prompt storage operations
 type Store interface {
+	InProject(project string) Store
+	Project() string
+	WithContext(ctx context.Context) Store
+	ListProjects() ([]models.Project, error)
+	AuthorizeProject() error
+	AuthorizePrompt(slug, access string) error
+	GetPromptACL(slug string) (models.PromptACL, error)
+	SetPromptACL(slug string, input models.SetPromptACLInput) (models.PromptACL, error)
+	GetPromptLinks(slug string) (models.PromptLinks, error)
+	SetPromptLinks(slug string, input models.SetPromptLinksInput) (models.PromptLinks, error)
+	CreateOrg(input models.CreateOrgInput) (models.Org, error)
+	ListOrgs() ([]models.Org, error)
+	GetOrg(name string) (models.Org, error)
+	AddOrgMember(org string, input models.AddOrgMemberInput) (models.OrgMember, error)
+	RemoveOrgMember(org, subject string) error
+	AssignProject(org, project string) error
+	ReleaseProject(org, project string) error
 	CreatePrompt(input models.CreatePromptInput) (models.PromptWithCurrentVersion, error)
+	ForkPrompt(slug string, input models.ForkPromptInput) (models.PromptWithCurrentVersion, error)
 	CreatePromptVersion(slug string, input models.CreatePromptVersionInput) (models.PromptWithCurrentVersion, error)
+	ImportPromptVersions(slug string, input models.ImportVersionsInput) ([]models.PromptVersion, error)
+	ImportPrompts(prompts []models.ExportedPrompt) (models.ImportResult, error)
 	GetPromptBySlug(slug string) (models.PromptWithCurrentVersion, error)
+	GetPromptsBySlugs(slugs []string) ([]models.PromptWithCurrentVersion, error)
 	GetPromptVersion(slug string, version int) (models.PromptVersion, error)
-	ListPrompts(limit, offset int) ([]models.PromptSummary, error)
-	ListPromptVersions(slug string) ([]models.PromptVersion, error)
+	GetPublicPromptVersion(slug string, version int) (models.PromptVersion, error)
+	GetLatestPromptVersion(slug string) (models.PromptVersion, error)
+	GetLatestPublicPromptVersion(slug string) (models.PromptVersion, error)
+	ListPrompts(limit, offset int, sort PromptSort, filter PromptFilter) ([]models.PromptSummary, error)
+	ListAllPrompts(limit, offset int, sort PromptSort, filter PromptFilter) ([]models.PromptSummary, error)
+	CountPrompts(includeArchived bool, filter PromptFilter) (int, error)
+	ListPromptVersions(slug string, limit, offset int) ([]models.PromptVersion, error)
+	CountPromptVersions(slug string) (int, error)
+	ListPublicPromptVersions(slug string, limit, offset int) ([]models.PromptVersion, error)
+	CountPublicPromptVersions(slug string) (int, error)
+	ExportPrompts(fn func(models.ExportedPrompt) error) (int, error)
 	GetStats() (models.Stats, error)
+	GetProjectStats() (models.Stats, error)
+	CheckReadiness(ctx context.Context, writeProbe bool) []models.ReadinessCheck
+	SetPromptVisibility(slug string, public bool) error
+	SetPromptDescription(slug, description string) (models.PromptWithCurrentVersion, error)
+	GetPromptDocs(slug string, revision int) (models.PromptDocs, error)
+	ListPromptDocs(slug string) ([]models.PromptDocs, error)
+	SetPromptDocs(slug, content string) (models.PromptDocs, error)
+	AddVersionComment(slug string, version int, body string) (models.VersionComment, error)
+	ListVersionComments(slug string, version int) ([]models.VersionComment, error)
+	SetPromptArchived(slug string, archived bool) error
+	SetPromptStarred(slug string, starred bool) error
+	RecordPromptUsage(counts []UsageCount) error
+	GetPromptUsage(slug string) (models.PromptUsage, error)
+	GetAnalytics(staleSince time.Time, limit int) (models.Analytics, error)
+	GetRegistryStats(since time.Time, limit int) (models.RegistryStats, error)
+	SetLegalHold(slug string, held bool, reason string) error
+	GetPromptRetention(slug string, defaults models.RetentionPolicy) (models.PromptRetention, error)
+	SetPromptRetention(slug string, policy *models.RetentionPolicy) error
+	PruneVersions(defaults models.RetentionPolicy, dryRun bool) (models.PruneResult, error)
+	SetPromptVariables(slug string, vars []models.Variable) (models.PromptWithCurrentVersion, error)
+	SetPromptExecution(slug string, config models.ExecutionConfig) error
+	SetVersionPinned(slug string, version int, pinned bool) (models.PromptVersion, error)
+	GetReviewPolicy(slug string) (models.ReviewPolicy, error)
+	SetReviewPolicy(slug string, policy models.ReviewPolicy) (models.ReviewPolicy, error)
+	GetVersionReview(slug string, version int) (models.VersionReview, error)
+	ApproveVersion(slug string, version int, comment string) (models.VersionReview, error)
+	RedactPromptVersion(slug string, version int, reason string) (models.PromptVersion, error)
+	ListPublicPrompts(limit, offset int) ([]models.PromptSummary, error)
+	CountPublicPrompts() (int, error)
+	ListPromptWebhooks(slug string) ([]models.Webhook, error)
+	AddPromptWebhook(slug, url string) (models.Webhook, error)
+	DeletePromptWebhook(slug string, id int64) error
+	CreateRelease(input models.CreateReleaseInput) (models.Release, error)
+	GetRelease(id int64) (models.Release, error)
+	ListReleases(filter ReleaseFilter, limit, offset int) ([]models.Release, error)
+	RollbackRelease(id int64) (models.Release, error)
+	CreateEnvironment(input models.CreateEnvironmentInput) (models.Environment, error)
+	ListEnvironments() ([]models.Environment, error)
+	GetEnvironment(name string) (models.Environment, error)
+	DeleteEnvironment(name string) error
+	Promote(slug string, input models.PromoteInput) (models.Release, error)
+	ListPromptLabels(slug string) ([]models.PromptLabel, error)
+	GetPromptLabel(slug, label string) (models.PromptLabel, error)
+	CreateCollection(input models.CreateCollectionInput) (models.Collection, error)
+	ListCollections() ([]models.Collection, error)
+	ListCollectionPrompts(id int64, recursive bool) ([]models.PromptSummary, error)
+	SetPromptInCollection(id int64, slug string, member bool) error
+	CreateDataset(input models.CreateDatasetInput) (models.Dataset, error)
+	ListDatasets() ([]models.Dataset, error)
+	GetDataset(name string) (models.Dataset, error)
+	CreateEvalRun(run models.EvalRun) (models.EvalRun, error)
+	AddEvalResult(runID int64, result models.EvalResult) error
+	FinishEvalRun(runID int64, runErr string) error
+	GetEvalRun(id int64) (models.EvalRun, error)
+	ListEvalRuns(slug string) ([]models.EvalRun, error)
+	ListAuditEntries(slug string, limit, offset int) ([]models.AuditEntry, error)
+	ListAdminAuditEntries(limit, offset int) ([]models.AuditEntry, error)
+	DeletePromptPermanently(slug string) (models.DeletedPrompt, error)
+	PurgeArchivedPrompts(cutoff time.Time, dryRun bool) (models.PurgeResult, error)
+	RecomputeDenormalized() (models.RecomputeResult, error)
+	ListStaleEmbeddings(model string, limit int) ([]models.EmbeddingSource, error)
+	SaveEmbeddings(model string, sources []models.EmbeddingSource, vectors [][]float32) error
+	SemanticSearch(model string, query []float32, limit int) ([]models.SemanticSearchResult, error)
+	Compact() (models.CompactResult, error)
+	Backup(destPath string) error
+	Reopen(dbPath string) (models.ReopenResult, error)
+	Restore(backupPath string) (models.RestoreResult, error)
 	Close() error
 }
 
-// SQLiteStore implements the Store interface using SQLite
+// SQLiteStore implements the Store interface using SQLite. Prompt operations
+// are scoped to one project and writes are attributed to one actor; InProject
+// and WithContext return stores for others sharing the same connection.
 type SQLiteStore struct {
+	*database
+	project string
+	actor   string
+	// logger shadows the database's so operations served for a request log
+	// with its request ID
+	logger *slog.Logger
+}
+
+// InProject returns a store for another project's prompts. Projects need no
+// setup; one exists once a prompt is created in it.
+func (s *SQLiteStore) InProject(project string) Store {
+	return &SQLiteStore{database: s.database, project: project, actor: s.actor, logger: s.logger}
+}
+
+// Project returns the project this store's prompt operations are scoped to
+func (s *SQLiteStore) Project() string {
+	return s.project
+}
+
+// ValidateProject checks a project name: 1-63 lowercase letters, digits, and
+// hyphens, starting with a letter or digit
+func ValidateProject(project string) error {
+	if project == "" || len(project) > 63 || project[0] == '-' {
+		return invalid("invalid project name %q: use 1-63 lowercase letters, digits, and hyphens", project)
+	}
+	for _, r := range project {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return invalid("invalid project name %q: use 1-63 lowercase letters, digits, and hyphens", project)
+		}
+	}
+	return nil
+}
+
+// ListProjects returns the default project followed by every other project
+// the caller can see, alphabetically. A project is listed once it holds a
+// prompt or belongs to an org; projects in orgs the caller isn't a member of
+// are left out. Prompt counts only include prompts the caller can read.
+func (s *SQLiteStore) ListProjects() ([]models.Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	rows, err := s.db.Query(`
+		WITH names AS (SELECT project FROM prompts UNION SELECT project FROM org_projects)
+		SELECT n.project, COALESCE(o.name, ''), COUNT(p.id), COUNT(p.archived_at)
+		FROM names n
+		LEFT JOIN prompts p ON p.project = n.project AND `+readableByCaller+`
+		LEFT JOIN org_projects op ON op.project = n.project
+		LEFT JOIN orgs o ON o.id = op.org_id
+		WHERE op.org_id IS NULL
+			OR EXISTS (SELECT 1 FROM org_members m WHERE m.org_id = op.org_id AND m.subject = ?)
+		GROUP BY n.project
+		ORDER BY n.project != ?, n.project
+	`, append(s.readableArgs(), s.actor, DefaultProject)...)
+	if err != nil {
+		s.logger.Error("failed to list projects", "error", err)
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.Project{}
+	hasDefault := false
+	for rows.Next() {
+		var project models.Project
+		if err := rows.Scan(&project.Name, &project.Org, &project.Prompts, &project.ArchivedPrompts); err != nil {
+			s.logger.Error("failed to scan project", "error", err)
+			return nil, fmt.Errorf("failed to scan project: %w", err)
+		}
+		hasDefault = hasDefault || project.Name == DefaultProject
+		results = append(results, project)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate projects", "error", err)
+		return nil, fmt.Errorf("failed to iterate projects: %w", err)
+	}
+	if !hasDefault {
+		results = append([]models.Project{{Name: DefaultProject}}, results...)
+	}
+
+	duration := time.Since(start)
+	s.observe("ListProjects", duration)
+	s.logger.Info("database operation",
+		"operation", "ListProjects",
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// database is the connection shared by a store and its project views
+type database struct {
+	// mu is held for reading by every operation so Reopen can drain them
+	// before swapping the connection
+	mu     sync.RWMutex
 	db     *sql.DB
+	path   string
+	opts   Options
 	logger *slog.Logger
+	// observer, if set, is told how long each operation took
+	observer atomic.Pointer[OperationObserver]
 }
 
-// New creates a new SQLiteStore and initializes the database
-func New(dbPath string) (*SQLiteStore, error) {
-	logger := slog.Default()
+// OperationObserver is told the name and duration of each completed database
+// operation, for example to record latency metrics
+type OperationObserver func(operation string, duration time.Duration)
 
-	// Remove sqlite3:// prefix if present
-	cleanPath := strings.TrimPrefix(dbPath, "sqlite3://")
-	db, err := sql.Open("sqlite3", cleanPath)
+// SetOperationObserver reports every completed operation to fn, including
+// operations of the store's project views. A nil fn stops reporting.
+func (d *database) SetOperationObserver(fn OperationObserver) {
+	if fn == nil {
+		d.observer.Store(nil)
+		return
+	}
+	d.observer.Store(&fn)
+}
+
+// observe reports a completed operation to the observer, if any
+func (d *database) observe(operation string, duration time.Duration) {
+	if fn := d.observer.Load(); fn != nil {
+		(*fn)(operation, duration)
+	}
+}
+
+// Options tunes the SQLite connection pool. Every connection is opened with
+// foreign keys enforced. The zero value uses WAL mode, synchronous=NORMAL,
+// the driver's busy timeout, no query timeout, and slog.Default().
+type Options struct {
+	// BusyTimeout is how long a statement waits for a lock held by another
+	// connection before failing with "database is locked"
+	BusyTimeout time.Duration
+	// MaxOpenConns caps the open connections; 0 leaves them unlimited
+	MaxOpenConns int
+	// JournalMode is DELETE, TRUNCATE, PERSIST, MEMORY, WAL, or OFF; empty
+	// means WAL, which lets reads run while a write is in progress
+	JournalMode string
+	// Synchronous is OFF, NORMAL, FULL, or EXTRA; empty means NORMAL, which
+	// in WAL mode survives application crashes and can only lose the most
+	// recent commits on power loss
+	Synchronous string
+	// QueryTimeout bounds each statement, including reading its rows; one
+	// still running is interrupted and fails with context.DeadlineExceeded.
+	// 0 means no limit.
+	QueryTimeout time.Duration
+	// Logger receives the store's logs; nil means slog.Default()
+	Logger *slog.Logger
+}
+
+// Option sets one of the Options for New
+type Option func(*Options)
+
+// WithBusyTimeout sets Options.BusyTimeout
+func WithBusyTimeout(d time.Duration) Option {
+	return func(o *Options) { o.BusyTimeout = d }
+}
+
+// WithMaxOpenConns sets Options.MaxOpenConns
+func WithMaxOpenConns(n int) Option {
+	return func(o *Options) { o.MaxOpenConns = n }
+}
+
+// WithJournalMode sets Options.JournalMode
+func WithJournalMode(mode string) Option {
+	return func(o *Options) { o.JournalMode = mode }
+}
+
+// WithSynchronous sets Options.Synchronous
+func WithSynchronous(setting string) Option {
+	return func(o *Options) { o.Synchronous = setting }
+}
+
+// WithQueryTimeout sets Options.QueryTimeout
+func WithQueryTimeout(d time.Duration) Option {
+	return func(o *Options) { o.QueryTimeout = d }
+}
+
+// WithLogger sets Options.Logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *Options) { o.Logger = logger }
+}
+
+// WithOptions replaces every setting with opts, e.g. ones built from a config
+// file; options after it can still change them
+func WithOptions(opts Options) Option {
+	return func(o *Options) { *o = opts }
+}
+
+// logger returns Logger, or slog.Default() when it isn't set
+func (o Options) logger() *slog.Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+	return slog.Default()
+}
+
+// Defaults for the Options pragmas
+const (
+	DefaultJournalMode = "WAL"
+	DefaultSynchronous = "NORMAL"
+)
+
+var (
+	journalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	synchronous  = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// Validate checks the pragma settings
+func (o Options) Validate() error {
+	if o.JournalMode != "" && !slices.Contains(journalModes, strings.ToUpper(o.JournalMode)) {
+		return invalid("invalid journal mode %q: want one of %s", o.JournalMode, strings.Join(journalModes, ", "))
+	}
+	if o.Synchronous != "" && !slices.Contains(synchronous, strings.ToUpper(o.Synchronous)) {
+		return invalid("invalid synchronous setting %q: want one of %s", o.Synchronous, strings.Join(synchronous, ", "))
+	}
+	if o.BusyTimeout < 0 {
+		return invalid("invalid busy timeout: cannot be negative")
+	}
+	if o.MaxOpenConns < 0 {
+		return invalid("invalid max open conns: cannot be negative")
+	}
+	if o.QueryTimeout < 0 {
+		return invalid("invalid query timeout: cannot be negative")
+	}
+	return nil
+}
+
+// dsn adds the connection settings to a database path. DSN parameters apply
+// to every pooled connection; a PRAGMA statement only to the one it ran on.
+func (o Options) dsn(dbPath string) string {
+	dsn := strings.TrimPrefix(dbPath, "sqlite3://")
+	params := []string{
+		"_journal_mode=" + cmp.Or(strings.ToUpper(o.JournalMode), DefaultJournalMode),
+		"_synchronous=" + cmp.Or(strings.ToUpper(o.Synchronous), DefaultSynchronous),
+		"_foreign_keys=1",
+	}
+	if o.BusyTimeout > 0 {
+		params = append(params, fmt.Sprintf("_busy_timeout=%d", o.BusyTimeout.Milliseconds()))
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + strings.Join(params, "&")
+}
+
+// New creates a new SQLiteStore and brings its schema up to date, e.g.
+// New(path, WithMaxOpenConns(8), WithQueryTimeout(10*time.Second)). The
+// options also apply when the database is reopened or restored.
+func New(dbPath string, options ...Option) (*SQLiteStore, error) {
+	var opts Options
+	for _, option := range options {
+		option(&opts)
+	}
+	return NewWithOptions(dbPath, opts)
+}
+
+// NewWithOptions is New with every setting given at once
+func NewWithOptions(dbPath string, opts Options) (*SQLiteStore, error) {
+	logger := opts.logger()
+
+	db, err := openDatabase(dbPath, opts, logger)
 	if err != nil {
-		logger.Error("failed to open database", "error", err, "path", dbPath)
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
 	store := &SQLiteStore{
-		db:     db,
-		logger: logger,
+		database: &database{db: db, path: dbPath, opts: opts, logger: logger},
+		project:  DefaultProject,
+		logger:   logger,
 	}
-
-	if err := store.initSchema(); err != nil {
+	if err := store.failInterruptedEvalRuns(); err != nil {
 		db.Close()
 		return nil, err
 	}
@@ -56,17 +418,57 @@ func New(dbPath string) (*SQLiteStore, error) {
 	return store, nil
 }
 
-// initSchema creates the database tables if they don't exist
-func (s *SQLiteStore) initSchema() error {
+// openDatabase opens a SQLite file and applies any pending migrations
+func openDatabase(dbPath string, opts Options, logger *slog.Logger) (*sql.DB, error) {
+	db, err := openConnection(dbPath, opts, logger)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := migrate(db, logger, LatestSchemaVersion()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// openConnection opens a SQLite file with opts applied to every connection
+func openConnection(dbPath string, opts Options, logger *slog.Logger) (*sql.DB, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	var db *sql.DB
+	if opts.QueryTimeout > 0 {
+		db = sql.OpenDB(&timeoutConnector{dsn: opts.dsn(dbPath), timeout: opts.QueryTimeout})
+	} else {
+		var err error
+		if db, err = sql.Open("sqlite3", opts.dsn(dbPath)); err != nil {
+			logger.Error("failed to open database", "error", err, "path", dbPath)
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	// Each connection to :memory: opens its own empty database, so keep one
+	if strings.TrimPrefix(dbPath, "sqlite3://") == ":memory:" {
+		db.SetMaxOpenConns(1)
+	}
+	return db, nil
+}
+
+// migrateBaseline is migration 1: the schema as it was before versioned
+// migrations. It creates missing tables and upgrades databases made by
+// earlier releases in place, so it's safe on any of them.
+func migrateBaseline(tx *sql.Tx) error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS prompts (
 		id               INTEGER PRIMARY KEY AUTOINCREMENT,
-		slug             TEXT UNIQUE NOT NULL,
+		project          TEXT NOT NULL DEFAULT 'default',
+		slug             TEXT NOT NULL,
 		title            TEXT NOT NULL,
 		description      TEXT,
 		current_version  INTEGER NOT NULL DEFAULT 0,
 		created_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		updated_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(project, slug)
 	);
 
 	CREATE TABLE IF NOT EXISTS prompt_versions (
@@ -78,51 +480,319 @@ func (s *SQLiteStore) initSchema() error {
 		FOREIGN KEY(prompt_id) REFERENCES prompts(id),
 		UNIQUE(prompt_id, version_number)
 	);
+
+	CREATE TABLE IF NOT EXISTS prompt_webhooks (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		prompt_id  INTEGER NOT NULL,
+		url        TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id),
+		UNIQUE(prompt_id, url)
+	);
 	`
 
-	if _, err := s.db.Exec(schema); err != nil {
-		s.logger.Error("failed to initialize schema", "error", err)
+	if _, err := tx.Exec(schema + evalSchema + auditSchema + orgSchema + aclSchema + docsSchema + immutabilitySchema); err != nil {
 		return fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
+	// Columns added after the initial schema; existing databases are upgraded in place
+	if err := ensureColumn(tx, "prompts", "public", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompts", "variables", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompts", "exec_provider", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompts", "exec_model", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompt_versions", "original_created_at", "DATETIME"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompts", "archived_at", "DATETIME"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompts", "original_created_at", "DATETIME"); err != nil {
+		return err
+	}
+
+	if err := migratePromptProjects(tx); err != nil {
+		return err
+	}
+	// Added after the projects rebuild, which only copies the columns it knows
+	if err := ensureColumn(tx, "prompts", "created_by", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompts", "updated_by", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompt_versions", "created_by", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompt_versions", "pinned", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompts", "legal_hold_at", "DATETIME"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompts", "legal_hold_reason", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompt_versions", "redacted_at", "DATETIME"); err != nil {
+		return err
+	}
+	if err := ensureColumn(tx, "prompt_versions", "original_sha256", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return ensureColumn(tx, "prompts", "owner", "TEXT NOT NULL DEFAULT ''")
+}
+
+// migratePromptProjects moves prompts from databases created before projects
+// into the default project. Slugs were unique across the whole table then,
+// and SQLite can't change a UNIQUE constraint in place, so the table is
+// rebuilt; prompt IDs are kept, so versions, webhooks, and eval runs still match.
+// Dropping the old table would trip the foreign keys that point at it, which
+// is why migrations run with them off.
+func migratePromptProjects(tx *sql.Tx) error {
+	var hasProject bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM pragma_table_info('prompts') WHERE name = 'project')`).Scan(&hasProject)
+	if err != nil {
+		return fmt.Errorf("failed to inspect table prompts: %w", err)
+	}
+	if hasProject {
+		return nil
+	}
+
+	const columns = `id, slug, title, description, current_version, created_at, updated_at,
+		public, variables, exec_provider, exec_model, archived_at, original_created_at`
+	for _, stmt := range []string{
+		`CREATE TABLE prompts_projects (
+			id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+			project             TEXT NOT NULL DEFAULT 'default',
+			slug                TEXT NOT NULL,
+			title               TEXT NOT NULL,
+			description         TEXT,
+			current_version     INTEGER NOT NULL DEFAULT 0,
+			created_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			public              BOOLEAN NOT NULL DEFAULT 0,
+			variables           TEXT NOT NULL DEFAULT '',
+			exec_provider       TEXT NOT NULL DEFAULT '',
+			exec_model          TEXT NOT NULL DEFAULT '',
+			archived_at         DATETIME,
+			original_created_at DATETIME,
+			UNIQUE(project, slug)
+		)`,
+		`INSERT INTO prompts_projects (` + columns + `) SELECT ` + columns + ` FROM prompts`,
+		`DROP TABLE prompts`,
+		`ALTER TABLE prompts_projects RENAME TO prompts`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to migrate prompts to projects: %w", err)
+		}
+	}
+	return nil
+}
+
+// ensureColumn adds a column to an existing table if it is not already present
+func ensureColumn(tx *sql.Tx, table, column, definition string) error {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM pragma_table_info(?) WHERE name = ?)`, table, column).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	if exists {
+		return nil
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
-// generateSlug creates a URL-friendly slug from a title
-func generateSlug(title string) string {
-	// Convert to lowercase
-	slug := strings.ToLower(title)
-	// Replace spaces with hyphens
-	slug = strings.ReplaceAll(slug, " ", "-")
-	// Remove any character that's not alphanumeric or hyphen
-	var result strings.Builder
-	for _, r := range slug {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
-			result.WriteRune(r)
+// encodeVariables serializes a variable schema for the prompts.variables column
+func encodeVariables(vars []models.Variable) (string, error) {
+	if len(vars) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode variables: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeVariables parses the prompts.variables column; empty means no schema
+func decodeVariables(data string) ([]models.Variable, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var vars []models.Variable
+	if err := json.Unmarshal([]byte(data), &vars); err != nil {
+		return nil, fmt.Errorf("failed to decode variables: %w", err)
+	}
+	return vars, nil
+}
+
+// MaxTemperature is the highest sampling temperature a version's model config may set
+const MaxTemperature = 2.0
+
+// encodeModelConfig validates a version's model parameters and returns the
+// prompt_versions.model_config column for them; empty means none
+func encodeModelConfig(config *models.ModelConfig) (string, error) {
+	if config == nil {
+		return "", nil
+	}
+	if t := config.Temperature; t != nil && (*t < 0 || *t > MaxTemperature) {
+		return "", invalidField("model_config", "invalid model config: temperature %g must be between 0 and %g", *t, MaxTemperature)
+	}
+	if config.MaxTokens < 0 {
+		return "", invalidField("model_config", "invalid model config: max_tokens %d must not be negative", config.MaxTokens)
+	}
+	for i, stop := range config.Stop {
+		if stop == "" {
+			return "", invalidField("model_config", "invalid model config: stop sequence %d is empty", i+1)
 		}
 	}
-	return result.String()
+	if config.Model == "" && config.Temperature == nil && config.MaxTokens == 0 && len(config.Stop) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode model config: %w", err)
+	}
+	return string(data), nil
+}
+
+// storedModelConfig returns config as it reads back once encoded: nil when
+// it sets nothing
+func storedModelConfig(encoded string, config *models.ModelConfig) *models.ModelConfig {
+	if encoded == "" {
+		return nil
+	}
+	return config
+}
+
+// modelConfigField scans the prompt_versions.model_config column into a
+// version's ModelConfig, leaving it nil when empty
+type modelConfigField struct {
+	v *models.PromptVersion
+}
+
+func (f modelConfigField) Scan(src any) error {
+	f.v.ModelConfig = nil
+	var data []byte
+	switch src := src.(type) {
+	case string:
+		data = []byte(src)
+	case []byte:
+		data = src
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	var config models.ModelConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to decode model config: %w", err)
+	}
+	f.v.ModelConfig = &config
+	return nil
+}
+
+// encodeMetadata returns the metadata column for custom fields; empty means none
+func encodeMetadata(metadata map[string]any) (string, error) {
+	if len(metadata) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", invalidField("metadata", "invalid metadata: %v", err)
+	}
+	return string(data), nil
+}
+
+// decodeMetadata parses a metadata column; empty means none
+func decodeMetadata(data string) (map[string]any, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var metadata map[string]any
+	if err := json.Unmarshal([]byte(data), &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+// metadataField scans the prompt_versions.metadata column into a version's Metadata
+type metadataField struct {
+	v *models.PromptVersion
+}
+
+func (f metadataField) Scan(src any) error {
+	var data string
+	switch src := src.(type) {
+	case string:
+		data = src
+	case []byte:
+		data = string(src)
+	}
+	var err error
+	f.v.Metadata, err = decodeMetadata(data)
+	return err
 }
 
 // CreatePrompt creates a new prompt with an initial version
 func (s *SQLiteStore) CreatePrompt(input models.CreatePromptInput) (models.PromptWithCurrentVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	start := time.Now()
 	var result models.PromptWithCurrentVersion
 
 	// Validate input
 	if strings.TrimSpace(input.Title) == "" {
-		return result, errors.New("title cannot be empty")
+		return result, invalidField("title", "title cannot be empty")
+	}
+	format, err := render.ValidateFormat(input.Format)
+	if err != nil {
+		return result, withField("format", err)
+	}
+	content, messages, err := versionContent(format, input.Content, input.Messages)
+	if err != nil {
+		return result, err
 	}
-	if strings.TrimSpace(input.Content) == "" {
-		return result, errors.New("content cannot be empty")
+	if err := validateDescription(input.Description); err != nil {
+		return result, err
 	}
-	if input.Description != "" && len(strings.TrimSpace(input.Description)) < 10 {
-		return result, errors.New("description must be at least 10 characters when provided")
+	if err := render.ValidateSchema(input.Variables); err != nil {
+		return result, withField("variables", err)
+	}
+	if err := render.CheckDeclared(content, input.Variables); err != nil {
+		return result, withKind(ErrValidation, err)
+	}
+	variables, err := encodeVariables(input.Variables)
+	if err != nil {
+		return result, err
+	}
+	modelConfig, err := encodeModelConfig(input.ModelConfig)
+	if err != nil {
+		return result, err
+	}
+	metadata, err := encodeMetadata(input.Metadata)
+	if err != nil {
+		return result, err
 	}
 	// Generate slug if not provided
 	slug := input.Slug
 	if slug == "" {
 		slug = generateSlug(input.Title)
+		if slug == "" {
+			return result, invalidField("slug", "invalid slug: can't make one from title %q; provide a slug", input.Title)
+		}
+	} else if err := ValidateSlug(slug); err != nil {
+		return result, err
 	}
 
 	// Begin transaction
@@ -135,13 +805,13 @@ func (s *SQLiteStore) CreatePrompt(input models.CreatePromptInput) (models.Promp
 
 	// Insert prompt
 	promptResult, err := tx.Exec(
-		`INSERT INTO prompts (slug, title, description, current_version) VALUES (?, ?, ?, 1)`,
-		slug, input.Title, input.Description,
+		`INSERT INTO prompts (project, slug, title, description, format, public, variables, metadata, current_version, created_by, updated_by, owner) VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?)`,
+		s.project, slug, input.Title, input.Description, format, input.Public, variables, metadata, s.actor, s.actor, s.actor,
 	)
 	if err != nil {
 		s.logger.Error("failed to insert prompt", "error", err, "slug", slug)
 		if strings.Contains(err.Error(), "UNIQUE constraint") {
-			return result, fmt.Errorf("prompt with slug %q already exists", slug)
+			return result, slugTaken(slug)
 		}
 		return result, fmt.Errorf("failed to insert prompt: %w", err)
 	}
@@ -154,8 +824,8 @@ func (s *SQLiteStore) CreatePrompt(input models.CreatePromptInput) (models.Promp
 
 	// Insert initial version
 	versionResult, err := tx.Exec(
-		`INSERT INTO prompt_versions (prompt_id, version_number, content) VALUES (?, 1, ?)`,
-		promptID, input.Content,
+		`INSERT INTO prompt_versions (prompt_id, version_number, content, content_sha256, model_config, created_by) VALUES (?, 1, ?, ?, ?, ?)`,
+		promptID, content, contentSHA256(content), modelConfig, s.actor,
 	)
 	if err != nil {
 		s.logger.Error("failed to insert version", "error", err, "prompt_id", promptID)
@@ -167,6 +837,9 @@ func (s *SQLiteStore) CreatePrompt(input models.CreatePromptInput) (models.Promp
 		s.logger.Error("failed to get version ID", "error", err)
 		return result, fmt.Errorf("failed to get version ID: %w", err)
 	}
+	if err := s.audit(tx, promptID, auditPromptCreated, 1, ""); err != nil {
+		return result, err
+	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
@@ -179,15 +852,28 @@ func (s *SQLiteStore) CreatePrompt(input models.CreatePromptInput) (models.Promp
 		Slug:        slug,
 		Title:       input.Title,
 		Description: input.Description,
+		Format:      format,
+		Public:      input.Public,
 		CurrentVersion: models.PromptVersion{
 			ID:            versionID,
 			PromptID:      promptID,
 			VersionNumber: 1,
-			Content:       input.Content,
+			Content:       content,
+			ContentSHA256: contentSHA256(content),
+			CreatedBy:     s.actor,
+			Messages:      messages,
+			ModelConfig:   storedModelConfig(modelConfig, input.ModelConfig),
 		},
+		Variables: input.Variables,
+		CreatedBy: s.actor,
+		UpdatedBy: s.actor,
+	}
+	if metadata != "" {
+		result.Metadata = input.Metadata
 	}
 
 	duration := time.Since(start)
+	s.observe("CreatePrompt", duration)
 	s.logger.Info("database operation",
 		"operation", "CreatePrompt",
 		"slug", slug,
@@ -199,12 +885,23 @@ func (s *SQLiteStore) CreatePrompt(input models.CreatePromptInput) (models.Promp
 
 // CreatePromptVersion creates a new version for an existing prompt
 func (s *SQLiteStore) CreatePromptVersion(slug string, input models.CreatePromptVersionInput) (models.PromptWithCurrentVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	start := time.Now()
 	var result models.PromptWithCurrentVersion
 
 	// Validate input
-	if strings.TrimSpace(input.Content) == "" {
-		return result, errors.New("content cannot be empty")
+	if strings.TrimSpace(input.Content) == "" && len(input.Messages) == 0 {
+		return result, invalidField("content", "content cannot be empty")
+	}
+	modelConfig, err := encodeModelConfig(input.ModelConfig)
+	if err != nil {
+		return result, err
+	}
+	metadata, err := encodeMetadata(input.Metadata)
+	if err != nil {
+		return result, err
 	}
 
 	// Begin transaction
@@ -217,27 +914,82 @@ func (s *SQLiteStore) CreatePromptVersion(slug string, input models.CreatePrompt
 
 	// Get prompt
 	var promptID int64
-	var title, description string
-	var currentVersion int
+	var title, description, format, variablesData, promptMetadata string
+	var public bool
+	var currentVersion, reviewApprovals int
 	err = tx.QueryRow(
-		`SELECT id, title, description, current_version FROM prompts WHERE slug = ?`,
-		slug,
-	).Scan(&promptID, &title, &description, &currentVersion)
+		`SELECT id, title, description, format, public, variables, metadata, current_version, review_approvals FROM prompts WHERE project = ? AND slug = ?`,
+		s.project, slug,
+	).Scan(&promptID, &title, &description, &format, &public, &variablesData, &promptMetadata, &currentVersion, &reviewApprovals)
 	if err == sql.ErrNoRows {
-		return result, fmt.Errorf("prompt with slug %q not found", slug)
+		return result, promptNotFound(slug)
 	}
 	if err != nil {
 		s.logger.Error("failed to get prompt", "error", err, "slug", slug)
 		return result, fmt.Errorf("failed to get prompt: %w", err)
 	}
 
-	// Calculate new version number
-	newVersionNumber := currentVersion + 1
+	content, messages, err := versionContent(format, input.Content, input.Messages)
+	if err != nil {
+		return result, err
+	}
+	if input.Deduplicate {
+		unchanged, err := s.currentVersionMatches(tx, promptID, currentVersion, content, modelConfig)
+		if err != nil {
+			return result, err
+		}
+		if unchanged {
+			tx.Rollback()
+			if result, err = s.getPromptBySlug(slug); err != nil {
+				return result, err
+			}
+			result.Unchanged = true
+			duration := time.Since(start)
+			s.observe("CreatePromptVersion", duration)
+			s.logger.Info("database operation",
+				"operation", "CreatePromptVersion",
+				"slug", slug,
+				"version", currentVersion,
+				"unchanged", true,
+				"duration_ms", duration.Milliseconds(),
+			)
+			return result, nil
+		}
+	}
+	// Checked after deduplication: resending the content that is already
+	// current isn't a conflict
+	if input.BaseVersion != nil && *input.BaseVersion != currentVersion {
+		return result, withKind(ErrVersionConflict, fmt.Errorf("version conflict: prompt %q is at version %d, not %d", slug, currentVersion, *input.BaseVersion))
+	}
+	// Every placeholder must be declared when the prompt has a variable schema
+	variables, err := decodeVariables(variablesData)
+	if err != nil {
+		return result, err
+	}
+	if err := render.CheckDeclared(content, variables); err != nil {
+		return result, withKind(ErrValidation, err)
+	}
+
+	// Calculate new version number. Versions pending review are newer than
+	// the current one, so number after the highest.
+	var newVersionNumber int
+	if err := tx.QueryRow(
+		`SELECT COALESCE(MAX(version_number), 0) + 1 FROM prompt_versions WHERE prompt_id = ?`, promptID,
+	).Scan(&newVersionNumber); err != nil {
+		s.logger.Error("failed to get version number", "error", err, "prompt_id", promptID)
+		return result, fmt.Errorf("failed to get version number: %w", err)
+	}
+	// Under a review policy the version waits for approval and the current
+	// version stays as it is
+	newCurrent, reviewStatus := newVersionNumber, ""
+	if reviewApprovals > 0 {
+		newCurrent, reviewStatus = currentVersion, ReviewPending
+	}
 
 	// Insert new version
 	versionResult, err := tx.Exec(
-		`INSERT INTO prompt_versions (prompt_id, version_number, content) VALUES (?, ?, ?)`,
-		promptID, newVersionNumber, input.Content,
+		`INSERT INTO prompt_versions (prompt_id, version_number, content, content_sha256, model_config, metadata, created_by, review_status) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		promptID, newVersionNumber, content, contentSHA256(content), modelConfig, metadata, s.actor, reviewStatus,
 	)
 	if err != nil {
 		s.logger.Error("failed to insert version", "error", err, "prompt_id", promptID)
@@ -252,13 +1004,16 @@ func (s *SQLiteStore) CreatePromptVersion(slug string, input models.CreatePrompt
 
 	// Update prompt's current_version and updated_at
 	_, err = tx.Exec(
-		`UPDATE prompts SET current_version = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
-		newVersionNumber, promptID,
+		`UPDATE prompts SET current_version = ?, updated_at = CURRENT_TIMESTAMP, updated_by = ? WHERE id = ?`,
+		newCurrent, s.actor, promptID,
 	)
 	if err != nil {
 		s.logger.Error("failed to update prompt", "error", err, "prompt_id", promptID)
 		return result, fmt.Errorf("failed to update prompt: %w", err)
 	}
+	if err := s.audit(tx, promptID, auditVersionCreated, newVersionNumber, ""); err != nil {
+		return result, err
+	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
@@ -271,231 +1026,2011 @@ func (s *SQLiteStore) CreatePromptVersion(slug string, input models.CreatePrompt
 		Slug:        slug,
 		Title:       title,
 		Description: description,
+		Format:      format,
+		Public:      public,
 		CurrentVersion: models.PromptVersion{
 			ID:            versionID,
 			PromptID:      promptID,
 			VersionNumber: newVersionNumber,
-			Content:       input.Content,
+			Content:       content,
+			ContentSHA256: contentSHA256(content),
+			CreatedBy:     s.actor,
+			Messages:      messages,
+			ModelConfig:   storedModelConfig(modelConfig, input.ModelConfig),
+			ReviewStatus:  reviewStatus,
 		},
+		Variables:       variables,
+		UpdatedBy:       s.actor,
+		PreviousVersion: currentVersion,
+	}
+	if metadata != "" {
+		result.CurrentVersion.Metadata = input.Metadata
+	}
+	if result.Metadata, err = decodeMetadata(promptMetadata); err != nil {
+		return result, err
+	}
+	if reviewStatus == ReviewPending {
+		pending := result.CurrentVersion
+		if result, err = s.getPromptBySlug(slug); err != nil {
+			return result, err
+		}
+		result.PendingVersion = &pending
 	}
 
 	duration := time.Since(start)
+	s.observe("CreatePromptVersion", duration)
 	s.logger.Info("database operation",
 		"operation", "CreatePromptVersion",
 		"slug", slug,
 		"version", newVersionNumber,
+		"pending", reviewStatus == ReviewPending,
 		"duration_ms", duration.Milliseconds(),
 	)
 	return result, nil
 }
 
-// GetPromptBySlug retrieves a prompt with its current version
-func (s *SQLiteStore) GetPromptBySlug(slug string) (models.PromptWithCurrentVersion, error) {
-	start := time.Now()
-	var result models.PromptWithCurrentVersion
-
-	// Get prompt with current version in a single query
-	err := s.db.QueryRow(`
-		SELECT
-			p.slug, p.title, p.description,
-			pv.id, pv.prompt_id, pv.version_number, pv.content, pv.created_at
-		FROM prompts p
-		JOIN prompt_versions pv ON p.id = pv.prompt_id AND pv.version_number = p.current_version
-		WHERE p.slug = ?
-	`, slug).Scan(
-		&result.Slug, &result.Title, &result.Description,
-		&result.CurrentVersion.ID, &result.CurrentVersion.PromptID,
-		&result.CurrentVersion.VersionNumber, &result.CurrentVersion.Content,
-		&result.CurrentVersion.CreatedAt,
-	)
-
+// currentVersionMatches reports whether a prompt's current version has the
+// given content, compared by hash, and model config. Metadata isn't compared,
+// so re-pushing a prompt with a new commit or build number still matches.
+func (s *SQLiteStore) currentVersionMatches(tx *sql.Tx, promptID int64, currentVersion int, content, modelConfig string) (bool, error) {
+	var hash, currentModelConfig string
+	err := tx.QueryRow(
+		`SELECT content_sha256, model_config FROM prompt_versions WHERE prompt_id = ? AND version_number = ?`,
+		promptID, currentVersion,
+	).Scan(&hash, &currentModelConfig)
 	if err == sql.ErrNoRows {
-		return result, fmt.Errorf("prompt with slug %q not found", slug)
+		return false, nil
 	}
 	if err != nil {
-		s.logger.Error("failed to get prompt", "error", err, "slug", slug)
-		return result, fmt.Errorf("failed to get prompt: %w", err)
+		s.logger.Error("failed to get current version", "error", err, "prompt_id", promptID)
+		return false, fmt.Errorf("failed to get current version: %w", err)
 	}
-
-	duration := time.Since(start)
-	s.logger.Info("database operation",
-		"operation", "GetPromptBySlug",
-		"slug", slug,
-		"duration_ms", duration.Milliseconds(),
-	)
-	return result, nil
+	return hash == contentSHA256(content) && currentModelConfig == modelConfig, nil
 }
 
-// GetPromptVersion retrieves a specific version of a prompt
-func (s *SQLiteStore) GetPromptVersion(slug string, version int) (models.PromptVersion, error) {
-	start := time.Now()
-	var result models.PromptVersion
-
-	err := s.db.QueryRow(`
-		SELECT pv.id, pv.prompt_id, pv.version_number, pv.content, pv.created_at
-		FROM prompt_versions pv
-		JOIN prompts p ON p.id = pv.prompt_id
-		WHERE p.slug = ? AND pv.version_number = ?
-	`, slug, version).Scan(
-		&result.ID, &result.PromptID, &result.VersionNumber,
-		&result.Content, &result.CreatedAt,
-	)
+// ImportPromptVersions appends versions to an existing prompt in the given
+// order, all in one transaction. Supplied creation times are kept as
+// original_created_at; created_at records when the import happened.
+func (s *SQLiteStore) ImportPromptVersions(slug string, input models.ImportVersionsInput) ([]models.PromptVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	if err == sql.ErrNoRows {
-		return result, fmt.Errorf("version %d not found for prompt %q", version, slug)
+	start := time.Now()
+	if len(input.Versions) == 0 {
+		return nil, invalid("versions cannot be empty")
 	}
-	if err != nil {
-		s.logger.Error("failed to get version", "error", err, "slug", slug, "version", version)
-		return result, fmt.Errorf("failed to get version: %w", err)
+	if len(input.Versions) > MaxImportVersions {
+		return nil, invalid("too many versions: at most %d per batch", MaxImportVersions)
+	}
+	for i, version := range input.Versions {
+		if strings.TrimSpace(version.Content) == "" {
+			return nil, invalid("version %d in batch: content cannot be empty", i+1)
+		}
 	}
 
-	duration := time.Since(start)
-	s.logger.Info("database operation",
-		"operation", "GetPromptVersion",
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var promptID int64
+	var format, variablesData string
+	var reviewApprovals int
+	err = tx.QueryRow(
+		`SELECT id, format, variables, review_approvals FROM prompts WHERE project = ? AND slug = ?`, s.project, slug,
+	).Scan(&promptID, &format, &variablesData, &reviewApprovals)
+	if err == sql.ErrNoRows {
+		return nil, promptNotFound(slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to get prompt", "error", err, "slug", slug)
+		return nil, fmt.Errorf("failed to get prompt: %w", err)
+	}
+	// Imported versions would become current without review
+	if reviewApprovals > 0 {
+		return nil, conflict("prompt %q requires review of new versions; create them one at a time instead", slug)
+	}
+	// Number after pending versions left from an earlier review policy
+	var lastVersion int
+	if err := tx.QueryRow(
+		`SELECT COALESCE(MAX(version_number), 0) FROM prompt_versions WHERE prompt_id = ?`, promptID,
+	).Scan(&lastVersion); err != nil {
+		s.logger.Error("failed to get version number", "error", err, "prompt_id", promptID)
+		return nil, fmt.Errorf("failed to get version number: %w", err)
+	}
+
+	variables, err := decodeVariables(variablesData)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.PromptVersion, 0, len(input.Versions))
+	for i, version := range input.Versions {
+		var messages []models.Message
+		if format == render.FormatChat {
+			if messages, err = render.ParseMessages(version.Content); err != nil {
+				return nil, fmt.Errorf("version %d in batch: %w", i+1, withKind(ErrValidation, err))
+			}
+		}
+		if err := render.CheckDeclared(version.Content, variables); err != nil {
+			return nil, fmt.Errorf("version %d in batch: %w", i+1, withKind(ErrValidation, err))
+		}
+
+		result := models.PromptVersion{
+			PromptID:          promptID,
+			VersionNumber:     lastVersion + i + 1,
+			Content:           version.Content,
+			ContentSHA256:     contentSHA256(version.Content),
+			CreatedBy:         s.actor,
+			OriginalCreatedAt: version.CreatedAt,
+			Messages:          messages,
+		}
+		err := tx.QueryRow(`
+			INSERT INTO prompt_versions (prompt_id, version_number, content, content_sha256, original_created_at, created_by)
+			VALUES (?, ?, ?, ?, ?, ?)
+			RETURNING id, created_at
+		`, promptID, result.VersionNumber, result.Content, result.ContentSHA256, timestampValue(result.OriginalCreatedAt), s.actor,
+		).Scan(&result.ID, &result.CreatedAt)
+		if err != nil {
+			s.logger.Error("failed to insert version", "error", err, "prompt_id", promptID)
+			return nil, fmt.Errorf("failed to insert version: %w", err)
+		}
+		results = append(results, result)
+	}
+
+	newCurrent := lastVersion + len(results)
+	if _, err := tx.Exec(
+		`UPDATE prompts SET current_version = ?, updated_at = CURRENT_TIMESTAMP, updated_by = ? WHERE id = ?`,
+		newCurrent, s.actor, promptID,
+	); err != nil {
+		s.logger.Error("failed to update prompt", "error", err, "prompt_id", promptID)
+		return nil, fmt.Errorf("failed to update prompt: %w", err)
+	}
+	detail := fmt.Sprintf("%d versions", len(results))
+	if err := s.audit(tx, promptID, auditVersionsImported, newCurrent, detail); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("ImportPromptVersions", duration)
+	s.logger.Info("database operation",
+		"operation", "ImportPromptVersions",
 		"slug", slug,
-		"version", version,
+		"versions", len(results),
+		"current_version", newCurrent,
 		"duration_ms", duration.Milliseconds(),
 	)
-	return result, nil
+	return results, nil
 }
 
-// ListPrompts retrieves prompts ordered by created_at DESC
-func (s *SQLiteStore) ListPrompts(limit, offset int) ([]models.PromptSummary, error) {
+// ImportPrompts creates prompts from another registry's export, each with its
+// full version history, in one transaction: an invalid prompt rejects the
+// whole import. Prompts whose slug already exists are skipped and left
+// untouched. Version numbers are kept, and the source's creation times are
+// stored as original_created_at so imported prompts and versions keep their
+// history ordering; created_at records when the import happened.
+func (s *SQLiteStore) ImportPrompts(prompts []models.ExportedPrompt) (models.ImportResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	start := time.Now()
-	rows, err := s.db.Query(`
-		SELECT slug, title, description, current_version, created_at, updated_at
-		FROM prompts
-		ORDER BY created_at DESC
-		LIMIT ? OFFSET ?
-	`, limit, offset)
+	result := models.ImportResult{Imported: []string{}, Skipped: []string{}}
+
+	tx, err := s.db.Begin()
 	if err != nil {
-		s.logger.Error("failed to list prompts", "error", err)
-		return nil, fmt.Errorf("failed to list prompts: %w", err)
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var results []models.PromptSummary
-	for rows.Next() {
-		var summary models.PromptSummary
-		err := rows.Scan(
-			&summary.Slug, &summary.Title, &summary.Description,
-			&summary.CurrentVersion, &summary.CreatedAt, &summary.UpdatedAt,
-		)
+	for _, prompt := range prompts {
+		if err := validateImportedPrompt(prompt); err != nil {
+			return result, err
+		}
+
+		var exists bool
+		err := tx.QueryRow(
+			`SELECT EXISTS (SELECT 1 FROM prompts WHERE project = ? AND slug = ?)`, s.project, prompt.Slug,
+		).Scan(&exists)
 		if err != nil {
-			s.logger.Error("failed to scan prompt", "error", err)
-			return nil, fmt.Errorf("failed to scan prompt: %w", err)
+			s.logger.Error("failed to check prompt", "error", err, "slug", prompt.Slug)
+			return result, fmt.Errorf("failed to check prompt: %w", err)
+		}
+		if exists {
+			result.Skipped = append(result.Skipped, prompt.Slug)
+			continue
 		}
-		results = append(results, summary)
-	}
 
-	if err := rows.Err(); err != nil {
-		s.logger.Error("failed to iterate prompts", "error", err)
-		return nil, fmt.Errorf("failed to iterate prompts: %w", err)
+		if err := s.insertImportedPrompt(tx, prompt); err != nil {
+			return result, err
+		}
+		result.Imported = append(result.Imported, prompt.Slug)
+		result.Versions += len(prompt.Versions)
 	}
 
-	// Return empty slice instead of nil
-	if results == nil {
-		results = []models.PromptSummary{}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	duration := time.Since(start)
+	s.observe("ImportPrompts", duration)
 	s.logger.Info("database operation",
-		"operation", "ListPrompts",
-		"limit", limit,
-		"offset", offset,
-		"rows_returned", len(results),
+		"operation", "ImportPrompts",
+		"imported", len(result.Imported),
+		"skipped", len(result.Skipped),
+		"versions", result.Versions,
 		"duration_ms", duration.Milliseconds(),
 	)
-	return results, nil
+	return result, nil
 }
 
-// ListPromptVersions retrieves all versions for a prompt
-func (s *SQLiteStore) ListPromptVersions(slug string) ([]models.PromptVersion, error) {
-	start := time.Now()
-	// First verify the prompt exists
+// validateImportedPrompt applies the rules CreatePrompt and
+// SetPromptVariables enforce. Only the current version has to match the
+// variable schema.
+func validateImportedPrompt(prompt models.ExportedPrompt) error {
+	if strings.TrimSpace(prompt.Slug) == "" {
+		return invalid("slug cannot be empty")
+	}
+	if strings.TrimSpace(prompt.Title) == "" {
+		return invalid("prompt %q: title cannot be empty", prompt.Slug)
+	}
+	if len(prompt.Versions) == 0 {
+		return invalid("prompt %q: versions cannot be empty", prompt.Slug)
+	}
+	if len(prompt.Versions) > MaxImportVersions {
+		return invalid("prompt %q: too many versions: at most %d per prompt", prompt.Slug, MaxImportVersions)
+	}
+	for i, version := range prompt.Versions {
+		if strings.TrimSpace(version.Content) == "" {
+			return invalid("prompt %q version %d: content cannot be empty", prompt.Slug, version.VersionNumber)
+		}
+		if version.VersionNumber < 1 || (i > 0 && version.VersionNumber <= prompt.Versions[i-1].VersionNumber) {
+			return invalid("prompt %q: invalid version numbers: must be positive and ascending", prompt.Slug)
+		}
+	}
+	if err := render.ValidateSchema(prompt.Variables); err != nil {
+		return fmt.Errorf("prompt %q: %w", prompt.Slug, withKind(ErrValidation, err))
+	}
+	format, err := render.ValidateFormat(prompt.Format)
+	if err != nil {
+		return fmt.Errorf("prompt %q: %w", prompt.Slug, withKind(ErrValidation, err))
+	}
+	current := prompt.Versions[len(prompt.Versions)-1]
+	if format == render.FormatChat {
+		if _, err := render.ParseMessages(current.Content); err != nil {
+			return fmt.Errorf("prompt %q: %w", prompt.Slug, withKind(ErrValidation, err))
+		}
+	}
+	if err := render.CheckDeclared(current.Content, prompt.Variables); err != nil {
+		return fmt.Errorf("prompt %q: %w", prompt.Slug, withKind(ErrValidation, err))
+	}
+	return nil
+}
+
+// insertImportedPrompt writes a validated prompt and its versions within tx
+func (s *SQLiteStore) insertImportedPrompt(tx *sql.Tx, prompt models.ExportedPrompt) error {
+	variables, err := encodeVariables(prompt.Variables)
+	if err != nil {
+		return err
+	}
+	var execution models.ExecutionConfig
+	if prompt.Execution != nil {
+		execution = *prompt.Execution
+	}
+	current := prompt.Versions[len(prompt.Versions)-1]
+
+	format, err := render.ValidateFormat(prompt.Format)
+	if err != nil {
+		return withKind(ErrValidation, err)
+	}
+	metadata, err := encodeMetadata(prompt.Metadata)
+	if err != nil {
+		return err
+	}
+
 	var promptID int64
-	err := s.db.QueryRow(`SELECT id FROM prompts WHERE slug = ?`, slug).Scan(&promptID)
+	err = tx.QueryRow(`
+		INSERT INTO prompts (project, slug, title, description, format, public, variables, metadata, exec_provider, exec_model, current_version, original_created_at, archived_at, created_by, updated_by, owner)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, s.project, prompt.Slug, prompt.Title, prompt.Description, format, prompt.Public, variables, metadata,
+		execution.Provider, execution.Model, current.VersionNumber,
+		timestampValue(originalCreatedAt(prompt.OriginalCreatedAt, prompt.CreatedAt)),
+		timestampValue(prompt.ArchivedAt), s.actor, s.actor, s.actor,
+	).Scan(&promptID)
+	if err != nil {
+		s.logger.Error("failed to insert prompt", "error", err, "slug", prompt.Slug)
+		return fmt.Errorf("failed to insert prompt: %w", err)
+	}
+
+	for _, version := range prompt.Versions {
+		modelConfig, err := encodeModelConfig(version.ModelConfig)
+		if err != nil {
+			return fmt.Errorf("prompt %q version %d: %w", prompt.Slug, version.VersionNumber, err)
+		}
+		versionMetadata, err := encodeMetadata(version.Metadata)
+		if err != nil {
+			return fmt.Errorf("prompt %q version %d: %w", prompt.Slug, version.VersionNumber, err)
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO prompt_versions (prompt_id, version_number, content, content_sha256, model_config, metadata, original_created_at, created_by, pinned)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, promptID, version.VersionNumber, version.Content, contentSHA256(version.Content), modelConfig, versionMetadata,
+			timestampValue(originalCreatedAt(version.OriginalCreatedAt, version.CreatedAt)), s.actor, version.Pinned,
+		); err != nil {
+			s.logger.Error("failed to insert version", "error", err, "prompt_id", promptID)
+			return fmt.Errorf("failed to insert version: %w", err)
+		}
+	}
+	if prompt.Docs != "" {
+		if err := s.insertDocs(tx, promptID, 1, prompt.Docs); err != nil {
+			return err
+		}
+	}
+	return s.audit(tx, promptID, auditPromptImported, current.VersionNumber, fmt.Sprintf("%d versions", len(prompt.Versions)))
+}
+
+// originalCreatedAt picks the creation time to keep for an exported record:
+// the time it was first created if it was itself imported, else its
+// created_at in the source registry
+func originalCreatedAt(original *time.Time, createdAt time.Time) *time.Time {
+	if original != nil {
+		return original
+	}
+	if createdAt.IsZero() {
+		return nil
+	}
+	return &createdAt
+}
+
+// timestampValue formats a supplied time like the CURRENT_TIMESTAMP values
+// SQLite writes for created_at, in UTC and without a zone suffix, so the two
+// compare correctly in ORDER BY COALESCE(original_created_at, created_at)
+func timestampValue(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format("2006-01-02 15:04:05.999999999")
+}
+
+// GetPromptBySlug retrieves a prompt with its current version
+func (s *SQLiteStore) GetPromptBySlug(slug string) (models.PromptWithCurrentVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.getPromptBySlug(slug)
+}
+
+// getPromptBySlug is GetPromptBySlug for callers already holding the lock
+func (s *SQLiteStore) getPromptBySlug(slug string) (models.PromptWithCurrentVersion, error) {
+	start := time.Now()
+
+	// Get prompt with current version in a single query
+	result, err := scanPromptWithVersion(s.db.QueryRow(
+		promptWithVersionSelect+` WHERE p.project = ? AND p.slug = ?`, s.project, slug))
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("prompt with slug %q not found", slug)
+		return result, promptNotFound(slug)
 	}
 	if err != nil {
 		s.logger.Error("failed to get prompt", "error", err, "slug", slug)
-		return nil, fmt.Errorf("failed to get prompt: %w", err)
+		return result, fmt.Errorf("failed to get prompt: %w", err)
 	}
 
-	// Get all versions
-	rows, err := s.db.Query(`
-		SELECT id, prompt_id, version_number, content, created_at
-		FROM prompt_versions
-		WHERE prompt_id = ?
-		ORDER BY version_number ASC
-	`, promptID)
+	duration := time.Since(start)
+	s.observe("GetPromptBySlug", duration)
+	s.logger.Info("database operation",
+		"operation", "GetPromptBySlug",
+		"slug", slug,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// GetPromptsBySlugs retrieves several prompts with their current versions in
+// one query, in the order of slugs. Prompts that don't exist or that the
+// caller can't read are left out.
+func (s *SQLiteStore) GetPromptsBySlugs(slugs []string) ([]models.PromptWithCurrentVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	if len(slugs) == 0 {
+		return []models.PromptWithCurrentVersion{}, nil
+	}
+	args := []any{s.project}
+	for _, slug := range slugs {
+		args = append(args, slug)
+	}
+	rows, err := s.db.Query(promptWithVersionSelect+`
+		WHERE p.project = ? AND p.slug IN (?`+strings.Repeat(", ?", len(slugs)-1)+`) AND `+readableByCaller,
+		append(args, s.readableArgs()...)...)
 	if err != nil {
-		s.logger.Error("failed to list versions", "error", err, "slug", slug)
-		return nil, fmt.Errorf("failed to list versions: %w", err)
+		s.logger.Error("failed to get prompts", "error", err, "slugs", len(slugs))
+		return nil, fmt.Errorf("failed to get prompts: %w", err)
 	}
 	defer rows.Close()
 
-	var results []models.PromptVersion
+	found := make(map[string]models.PromptWithCurrentVersion, len(slugs))
 	for rows.Next() {
-		var version models.PromptVersion
-		err := rows.Scan(
-			&version.ID, &version.PromptID, &version.VersionNumber,
-			&version.Content, &version.CreatedAt,
-		)
+		prompt, err := scanPromptWithVersion(rows)
 		if err != nil {
-			s.logger.Error("failed to scan version", "error", err)
-			return nil, fmt.Errorf("failed to scan version: %w", err)
+			s.logger.Error("failed to scan prompt", "error", err)
+			return nil, fmt.Errorf("failed to scan prompt: %w", err)
 		}
-		results = append(results, version)
+		found[prompt.Slug] = prompt
 	}
-
 	if err := rows.Err(); err != nil {
-		s.logger.Error("failed to iterate versions", "error", err)
-		return nil, fmt.Errorf("failed to iterate versions: %w", err)
+		return nil, fmt.Errorf("failed to get prompts: %w", err)
+	}
+
+	results := make([]models.PromptWithCurrentVersion, 0, len(found))
+	for _, slug := range slugs {
+		if prompt, ok := found[slug]; ok {
+			results = append(results, prompt)
+			delete(found, slug)
+		}
 	}
 
 	duration := time.Since(start)
+	s.observe("GetPromptsBySlugs", duration)
 	s.logger.Info("database operation",
-		"operation", "ListPromptVersions",
-		"slug", slug,
+		"operation", "GetPromptsBySlugs",
+		"requested", len(slugs),
 		"rows_returned", len(results),
 		"duration_ms", duration.Milliseconds(),
 	)
 	return results, nil
 }
 
-// GetStats retrieves system-wide statistics
-func (s *SQLiteStore) GetStats() (models.Stats, error) {
+// promptWithVersionSelect selects prompts p with their current version pv,
+// for scanPromptWithVersion. Callers append the WHERE clause.
+const promptWithVersionSelect = `
+	SELECT
+		p.slug, p.title, p.description, p.format, p.public, p.variables, p.exec_provider, p.exec_model,
+		p.created_at, p.updated_at, p.original_created_at, p.archived_at, p.created_by, p.updated_by,
+		p.legal_hold_at, p.legal_hold_reason, p.owner,
+		(SELECT COALESCE(MAX(d.revision), 0) FROM prompt_docs d WHERE d.prompt_id = p.id),
+		f.slug, p.forked_from_version, p.metadata,
+		` + versionColumns + `
+	FROM prompts p
+	JOIN prompt_versions pv ON p.id = pv.prompt_id AND pv.version_number = p.current_version
+	LEFT JOIN prompts f ON f.id = p.forked_from_id`
+
+// scanPromptWithVersion scans a row selected by promptWithVersionSelect
+func scanPromptWithVersion(row interface{ Scan(...any) error }) (models.PromptWithCurrentVersion, error) {
+	var result models.PromptWithCurrentVersion
+	var variablesData, metadata string
+	var execution models.ExecutionConfig
+	var forkedFrom *string
+	var forkedFromVersion *int
+
+	err := row.Scan(append([]any{
+		&result.Slug, &result.Title, &result.Description, &result.Format, &result.Public, &variablesData,
+		&execution.Provider, &execution.Model,
+		&result.CreatedAt, &result.UpdatedAt, &result.OriginalCreatedAt, &result.ArchivedAt,
+		&result.CreatedBy, &result.UpdatedBy, &result.LegalHoldAt, &result.LegalHoldReason,
+		&result.Owner, &result.DocsRevision, &forkedFrom, &forkedFromVersion, &metadata,
+	}, versionFields(&result.CurrentVersion)...)...)
+	if err != nil {
+		return result, err
+	}
+	if result.Variables, err = decodeVariables(variablesData); err != nil {
+		return result, err
+	}
+	if result.Metadata, err = decodeMetadata(metadata); err != nil {
+		return result, err
+	}
+	if execution != (models.ExecutionConfig{}) {
+		result.Execution = &execution
+	}
+	if forkedFrom != nil && forkedFromVersion != nil {
+		result.ForkedFrom = &models.ForkOrigin{Slug: *forkedFrom, Version: *forkedFromVersion}
+	}
+	return result, nil
+}
+
+// GetPromptVersion retrieves a specific version of a prompt
+func (s *SQLiteStore) GetPromptVersion(slug string, version int) (models.PromptVersion, error) {
+	return s.getPromptVersion("GetPromptVersion", slug, version, false)
+}
+
+// GetPublicPromptVersion retrieves a specific version of a prompt for the
+// public gallery, reporting versions pending review as not found
+func (s *SQLiteStore) GetPublicPromptVersion(slug string, version int) (models.PromptVersion, error) {
+	return s.getPromptVersion("GetPublicPromptVersion", slug, version, true)
+}
+
+// getPromptVersion retrieves a specific version of a prompt, reporting
+// versions pending review as not found when published is set
+func (s *SQLiteStore) getPromptVersion(operation, slug string, version int, published bool) (models.PromptVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	start := time.Now()
-	var stats models.Stats
+	var result models.PromptVersion
 
-	// Get total prompts
-	err := s.db.QueryRow(`SELECT COUNT(*) FROM prompts`).Scan(&stats.TotalPrompts)
+	err := s.db.QueryRow(`
+		SELECT `+versionColumns+`
+		FROM prompt_versions pv
+		JOIN prompts p ON p.id = pv.prompt_id
+		WHERE p.project = ? AND p.slug = ? AND pv.version_number = ? AND (NOT ? OR `+notPendingReview+`)
+	`, s.project, slug, version, published).Scan(versionFields(&result)...)
+
+	if err == sql.ErrNoRows {
+		return result, versionNotFound(slug, version)
+	}
 	if err != nil {
-		s.logger.Error("failed to count prompts", "error", err)
-		return stats, fmt.Errorf("failed to count prompts: %w", err)
+		s.logger.Error("failed to get version", "error", err, "slug", slug, "version", version)
+		return result, fmt.Errorf("failed to get version: %w", err)
 	}
 
-	// Get total versions
-	err = s.db.QueryRow(`SELECT COUNT(*) FROM prompt_versions`).Scan(&stats.TotalPromptVersions)
+	duration := time.Since(start)
+	s.observe(operation, duration)
+	s.logger.Info("database operation",
+		"operation", operation,
+		"slug", slug,
+		"version", version,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// GetLatestPromptVersion retrieves a prompt's highest-numbered version. That
+// is its current version unless something moved current_version back or the
+// version is pending review.
+func (s *SQLiteStore) GetLatestPromptVersion(slug string) (models.PromptVersion, error) {
+	return s.latestPromptVersion("GetLatestPromptVersion", slug, false)
+}
+
+// GetLatestPublicPromptVersion retrieves a prompt's highest-numbered version
+// that isn't pending review, for the public gallery
+func (s *SQLiteStore) GetLatestPublicPromptVersion(slug string) (models.PromptVersion, error) {
+	return s.latestPromptVersion("GetLatestPublicPromptVersion", slug, true)
+}
+
+// notPendingReview matches versions that aren't waiting for review approvals
+const notPendingReview = "pv.review_status != '" + ReviewPending + "'"
+
+// latestPromptVersion retrieves a prompt's highest-numbered version, leaving
+// out versions pending review when published is set
+func (s *SQLiteStore) latestPromptVersion(operation, slug string, published bool) (models.PromptVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.PromptVersion
+
+	err := s.db.QueryRow(`
+		SELECT `+versionColumns+`
+		FROM prompt_versions pv
+		JOIN prompts p ON p.id = pv.prompt_id
+		WHERE p.project = ? AND p.slug = ? AND (NOT ? OR `+notPendingReview+`)
+		ORDER BY pv.version_number DESC
+		LIMIT 1
+	`, s.project, slug, published).Scan(versionFields(&result)...)
+
+	if err == sql.ErrNoRows {
+		return result, promptNotFound(slug)
+	}
 	if err != nil {
-		s.logger.Error("failed to count versions", "error", err)
-		return stats, fmt.Errorf("failed to count versions: %w", err)
+		s.logger.Error("failed to get version", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to get version: %w", err)
 	}
 
 	duration := time.Since(start)
+	s.observe(operation, duration)
 	s.logger.Info("database operation",
-		"operation", "GetStats",
-		"total_prompts", stats.TotalPrompts,
-		"total_versions", stats.TotalPromptVersions,
+		"operation", operation,
+		"slug", slug,
+		"version", result.VersionNumber,
 		"duration_ms", duration.Milliseconds(),
 	)
-	return stats, nil
+	return result, nil
 }
 
-// Close closes the database connection
-func (s *SQLiteStore) Close() error {
+// ListPrompts retrieves prompts that aren't archived and match filter, in the
+// given order, newest first by default. Imported prompts sort by their
+// original creation time.
+func (s *SQLiteStore) ListPrompts(limit, offset int, sort PromptSort, filter PromptFilter) ([]models.PromptSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	orderBy, err := sort.orderBy()
+	if err != nil {
+		return nil, err
+	}
+	where, filterArgs, err := filter.where(s.actor)
+	if err != nil {
+		return nil, err
+	}
+	args := append(append([]any{s.project}, s.readableArgs()...), filterArgs...)
+	results, err := s.queryPromptSummaries(`
+		SELECT slug, title, description, public, current_version, created_at, updated_at, original_created_at, archived_at
+		FROM prompts p
+		WHERE project = ? AND archived_at IS NULL AND `+readableByCaller+where+`
+		ORDER BY `+orderBy+`
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Since(start)
+	s.observe("ListPrompts", duration)
+	s.logger.Info("database operation",
+		"operation", "ListPrompts",
+		"limit", limit,
+		"offset", offset,
+		"sort", sort.String(),
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// ListAllPrompts retrieves prompts like ListPrompts, including archived ones
+func (s *SQLiteStore) ListAllPrompts(limit, offset int, sort PromptSort, filter PromptFilter) ([]models.PromptSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	orderBy, err := sort.orderBy()
+	if err != nil {
+		return nil, err
+	}
+	where, filterArgs, err := filter.where(s.actor)
+	if err != nil {
+		return nil, err
+	}
+	args := append(append([]any{s.project}, s.readableArgs()...), filterArgs...)
+	results, err := s.queryPromptSummaries(`
+		SELECT slug, title, description, public, current_version, created_at, updated_at, original_created_at, archived_at
+		FROM prompts p
+		WHERE project = ? AND `+readableByCaller+where+`
+		ORDER BY `+orderBy+`
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Since(start)
+	s.observe("ListAllPrompts", duration)
+	s.logger.Info("database operation",
+		"operation", "ListAllPrompts",
+		"limit", limit,
+		"offset", offset,
+		"sort", sort.String(),
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// ListPublicPrompts retrieves public prompts that aren't archived, ordered like ListPrompts
+func (s *SQLiteStore) ListPublicPrompts(limit, offset int) ([]models.PromptSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	results, err := s.queryPromptSummaries(`
+		SELECT slug, title, description, public, current_version, created_at, updated_at, original_created_at, archived_at
+		FROM prompts
+		WHERE project = ? AND public = 1 AND archived_at IS NULL
+		ORDER BY COALESCE(original_created_at, created_at) DESC
+		LIMIT ? OFFSET ?
+	`, s.project, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Since(start)
+	s.observe("ListPublicPrompts", duration)
+	s.logger.Info("database operation",
+		"operation", "ListPublicPrompts",
+		"limit", limit,
+		"offset", offset,
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// CountPrompts returns how many prompts ListPrompts, or ListAllPrompts when
+// includeArchived is set, would return for filter without a limit
+func (s *SQLiteStore) CountPrompts(includeArchived bool, filter PromptFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	where, filterArgs, err := filter.where(s.actor)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = s.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM prompts p
+		WHERE project = ? AND (? OR archived_at IS NULL) AND `+readableByCaller+where,
+		append(append([]any{s.project, includeArchived}, s.readableArgs()...), filterArgs...)...,
+	).Scan(&count)
+	if err != nil {
+		s.logger.Error("failed to count prompts", "error", err)
+		return 0, fmt.Errorf("failed to count prompts: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("CountPrompts", duration)
+	s.logger.Info("database operation",
+		"operation", "CountPrompts",
+		"include_archived", includeArchived,
+		"count", count,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return count, nil
+}
+
+// CountPublicPrompts returns how many prompts ListPublicPrompts would return
+// without a limit
+func (s *SQLiteStore) CountPublicPrompts() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM prompts
+		WHERE project = ? AND public = 1 AND archived_at IS NULL
+	`, s.project).Scan(&count)
+	if err != nil {
+		s.logger.Error("failed to count public prompts", "error", err)
+		return 0, fmt.Errorf("failed to count public prompts: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("CountPublicPrompts", duration)
+	s.logger.Info("database operation",
+		"operation", "CountPublicPrompts",
+		"count", count,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return count, nil
+}
+
+// queryPromptSummaries runs a prompt listing query and scans the rows into summaries
+func (s *SQLiteStore) queryPromptSummaries(query string, args ...any) ([]models.PromptSummary, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		s.logger.Error("failed to list prompts", "error", err)
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.PromptSummary
+	for rows.Next() {
+		var summary models.PromptSummary
+		err := rows.Scan(
+			&summary.Slug, &summary.Title, &summary.Description, &summary.Public,
+			&summary.CurrentVersion, &summary.CreatedAt, &summary.UpdatedAt, &summary.OriginalCreatedAt,
+			&summary.ArchivedAt,
+		)
+		if err != nil {
+			s.logger.Error("failed to scan prompt", "error", err)
+			return nil, fmt.Errorf("failed to scan prompt: %w", err)
+		}
+		results = append(results, summary)
+	}
+
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate prompts", "error", err)
+		return nil, fmt.Errorf("failed to iterate prompts: %w", err)
+	}
+
+	// Return empty slice instead of nil
+	if results == nil {
+		results = []models.PromptSummary{}
+	}
+	return results, nil
+}
+
+// SetPromptVisibility marks a prompt as public or private
+func (s *SQLiteStore) SetPromptVisibility(slug string, public bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	visibility := "private"
+	if public {
+		visibility = "public"
+	}
+	err := s.updatePrompt(slug, auditVisibilityChanged, visibility,
+		`UPDATE prompts SET public = ?, updated_at = CURRENT_TIMESTAMP, updated_by = ? WHERE project = ? AND slug = ? RETURNING id`,
+		public, s.actor, s.project, slug,
+	)
+	if err != nil {
+		return err
+	}
+
+	duration := time.Since(start)
+	s.observe("SetPromptVisibility", duration)
+	s.logger.Info("database operation",
+		"operation", "SetPromptVisibility",
+		"slug", slug,
+		"public", public,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// SetPromptDescription replaces a prompt's Markdown description. An empty
+// description clears it.
+func (s *SQLiteStore) SetPromptDescription(slug, description string) (models.PromptWithCurrentVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	if err := validateDescription(description); err != nil {
+		return models.PromptWithCurrentVersion{}, err
+	}
+	err := s.updatePrompt(slug, auditDescriptionChanged, "",
+		`UPDATE prompts SET description = ?, updated_at = CURRENT_TIMESTAMP, updated_by = ? WHERE project = ? AND slug = ? RETURNING id`,
+		description, s.actor, s.project, slug,
+	)
+	if err != nil {
+		return models.PromptWithCurrentVersion{}, err
+	}
+
+	duration := time.Since(start)
+	s.observe("SetPromptDescription", duration)
+	s.logger.Info("database operation",
+		"operation", "SetPromptDescription",
+		"slug", slug,
+		"length", len(description),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return s.getPromptBySlug(slug)
+}
+
+// validateDescription rejects descriptions too short to be useful. Empty
+// descriptions are allowed.
+func validateDescription(description string) error {
+	if description != "" && len(strings.TrimSpace(description)) < 10 {
+		return invalidField("description", "invalid description: must be at least 10 characters when provided")
+	}
+	return nil
+}
+
+// SetPromptArchived archives or unarchives a prompt. Archived prompts are left
+// out of listings but can still be fetched by slug, so deployments pinned to
+// them keep working. Archiving an archived prompt keeps its original time.
+func (s *SQLiteStore) SetPromptArchived(slug string, archived bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	action := auditUnarchived
+	if archived {
+		action = auditArchived
+	}
+	err := s.updatePrompt(slug, action, "", `
+		UPDATE prompts
+		SET archived_at = CASE WHEN ? THEN COALESCE(archived_at, CURRENT_TIMESTAMP) ELSE NULL END,
+			updated_at = CURRENT_TIMESTAMP, updated_by = ?
+		WHERE project = ? AND slug = ?
+		RETURNING id
+	`, archived, s.actor, s.project, slug)
+	if err != nil {
+		return err
+	}
+
+	duration := time.Since(start)
+	s.observe("SetPromptArchived", duration)
+	s.logger.Info("database operation",
+		"operation", "SetPromptArchived",
+		"slug", slug,
+		"archived", archived,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// SetLegalHold places or releases a legal hold. While held, the prompt and
+// every version must survive deletion, purges, and retention pruning. Placing
+// a hold on a held prompt updates the reason but keeps the original time.
+func (s *SQLiteStore) SetLegalHold(slug string, held bool, reason string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reason = strings.TrimSpace(reason)
+	if held && reason == "" {
+		return invalidField("reason", "legal hold reason cannot be empty")
+	}
+	if !held {
+		reason = ""
+	}
+
+	start := time.Now()
+	action := auditLegalHoldReleased
+	if held {
+		action = auditLegalHoldPlaced
+	}
+	err := s.updatePrompt(slug, action, reason, `
+		UPDATE prompts
+		SET legal_hold_at = CASE WHEN ? THEN COALESCE(legal_hold_at, CURRENT_TIMESTAMP) ELSE NULL END,
+			legal_hold_reason = ?, updated_at = CURRENT_TIMESTAMP, updated_by = ?
+		WHERE project = ? AND slug = ?
+		RETURNING id
+	`, held, reason, s.actor, s.project, slug)
+	if err != nil {
+		return err
+	}
+
+	duration := time.Since(start)
+	s.observe("SetLegalHold", duration)
+	s.logger.Info("database operation",
+		"operation", "SetLegalHold",
+		"slug", slug,
+		"held", held,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// SetPromptVariables replaces a prompt's variable schema. The current version
+// must only use declared placeholders; an empty list removes the schema.
+func (s *SQLiteStore) SetPromptVariables(slug string, vars []models.Variable) (models.PromptWithCurrentVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.PromptWithCurrentVersion
+
+	if err := render.ValidateSchema(vars); err != nil {
+		return result, withField("variables", err)
+	}
+	variables, err := encodeVariables(vars)
+	if err != nil {
+		return result, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var promptID int64
+	var content string
+	err = tx.QueryRow(`
+		SELECT p.id, pv.content
+		FROM prompts p
+		JOIN prompt_versions pv ON p.id = pv.prompt_id AND pv.version_number = p.current_version
+		WHERE p.project = ? AND p.slug = ?
+	`, s.project, slug).Scan(&promptID, &content)
+	if err == sql.ErrNoRows {
+		return result, promptNotFound(slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to get prompt", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to get prompt: %w", err)
+	}
+	if err := render.CheckDeclared(content, vars); err != nil {
+		return result, withKind(ErrValidation, err)
+	}
+
+	if _, err := tx.Exec(
+		`UPDATE prompts SET variables = ?, updated_at = CURRENT_TIMESTAMP, updated_by = ? WHERE id = ?`,
+		variables, s.actor, promptID,
+	); err != nil {
+		s.logger.Error("failed to update variables", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to update variables: %w", err)
+	}
+	if err := s.audit(tx, promptID, auditVariablesChanged, 0, ""); err != nil {
+		return result, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("SetPromptVariables", duration)
+	s.logger.Info("database operation",
+		"operation", "SetPromptVariables",
+		"slug", slug,
+		"variables", len(vars),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return s.getPromptBySlug(slug)
+}
+
+// SetPromptExecution sets the provider and model a prompt executes on by default.
+// Empty fields fall back to the server defaults.
+func (s *SQLiteStore) SetPromptExecution(slug string, config models.ExecutionConfig) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	detail := strings.Trim(config.Provider+"/"+config.Model, "/")
+	err := s.updatePrompt(slug, auditExecutionChanged, detail,
+		`UPDATE prompts SET exec_provider = ?, exec_model = ?, updated_at = CURRENT_TIMESTAMP, updated_by = ? WHERE project = ? AND slug = ? RETURNING id`,
+		config.Provider, config.Model, s.actor, s.project, slug,
+	)
+	if err != nil {
+		return err
+	}
+
+	duration := time.Since(start)
+	s.observe("SetPromptExecution", duration)
+	s.logger.Info("database operation",
+		"operation", "SetPromptExecution",
+		"slug", slug,
+		"provider", config.Provider,
+		"model", config.Model,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// versionColumns selects a version from prompt_versions aliased as pv, in
+// the order versionFields scans them, followed by its prompt's format
+const versionColumns = `pv.id, pv.prompt_id, pv.version_number, pv.content, pv.created_at,
+	pv.original_created_at, pv.created_by, pv.pinned, pv.redacted_at, pv.original_sha256, pv.content_sha256, pv.model_config, pv.metadata,
+	pv.review_status, (SELECT pf.format FROM prompts pf WHERE pf.id = pv.prompt_id)`
+
+// versionFields returns scan destinations for versionColumns
+func versionFields(v *models.PromptVersion) []any {
+	return []any{
+		&v.ID, &v.PromptID, &v.VersionNumber, &v.Content, &v.CreatedAt,
+		&v.OriginalCreatedAt, &v.CreatedBy, &v.Pinned, &v.RedactedAt, &v.OriginalSHA256, &v.ContentSHA256,
+		modelConfigField{v}, metadataField{v}, &v.ReviewStatus, versionFormat{v},
+	}
+}
+
+// versionFormat scans the prompt format that ends versionColumns, after the
+// rest of the version, and decodes a chat version's messages from its
+// content. Redacted versions hold a plain-text notice and get no messages.
+type versionFormat struct {
+	v *models.PromptVersion
+}
+
+func (f versionFormat) Scan(src any) error {
+	f.v.Messages = nil
+	var format string
+	switch src := src.(type) {
+	case string:
+		format = src
+	case []byte:
+		format = string(src)
+	}
+	if format == render.FormatChat && f.v.RedactedAt == nil {
+		// Content was validated on write, so a decode failure leaves it raw
+		f.v.Messages, _ = render.ParseMessages(f.v.Content)
+	}
+	return nil
+}
+
+// versionContent returns the content to store for a new version of a prompt
+// in format, with its messages for a chat prompt. Text content is kept as
+// given. Chat messages can be given decoded or as their JSON in content, and
+// are stored in one encoding either way.
+func versionContent(format, content string, messages []models.Message) (string, []models.Message, error) {
+	if format != render.FormatChat {
+		if len(messages) > 0 {
+			return "", nil, invalidField("messages", "invalid messages: only chat prompts have messages")
+		}
+		if strings.TrimSpace(content) == "" {
+			return "", nil, invalidField("content", "content cannot be empty")
+		}
+		return content, nil, nil
+	}
+	if len(messages) > 0 && content != "" {
+		return "", nil, invalidField("messages", "invalid messages: set either content or messages, not both")
+	}
+	if len(messages) == 0 {
+		if strings.TrimSpace(content) == "" {
+			return "", nil, invalidField("messages", "messages cannot be empty")
+		}
+		var err error
+		if messages, err = render.ParseMessages(content); err != nil {
+			return "", nil, withField("content", err)
+		}
+	}
+	encoded, err := render.EncodeMessages(messages)
+	if err != nil {
+		return "", nil, withField("messages", err)
+	}
+	return encoded, messages, nil
+}
+
+// SetVersionPinned pins or unpins a version. Pinned versions are kept forever:
+// anything that prunes versions, such as a retention policy, must skip them.
+func (s *SQLiteStore) SetVersionPinned(slug string, version int, pinned bool) (models.PromptVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.PromptVersion
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		UPDATE prompt_versions SET pinned = ?
+		WHERE prompt_id = (SELECT id FROM prompts WHERE project = ? AND slug = ?) AND version_number = ?
+		RETURNING id, prompt_id, version_number, content, created_at, original_created_at, created_by, pinned,
+			redacted_at, original_sha256, content_sha256, model_config, metadata, review_status, (SELECT pf.format FROM prompts pf WHERE pf.id = prompt_id)`,
+		pinned, s.project, slug, version,
+	).Scan(versionFields(&result)...)
+	if err == sql.ErrNoRows {
+		return result, versionNotFound(slug, version)
+	}
+	if err != nil {
+		s.logger.Error("failed to update pin", "error", err, "slug", slug, "version", version)
+		return result, fmt.Errorf("failed to update pin: %w", err)
+	}
+	action := auditVersionUnpinned
+	if pinned {
+		action = auditVersionPinned
+	}
+	if err := s.audit(tx, result.PromptID, action, version, ""); err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("SetVersionPinned", duration)
+	s.logger.Info("database operation",
+		"operation", "SetVersionPinned",
+		"slug", slug,
+		"version", version,
+		"pinned", pinned,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// ListPromptVersions retrieves a page of a prompt's versions, oldest first.
+// A negative limit returns every version after offset.
+func (s *SQLiteStore) ListPromptVersions(slug string, limit, offset int) ([]models.PromptVersion, error) {
+	return s.listPromptVersions("ListPromptVersions", slug, limit, offset, false)
+}
+
+// ListPublicPromptVersions retrieves a page of a prompt's versions that
+// aren't pending review, oldest first, for the public gallery
+func (s *SQLiteStore) ListPublicPromptVersions(slug string, limit, offset int) ([]models.PromptVersion, error) {
+	return s.listPromptVersions("ListPublicPromptVersions", slug, limit, offset, true)
+}
+
+// listPromptVersions retrieves a page of a prompt's versions, leaving out
+// versions pending review when published is set
+func (s *SQLiteStore) listPromptVersions(operation, slug string, limit, offset int, published bool) ([]models.PromptVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	// First verify the prompt exists
+	promptID, err := s.promptID(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+versionColumns+`
+		FROM prompt_versions pv
+		WHERE prompt_id = ? AND (NOT ? OR `+notPendingReview+`)
+		ORDER BY version_number ASC
+		LIMIT ? OFFSET ?
+	`, promptID, published, limit, offset)
+	if err != nil {
+		s.logger.Error("failed to list versions", "error", err, "slug", slug)
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.PromptVersion
+	for rows.Next() {
+		var version models.PromptVersion
+		if err := rows.Scan(versionFields(&version)...); err != nil {
+			s.logger.Error("failed to scan version", "error", err)
+			return nil, fmt.Errorf("failed to scan version: %w", err)
+		}
+		results = append(results, version)
+	}
+
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate versions", "error", err)
+		return nil, fmt.Errorf("failed to iterate versions: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe(operation, duration)
+	s.logger.Info("database operation",
+		"operation", operation,
+		"slug", slug,
+		"limit", limit,
+		"offset", offset,
+		"rows_returned", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// CountPromptVersions returns how many versions a prompt has
+func (s *SQLiteStore) CountPromptVersions(slug string) (int, error) {
+	return s.countPromptVersions("CountPromptVersions", slug, false)
+}
+
+// CountPublicPromptVersions returns how many versions ListPublicPromptVersions
+// would return without a limit
+func (s *SQLiteStore) CountPublicPromptVersions(slug string) (int, error) {
+	return s.countPromptVersions("CountPublicPromptVersions", slug, true)
+}
+
+// countPromptVersions counts a prompt's versions, leaving out versions
+// pending review when published is set
+func (s *SQLiteStore) countPromptVersions(operation, slug string, published bool) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	promptID, err := s.promptID(slug)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM prompt_versions pv WHERE prompt_id = ? AND (NOT ? OR `+notPendingReview+`)`, promptID, published).Scan(&count); err != nil {
+		s.logger.Error("failed to count versions", "error", err, "slug", slug)
+		return 0, fmt.Errorf("failed to count versions: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe(operation, duration)
+	s.logger.Info("database operation",
+		"operation", operation,
+		"slug", slug,
+		"count", count,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return count, nil
+}
+
+// ExportPrompts calls fn with every prompt and its full version history,
+// oldest prompt first, and returns how many prompts were exported. Versions
+// are loaded one prompt at a time and no query is open while fn runs, so a
+// slow consumer doesn't hold the database. An error from fn stops the export.
+func (s *SQLiteStore) ExportPrompts(fn func(models.ExportedPrompt) error) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	rows, err := s.db.Query(`
+		SELECT id, slug, title, description, format, public, variables, exec_provider, exec_model,
+			created_at, updated_at, original_created_at, archived_at, `+latestDocs+`, metadata
+		FROM prompts p
+		WHERE project = ? AND `+readableByCaller+`
+		ORDER BY id ASC
+	`, append([]any{s.project}, s.readableArgs()...)...)
+	if err != nil {
+		s.logger.Error("failed to list prompts for export", "error", err)
+		return 0, fmt.Errorf("failed to list prompts: %w", err)
+	}
+
+	type exportRow struct {
+		id        int64
+		variables string
+		metadata  string
+		prompt    models.ExportedPrompt
+	}
+	var prompts []exportRow
+	for rows.Next() {
+		var row exportRow
+		var execution models.ExecutionConfig
+		if err := rows.Scan(
+			&row.id, &row.prompt.Slug, &row.prompt.Title, &row.prompt.Description, &row.prompt.Format, &row.prompt.Public,
+			&row.variables, &execution.Provider, &execution.Model,
+			&row.prompt.CreatedAt, &row.prompt.UpdatedAt, &row.prompt.OriginalCreatedAt, &row.prompt.ArchivedAt,
+			&row.prompt.Docs, &row.metadata,
+		); err != nil {
+			rows.Close()
+			s.logger.Error("failed to scan prompt", "error", err)
+			return 0, fmt.Errorf("failed to scan prompt: %w", err)
+		}
+		if execution != (models.ExecutionConfig{}) {
+			row.prompt.Execution = &execution
+		}
+		// Text is the default, so text prompts export as before
+		if row.prompt.Format == render.FormatText {
+			row.prompt.Format = ""
+		}
+		prompts = append(prompts, row)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		s.logger.Error("failed to iterate prompts", "error", err)
+		return 0, fmt.Errorf("failed to iterate prompts: %w", err)
+	}
+	rows.Close()
+
+	for i, row := range prompts {
+		prompt := row.prompt
+		if prompt.Variables, err = decodeVariables(row.variables); err != nil {
+			return i, err
+		}
+		if prompt.Metadata, err = decodeMetadata(row.metadata); err != nil {
+			return i, err
+		}
+		if prompt.Versions, err = s.exportVersions(row.id); err != nil {
+			return i, err
+		}
+		if err := fn(prompt); err != nil {
+			return i, err
+		}
+	}
+
+	duration := time.Since(start)
+	s.observe("ExportPrompts", duration)
+	s.logger.Info("database operation",
+		"operation", "ExportPrompts",
+		"prompts", len(prompts),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return len(prompts), nil
+}
+
+// exportVersions loads a prompt's versions, oldest first
+func (s *SQLiteStore) exportVersions(promptID int64) ([]models.ExportedVersion, error) {
+	rows, err := s.db.Query(`
+		SELECT version_number, content, created_at, original_created_at, pinned, model_config, metadata
+		FROM prompt_versions
+		WHERE prompt_id = ?
+		ORDER BY version_number ASC
+	`, promptID)
+	if err != nil {
+		s.logger.Error("failed to list versions for export", "error", err, "prompt_id", promptID)
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []models.ExportedVersion{}
+	for rows.Next() {
+		var version models.ExportedVersion
+		var scanned models.PromptVersion
+		if err := rows.Scan(&version.VersionNumber, &version.Content, &version.CreatedAt, &version.OriginalCreatedAt, &version.Pinned, modelConfigField{&scanned}, metadataField{&scanned}); err != nil {
+			s.logger.Error("failed to scan version", "error", err)
+			return nil, fmt.Errorf("failed to scan version: %w", err)
+		}
+		version.ModelConfig, version.Metadata = scanned.ModelConfig, scanned.Metadata
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate versions", "error", err)
+		return nil, fmt.Errorf("failed to iterate versions: %w", err)
+	}
+	return versions, nil
+}
+
+// GetStats retrieves system-wide statistics
+func (s *SQLiteStore) GetStats() (models.Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var stats models.Stats
+
+	// Get total prompts
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM prompts`).Scan(&stats.TotalPrompts)
+	if err != nil {
+		s.logger.Error("failed to count prompts", "error", err)
+		return stats, fmt.Errorf("failed to count prompts: %w", err)
+	}
+
+	// Get total versions
+	err = s.db.QueryRow(`SELECT COUNT(*) FROM prompt_versions`).Scan(&stats.TotalPromptVersions)
+	if err != nil {
+		s.logger.Error("failed to count versions", "error", err)
+		return stats, fmt.Errorf("failed to count versions: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("GetStats", duration)
+	s.logger.Info("database operation",
+		"operation", "GetStats",
+		"total_prompts", stats.TotalPrompts,
+		"total_versions", stats.TotalPromptVersions,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return stats, nil
+}
+
+// GetProjectStats counts the prompts in the store's project that the caller
+// can read, including archived ones, and their versions. GetStats counts the
+// whole registry.
+func (s *SQLiteStore) GetProjectStats() (models.Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var stats models.Stats
+	err := s.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(`+versionCount+`), 0)
+		FROM prompts p
+		WHERE p.project = ? AND `+readableByCaller,
+		append([]any{s.project}, s.readableArgs()...)...,
+	).Scan(&stats.TotalPrompts, &stats.TotalPromptVersions)
+	if err != nil {
+		s.logger.Error("failed to count prompts", "error", err)
+		return stats, fmt.Errorf("failed to count prompts: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("GetProjectStats", duration)
+	s.logger.Info("database operation",
+		"operation", "GetProjectStats",
+		"total_prompts", stats.TotalPrompts,
+		"total_versions", stats.TotalPromptVersions,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return stats, nil
+}
+
+// ListPromptWebhooks returns the webhooks registered on a prompt, oldest first
+func (s *SQLiteStore) ListPromptWebhooks(slug string) ([]models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	promptID, err := s.promptID(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(
+		`SELECT id, url, created_at FROM prompt_webhooks WHERE prompt_id = ? ORDER BY id`,
+		promptID,
+	)
+	if err != nil {
+		s.logger.Error("failed to list webhooks", "error", err, "slug", slug)
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.Webhook{}
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := rows.Scan(&webhook.ID, &webhook.URL, &webhook.CreatedAt); err != nil {
+			s.logger.Error("failed to scan webhook", "error", err)
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		results = append(results, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate webhooks", "error", err)
+		return nil, fmt.Errorf("failed to iterate webhooks: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("ListPromptWebhooks", duration)
+	s.logger.Info("database operation",
+		"operation", "ListPromptWebhooks",
+		"slug", slug,
+		"count", len(results),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return results, nil
+}
+
+// AddPromptWebhook registers a URL to be notified about changes to a prompt
+func (s *SQLiteStore) AddPromptWebhook(slug, url string) (models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.Webhook
+
+	if strings.TrimSpace(url) == "" {
+		return result, invalidField("url", "url cannot be empty")
+	}
+	promptID, err := s.promptID(slug)
+	if err != nil {
+		return result, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO prompt_webhooks (prompt_id, url) VALUES (?, ?) RETURNING id, url, created_at`,
+		promptID, url,
+	).Scan(&result.ID, &result.URL, &result.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return result, conflict("webhook %q already exists for prompt %q", url, slug)
+		}
+		s.logger.Error("failed to add webhook", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to add webhook: %w", err)
+	}
+	if err := s.audit(tx, promptID, auditWebhookAdded, 0, url); err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("AddPromptWebhook", duration)
+	s.logger.Info("database operation",
+		"operation", "AddPromptWebhook",
+		"slug", slug,
+		"webhook_id", result.ID,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// DeletePromptWebhook removes a webhook from a prompt
+func (s *SQLiteStore) DeletePromptWebhook(slug string, id int64) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var promptID int64
+	var url string
+	err = tx.QueryRow(`
+		DELETE FROM prompt_webhooks
+		WHERE id = ? AND prompt_id = (SELECT id FROM prompts WHERE project = ? AND slug = ?)
+		RETURNING prompt_id, url
+	`, id, s.project, slug).Scan(&promptID, &url)
+	if err == sql.ErrNoRows {
+		return notFound("webhook %d not found for prompt %q", id, slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to delete webhook", "error", err, "slug", slug)
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if err := s.audit(tx, promptID, auditWebhookDeleted, 0, url); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("DeletePromptWebhook", duration)
+	s.logger.Info("database operation",
+		"operation", "DeletePromptWebhook",
+		"slug", slug,
+		"webhook_id", id,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// updatePrompt runs query, an UPDATE on one prompt that returns its id, and
+// records action in the audit log in the same transaction
+func (s *SQLiteStore) updatePrompt(slug, action, detail, query string, args ...any) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var promptID int64
+	err = tx.QueryRow(query, args...).Scan(&promptID)
+	if err == sql.ErrNoRows {
+		return promptNotFound(slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to update prompt", "error", err, "slug", slug, "action", action)
+		return fmt.Errorf("failed to update prompt: %w", err)
+	}
+	if err := s.audit(tx, promptID, action, 0, detail); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// promptID looks up a prompt's primary key by slug
+func (s *SQLiteStore) promptID(slug string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`SELECT id FROM prompts WHERE project = ? AND slug = ?`, s.project, slug).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, promptNotFound(slug)
+	}
+	if err != nil {
+		s.logger.Error("failed to get prompt", "error", err, "slug", slug)
+		return 0, fmt.Errorf("failed to get prompt: %w", err)
+	}
+	return id, nil
+}
+
+// Compact rebuilds the database file with VACUUM to release free pages,
+// then refreshes query planner statistics with ANALYZE, and records the
+// space reclaimed in the admin audit log
+func (s *SQLiteStore) Compact() (models.CompactResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	var result models.CompactResult
+
+	before, err := s.databaseSize()
+	if err != nil {
+		return result, err
+	}
+
+	if _, err := s.db.Exec(`VACUUM`); err != nil {
+		s.logger.Error("failed to vacuum database", "error", err)
+		return result, fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	if _, err := s.db.Exec(`ANALYZE`); err != nil {
+		s.logger.Error("failed to analyze database", "error", err)
+		return result, fmt.Errorf("failed to analyze database: %w", err)
+	}
+
+	after, err := s.databaseSize()
+	if err != nil {
+		return result, err
+	}
+
+	duration := time.Since(start)
+	result = models.CompactResult{
+		SizeBeforeBytes: before,
+		SizeAfterBytes:  after,
+		ReclaimedBytes:  before - after,
+		DurationMs:      duration.Milliseconds(),
+	}
+	if err := s.adminAudit(s.db, auditAdminCompacted, "", "", fmt.Sprintf("reclaimed %d bytes", result.ReclaimedBytes)); err != nil {
+		return result, err
+	}
+	s.observe("Compact", duration)
+	s.logger.Info("database operation",
+		"operation", "Compact",
+		"size_before_bytes", before,
+		"size_after_bytes", after,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// Backup writes a consistent snapshot of the database to destPath with VACUUM
+// INTO while the registry stays online. destPath must not exist yet.
+func (s *SQLiteStore) Backup(destPath string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	if _, err := s.db.Exec(`VACUUM INTO ?`, destPath); err != nil {
+		s.logger.Error("failed to back up database", "error", err, "path", destPath)
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("Backup", duration)
+	s.logger.Info("database operation",
+		"operation", "Backup",
+		"path", destPath,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// databaseSize returns the size of the main database in bytes
+func (s *SQLiteStore) databaseSize() (int64, error) {
+	var pageCount, pageSize int64
+	if err := s.db.QueryRow(`PRAGMA page_count`).Scan(&pageCount); err != nil {
+		return 0, fmt.Errorf("failed to read page count: %w", err)
+	}
+	if err := s.db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("failed to read page size: %w", err)
+	}
+	return pageCount * pageSize, nil
+}
+
+// Reopen switches the store to the database at dbPath without a restart, for
+// restores and blue/green data swaps. An empty path reopens the current file.
+// The new database is opened and checked first; then in-flight operations are
+// drained, the connection is swapped, and the old one is closed. On error the
+// store keeps using the current database.
+func (s *SQLiteStore) Reopen(dbPath string) (models.ReopenResult, error) {
+	start := time.Now()
+	var result models.ReopenResult
+
+	s.mu.RLock()
+	previous := s.path
+	s.mu.RUnlock()
+	if dbPath == "" {
+		dbPath = previous
+	}
+	// Opening a missing file would silently start an empty registry
+	if cleanPath := strings.TrimPrefix(dbPath, "sqlite3://"); cleanPath != ":memory:" && !strings.HasPrefix(cleanPath, "file:") {
+		if _, err := os.Stat(cleanPath); err != nil {
+			return result, notFound("database file %q not found", dbPath)
+		}
+	}
+
+	db, err := openDatabase(dbPath, s.opts, s.logger)
+	if err != nil {
+		return result, invalid("invalid database %q: %w", dbPath, err)
+	}
+	var check string
+	if err := db.QueryRow(`PRAGMA quick_check`).Scan(&check); err != nil || check != "ok" {
+		db.Close()
+		s.logger.Error("database failed integrity check", "error", err, "result", check, "path", dbPath)
+		return result, invalid("invalid database %q: integrity check failed", dbPath)
+	}
+
+	// Waits for operations holding the read lock and blocks new ones until the swap is done
+	s.mu.Lock()
+	old := s.db
+	s.db, s.path = db, dbPath
+	s.mu.Unlock()
+
+	if err := old.Close(); err != nil {
+		s.logger.Error("failed to close previous database", "error", err, "path", previous)
+	}
+
+	duration := time.Since(start)
+	result = models.ReopenResult{
+		PreviousPath: previous,
+		Path:         dbPath,
+		DurationMs:   duration.Milliseconds(),
+	}
+	s.observe("Reopen", duration)
+	s.logger.Info("database operation",
+		"operation", "Reopen",
+		"previous_path", previous,
+		"path", dbPath,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// Restore replaces the database with a copy of the backup at backupPath. The
+// backup is validated and copied next to the database first; then in-flight
+// operations are drained, the connection is closed, the current file is moved
+// aside as <path>.pre-restore-<time>, and the copy takes its place. If the
+// restored file can't be opened, the previous database is put back.
+func (s *SQLiteStore) Restore(backupPath string) (models.RestoreResult, error) {
+	start := time.Now()
+	var result models.RestoreResult
+
+	s.mu.RLock()
+	dbPath := s.path
+	s.mu.RUnlock()
+	cleanPath := strings.TrimPrefix(dbPath, "sqlite3://")
+	if cleanPath == ":memory:" || strings.HasPrefix(cleanPath, "file:") {
+		return result, invalid("cannot restore into database %q: not a plain file", dbPath)
+	}
+
+	staged, err := stageRestore(backupPath, cleanPath)
+	if err != nil {
+		return result, err
+	}
+	defer os.Remove(staged)
+
+	// Waits for operations holding the read lock and blocks new ones until the swap is done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.db.Close(); err != nil {
+		s.logger.Error("failed to close database before restore", "error", err)
+	}
+	previous, err := installRestore(staged, cleanPath)
+	if err == nil {
+		var db *sql.DB
+		if db, err = openDatabase(dbPath, s.opts, s.logger); err == nil {
+			s.db = db
+		}
+	}
+	if err != nil {
+		s.logger.Error("restore failed, reopening previous database", "error", err, "backup", backupPath)
+		if previous != "" {
+			if rbErr := os.Rename(previous, cleanPath); rbErr != nil {
+				s.logger.Error("failed to put previous database back", "error", rbErr, "path", previous)
+			}
+		}
+		db, openErr := openDatabase(dbPath, s.opts, s.logger)
+		if openErr != nil {
+			// Leave a closed handle so operations fail instead of panicking
+			s.logger.Error("failed to reopen previous database", "error", openErr)
+			return result, fmt.Errorf("failed to restore database: %w", err)
+		}
+		s.db = db
+		return result, fmt.Errorf("failed to restore database: %w", err)
+	}
+
+	duration := time.Since(start)
+	result = models.RestoreResult{
+		BackupPath:   backupPath,
+		Path:         dbPath,
+		PreviousCopy: previous,
+		DurationMs:   duration.Milliseconds(),
+	}
+	s.observe("Restore", duration)
+	s.logger.Info("database operation",
+		"operation", "Restore",
+		"backup", backupPath,
+		"path", dbPath,
+		"previous_copy", previous,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// RestoreFile replaces the database file at dbPath with a copy of the backup
+// at backupPath. Nothing may have the database open; the server uses this for
+// its --restore mode before the store is created.
+func RestoreFile(backupPath, dbPath string) (models.RestoreResult, error) {
+	start := time.Now()
+	var result models.RestoreResult
+	logger := slog.Default()
+
+	cleanPath := strings.TrimPrefix(dbPath, "sqlite3://")
+	staged, err := stageRestore(backupPath, cleanPath)
+	if err != nil {
+		return result, err
+	}
+	defer os.Remove(staged)
+
+	previous, err := installRestore(staged, cleanPath)
+	if err != nil {
+		return result, fmt.Errorf("failed to restore database: %w", err)
+	}
+	// Brings a backup taken by an older release up to the current schema
+	db, err := openDatabase(dbPath, Options{}, logger)
+	if err != nil {
+		return result, fmt.Errorf("failed to open restored database: %w", err)
+	}
+	db.Close()
+
+	duration := time.Since(start)
+	result = models.RestoreResult{
+		BackupPath:   backupPath,
+		Path:         dbPath,
+		PreviousCopy: previous,
+		DurationMs:   duration.Milliseconds(),
+	}
+	logger.Info("database operation",
+		"operation", "RestoreFile",
+		"backup", backupPath,
+		"path", dbPath,
+		"previous_copy", previous,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
+
+// ValidateBackup checks that path is an intact registry database without
+// modifying it
+func ValidateBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return notFound("backup file %q not found", path)
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return invalid("invalid backup %q: %w", path, err)
+	}
+	defer db.Close()
+
+	var check string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&check); err != nil || check != "ok" {
+		return invalid("invalid backup %q: integrity check failed", path)
+	}
+	var tables int
+	err = db.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name IN ('prompts', 'prompt_versions')
+	`).Scan(&tables)
+	if err != nil || tables != 2 {
+		return invalid("invalid backup %q: not a prompt registry database", path)
+	}
+	return nil
+}
+
+// stageRestore validates the backup and copies it next to dbPath, so the
+// final swap is a rename on the same filesystem. The caller removes the copy.
+func stageRestore(backupPath, dbPath string) (string, error) {
+	if err := ValidateBackup(backupPath); err != nil {
+		return "", err
+	}
+	staged := dbPath + ".restore-tmp"
+	if err := copyFile(backupPath, staged); err != nil {
+		os.Remove(staged)
+		return "", fmt.Errorf("failed to copy backup: %w", err)
+	}
+	return staged, nil
+}
+
+// installRestore moves the current database at dbPath aside and renames the
+// staged copy into its place, returning where the previous file went
+func installRestore(staged, dbPath string) (string, error) {
+	var previous string
+	if _, err := os.Stat(dbPath); err == nil {
+		previous = dbPath + ".pre-restore-" + time.Now().UTC().Format("20060102T150405Z")
+		if err := os.Rename(dbPath, previous); err != nil {
+			return "", fmt.Errorf("failed to move current database aside: %w", err)
+		}
+		// A write-ahead log left by a crash holds commits not yet in the file,
+		// so it goes with the copy it belongs to
+		if _, err := os.Stat(dbPath + "-wal"); err == nil {
+			os.Rename(dbPath+"-wal", previous+"-wal")
+		}
+	}
+	// Journals left by the old database must not be applied to the restored one
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		os.Remove(dbPath + suffix)
+	}
+	if err := os.Rename(staged, dbPath); err != nil {
+		return previous, fmt.Errorf("failed to move restored database into place: %w", err)
+	}
+	return previous, nil
+}
+
+// copyFile copies src to dst and syncs it to disk
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Close closes the database connection
+func (s *SQLiteStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if err := s.db.Close(); err != nil {
 		s.logger.Error("failed to close database", "error", err)
 		return fmt.Errorf("failed to close database: %w", err)
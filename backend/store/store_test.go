@@ -1,9 +1,25 @@
 package store
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/shahram/prompt-registry/backend/auth"
 	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/render"
 )
 
 func setupTestStore(t *testing.T) *SQLiteStore {
@@ -142,6 +158,49 @@ func TestCreatePrompt_DuplicateSlug(t *testing.T) {
 	}
 }
 
+func TestGenerateSlug(t *testing.T) {
+	tests := []struct {
+		title, want string
+	}{
+		{"My Test Prompt", "my-test-prompt"},
+		{"Résumé Prüfung", "resume-prufung"},
+		{"Straße & Smørrebrød", "strasse-smorrebrod"},
+		{"  Hello,   World!!  ", "hello-world"},
+		{"C++ -- tips", "c-tips"},
+		{"ﬁle Ｎｏ２", "file-no2"},
+		{"Health", "health-prompt"},
+		{"日本語", ""},
+		{strings.Repeat("word ", 30), strings.TrimSuffix(strings.Repeat("word-", 20), "-")},
+	}
+	for _, tt := range tests {
+		if got := generateSlug(tt.title); got != tt.want {
+			t.Errorf("generateSlug(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestCreatePrompt_InvalidSlug(t *testing.T) {
+	s := setupTestStore(t)
+
+	for _, slug := range []string{"My Prompt", "my_prompt", "-lead", "trail-", "double--hyphen", "café", "metrics", strings.Repeat("a", MaxSlugLength+1)} {
+		_, err := s.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: "Title", Content: "Content"})
+		if err == nil || !strings.Contains(err.Error(), "invalid slug") {
+			t.Errorf("Expected invalid slug error for %q, got %v", slug, err)
+		}
+	}
+	if err := ValidateSlug("My Prompt"); err == nil || !strings.Contains(err.Error(), `like "my-prompt"`) {
+		t.Errorf("Expected a suggested slug, got %v", err)
+	}
+
+	// A title with nothing to transliterate needs an explicit slug
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Title: "日本語", Content: "Content"}); err == nil || !strings.Contains(err.Error(), "provide a slug") {
+		t.Errorf("Expected an error asking for a slug, got %v", err)
+	}
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "nihongo", Title: "日本語", Content: "Content"}); err != nil {
+		t.Errorf("CreatePrompt with an explicit slug failed: %v", err)
+	}
+}
+
 // Test CreatePromptVersion
 func TestCreatePromptVersion_Success(t *testing.T) {
 	s := setupTestStore(t)
@@ -361,7 +420,7 @@ func TestListPrompts_Success(t *testing.T) {
 	}
 
 	// List all prompts
-	results, err := s.ListPrompts(10, 0)
+	results, err := s.ListPrompts(10, 0, PromptSort{}, PromptFilter{})
 	if err != nil {
 		t.Fatalf("ListPrompts failed: %v", err)
 	}
@@ -399,7 +458,7 @@ func TestListPrompts_LimitAndOffset(t *testing.T) {
 	}
 
 	// Get first 2
-	results, err := s.ListPrompts(2, 0)
+	results, err := s.ListPrompts(2, 0, PromptSort{}, PromptFilter{})
 	if err != nil {
 		t.Fatalf("ListPrompts failed: %v", err)
 	}
@@ -408,7 +467,7 @@ func TestListPrompts_LimitAndOffset(t *testing.T) {
 	}
 
 	// Get next 2 (with offset)
-	results2, err := s.ListPrompts(2, 2)
+	results2, err := s.ListPrompts(2, 2, PromptSort{}, PromptFilter{})
 	if err != nil {
 		t.Fatalf("ListPrompts failed: %v", err)
 	}
@@ -422,10 +481,117 @@ func TestListPrompts_LimitAndOffset(t *testing.T) {
 	}
 }
 
+func TestListPrompts_Sort(t *testing.T) {
+	s := setupTestStore(t)
+
+	for _, title := range []string{"banana", "Apple", "cherry"} {
+		if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: strings.ToLower(title), Title: title, Content: "Content"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	// Timestamps have one-second resolution, so spread them out explicitly
+	for slug, day := range map[string]int{"banana": 3, "apple": 1, "cherry": 2} {
+		if _, err := s.db.Exec(`UPDATE prompts SET updated_at = ? WHERE slug = ?`, fmt.Sprintf("2025-01-0%d 00:00:00", day), slug); err != nil {
+			t.Fatalf("Failed to set updated_at: %v", err)
+		}
+	}
+
+	tests := []struct {
+		sort PromptSort
+		want []string
+	}{
+		{PromptSort{}, []string{"cherry", "apple", "banana"}},
+		{PromptSort{Order: OrderAsc}, []string{"banana", "apple", "cherry"}},
+		{PromptSort{Field: SortUpdatedAt}, []string{"banana", "cherry", "apple"}},
+		{PromptSort{Field: SortTitle}, []string{"apple", "banana", "cherry"}},
+		{PromptSort{Field: SortTitle, Order: OrderDesc}, []string{"cherry", "banana", "apple"}},
+	}
+	for _, tt := range tests {
+		results, err := s.ListPrompts(10, 0, tt.sort, PromptFilter{})
+		if err != nil {
+			t.Fatalf("ListPrompts(%s) failed: %v", tt.sort, err)
+		}
+		var got []string
+		for _, p := range results {
+			got = append(got, p.Slug)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ListPrompts(%s) = %v, want %v", tt.sort, got, tt.want)
+		}
+	}
+
+	for _, sort := range []PromptSort{{Field: "slug; DROP TABLE prompts"}, {Order: "sideways"}} {
+		if _, err := s.ListAllPrompts(10, 0, sort, PromptFilter{}); err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Errorf("Expected invalid sort error for %+v, got %v", sort, err)
+		}
+	}
+}
+
+func TestListPrompts_Filter(t *testing.T) {
+	s := setupTestStore(t)
+
+	for _, slug := range []string{"stale", "busy", "fresh"} {
+		if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	for i := 2; i <= 4; i++ {
+		if _, err := s.CreatePromptVersion("busy", models.CreatePromptVersionInput{Content: "v" + strconv.Itoa(i)}); err != nil {
+			t.Fatalf("CreatePromptVersion failed: %v", err)
+		}
+	}
+	for slug, dates := range map[string][2]string{
+		"stale": {"2024-01-01 00:00:00", "2024-02-01 00:00:00"},
+		"busy":  {"2024-06-01 00:00:00", "2025-03-01 12:00:00"},
+		"fresh": {"2025-02-01 00:00:00", "2025-02-01 00:00:00"},
+	} {
+		if _, err := s.db.Exec(`UPDATE prompts SET created_at = ?, updated_at = ? WHERE slug = ?`, dates[0], dates[1], slug); err != nil {
+			t.Fatalf("Failed to set timestamps: %v", err)
+		}
+	}
+	day := func(date string) time.Time {
+		d, _ := time.Parse(time.DateOnly, date)
+		return d
+	}
+
+	tests := []struct {
+		name   string
+		filter PromptFilter
+		want   []string
+	}{
+		{"none", PromptFilter{}, []string{"fresh", "busy", "stale"}},
+		{"stale", PromptFilter{UpdatedBefore: day("2025-01-01")}, []string{"stale"}},
+		{"recently updated", PromptFilter{UpdatedAfter: day("2025-01-01")}, []string{"fresh", "busy"}},
+		{"created range", PromptFilter{CreatedAfter: day("2024-03-01"), CreatedBefore: day("2025-01-01")}, []string{"busy"}},
+		{"churned", PromptFilter{MinVersions: 3}, []string{"busy"}},
+		{"single version", PromptFilter{MaxVersions: 1}, []string{"fresh", "stale"}},
+	}
+	for _, tt := range tests {
+		results, err := s.ListPrompts(10, 0, PromptSort{}, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: ListPrompts failed: %v", tt.name, err)
+		}
+		var got []string
+		for _, p := range results {
+			got = append(got, p.Slug)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		if n, err := s.CountPrompts(false, tt.filter); err != nil || n != len(tt.want) {
+			t.Errorf("%s: expected count %d, got %d (%v)", tt.name, len(tt.want), n, err)
+		}
+	}
+
+	if _, err := s.ListPrompts(10, 0, PromptSort{}, PromptFilter{MinVersions: 5, MaxVersions: 2}); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("Expected invalid filter error, got %v", err)
+	}
+}
+
 func TestListPrompts_Empty(t *testing.T) {
 	s := setupTestStore(t)
 
-	results, err := s.ListPrompts(10, 0)
+	results, err := s.ListPrompts(10, 0, PromptSort{}, PromptFilter{})
 	if err != nil {
 		t.Fatalf("ListPrompts failed: %v", err)
 	}
@@ -435,6 +601,32 @@ func TestListPrompts_Empty(t *testing.T) {
 	}
 }
 
+func TestCountPrompts(t *testing.T) {
+	s := setupTestStore(t)
+
+	for _, slug := range []string{"active", "shared", "old"} {
+		if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "Content"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	if err := s.SetPromptVisibility("shared", true); err != nil {
+		t.Fatalf("SetPromptVisibility failed: %v", err)
+	}
+	if err := s.SetPromptArchived("old", true); err != nil {
+		t.Fatalf("SetPromptArchived failed: %v", err)
+	}
+
+	if n, err := s.CountPrompts(false, PromptFilter{}); err != nil || n != 2 {
+		t.Errorf("Expected 2 listed prompts, got %d (%v)", n, err)
+	}
+	if n, err := s.CountPrompts(true, PromptFilter{}); err != nil || n != 3 {
+		t.Errorf("Expected 3 prompts including archived, got %d (%v)", n, err)
+	}
+	if n, err := s.CountPublicPrompts(); err != nil || n != 1 {
+		t.Errorf("Expected 1 public prompt, got %d (%v)", n, err)
+	}
+}
+
 // Test ListPromptVersions
 func TestListPromptVersions_Success(t *testing.T) {
 	s := setupTestStore(t)
@@ -462,7 +654,7 @@ func TestListPromptVersions_Success(t *testing.T) {
 	}
 
 	// List versions
-	versions, err := s.ListPromptVersions("test-prompt")
+	versions, err := s.ListPromptVersions("test-prompt", 100, 0)
 	if err != nil {
 		t.Fatalf("ListPromptVersions failed: %v", err)
 	}
@@ -483,12 +675,43 @@ func TestListPromptVersions_Success(t *testing.T) {
 func TestListPromptVersions_NonExistentSlug(t *testing.T) {
 	s := setupTestStore(t)
 
-	_, err := s.ListPromptVersions("non-existent")
+	_, err := s.ListPromptVersions("non-existent", 100, 0)
 	if err == nil {
 		t.Error("Expected error for non-existent slug, got nil")
 	}
 }
 
+func TestListPromptVersions_LimitAndOffset(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "long", Title: "Long", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	for i := 2; i <= 5; i++ {
+		if _, err := s.CreatePromptVersion("long", models.CreatePromptVersionInput{Content: "v" + strconv.Itoa(i)}); err != nil {
+			t.Fatalf("CreatePromptVersion failed: %v", err)
+		}
+	}
+
+	page, err := s.ListPromptVersions("long", 2, 2)
+	if err != nil {
+		t.Fatalf("ListPromptVersions failed: %v", err)
+	}
+	if len(page) != 2 || page[0].VersionNumber != 3 || page[1].VersionNumber != 4 {
+		t.Errorf("Expected versions 3 and 4, got %+v", page)
+	}
+	if all, err := s.ListPromptVersions("long", -1, 0); err != nil || len(all) != 5 {
+		t.Errorf("Expected every version with a negative limit, got %d (%v)", len(all), err)
+	}
+
+	if n, err := s.CountPromptVersions("long"); err != nil || n != 5 {
+		t.Errorf("Expected 5 versions, got %d (%v)", n, err)
+	}
+	if _, err := s.CountPromptVersions("missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found counting a missing prompt, got %v", err)
+	}
+}
+
 // Test GetStats
 func TestGetStats_Success(t *testing.T) {
 	s := setupTestStore(t)
@@ -550,3 +773,3591 @@ func TestGetStats_Success(t *testing.T) {
 		t.Errorf("Expected 3 versions, got %d", stats.TotalPromptVersions)
 	}
 }
+
+// Test public visibility
+func TestSetPromptVisibility_ListPublicPrompts(t *testing.T) {
+	s := setupTestStore(t)
+
+	_, err := s.CreatePrompt(models.CreatePromptInput{Slug: "shared", Title: "Shared", Content: "Content", Public: true})
+	if err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	_, err = s.CreatePrompt(models.CreatePromptInput{Slug: "internal", Title: "Internal", Content: "Content"})
+	if err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	public, err := s.ListPublicPrompts(100, 0)
+	if err != nil {
+		t.Fatalf("ListPublicPrompts failed: %v", err)
+	}
+	if len(public) != 1 || public[0].Slug != "shared" {
+		t.Fatalf("Expected only 'shared' to be public, got %+v", public)
+	}
+
+	if err := s.SetPromptVisibility("internal", true); err != nil {
+		t.Fatalf("SetPromptVisibility failed: %v", err)
+	}
+	if err := s.SetPromptVisibility("shared", false); err != nil {
+		t.Fatalf("SetPromptVisibility failed: %v", err)
+	}
+
+	public, err = s.ListPublicPrompts(100, 0)
+	if err != nil {
+		t.Fatalf("ListPublicPrompts failed: %v", err)
+	}
+	if len(public) != 1 || public[0].Slug != "internal" {
+		t.Fatalf("Expected only 'internal' to be public, got %+v", public)
+	}
+
+	prompt, err := s.GetPromptBySlug("internal")
+	if err != nil {
+		t.Fatalf("GetPromptBySlug failed: %v", err)
+	}
+	if !prompt.Public {
+		t.Error("Expected GetPromptBySlug to report public=true")
+	}
+}
+
+func TestSetPromptDescription(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "Content"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	description := "Owned by **search**. See [runbook](https://wiki.example.com/summarize)."
+	prompt, err := s.SetPromptDescription("summarize", description)
+	if err != nil {
+		t.Fatalf("SetPromptDescription failed: %v", err)
+	}
+	if prompt.Description != description || prompt.CurrentVersion.VersionNumber != 1 {
+		t.Errorf("Expected description to change without a new version, got %+v", prompt)
+	}
+	entries, err := s.ListAuditEntries("summarize", 1, 0)
+	if err != nil || len(entries) != 1 || entries[0].Action != "prompt.description_changed" {
+		t.Errorf("Expected a description_changed audit entry, got %+v, %v", entries, err)
+	}
+
+	if _, err := s.SetPromptDescription("summarize", "too short"); err == nil || !strings.Contains(err.Error(), "invalid description") {
+		t.Errorf("Expected invalid description error, got %v", err)
+	}
+	if prompt, err := s.SetPromptDescription("summarize", ""); err != nil || prompt.Description != "" {
+		t.Errorf("Expected description to be cleared, got %q, %v", prompt.Description, err)
+	}
+	if _, err := s.SetPromptDescription("missing", description); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestPromptDocs(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "Summarize {{text}}"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	docs, err := s.GetPromptDocs("summarize", 0)
+	if err != nil || docs.Revision != 0 || docs.Content != "" {
+		t.Fatalf("Expected no docs yet, got %+v, %v", docs, err)
+	}
+
+	if docs, err = s.SetPromptDocs("summarize", "# Intent\nShort summaries."); err != nil || docs.Revision != 1 {
+		t.Fatalf("Expected revision 1, got %+v, %v", docs, err)
+	}
+	if docs, err = s.SetPromptDocs("summarize", "# Intent\nShort summaries.\n\n## Caveats\nEnglish only."); err != nil || docs.Revision != 2 {
+		t.Fatalf("Expected revision 2, got %+v, %v", docs, err)
+	}
+	// Saving unchanged docs doesn't add a revision
+	if docs, err = s.SetPromptDocs("summarize", docs.Content); err != nil || docs.Revision != 2 {
+		t.Errorf("Expected unchanged docs to stay at revision 2, got %+v, %v", docs, err)
+	}
+
+	prompt, err := s.GetPromptBySlug("summarize")
+	if err != nil {
+		t.Fatalf("GetPromptBySlug failed: %v", err)
+	}
+	if prompt.DocsRevision != 2 || prompt.CurrentVersion.VersionNumber != 1 {
+		t.Errorf("Expected docs revision 2 without a new content version, got %+v", prompt)
+	}
+
+	if first, err := s.GetPromptDocs("summarize", 1); err != nil || first.Content != "# Intent\nShort summaries." {
+		t.Errorf("Expected revision 1 to be kept, got %+v, %v", first, err)
+	}
+	revisions, err := s.ListPromptDocs("summarize")
+	if err != nil || len(revisions) != 2 || revisions[0].Revision != 2 || revisions[1].Revision != 1 {
+		t.Errorf("Expected revisions [2 1], got %+v, %v", revisions, err)
+	}
+	entries, err := s.ListAuditEntries("summarize", 1, 0)
+	if err != nil || len(entries) != 1 || entries[0].Action != "prompt.docs_updated" || entries[0].Detail != "revision 2" {
+		t.Errorf("Expected a docs_updated audit entry, got %+v, %v", entries, err)
+	}
+
+	if _, err := s.GetPromptDocs("summarize", 3); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected missing revision to be not found, got %v", err)
+	}
+	if _, err := s.SetPromptDocs("missing", "docs"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	// Exports carry the latest docs, which an import restores as revision 1
+	var exported []models.ExportedPrompt
+	if _, err := s.ExportPrompts(func(p models.ExportedPrompt) error {
+		exported = append(exported, p)
+		return nil
+	}); err != nil {
+		t.Fatalf("ExportPrompts failed: %v", err)
+	}
+	if len(exported) != 1 || exported[0].Docs != docs.Content {
+		t.Fatalf("Expected export to carry the latest docs, got %+v", exported)
+	}
+	other := s.InProject("other")
+	if _, err := other.ImportPrompts(exported); err != nil {
+		t.Fatalf("ImportPrompts failed: %v", err)
+	}
+	if imported, err := other.GetPromptDocs("summarize", 0); err != nil || imported.Revision != 1 || imported.Content != docs.Content {
+		t.Errorf("Expected imported docs at revision 1, got %+v, %v", imported, err)
+	}
+}
+
+func TestSetPromptVisibility_NonExistentSlug(t *testing.T) {
+	s := setupTestStore(t)
+
+	if err := s.SetPromptVisibility("non-existent", true); err == nil {
+		t.Error("Expected error for non-existent slug, got nil")
+	}
+}
+
+func TestSetPromptArchived(t *testing.T) {
+	s := setupTestStore(t)
+
+	for _, input := range []models.CreatePromptInput{
+		{Slug: "retired", Title: "Retired", Content: "old", Public: true},
+		{Slug: "active", Title: "Active", Content: "new", Public: true},
+	} {
+		if _, err := s.CreatePrompt(input); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+
+	if err := s.SetPromptArchived("retired", true); err != nil {
+		t.Fatalf("SetPromptArchived failed: %v", err)
+	}
+	prompt, err := s.GetPromptBySlug("retired")
+	if err != nil {
+		t.Fatalf("Expected archived prompt to stay fetchable, got %v", err)
+	}
+	if prompt.ArchivedAt == nil {
+		t.Fatal("Expected archived_at to be set")
+	}
+	archivedAt := *prompt.ArchivedAt
+
+	slugs := func(summaries []models.PromptSummary, err error) []string {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		var out []string
+		for _, p := range summaries {
+			out = append(out, p.Slug)
+		}
+		return out
+	}
+	if got := slugs(s.ListPrompts(10, 0, PromptSort{}, PromptFilter{})); !reflect.DeepEqual(got, []string{"active"}) {
+		t.Errorf("Expected ListPrompts to hide archived prompts, got %v", got)
+	}
+	if got := slugs(s.ListPublicPrompts(10, 0)); !reflect.DeepEqual(got, []string{"active"}) {
+		t.Errorf("Expected ListPublicPrompts to hide archived prompts, got %v", got)
+	}
+	all, err := s.ListAllPrompts(10, 0, PromptSort{}, PromptFilter{})
+	if len(slugs(all, err)) != 2 {
+		t.Errorf("Expected ListAllPrompts to include archived prompts, got %+v", all)
+	}
+	for _, p := range all {
+		if (p.ArchivedAt != nil) != (p.Slug == "retired") {
+			t.Errorf("Unexpected archived_at for %s: %v", p.Slug, p.ArchivedAt)
+		}
+	}
+
+	// Archiving again keeps the original time
+	if err := s.SetPromptArchived("retired", true); err != nil {
+		t.Fatalf("SetPromptArchived failed: %v", err)
+	}
+	if prompt, _ := s.GetPromptBySlug("retired"); prompt.ArchivedAt == nil || !prompt.ArchivedAt.Equal(archivedAt) {
+		t.Errorf("Expected archived_at %v to be kept, got %v", archivedAt, prompt.ArchivedAt)
+	}
+
+	if err := s.SetPromptArchived("retired", false); err != nil {
+		t.Fatalf("SetPromptArchived failed: %v", err)
+	}
+	if prompt, _ := s.GetPromptBySlug("retired"); prompt.ArchivedAt != nil {
+		t.Errorf("Expected archived_at to be cleared, got %v", prompt.ArchivedAt)
+	}
+	if got := slugs(s.ListPrompts(10, 0, PromptSort{}, PromptFilter{})); len(got) != 2 {
+		t.Errorf("Expected unarchived prompt to be listed again, got %v", got)
+	}
+
+	if err := s.SetPromptArchived("missing", true); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestProjects(t *testing.T) {
+	s := setupTestStore(t)
+	search := s.InProject("search")
+
+	if s.Project() != DefaultProject || search.Project() != "search" {
+		t.Fatalf("Unexpected projects %q and %q", s.Project(), search.Project())
+	}
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Default", Content: "hi"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	// The same slug can be used in another project
+	if _, err := search.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Search", Content: "hello"}); err != nil {
+		t.Fatalf("CreatePrompt in second project failed: %v", err)
+	}
+	if _, err := search.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Again", Content: "x"}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected duplicate slug within a project to fail, got %v", err)
+	}
+	if _, err := search.CreatePrompt(models.CreatePromptInput{Slug: "ranking", Title: "Ranking", Content: "rank"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := search.CreatePromptVersion("greeting", models.CreatePromptVersionInput{Content: "hello again"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	prompt, err := s.GetPromptBySlug("greeting")
+	if err != nil {
+		t.Fatalf("GetPromptBySlug failed: %v", err)
+	}
+	if prompt.Title != "Default" || prompt.CurrentVersion.VersionNumber != 1 {
+		t.Errorf("Expected default project's prompt untouched, got %+v", prompt)
+	}
+	if _, err := s.GetPromptBySlug("ranking"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected prompt from another project to be not found, got %v", err)
+	}
+	if versions, err := search.ListPromptVersions("greeting", 100, 0); err != nil || len(versions) != 2 {
+		t.Errorf("Expected 2 versions in search project, got %d, %v", len(versions), err)
+	}
+	if prompts, err := s.ListPrompts(10, 0, PromptSort{}, PromptFilter{}); err != nil || len(prompts) != 1 {
+		t.Errorf("Expected 1 prompt in default project, got %+v, %v", prompts, err)
+	}
+	if prompts, err := search.ListPrompts(10, 0, PromptSort{}, PromptFilter{}); err != nil || len(prompts) != 2 {
+		t.Errorf("Expected 2 prompts in search project, got %+v, %v", prompts, err)
+	}
+	if err := search.SetPromptArchived("ranking", true); err != nil {
+		t.Fatalf("SetPromptArchived failed: %v", err)
+	}
+
+	projects, err := s.ListProjects()
+	if err != nil {
+		t.Fatalf("ListProjects failed: %v", err)
+	}
+	want := []models.Project{
+		{Name: DefaultProject, Prompts: 1},
+		{Name: "search", Prompts: 2, ArchivedPrompts: 1},
+	}
+	if !reflect.DeepEqual(projects, want) {
+		t.Errorf("Expected projects %+v, got %+v", want, projects)
+	}
+
+	// Stats stay registry-wide
+	stats, err := s.GetStats()
+	if err != nil || stats.TotalPrompts != 3 {
+		t.Errorf("Expected 3 prompts across projects, got %+v, %v", stats, err)
+	}
+	if stats, err := s.InProject("search").GetProjectStats(); err != nil || stats.TotalPrompts != 2 {
+		t.Errorf("Expected 2 prompts in search, got %+v, %v", stats, err)
+	}
+
+	for _, name := range []string{"search", "team-a", "a1"} {
+		if err := ValidateProject(name); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "-lead", "Upper", "has space", "under_score", strings.Repeat("a", 64)} {
+		if err := ValidateProject(name); err == nil {
+			t.Errorf("Expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	base := setupTestStore(t)
+	as := func(subject string) Store {
+		return base.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: subject, Method: "apikey"}))
+	}
+	alice, bob := as("alice"), as("bob")
+
+	if _, err := alice.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Greeting", Content: "hi"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := bob.CreatePromptVersion("greeting", models.CreatePromptVersionInput{Content: "hello"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if err := bob.SetPromptVisibility("greeting", true); err != nil {
+		t.Fatalf("SetPromptVisibility failed: %v", err)
+	}
+	webhook, err := alice.AddPromptWebhook("greeting", "https://example.com/hook")
+	if err != nil {
+		t.Fatalf("AddPromptWebhook failed: %v", err)
+	}
+	if err := alice.DeletePromptWebhook("greeting", webhook.ID); err != nil {
+		t.Fatalf("DeletePromptWebhook failed: %v", err)
+	}
+	// The identity carries across projects, and writes without one have no actor
+	if err := alice.InProject(DefaultProject).SetPromptArchived("greeting", true); err != nil {
+		t.Fatalf("SetPromptArchived failed: %v", err)
+	}
+	if err := base.SetPromptExecution("greeting", models.ExecutionConfig{Provider: "openai", Model: "gpt-4o"}); err != nil {
+		t.Fatalf("SetPromptExecution failed: %v", err)
+	}
+	// Failed writes leave no audit entry
+	if err := bob.SetPromptVisibility("missing", true); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	prompt, err := base.GetPromptBySlug("greeting")
+	if err != nil {
+		t.Fatalf("GetPromptBySlug failed: %v", err)
+	}
+	if prompt.CreatedBy != "alice" || prompt.UpdatedBy != "" || prompt.CurrentVersion.CreatedBy != "bob" {
+		t.Errorf("Unexpected attribution: created_by %q, updated_by %q, version created_by %q",
+			prompt.CreatedBy, prompt.UpdatedBy, prompt.CurrentVersion.CreatedBy)
+	}
+	versions, err := base.ListPromptVersions("greeting", 100, 0)
+	if err != nil || len(versions) != 2 || versions[0].CreatedBy != "alice" {
+		t.Errorf("Expected first version created by alice, got %+v, %v", versions, err)
+	}
+
+	entries, err := base.ListAuditEntries("greeting", 100, 0)
+	if err != nil {
+		t.Fatalf("ListAuditEntries failed: %v", err)
+	}
+	type row struct{ actor, action, detail string }
+	var got []row
+	for _, e := range entries {
+		got = append(got, row{e.Actor, e.Action, e.Detail})
+		if e.Project != DefaultProject || e.Slug != "greeting" {
+			t.Errorf("Unexpected entry scope: %+v", e)
+		}
+	}
+	want := []row{
+		{"", "prompt.execution_changed", "openai/gpt-4o"},
+		{"alice", "prompt.archived", ""},
+		{"alice", "webhook.deleted", "https://example.com/hook"},
+		{"alice", "webhook.added", "https://example.com/hook"},
+		{"bob", "prompt.visibility_changed", "public"},
+		{"bob", "prompt.version_created", ""},
+		{"alice", "prompt.created", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected audit log %+v, got %+v", want, got)
+	}
+	if entries[5].Version != 2 || entries[6].Version != 1 {
+		t.Errorf("Expected version numbers on version entries, got %+v", entries[5:])
+	}
+
+	if page, err := base.ListAuditEntries("greeting", 2, 1); err != nil || len(page) != 2 || page[0].Action != "prompt.archived" {
+		t.Errorf("Expected second page of 2 entries, got %+v, %v", page, err)
+	}
+	if _, err := base.ListAuditEntries("missing", 10, 0); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestOrgs(t *testing.T) {
+	base := setupTestStore(t)
+	as := func(subject string) Store {
+		return base.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: subject}))
+	}
+	alice, bob, carol := as("alice"), as("bob"), as("carol")
+
+	if _, err := base.CreateOrg(models.CreateOrgInput{Name: "search"}); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected anonymous org creation to be denied, got %v", err)
+	}
+	org, err := alice.CreateOrg(models.CreateOrgInput{Name: "search"})
+	if err != nil {
+		t.Fatalf("CreateOrg failed: %v", err)
+	}
+	if len(org.Members) != 1 || org.Members[0].Subject != "alice" || org.Members[0].Role != OrgOwner {
+		t.Errorf("Expected creator to be the owner, got %+v", org.Members)
+	}
+	if _, err := bob.CreateOrg(models.CreateOrgInput{Name: "search"}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected duplicate org to fail, got %v", err)
+	}
+	if _, err := alice.CreateOrg(models.CreateOrgInput{Name: "Bad Name"}); err == nil || !strings.Contains(err.Error(), "invalid org name") {
+		t.Errorf("Expected invalid name to fail, got %v", err)
+	}
+
+	// Non-members can't see the org at all; members can't manage it
+	if _, err := bob.GetOrg("search"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected org to be hidden from non-members, got %v", err)
+	}
+	if _, err := alice.AddOrgMember("search", models.AddOrgMemberInput{Subject: "bob"}); err != nil {
+		t.Fatalf("AddOrgMember failed: %v", err)
+	}
+	if _, err := alice.AddOrgMember("search", models.AddOrgMemberInput{Subject: "bob"}); err == nil || !strings.Contains(err.Error(), "already") {
+		t.Errorf("Expected duplicate member to fail, got %v", err)
+	}
+	if _, err := alice.AddOrgMember("search", models.AddOrgMemberInput{Subject: "dave", Role: "admin"}); err == nil || !strings.Contains(err.Error(), "invalid role") {
+		t.Errorf("Expected invalid role to fail, got %v", err)
+	}
+	if _, err := bob.AddOrgMember("search", models.AddOrgMemberInput{Subject: "carol"}); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected member invite to be denied, got %v", err)
+	}
+	org, err = bob.GetOrg("search")
+	if err != nil {
+		t.Fatalf("GetOrg failed: %v", err)
+	}
+	if len(org.Members) != 2 || org.Members[1].Subject != "bob" || org.Members[1].Role != OrgMember || org.Members[1].InvitedBy != "alice" {
+		t.Errorf("Unexpected members: %+v", org.Members)
+	}
+
+	// Projects in the org are hidden from everyone else
+	if _, err := alice.InProject("ranking").CreatePrompt(models.CreatePromptInput{Slug: "p", Title: "P", Content: "x"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if err := bob.AssignProject("search", "ranking"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected member assignment to be denied, got %v", err)
+	}
+	if err := alice.AssignProject("search", DefaultProject); err == nil || !strings.Contains(err.Error(), "default project") {
+		t.Errorf("Expected default project assignment to fail, got %v", err)
+	}
+	for _, project := range []string{"ranking", "fresh"} {
+		if err := alice.AssignProject("search", project); err != nil {
+			t.Fatalf("AssignProject failed: %v", err)
+		}
+	}
+	if err := bob.InProject("ranking").AuthorizeProject(); err != nil {
+		t.Errorf("Expected member to see the project, got %v", err)
+	}
+	if err := carol.InProject("ranking").AuthorizeProject(); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected project to be hidden from non-members, got %v", err)
+	}
+	if err := carol.InProject("other").AuthorizeProject(); err != nil {
+		t.Errorf("Expected projects outside orgs to stay open, got %v", err)
+	}
+
+	names := func(s Store) []string {
+		t.Helper()
+		projects, err := s.ListProjects()
+		if err != nil {
+			t.Fatalf("ListProjects failed: %v", err)
+		}
+		var out []string
+		for _, p := range projects {
+			out = append(out, p.Name+":"+p.Org)
+		}
+		return out
+	}
+	if got := names(bob); !reflect.DeepEqual(got, []string{"default:", "fresh:search", "ranking:search"}) {
+		t.Errorf("Unexpected projects for a member: %v", got)
+	}
+	if got := names(carol); !reflect.DeepEqual(got, []string{"default:"}) {
+		t.Errorf("Unexpected projects for a non-member: %v", got)
+	}
+
+	// Transfers need ownership of both orgs
+	if _, err := carol.CreateOrg(models.CreateOrgInput{Name: "ads"}); err != nil {
+		t.Fatalf("CreateOrg failed: %v", err)
+	}
+	if err := carol.AssignProject("ads", "ranking"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected transfer by a non-member of the current org to fail, got %v", err)
+	}
+	if orgs, err := alice.ListOrgs(); err != nil || len(orgs) != 1 || !reflect.DeepEqual(orgs[0].Projects, []string{"fresh", "ranking"}) {
+		t.Errorf("Unexpected orgs: %+v, %v", orgs, err)
+	}
+
+	if err := alice.ReleaseProject("search", "fresh"); err != nil {
+		t.Fatalf("ReleaseProject failed: %v", err)
+	}
+	if err := carol.InProject("fresh").AuthorizeProject(); err != nil {
+		t.Errorf("Expected released project to be open, got %v", err)
+	}
+
+	// Members can leave, but the last owner can't
+	if err := bob.RemoveOrgMember("search", "alice"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected member removing an owner to be denied, got %v", err)
+	}
+	if err := alice.RemoveOrgMember("search", "alice"); err == nil || !strings.Contains(err.Error(), "last owner") {
+		t.Errorf("Expected last owner removal to fail, got %v", err)
+	}
+	if err := bob.RemoveOrgMember("search", "bob"); err != nil {
+		t.Fatalf("RemoveOrgMember failed: %v", err)
+	}
+	if err := bob.InProject("ranking").AuthorizeProject(); err == nil {
+		t.Error("Expected project to be hidden after leaving the org")
+	}
+	if err := alice.RemoveOrgMember("search", "bob"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestNew_UpgradesExistingSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	// Create a database with the original schema (no public column)
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE prompts (
+			id               INTEGER PRIMARY KEY AUTOINCREMENT,
+			slug             TEXT UNIQUE NOT NULL,
+			title            TEXT NOT NULL,
+			description      TEXT,
+			current_version  INTEGER NOT NULL DEFAULT 0,
+			created_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE prompt_versions (
+			id             INTEGER PRIMARY KEY AUTOINCREMENT,
+			prompt_id      INTEGER NOT NULL,
+			version_number INTEGER NOT NULL,
+			content        TEXT NOT NULL,
+			created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY(prompt_id) REFERENCES prompts(id),
+			UNIQUE(prompt_id, version_number)
+		);
+		INSERT INTO prompts (slug, title, description, current_version) VALUES ('legacy', 'Legacy', '', 1);
+		INSERT INTO prompt_versions (prompt_id, version_number, content) VALUES (1, 1, 'legacy v1');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+	db.Close()
+
+	s, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("New failed on legacy database: %v", err)
+	}
+	defer s.Close()
+
+	prompts, err := s.ListPrompts(10, 0, PromptSort{}, PromptFilter{})
+	if err != nil {
+		t.Fatalf("ListPrompts failed: %v", err)
+	}
+	if len(prompts) != 1 || prompts[0].Public {
+		t.Errorf("Expected legacy prompt to default to private, got %+v", prompts)
+	}
+	prompt, err := s.GetPromptBySlug("legacy")
+	if err != nil || prompt.CurrentVersion.Content != "legacy v1" {
+		t.Errorf("Expected legacy versions to survive the upgrade, got %+v, %v", prompt, err)
+	}
+	if prompt.CurrentVersion.ContentSHA256 != contentSHA256("legacy v1") {
+		t.Errorf("Expected the upgrade to hash existing versions, got %q", prompt.CurrentVersion.ContentSHA256)
+	}
+
+	// Legacy prompts land in the default project, and the old global slug
+	// constraint no longer applies across projects
+	if _, err := s.InProject("other").CreatePrompt(models.CreatePromptInput{Slug: "legacy", Title: "Other", Content: "v1"}); err != nil {
+		t.Errorf("Expected legacy slug to be reusable in another project, got %v", err)
+	}
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "legacy", Title: "Dup", Content: "v1"}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected duplicate slug in default project to fail, got %v", err)
+	}
+}
+
+func TestNewWithOptions_Pragmas(t *testing.T) {
+	s, err := NewWithOptions(filepath.Join(t.TempDir(), "pragmas.db"), Options{BusyTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %v", err)
+	}
+	defer s.Close()
+
+	pragma := func(name string) string {
+		var value string
+		if err := s.db.QueryRow(`PRAGMA ` + name).Scan(&value); err != nil {
+			t.Fatalf("PRAGMA %s failed: %v", name, err)
+		}
+		return value
+	}
+	for name, want := range map[string]string{
+		"journal_mode": "wal",
+		"synchronous":  "1", // NORMAL
+		"foreign_keys": "1",
+		"busy_timeout": "5000",
+	} {
+		if got := pragma(name); got != want {
+			t.Errorf("Expected %s %s, got %s", name, want, got)
+		}
+	}
+
+	// Writers on separate connections wait for each other instead of failing
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slug := fmt.Sprintf("concurrent-%d", i)
+			if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent write failed: %v", err)
+	}
+
+	if _, err := s.db.Exec(`INSERT INTO prompt_versions (prompt_id, version_number, content) VALUES (9999, 1, 'orphan')`); err == nil {
+		t.Error("Expected foreign keys to reject a version of a missing prompt")
+	}
+
+	if _, err := NewWithOptions(filepath.Join(t.TempDir(), "bad.db"), Options{JournalMode: "fast"}); err == nil || !strings.Contains(err.Error(), "invalid journal mode") {
+		t.Errorf("Expected invalid journal mode error, got %v", err)
+	}
+	rollback, err := NewWithOptions(filepath.Join(t.TempDir(), "rollback.db"), Options{JournalMode: "delete", Synchronous: "full"})
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %v", err)
+	}
+	defer rollback.Close()
+	var mode string
+	rollback.db.QueryRow(`PRAGMA journal_mode`).Scan(&mode)
+	if mode != "delete" {
+		t.Errorf("Expected journal_mode delete, got %s", mode)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Fatalf("Expected migration %d to have version %d, got %d", i, i+1, m.version)
+		}
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "migrations.db")
+	s, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	s.Close()
+
+	tableExists := func(name string) bool {
+		t.Helper()
+		db, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`, name).Scan(&exists); err != nil {
+			t.Fatalf("Failed to inspect schema: %v", err)
+		}
+		return exists
+	}
+
+	status, err := MigrationStatus(dbPath, Options{})
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	if len(status) != LatestSchemaVersion() {
+		t.Fatalf("Expected %d migrations, got %d", LatestSchemaVersion(), len(status))
+	}
+	for _, m := range status {
+		if m.AppliedAt == nil {
+			t.Errorf("Expected migration %d to be applied on a new database", m.Version)
+		}
+	}
+	if status[0].Reversible {
+		t.Error("Expected the baseline migration to be irreversible")
+	}
+
+	// Migrating down reverts later migrations, and opening the store applies them again
+	result, err := MigrateFile(dbPath, Options{}, 1)
+	if err != nil {
+		t.Fatalf("MigrateFile down failed: %v", err)
+	}
+	if result.FromVersion != LatestSchemaVersion() || result.ToVersion != 1 {
+		t.Errorf("Expected migration from %d to 1, got %d to %d", LatestSchemaVersion(), result.FromVersion, result.ToVersion)
+	}
+	if tableExists("releases") {
+		t.Error("Expected the releases table to be dropped")
+	}
+	status, _ = MigrationStatus(dbPath, Options{})
+	if status[1].AppliedAt != nil {
+		t.Error("Expected the releases migration to be pending")
+	}
+	s, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	s.Close()
+	if !tableExists("releases") {
+		t.Error("Expected the releases table to be recreated")
+	}
+
+	if _, err := MigrateFile(dbPath, Options{}, 0); err == nil || !strings.Contains(err.Error(), "can't be reverted") {
+		t.Errorf("Expected an irreversible migration error, got %v", err)
+	}
+	if _, err := MigrateFile(dbPath, Options{}, LatestSchemaVersion()+1); err == nil || !strings.Contains(err.Error(), "invalid schema version") {
+		t.Errorf("Expected an invalid version error, got %v", err)
+	}
+	if _, err := MigrateFile(filepath.Join(t.TempDir(), "missing.db"), Options{}, 1); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+
+	// A database migrated by a newer build is left alone
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	newer := LatestSchemaVersion() + 1
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, 'future')`, newer); err != nil {
+		t.Fatalf("Failed to record migration: %v", err)
+	}
+	db.Close()
+	if _, err := New(dbPath); err == nil || !strings.Contains(err.Error(), "newer than this build") {
+		t.Errorf("Expected a newer schema error, got %v", err)
+	}
+	status, err = MigrationStatus(dbPath, Options{})
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	if last := status[len(status)-1]; last.Version != newer || last.Name != "future" || last.AppliedAt == nil {
+		t.Errorf("Expected the newer migration in the status, got %+v", last)
+	}
+}
+
+func TestCompact_ReclaimsSpace(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "compact.db"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "big", Title: "Big", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	content := strings.Repeat("lorem ipsum ", 2000)
+	for i := 0; i < 50; i++ {
+		if _, err := s.CreatePromptVersion("big", models.CreatePromptVersionInput{Content: content}); err != nil {
+			t.Fatalf("CreatePromptVersion failed: %v", err)
+		}
+	}
+	// Simulate pruning old versions so the file has free pages to release
+	if _, err := s.db.Exec(`DELETE FROM prompt_versions WHERE version_number BETWEEN 2 AND 50`); err != nil {
+		t.Fatalf("Failed to delete versions: %v", err)
+	}
+
+	result, err := s.Compact()
+	if err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if result.ReclaimedBytes <= 0 {
+		t.Errorf("Expected reclaimed space, got %+v", result)
+	}
+	if result.SizeAfterBytes != result.SizeBeforeBytes-result.ReclaimedBytes {
+		t.Errorf("Inconsistent sizes: %+v", result)
+	}
+
+	if _, err := s.GetPromptBySlug("big"); err != nil {
+		t.Errorf("GetPromptBySlug after compact failed: %v", err)
+	}
+}
+
+func TestPromptVariables_Validation(t *testing.T) {
+	s := setupTestStore(t)
+
+	vars := []models.Variable{{Name: "name", Type: "string", Required: true}}
+	if _, err := s.CreatePrompt(models.CreatePromptInput{
+		Slug: "bad", Title: "Bad", Content: "Hi {{name}} from {{place}}", Variables: vars,
+	}); err == nil || !strings.Contains(err.Error(), "invalid variables") {
+		t.Errorf("Expected undeclared placeholder to be rejected, got %v", err)
+	}
+
+	created, err := s.CreatePrompt(models.CreatePromptInput{
+		Slug: "greet", Title: "Greet", Content: "Hi {{name}}", Variables: vars,
+	})
+	if err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if len(created.Variables) != 1 {
+		t.Errorf("Expected 1 variable, got %+v", created.Variables)
+	}
+
+	if _, err := s.CreatePromptVersion("greet", models.CreatePromptVersionInput{Content: "Hi {{name}} in {{place}}"}); err == nil {
+		t.Error("Expected version with undeclared placeholder to be rejected")
+	}
+
+	// Declaring the new variable allows the version
+	vars = append(vars, models.Variable{Name: "place", Type: "string"})
+	if _, err := s.SetPromptVariables("greet", vars); err != nil {
+		t.Fatalf("SetPromptVariables failed: %v", err)
+	}
+	if _, err := s.CreatePromptVersion("greet", models.CreatePromptVersionInput{Content: "Hi {{name}} in {{place}}"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	// Removing a variable the current version uses is rejected
+	if _, err := s.SetPromptVariables("greet", vars[:1]); err == nil {
+		t.Error("Expected schema missing a used placeholder to be rejected")
+	}
+
+	got, err := s.GetPromptBySlug("greet")
+	if err != nil {
+		t.Fatalf("GetPromptBySlug failed: %v", err)
+	}
+	if len(got.Variables) != 2 || got.Variables[1].Name != "place" {
+		t.Errorf("Expected stored schema, got %+v", got.Variables)
+	}
+
+	if _, err := s.SetPromptVariables("missing", nil); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestSetPromptExecution(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "exec", Title: "Exec", Content: "Hi"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	prompt, err := s.GetPromptBySlug("exec")
+	if err != nil {
+		t.Fatalf("GetPromptBySlug failed: %v", err)
+	}
+	if prompt.Execution != nil {
+		t.Errorf("Expected no execution config by default, got %+v", prompt.Execution)
+	}
+
+	if err := s.SetPromptExecution("exec", models.ExecutionConfig{Provider: "anthropic", Model: "claude"}); err != nil {
+		t.Fatalf("SetPromptExecution failed: %v", err)
+	}
+	prompt, _ = s.GetPromptBySlug("exec")
+	if prompt.Execution == nil || prompt.Execution.Provider != "anthropic" || prompt.Execution.Model != "claude" {
+		t.Errorf("Unexpected execution config: %+v", prompt.Execution)
+	}
+
+	if err := s.SetPromptExecution("exec", models.ExecutionConfig{}); err != nil {
+		t.Fatalf("SetPromptExecution failed: %v", err)
+	}
+	prompt, _ = s.GetPromptBySlug("exec")
+	if prompt.Execution != nil {
+		t.Errorf("Expected execution config to be cleared, got %+v", prompt.Execution)
+	}
+
+	if err := s.SetPromptExecution("missing", models.ExecutionConfig{Provider: "openai"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewWithOptions(filepath.Join(dir, "tuned.db"), Options{BusyTimeout: 2500 * time.Millisecond, MaxOpenConns: 4})
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %v", err)
+	}
+	defer s.Close()
+
+	check := func() {
+		t.Helper()
+		var timeout int
+		if err := s.db.QueryRow(`PRAGMA busy_timeout`).Scan(&timeout); err != nil || timeout != 2500 {
+			t.Errorf("Expected busy_timeout 2500, got %d, %v", timeout, err)
+		}
+		if got := s.db.Stats().MaxOpenConnections; got != 4 {
+			t.Errorf("Expected 4 max open connections, got %d", got)
+		}
+	}
+	check()
+
+	// The options survive swapping to another file
+	other, err := New(filepath.Join(dir, "other.db"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	other.Close()
+	if _, err := s.Reopen(filepath.Join(dir, "other.db")); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	check()
+}
+
+func TestNew_Options(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	s, err := New(filepath.Join(t.TempDir(), "options.db"),
+		WithMaxOpenConns(2),
+		WithBusyTimeout(time.Second),
+		WithQueryTimeout(50*time.Millisecond),
+		WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer s.Close()
+
+	if got := s.db.Stats().MaxOpenConnections; got != 2 {
+		t.Errorf("Expected 2 max open connections, got %d", got)
+	}
+	if !strings.Contains(logs.String(), "database initialized") {
+		t.Errorf("Expected logs on the given logger, got %q", logs.String())
+	}
+
+	// A runaway statement is interrupted, and its connection is usable again
+	start := time.Now()
+	var n int
+	err = s.db.QueryRow(`WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT MAX(x) FROM c`).Scan(&n)
+	if err == nil {
+		t.Fatal("Expected the query to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the query to stop near its timeout, took %v", elapsed)
+	}
+	for i := range 5 {
+		slug := fmt.Sprintf("after-timeout-%d", i)
+		if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+			t.Fatalf("CreatePrompt after timeout failed: %v", err)
+		}
+	}
+	prompts, err := s.ListPrompts(10, 0, PromptSort{}, PromptFilter{})
+	if err != nil || len(prompts) != 5 {
+		t.Errorf("Expected 5 prompts, got %d, %v", len(prompts), err)
+	}
+
+	// Later options override earlier ones
+	if _, err := New(":memory:", WithOptions(Options{QueryTimeout: time.Second}), WithQueryTimeout(-time.Second)); err == nil || !strings.Contains(err.Error(), "invalid query timeout") {
+		t.Errorf("Expected an invalid query timeout error, got %v", err)
+	}
+	if _, err := New(":memory:", WithMaxOpenConns(-1)); err == nil || !strings.Contains(err.Error(), "invalid max open conns") {
+		t.Errorf("Expected an invalid max open conns error, got %v", err)
+	}
+}
+
+func TestReopen_SwapsDatabase(t *testing.T) {
+	dir := t.TempDir()
+	bluePath, greenPath := filepath.Join(dir, "blue.db"), filepath.Join(dir, "green.db")
+
+	green, err := New(greenPath)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := green.CreatePrompt(models.CreatePromptInput{Slug: "green", Title: "Green", Content: "g"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	green.Close()
+
+	s, err := New(bluePath)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "blue", Title: "Blue", Content: "b"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	// Reads running during the swap must land on one database or the other, never fail
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if _, err := s.ListPrompts(10, 0, PromptSort{}, PromptFilter{}); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	result, err := s.Reopen(greenPath)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Read failed during reopen: %v", err)
+	}
+
+	if result.PreviousPath != bluePath || result.Path != greenPath {
+		t.Errorf("Unexpected reopen result: %+v", result)
+	}
+	if _, err := s.GetPromptBySlug("green"); err != nil {
+		t.Errorf("Expected green prompt after reopen, got %v", err)
+	}
+	if _, err := s.GetPromptBySlug("blue"); err == nil {
+		t.Error("Expected blue prompt to be gone after reopen")
+	}
+}
+
+func TestReopen_RejectsMissingOrInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(filepath.Join(dir, "current.db"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "kept", Title: "Kept", Content: "k"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	if _, err := s.Reopen(filepath.Join(dir, "typo.db")); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte(strings.Repeat("not a database ", 100)), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := s.Reopen(garbage); err == nil || !strings.Contains(err.Error(), "invalid database") {
+		t.Errorf("Expected invalid database error, got %v", err)
+	}
+
+	if _, err := s.GetPromptBySlug("kept"); err != nil {
+		t.Errorf("Expected store to keep the current database after a failed reopen, got %v", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "prompts.db")
+	s, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "backed-up", Title: "Backed up", Content: "b"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	backupPath := filepath.Join(dir, "backup.db")
+	if err := s.Backup(backupPath); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "after", Title: "After", Content: "a"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	if _, err := s.Restore(filepath.Join(dir, "typo.db")); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte(strings.Repeat("not a database ", 100)), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := s.Restore(garbage); err == nil || !strings.Contains(err.Error(), "invalid backup") {
+		t.Errorf("Expected invalid backup error, got %v", err)
+	}
+	if _, err := s.GetPromptBySlug("after"); err != nil {
+		t.Fatalf("Expected failed restores to leave the database alone, got %v", err)
+	}
+
+	result, err := s.Restore(backupPath)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if result.BackupPath != backupPath || result.Path != dbPath || result.PreviousCopy == "" {
+		t.Errorf("Unexpected restore result: %+v", result)
+	}
+	if _, err := s.GetPromptBySlug("backed-up"); err != nil {
+		t.Errorf("Expected backed up prompt after restore, got %v", err)
+	}
+	if _, err := s.GetPromptBySlug("after"); err == nil {
+		t.Error("Expected prompt created after the backup to be gone")
+	}
+	if _, err := os.Stat(dbPath + ".restore-tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected staged copy to be cleaned up, got %v", err)
+	}
+
+	// The replaced database is kept intact
+	previous, err := New(result.PreviousCopy)
+	if err != nil {
+		t.Fatalf("Failed to open previous copy: %v", err)
+	}
+	defer previous.Close()
+	if _, err := previous.GetPromptBySlug("after"); err != nil {
+		t.Errorf("Expected previous copy to hold the replaced data, got %v", err)
+	}
+
+	// The restored database stays writable
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "next", Title: "Next", Content: "n"}); err != nil {
+		t.Errorf("CreatePrompt after restore failed: %v", err)
+	}
+}
+
+func TestRestoreFile(t *testing.T) {
+	dir := t.TempDir()
+	source, err := New(filepath.Join(dir, "source.db"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := source.CreatePrompt(models.CreatePromptInput{Slug: "restored", Title: "Restored", Content: "r"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	backupPath := filepath.Join(dir, "backup.db")
+	if err := source.Backup(backupPath); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	source.Close()
+
+	// Restoring into a path with no database yet leaves nothing to move aside
+	dbPath := filepath.Join(dir, "fresh.db")
+	result, err := RestoreFile(backupPath, dbPath)
+	if err != nil {
+		t.Fatalf("RestoreFile failed: %v", err)
+	}
+	if result.PreviousCopy != "" {
+		t.Errorf("Expected no previous copy, got %q", result.PreviousCopy)
+	}
+	s, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.GetPromptBySlug("restored"); err != nil {
+		t.Errorf("Expected restored prompt, got %v", err)
+	}
+
+	if _, err := RestoreFile(filepath.Join(dir, "missing.db"), dbPath); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestPromptWebhooks(t *testing.T) {
+	s := setupTestStore(t)
+
+	for _, slug := range []string{"owned", "other"} {
+		if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "x"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+
+	hooks, err := s.ListPromptWebhooks("owned")
+	if err != nil || len(hooks) != 0 {
+		t.Fatalf("Expected no webhooks, got %v, %v", hooks, err)
+	}
+
+	first, err := s.AddPromptWebhook("owned", "https://team.example.com/hook")
+	if err != nil {
+		t.Fatalf("AddPromptWebhook failed: %v", err)
+	}
+	if _, err := s.AddPromptWebhook("owned", "https://oncall.example.com/hook"); err != nil {
+		t.Fatalf("AddPromptWebhook failed: %v", err)
+	}
+	if _, err := s.AddPromptWebhook("other", "https://team.example.com/hook"); err != nil {
+		t.Errorf("Expected the same URL to be allowed on another prompt, got %v", err)
+	}
+	if _, err := s.AddPromptWebhook("owned", "https://team.example.com/hook"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected already exists error, got %v", err)
+	}
+	if _, err := s.AddPromptWebhook("missing", "https://team.example.com/hook"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	hooks, _ = s.ListPromptWebhooks("owned")
+	if len(hooks) != 2 || hooks[0].ID != first.ID || hooks[0].CreatedAt.IsZero() {
+		t.Errorf("Unexpected webhooks: %+v", hooks)
+	}
+
+	if err := s.DeletePromptWebhook("other", first.ID); err == nil {
+		t.Error("Expected deleting through another prompt to fail")
+	}
+	if err := s.DeletePromptWebhook("owned", first.ID); err != nil {
+		t.Fatalf("DeletePromptWebhook failed: %v", err)
+	}
+	hooks, _ = s.ListPromptWebhooks("owned")
+	if len(hooks) != 1 || hooks[0].URL != "https://oncall.example.com/hook" {
+		t.Errorf("Unexpected webhooks after delete: %+v", hooks)
+	}
+}
+
+func TestDatasetsAndEvalRuns(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "capital", Title: "Capital", Content: "Capital of {{country}}?"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	input := models.CreateDatasetInput{
+		Name: "capitals",
+		Items: []models.DatasetItem{
+			{Input: map[string]any{"country": "France"}, Expected: "Paris"},
+			{Input: map[string]any{"country": "Japan"}, Expected: "Tokyo"},
+		},
+	}
+	dataset, err := s.CreateDataset(input)
+	if err != nil {
+		t.Fatalf("CreateDataset failed: %v", err)
+	}
+	if dataset.ItemCount != 2 || dataset.Items[0].ID == 0 {
+		t.Errorf("Unexpected dataset: %+v", dataset)
+	}
+	if _, err := s.CreateDataset(input); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected already exists error, got %v", err)
+	}
+	if _, err := s.CreateDataset(models.CreateDatasetInput{Name: "empty"}); err == nil || !strings.Contains(err.Error(), "cannot be empty") {
+		t.Errorf("Expected cannot be empty error, got %v", err)
+	}
+
+	datasets, err := s.ListDatasets()
+	if err != nil || len(datasets) != 1 || datasets[0].ItemCount != 2 || datasets[0].Items != nil {
+		t.Errorf("Unexpected datasets: %+v, %v", datasets, err)
+	}
+	if _, err := s.GetDataset("missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	run, err := s.CreateEvalRun(models.EvalRun{Slug: "capital", VersionNumber: 1, Dataset: "capitals", Provider: "openai", Model: "m", Scorer: "exact"})
+	if err != nil {
+		t.Fatalf("CreateEvalRun failed: %v", err)
+	}
+	if run.Total != 2 || run.Status != "running" {
+		t.Errorf("Unexpected run: %+v", run)
+	}
+
+	if err := s.AddEvalResult(run.ID, models.EvalResult{ItemID: dataset.Items[0].ID, Output: "Paris", Score: 1, Passed: true}); err != nil {
+		t.Fatalf("AddEvalResult failed: %v", err)
+	}
+	if err := s.AddEvalResult(run.ID, models.EvalResult{ItemID: dataset.Items[1].ID, Output: "Kyoto"}); err != nil {
+		t.Fatalf("AddEvalResult failed: %v", err)
+	}
+	if err := s.FinishEvalRun(run.ID, ""); err != nil {
+		t.Fatalf("FinishEvalRun failed: %v", err)
+	}
+
+	got, err := s.GetEvalRun(run.ID)
+	if err != nil {
+		t.Fatalf("GetEvalRun failed: %v", err)
+	}
+	if got.Status != "completed" || got.Completed != 2 || got.Passed != 1 || got.Score != 0.5 || got.FinishedAt == nil {
+		t.Errorf("Unexpected run: %+v", got)
+	}
+	if len(got.Results) != 2 || got.Results[0].Input["country"] != "France" || got.Results[0].Expected != "Paris" {
+		t.Errorf("Unexpected results: %+v", got.Results)
+	}
+
+	runs, err := s.ListEvalRuns("capital")
+	if err != nil || len(runs) != 1 || runs[0].Results != nil {
+		t.Errorf("Unexpected runs: %+v, %v", runs, err)
+	}
+	if _, err := s.GetEvalRun(999); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestExportPrompts(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "first", Title: "First", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := s.CreatePromptVersion("first", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if err := s.SetPromptExecution("first", models.ExecutionConfig{Provider: "anthropic"}); err != nil {
+		t.Fatalf("SetPromptExecution failed: %v", err)
+	}
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "second", Title: "Second", Content: "only"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	var exported []models.ExportedPrompt
+	count, err := s.ExportPrompts(func(p models.ExportedPrompt) error {
+		exported = append(exported, p)
+		return nil
+	})
+	if err != nil || count != 2 || len(exported) != 2 {
+		t.Fatalf("Expected 2 exported prompts, got %d, %v", count, err)
+	}
+	first := exported[0]
+	if first.Slug != "first" || len(first.Versions) != 2 || first.Versions[1].Content != "v2" || first.Versions[1].VersionNumber != 2 {
+		t.Errorf("Unexpected first prompt: %+v", first)
+	}
+	if first.Execution == nil || first.Execution.Provider != "anthropic" || exported[1].Execution != nil {
+		t.Errorf("Unexpected execution configs: %+v, %+v", first.Execution, exported[1].Execution)
+	}
+
+	stop := errors.New("stop")
+	count, err = s.ExportPrompts(func(p models.ExportedPrompt) error { return stop })
+	if !errors.Is(err, stop) || count != 0 {
+		t.Errorf("Expected callback error to stop the export, got %d, %v", count, err)
+	}
+}
+
+func TestImportPromptVersions(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "legacy", Title: "Legacy", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	original := time.Date(2023, 3, 14, 9, 30, 0, 0, time.UTC)
+	results, err := s.ImportPromptVersions("legacy", models.ImportVersionsInput{Versions: []models.ImportVersion{
+		{Content: "v2", CreatedAt: &original},
+		{Content: "v3"},
+	}})
+	if err != nil {
+		t.Fatalf("ImportPromptVersions failed: %v", err)
+	}
+	if len(results) != 2 || results[0].VersionNumber != 2 || results[1].VersionNumber != 3 || results[0].ID == 0 {
+		t.Fatalf("Unexpected imported versions: %+v", results)
+	}
+
+	versions, err := s.ListPromptVersions("legacy", 100, 0)
+	if err != nil || len(versions) != 3 {
+		t.Fatalf("Expected 3 versions, got %d, %v", len(versions), err)
+	}
+	if versions[1].OriginalCreatedAt == nil || !versions[1].OriginalCreatedAt.Equal(original) {
+		t.Errorf("Expected original_created_at %v, got %v", original, versions[1].OriginalCreatedAt)
+	}
+	if versions[0].OriginalCreatedAt != nil || versions[2].OriginalCreatedAt != nil || versions[1].CreatedAt.IsZero() {
+		t.Errorf("Unexpected timestamps: %+v", versions)
+	}
+	prompt, _ := s.GetPromptBySlug("legacy")
+	if prompt.CurrentVersion.VersionNumber != 3 || prompt.CurrentVersion.Content != "v3" {
+		t.Errorf("Expected current version 3, got %+v", prompt.CurrentVersion)
+	}
+
+	// A bad entry rejects the whole batch
+	_, err = s.ImportPromptVersions("legacy", models.ImportVersionsInput{Versions: []models.ImportVersion{
+		{Content: "v4"},
+		{Content: "  "},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "version 2 in batch: content cannot be empty") {
+		t.Errorf("Expected empty content error, got %v", err)
+	}
+	if _, err := s.SetPromptVariables("legacy", []models.Variable{{Name: "text", Type: "string"}}); err != nil {
+		t.Fatalf("SetPromptVariables failed: %v", err)
+	}
+	_, err = s.ImportPromptVersions("legacy", models.ImportVersionsInput{Versions: []models.ImportVersion{
+		{Content: "{{text}}"},
+		{Content: "{{other}}"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "invalid variables") {
+		t.Errorf("Expected invalid variables error, got %v", err)
+	}
+	if versions, _ := s.ListPromptVersions("legacy", 100, 0); len(versions) != 3 {
+		t.Errorf("Expected failed batches to add nothing, got %d versions", len(versions))
+	}
+
+	if _, err := s.ImportPromptVersions("legacy", models.ImportVersionsInput{}); err == nil || !strings.Contains(err.Error(), "cannot be empty") {
+		t.Errorf("Expected empty batch error, got %v", err)
+	}
+	if _, err := s.ImportPromptVersions("missing", models.ImportVersionsInput{Versions: []models.ImportVersion{{Content: "x"}}}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestImportPrompts(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "existing", Title: "Existing", Content: "mine"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	created := time.Date(2022, 1, 10, 8, 0, 0, 0, time.FixedZone("EST", -5*60*60))
+	edited := time.Date(2022, 2, 1, 8, 0, 0, 0, time.UTC)
+	first := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	result, err := s.ImportPrompts([]models.ExportedPrompt{
+		{
+			Slug:      "legacy",
+			Title:     "Legacy",
+			Variables: []models.Variable{{Name: "name", Type: "string"}},
+			Execution: &models.ExecutionConfig{Provider: "local"},
+			CreatedAt: created,
+			Versions: []models.ExportedVersion{
+				{VersionNumber: 1, Content: "Hi", CreatedAt: created},
+				{VersionNumber: 3, Content: "Hi {{name}}", CreatedAt: time.Now(), OriginalCreatedAt: &edited},
+			},
+		},
+		{Slug: "existing", Title: "Theirs", Versions: []models.ExportedVersion{{VersionNumber: 1, Content: "theirs"}}},
+		{
+			Slug:              "reimported",
+			Title:             "Reimported",
+			CreatedAt:         time.Now(),
+			OriginalCreatedAt: &first,
+			Versions:          []models.ExportedVersion{{VersionNumber: 1, Content: "x"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ImportPrompts failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Imported, []string{"legacy", "reimported"}) || !reflect.DeepEqual(result.Skipped, []string{"existing"}) || result.Versions != 3 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	prompt, err := s.GetPromptBySlug("legacy")
+	if err != nil {
+		t.Fatalf("GetPromptBySlug failed: %v", err)
+	}
+	if prompt.CurrentVersion.VersionNumber != 3 || prompt.Execution == nil || len(prompt.Variables) != 1 {
+		t.Errorf("Unexpected imported prompt: %+v", prompt)
+	}
+	if prompt.OriginalCreatedAt == nil || !prompt.OriginalCreatedAt.Equal(created) || prompt.CreatedAt.Before(time.Now().Add(-time.Hour)) {
+		t.Errorf("Expected original creation time %v alongside a fresh created_at, got %v and %v", created, prompt.OriginalCreatedAt, prompt.CreatedAt)
+	}
+	if prompt.CurrentVersion.OriginalCreatedAt == nil || !prompt.CurrentVersion.OriginalCreatedAt.Equal(edited) {
+		t.Errorf("Expected the version's earliest known time %v, got %v", edited, prompt.CurrentVersion.OriginalCreatedAt)
+	}
+	if existing, _ := s.GetPromptBySlug("existing"); existing.Title != "Existing" || existing.OriginalCreatedAt != nil {
+		t.Errorf("Expected the existing prompt to be left alone, got %+v", existing)
+	}
+
+	// Imported prompts sort by when they were originally created
+	summaries, err := s.ListPrompts(10, 0, PromptSort{}, PromptFilter{})
+	if err != nil {
+		t.Fatalf("ListPrompts failed: %v", err)
+	}
+	var order []string
+	for _, summary := range summaries {
+		order = append(order, summary.Slug)
+	}
+	if !reflect.DeepEqual(order, []string{"existing", "legacy", "reimported"}) {
+		t.Errorf("Expected original creation order, got %v", order)
+	}
+	if summaries[2].OriginalCreatedAt == nil || !summaries[2].OriginalCreatedAt.Equal(first) {
+		t.Errorf("Expected summary to carry original_created_at, got %+v", summaries[2])
+	}
+
+	// One invalid prompt rejects the whole import
+	_, err = s.ImportPrompts([]models.ExportedPrompt{
+		{Slug: "fine", Title: "Fine", Versions: []models.ExportedVersion{{VersionNumber: 1, Content: "ok"}}},
+		{Slug: "broken", Title: "Broken", Versions: []models.ExportedVersion{{VersionNumber: 2, Content: "b"}, {VersionNumber: 1, Content: "a"}}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid version numbers") {
+		t.Errorf("Expected version number error, got %v", err)
+	}
+	if _, err := s.GetPromptBySlug("fine"); err == nil {
+		t.Error("Expected a rejected import to create nothing")
+	}
+	_, err = s.ImportPrompts([]models.ExportedPrompt{{
+		Slug:      "undeclared",
+		Title:     "Undeclared",
+		Variables: []models.Variable{{Name: "name", Type: "string"}},
+		Versions:  []models.ExportedVersion{{VersionNumber: 1, Content: "{{other}}"}},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "invalid variables") {
+		t.Errorf("Expected invalid variables error, got %v", err)
+	}
+}
+
+func TestVersionPinning(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "terms", Title: "Terms", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := s.CreatePromptVersion("terms", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	version, err := s.SetVersionPinned("terms", 1, true)
+	if err != nil {
+		t.Fatalf("SetVersionPinned failed: %v", err)
+	}
+	if !version.Pinned || version.VersionNumber != 1 || version.Content != "v1" {
+		t.Errorf("Unexpected pinned version: %+v", version)
+	}
+	versions, err := s.ListPromptVersions("terms", 100, 0)
+	if err != nil {
+		t.Fatalf("ListPromptVersions failed: %v", err)
+	}
+	if len(versions) != 2 || !versions[0].Pinned || versions[1].Pinned {
+		t.Errorf("Expected only version 1 pinned in listing, got %+v", versions)
+	}
+	if prompt, err := s.GetPromptBySlug("terms"); err != nil || prompt.CurrentVersion.Pinned {
+		t.Errorf("Expected unpinned current version, got %+v, %v", prompt, err)
+	}
+
+	if _, err := s.SetVersionPinned("terms", 3, true); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error for missing version, got %v", err)
+	}
+	// Pinning again is harmless
+	if _, err := s.SetVersionPinned("terms", 1, true); err != nil {
+		t.Fatalf("SetVersionPinned failed: %v", err)
+	}
+	if _, err := s.InProject("other").SetVersionPinned("terms", 1, false); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error in another project, got %v", err)
+	}
+
+	// The pin survives an export and import into another project
+	var exported []models.ExportedPrompt
+	if _, err := s.ExportPrompts(func(p models.ExportedPrompt) error {
+		exported = append(exported, p)
+		return nil
+	}); err != nil {
+		t.Fatalf("ExportPrompts failed: %v", err)
+	}
+	if len(exported) != 1 || !exported[0].Versions[0].Pinned || exported[0].Versions[1].Pinned {
+		t.Fatalf("Expected pin in export, got %+v", exported)
+	}
+	other := s.InProject("other")
+	if _, err := other.ImportPrompts(exported); err != nil {
+		t.Fatalf("ImportPrompts failed: %v", err)
+	}
+	if version, err := other.GetPromptVersion("terms", 1); err != nil || !version.Pinned {
+		t.Errorf("Expected imported version to stay pinned, got %+v, %v", version, err)
+	}
+
+	if version, err := s.SetVersionPinned("terms", 1, false); err != nil || version.Pinned {
+		t.Errorf("Expected version to be unpinned, got %+v, %v", version, err)
+	}
+	entries, err := s.ListAuditEntries("terms", 10, 0)
+	if err != nil {
+		t.Fatalf("ListAuditEntries failed: %v", err)
+	}
+	var actions []string
+	for _, entry := range entries {
+		if entry.Version == 1 {
+			actions = append(actions, entry.Action)
+		}
+	}
+	if strings.Join(actions, ",") != "prompt.version_unpinned,prompt.version_pinned,prompt.version_pinned,prompt.created" {
+		t.Errorf("Unexpected pin audit entries: %v", actions)
+	}
+}
+
+func TestLegalHold(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "terms", Title: "Terms", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if err := s.SetLegalHold("terms", true, "  "); err == nil || !strings.Contains(err.Error(), "cannot be empty") {
+		t.Errorf("Expected error for hold without a reason, got %v", err)
+	}
+	if err := s.SetLegalHold("missing", true, "case 42"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	if err := s.SetLegalHold("terms", true, "case 42"); err != nil {
+		t.Fatalf("SetLegalHold failed: %v", err)
+	}
+	held, err := s.GetPromptBySlug("terms")
+	if err != nil {
+		t.Fatalf("GetPromptBySlug failed: %v", err)
+	}
+	if held.LegalHoldAt == nil || held.LegalHoldReason != "case 42" {
+		t.Fatalf("Expected held prompt, got %+v", held)
+	}
+	// A new reason keeps the original hold time
+	if _, err := s.db.Exec(`UPDATE prompts SET legal_hold_at = '2020-01-01 00:00:00'`); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetLegalHold("terms", true, "case 43"); err != nil {
+		t.Fatalf("SetLegalHold failed: %v", err)
+	}
+	held, _ = s.GetPromptBySlug("terms")
+	if held.LegalHoldAt == nil || held.LegalHoldAt.Year() != 2020 || held.LegalHoldReason != "case 43" {
+		t.Errorf("Expected original hold time with new reason, got %v %q", held.LegalHoldAt, held.LegalHoldReason)
+	}
+
+	if err := s.SetLegalHold("terms", false, "ignored"); err != nil {
+		t.Fatalf("SetLegalHold failed: %v", err)
+	}
+	released, _ := s.GetPromptBySlug("terms")
+	if released.LegalHoldAt != nil || released.LegalHoldReason != "" {
+		t.Errorf("Expected hold to be released, got %v %q", released.LegalHoldAt, released.LegalHoldReason)
+	}
+
+	entries, err := s.ListAuditEntries("terms", 3, 0)
+	if err != nil {
+		t.Fatalf("ListAuditEntries failed: %v", err)
+	}
+	if len(entries) != 3 || entries[0].Action != "prompt.legal_hold_released" ||
+		entries[1].Action != "prompt.legal_hold_placed" || entries[1].Detail != "case 43" || entries[2].Detail != "case 42" {
+		t.Errorf("Unexpected legal hold audit entries: %+v", entries)
+	}
+}
+
+func TestPromptACL(t *testing.T) {
+	base := setupTestStore(t)
+	as := func(subject string) Store {
+		return base.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: subject, Method: "apikey"}))
+	}
+	alice, bob, carol := as("alice"), as("bob"), as("carol")
+
+	if _, err := alice.CreatePrompt(models.CreatePromptInput{Slug: "secret", Title: "Secret", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := base.CreatePrompt(models.CreatePromptInput{Slug: "legacy", Title: "Legacy", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if prompt, err := base.GetPromptBySlug("secret"); err != nil || prompt.Owner != "alice" {
+		t.Fatalf("Expected alice to own the prompt, got %q, %v", prompt.Owner, err)
+	}
+
+	if _, err := bob.SetPromptACL("secret", models.SetPromptACLInput{}); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected permission denied for non-owner, got %v", err)
+	}
+	acl, err := alice.SetPromptACL("secret", models.SetPromptACLInput{Grants: []models.PromptGrant{{Type: GranteeUser, Name: "bob", Access: AccessRead}}})
+	if err != nil {
+		t.Fatalf("SetPromptACL failed: %v", err)
+	}
+	if acl.Owner != "alice" || len(acl.Grants) != 1 || acl.Grants[0].GrantedBy != "alice" {
+		t.Errorf("Unexpected acl: %+v", acl)
+	}
+
+	if err := bob.AuthorizePrompt("secret", AccessRead); err != nil {
+		t.Errorf("Expected bob to read, got %v", err)
+	}
+	if err := bob.AuthorizePrompt("secret", AccessWrite); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected bob to be denied writes, got %v", err)
+	}
+	if err := carol.AuthorizePrompt("secret", AccessRead); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected the prompt to be hidden from carol, got %v", err)
+	}
+	// Missing prompts pass, and internal callers without an identity see everything
+	if err := carol.AuthorizePrompt("missing", AccessWrite); err != nil {
+		t.Errorf("Expected missing prompt to pass, got %v", err)
+	}
+	if err := base.AuthorizePrompt("secret", AccessWrite); err != nil {
+		t.Errorf("Expected internal caller to pass, got %v", err)
+	}
+	for store, want := range map[Store]int{carol: 1, bob: 2, base: 2} {
+		if prompts, err := store.ListPrompts(10, 0, PromptSort{}, PromptFilter{}); err != nil || len(prompts) != want {
+			t.Errorf("Expected %d listed prompts, got %d, %v", want, len(prompts), err)
+		}
+	}
+	// Project counts leave out prompts the caller can't read
+	for store, want := range map[Store]int{carol: 1, bob: 2, base: 2} {
+		if projects, err := store.ListProjects(); err != nil || len(projects) != 1 || projects[0].Prompts != want {
+			t.Errorf("Expected %d prompts counted, got %+v, %v", want, projects, err)
+		}
+	}
+
+	// The first caller to restrict an unowned prompt becomes its owner
+	if acl, err := carol.SetPromptACL("legacy", models.SetPromptACLInput{Grants: []models.PromptGrant{}}); err != nil || acl.Owner != "carol" {
+		t.Errorf("Expected carol to claim the prompt, got %+v, %v", acl, err)
+	}
+	entries, err := base.ListAuditEntries("secret", 1, 0)
+	if err != nil || len(entries) != 1 || entries[0].Action != "prompt.acl_changed" || entries[0].Detail != "owner=alice user:bob=read" {
+		t.Errorf("Unexpected audit entry: %+v, %v", entries, err)
+	}
+}
+
+func TestVersionContentImmutable(t *testing.T) {
+	s := setupTestStore(t)
+	created, err := s.CreatePrompt(models.CreatePromptInput{Slug: "frozen", Title: "Frozen", Content: "original"})
+	if err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	versionID := created.CurrentVersion.ID
+
+	_, err = s.db.Exec(`UPDATE prompt_versions SET content = 'edited' WHERE id = ?`, versionID)
+	if err == nil || !strings.Contains(err.Error(), "immutable") {
+		t.Fatalf("Expected content update to be rejected, got %v", err)
+	}
+
+	// Other columns stay writable, and setting content to itself is allowed
+	if _, err := s.SetVersionPinned("frozen", 1, true); err != nil {
+		t.Fatalf("SetVersionPinned failed: %v", err)
+	}
+	if _, err := s.db.Exec(`UPDATE prompt_versions SET content = content, pinned = 0 WHERE id = ?`, versionID); err != nil {
+		t.Fatalf("Expected unchanged content to be allowed, got %v", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := rewriteVersionContent(tx, versionID, "rewritten"); err != nil {
+		tx.Rollback()
+		t.Fatalf("rewriteVersionContent failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	version, err := s.GetPromptVersion("frozen", 1)
+	if err != nil {
+		t.Fatalf("GetPromptVersion failed: %v", err)
+	}
+	if version.Content != "rewritten" {
+		t.Errorf("Expected rewritten content, got %q", version.Content)
+	}
+
+	// The unlock lasts only as long as the rewrite
+	if _, err := s.db.Exec(`UPDATE prompt_versions SET content = 'edited' WHERE id = ?`, versionID); err == nil {
+		t.Error("Expected content to be locked again after the rewrite")
+	}
+}
+
+func TestRedactPromptVersion(t *testing.T) {
+	s := setupTestStore(t)
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "support", Title: "Support", Content: "password hunter2"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := s.CreatePromptVersion("support", models.CreatePromptVersionInput{Content: "password {{password}}"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	if _, err := s.RedactPromptVersion("support", 1, " "); err == nil || !strings.Contains(err.Error(), "cannot be empty") {
+		t.Errorf("Expected error for redaction without a reason, got %v", err)
+	}
+	if _, err := s.RedactPromptVersion("support", 3, "SEC-7"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	redacted, err := s.RedactPromptVersion("support", 1, "SEC-7")
+	if err != nil {
+		t.Fatalf("RedactPromptVersion failed: %v", err)
+	}
+	if redacted.VersionNumber != 1 || redacted.RedactedAt == nil || redacted.Content != redactionNotice("SEC-7", *redacted.RedactedAt) {
+		t.Errorf("Unexpected redacted version: %+v", redacted)
+	}
+	// sha256("password hunter2")
+	if want := "7a03da824a8733b021ef7aa8995f948fb683d7395f5fb9631d34d39333bf83dd"; redacted.OriginalSHA256 != want {
+		t.Errorf("Expected SHA-256 %s of the original, got %q", want, redacted.OriginalSHA256)
+	}
+
+	// Reads see the notice; other versions are untouched
+	versions, err := s.ListPromptVersions("support", -1, 0)
+	if err != nil {
+		t.Fatalf("ListPromptVersions failed: %v", err)
+	}
+	if len(versions) != 2 || strings.Contains(versions[0].Content, "hunter2") || versions[1].RedactedAt != nil || versions[1].Content != "password {{password}}" {
+		t.Errorf("Unexpected versions after redaction: %+v", versions)
+	}
+
+	if _, err := s.RedactPromptVersion("support", 1, "SEC-7"); err == nil || !strings.Contains(err.Error(), "already redacted") {
+		t.Errorf("Expected already redacted error, got %v", err)
+	}
+	if err := s.SetLegalHold("support", true, "case 42"); err != nil {
+		t.Fatalf("SetLegalHold failed: %v", err)
+	}
+	if _, err := s.RedactPromptVersion("support", 2, "SEC-8"); err == nil || !strings.Contains(err.Error(), "legal hold") {
+		t.Errorf("Expected legal hold error, got %v", err)
+	}
+}
+
+func TestReleases(t *testing.T) {
+	s := setupTestStore(t)
+	for _, slug := range []string{"planner", "critic"} {
+		if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: slug + " v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+		if _, err := s.CreatePromptVersion(slug, models.CreatePromptVersionInput{Content: slug + " v2"}); err != nil {
+			t.Fatalf("CreatePromptVersion failed: %v", err)
+		}
+	}
+
+	first, err := s.CreateRelease(models.CreateReleaseInput{Label: "production", Items: []models.ReleaseItem{
+		{Slug: "planner", Version: 1}, {Slug: "critic", Version: 1},
+	}})
+	if err != nil {
+		t.Fatalf("CreateRelease failed: %v", err)
+	}
+
+	// A bad item fails the whole release, leaving the other label alone
+	_, err = s.CreateRelease(models.CreateReleaseInput{Label: "production", Items: []models.ReleaseItem{
+		{Slug: "planner", Version: 2}, {Slug: "critic", Version: 9},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+	if label, err := s.GetPromptLabel("planner", "production"); err != nil || label.Version != 1 || label.ReleaseID != first.ID {
+		t.Errorf("Expected planner still at v1 from release %d, got %+v, %v", first.ID, label, err)
+	}
+
+	for _, input := range []models.CreateReleaseInput{
+		{Label: "Prod!", Items: []models.ReleaseItem{{Slug: "planner", Version: 1}}},
+		{Label: "production"},
+		{Label: "production", Items: []models.ReleaseItem{{Slug: "planner", Version: 1}, {Slug: "planner", Version: 2}}},
+	} {
+		if _, err := s.CreateRelease(input); err == nil {
+			t.Errorf("Expected error for %+v", input)
+		}
+	}
+
+	second, err := s.CreateRelease(models.CreateReleaseInput{Label: "production", Note: "new pipeline", Items: []models.ReleaseItem{
+		{Slug: "planner", Version: 2}, {Slug: "critic", Version: 2},
+	}})
+	if err != nil {
+		t.Fatalf("CreateRelease failed: %v", err)
+	}
+	if len(second.Items) != 2 || second.Items[0].PreviousVersion != 1 {
+		t.Errorf("Unexpected release: %+v", second)
+	}
+
+	// The first release's labels have moved on, so it can't be rolled back
+	if _, err := s.RollbackRelease(first.ID); err == nil || !strings.Contains(err.Error(), "has changed since") {
+		t.Errorf("Expected changed label error, got %v", err)
+	}
+
+	rolledBack, err := s.RollbackRelease(second.ID)
+	if err != nil {
+		t.Fatalf("RollbackRelease failed: %v", err)
+	}
+	if rolledBack.RolledBackAt == nil {
+		t.Errorf("Expected rolled back release, got %+v", rolledBack)
+	}
+	for _, slug := range []string{"planner", "critic"} {
+		if label, err := s.GetPromptLabel(slug, "production"); err != nil || label.Version != 1 || label.ReleaseID != first.ID {
+			t.Errorf("Expected %s back at v1 from release %d, got %+v, %v", slug, first.ID, label, err)
+		}
+	}
+	if _, err := s.RollbackRelease(second.ID); err == nil || !strings.Contains(err.Error(), "already rolled back") {
+		t.Errorf("Expected already rolled back error, got %v", err)
+	}
+
+	// Rolling back the first release removes the label it added
+	if _, err := s.RollbackRelease(first.ID); err != nil {
+		t.Fatalf("RollbackRelease failed: %v", err)
+	}
+	if labels, err := s.ListPromptLabels("planner"); err != nil || len(labels) != 0 {
+		t.Errorf("Expected no labels, got %+v, %v", labels, err)
+	}
+
+	releases, err := s.ListReleases(ReleaseFilter{Label: "production"}, 10, 0)
+	if err != nil {
+		t.Fatalf("ListReleases failed: %v", err)
+	}
+	if len(releases) != 2 || releases[0].ID != second.ID {
+		t.Errorf("Expected 2 releases newest first, got %+v", releases)
+	}
+
+	if _, err := s.RedactPromptVersion("critic", 2, "SEC-1"); err != nil {
+		t.Fatalf("RedactPromptVersion failed: %v", err)
+	}
+	_, err = s.CreateRelease(models.CreateReleaseInput{Label: "production", Items: []models.ReleaseItem{{Slug: "critic", Version: 2}}})
+	if err == nil || !strings.Contains(err.Error(), "can't be released") {
+		t.Errorf("Expected redacted version error, got %v", err)
+	}
+}
+
+func TestReleaseHistoryAccess(t *testing.T) {
+	base := setupTestStore(t)
+	as := func(subject string) Store {
+		return base.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: subject, Method: "apikey"}))
+	}
+	alice, bob := as("alice"), as("bob")
+
+	if _, err := alice.CreatePrompt(models.CreatePromptInput{Slug: "secret", Title: "Secret", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	grants := []models.PromptGrant{{Type: GranteeUser, Name: "carol", Access: AccessRead}}
+	if _, err := alice.SetPromptACL("secret", models.SetPromptACLInput{Grants: grants}); err != nil {
+		t.Fatalf("SetPromptACL failed: %v", err)
+	}
+	if _, err := base.CreatePrompt(models.CreatePromptInput{Slug: "shared", Title: "Shared", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	release, err := alice.CreateRelease(models.CreateReleaseInput{Label: "production", Items: []models.ReleaseItem{
+		{Slug: "secret", Version: 1}, {Slug: "shared", Version: 1},
+	}})
+	if err != nil {
+		t.Fatalf("CreateRelease failed: %v", err)
+	}
+
+	// History lists each release's items, minus prompts the caller can't read
+	releases, err := alice.ListReleases(ReleaseFilter{Slug: "secret"}, 10, 0)
+	if err != nil || len(releases) != 1 || len(releases[0].Items) != 2 {
+		t.Errorf("Expected alice to see both items, got %+v, %v", releases, err)
+	}
+	releases, err = bob.ListReleases(ReleaseFilter{}, 10, 0)
+	if err != nil || len(releases) != 1 || len(releases[0].Items) != 1 || releases[0].Items[0].Slug != "shared" {
+		t.Errorf("Expected bob to see only the shared item, got %+v, %v", releases, err)
+	}
+	if releases, err := bob.ListReleases(ReleaseFilter{Slug: "secret"}, 10, 0); err != nil || len(releases) != 0 {
+		t.Errorf("Expected no releases for a prompt bob can't read, got %+v, %v", releases, err)
+	}
+
+	if _, err := bob.RollbackRelease(release.ID); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected permission denied, got %v", err)
+	}
+	if _, err := alice.RollbackRelease(release.ID); err != nil {
+		t.Errorf("RollbackRelease failed: %v", err)
+	}
+}
+
+func TestForkPrompt(t *testing.T) {
+	base := setupTestStore(t)
+	as := func(subject string) Store {
+		return base.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: subject, Method: "apikey"}))
+	}
+	alice, bob, carol := as("alice"), as("bob"), as("carol")
+
+	vars := []models.Variable{{Name: "name", Type: "string"}}
+	if _, err := alice.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Greeting", Description: "Greets someone", Content: "Hi {{name}}", Variables: vars}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	for _, content := range []string{"Hello {{name}}", "Hello, {{name}}!"} {
+		if _, err := alice.CreatePromptVersion("greeting", models.CreatePromptVersionInput{Content: content}); err != nil {
+			t.Fatalf("CreatePromptVersion failed: %v", err)
+		}
+	}
+
+	fork, err := alice.ForkPrompt("greeting", models.ForkPromptInput{Slug: "greeting-formal", Title: "Formal greeting"})
+	if err != nil {
+		t.Fatalf("ForkPrompt failed: %v", err)
+	}
+	if fork.Title != "Formal greeting" || fork.Description != "Greets someone" || len(fork.Variables) != 1 {
+		t.Errorf("Expected the fork to keep the description and variables, got %+v", fork)
+	}
+	if fork.CurrentVersion.VersionNumber != 1 || fork.CurrentVersion.Content != "Hello, {{name}}!" {
+		t.Errorf("Expected version 1 with the current content, got %+v", fork.CurrentVersion)
+	}
+	if fork.ForkedFrom == nil || *fork.ForkedFrom != (models.ForkOrigin{Slug: "greeting", Version: 3}) {
+		t.Errorf("Expected forked_from greeting version 3, got %+v", fork.ForkedFrom)
+	}
+	if source, _ := alice.GetPromptBySlug("greeting"); source.ForkedFrom != nil {
+		t.Errorf("Expected the source to have no fork origin, got %+v", source.ForkedFrom)
+	}
+
+	full, err := alice.ForkPrompt("greeting", models.ForkPromptInput{Slug: "greeting-full", History: true})
+	if err != nil {
+		t.Fatalf("ForkPrompt with history failed: %v", err)
+	}
+	if full.Title != "Greeting" || full.CurrentVersion.VersionNumber != 3 {
+		t.Errorf("Expected the source title at version 3, got %q at %d", full.Title, full.CurrentVersion.VersionNumber)
+	}
+	versions, err := alice.ListPromptVersions("greeting-full", 10, 0)
+	if err != nil || len(versions) != 3 {
+		t.Fatalf("Expected 3 copied versions, got %d, %v", len(versions), err)
+	}
+	for _, v := range versions {
+		if v.CreatedBy != "alice" || v.OriginalCreatedAt == nil {
+			t.Errorf("Expected version %d to keep its author and creation time, got %+v", v.VersionNumber, v)
+		}
+	}
+
+	if _, err := alice.ForkPrompt("greeting", models.ForkPromptInput{Slug: "greeting-full"}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected already exists, got %v", err)
+	}
+	if _, err := alice.ForkPrompt("greeting", models.ForkPromptInput{Slug: "Bad Slug"}); err == nil || !strings.Contains(err.Error(), "invalid slug") {
+		t.Errorf("Expected invalid slug, got %v", err)
+	}
+	if _, err := alice.ForkPrompt("missing", models.ForkPromptInput{Slug: "copy"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found, got %v", err)
+	}
+
+	// A fork of a restricted prompt keeps its grants, so it stays restricted
+	if _, err := alice.SetPromptACL("greeting", models.SetPromptACLInput{Grants: []models.PromptGrant{{Type: GranteeUser, Name: "bob", Access: AccessRead}}}); err != nil {
+		t.Fatalf("SetPromptACL failed: %v", err)
+	}
+	bobs, err := bob.ForkPrompt("greeting", models.ForkPromptInput{Slug: "greeting-bob"})
+	if err != nil {
+		t.Fatalf("ForkPrompt by a reader failed: %v", err)
+	}
+	if bobs.Owner != "bob" {
+		t.Errorf("Expected bob to own the fork, got %q", bobs.Owner)
+	}
+	if err := carol.AuthorizePrompt("greeting-bob", AccessRead); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected the fork to be hidden from carol, got %v", err)
+	}
+	if err := bob.AuthorizePrompt("greeting-bob", AccessWrite); err != nil {
+		t.Errorf("Expected bob to write to his fork, got %v", err)
+	}
+}
+
+func TestGetLatestPromptVersion(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := s.CreatePromptVersion("summarize", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	// Latest is the highest version even when the prompt serves an older one
+	if _, err := s.db.Exec(`UPDATE prompts SET current_version = 1 WHERE slug = 'summarize'`); err != nil {
+		t.Fatalf("Failed to move current_version: %v", err)
+	}
+
+	latest, err := s.GetLatestPromptVersion("summarize")
+	if err != nil || latest.VersionNumber != 2 || latest.Content != "v2" {
+		t.Errorf("Expected version 2, got %+v, %v", latest, err)
+	}
+	if prompt, _ := s.GetPromptBySlug("summarize"); prompt.CurrentVersion.VersionNumber != 1 {
+		t.Errorf("Expected current version 1, got %d", prompt.CurrentVersion.VersionNumber)
+	}
+	if _, err := s.GetLatestPromptVersion("missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found, got %v", err)
+	}
+}
+
+func TestChatPrompt(t *testing.T) {
+	s := setupTestStore(t)
+
+	created, err := s.CreatePrompt(models.CreatePromptInput{
+		Slug:   "support",
+		Title:  "Support",
+		Format: render.FormatChat,
+		Messages: []models.Message{
+			{Role: render.RoleSystem, Content: "You help with {{product}}."},
+			{Role: render.RoleUser, Content: "{{question}}"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if created.Format != render.FormatChat || len(created.CurrentVersion.Messages) != 2 {
+		t.Errorf("Expected a chat prompt with 2 messages, got %+v", created)
+	}
+
+	updated, err := s.CreatePromptVersion("support", models.CreatePromptVersionInput{Messages: []models.Message{
+		{Role: render.RoleSystem, Content: "You help with {{product}}. Be brief."},
+		{Role: render.RoleUser, Content: "{{question}}"},
+	}})
+	if err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	// Messages are stored as JSON content, so a raw JSON version works too
+	if _, err := s.CreatePromptVersion("support", models.CreatePromptVersionInput{Content: updated.CurrentVersion.Content}); err != nil {
+		t.Errorf("CreatePromptVersion with JSON content failed: %v", err)
+	}
+
+	version, err := s.GetPromptVersion("support", 2)
+	if err != nil || len(version.Messages) != 2 || version.Messages[0].Content != "You help with {{product}}. Be brief." {
+		t.Errorf("Expected version 2's messages, got %+v, %v", version, err)
+	}
+	prompt, err := s.GetPromptBySlug("support")
+	if err != nil || prompt.Format != render.FormatChat || prompt.CurrentVersion.VersionNumber != 3 {
+		t.Errorf("Expected chat prompt at version 3, got %+v, %v", prompt, err)
+	}
+
+	for name, input := range map[string]models.CreatePromptVersionInput{
+		"plain text":    {Content: "Hello"},
+		"unknown role":  {Messages: []models.Message{{Role: "narrator", Content: "Hi"}}},
+		"empty message": {Messages: []models.Message{{Role: render.RoleUser, Content: ""}}},
+		"both":          {Content: "Hello", Messages: []models.Message{{Role: render.RoleUser, Content: "Hi"}}},
+	} {
+		if _, err := s.CreatePromptVersion("support", input); err == nil || !strings.Contains(err.Error(), "invalid messages") {
+			t.Errorf("%s: expected invalid messages error, got %v", name, err)
+		}
+	}
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "plain", Title: "Plain", Content: "Hi",
+		Messages: []models.Message{{Role: render.RoleUser, Content: "Hi"}}}); err == nil || !strings.Contains(err.Error(), "only chat prompts") {
+		t.Errorf("Expected messages on a text prompt to be rejected, got %v", err)
+	}
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "odd", Title: "Odd", Format: "xml", Content: "Hi"}); err == nil || !strings.Contains(err.Error(), "invalid format") {
+		t.Errorf("Expected invalid format error, got %v", err)
+	}
+}
+
+func TestVersionModelConfig(t *testing.T) {
+	s := setupTestStore(t)
+
+	temperature := 0.2
+	config := &models.ModelConfig{Model: "gpt-4o", Temperature: &temperature, MaxTokens: 300, Stop: []string{"\n\n"}}
+	created, err := s.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "v1", ModelConfig: config})
+	if err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if !reflect.DeepEqual(created.CurrentVersion.ModelConfig, config) {
+		t.Errorf("Expected the model config back, got %+v", created.CurrentVersion.ModelConfig)
+	}
+	// An empty config is the same as none
+	if _, err := s.CreatePromptVersion("summarize", models.CreatePromptVersionInput{Content: "v2", ModelConfig: &models.ModelConfig{}}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	v1, err := s.GetPromptVersion("summarize", 1)
+	if err != nil || !reflect.DeepEqual(v1.ModelConfig, config) {
+		t.Errorf("Expected version 1's model config, got %+v, %v", v1.ModelConfig, err)
+	}
+	prompt, err := s.GetPromptBySlug("summarize")
+	if err != nil || prompt.CurrentVersion.ModelConfig != nil {
+		t.Errorf("Expected version 2 to have no model config, got %+v, %v", prompt.CurrentVersion.ModelConfig, err)
+	}
+
+	var exported []models.ExportedPrompt
+	if _, err := s.ExportPrompts(func(p models.ExportedPrompt) error {
+		exported = append(exported, p)
+		return nil
+	}); err != nil {
+		t.Fatalf("ExportPrompts failed: %v", err)
+	}
+	if got := exported[0].Versions[0].ModelConfig; !reflect.DeepEqual(got, config) {
+		t.Errorf("Expected the model config in the export, got %+v", got)
+	}
+
+	tooHot := 3.0
+	for name, bad := range map[string]*models.ModelConfig{
+		"temperature": {Temperature: &tooHot},
+		"max_tokens":  {MaxTokens: -1},
+		"stop":        {Stop: []string{""}},
+	} {
+		_, err := s.CreatePromptVersion("summarize", models.CreatePromptVersionInput{Content: "v3", ModelConfig: bad})
+		if err == nil || !strings.Contains(err.Error(), "invalid model config") {
+			t.Errorf("%s: expected invalid model config error, got %v", name, err)
+		}
+	}
+}
+
+func TestPromptMetadata(t *testing.T) {
+	s := setupTestStore(t)
+
+	promptMetadata := map[string]any{"team": "search", "ticket": "SRCH-142"}
+	created, err := s.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "v1", Metadata: promptMetadata})
+	if err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if !reflect.DeepEqual(created.Metadata, promptMetadata) {
+		t.Errorf("Expected the prompt metadata back, got %v", created.Metadata)
+	}
+
+	versionMetadata := map[string]any{"reviewed": true, "score": 0.9}
+	updated, err := s.CreatePromptVersion("summarize", models.CreatePromptVersionInput{Content: "v2", Metadata: versionMetadata})
+	if err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if !reflect.DeepEqual(updated.Metadata, promptMetadata) || !reflect.DeepEqual(updated.CurrentVersion.Metadata, versionMetadata) {
+		t.Errorf("Unexpected metadata: prompt %v, version %v", updated.Metadata, updated.CurrentVersion.Metadata)
+	}
+
+	prompt, err := s.GetPromptBySlug("summarize")
+	if err != nil || !reflect.DeepEqual(prompt.Metadata, promptMetadata) || !reflect.DeepEqual(prompt.CurrentVersion.Metadata, versionMetadata) {
+		t.Errorf("Expected metadata on the prompt and its current version, got %+v, %v", prompt, err)
+	}
+	if v1, err := s.GetPromptVersion("summarize", 1); err != nil || v1.Metadata != nil {
+		t.Errorf("Expected version 1 to have no metadata, got %v, %v", v1.Metadata, err)
+	}
+
+	fork, err := s.ForkPrompt("summarize", models.ForkPromptInput{Slug: "summarize-copy"})
+	if err != nil || !reflect.DeepEqual(fork.Metadata, promptMetadata) || !reflect.DeepEqual(fork.CurrentVersion.Metadata, versionMetadata) {
+		t.Errorf("Expected the fork to keep the metadata, got %+v, %v", fork, err)
+	}
+}
+
+func TestVersionComments(t *testing.T) {
+	base := setupTestStore(t)
+	alice := base.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: "alice", Method: "apikey"}))
+
+	if _, err := base.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := base.CreatePromptVersion("summarize", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	first, err := alice.AddVersionComment("summarize", 1, "Too terse for long articles")
+	if err != nil {
+		t.Fatalf("AddVersionComment failed: %v", err)
+	}
+	if first.ID == 0 || first.VersionNumber != 1 || first.CreatedBy != "alice" || first.CreatedAt.IsZero() {
+		t.Errorf("Unexpected comment: %+v", first)
+	}
+	if _, err := alice.AddVersionComment("summarize", 1, "Fixed in version 2"); err != nil {
+		t.Fatalf("AddVersionComment failed: %v", err)
+	}
+
+	comments, err := base.ListVersionComments("summarize", 1)
+	if err != nil || len(comments) != 2 || comments[0].Body != "Too terse for long articles" || comments[1].Body != "Fixed in version 2" {
+		t.Errorf("Expected both comments oldest first, got %+v, %v", comments, err)
+	}
+	if comments, err := base.ListVersionComments("summarize", 2); err != nil || len(comments) != 0 {
+		t.Errorf("Expected no comments on version 2, got %+v, %v", comments, err)
+	}
+	if v1, err := base.GetPromptVersion("summarize", 1); err != nil || v1.Content != "v1" {
+		t.Errorf("Expected comments to leave the content alone, got %+v, %v", v1, err)
+	}
+
+	if _, err := base.AddVersionComment("summarize", 1, "  "); err == nil || !strings.Contains(err.Error(), "cannot be empty") {
+		t.Errorf("Expected empty comment error, got %v", err)
+	}
+	if _, err := base.AddVersionComment("summarize", 1, strings.Repeat("x", MaxCommentLength+1)); err == nil || !strings.Contains(err.Error(), "the limit is") {
+		t.Errorf("Expected comment limit error, got %v", err)
+	}
+	if _, err := base.AddVersionComment("summarize", 3, "hi"); err == nil || !strings.Contains(err.Error(), "version 3 not found") {
+		t.Errorf("Expected version not found, got %v", err)
+	}
+	if _, err := base.ListVersionComments("missing", 1); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected prompt not found, got %v", err)
+	}
+}
+
+func TestPromptStars(t *testing.T) {
+	base := setupTestStore(t)
+	as := func(subject string) Store {
+		return base.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: subject, Method: "apikey"}))
+	}
+	alice, bob := as("alice"), as("bob")
+
+	for _, slug := range []string{"summarize", "translate", "classify"} {
+		if _, err := base.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	for _, slug := range []string{"summarize", "classify", "classify"} {
+		if err := alice.SetPromptStarred(slug, true); err != nil {
+			t.Fatalf("SetPromptStarred failed: %v", err)
+		}
+	}
+	if err := bob.SetPromptStarred("translate", true); err != nil {
+		t.Fatalf("SetPromptStarred failed: %v", err)
+	}
+
+	starred := PromptFilter{Starred: true}
+	prompts, err := alice.ListPrompts(100, 0, PromptSort{Field: SortTitle}, starred)
+	if err != nil || len(prompts) != 2 || prompts[0].Slug != "classify" || prompts[1].Slug != "summarize" {
+		t.Errorf("Expected alice's two starred prompts, got %+v, %v", prompts, err)
+	}
+	if count, err := alice.CountPrompts(false, starred); err != nil || count != 2 {
+		t.Errorf("Expected a count of 2, got %d, %v", count, err)
+	}
+	prompts, err = bob.ListPrompts(100, 0, PromptSort{}, starred)
+	if err != nil || len(prompts) != 1 || prompts[0].Slug != "translate" {
+		t.Errorf("Expected bob's starred prompt, got %+v, %v", prompts, err)
+	}
+
+	if err := alice.SetPromptStarred("classify", false); err != nil {
+		t.Fatalf("SetPromptStarred failed: %v", err)
+	}
+	if err := alice.SetPromptStarred("translate", false); err != nil {
+		t.Errorf("Expected unstarring an unstarred prompt to succeed, got %v", err)
+	}
+	if count, err := alice.CountPrompts(false, starred); err != nil || count != 1 {
+		t.Errorf("Expected a count of 1, got %d, %v", count, err)
+	}
+	if err := alice.SetPromptStarred("missing", true); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected prompt not found, got %v", err)
+	}
+}
+
+func TestPromptUsage(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := s.CreatePromptVersion("summarize", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	usage, err := s.GetPromptUsage("summarize")
+	if err != nil || usage.Fetches != 0 || usage.LastFetchedAt != nil || len(usage.Versions) != 2 {
+		t.Errorf("Expected two unfetched versions, got %+v, %v", usage, err)
+	}
+
+	earlier := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+	batches := [][]UsageCount{
+		{{Slug: "summarize", Version: 2, Fetches: 3, LastFetchedAt: later}, {Slug: "missing", Version: 1, Fetches: 1, LastFetchedAt: later}},
+		{{Slug: "summarize", Version: 2, Fetches: 2, LastFetchedAt: earlier}},
+	}
+	for _, batch := range batches {
+		if err := s.RecordPromptUsage(batch); err != nil {
+			t.Fatalf("RecordPromptUsage failed: %v", err)
+		}
+	}
+
+	usage, err = s.GetPromptUsage("summarize")
+	if err != nil {
+		t.Fatalf("GetPromptUsage failed: %v", err)
+	}
+	if usage.Fetches != 5 || usage.LastFetchedAt == nil || !usage.LastFetchedAt.Equal(later) {
+		t.Errorf("Expected 5 fetches, last at %v, got %+v", later, usage)
+	}
+	if v1 := usage.Versions[0]; v1.VersionNumber != 1 || v1.Fetches != 0 || v1.LastFetchedAt != nil {
+		t.Errorf("Expected version 1 to be unfetched, got %+v", v1)
+	}
+	if v2 := usage.Versions[1]; v2.Fetches != 5 || !v2.LastFetchedAt.Equal(later) {
+		t.Errorf("Expected version 2 to keep the latest fetch time, got %+v", v2)
+	}
+	if _, err := s.GetPromptUsage("missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected prompt not found, got %v", err)
+	}
+}
+
+func TestAnalytics(t *testing.T) {
+	s := setupTestStore(t)
+
+	for _, slug := range []string{"hot", "warm", "cold", "unused", "retired"} {
+		if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	if _, err := s.CreatePromptVersion("hot", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if err := s.SetPromptArchived("retired", true); err != nil {
+		t.Fatalf("SetPromptArchived failed: %v", err)
+	}
+
+	// Every prompt predates staleSince; only hot and warm were fetched after it
+	now := time.Now().UTC()
+	staleSince := now.Add(time.Hour)
+	err := s.RecordPromptUsage([]UsageCount{
+		{Slug: "hot", Version: 1, Fetches: 10, LastFetchedAt: now.Add(2 * time.Hour)},
+		{Slug: "hot", Version: 2, Fetches: 5, LastFetchedAt: now.Add(2 * time.Hour)},
+		{Slug: "warm", Version: 1, Fetches: 3, LastFetchedAt: now.Add(3 * time.Hour)},
+		{Slug: "cold", Version: 1, Fetches: 7, LastFetchedAt: now.AddDate(0, 0, -60)},
+		{Slug: "retired", Version: 1, Fetches: 100, LastFetchedAt: now},
+	})
+	if err != nil {
+		t.Fatalf("RecordPromptUsage failed: %v", err)
+	}
+
+	result, err := s.GetAnalytics(staleSince, 2)
+	if err != nil {
+		t.Fatalf("GetAnalytics failed: %v", err)
+	}
+	if result.Prompts != 4 || result.Versions != 5 || result.Fetches != 25 {
+		t.Errorf("Expected totals over the 4 unarchived prompts, got %+v", result)
+	}
+	slugs := func(list []models.PromptActivity) []string {
+		var out []string
+		for _, a := range list {
+			out = append(out, a.Slug)
+		}
+		return out
+	}
+	if got := slugs(result.MostFetched); !reflect.DeepEqual(got, []string{"hot", "cold"}) {
+		t.Errorf("Unexpected most fetched: %v", got)
+	}
+	if hot := result.MostFetched[0]; hot.Fetches != 15 || hot.Versions != 2 || hot.CurrentVersion != 2 || hot.LastFetchedAt == nil {
+		t.Errorf("Unexpected activity for hot: %+v", hot)
+	}
+	if got := slugs(result.Stale); !reflect.DeepEqual(got, []string{"unused", "cold"}) {
+		t.Errorf("Unexpected stale prompts: %v", got)
+	}
+	if result.Stale[0].LastFetchedAt != nil {
+		t.Errorf("Expected unused to have no last fetch, got %v", result.Stale[0].LastFetchedAt)
+	}
+	if len(result.RecentlyChanged) != 2 {
+		t.Errorf("Expected 2 recently changed prompts, got %v", slugs(result.RecentlyChanged))
+	}
+
+	// Prompts created after staleSince aren't stale yet
+	if result, err := s.GetAnalytics(now.Add(-time.Hour), 10); err != nil || len(result.Stale) != 0 {
+		t.Errorf("Expected no stale prompts, got %+v, %v", result.Stale, err)
+	}
+}
+
+// countingStore counts GetPromptBySlug calls, blocking each until release is
+// closed
+type countingStore struct {
+	Store
+	calls   atomic.Int64
+	release chan struct{}
+}
+
+func (s *countingStore) GetPromptBySlug(slug string) (models.PromptWithCurrentVersion, error) {
+	s.calls.Add(1)
+	<-s.release
+	return s.Store.GetPromptBySlug(slug)
+}
+
+func TestCachedStore(t *testing.T) {
+	base := setupTestStore(t)
+	c := NewCachedStore(base, 2)
+
+	for _, slug := range []string{"summarize", "translate", "classify"} {
+		if _, err := c.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	if _, err := c.GetPromptBySlug("summarize"); err != nil {
+		t.Fatalf("GetPromptBySlug failed: %v", err)
+	}
+
+	// A write behind the cache's back isn't seen until the entry expires
+	if _, err := base.CreatePromptVersion("summarize", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if prompt, err := c.GetPromptBySlug("summarize"); err != nil || prompt.CurrentVersion.VersionNumber != 1 {
+		t.Errorf("Expected the cached version 1, got %d, %v", prompt.CurrentVersion.VersionNumber, err)
+	}
+	c.cache.now = func() time.Time { return time.Now().Add(cacheTTL) }
+	if prompt, err := c.GetPromptBySlug("summarize"); err != nil || prompt.CurrentVersion.VersionNumber != 2 {
+		t.Errorf("Expected an expired entry to be reloaded, got %d, %v", prompt.CurrentVersion.VersionNumber, err)
+	}
+	c.cache.now = time.Now
+
+	// Writes through any view of the cached store are seen immediately
+	view := c.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: "alice"})).InProject(DefaultProject)
+	if _, err := view.CreatePromptVersion("summarize", models.CreatePromptVersionInput{Content: "v3"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if prompt, err := c.GetPromptBySlug("summarize"); err != nil || prompt.CurrentVersion.VersionNumber != 3 {
+		t.Errorf("Expected version 3 after the write, got %d, %v", prompt.CurrentVersion.VersionNumber, err)
+	}
+	if _, err := c.SetPromptDescription("summarize", "Summarizes articles"); err != nil {
+		t.Fatalf("SetPromptDescription failed: %v", err)
+	}
+	if prompt, err := c.GetPromptBySlug("summarize"); err != nil || prompt.Description != "Summarizes articles" {
+		t.Errorf("Expected the new description, got %q, %v", prompt.Description, err)
+	}
+	if err := view.SetPromptRetention("summarize", &models.RetentionPolicy{KeepVersions: 5}); err != nil {
+		t.Fatalf("SetPromptRetention failed: %v", err)
+	}
+	if prompt, err := c.GetPromptBySlug("summarize"); err != nil || prompt.UpdatedBy != "alice" {
+		t.Errorf("Expected alice to have updated the prompt, got %q, %v", prompt.UpdatedBy, err)
+	}
+
+	// Projects are cached separately
+	if _, err := c.InProject("other").GetPromptBySlug("summarize"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found in another project, got %v", err)
+	}
+
+	// The least recently used prompt is evicted
+	c.GetPromptBySlug("translate")
+	c.GetPromptBySlug("classify")
+	if _, ok := c.cache.entries[c.key("summarize")]; ok || len(c.cache.entries) != 2 {
+		t.Errorf("Expected summarize to be evicted, got %d entries", len(c.cache.entries))
+	}
+}
+
+func TestCachedStore_Singleflight(t *testing.T) {
+	base := setupTestStore(t)
+	if _, err := base.CreatePrompt(models.CreatePromptInput{Slug: "summarize", Title: "Summarize", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	counting := &countingStore{Store: base, release: make(chan struct{})}
+	c := NewCachedStore(counting, DefaultCacheSize)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetPromptBySlug("summarize"); err != nil {
+				t.Errorf("GetPromptBySlug failed: %v", err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(counting.release)
+	wg.Wait()
+
+	if calls := counting.calls.Load(); calls != 1 {
+		t.Errorf("Expected concurrent lookups to share 1 query, got %d", calls)
+	}
+	if _, err := c.GetPromptBySlug("summarize"); err != nil || counting.calls.Load() != 1 {
+		t.Errorf("Expected a cache hit, got %d queries, %v", counting.calls.Load(), err)
+	}
+}
+
+func TestRegistryStats(t *testing.T) {
+	s := setupTestStore(t)
+
+	for _, slug := range []string{"small", "large", "retired"} {
+		if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	for _, content := range []string{"a much longer second version", "v3"} {
+		if _, err := s.CreatePromptVersion("large", models.CreatePromptVersionInput{Content: content}); err != nil {
+			t.Fatalf("CreatePromptVersion failed: %v", err)
+		}
+	}
+	if err := s.SetPromptArchived("retired", true); err != nil {
+		t.Fatalf("SetPromptArchived failed: %v", err)
+	}
+	_, err := s.CreateRelease(models.CreateReleaseInput{Label: "production", Items: []models.ReleaseItem{
+		{Slug: "small", Version: 1}, {Slug: "large", Version: 3},
+	}})
+	if err != nil {
+		t.Fatalf("CreateRelease failed: %v", err)
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -2)
+	result, err := s.GetRegistryStats(since, 2)
+	if err != nil {
+		t.Fatalf("GetRegistryStats failed: %v", err)
+	}
+	if result.Prompts != 3 || result.Versions != 5 || result.ContentBytes != 36 || result.DatabaseBytes == 0 {
+		t.Errorf("Expected totals over all 3 prompts, got %+v", result)
+	}
+	if !reflect.DeepEqual(result.Labels, []models.LabelCount{{Label: "production", Prompts: 2}}) {
+		t.Errorf("Unexpected label counts: %+v", result.Labels)
+	}
+	if b := result.VersionsPerPrompt; b[0].Min != 1 || b[0].Max != 1 || b[0].Prompts != 2 || b[1].Prompts != 1 || b[len(b)-1].Max != 0 {
+		t.Errorf("Unexpected version distribution: %+v", b)
+	}
+	if len(result.Largest) != 2 || result.Largest[0].Slug != "large" || result.Largest[0].Versions != 3 || result.Largest[0].ContentBytes != 32 {
+		t.Errorf("Unexpected largest prompts: %+v", result.Largest)
+	}
+	if len(result.Created) != 3 {
+		t.Fatalf("Expected 3 days of creations, got %+v", result.Created)
+	}
+	if today := result.Created[2]; today.Date != time.Now().UTC().Format(time.DateOnly) || today.Prompts != 3 || today.Versions != 5 {
+		t.Errorf("Unexpected creations today: %+v", today)
+	}
+}
+
+func TestCheckReadiness(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "ready.db")
+	s, err := New(dbPath, WithBusyTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	failed := func(checks []models.ReadinessCheck) []string {
+		var names []string
+		for _, check := range checks {
+			if !check.OK {
+				names = append(names, check.Name)
+			}
+		}
+		return names
+	}
+
+	checks := s.CheckReadiness(context.Background(), true)
+	if len(checks) != 3 || failed(checks) != nil {
+		t.Fatalf("Expected 3 passing checks, got %+v", checks)
+	}
+	if checks := s.CheckReadiness(context.Background(), false); len(checks) != 2 {
+		t.Errorf("Expected no write check without the probe, got %+v", checks)
+	}
+
+	// Another connection holding the write lock fails only the write probe
+	other, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer other.Close()
+	tx, err := other.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx.Exec(`UPDATE schema_migrations SET name = name`); err != nil {
+		t.Fatalf("Failed to take write lock: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if got := failed(s.CheckReadiness(ctx, true)); !reflect.DeepEqual(got, []string{"write"}) {
+		t.Errorf("Expected only the write check to fail, got %v", got)
+	}
+	tx.Rollback()
+
+	// A schema rolled back under the running server fails the schema check
+	if _, err := s.db.Exec(`DELETE FROM schema_migrations WHERE version = ?`, LatestSchemaVersion()); err != nil {
+		t.Fatalf("Failed to roll back schema version: %v", err)
+	}
+	if got := failed(s.CheckReadiness(context.Background(), true)); !reflect.DeepEqual(got, []string{"schema"}) {
+		t.Errorf("Expected only the schema check to fail, got %v", got)
+	}
+}
+
+func TestVersionContentHash(t *testing.T) {
+	s := setupTestStore(t)
+
+	// SHA-256 of "v1"
+	const v1 = "3bfc269594ef649228e9a74bab00f042efc91d5acc6fbee31a382e80d42388fe"
+	created, err := s.CreatePrompt(models.CreatePromptInput{Slug: "hashed", Title: "Hashed", Content: "v1"})
+	if err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if created.CurrentVersion.ContentSHA256 != v1 {
+		t.Errorf("Expected hash %s, got %q", v1, created.CurrentVersion.ContentSHA256)
+	}
+	updated, err := s.CreatePromptVersion("hashed", models.CreatePromptVersionInput{Content: "v2"})
+	if err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	imported, err := s.ImportPromptVersions("hashed", models.ImportVersionsInput{Versions: []models.ImportVersion{{Content: "v3"}}})
+	if err != nil {
+		t.Fatalf("ImportPromptVersions failed: %v", err)
+	}
+	versions, err := s.ListPromptVersions("hashed", 10, 0)
+	if err != nil {
+		t.Fatalf("ListPromptVersions failed: %v", err)
+	}
+	for _, v := range versions {
+		if v.ContentSHA256 != contentSHA256(v.Content) {
+			t.Errorf("Version %d: expected the hash of its content, got %q", v.VersionNumber, v.ContentSHA256)
+		}
+	}
+	if updated.CurrentVersion.ContentSHA256 != versions[1].ContentSHA256 || imported[0].ContentSHA256 != versions[2].ContentSHA256 {
+		t.Errorf("Expected returned hashes to match stored ones")
+	}
+
+	// The hash is locked like the content
+	if _, err := s.db.Exec(`UPDATE prompt_versions SET content_sha256 = 'forged' WHERE id = ?`, created.CurrentVersion.ID); err == nil || !strings.Contains(err.Error(), "immutable") {
+		t.Errorf("Expected immutable error, got %v", err)
+	}
+
+	// Redaction hashes the notice and keeps the old hash as original_sha256
+	redacted, err := s.RedactPromptVersion("hashed", 1, "contained a secret")
+	if err != nil {
+		t.Fatalf("RedactPromptVersion failed: %v", err)
+	}
+	if redacted.OriginalSHA256 != v1 || redacted.ContentSHA256 != contentSHA256(redacted.Content) {
+		t.Errorf("Unexpected hashes after redaction: %+v", redacted)
+	}
+}
+
+func TestCreatePromptVersion_Deduplicate(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "ci", Title: "CI", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	// Identical content returns the current version, even with new metadata
+	result, err := s.CreatePromptVersion("ci", models.CreatePromptVersionInput{
+		Content: "v1", Metadata: map[string]any{"build": 2}, Deduplicate: true,
+	})
+	if err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if !result.Unchanged || result.CurrentVersion.VersionNumber != 1 {
+		t.Errorf("Expected version 1 unchanged, got %+v", result)
+	}
+
+	// A different model config is a change
+	result, err = s.CreatePromptVersion("ci", models.CreatePromptVersionInput{
+		Content: "v1", ModelConfig: &models.ModelConfig{Model: "gpt-4o"}, Deduplicate: true,
+	})
+	if err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if result.Unchanged || result.CurrentVersion.VersionNumber != 2 {
+		t.Errorf("Expected version 2 to be created, got %+v", result)
+	}
+
+	// Without the flag identical content still creates a version
+	result, err = s.CreatePromptVersion("ci", models.CreatePromptVersionInput{
+		Content: "v1", ModelConfig: &models.ModelConfig{Model: "gpt-4o"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if result.Unchanged || result.CurrentVersion.VersionNumber != 3 {
+		t.Errorf("Expected version 3 to be created, got %+v", result)
+	}
+}
+
+func TestCreatePromptVersion_BaseVersion(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "edit", Title: "Edit", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	base := 1
+	if _, err := s.CreatePromptVersion("edit", models.CreatePromptVersionInput{Content: "v2", BaseVersion: &base}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	// A second edit written against version 1 conflicts with the first
+	_, err := s.CreatePromptVersion("edit", models.CreatePromptVersionInput{Content: "other", BaseVersion: &base})
+	if err == nil || !strings.Contains(err.Error(), "version conflict") {
+		t.Fatalf("Expected version conflict, got %v", err)
+	}
+	versions, err := s.ListPromptVersions("edit", 100, 0)
+	if err != nil {
+		t.Fatalf("ListPromptVersions failed: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("Expected 2 versions after the conflict, got %d", len(versions))
+	}
+
+	// Resending the current content isn't a conflict
+	result, err := s.CreatePromptVersion("edit", models.CreatePromptVersionInput{Content: "v2", BaseVersion: &base, Deduplicate: true})
+	if err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if !result.Unchanged {
+		t.Errorf("Expected version 2 unchanged, got %+v", result)
+	}
+}
+
+func TestGetPromptsBySlugs(t *testing.T) {
+	base := setupTestStore(t)
+	as := func(subject string) Store {
+		return base.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: subject, Method: "apikey"}))
+	}
+	alice, bob := as("alice"), as("bob")
+
+	for _, slug := range []string{"one", "two"} {
+		if _, err := base.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: slug + " v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	if _, err := base.CreatePromptVersion("two", models.CreatePromptVersionInput{Content: "two v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if _, err := alice.CreatePrompt(models.CreatePromptInput{Slug: "secret", Title: "Secret", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := alice.SetPromptACL("secret", models.SetPromptACLInput{Grants: []models.PromptGrant{{Type: GranteeUser, Name: "carol", Access: AccessRead}}}); err != nil {
+		t.Fatalf("SetPromptACL failed: %v", err)
+	}
+
+	// Results follow the requested order and skip missing prompts
+	prompts, err := alice.GetPromptsBySlugs([]string{"two", "missing", "secret", "one"})
+	if err != nil {
+		t.Fatalf("GetPromptsBySlugs failed: %v", err)
+	}
+	var got []string
+	for _, prompt := range prompts {
+		got = append(got, fmt.Sprintf("%s@%d", prompt.Slug, prompt.CurrentVersion.VersionNumber))
+	}
+	if strings.Join(got, ",") != "two@2,secret@1,one@1" {
+		t.Errorf("Expected two@2,secret@1,one@1, got %v", got)
+	}
+	if prompts[0].CurrentVersion.Content != "two v2" {
+		t.Errorf("Expected the current content, got %q", prompts[0].CurrentVersion.Content)
+	}
+
+	// Prompts the caller can't read are left out
+	prompts, err = bob.GetPromptsBySlugs([]string{"secret", "one"})
+	if err != nil {
+		t.Fatalf("GetPromptsBySlugs failed: %v", err)
+	}
+	if len(prompts) != 1 || prompts[0].Slug != "one" {
+		t.Errorf("Expected only one for bob, got %+v", prompts)
+	}
+}
+
+func TestListPrompts_Query(t *testing.T) {
+	s := setupTestStore(t)
+
+	for _, input := range []models.CreatePromptInput{
+		{Slug: "support-reply", Title: "Support Reply", Content: "v1"},
+		{Slug: "summary", Title: "Summarize", Description: "Condenses SUPPORT tickets", Content: "v1"},
+		{Slug: "discount", Title: "100% off", Content: "v1"},
+		{Slug: "greeting", Title: "Greeting", Content: "v1"},
+	} {
+		if _, err := s.CreatePrompt(input); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"support", []string{"summary", "support-reply"}},
+		{"REPLY", []string{"support-reply"}},
+		{"mmar", []string{"summary"}},
+		// Wildcards match literally
+		{"%", []string{"discount"}},
+		{"_", []string{}},
+	}
+	for _, tt := range tests {
+		summaries, err := s.ListPrompts(100, 0, PromptSort{Field: SortTitle}, PromptFilter{Query: tt.query})
+		if err != nil {
+			t.Fatalf("%q: ListPrompts failed: %v", tt.query, err)
+		}
+		got := []string{}
+		for _, summary := range summaries {
+			got = append(got, summary.Slug)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+		}
+		count, err := s.CountPrompts(false, PromptFilter{Query: tt.query})
+		if err != nil || count != len(tt.want) {
+			t.Errorf("%q: expected count %d, got %d, %v", tt.query, len(tt.want), count, err)
+		}
+	}
+
+	if _, err := s.ListPrompts(100, 0, PromptSort{}, PromptFilter{Query: strings.Repeat("x", MaxQueryLength+1)}); err == nil {
+		t.Error("Expected an error for an overlong query")
+	}
+}
+
+func TestCollections(t *testing.T) {
+	s := setupTestStore(t)
+
+	for _, slug := range []string{"refund-reply", "refund-policy", "greeting"} {
+		if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+
+	if _, err := s.CreateCollection(models.CreateCollectionInput{Path: "support/refunds"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected the missing parent to be reported, got %v", err)
+	}
+	support, err := s.CreateCollection(models.CreateCollectionInput{Path: "support", Title: "Support"})
+	if err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	refunds, err := s.CreateCollection(models.CreateCollectionInput{Path: "support/refunds"})
+	if err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	if refunds.Parent != "support" || refunds.Title != "refunds" {
+		t.Errorf("Expected parent support and title refunds, got %+v", refunds)
+	}
+	if _, err := s.CreateCollection(models.CreateCollectionInput{Path: "support"}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected a duplicate path to fail, got %v", err)
+	}
+	for _, path := range []string{"", "Support", "support/", "a/b/c/d/e/f/g/h/i"} {
+		if _, err := s.CreateCollection(models.CreateCollectionInput{Path: path}); err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Errorf("%q: expected an invalid path error, got %v", path, err)
+		}
+	}
+
+	for _, add := range []struct {
+		id   int64
+		slug string
+	}{{support.ID, "greeting"}, {refunds.ID, "refund-reply"}, {refunds.ID, "refund-policy"}, {refunds.ID, "refund-policy"}} {
+		if err := s.SetPromptInCollection(add.id, add.slug, true); err != nil {
+			t.Fatalf("SetPromptInCollection failed: %v", err)
+		}
+	}
+	if err := s.SetPromptInCollection(refunds.ID, "missing", true); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a missing prompt to be reported, got %v", err)
+	}
+	if err := s.SetPromptInCollection(999, "greeting", true); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a missing collection to be reported, got %v", err)
+	}
+
+	slugs := func(summaries []models.PromptSummary) string {
+		var names []string
+		for _, summary := range summaries {
+			names = append(names, summary.Slug)
+		}
+		return strings.Join(names, ",")
+	}
+	direct, err := s.ListCollectionPrompts(support.ID, false)
+	if err != nil {
+		t.Fatalf("ListCollectionPrompts failed: %v", err)
+	}
+	if got := slugs(direct); got != "greeting" {
+		t.Errorf("Expected greeting directly in support, got %q", got)
+	}
+	all, err := s.ListCollectionPrompts(support.ID, true)
+	if err != nil {
+		t.Fatalf("ListCollectionPrompts failed: %v", err)
+	}
+	if got := slugs(all); got != "greeting,refund-policy,refund-reply" {
+		t.Errorf("Expected every prompt under support, got %q", got)
+	}
+
+	if err := s.SetPromptInCollection(refunds.ID, "refund-policy", false); err != nil {
+		t.Fatalf("SetPromptInCollection failed: %v", err)
+	}
+	collections, err := s.ListCollections()
+	if err != nil {
+		t.Fatalf("ListCollections failed: %v", err)
+	}
+	if len(collections) != 2 || collections[0].Path != "support" || collections[1].Prompts != 1 {
+		t.Errorf("Expected support then refunds with one prompt, got %+v", collections)
+	}
+
+	// Collections belong to a project
+	other, err := s.InProject("other").ListCollections()
+	if err != nil || len(other) != 0 {
+		t.Errorf("Expected no collections in another project, got %+v, %v", other, err)
+	}
+}
+
+func TestPromptLinks(t *testing.T) {
+	base := setupTestStore(t)
+	as := func(subject string) Store {
+		return base.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: subject, Method: "apikey"}))
+	}
+	alice, bob := as("alice"), as("bob")
+
+	for _, slug := range []string{"preamble", "support", "sales", "support-v2"} {
+		if _, err := alice.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	link := func(slug string, links ...models.PromptLink) (models.PromptLinks, error) {
+		return alice.SetPromptLinks(slug, models.SetPromptLinksInput{Links: links})
+	}
+	includes := func(slug string) models.PromptLink { return models.PromptLink{Slug: slug, Kind: LinkIncludes} }
+
+	if _, err := link("support", includes("preamble")); err != nil {
+		t.Fatalf("SetPromptLinks failed: %v", err)
+	}
+	if _, err := link("sales", includes("preamble")); err != nil {
+		t.Fatalf("SetPromptLinks failed: %v", err)
+	}
+	result, err := link("support-v2", includes("preamble"), models.PromptLink{Slug: "support", Kind: LinkDerivedFrom})
+	if err != nil {
+		t.Fatalf("SetPromptLinks failed: %v", err)
+	}
+	if len(result.Links) != 2 || result.Links[0].Kind != LinkDerivedFrom || result.Links[0].CreatedBy != "alice" {
+		t.Errorf("Expected derived_from then includes, got %+v", result.Links)
+	}
+
+	// The reverse lookup finds every prompt that includes the preamble
+	links, err := alice.GetPromptLinks("preamble")
+	if err != nil {
+		t.Fatalf("GetPromptLinks failed: %v", err)
+	}
+	var dependents []string
+	for _, dependent := range links.Dependents {
+		dependents = append(dependents, dependent.Slug)
+	}
+	if len(links.Links) != 0 || strings.Join(dependents, ",") != "sales,support,support-v2" {
+		t.Errorf("Expected sales, support, and support-v2 to depend on preamble, got %+v", links)
+	}
+
+	for _, tt := range []struct {
+		slug  string
+		links []models.PromptLink
+	}{
+		{"preamble", []models.PromptLink{includes("support")}}, // cycle
+		{"preamble", []models.PromptLink{includes("preamble")}},
+		{"preamble", []models.PromptLink{includes("missing")}},
+		{"preamble", []models.PromptLink{{Slug: "sales", Kind: "uses"}}},
+		{"sales", []models.PromptLink{includes("preamble"), includes("preamble")}},
+	} {
+		if _, err := link(tt.slug, tt.links...); err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Errorf("%s %+v: expected an invalid link error, got %v", tt.slug, tt.links, err)
+		}
+	}
+	// A failed replace leaves the links as they were
+	if links, _ := alice.GetPromptLinks("sales"); len(links.Links) != 1 {
+		t.Errorf("Expected sales to keep its link, got %+v", links)
+	}
+
+	// Dependents the caller can't read are left out
+	if _, err := alice.SetPromptACL("sales", models.SetPromptACLInput{Grants: []models.PromptGrant{{Type: GranteeUser, Name: "carol", Access: AccessRead}}}); err != nil {
+		t.Fatalf("SetPromptACL failed: %v", err)
+	}
+	links, err = bob.GetPromptLinks("preamble")
+	if err != nil {
+		t.Fatalf("GetPromptLinks failed: %v", err)
+	}
+	if len(links.Dependents) != 2 {
+		t.Errorf("Expected bob to see two dependents, got %+v", links.Dependents)
+	}
+
+	// Clearing the links removes them from the reverse lookup
+	if _, err := link("support-v2"); err != nil {
+		t.Fatalf("SetPromptLinks failed: %v", err)
+	}
+	if links, _ := alice.GetPromptLinks("preamble"); len(links.Dependents) != 2 {
+		t.Errorf("Expected two dependents after clearing support-v2, got %+v", links.Dependents)
+	}
+}
+
+func TestPruneVersions(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "notes", Title: "Notes", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	for v := 2; v <= 8; v++ {
+		if _, err := s.CreatePromptVersion("notes", models.CreatePromptVersionInput{Content: fmt.Sprintf("v%d", v)}); err != nil {
+			t.Fatalf("CreatePromptVersion failed: %v", err)
+		}
+		if v == 4 {
+			// The fork keeps version 4, which the prompt was at when forked
+			if _, err := s.ForkPrompt("notes", models.ForkPromptInput{Slug: "notes-fork"}); err != nil {
+				t.Fatalf("ForkPrompt failed: %v", err)
+			}
+		}
+	}
+	if _, err := s.SetVersionPinned("notes", 2, true); err != nil {
+		t.Fatalf("SetVersionPinned failed: %v", err)
+	}
+	if _, err := s.CreateRelease(models.CreateReleaseInput{Label: "production", Items: []models.ReleaseItem{{Slug: "notes", Version: 3}}}); err != nil {
+		t.Fatalf("CreateRelease failed: %v", err)
+	}
+	if _, err := s.AddVersionComment("notes", 5, "Superseded"); err != nil {
+		t.Fatalf("AddVersionComment failed: %v", err)
+	}
+
+	if err := s.SetPromptRetention("notes", &models.RetentionPolicy{KeepVersions: -1}); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("Expected invalid policy error, got %v", err)
+	}
+	if err := s.SetPromptRetention("missing", &models.RetentionPolicy{KeepVersions: 2}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+	if err := s.SetPromptRetention("notes", &models.RetentionPolicy{KeepVersions: 2}); err != nil {
+		t.Fatalf("SetPromptRetention failed: %v", err)
+	}
+	retention, err := s.GetPromptRetention("notes", models.RetentionPolicy{KeepDays: 365})
+	if err != nil {
+		t.Fatalf("GetPromptRetention failed: %v", err)
+	}
+	// The newest two are kept by count; pinned, released, and forked versions whatever their age
+	if retention.Policy == nil || retention.Effective.KeepVersions != 2 || fmt.Sprint(retention.Prunable) != "[1 5 6]" {
+		t.Errorf("Expected versions 1, 5, and 6 prunable, got %+v", retention)
+	}
+
+	// A prompt following the default, with its first version backdated past it
+	for _, slug := range []string{"old", "held"} {
+		if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+		if _, err := s.CreatePromptVersion(slug, models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+			t.Fatalf("CreatePromptVersion failed: %v", err)
+		}
+	}
+	if _, err := s.db.Exec(`UPDATE prompt_versions SET created_at = '2020-01-01 00:00:00' WHERE version_number = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetLegalHold("held", true, "case 42"); err != nil {
+		t.Fatalf("SetLegalHold failed: %v", err)
+	}
+	defaults := models.RetentionPolicy{KeepDays: 30}
+
+	report, err := s.PruneVersions(defaults, true)
+	if err != nil {
+		t.Fatalf("PruneVersions dry run failed: %v", err)
+	}
+	if !report.DryRun || report.Versions != 4 || len(report.Prompts) != 2 ||
+		report.Prompts[0].Slug != "notes" || report.Prompts[1].Slug != "old" || fmt.Sprint(report.Prompts[1].Versions) != "[1]" {
+		t.Errorf("Expected notes and old in the dry run report, got %+v", report)
+	}
+	if count, _ := s.CountPromptVersions("notes"); count != 8 {
+		t.Errorf("Expected a dry run to keep all 8 versions, got %d", count)
+	}
+
+	result, err := s.PruneVersions(defaults, false)
+	if err != nil {
+		t.Fatalf("PruneVersions failed: %v", err)
+	}
+	if result.DryRun || result.Versions != 4 {
+		t.Errorf("Expected 4 versions pruned, got %+v", result)
+	}
+	versions, err := s.ListPromptVersions("notes", 100, 0)
+	if err != nil {
+		t.Fatalf("ListPromptVersions failed: %v", err)
+	}
+	var remaining []int
+	for _, v := range versions {
+		remaining = append(remaining, v.VersionNumber)
+	}
+	if fmt.Sprint(remaining) != "[2 3 4 7 8]" {
+		t.Errorf("Expected versions 2, 3, 4, 7, and 8 left, got %v", remaining)
+	}
+	var comments int
+	s.db.QueryRow(`SELECT COUNT(*) FROM version_comments`).Scan(&comments)
+	if comments != 0 {
+		t.Errorf("Expected the pruned version's comment deleted, got %d", comments)
+	}
+	if count, _ := s.CountPromptVersions("held"); count != 2 {
+		t.Errorf("Expected a held prompt to keep every version, got %d", count)
+	}
+	entries, err := s.ListAuditEntries("old", 1, 0)
+	if err != nil || len(entries) != 1 || entries[0].Action != "prompt.versions_pruned" || entries[0].Detail != "versions 1" {
+		t.Errorf("Expected a versions_pruned audit entry, got %+v, %v", entries, err)
+	}
+
+	// Pruning again finds nothing, and a cleared policy falls back to the default
+	if result, err = s.PruneVersions(defaults, false); err != nil || result.Versions != 0 {
+		t.Errorf("Expected nothing left to prune, got %+v, %v", result, err)
+	}
+	if err := s.SetPromptRetention("notes", nil); err != nil {
+		t.Fatalf("SetPromptRetention failed: %v", err)
+	}
+	if retention, _ = s.GetPromptRetention("notes", defaults); retention.Policy != nil || retention.Effective != defaults {
+		t.Errorf("Expected the default policy, got %+v", retention)
+	}
+}
+
+func TestPruneVersions_HoldPlacedAfterCandidateSelection(t *testing.T) {
+	s := setupTestStore(t)
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "notes", Title: "Notes", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := s.CreatePromptVersion("notes", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	id, err := s.promptID("notes")
+	if err != nil {
+		t.Fatalf("promptID failed: %v", err)
+	}
+
+	// PruneVersions has already picked the prompt when the hold lands
+	if err := s.SetLegalHold("notes", true, "case 42"); err != nil {
+		t.Fatalf("SetLegalHold failed: %v", err)
+	}
+	pruned, err := s.pruneCandidate(id, models.RetentionPolicy{KeepVersions: 1}, time.Now(), false)
+	if err != nil || len(pruned) != 0 {
+		t.Errorf("Expected nothing pruned under legal hold, got %v, %v", pruned, err)
+	}
+	if count, _ := s.CountPromptVersions("notes"); count != 2 {
+		t.Errorf("Expected both versions kept, got %d", count)
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	s := setupTestStore(t)
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Greeting", Content: "Hello"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		err  func() error
+		kind error
+	}{
+		{"missing prompt", func() error { _, err := s.GetPromptBySlug("missing"); return err }, ErrNotFound},
+		{"taken slug", func() error {
+			_, err := s.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Again", Content: "Hi"})
+			return err
+		}, ErrConflict},
+		{"bad slug", func() error {
+			_, err := s.CreatePrompt(models.CreatePromptInput{Slug: "Not A Slug", Title: "Bad", Content: "Hi"})
+			return err
+		}, ErrValidation},
+		{"anonymous org", func() error { _, err := s.CreateOrg(models.CreateOrgInput{Name: "search"}); return err }, ErrPermission},
+	}
+	for _, tt := range tests {
+		err := tt.err()
+		if !errors.Is(err, tt.kind) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.kind, err)
+		}
+		for _, other := range []error{ErrNotFound, ErrConflict, ErrValidation, ErrPermission} {
+			if other != tt.kind && errors.Is(err, other) {
+				t.Errorf("%s: expected only %v, also matched %v", tt.name, tt.kind, other)
+			}
+		}
+	}
+}
+
+func TestMaintenanceOperations(t *testing.T) {
+	base := setupTestStore(t)
+	s := base.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: "admin", Method: "admin"}))
+
+	for _, slug := range []string{"keep", "old", "held", "doomed"} {
+		if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: slug, Title: slug, Content: "v1"}); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	if _, err := s.CreatePromptVersion("doomed", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	for _, slug := range []string{"old", "held"} {
+		if err := s.SetPromptArchived(slug, true); err != nil {
+			t.Fatalf("SetPromptArchived failed: %v", err)
+		}
+	}
+	if err := s.SetLegalHold("held", true, "litigation"); err != nil {
+		t.Fatalf("SetLegalHold failed: %v", err)
+	}
+	// Everything else recorded about doomed goes with it
+	if _, err := s.AddVersionComment("doomed", 1, "Old"); err != nil {
+		t.Fatalf("AddVersionComment failed: %v", err)
+	}
+	if _, err := s.CreateRelease(models.CreateReleaseInput{Label: "production", Items: []models.ReleaseItem{{Slug: "doomed", Version: 2}}}); err != nil {
+		t.Fatalf("CreateRelease failed: %v", err)
+	}
+	if _, err := s.SetPromptLinks("keep", models.SetPromptLinksInput{Links: []models.PromptLink{{Slug: "doomed", Kind: LinkIncludes}}}); err != nil {
+		t.Fatalf("SetPromptLinks failed: %v", err)
+	}
+	if err := s.SetPromptStarred("doomed", true); err != nil {
+		t.Fatalf("SetPromptStarred failed: %v", err)
+	}
+	if _, err := s.ForkPrompt("doomed", models.ForkPromptInput{Slug: "doomed-fork"}); err != nil {
+		t.Fatalf("ForkPrompt failed: %v", err)
+	}
+
+	// Only archived prompts archived before the cutoff are purged, and never held ones
+	if result, err := s.PurgeArchivedPrompts(time.Now().Add(-time.Hour), false); err != nil || len(result.Prompts) != 0 {
+		t.Errorf("Expected nothing archived an hour ago, got %+v, %v", result, err)
+	}
+	result, err := s.PurgeArchivedPrompts(time.Now().Add(time.Minute), true)
+	if err != nil || !result.DryRun || len(result.Prompts) != 1 || result.Prompts[0].Slug != "old" {
+		t.Fatalf("Expected a dry run listing old, got %+v, %v", result, err)
+	}
+	if _, err := s.GetPromptBySlug("old"); err != nil {
+		t.Errorf("Expected a dry run to keep old, got %v", err)
+	}
+	if result, err = s.PurgeArchivedPrompts(time.Now().Add(time.Minute), false); err != nil || len(result.Prompts) != 1 {
+		t.Fatalf("Expected old purged, got %+v, %v", result, err)
+	}
+	if _, err := s.GetPromptBySlug("old"); !errors.Is(err, ErrPromptNotFound) {
+		t.Errorf("Expected old gone, got %v", err)
+	}
+
+	if _, err := s.DeletePromptPermanently("held"); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected a held prompt to be refused, got %v", err)
+	}
+	if _, err := s.DeletePromptPermanently("missing"); !errors.Is(err, ErrPromptNotFound) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+	deleted, err := s.DeletePromptPermanently("doomed")
+	if err != nil || deleted.Versions != 2 {
+		t.Fatalf("Expected doomed deleted with 2 versions, got %+v, %v", deleted, err)
+	}
+	for _, table := range []string{"prompt_versions", "version_comments", "release_items", "prompt_labels", "prompt_links", "prompt_stars"} {
+		var n int
+		base.db.QueryRow(`SELECT COUNT(*) FROM ` + table + ` WHERE prompt_id NOT IN (SELECT id FROM prompts)`).Scan(&n)
+		if n != 0 {
+			t.Errorf("Expected no %s rows left for deleted prompts, got %d", table, n)
+		}
+	}
+	if links, err := s.GetPromptLinks("keep"); err != nil || len(links.Links) != 0 {
+		t.Errorf("Expected keep's link to doomed removed, got %+v, %v", links, err)
+	}
+	if fork, err := s.GetPromptBySlug("doomed-fork"); err != nil || fork.ForkedFrom != nil {
+		t.Errorf("Expected the fork kept without its source, got %+v, %v", fork.ForkedFrom, err)
+	}
+
+	entries, err := s.ListAdminAuditEntries(10, 0)
+	// The empty purge counts too
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Expected 3 admin audit entries, got %+v, %v", entries, err)
+	}
+	if entries[0].Action != "admin.prompt_deleted" || entries[0].Slug != "doomed" || entries[0].Actor != "admin" || entries[0].Detail != "2 versions" {
+		t.Errorf("Unexpected delete entry: %+v", entries[0])
+	}
+	if entries[1].Action != "admin.archived_purged" || entries[1].Detail != "1 prompts: default/old" {
+		t.Errorf("Unexpected purge entry: %+v", entries[1])
+	}
+
+	// Recompute repairs a dangling current version and a missing hash
+	if _, err := base.db.Exec(`UPDATE prompts SET current_version = 9 WHERE slug = 'keep'`); err != nil {
+		t.Fatalf("Failed to break current version: %v", err)
+	}
+	tx, err := base.db.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	tx.Exec(`INSERT INTO version_content_unlocks (version_id) SELECT id FROM prompt_versions`)
+	tx.Exec(`UPDATE prompt_versions SET content_sha256 = '' WHERE prompt_id = (SELECT id FROM prompts WHERE slug = 'keep')`)
+	tx.Exec(`DELETE FROM version_content_unlocks`)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	recomputed, err := s.RecomputeDenormalized()
+	if err != nil || recomputed.CurrentVersions != 1 || recomputed.ContentHashes != 1 || recomputed.HashMismatches != 0 {
+		t.Fatalf("Expected one of each repaired, got %+v, %v", recomputed, err)
+	}
+	prompt, err := s.GetPromptBySlug("keep")
+	if err != nil || prompt.CurrentVersion.VersionNumber != 1 || prompt.CurrentVersion.ContentSHA256 != contentSHA256("v1") {
+		t.Errorf("Expected keep repaired, got %+v, %v", prompt.CurrentVersion, err)
+	}
+	if recomputed, err = s.RecomputeDenormalized(); err != nil || recomputed.CurrentVersions != 0 || recomputed.ContentHashes != 0 {
+		t.Errorf("Expected nothing left to repair, got %+v, %v", recomputed, err)
+	}
+}
+
+func TestSemanticSearch(t *testing.T) {
+	s := setupTestStore(t)
+
+	for _, p := range []models.CreatePromptInput{
+		{Slug: "refund", Title: "Refund request", Content: "Handle a refund"},
+		{Slug: "greeting", Title: "Greeting", Description: "Says hello by name", Content: "Hello {{name}}"},
+		{Slug: "archived", Title: "Archived", Content: "Old"},
+	} {
+		if _, err := s.CreatePrompt(p); err != nil {
+			t.Fatalf("CreatePrompt failed: %v", err)
+		}
+	}
+	if err := s.SetPromptArchived("archived", true); err != nil {
+		t.Fatalf("SetPromptArchived failed: %v", err)
+	}
+	if _, err := s.InProject("other").CreatePrompt(models.CreatePromptInput{Slug: "elsewhere", Title: "Elsewhere", Content: "x"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	// Every unarchived prompt in every project needs an embedding
+	sources, err := s.ListStaleEmbeddings("test-model", 10)
+	if err != nil || len(sources) != 3 {
+		t.Fatalf("Expected 3 prompts to embed, got %+v, %v", sources, err)
+	}
+	if got, _ := s.ListStaleEmbeddings("test-model", 1); len(got) != 1 {
+		t.Errorf("Expected the limit to apply, got %d", len(got))
+	}
+	vectors := map[string][]float32{"refund": {3, 0}, "greeting": {0, 1}, "elsewhere": {1, 0}}
+	var batch [][]float32
+	for _, source := range sources {
+		if source.Slug == "greeting" && source.Text != "Greeting\n\nSays hello by name\n\nHello {{name}}" {
+			t.Errorf("Unexpected text to embed: %q", source.Text)
+		}
+		batch = append(batch, vectors[source.Slug])
+	}
+	if err := s.SaveEmbeddings("test-model", sources, batch); err != nil {
+		t.Fatalf("SaveEmbeddings failed: %v", err)
+	}
+	if err := s.SaveEmbeddings("test-model", sources, batch[:1]); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrValidation for a vector count mismatch, got %v", err)
+	}
+	if got, _ := s.ListStaleEmbeddings("test-model", 10); len(got) != 0 {
+		t.Errorf("Expected nothing stale after saving, got %+v", got)
+	}
+	if got, _ := s.ListStaleEmbeddings("other-model", 10); len(got) != 3 {
+		t.Errorf("Expected every prompt stale for another model, got %d", len(got))
+	}
+
+	// Closest first, scored by cosine similarity, within the project
+	results, err := s.SemanticSearch("test-model", []float32{1, 0.1}, 10)
+	if err != nil {
+		t.Fatalf("SemanticSearch failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Slug != "refund" || results[1].Slug != "greeting" {
+		t.Fatalf("Expected refund then greeting, got %+v", results)
+	}
+	if results[0].Score < 0.99 || results[0].Score > 1.0001 || results[0].Title != "Refund request" {
+		t.Errorf("Expected a near-1 score for refund, got %+v", results[0])
+	}
+	if results, _ := s.SemanticSearch("test-model", []float32{0, 1}, 1); len(results) != 1 || results[0].Slug != "greeting" {
+		t.Errorf("Expected only greeting, got %+v", results)
+	}
+	if _, err := s.SemanticSearch("test-model", []float32{1, 0}, 0); Field(err) != "limit" {
+		t.Errorf("Expected a limit validation error, got %v", err)
+	}
+	if results, _ := s.SemanticSearch("test-model", []float32{1, 0, 0}, 10); len(results) != 0 {
+		t.Errorf("Expected no matches for other dimensions, got %+v", results)
+	}
+
+	// A new version makes the embedding stale
+	if _, err := s.CreatePromptVersion("greeting", models.CreatePromptVersionInput{Content: "Hi {{name}}"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if got, _ := s.ListStaleEmbeddings("test-model", 10); len(got) != 1 || got[0].Slug != "greeting" {
+		t.Errorf("Expected greeting stale after a new version, got %+v", got)
+	}
+}
+
+func TestEnvironmentPromotion(t *testing.T) {
+	base := setupTestStore(t)
+	alice := base.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: "alice", Method: "apikey"}))
+	if _, err := base.CreatePrompt(models.CreatePromptInput{Slug: "planner", Title: "Planner", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := base.CreatePromptVersion("planner", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	// A prod label released before the pipeline existed
+	if _, err := base.CreateRelease(models.CreateReleaseInput{Label: "prod", Items: []models.ReleaseItem{{Slug: "planner", Version: 1}}}); err != nil {
+		t.Fatalf("CreateRelease failed: %v", err)
+	}
+	for _, name := range []string{"dev", "staging", "prod"} {
+		if _, err := alice.CreateEnvironment(models.CreateEnvironmentInput{Name: name}); err != nil {
+			t.Fatalf("CreateEnvironment %s failed: %v", name, err)
+		}
+	}
+	if _, err := base.CreateEnvironment(models.CreateEnvironmentInput{Name: "dev"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict for a duplicate environment, got %v", err)
+	}
+	if _, err := base.CreateEnvironment(models.CreateEnvironmentInput{Name: "Prod!"}); !errors.Is(err, ErrValidation) || Field(err) != "name" {
+		t.Errorf("Expected validation error on name, got %v", err)
+	}
+	envs, err := base.ListEnvironments()
+	if err != nil || len(envs) != 3 || envs[1].Name != "staging" || envs[1].Position != 2 ||
+		envs[1].Previous != "dev" || envs[1].Next != "prod" || envs[0].CreatedBy != "alice" {
+		t.Fatalf("Unexpected pipeline %+v, %v", envs, err)
+	}
+
+	// Versions can't skip an environment
+	if _, err := base.Promote("planner", models.PromoteInput{Environment: "staging"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict promoting past dev, got %v", err)
+	}
+	// The first environment defaults to the current version
+	release, err := alice.Promote("planner", models.PromoteInput{Environment: "dev"})
+	if err != nil || release.Label != "dev" || release.Items[0].Version != 2 || release.CreatedBy != "alice" {
+		t.Fatalf("Unexpected promotion to dev %+v, %v", release, err)
+	}
+	if _, err := base.Promote("planner", models.PromoteInput{Environment: "dev"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict for a version already in dev, got %v", err)
+	}
+	if _, err := base.Promote("planner", models.PromoteInput{Environment: "staging", Version: 1}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict for a version not in dev, got %v", err)
+	}
+	release, err = alice.Promote("planner", models.PromoteInput{Environment: "staging"})
+	if err != nil || release.Items[0].Version != 2 || release.Note != "promoted from dev" {
+		t.Fatalf("Unexpected promotion to staging %+v, %v", release, err)
+	}
+
+	env, err := base.GetEnvironment("staging")
+	if err != nil || env.Prompts != 1 || len(env.Deployments) != 1 || env.Deployments[0].Slug != "planner" ||
+		env.Deployments[0].Version != 2 || env.Deployments[0].PromotedBy != "alice" || env.Deployments[0].ReleaseID != release.ID {
+		t.Errorf("Unexpected staging %+v, %v", env, err)
+	}
+
+	// Releases can't move environment labels, and rolling one back can't
+	// restore a version that never went through the environment before it
+	if _, err := base.CreateRelease(models.CreateReleaseInput{Label: "prod", Items: []models.ReleaseItem{{Slug: "planner", Version: 2}}}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict releasing to an environment label, got %v", err)
+	}
+	prodRelease, err := alice.Promote("planner", models.PromoteInput{Environment: "prod"})
+	if err != nil || prodRelease.Items[0].PreviousVersion != 1 {
+		t.Fatalf("Unexpected promotion to prod %+v, %v", prodRelease, err)
+	}
+	if _, err := base.RollbackRelease(prodRelease.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict rolling prod back to a version never in staging, got %v", err)
+	}
+	if label, err := base.GetPromptLabel("planner", "prod"); err != nil || label.Version != 2 {
+		t.Errorf("Expected prod unchanged, got %+v, %v", label, err)
+	}
+
+	// Promotions are releases, so they roll back like one
+	if _, err := base.RollbackRelease(release.ID); err != nil {
+		t.Fatalf("RollbackRelease failed: %v", err)
+	}
+	if env, err := base.GetEnvironment("staging"); err != nil || len(env.Deployments) != 0 {
+		t.Errorf("Expected staging empty after rollback, got %+v, %v", env, err)
+	}
+
+	for _, input := range []models.PromoteInput{{}, {Environment: "qa"}} {
+		if _, err := base.Promote("planner", input); err == nil {
+			t.Errorf("Expected error for %+v", input)
+		}
+	}
+	if _, err := base.Promote("missing", models.PromoteInput{Environment: "dev"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found for a missing prompt, got %v", err)
+	}
+
+	// Deleting an environment keeps its label and joins its neighbours
+	if err := base.DeleteEnvironment("staging"); err != nil {
+		t.Fatalf("DeleteEnvironment failed: %v", err)
+	}
+	if err := base.DeleteEnvironment("staging"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found deleting twice, got %v", err)
+	}
+	if env, err := base.GetEnvironment("prod"); err != nil || env.Previous != "dev" || env.Position != 2 {
+		t.Errorf("Expected prod after dev, got %+v, %v", env, err)
+	}
+	if label, err := base.GetPromptLabel("planner", "dev"); err != nil || label.Version != 2 {
+		t.Errorf("Expected dev label kept, got %+v, %v", label, err)
+	}
+
+	// Rolling back to a version that has been through the environment before
+	// works even once that environment has moved on
+	if _, err := base.CreatePromptVersion("planner", models.CreatePromptVersionInput{Content: "v3"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if _, err := base.Promote("planner", models.PromoteInput{Environment: "dev"}); err != nil {
+		t.Fatalf("Promote to dev failed: %v", err)
+	}
+	prodRelease, err = base.Promote("planner", models.PromoteInput{Environment: "prod"})
+	if err != nil {
+		t.Fatalf("Promote to prod failed: %v", err)
+	}
+	if _, err := base.RollbackRelease(prodRelease.ID); err != nil {
+		t.Fatalf("RollbackRelease failed: %v", err)
+	}
+	if label, err := base.GetPromptLabel("planner", "prod"); err != nil || label.Version != 2 {
+		t.Errorf("Expected prod back on version 2, got %+v, %v", label, err)
+	}
+}
+
+func TestPublicPromptVersions_SkipPendingReview(t *testing.T) {
+	s := setupTestStore(t)
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "support", Title: "Support", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := s.SetReviewPolicy("support", models.ReviewPolicy{RequiredApprovals: 1, Reviewers: []string{"bob"}}); err != nil {
+		t.Fatalf("SetReviewPolicy failed: %v", err)
+	}
+	if _, err := s.CreatePromptVersion("support", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	if versions, err := s.ListPublicPromptVersions("support", 100, 0); err != nil || len(versions) != 1 || versions[0].VersionNumber != 1 {
+		t.Errorf("Expected only version 1, got %+v, %v", versions, err)
+	}
+	if count, err := s.CountPublicPromptVersions("support"); err != nil || count != 1 {
+		t.Errorf("Expected 1 public version, got %d, %v", count, err)
+	}
+	if latest, err := s.GetLatestPublicPromptVersion("support"); err != nil || latest.VersionNumber != 1 {
+		t.Errorf("Expected latest public version 1, got %+v, %v", latest, err)
+	}
+	if _, err := s.GetPublicPromptVersion("support", 2); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Expected pending version to be not found, got %v", err)
+	}
+	if latest, err := s.GetLatestPromptVersion("support"); err != nil || latest.VersionNumber != 2 {
+		t.Errorf("Expected latest version 2, got %+v, %v", latest, err)
+	}
+}
+
+func TestForkPrompt_KeepsPendingReview(t *testing.T) {
+	s := setupTestStore(t)
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "support", Title: "Support", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := s.SetReviewPolicy("support", models.ReviewPolicy{RequiredApprovals: 1, Reviewers: []string{"bob"}}); err != nil {
+		t.Fatalf("SetReviewPolicy failed: %v", err)
+	}
+	if _, err := s.CreatePromptVersion("support", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+
+	fork, err := s.ForkPrompt("support", models.ForkPromptInput{Slug: "support-copy", History: true})
+	if err != nil {
+		t.Fatalf("ForkPrompt failed: %v", err)
+	}
+	if fork.CurrentVersion.VersionNumber != 1 {
+		t.Errorf("Expected the fork to serve version 1, got %d", fork.CurrentVersion.VersionNumber)
+	}
+	if v, err := s.GetPromptVersion("support-copy", 2); err != nil || v.ReviewStatus != ReviewPending {
+		t.Errorf("Expected version 2 to stay pending in the fork, got %+v, %v", v, err)
+	}
+	if latest, err := s.GetLatestPublicPromptVersion("support-copy"); err != nil || latest.VersionNumber != 1 {
+		t.Errorf("Expected latest public version 1, got %+v, %v", latest, err)
+	}
+	if _, err := s.GetPublicPromptVersion("support-copy", 2); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Expected pending version to be not found, got %v", err)
+	}
+	if _, err := s.CreateRelease(models.CreateReleaseInput{Label: "prod", Items: []models.ReleaseItem{{Slug: "support-copy", Version: 2}}}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected the pending version to be rejected, got %v", err)
+	}
+}
+
+func TestVersionReview(t *testing.T) {
+	base := setupTestStore(t)
+	as := func(subject string) Store {
+		return base.WithContext(auth.WithIdentity(context.Background(), auth.Identity{Subject: subject, Method: "apikey"}))
+	}
+	alice, bob, carol := as("alice"), as("bob"), as("carol")
+	if _, err := alice.CreatePrompt(models.CreatePromptInput{Slug: "support", Title: "Support", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := base.SetReviewPolicy("support", models.ReviewPolicy{RequiredApprovals: 3, Reviewers: []string{"bob", "carol"}}); !errors.Is(err, ErrValidation) || Field(err) != "required_approvals" {
+		t.Errorf("Expected validation error on required_approvals, got %v", err)
+	}
+	policy, err := base.SetReviewPolicy("support", models.ReviewPolicy{RequiredApprovals: 2, Reviewers: []string{"bob", " carol", "bob"}})
+	if err != nil || !reflect.DeepEqual(policy.Reviewers, []string{"bob", "carol"}) {
+		t.Fatalf("Unexpected policy %+v, %v", policy, err)
+	}
+
+	// New versions wait for review and leave the current version alone
+	result, err := alice.CreatePromptVersion("support", models.CreatePromptVersionInput{Content: "v2"})
+	if err != nil || result.CurrentVersion.VersionNumber != 1 || result.PendingVersion == nil ||
+		result.PendingVersion.VersionNumber != 2 || result.PendingVersion.ReviewStatus != ReviewPending {
+		t.Fatalf("Unexpected pending create %+v, %v", result, err)
+	}
+	if _, err := base.CreateRelease(models.CreateReleaseInput{Label: "prod", Items: []models.ReleaseItem{{Slug: "support", Version: 2}}}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict releasing a pending version, got %v", err)
+	}
+	if _, err := base.ImportPromptVersions("support", models.ImportVersionsInput{Versions: []models.ImportVersion{{Content: "v3"}}}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict importing under a review policy, got %v", err)
+	}
+
+	if _, err := alice.ApproveVersion("support", 2, ""); !errors.Is(err, ErrPermission) {
+		t.Errorf("Expected permission error for a non-reviewer, got %v", err)
+	}
+	review, err := bob.ApproveVersion("support", 2, "looks good")
+	if err != nil || review.Status != ReviewPending || len(review.Approvals) != 1 || review.Approvals[0].Comment != "looks good" {
+		t.Fatalf("Unexpected review after one approval %+v, %v", review, err)
+	}
+	if _, err := bob.ApproveVersion("support", 2, ""); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict for a repeated approval, got %v", err)
+	}
+	if prompt, err := base.GetPromptBySlug("support"); err != nil || prompt.CurrentVersion.VersionNumber != 1 {
+		t.Errorf("Expected version 1 current until approved, got %+v, %v", prompt.CurrentVersion, err)
+	}
+	review, err = carol.ApproveVersion("support", 2, "")
+	if err != nil || review.Status != ReviewApproved || review.RequiredApprovals != 2 || len(review.Approvals) != 2 {
+		t.Fatalf("Unexpected review after two approvals %+v, %v", review, err)
+	}
+	prompt, err := base.GetPromptBySlug("support")
+	if err != nil || prompt.CurrentVersion.VersionNumber != 2 || prompt.CurrentVersion.ReviewStatus != ReviewApproved {
+		t.Errorf("Expected approved version 2 current, got %+v, %v", prompt.CurrentVersion, err)
+	}
+	if _, err := carol.ApproveVersion("support", 2, ""); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict approving an approved version, got %v", err)
+	}
+
+	// Reviewers can't approve their own versions
+	if _, err := bob.CreatePromptVersion("support", models.CreatePromptVersionInput{Content: "v3"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	if _, err := bob.ApproveVersion("support", 3, ""); !errors.Is(err, ErrPermission) {
+		t.Errorf("Expected permission error for the author, got %v", err)
+	}
+	if review, err := base.GetVersionReview("support", 3); err != nil || review.Status != ReviewPending || len(review.Approvals) != 0 {
+		t.Errorf("Unexpected review of version 3 %+v, %v", review, err)
+	}
+	if _, err := base.GetVersionReview("support", 9); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Expected version not found, got %v", err)
+	}
+
+	// Turning review off applies to versions created from then on
+	if _, err := base.SetReviewPolicy("support", models.ReviewPolicy{}); err != nil {
+		t.Fatalf("SetReviewPolicy failed: %v", err)
+	}
+	result, err = alice.CreatePromptVersion("support", models.CreatePromptVersionInput{Content: "v4"})
+	if err != nil || result.PendingVersion != nil || result.CurrentVersion.VersionNumber != 4 || result.CurrentVersion.ReviewStatus != "" {
+		t.Errorf("Unexpected create without review %+v, %v", result, err)
+	}
+	if policy, err := base.GetReviewPolicy("support"); err != nil || policy.RequiredApprovals != 0 || len(policy.Reviewers) != 0 {
+		t.Errorf("Unexpected policy after turning review off %+v, %v", policy, err)
+	}
+}
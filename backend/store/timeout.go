@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// timeoutConnector opens SQLite connections that give each statement at most
+// timeout to finish, for Options.QueryTimeout
+type timeoutConnector struct {
+	dsn     string
+	timeout time.Duration
+}
+
+func (c *timeoutConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &timeoutConn{SQLiteConn: conn.(*sqlite3.SQLiteConn), timeout: c.timeout}, nil
+}
+
+func (c *timeoutConnector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// timeoutConn adds the deadline to every statement. The driver interrupts a
+// statement whose context is done, so a runaway query frees its connection.
+type timeoutConn struct {
+	*sqlite3.SQLiteConn
+	timeout time.Duration
+}
+
+func (c *timeoutConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.SQLiteConn.ExecContext(ctx, query, args)
+}
+
+func (c *timeoutConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &timeoutRows{Rows: rows, cancel: cancel}, nil
+}
+
+// timeoutRows keeps its statement's deadline until the rows are closed
+type timeoutRows struct {
+	driver.Rows
+	cancel context.CancelFunc
+}
+
+func (r *timeoutRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
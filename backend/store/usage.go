@@ -0,0 +1,131 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// usageSchema counts fetches of each prompt version. Rows are written in
+// batches, so counts lag reads by up to one flush.
+const usageSchema = `
+	CREATE TABLE IF NOT EXISTS prompt_usage (
+		prompt_id       INTEGER NOT NULL,
+		version_number  INTEGER NOT NULL,
+		fetches         INTEGER NOT NULL DEFAULT 0,
+		last_fetched_at DATETIME NOT NULL,
+		PRIMARY KEY(prompt_id, version_number),
+		FOREIGN KEY(prompt_id) REFERENCES prompts(id)
+	);
+`
+
+// UsageCount is a batch of fetches of one prompt version
+type UsageCount struct {
+	Slug          string
+	Version       int
+	Fetches       int64
+	LastFetchedAt time.Time
+}
+
+// RecordPromptUsage adds counts to the stored usage in one transaction.
+// Counts for prompts that no longer exist are dropped.
+func (s *SQLiteStore) RecordPromptUsage(counts []UsageCount) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.logger.Error("failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, c := range counts {
+		_, err := tx.Exec(`
+			INSERT INTO prompt_usage (prompt_id, version_number, fetches, last_fetched_at)
+			SELECT id, ?, ?, ? FROM prompts WHERE project = ? AND slug = ?
+			ON CONFLICT(prompt_id, version_number) DO UPDATE SET
+				fetches = fetches + excluded.fetches,
+				last_fetched_at = MAX(last_fetched_at, excluded.last_fetched_at)
+		`, c.Version, c.Fetches, timestampValue(&c.LastFetchedAt), s.project, c.Slug)
+		if err != nil {
+			s.logger.Error("failed to record usage", "error", err, "slug", c.Slug, "version", c.Version)
+			return fmt.Errorf("failed to record usage: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed to commit transaction", "error", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("RecordPromptUsage", duration)
+	s.logger.Info("database operation",
+		"operation", "RecordPromptUsage",
+		"versions", len(counts),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// GetPromptUsage returns fetch counts for every version of a prompt, oldest
+// version first
+func (s *SQLiteStore) GetPromptUsage(slug string) (models.PromptUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := time.Now()
+	result := models.PromptUsage{Slug: slug, Versions: []models.VersionUsage{}}
+	promptID, err := s.promptID(slug)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT pv.version_number, COALESCE(u.fetches, 0), u.last_fetched_at
+		FROM prompt_versions pv
+		LEFT JOIN prompt_usage u ON u.prompt_id = pv.prompt_id AND u.version_number = pv.version_number
+		WHERE pv.prompt_id = ?
+		ORDER BY pv.version_number ASC
+	`, promptID)
+	if err != nil {
+		s.logger.Error("failed to get usage", "error", err, "slug", slug)
+		return result, fmt.Errorf("failed to get usage: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var usage models.VersionUsage
+		var lastFetchedAt sql.NullTime
+		if err := rows.Scan(&usage.VersionNumber, &usage.Fetches, &lastFetchedAt); err != nil {
+			s.logger.Error("failed to scan usage", "error", err)
+			return result, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		if lastFetchedAt.Valid {
+			usage.LastFetchedAt = &lastFetchedAt.Time
+			if result.LastFetchedAt == nil || lastFetchedAt.Time.After(*result.LastFetchedAt) {
+				result.LastFetchedAt = usage.LastFetchedAt
+			}
+		}
+		result.Fetches += usage.Fetches
+		result.Versions = append(result.Versions, usage)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("failed to iterate usage", "error", err)
+		return result, fmt.Errorf("failed to iterate usage: %w", err)
+	}
+
+	duration := time.Since(start)
+	s.observe("GetPromptUsage", duration)
+	s.logger.Info("database operation",
+		"operation", "GetPromptUsage",
+		"slug", slug,
+		"fetches", result.Fetches,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return result, nil
+}
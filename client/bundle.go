@@ -0,0 +1,75 @@
+package client
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// BundleFormatVersion is the current offline bundle format
+const BundleFormatVersion = 1
+
+// Bundle is an offline snapshot of prompts' current versions. It is written
+// by `promptctl bundle` as gzip-compressed JSON and loaded with LoadBundle.
+type Bundle struct {
+	FormatVersion int                               `json:"format_version"`
+	CreatedAt     time.Time                         `json:"created_at"`
+	Source        string                            `json:"source"`
+	Prompts       []models.PromptWithCurrentVersion `json:"prompts"`
+}
+
+// Get returns the bundled prompt for slug
+func (b *Bundle) Get(slug string) (models.PromptWithCurrentVersion, bool) {
+	for _, p := range b.Prompts {
+		if p.Slug == slug {
+			return p, true
+		}
+	}
+	return models.PromptWithCurrentVersion{}, false
+}
+
+// WriteBundle writes b to w as gzip-compressed JSON
+func WriteBundle(w io.Writer, b *Bundle) error {
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(b); err != nil {
+		gz.Close()
+		return fmt.Errorf("failed to encode bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress bundle: %w", err)
+	}
+	return nil
+}
+
+// ReadBundle reads a gzip-compressed JSON bundle from r
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress bundle: %w", err)
+	}
+	defer gz.Close()
+
+	var b Bundle
+	if err := json.NewDecoder(gz).Decode(&b); err != nil {
+		return nil, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	if b.FormatVersion != BundleFormatVersion {
+		return nil, fmt.Errorf("unsupported bundle format version %d", b.FormatVersion)
+	}
+	return &b, nil
+}
+
+// LoadBundle reads a bundle file from disk
+func LoadBundle(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+	return ReadBundle(f)
+}
@@ -0,0 +1,206 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// CacheOptions configures a Cache
+type CacheOptions struct {
+	// RefreshInterval is how often cached prompts are revalidated in the background
+	RefreshInterval time.Duration
+	// Dir optionally persists cached prompts to disk so they survive restarts
+	Dir string
+	// OnRefreshError is called when a background refresh fails; the stale entry is kept
+	OnRefreshError func(slug string, err error)
+}
+
+// Cache serves prompts from memory (and optionally disk) and keeps them fresh
+// in the background, so hot paths never block on the registry once warmed.
+type Cache struct {
+	client *Client
+	opts   CacheOptions
+
+	mu      sync.RWMutex
+	entries map[string]cacheEntry
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// cacheEntry is a cached prompt and the ETag it was served with
+type cacheEntry struct {
+	Prompt    models.PromptWithCurrentVersion `json:"prompt"`
+	ETag      string                          `json:"etag"`
+	FetchedAt time.Time                       `json:"fetched_at"`
+}
+
+// NewCache creates a cache in front of c and starts its background refresh loop.
+// Call Close to stop refreshing.
+func NewCache(c *Client, opts CacheOptions) (*Cache, error) {
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = time.Minute
+	}
+	if opts.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+	}
+
+	cache := &Cache{
+		client:  c,
+		opts:    opts,
+		entries: make(map[string]cacheEntry),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go cache.refreshLoop()
+	return cache, nil
+}
+
+// Get returns a prompt from memory or disk without contacting the registry.
+// On a cold miss it fetches synchronously once; later updates arrive via background refresh.
+func (cache *Cache) Get(ctx context.Context, slug string) (models.PromptWithCurrentVersion, error) {
+	cache.mu.RLock()
+	entry, ok := cache.entries[slug]
+	cache.mu.RUnlock()
+	if ok {
+		return entry.Prompt, nil
+	}
+
+	if entry, ok := cache.readDisk(slug); ok {
+		cache.store(slug, entry, false)
+		return entry.Prompt, nil
+	}
+
+	prompt, etag, _, err := cache.client.getPromptIfChanged(ctx, slug, "")
+	if err != nil {
+		return prompt, err
+	}
+	cache.store(slug, cacheEntry{Prompt: prompt, ETag: etag, FetchedAt: time.Now()}, true)
+	return prompt, nil
+}
+
+// Warm loads the given slugs into the cache, returning the first error encountered
+func (cache *Cache) Warm(ctx context.Context, slugs ...string) error {
+	var errs []error
+	for _, slug := range slugs {
+		if _, err := cache.Get(ctx, slug); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", slug, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Refresh revalidates every cached prompt now, using ETags to skip unchanged ones
+func (cache *Cache) Refresh(ctx context.Context) {
+	cache.mu.RLock()
+	snapshot := make(map[string]cacheEntry, len(cache.entries))
+	for slug, entry := range cache.entries {
+		snapshot[slug] = entry
+	}
+	cache.mu.RUnlock()
+
+	for slug, entry := range snapshot {
+		prompt, etag, modified, err := cache.client.getPromptIfChanged(ctx, slug, entry.ETag)
+		if err != nil {
+			if cache.opts.OnRefreshError != nil {
+				cache.opts.OnRefreshError(slug, err)
+			}
+			continue
+		}
+		if !modified {
+			entry.FetchedAt = time.Now()
+			cache.store(slug, entry, false)
+			continue
+		}
+		cache.store(slug, cacheEntry{Prompt: prompt, ETag: etag, FetchedAt: time.Now()}, true)
+	}
+}
+
+// Close stops the background refresh loop
+func (cache *Cache) Close() {
+	cache.once.Do(func() { close(cache.stop) })
+	<-cache.done
+}
+
+func (cache *Cache) refreshLoop() {
+	defer close(cache.done)
+	ticker := time.NewTicker(cache.opts.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cache.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), cache.opts.RefreshInterval)
+			cache.Refresh(ctx)
+			cancel()
+		}
+	}
+}
+
+// store saves an entry in memory and, when persist is set, on disk
+func (cache *Cache) store(slug string, entry cacheEntry, persist bool) {
+	cache.mu.Lock()
+	cache.entries[slug] = entry
+	cache.mu.Unlock()
+
+	if persist {
+		cache.writeDisk(slug, entry)
+	}
+}
+
+func (cache *Cache) diskPath(slug string) string {
+	return filepath.Join(cache.opts.Dir, url.PathEscape(slug)+".json")
+}
+
+func (cache *Cache) readDisk(slug string) (cacheEntry, bool) {
+	var entry cacheEntry
+	if cache.opts.Dir == "" {
+		return entry, false
+	}
+	data, err := os.ReadFile(cache.diskPath(slug))
+	if err != nil {
+		return entry, false
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+// writeDisk persists an entry atomically; failures only cost a cold start later
+func (cache *Cache) writeDisk(slug string, entry cacheEntry) {
+	if cache.opts.Dir == "" {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(cache.opts.Dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), cache.diskPath(slug)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
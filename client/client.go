@@ -0,0 +1,404 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+)
+
+// ErrNotFound is returned when the registry responds 404
+var ErrNotFound = errors.New("not found")
+
+// APIError is returned for non-2xx registry responses
+type APIError struct {
+	StatusCode int
+	// Code is the registry's machine-readable error code, such as
+	// PROMPT_NOT_FOUND or SLUG_CONFLICT
+	Code    string
+	Message string
+	// Field names the request field a validation error is about, if any
+	Field string
+	// RequestID is the registry's X-Request-ID for the failed request, for
+	// finding it in the registry's logs
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("registry returned %d: %s (request ID %s)", e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("registry returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Is lets errors.Is(err, ErrNotFound) match 404 responses
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// Client is a Go client for the prompt registry HTTP API
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	fallback   *Bundle
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient overrides the HTTP client used for requests
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithAPIKey sends key as a bearer token on every request
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithBundleFallback serves prompts from an offline bundle when the registry
+// is unreachable or failing (network errors and 5xx). A 404 from a reachable
+// registry is authoritative and is not masked by the bundle.
+func WithBundleFallback(b *Bundle) Option {
+	return func(c *Client) {
+		c.fallback = b
+	}
+}
+
+// New creates a Client for the registry at baseURL (e.g. "http://localhost:8080")
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// BaseURL returns the registry base URL the client talks to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// GetPrompt fetches a prompt with its current version
+func (c *Client) GetPrompt(ctx context.Context, slug string) (models.PromptWithCurrentVersion, error) {
+	var result models.PromptWithCurrentVersion
+	err := c.do(ctx, http.MethodGet, "/api/prompts/"+url.PathEscape(slug), nil, &result)
+	if err != nil && c.fallback != nil && isUnavailable(err) {
+		if bundled, ok := c.fallback.Get(slug); ok {
+			return bundled, nil
+		}
+	}
+	return result, err
+}
+
+// GetPrompts fetches several prompts with their current versions in one
+// request. Slugs that don't exist or can't be read are returned in Missing.
+// When the registry is unavailable, prompts are served from the fallback
+// bundle if one is set.
+func (c *Client) GetPrompts(ctx context.Context, slugs []string) (models.BatchGetPromptsResult, error) {
+	var result models.BatchGetPromptsResult
+	body, err := json.Marshal(models.BatchGetPromptsInput{Slugs: slugs})
+	if err != nil {
+		return result, fmt.Errorf("failed to encode request: %w", err)
+	}
+	err = c.do(ctx, http.MethodPost, "/api/prompts:batchGet", bytes.NewReader(body), &result)
+	if err != nil && c.fallback != nil && isUnavailable(err) {
+		bundled := models.BatchGetPromptsResult{Prompts: []models.PromptWithCurrentVersion{}, Missing: []string{}}
+		for _, slug := range slugs {
+			if prompt, ok := c.fallback.Get(slug); ok {
+				bundled.Prompts = append(bundled.Prompts, prompt)
+			} else {
+				bundled.Missing = append(bundled.Missing, slug)
+			}
+		}
+		return bundled, nil
+	}
+	return result, err
+}
+
+// getPromptIfChanged fetches a prompt unless it still matches etag.
+// It returns modified=false when the registry answers 304 Not Modified.
+func (c *Client) getPromptIfChanged(ctx context.Context, slug, etag string) (prompt models.PromptWithCurrentVersion, newETag string, modified bool, err error) {
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	resp, err := c.send(ctx, http.MethodGet, "/api/prompts/"+url.PathEscape(slug), nil, header, &prompt)
+	if err != nil {
+		return prompt, "", false, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return prompt, etag, false, nil
+	}
+	return prompt, resp.Header.Get("ETag"), true, nil
+}
+
+// ListPrompts fetches one page of prompt summaries
+func (c *Client) ListPrompts(ctx context.Context, limit, offset int) ([]models.PromptSummary, error) {
+	var result []models.PromptSummary
+	path := fmt.Sprintf("/api/prompts?limit=%d&offset=%d", limit, offset)
+	err := c.do(ctx, http.MethodGet, path, nil, &result)
+	return result, err
+}
+
+// versionPageSize is the page size ListVersions requests
+const versionPageSize = 100
+
+// ListVersions fetches every version of a prompt, oldest first, a page at a time
+func (c *Client) ListVersions(ctx context.Context, slug string) ([]models.PromptVersion, error) {
+	var all []models.PromptVersion
+	for offset := 0; ; offset += versionPageSize {
+		var page []models.PromptVersion
+		path := fmt.Sprintf("/api/prompts/%s/versions?limit=%d&offset=%d", url.PathEscape(slug), versionPageSize, offset)
+		if err := c.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < versionPageSize {
+			return all, nil
+		}
+	}
+}
+
+// GetVersion fetches a single version of a prompt
+func (c *Client) GetVersion(ctx context.Context, slug string, version int) (models.PromptVersion, error) {
+	var result models.PromptVersion
+	path := fmt.Sprintf("/api/prompts/%s/versions/%d", url.PathEscape(slug), version)
+	err := c.do(ctx, http.MethodGet, path, nil, &result)
+	return result, err
+}
+
+// DiffVersions returns the hunks between two versions of a prompt, with
+// context unchanged lines around each
+func (c *Client) DiffVersions(ctx context.Context, slug string, from, to, context int) (models.VersionDiff, error) {
+	var result models.VersionDiff
+	query := url.Values{
+		"from":    {strconv.Itoa(from)},
+		"to":      {strconv.Itoa(to)},
+		"context": {strconv.Itoa(context)},
+		"format":  {"json"},
+	}
+	path := fmt.Sprintf("/api/prompts/%s/diff?%s", url.PathEscape(slug), query.Encode())
+	err := c.do(ctx, http.MethodGet, path, nil, &result)
+	return result, err
+}
+
+// CreatePrompt creates a prompt with its first version
+func (c *Client) CreatePrompt(ctx context.Context, input models.CreatePromptInput) (models.PromptWithCurrentVersion, error) {
+	var result models.PromptWithCurrentVersion
+	body, err := json.Marshal(input)
+	if err != nil {
+		return result, fmt.Errorf("failed to encode request: %w", err)
+	}
+	err = c.do(ctx, http.MethodPost, "/api/prompts", bytes.NewReader(body), &result)
+	return result, err
+}
+
+// CreateVersion appends a new version to a prompt and makes it current. With
+// input.Deduplicate, unchanged content returns the prompt with Unchanged set.
+// With input.BaseVersion, it fails with a 409 APIError when another version
+// has become current since. Under a review policy the version is returned in
+// PendingVersion and the current version stays as it was.
+func (c *Client) CreateVersion(ctx context.Context, slug string, input models.CreatePromptVersionInput) (models.PromptWithCurrentVersion, error) {
+	var result models.PromptWithCurrentVersion
+	body, err := json.Marshal(input)
+	if err != nil {
+		return result, fmt.Errorf("failed to encode request: %w", err)
+	}
+	err = c.do(ctx, http.MethodPost, "/api/prompts/"+url.PathEscape(slug)+"/versions", bytes.NewReader(body), &result)
+	return result, err
+}
+
+// ForkPrompt copies a prompt into a new one at input.Slug
+func (c *Client) ForkPrompt(ctx context.Context, slug string, input models.ForkPromptInput) (models.PromptWithCurrentVersion, error) {
+	var result models.PromptWithCurrentVersion
+	body, err := json.Marshal(input)
+	if err != nil {
+		return result, fmt.Errorf("failed to encode request: %w", err)
+	}
+	err = c.do(ctx, http.MethodPost, "/api/prompts/"+url.PathEscape(slug)+"/fork", bytes.NewReader(body), &result)
+	return result, err
+}
+
+// Render validates variables against the prompt's schema and returns the
+// current version with placeholders substituted
+func (c *Client) Render(ctx context.Context, slug string, variables map[string]any) (models.RenderResult, error) {
+	var result models.RenderResult
+	body, err := json.Marshal(models.RenderInput{Variables: variables})
+	if err != nil {
+		return result, fmt.Errorf("failed to encode request: %w", err)
+	}
+	err = c.do(ctx, http.MethodPost, "/api/prompts/"+url.PathEscape(slug)+"/render", bytes.NewReader(body), &result)
+	return result, err
+}
+
+// SemanticSearch finds up to limit prompts closest in meaning to q, best
+// first. The registry must have semantic search enabled; see Capabilities.
+func (c *Client) SemanticSearch(ctx context.Context, q string, limit int) ([]models.SemanticSearchResult, error) {
+	var result []models.SemanticSearchResult
+	query := url.Values{"q": {q}, "limit": {strconv.Itoa(limit)}}
+	err := c.do(ctx, http.MethodGet, "/api/prompts/semantic-search?"+query.Encode(), nil, &result)
+	return result, err
+}
+
+// Capabilities fetches the registry's enabled features and limits. It needs
+// no API key.
+func (c *Client) Capabilities(ctx context.Context) (models.Capabilities, error) {
+	var result models.Capabilities
+	err := c.do(ctx, http.MethodGet, "/api/capabilities", nil, &result)
+	return result, err
+}
+
+// ListReleases fetches the most recent releases, newest first. A non-empty
+// label lists only that label's releases.
+func (c *Client) ListReleases(ctx context.Context, label string, limit int) ([]models.Release, error) {
+	var result []models.Release
+	query := url.Values{"limit": {strconv.Itoa(limit)}}
+	if label != "" {
+		query.Set("label", label)
+	}
+	err := c.do(ctx, http.MethodGet, "/api/releases?"+query.Encode(), nil, &result)
+	return result, err
+}
+
+// RollbackRelease puts back every label a release moved
+func (c *Client) RollbackRelease(ctx context.Context, id int64) (models.Release, error) {
+	var result models.Release
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/releases/%d/rollback", id), nil, &result)
+	return result, err
+}
+
+// ListEnvironments fetches the project's promotion pipeline in order
+func (c *Client) ListEnvironments(ctx context.Context) ([]models.Environment, error) {
+	var result []models.Environment
+	err := c.do(ctx, http.MethodGet, "/api/environments", nil, &result)
+	return result, err
+}
+
+// GetEnvironment fetches an environment with the version of each prompt
+// live in it
+func (c *Client) GetEnvironment(ctx context.Context, name string) (models.Environment, error) {
+	var result models.Environment
+	err := c.do(ctx, http.MethodGet, "/api/environments/"+url.PathEscape(name), nil, &result)
+	return result, err
+}
+
+// Promote puts a version of a prompt live in an environment. It fails with
+// a 409 APIError when the version isn't live in the environment before it.
+func (c *Client) Promote(ctx context.Context, slug string, input models.PromoteInput) (models.Release, error) {
+	var result models.Release
+	body, err := json.Marshal(input)
+	if err != nil {
+		return result, fmt.Errorf("failed to encode request: %w", err)
+	}
+	err = c.do(ctx, http.MethodPost, fmt.Sprintf("/api/prompts/%s/promote", url.PathEscape(slug)), bytes.NewReader(body), &result)
+	return result, err
+}
+
+// ApproveVersion approves a version pending review as the client's subject.
+// The approval that completes the review makes the version current.
+func (c *Client) ApproveVersion(ctx context.Context, slug string, version int, comment string) (models.VersionReview, error) {
+	var result models.VersionReview
+	body, err := json.Marshal(models.ApproveVersionInput{Comment: comment})
+	if err != nil {
+		return result, fmt.Errorf("failed to encode request: %w", err)
+	}
+	err = c.do(ctx, http.MethodPost, fmt.Sprintf("/api/prompts/%s/versions/%d/approvals", url.PathEscape(slug), version), bytes.NewReader(body), &result)
+	return result, err
+}
+
+// Compact runs VACUUM/ANALYZE on the registry database. The client's API key
+// must be the server's admin token.
+func (c *Client) Compact(ctx context.Context) (models.CompactResult, error) {
+	var result models.CompactResult
+	err := c.do(ctx, http.MethodPost, "/api/admin/compact", nil, &result)
+	return result, err
+}
+
+// isUnavailable reports whether err means the registry could not serve the request
+func isUnavailable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+// do sends a request and decodes a JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, out any) error {
+	_, err := c.send(ctx, method, path, body, nil, out)
+	return err
+}
+
+// send performs a request with extra headers and decodes a 2xx JSON body into out.
+// A 304 Not Modified is returned without error and without decoding. The
+// returned response's body is already closed; only its status and headers are usable.
+func (c *Client) send(ctx context.Context, method, path string, body io.Reader, header http.Header, out any) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errResp struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+			Field   string `json:"field"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Message == "" {
+			errResp.Message = http.StatusText(resp.StatusCode)
+		}
+		return resp, &APIError{
+			StatusCode: resp.StatusCode,
+			Code:       errResp.Code,
+			Message:    errResp.Message,
+			Field:      errResp.Field,
+			RequestID:  resp.Header.Get("X-Request-ID"),
+		}
+	}
+
+	if out == nil {
+		return resp, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,356 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/handlers"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+func setupTestServer(t *testing.T) (*Client, store.Store) {
+	t.Helper()
+	s, err := store.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	server := httptest.NewServer(handlers.New(s, logger).Routes())
+	t.Cleanup(server.Close)
+
+	return New(server.URL), s
+}
+
+func TestGetPrompt_Success(t *testing.T) {
+	c, s := setupTestServer(t)
+
+	_, err := s.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Greeting", Content: "Hello"})
+	if err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	prompt, err := c.GetPrompt(context.Background(), "greeting")
+	if err != nil {
+		t.Fatalf("GetPrompt failed: %v", err)
+	}
+	if prompt.CurrentVersion.Content != "Hello" {
+		t.Errorf("Expected content %q, got %q", "Hello", prompt.CurrentVersion.Content)
+	}
+}
+
+func TestGetPrompt_NotFound(t *testing.T) {
+	c, _ := setupTestServer(t)
+
+	_, err := c.GetPrompt(context.Background(), "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 404 {
+		t.Errorf("Expected APIError with status 404, got %v", err)
+	}
+	if apiErr != nil && (apiErr.RequestID == "" || !strings.Contains(err.Error(), apiErr.RequestID)) {
+		t.Errorf("Expected the registry's request ID in the error, got %v", err)
+	}
+}
+
+func TestBundle_RoundTrip(t *testing.T) {
+	bundle := &Bundle{
+		FormatVersion: BundleFormatVersion,
+		Source:        "http://registry",
+		Prompts: []models.PromptWithCurrentVersion{
+			{Slug: "greeting", CurrentVersion: models.PromptVersion{VersionNumber: 2, Content: "Hi"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, bundle); err != nil {
+		t.Fatalf("WriteBundle failed: %v", err)
+	}
+	loaded, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatalf("ReadBundle failed: %v", err)
+	}
+
+	prompt, ok := loaded.Get("greeting")
+	if !ok || prompt.CurrentVersion.Content != "Hi" {
+		t.Errorf("Expected bundled greeting, got %+v (found=%v)", prompt, ok)
+	}
+	if _, ok := loaded.Get("missing"); ok {
+		t.Error("Expected missing slug to be absent")
+	}
+}
+
+func TestGetPrompt_BundleFallback(t *testing.T) {
+	bundle := &Bundle{
+		FormatVersion: BundleFormatVersion,
+		Prompts: []models.PromptWithCurrentVersion{
+			{Slug: "greeting", CurrentVersion: models.PromptVersion{VersionNumber: 1, Content: "Bundled"}},
+		},
+	}
+
+	// Registry unreachable: served from the bundle
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	c := New(down.URL, WithBundleFallback(bundle))
+
+	prompt, err := c.GetPrompt(context.Background(), "greeting")
+	if err != nil {
+		t.Fatalf("Expected bundle fallback, got error: %v", err)
+	}
+	if prompt.CurrentVersion.Content != "Bundled" {
+		t.Errorf("Expected bundled content, got %q", prompt.CurrentVersion.Content)
+	}
+
+	// Registry reachable and authoritative: 404 is not masked
+	live, _ := setupTestServer(t)
+	c = New(live.BaseURL(), WithBundleFallback(bundle))
+	if _, err := c.GetPrompt(context.Background(), "greeting"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound from live registry, got %v", err)
+	}
+}
+
+func TestCache_RefreshAndOffline(t *testing.T) {
+	s, err := store.New(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test store: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Greeting", Content: "Hello"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	server := httptest.NewServer(handlers.New(s, logger).Routes())
+
+	dir := t.TempDir()
+	cache, err := NewCache(New(server.URL), CacheOptions{RefreshInterval: time.Hour, Dir: dir})
+	if err != nil {
+		t.Fatalf("NewCache failed: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	if err := cache.Warm(ctx, "greeting"); err != nil {
+		t.Fatalf("Warm failed: %v", err)
+	}
+
+	if _, err := s.CreatePromptVersion("greeting", models.CreatePromptVersionInput{Content: "Hello again"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	cache.Refresh(ctx)
+	prompt, err := cache.Get(ctx, "greeting")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if prompt.CurrentVersion.Content != "Hello again" {
+		t.Errorf("Expected refreshed content, got %q", prompt.CurrentVersion.Content)
+	}
+
+	// Registry goes away: the warm cache and a fresh cache over the same dir still serve
+	server.Close()
+	var refreshErrs int
+	cache.opts.OnRefreshError = func(string, error) { refreshErrs++ }
+	cache.Refresh(ctx)
+	if refreshErrs != 1 {
+		t.Errorf("Expected 1 refresh error, got %d", refreshErrs)
+	}
+	if prompt, err := cache.Get(ctx, "greeting"); err != nil || prompt.CurrentVersion.Content != "Hello again" {
+		t.Errorf("Expected stale entry to be served, got %q, %v", prompt.CurrentVersion.Content, err)
+	}
+
+	cold, err := NewCache(New(server.URL), CacheOptions{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewCache failed: %v", err)
+	}
+	defer cold.Close()
+	if prompt, err := cold.Get(ctx, "greeting"); err != nil || prompt.CurrentVersion.Content != "Hello again" {
+		t.Errorf("Expected prompt from disk, got %q, %v", prompt.CurrentVersion.Content, err)
+	}
+}
+
+func TestGetPromptIfChanged_NotModified(t *testing.T) {
+	c, s := setupTestServer(t)
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Greeting", Content: "Hello"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	ctx := context.Background()
+	_, etag, modified, err := c.getPromptIfChanged(ctx, "greeting", "")
+	if err != nil || !modified || etag == "" {
+		t.Fatalf("Expected a modified response with an ETag, got etag=%q modified=%v err=%v", etag, modified, err)
+	}
+
+	_, again, modified, err := c.getPromptIfChanged(ctx, "greeting", etag)
+	if err != nil || modified || again != etag {
+		t.Errorf("Expected 304 with the same ETag, got etag=%q modified=%v err=%v", again, modified, err)
+	}
+}
+
+func TestClient_CreateAndListVersions(t *testing.T) {
+	c, _ := setupTestServer(t)
+	ctx := context.Background()
+
+	created, err := c.CreatePrompt(ctx, models.CreatePromptInput{Slug: "greeting", Title: "Greeting", Content: "Hello"})
+	if err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if created.CurrentVersion.VersionNumber != 1 {
+		t.Errorf("Expected version 1, got %d", created.CurrentVersion.VersionNumber)
+	}
+
+	updated, err := c.CreateVersion(ctx, "greeting", models.CreatePromptVersionInput{Content: "Hi"})
+	if err != nil {
+		t.Fatalf("CreateVersion failed: %v", err)
+	}
+	if updated.CurrentVersion.VersionNumber != 2 {
+		t.Errorf("Expected version 2, got %d", updated.CurrentVersion.VersionNumber)
+	}
+
+	versions, err := c.ListVersions(ctx, "greeting")
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(versions))
+	}
+
+	first, err := c.GetVersion(ctx, "greeting", 1)
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if first.Content != "Hello" {
+		t.Errorf("Expected content %q, got %q", "Hello", first.Content)
+	}
+
+	if _, err := c.CreateVersion(ctx, "missing", models.CreatePromptVersionInput{Content: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestClient_Render(t *testing.T) {
+	c, s := setupTestServer(t)
+	_, err := s.CreatePrompt(models.CreatePromptInput{
+		Slug:      "greeting",
+		Title:     "Greeting",
+		Content:   "Hello {{name}}",
+		Variables: []models.Variable{{Name: "name", Type: "string", Required: true}},
+	})
+	if err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	result, err := c.Render(context.Background(), "greeting", map[string]any{"name": "Ada"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if result.Content != "Hello Ada" {
+		t.Errorf("Expected %q, got %q", "Hello Ada", result.Content)
+	}
+
+	var apiErr *APIError
+	if _, err := c.Render(context.Background(), "greeting", nil); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for missing required variable, got %v", err)
+	}
+}
+
+func TestClient_ReleaseRollback(t *testing.T) {
+	c, s := setupTestServer(t)
+	ctx := context.Background()
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "planner", Title: "Planner", Content: "v1"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+	if _, err := s.CreatePromptVersion("planner", models.CreatePromptVersionInput{Content: "v2"}); err != nil {
+		t.Fatalf("CreatePromptVersion failed: %v", err)
+	}
+	for _, version := range []int{1, 2} {
+		if _, err := s.CreateRelease(models.CreateReleaseInput{Label: "production", Items: []models.ReleaseItem{{Slug: "planner", Version: version}}}); err != nil {
+			t.Fatalf("CreateRelease failed: %v", err)
+		}
+	}
+
+	releases, err := c.ListReleases(ctx, "production", 10)
+	if err != nil {
+		t.Fatalf("ListReleases failed: %v", err)
+	}
+	if len(releases) != 2 || len(releases[0].Items) != 1 || releases[0].Items[0].Version != 2 {
+		t.Fatalf("Expected 2 releases with items, newest first, got %+v", releases)
+	}
+
+	rolledBack, err := c.RollbackRelease(ctx, releases[0].ID)
+	if err != nil {
+		t.Fatalf("RollbackRelease failed: %v", err)
+	}
+	if rolledBack.RolledBackAt == nil {
+		t.Errorf("Expected rolled back release, got %+v", rolledBack)
+	}
+	if label, err := s.GetPromptLabel("planner", "production"); err != nil || label.Version != 1 {
+		t.Errorf("Expected label back at v1, got %+v, %v", label, err)
+	}
+
+	var apiErr *APIError
+	if _, err := c.RollbackRelease(ctx, releases[0].ID); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("Expected 409 rolling back twice, got %v", err)
+	}
+}
+
+func TestClient_Capabilities(t *testing.T) {
+	c, _ := setupTestServer(t)
+
+	caps, err := c.Capabilities(context.Background())
+	if err != nil {
+		t.Fatalf("Capabilities failed: %v", err)
+	}
+	if caps.APIVersion != handlers.APIVersion || caps.Auth.Method != "none" {
+		t.Errorf("Unexpected capabilities: %+v", caps)
+	}
+	if caps.Limits.MaxContentBytes != handlers.DefaultMaxContentBytes {
+		t.Errorf("Expected default content limit, got %d", caps.Limits.MaxContentBytes)
+	}
+}
+
+func TestClient_GetPrompts(t *testing.T) {
+	c, s := setupTestServer(t)
+
+	if _, err := s.CreatePrompt(models.CreatePromptInput{Slug: "greeting", Title: "Greeting", Content: "Hello"}); err != nil {
+		t.Fatalf("CreatePrompt failed: %v", err)
+	}
+
+	result, err := c.GetPrompts(context.Background(), []string{"greeting", "farewell"})
+	if err != nil {
+		t.Fatalf("GetPrompts failed: %v", err)
+	}
+	if len(result.Prompts) != 1 || result.Prompts[0].CurrentVersion.Content != "Hello" {
+		t.Errorf("Expected the greeting prompt, got %+v", result.Prompts)
+	}
+	if len(result.Missing) != 1 || result.Missing[0] != "farewell" {
+		t.Errorf("Expected farewell to be missing, got %v", result.Missing)
+	}
+}
+
+func TestClient_APIErrorCode(t *testing.T) {
+	c, _ := setupTestServer(t)
+	ctx := context.Background()
+
+	var apiErr *APIError
+	if _, err := c.GetPrompt(ctx, "missing"); !errors.As(err, &apiErr) || apiErr.Code != "PROMPT_NOT_FOUND" || !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a PROMPT_NOT_FOUND error, got %v", err)
+	}
+	if _, err := c.CreatePrompt(ctx, models.CreatePromptInput{Slug: "Not A Slug", Title: "Bad", Content: "Hi"}); !errors.As(err, &apiErr) ||
+		apiErr.Code != "VALIDATION_FAILED" || apiErr.Field != "slug" {
+		t.Errorf("Expected a VALIDATION_FAILED error about slug, got %+v", apiErr)
+	}
+}
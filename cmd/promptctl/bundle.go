@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/client"
+)
+
+// listPageSize is the page size used when walking the full prompt listing
+const listPageSize = 100
+
+// listAllPrompts pages through every prompt summary in the registry
+func listAllPrompts(ctx context.Context, c *client.Client) ([]models.PromptSummary, error) {
+	var all []models.PromptSummary
+	for offset := 0; ; offset += listPageSize {
+		page, err := c.ListPrompts(ctx, listPageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < listPageSize {
+			return all, nil
+		}
+	}
+}
+
+// runBundle writes selected prompts' current versions to a compressed offline bundle
+func runBundle(c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("bundle", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "prompts.bundle.json.gz", "output file")
+	publicOnly := flags.Bool("public", false, "only include prompts visible in the public gallery")
+	timeout := flags.Duration("timeout", 60*time.Second, "overall timeout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	// Explicit slugs select exactly those prompts; otherwise bundle everything
+	slugs := flags.Args()
+	if len(slugs) == 0 {
+		summaries, err := listAllPrompts(ctx, c)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: failed to list prompts: %v\n", err)
+			return exitError
+		}
+		for _, s := range summaries {
+			if *publicOnly && !s.Public {
+				continue
+			}
+			slugs = append(slugs, s.Slug)
+		}
+	}
+
+	bundle := &client.Bundle{
+		FormatVersion: client.BundleFormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		Source:        c.BaseURL(),
+	}
+	for _, slug := range slugs {
+		prompt, err := c.GetPrompt(ctx, slug)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s: %v\n", slug, err)
+			return exitError
+		}
+		if *publicOnly && !prompt.Public {
+			fmt.Fprintf(stderr, "error: %s is not public\n", slug)
+			return exitError
+		}
+		bundle.Prompts = append(bundle.Prompts, prompt)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	if err := client.WriteBundle(f, bundle); err != nil {
+		f.Close()
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Fprintf(stdout, "wrote %d prompts to %s\n", len(bundle.Prompts), *output)
+	return exitOK
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/diff"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/client"
+)
+
+// runCompare checks the registry against a remote one and reports prompts
+// missing from either side, differing current content, and version count
+// drift. Exit code is 0 when they match, 1 on drift, and 2 on errors.
+func runCompare(c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("compare", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	remoteURL := flags.String("remote", "", "remote registry base URL")
+	remoteKey := flags.String("remote-api-key", "", "remote API key (default: $PROMPT_REGISTRY_REMOTE_API_KEY)")
+	showDiff := flags.Bool("diff", false, "print a unified diff for prompts whose content differs")
+	contextLines := flags.Int("context", 3, "lines of context in diffs")
+	timeout := flags.Duration("timeout", 60*time.Second, "overall timeout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *remoteURL == "" || flags.NArg() != 0 {
+		fmt.Fprintln(stderr, "usage: promptctl compare --remote URL [--remote-api-key KEY] [--diff]")
+		return exitError
+	}
+	if *remoteKey == "" {
+		*remoteKey = os.Getenv("PROMPT_REGISTRY_REMOTE_API_KEY")
+	}
+	remote := client.New(*remoteURL, client.WithAPIKey(*remoteKey))
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	localPrompts, err := listAllPrompts(ctx, c)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: failed to list prompts on %s: %v\n", c.BaseURL(), err)
+		return exitError
+	}
+	remotePrompts, err := listAllPrompts(ctx, remote)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: failed to list prompts on %s: %v\n", remote.BaseURL(), err)
+		return exitError
+	}
+
+	local := make(map[string]models.PromptSummary, len(localPrompts))
+	for _, p := range localPrompts {
+		local[p.Slug] = p
+	}
+	others := make(map[string]models.PromptSummary, len(remotePrompts))
+	for _, p := range remotePrompts {
+		others[p.Slug] = p
+	}
+	slugs := make([]string, 0, len(local)+len(others))
+	for slug := range local {
+		slugs = append(slugs, slug)
+	}
+	for slug := range others {
+		if _, ok := local[slug]; !ok {
+			slugs = append(slugs, slug)
+		}
+	}
+	sort.Strings(slugs)
+
+	var missing, changed, drifted, failed int
+	for _, slug := range slugs {
+		here, inLocal := local[slug]
+		there, inRemote := others[slug]
+		if !inRemote {
+			fmt.Fprintf(stdout, "only in %s: %s\n", c.BaseURL(), slug)
+			missing++
+			continue
+		}
+		if !inLocal {
+			fmt.Fprintf(stdout, "only in %s: %s\n", remote.BaseURL(), slug)
+			missing++
+			continue
+		}
+
+		// Versions are append-only, so the current version number is the version count
+		if here.CurrentVersion != there.CurrentVersion {
+			fmt.Fprintf(stdout, "version drift: %s is at v%d on %s, v%d on %s\n",
+				slug, here.CurrentVersion, c.BaseURL(), there.CurrentVersion, remote.BaseURL())
+			drifted++
+		}
+
+		a, err := c.GetPrompt(ctx, slug)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s on %s: %v\n", slug, c.BaseURL(), err)
+			failed++
+			continue
+		}
+		b, err := remote.GetPrompt(ctx, slug)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s on %s: %v\n", slug, remote.BaseURL(), err)
+			failed++
+			continue
+		}
+		if a.CurrentVersion.Content == b.CurrentVersion.Content {
+			continue
+		}
+		fmt.Fprintf(stdout, "content differs: %s (v%d on %s, v%d on %s)\n",
+			slug, a.CurrentVersion.VersionNumber, c.BaseURL(), b.CurrentVersion.VersionNumber, remote.BaseURL())
+		changed++
+		if *showDiff {
+			fmt.Fprint(stdout, diff.Unified(
+				fmt.Sprintf("%s/%s@v%d", c.BaseURL(), slug, a.CurrentVersion.VersionNumber),
+				fmt.Sprintf("%s/%s@v%d", remote.BaseURL(), slug, b.CurrentVersion.VersionNumber),
+				a.CurrentVersion.Content, b.CurrentVersion.Content, *contextLines,
+			))
+		}
+	}
+
+	fmt.Fprintf(stderr, "%d prompts compared: %d missing on one side, %d with different content, %d with version drift, %d errors\n",
+		len(slugs), missing, changed, drifted, failed)
+
+	switch {
+	case failed > 0:
+		return exitError
+	case missing > 0 || changed > 0 || drifted > 0:
+		return exitDrift
+	default:
+		return exitOK
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// config holds connection settings shared by every subcommand
+type config struct {
+	Server string `json:"server"`
+	APIKey string `json:"api_key"`
+}
+
+// defaultConfigPath returns $PROMPTCTL_CONFIG or <user config dir>/promptctl/config.json
+func defaultConfigPath() string {
+	if path := os.Getenv("PROMPTCTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "promptctl", "config.json")
+}
+
+// loadConfig resolves settings with precedence env > config file > defaults.
+// Command-line flags are applied on top by the caller. A missing file is not an error.
+func loadConfig(path string) (config, error) {
+	cfg := config{Server: "http://localhost:8080"}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return cfg, fmt.Errorf("failed to read config: %w", err)
+		default:
+			var file config
+			if err := json.Unmarshal(data, &file); err != nil {
+				return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+			}
+			if file.Server != "" {
+				cfg.Server = file.Server
+			}
+			cfg.APIKey = file.APIKey
+		}
+	}
+
+	cfg.Server = getEnv("PROMPT_REGISTRY_URL", cfg.Server)
+	cfg.APIKey = getEnv("PROMPT_REGISTRY_API_KEY", cfg.APIKey)
+	return cfg, nil
+}
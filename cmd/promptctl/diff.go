@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/diff"
+	"github.com/shahram/prompt-registry/client"
+)
+
+// promptFile is a local prompt file whose base name (minus extension) is the slug
+type promptFile struct {
+	Path string
+	Slug string
+}
+
+// findPromptFiles walks dir and returns prompt files, skipping hidden entries
+func findPromptFiles(dir string) ([]promptFile, error) {
+	var files []promptFile
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		name := d.Name()
+		files = append(files, promptFile{
+			Path: path,
+			Slug: strings.TrimSuffix(name, filepath.Ext(name)),
+		})
+		return nil
+	})
+	return files, err
+}
+
+// runDiff compares local prompt files with the registry's current versions.
+// Exit code is 0 when everything matches, 1 on drift, and 2 on errors.
+func runDiff(c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	contextLines := flags.Int("context", 3, "lines of context in diffs")
+	timeout := flags.Duration("timeout", 30*time.Second, "overall timeout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: promptctl diff [--context N] <dir>")
+		return exitError
+	}
+
+	files, err := findPromptFiles(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var changed, missing, failed int
+	for _, f := range files {
+		local, err := os.ReadFile(f.Path)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s: %v\n", f.Path, err)
+			failed++
+			continue
+		}
+
+		remote, err := c.GetPrompt(ctx, f.Slug)
+		if errors.Is(err, client.ErrNotFound) {
+			fmt.Fprintf(stdout, "only in %s: %s (no prompt %q in registry)\n", flags.Arg(0), f.Path, f.Slug)
+			missing++
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s: %v\n", f.Slug, err)
+			failed++
+			continue
+		}
+
+		remoteName := fmt.Sprintf("registry/%s@v%d", f.Slug, remote.CurrentVersion.VersionNumber)
+		if out := diff.Unified(remoteName, f.Path, remote.CurrentVersion.Content, string(local), *contextLines); out != "" {
+			fmt.Fprint(stdout, out)
+			changed++
+		}
+	}
+
+	fmt.Fprintf(stderr, "%d files checked: %d changed, %d missing from registry, %d errors\n",
+		len(files), changed, missing, failed)
+
+	switch {
+	case failed > 0:
+		return exitError
+	case changed > 0 || missing > 0:
+		return exitDrift
+	default:
+		return exitOK
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/shahram/prompt-registry/client"
+)
+
+// Exit codes shared by all subcommands
+const (
+	exitOK    = 0
+	exitDrift = 1 // command ran but found differences
+	exitError = 2
+)
+
+const usage = `promptctl - manage prompts in a prompt registry
+
+Usage:
+  promptctl [--server URL] [--api-key KEY] [--config FILE] <command> [arguments]
+
+Commands:
+  list                      List prompts with their current version
+  get <slug>                Print a prompt's current content (--version N for an older one)
+  push <file>               Publish a file as a new version, creating the prompt if needed
+                            (slug defaults to the file name minus extension)
+  versions <slug>           Show a prompt's version history
+  rollback <slug> <version> Re-publish an earlier version's content as the new current version
+  releases                  Show release history with each release's prompt versions
+                            (--label to show one label, --limit N)
+  rollback-release <id>     Put back every label a release moved
+                            (--label LABEL instead of an id: that label's latest active release)
+  diff <dir>                Compare local prompt files against registry current versions
+  compare --remote URL      Compare the registry against another one: missing prompts,
+                            differing current content, and version count drift
+                            (--remote-api-key KEY, --diff for unified diffs)
+  bundle [slug...]          Write prompts' current versions to an offline bundle
+                            (-o file, --public to restrict to gallery prompts)
+  publish-static [slug...]  Render prompts and their version history into a static HTML site
+                            (-o dir, --title, --base-url for canonical links, --public)
+  compact                   VACUUM/ANALYZE the registry database (API key must be the admin token)
+
+Environment:
+  PROMPT_REGISTRY_URL       Registry base URL (default: http://localhost:8080)
+  PROMPT_REGISTRY_API_KEY   API key sent as a bearer token
+  PROMPT_REGISTRY_REMOTE_API_KEY
+                            API key for the compare --remote registry
+  PROMPTCTL_CONFIG          Config file (default: <user config dir>/promptctl/config.json)
+
+The config file is JSON: {"server": "...", "api_key": "..."}. Flags override
+environment variables, which override the config file.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run parses global flags and dispatches to a subcommand, returning the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("promptctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	server := flags.String("server", "", "registry base URL")
+	apiKey := flags.String("api-key", "", "API key")
+	configPath := flags.String("config", defaultConfigPath(), "config file")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitError
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	if *server != "" {
+		cfg.Server = *server
+	}
+	if *apiKey != "" {
+		cfg.APIKey = *apiKey
+	}
+
+	c := client.New(cfg.Server, client.WithAPIKey(cfg.APIKey))
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+
+	switch command {
+	case "list":
+		return runList(c, commandArgs, stdout, stderr)
+	case "get":
+		return runGet(c, commandArgs, stdout, stderr)
+	case "push":
+		return runPush(c, commandArgs, stdout, stderr)
+	case "versions":
+		return runVersions(c, commandArgs, stdout, stderr)
+	case "rollback":
+		return runRollback(c, commandArgs, stdout, stderr)
+	case "releases":
+		return runReleases(c, commandArgs, stdout, stderr)
+	case "rollback-release":
+		return runRollbackRelease(c, commandArgs, stdout, stderr)
+	case "diff":
+		return runDiff(c, commandArgs, stdout, stderr)
+	case "compare":
+		return runCompare(c, commandArgs, stdout, stderr)
+	case "bundle":
+		return runBundle(c, commandArgs, stdout, stderr)
+	case "publish-static":
+		return runPublishStatic(c, commandArgs, stdout, stderr)
+	case "compact":
+		return runCompact(c, commandArgs, stdout, stderr)
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n", command)
+		flags.Usage()
+		return exitError
+	}
+}
+
+// getEnv retrieves an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
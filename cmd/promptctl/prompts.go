@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/client"
+)
+
+// runList prints every prompt with its current version number
+func runList(c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	timeout := flags.Duration("timeout", 30*time.Second, "overall timeout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	prompts, err := listAllPrompts(ctx, c)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: failed to list prompts: %v\n", err)
+		return exitError
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SLUG\tVERSION\tUPDATED\tTITLE")
+	for _, p := range prompts {
+		fmt.Fprintf(tw, "%s\tv%d\t%s\t%s\n", p.Slug, p.CurrentVersion, p.UpdatedAt.Format(time.DateTime), p.Title)
+	}
+	tw.Flush()
+	return exitOK
+}
+
+// runGet writes a prompt's content (current or a specific version) to stdout
+func runGet(c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	version := flags.Int("version", 0, "version number (default: current)")
+	timeout := flags.Duration("timeout", 30*time.Second, "overall timeout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: promptctl get [--version N] <slug>")
+		return exitError
+	}
+	slug := flags.Arg(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var content string
+	if *version > 0 {
+		v, err := c.GetVersion(ctx, slug, *version)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s@v%d: %v\n", slug, *version, err)
+			return exitError
+		}
+		content = v.Content
+	} else {
+		p, err := c.GetPrompt(ctx, slug)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s: %v\n", slug, err)
+			return exitError
+		}
+		content = p.CurrentVersion.Content
+	}
+
+	fmt.Fprint(stdout, content)
+	if !strings.HasSuffix(content, "\n") {
+		fmt.Fprintln(stdout)
+	}
+	return exitOK
+}
+
+// runPush uploads a local file as a new version, creating the prompt if needed.
+// Files whose content already matches the current version are left alone.
+func runPush(c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("push", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	slug := flags.String("slug", "", "prompt slug (default: file name minus extension)")
+	title := flags.String("title", "", "title when creating a new prompt (default: slug)")
+	description := flags.String("description", "", "description when creating a new prompt")
+	timeout := flags.Duration("timeout", 30*time.Second, "overall timeout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: promptctl push [--slug S] [--title T] <file>")
+		return exitError
+	}
+
+	path := flags.Arg(0)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	if *slug == "" {
+		name := filepath.Base(path)
+		*slug = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	// Registries that predate /api/capabilities still check the size themselves
+	if caps, err := c.Capabilities(ctx); err == nil {
+		if limit := caps.Limits.MaxContentBytes; limit > 0 && len(content) > limit {
+			fmt.Fprintf(stderr, "error: %s is %d bytes; the registry accepts at most %d\n", path, len(content), limit)
+			return exitError
+		}
+	}
+
+	current, err := c.GetPrompt(ctx, *slug)
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		if *title == "" {
+			*title = *slug
+		}
+		created, err := c.CreatePrompt(ctx, models.CreatePromptInput{
+			Slug:        *slug,
+			Title:       *title,
+			Description: *description,
+			Content:     string(content),
+		})
+		if err != nil {
+			fmt.Fprintf(stderr, "error: failed to create %s: %v\n", *slug, err)
+			return exitError
+		}
+		fmt.Fprintf(stdout, "created %s v%d\n", created.Slug, created.CurrentVersion.VersionNumber)
+		return exitOK
+	case err != nil:
+		fmt.Fprintf(stderr, "error: %s: %v\n", *slug, err)
+		return exitError
+	}
+
+	if current.CurrentVersion.Content == string(content) {
+		fmt.Fprintf(stdout, "unchanged %s v%d\n", *slug, current.CurrentVersion.VersionNumber)
+		return exitOK
+	}
+
+	// Deduplicate catches a push racing another that sent the same content;
+	// BaseVersion rejects one racing a push of different content, rather than
+	// silently replacing it
+	base := current.CurrentVersion.VersionNumber
+	updated, err := c.CreateVersion(ctx, *slug, models.CreatePromptVersionInput{
+		Content:     string(content),
+		Deduplicate: true,
+		BaseVersion: &base,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "error: failed to push %s: %v\n", *slug, err)
+		return exitError
+	}
+	if updated.Unchanged {
+		fmt.Fprintf(stdout, "unchanged %s v%d\n", updated.Slug, updated.CurrentVersion.VersionNumber)
+		return exitOK
+	}
+	fmt.Fprintf(stdout, "pushed %s v%d\n", updated.Slug, updated.CurrentVersion.VersionNumber)
+	return exitOK
+}
+
+// runVersions prints a prompt's version history
+func runVersions(c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("versions", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	timeout := flags.Duration("timeout", 30*time.Second, "overall timeout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: promptctl versions <slug>")
+		return exitError
+	}
+	slug := flags.Arg(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	versions, err := c.ListVersions(ctx, slug)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s: %v\n", slug, err)
+		return exitError
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tCREATED\tLINES\tFIRST LINE")
+	for _, v := range versions {
+		firstLine, _, _ := strings.Cut(v.Content, "\n")
+		if len(firstLine) > 60 {
+			firstLine = firstLine[:57] + "..."
+		}
+		fmt.Fprintf(tw, "v%d\t%s\t%d\t%s\n", v.VersionNumber, v.CreatedAt.Format(time.DateTime),
+			strings.Count(strings.TrimSuffix(v.Content, "\n"), "\n")+1, firstLine)
+	}
+	tw.Flush()
+	return exitOK
+}
+
+// runRollback makes an earlier version current again by re-publishing its
+// content as a new version, so history is never rewritten
+func runRollback(c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	timeout := flags.Duration("timeout", 30*time.Second, "overall timeout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintln(stderr, "usage: promptctl rollback <slug> <version>")
+		return exitError
+	}
+	slug := flags.Arg(0)
+	target, err := strconv.Atoi(strings.TrimPrefix(flags.Arg(1), "v"))
+	if err != nil || target < 1 {
+		fmt.Fprintf(stderr, "error: invalid version %q\n", flags.Arg(1))
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	old, err := c.GetVersion(ctx, slug, target)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s@v%d: %v\n", slug, target, err)
+		return exitError
+	}
+
+	updated, err := c.CreateVersion(ctx, slug, models.CreatePromptVersionInput{Content: old.Content})
+	if err != nil {
+		fmt.Fprintf(stderr, "error: failed to roll back %s: %v\n", slug, err)
+		return exitError
+	}
+	fmt.Fprintf(stdout, "rolled back %s to v%d content as v%d\n", slug, target, updated.CurrentVersion.VersionNumber)
+	return exitOK
+}
+
+// runCompact asks the registry to VACUUM its database and reports reclaimed space
+func runCompact(c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("compact", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	timeout := flags.Duration("timeout", 10*time.Minute, "overall timeout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	result, err := c.Compact(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: compact failed: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintf(stdout, "compacted in %dms: %d -> %d bytes (%d reclaimed)\n",
+		result.DurationMs, result.SizeBeforeBytes, result.SizeAfterBytes, result.ReclaimedBytes)
+	return exitOK
+}
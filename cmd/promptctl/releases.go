@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/client"
+)
+
+// runReleases prints release history, newest first, with the versions each
+// release promoted
+func runReleases(c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("releases", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	label := flags.String("label", "", "only releases of this label")
+	limit := flags.Int("limit", 20, "number of releases to show")
+	timeout := flags.Duration("timeout", 30*time.Second, "overall timeout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintln(stderr, "usage: promptctl releases [--label LABEL] [--limit N]")
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	releases, err := c.ListReleases(ctx, *label, *limit)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: failed to list releases: %v\n", err)
+		return exitError
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tLABEL\tCREATED\tBY\tSTATUS\tPROMPTS")
+	for _, r := range releases {
+		status := "active"
+		if r.RolledBackAt != nil {
+			status = "rolled back " + r.RolledBackAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Label, r.CreatedAt.Format(time.DateTime),
+			r.CreatedBy, status, releaseItems(r.Items))
+	}
+	tw.Flush()
+	return exitOK
+}
+
+// runRollbackRelease undoes a release, by id or, with --label, the label's
+// most recent release that is still active
+func runRollbackRelease(c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rollback-release", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	label := flags.String("label", "", "roll back this label's latest active release")
+	timeout := flags.Duration("timeout", 30*time.Second, "overall timeout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if (flags.NArg() == 1) == (*label != "") || flags.NArg() > 1 {
+		fmt.Fprintln(stderr, "usage: promptctl rollback-release <id> | --label LABEL")
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var id int64
+	if *label != "" {
+		releases, err := c.ListReleases(ctx, *label, 100)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: failed to list releases: %v\n", err)
+			return exitError
+		}
+		for _, r := range releases {
+			if r.RolledBackAt == nil {
+				id = r.ID
+				break
+			}
+		}
+		if id == 0 {
+			fmt.Fprintf(stderr, "error: no active release of %s\n", *label)
+			return exitError
+		}
+	} else {
+		parsed, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+		if err != nil || parsed < 1 {
+			fmt.Fprintf(stderr, "error: invalid release id %q\n", flags.Arg(0))
+			return exitError
+		}
+		id = parsed
+	}
+
+	release, err := c.RollbackRelease(ctx, id)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: failed to roll back release %d: %v\n", id, err)
+		return exitError
+	}
+	fmt.Fprintf(stdout, "rolled back release %d of %s\n", release.ID, release.Label)
+	for _, item := range release.Items {
+		if item.PreviousVersion > 0 {
+			fmt.Fprintf(stdout, "  %s: v%d -> v%d\n", item.Slug, item.Version, item.PreviousVersion)
+		} else {
+			fmt.Fprintf(stdout, "  %s: v%d -> label removed\n", item.Slug, item.Version)
+		}
+	}
+	return exitOK
+}
+
+// releaseItems formats a release's prompts as slug@vN, comma separated
+func releaseItems(items []models.ReleaseItem) string {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		parts = append(parts, fmt.Sprintf("%s@v%d", item.Slug, item.Version))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/gallery"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/client"
+)
+
+// staticSlug matches slugs that are safe to use as file names and links
+var staticSlug = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// runPublishStatic renders prompts into a static HTML site: index.html and
+// prompts/<slug>.html with each prompt's version history
+func runPublishStatic(c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("publish-static", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "site", "output directory")
+	title := flags.String("title", "Prompt Library", "site title")
+	baseURL := flags.String("base-url", "", "URL the site is hosted at, for canonical links")
+	publicOnly := flags.Bool("public", false, "only include prompts visible in the public gallery")
+	timeout := flags.Duration("timeout", 5*time.Minute, "overall timeout")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	summaries, err := listAllPrompts(ctx, c)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: failed to list prompts: %v\n", err)
+		return exitError
+	}
+
+	// Explicit slugs select exactly those prompts; otherwise publish everything
+	selected := make(map[string]bool)
+	for _, slug := range flags.Args() {
+		selected[slug] = true
+	}
+	summaries = slices.DeleteFunc(summaries, func(s models.PromptSummary) bool {
+		if len(selected) > 0 && !selected[s.Slug] {
+			return true
+		}
+		return *publicOnly && !s.Public
+	})
+	for _, s := range summaries {
+		delete(selected, s.Slug)
+	}
+	for slug := range selected {
+		fmt.Fprintf(stderr, "error: %s: not found\n", slug)
+		return exitError
+	}
+
+	if err := os.MkdirAll(filepath.Join(*output, "prompts"), 0o755); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	canonical := func(path string) string {
+		if *baseURL == "" {
+			return ""
+		}
+		return strings.TrimRight(*baseURL, "/") + "/" + path
+	}
+
+	var published []models.PromptSummary
+	for _, s := range summaries {
+		if !staticSlug.MatchString(s.Slug) {
+			fmt.Fprintf(stderr, "warning: %s: slug can't be used as a file name, skipped\n", s.Slug)
+			continue
+		}
+		prompt, err := c.GetPrompt(ctx, s.Slug)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s: %v\n", s.Slug, err)
+			return exitError
+		}
+		versions, err := c.ListVersions(ctx, s.Slug)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s: failed to list versions: %v\n", s.Slug, err)
+			return exitError
+		}
+		slices.Reverse(versions)
+
+		path := "prompts/" + s.Slug + ".html"
+		if err := writePage(filepath.Join(*output, filepath.FromSlash(path)), "prompt", map[string]any{
+			"Title":     *title,
+			"Prefix":    "..",
+			"Ext":       ".html",
+			"Canonical": canonical(path),
+			"Prompt":    prompt,
+			"Versions":  versions,
+		}); err != nil {
+			fmt.Fprintf(stderr, "error: %s: %v\n", s.Slug, err)
+			return exitError
+		}
+		published = append(published, s)
+	}
+
+	if err := writePage(filepath.Join(*output, "index.html"), "index", map[string]any{
+		"Title":     *title,
+		"Prefix":    ".",
+		"Ext":       ".html",
+		"Canonical": canonical("index.html"),
+		"Prompts":   published,
+	}); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Fprintf(stdout, "wrote %d prompts to %s\n", len(published), *output)
+	return exitOK
+}
+
+// writePage renders a gallery template to a file
+func writePage(path, name string, data map[string]any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gallery.Templates.ExecuteTemplate(f, name, data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	return f.Close()
+}
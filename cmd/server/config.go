@@ -0,0 +1,655 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shahram/prompt-registry/backend/handlers"
+	"github.com/shahram/prompt-registry/backend/store"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the server configuration. Each setting comes from, in order of
+// precedence: a command-line flag, an environment variable, the YAML config
+// file, and the default.
+type Config struct {
+	Server        ServerConfig        `yaml:"server"`
+	Database      DatabaseConfig      `yaml:"database"`
+	CORS          CORSConfig          `yaml:"cors"`
+	Auth          AuthConfig          `yaml:"auth"`
+	Logging       LoggingConfig       `yaml:"logging"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit"`
+	Webhooks      WebhooksConfig      `yaml:"webhooks"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+}
+
+// ServerConfig covers the HTTP listener
+type ServerConfig struct {
+	Port    string `yaml:"port"`
+	BaseURL string `yaml:"base_url"`
+	// BasePath serves every route under this prefix, for running behind a
+	// gateway that forwards a sub-path without rewriting it
+	BasePath        string   `yaml:"base_path"`
+	ReadTimeout     Duration `yaml:"read_timeout"`
+	WriteTimeout    Duration `yaml:"write_timeout"`
+	IdleTimeout     Duration `yaml:"idle_timeout"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout"`
+	TLSCertFile     string   `yaml:"tls_cert_file"`
+	TLSKeyFile      string   `yaml:"tls_key_file"`
+	TLSClientCAFile string   `yaml:"tls_client_ca_file"`
+	// TLSAutocertDomains gets certificates for these hostnames from Let's
+	// Encrypt instead of reading them from files
+	TLSAutocertDomains  []string `yaml:"tls_autocert_domains"`
+	TLSAutocertCacheDir string   `yaml:"tls_autocert_cache_dir"`
+	TLSAutocertEmail    string   `yaml:"tls_autocert_email"`
+	// HTTPRedirectPort serves plain HTTP on this port, redirecting to HTTPS
+	HTTPRedirectPort string `yaml:"http_redirect_port"`
+	// MaxContentBytes rejects prompt versions with larger content; 0 means no limit
+	MaxContentBytes int `yaml:"max_content_bytes"`
+	// MaxBodyBytes rejects larger request bodies; 0 means no limit
+	MaxBodyBytes int `yaml:"max_body_bytes"`
+	// MaxTitleLength and MaxDescriptionLength are in characters; 0 means no limit
+	MaxTitleLength       int `yaml:"max_title_length"`
+	MaxDescriptionLength int `yaml:"max_description_length"`
+	// MaxMetadataBytes limits the JSON size of prompt and version metadata; 0 means no limit
+	MaxMetadataBytes int `yaml:"max_metadata_bytes"`
+	// ReadyTimeout bounds the /readyz checks; ReadyWriteProbe adds a check
+	// that the database can take the write lock
+	ReadyTimeout    Duration `yaml:"ready_timeout"`
+	ReadyWriteProbe bool     `yaml:"ready_write_probe"`
+	// ReadOnly starts the server rejecting changes, e.g. for a replica
+	// serving stale reads; admins can turn it off at runtime
+	ReadOnly bool `yaml:"read_only"`
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-For header gives the client IP
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// DatabaseConfig covers the SQLite database
+type DatabaseConfig struct {
+	Path         string   `yaml:"path"`
+	BusyTimeout  Duration `yaml:"busy_timeout"`
+	MaxOpenConns int      `yaml:"max_open_conns"`
+	JournalMode  string   `yaml:"journal_mode"`
+	Synchronous  string   `yaml:"synchronous"`
+	QueryTimeout Duration `yaml:"query_timeout"`
+	// CacheSize is how many prompts are cached in memory; 0 disables the cache
+	CacheSize int `yaml:"cache_size"`
+}
+
+// CORSConfig covers browser access from other origins
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	AllowedHeaders []string `yaml:"allowed_headers"`
+}
+
+// AuthConfig covers API and admin authentication
+type AuthConfig struct {
+	Method string `yaml:"method"`
+	// APIKeys maps each key's name to its secret
+	APIKeys             map[string]string `yaml:"api_keys"`
+	OIDCIssuer          string            `yaml:"oidc_issuer"`
+	OIDCAudience        string            `yaml:"oidc_audience"`
+	MTLSAllowedSubjects []string          `yaml:"mtls_allowed_subjects"`
+	AdminToken          string            `yaml:"admin_token"`
+	// CSRF requires browser writes without explicit credentials to send a
+	// token from /api/csrf; CSRFSecret signs the tokens
+	CSRF       bool   `yaml:"csrf"`
+	CSRFSecret string `yaml:"csrf_secret"`
+}
+
+// RateLimitConfig covers the per-caller /api/* rate limit
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"` // 0 disables the limit
+	Burst             int     `yaml:"burst"`
+}
+
+// WebhooksConfig covers the webhooks notified about every prompt
+type WebhooksConfig struct {
+	URLs   []string `yaml:"urls"`
+	Secret string   `yaml:"secret"`
+}
+
+// NotificationsConfig covers the chat messages posted about new versions,
+// rollbacks, and deletions
+type NotificationsConfig struct {
+	// SlackWebhookURL is the Slack incoming webhook for every project
+	// without its own
+	SlackWebhookURL string `yaml:"slack_webhook_url"`
+	// ProjectSlackWebhookURLs sends a project's messages to its own Slack
+	// incoming webhook instead
+	ProjectSlackWebhookURLs map[string]string `yaml:"project_slack_webhook_urls"`
+}
+
+// LoggingConfig covers application and access logs
+type LoggingConfig struct {
+	Format    string          `yaml:"format"`
+	Level     string          `yaml:"level"`
+	AccessLog AccessLogConfig `yaml:"access_log"`
+}
+
+// AccessLogConfig covers the per-request access log line
+type AccessLogConfig struct {
+	SampleRate   int      `yaml:"sample_rate"`
+	Fields       []string `yaml:"fields"`
+	ExcludePaths []string `yaml:"exclude_paths"`
+}
+
+// Duration is a time.Duration written as a string such as "15s" in YAML
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", node.Line, node.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+// defaultConfig returns the settings used when nothing overrides them
+func defaultConfig() Config {
+	cors := handlers.DefaultCORSConfig()
+	rateLimit := handlers.DefaultRateLimitConfig()
+	return Config{
+		Server: ServerConfig{
+			Port:                 "8080",
+			BaseURL:              "http://localhost:8080",
+			ReadTimeout:          Duration(15 * time.Second),
+			WriteTimeout:         Duration(15 * time.Second),
+			IdleTimeout:          Duration(60 * time.Second),
+			ShutdownTimeout:      Duration(30 * time.Second),
+			TLSAutocertCacheDir:  "./data/autocert",
+			MaxContentBytes:      handlers.DefaultMaxContentBytes,
+			MaxBodyBytes:         handlers.DefaultMaxBodyBytes,
+			MaxTitleLength:       handlers.DefaultMaxTitleLength,
+			MaxDescriptionLength: handlers.DefaultMaxDescriptionLength,
+			MaxMetadataBytes:     handlers.DefaultMaxMetadataBytes,
+			ReadyTimeout:         Duration(handlers.DefaultReadinessConfig().Timeout),
+		},
+		Database: DatabaseConfig{
+			Path:        "./data/prompts.db",
+			BusyTimeout: Duration(5 * time.Second),
+			JournalMode: store.DefaultJournalMode,
+			Synchronous: store.DefaultSynchronous,
+			CacheSize:   store.DefaultCacheSize,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: cors.AllowedOrigins,
+			AllowedHeaders: cors.AllowedHeaders,
+		},
+		Auth: AuthConfig{
+			Method: "none",
+			CSRF:   true,
+		},
+		Logging: LoggingConfig{
+			Format: "text",
+			Level:  "info",
+			AccessLog: AccessLogConfig{
+				SampleRate: handlers.DefaultAccessLogConfig().SampleRate,
+			},
+		},
+		RateLimit: RateLimitConfig{
+			RequestsPerSecond: rateLimit.RequestsPerSecond,
+			Burst:             rateLimit.Burst,
+		},
+	}
+}
+
+// loadConfig builds the configuration from the defaults, the config file at
+// path (if any), and the environment. Flags are applied by the caller.
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+	if path != "" {
+		if err := loadConfigFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// loadConfigFile overlays a YAML config file on cfg. Unknown keys are
+// rejected so typos don't silently fall back to defaults.
+func loadConfigFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides cfg with the environment variables that are set
+func applyEnv(cfg *Config) error {
+	var errs []error
+	str := func(key string, dst *string) {
+		if v := os.Getenv(key); v != "" {
+			*dst = v
+		}
+	}
+	list := func(key string, dst *[]string) {
+		if os.Getenv(key) != "" {
+			*dst = getEnvList(key)
+		}
+	}
+	integer := func(key string, dst *int) {
+		if v := os.Getenv(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s %q: want an integer", key, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	number := func(key string, dst *float64) {
+		if v := os.Getenv(key); v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s %q: want a number", key, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	boolean := func(key string, dst *bool) {
+		if v := os.Getenv(key); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s %q: want true or false", key, v))
+				return
+			}
+			*dst = b
+		}
+	}
+	duration := func(key string, dst *Duration) {
+		if v := os.Getenv(key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s %q: want a duration such as 15s", key, v))
+				return
+			}
+			*dst = Duration(d)
+		}
+	}
+
+	str("PORT", &cfg.Server.Port)
+	str("BASE_URL", &cfg.Server.BaseURL)
+	str("BASE_PATH", &cfg.Server.BasePath)
+	duration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	duration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+	duration("SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout)
+	duration("SERVER_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+	str("TLS_CERT_FILE", &cfg.Server.TLSCertFile)
+	str("TLS_KEY_FILE", &cfg.Server.TLSKeyFile)
+	str("TLS_CLIENT_CA_FILE", &cfg.Server.TLSClientCAFile)
+	list("TLS_AUTOCERT_DOMAINS", &cfg.Server.TLSAutocertDomains)
+	str("TLS_AUTOCERT_CACHE_DIR", &cfg.Server.TLSAutocertCacheDir)
+	str("TLS_AUTOCERT_EMAIL", &cfg.Server.TLSAutocertEmail)
+	str("HTTP_REDIRECT_PORT", &cfg.Server.HTTPRedirectPort)
+	integer("MAX_CONTENT_BYTES", &cfg.Server.MaxContentBytes)
+	integer("MAX_BODY_BYTES", &cfg.Server.MaxBodyBytes)
+	integer("MAX_TITLE_LENGTH", &cfg.Server.MaxTitleLength)
+	integer("MAX_DESCRIPTION_LENGTH", &cfg.Server.MaxDescriptionLength)
+	integer("MAX_METADATA_BYTES", &cfg.Server.MaxMetadataBytes)
+	duration("READY_TIMEOUT", &cfg.Server.ReadyTimeout)
+	boolean("READY_WRITE_PROBE", &cfg.Server.ReadyWriteProbe)
+	boolean("READ_ONLY", &cfg.Server.ReadOnly)
+	list("TRUSTED_PROXIES", &cfg.Server.TrustedProxies)
+
+	str("DATABASE_PATH", &cfg.Database.Path)
+	duration("DATABASE_BUSY_TIMEOUT", &cfg.Database.BusyTimeout)
+	integer("DATABASE_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns)
+	duration("DATABASE_QUERY_TIMEOUT", &cfg.Database.QueryTimeout)
+	str("DATABASE_JOURNAL_MODE", &cfg.Database.JournalMode)
+	str("DATABASE_SYNCHRONOUS", &cfg.Database.Synchronous)
+	integer("DATABASE_CACHE_SIZE", &cfg.Database.CacheSize)
+
+	list("CORS_ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+	list("CORS_ALLOWED_HEADERS", &cfg.CORS.AllowedHeaders)
+
+	str("AUTH_METHOD", &cfg.Auth.Method)
+	if os.Getenv("API_KEYS") != "" {
+		cfg.Auth.APIKeys = make(map[string]string)
+		for _, entry := range getEnvList("API_KEYS") {
+			name, key, ok := strings.Cut(entry, ":")
+			if !ok || name == "" || key == "" {
+				errs = append(errs, fmt.Errorf("invalid API_KEYS entry %q: want name:key", entry))
+				continue
+			}
+			cfg.Auth.APIKeys[name] = key
+		}
+	}
+	str("OIDC_ISSUER", &cfg.Auth.OIDCIssuer)
+	str("OIDC_AUDIENCE", &cfg.Auth.OIDCAudience)
+	list("MTLS_ALLOWED_SUBJECTS", &cfg.Auth.MTLSAllowedSubjects)
+	str("ADMIN_TOKEN", &cfg.Auth.AdminToken)
+	boolean("CSRF_ENABLED", &cfg.Auth.CSRF)
+	str("CSRF_SECRET", &cfg.Auth.CSRFSecret)
+
+	str("LOG_FORMAT", &cfg.Logging.Format)
+	str("LOG_LEVEL", &cfg.Logging.Level)
+	integer("ACCESS_LOG_SAMPLE_RATE", &cfg.Logging.AccessLog.SampleRate)
+	list("ACCESS_LOG_FIELDS", &cfg.Logging.AccessLog.Fields)
+	list("ACCESS_LOG_EXCLUDE_PATHS", &cfg.Logging.AccessLog.ExcludePaths)
+
+	number("RATE_LIMIT_RPS", &cfg.RateLimit.RequestsPerSecond)
+	integer("RATE_LIMIT_BURST", &cfg.RateLimit.Burst)
+
+	list("WEBHOOK_URLS", &cfg.Webhooks.URLs)
+	str("WEBHOOK_SECRET", &cfg.Webhooks.Secret)
+
+	str("SLACK_WEBHOOK_URL", &cfg.Notifications.SlackWebhookURL)
+	if os.Getenv("SLACK_PROJECT_WEBHOOK_URLS") != "" {
+		cfg.Notifications.ProjectSlackWebhookURLs = make(map[string]string)
+		for _, entry := range getEnvList("SLACK_PROJECT_WEBHOOK_URLS") {
+			project, webhookURL, ok := strings.Cut(entry, "=")
+			if !ok || project == "" || webhookURL == "" {
+				errs = append(errs, fmt.Errorf("invalid SLACK_PROJECT_WEBHOOK_URLS entry %q: want project=url", entry))
+				continue
+			}
+			cfg.Notifications.ProjectSlackWebhookURLs[project] = webhookURL
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// configFlags registers the flags that override the config file and the
+// environment. The returned function applies the ones that were set.
+func configFlags(fs *flag.FlagSet) func(*Config) {
+	port := fs.String("port", "", "HTTP port (PORT)")
+	baseURL := fs.String("base-url", "", "base URL of the registry (BASE_URL)")
+	dbPath := fs.String("database", "", "SQLite database path (DATABASE_PATH)")
+	authMethod := fs.String("auth-method", "", "API authentication: none, apikey, oidc, or mtls (AUTH_METHOD)")
+	logFormat := fs.String("log-format", "", "log format: text or json (LOG_FORMAT)")
+	logLevel := fs.String("log-level", "", "log level: debug, info, warn, or error (LOG_LEVEL)")
+	return func(cfg *Config) {
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "port":
+				cfg.Server.Port = *port
+			case "base-url":
+				cfg.Server.BaseURL = *baseURL
+			case "database":
+				cfg.Database.Path = *dbPath
+			case "auth-method":
+				cfg.Auth.Method = *authMethod
+			case "log-format":
+				cfg.Logging.Format = *logFormat
+			case "log-level":
+				cfg.Logging.Level = *logLevel
+			}
+		})
+	}
+}
+
+// Validate reports every invalid setting at once
+func (c Config) Validate() error {
+	var errs []error
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port %q is not a port number", c.Server.Port))
+	}
+	if u, err := url.Parse(c.Server.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("server.base_url %q must be an absolute URL", c.Server.BaseURL))
+	}
+	if p := c.Server.BasePath; p != "" && (!strings.HasPrefix(p, "/") || strings.ContainsAny(p, "?#%{} ")) {
+		errs = append(errs, fmt.Errorf("server.base_path %q must be a path starting with /, such as /prompt-registry", p))
+	}
+	for name, d := range map[string]Duration{
+		"read_timeout":  c.Server.ReadTimeout,
+		"write_timeout": c.Server.WriteTimeout,
+		"idle_timeout":  c.Server.IdleTimeout,
+	} {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("server.%s cannot be negative", name))
+		}
+	}
+	for name, n := range map[string]int{
+		"max_content_bytes":      c.Server.MaxContentBytes,
+		"max_body_bytes":         c.Server.MaxBodyBytes,
+		"max_title_length":       c.Server.MaxTitleLength,
+		"max_description_length": c.Server.MaxDescriptionLength,
+		"max_metadata_bytes":     c.Server.MaxMetadataBytes,
+	} {
+		if n < 0 {
+			errs = append(errs, fmt.Errorf("server.%s cannot be negative", name))
+		}
+	}
+	if c.Server.MaxBodyBytes > 0 && c.Server.MaxContentBytes > c.Server.MaxBodyBytes {
+		errs = append(errs, errors.New("server.max_body_bytes must be at least server.max_content_bytes"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if c.Server.ReadyTimeout <= 0 {
+		errs = append(errs, errors.New("server.ready_timeout must be positive"))
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		errs = append(errs, errors.New("server.tls_cert_file and server.tls_key_file must be set together"))
+	}
+	if c.Server.TLSCertFile != "" && len(c.Server.TLSAutocertDomains) > 0 {
+		errs = append(errs, errors.New("server.tls_cert_file and server.tls_autocert_domains can't be used together"))
+	}
+	if len(c.Server.TLSAutocertDomains) > 0 && c.Server.TLSAutocertCacheDir == "" {
+		errs = append(errs, errors.New("server.tls_autocert_domains needs server.tls_autocert_cache_dir"))
+	}
+	if c.Server.TLSClientCAFile != "" && !c.Server.tlsEnabled() {
+		errs = append(errs, errors.New("server.tls_client_ca_file needs server.tls_cert_file and server.tls_key_file, or server.tls_autocert_domains"))
+	}
+	for _, entry := range c.Server.TrustedProxies {
+		if _, err := parseTrustedProxy(entry); err != nil {
+			errs = append(errs, fmt.Errorf("server.trusted_proxies entry %q must be an IP address or a CIDR range such as 10.0.0.0/8", entry))
+		}
+	}
+	if c.Server.HTTPRedirectPort != "" {
+		if !c.Server.tlsEnabled() {
+			errs = append(errs, errors.New("server.http_redirect_port needs TLS: server.tls_cert_file or server.tls_autocert_domains"))
+		}
+		if c.Server.HTTPRedirectPort == c.Server.Port {
+			errs = append(errs, errors.New("server.http_redirect_port must differ from server.port"))
+		}
+	}
+
+	if c.Database.Path == "" {
+		errs = append(errs, errors.New("database.path cannot be empty"))
+	}
+	if c.Database.CacheSize < 0 {
+		errs = append(errs, errors.New("database.cache_size cannot be negative"))
+	}
+	if err := c.storeOptions().Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("database: %w", err))
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+			errs = append(errs, fmt.Errorf("cors.allowed_origins entry %q must be \"*\" or a scheme and host such as https://app.example.com", origin))
+		}
+	}
+
+	switch c.Auth.Method {
+	case "none", "oidc":
+	case "apikey":
+		if len(c.Auth.APIKeys) == 0 {
+			errs = append(errs, errors.New("auth.method apikey needs auth.api_keys"))
+		}
+	case "mtls":
+		if !c.Server.tlsEnabled() || c.Server.TLSClientCAFile == "" {
+			errs = append(errs, errors.New("auth.method mtls needs TLS and server.tls_client_ca_file"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth.method %q must be none, apikey, oidc, or mtls", c.Auth.Method))
+	}
+
+	if !slices.Contains([]string{"text", "json"}, c.Logging.Format) {
+		errs = append(errs, fmt.Errorf("logging.format %q must be text or json", c.Logging.Format))
+	}
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, c.Logging.Level) {
+		errs = append(errs, fmt.Errorf("logging.level %q must be debug, info, warn, or error", c.Logging.Level))
+	}
+	if err := c.accessLog().Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("logging.access_log: %w", err))
+	}
+
+	if c.RateLimit.RequestsPerSecond < 0 {
+		errs = append(errs, errors.New("rate_limit.requests_per_second cannot be negative"))
+	}
+	if c.RateLimit.Burst < 0 {
+		errs = append(errs, errors.New("rate_limit.burst cannot be negative"))
+	}
+	for _, target := range c.Webhooks.URLs {
+		if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhooks.urls entry %q must be an http or https URL", target))
+		}
+	}
+	if target := c.Notifications.SlackWebhookURL; target != "" {
+		if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, errors.New("notifications.slack_webhook_url must be an http or https URL"))
+		}
+	}
+	for project, target := range c.Notifications.ProjectSlackWebhookURLs {
+		if err := store.ValidateProject(project); err != nil {
+			errs = append(errs, fmt.Errorf("notifications.project_slack_webhook_urls: %w", err))
+		}
+		if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("notifications.project_slack_webhook_urls entry for %q must be an http or https URL", project))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// storeOptions converts the database settings for the store
+func (c Config) storeOptions() store.Options {
+	return store.Options{
+		BusyTimeout:  time.Duration(c.Database.BusyTimeout),
+		MaxOpenConns: c.Database.MaxOpenConns,
+		JournalMode:  c.Database.JournalMode,
+		Synchronous:  c.Database.Synchronous,
+		QueryTimeout: time.Duration(c.Database.QueryTimeout),
+	}
+}
+
+// trustedProxies converts server.trusted_proxies for the handlers, skipping
+// entries Validate rejects
+func (c Config) trustedProxies() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range c.Server.TrustedProxies {
+		if prefix, err := parseTrustedProxy(entry); err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// parseTrustedProxy parses a CIDR range, or an address as a range holding
+// only that address
+func parseTrustedProxy(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// accessLog converts the access log settings for the handlers
+func (c Config) accessLog() handlers.AccessLogConfig {
+	return handlers.AccessLogConfig{
+		SampleRate:   c.Logging.AccessLog.SampleRate,
+		Fields:       c.Logging.AccessLog.Fields,
+		ExcludePaths: c.Logging.AccessLog.ExcludePaths,
+	}
+}
+
+// reloadable returns the settings a running server can change on SIGHUP,
+// other than the log level
+func (c Config) reloadable() handlers.ReloadConfig {
+	return handlers.ReloadConfig{
+		CORS: handlers.CORSConfig{
+			AllowedOrigins: c.CORS.AllowedOrigins,
+			AllowedHeaders: c.CORS.AllowedHeaders,
+		},
+		RateLimit: handlers.RateLimitConfig{
+			RequestsPerSecond: c.RateLimit.RequestsPerSecond,
+			Burst:             c.RateLimit.Burst,
+		},
+		WebhookURLs:   c.Webhooks.URLs,
+		WebhookSecret: c.Webhooks.Secret,
+		Slack: handlers.SlackConfig{
+			WebhookURL:         c.Notifications.SlackWebhookURL,
+			ProjectWebhookURLs: c.Notifications.ProjectSlackWebhookURLs,
+		},
+	}
+}
+
+// redactedSecret replaces secrets in --print-config output
+const redactedSecret = "REDACTED"
+
+// printConfig writes the effective configuration as YAML, with secrets
+// replaced so the output can be shared
+func printConfig(w io.Writer, cfg Config) error {
+	if len(cfg.Auth.APIKeys) > 0 {
+		keys := make(map[string]string, len(cfg.Auth.APIKeys))
+		for name := range cfg.Auth.APIKeys {
+			keys[name] = redactedSecret
+		}
+		cfg.Auth.APIKeys = keys
+	}
+	if cfg.Auth.AdminToken != "" {
+		cfg.Auth.AdminToken = redactedSecret
+	}
+	if cfg.Auth.CSRFSecret != "" {
+		cfg.Auth.CSRFSecret = redactedSecret
+	}
+	if cfg.Webhooks.Secret != "" {
+		cfg.Webhooks.Secret = redactedSecret
+	}
+	// Slack incoming webhook URLs carry the token that allows posting
+	if cfg.Notifications.SlackWebhookURL != "" {
+		cfg.Notifications.SlackWebhookURL = redactedSecret
+	}
+	if len(cfg.Notifications.ProjectSlackWebhookURLs) > 0 {
+		urls := make(map[string]string, len(cfg.Notifications.ProjectSlackWebhookURLs))
+		for project := range cfg.Notifications.ProjectSlackWebhookURLs {
+			urls[project] = redactedSecret
+		}
+		cfg.Notifications.ProjectSlackWebhookURLs = urls
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -2,36 +2,66 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/shahram/prompt-registry/backend/auth"
+	"github.com/shahram/prompt-registry/backend/backup"
+	"github.com/shahram/prompt-registry/backend/gitsync"
 	"github.com/shahram/prompt-registry/backend/handlers"
+	"github.com/shahram/prompt-registry/backend/models"
+	"github.com/shahram/prompt-registry/backend/providers"
 	"github.com/shahram/prompt-registry/backend/store"
 )
 
 func main() {
-	// Initialize logger
-	var logHandler slog.Handler
-	logFormat := getEnv("LOG_FORMAT", "text")
-	logLevel := getEnv("LOG_LEVEL", "info")
+	restorePath := flag.String("restore", "", "replace the database with this backup file and exit")
+	migrateCmd := flag.String("migrate", "", "status, up, down, or a schema version: show or migrate the database schema and exit")
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML config file (CONFIG_FILE)")
+	showConfig := flag.Bool("print-config", false, "print the effective configuration, with secrets redacted, and exit")
+	applyFlags := configFlags(flag.CommandLine)
+	flag.Parse()
 
-	level := slog.LevelInfo
-	switch logLevel {
-	case "debug":
-		level = slog.LevelDebug
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
+	// Configuration: defaults, then the config file, environment, and flags.
+	// SIGHUP loads it again the same way.
+	load := func() (Config, error) {
+		cfg, err := loadConfig(*configPath)
+		if err != nil {
+			return cfg, err
+		}
+		applyFlags(&cfg)
+		return cfg, cfg.Validate()
+	}
+	cfg, err := load()
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
 	}
+	if *showConfig {
+		if err := printConfig(os.Stdout, cfg); err != nil {
+			slog.Error("failed to print configuration", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Initialize logger. The level can change on SIGHUP.
+	var logHandler slog.Handler
+	level := new(slog.LevelVar)
+	level.Set(cfg.Logging.slogLevel())
 
 	opts := &slog.HandlerOptions{Level: level}
-	if logFormat == "json" {
+	if cfg.Logging.Format == "json" {
 		logHandler = slog.NewJSONHandler(os.Stdout, opts)
 	} else {
 		logHandler = slog.NewTextHandler(os.Stdout, opts)
@@ -40,17 +70,15 @@ func main() {
 	logger := slog.New(logHandler)
 	slog.SetDefault(logger)
 
-	// Configuration from environment variables
-	port := getEnv("PORT", "8080")
-	dbPath := getEnv("DATABASE_PATH", "./data/prompts.db")
-	baseURL := getEnv("BASE_URL", "http://localhost:8080")
-
+	dbPath := cfg.Database.Path
 	logger.Info("starting prompt registry server",
-		"port", port,
+		"port", cfg.Server.Port,
 		"database", dbPath,
-		"base_url", baseURL,
-		"log_format", logFormat,
-		"log_level", logLevel,
+		"base_url", cfg.Server.BaseURL,
+		"base_path", handlers.NormalizeBasePath(cfg.Server.BasePath),
+		"log_format", cfg.Logging.Format,
+		"log_level", cfg.Logging.Level,
+		"config_file", *configPath,
 	)
 
 	// Create data directory if needed
@@ -60,63 +88,390 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Offline restore: the server must not be running against this database
+	if *restorePath != "" {
+		result, err := store.RestoreFile(*restorePath, dbPath)
+		if err != nil {
+			logger.Error("failed to restore database", "error", err, "backup", *restorePath)
+			os.Exit(1)
+		}
+		logger.Info("database restored",
+			"backup", result.BackupPath,
+			"path", result.Path,
+			"previous_copy", result.PreviousCopy,
+		)
+		return
+	}
+
+	// Offline migration, e.g. reverting the schema before running an older release
+	if *migrateCmd != "" {
+		if err := runMigrate(os.Stdout, *migrateCmd, dbPath, cfg.storeOptions(), logger); err != nil {
+			logger.Error("failed to migrate database", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Initialize database
-	db, err := store.New(dbPath)
+	db, err := store.New(dbPath, store.WithOptions(cfg.storeOptions()), store.WithLogger(logger))
 	if err != nil {
 		logger.Error("failed to initialize database", "error", err)
 		os.Exit(1)
 	}
 	defer db.Close()
+	var prompts store.Store = db
+	if cfg.Database.CacheSize > 0 {
+		prompts = store.NewCachedStore(db, cfg.Database.CacheSize)
+	}
 
 	// Initialize handlers
-	h := handlers.New(db, logger)
+	h := handlers.New(prompts, logger)
+	db.SetOperationObserver(h.Metrics.ObserveDBOperation)
+	h.BaseURL = cfg.Server.BaseURL
+	h.BasePath = cfg.Server.BasePath
+	h.MaxContentBytes = cfg.Server.MaxContentBytes
+	h.MaxBodyBytes = int64(cfg.Server.MaxBodyBytes)
+	h.MaxTitleLength = cfg.Server.MaxTitleLength
+	h.MaxDescriptionLength = cfg.Server.MaxDescriptionLength
+	h.MaxMetadataBytes = cfg.Server.MaxMetadataBytes
+	h.Readiness = handlers.ReadinessConfig{
+		Timeout:    time.Duration(cfg.Server.ReadyTimeout),
+		WriteProbe: cfg.Server.ReadyWriteProbe,
+	}
+	reloadable := cfg.reloadable()
+	h.CORS = reloadable.CORS
+	h.CSRF = handlers.CSRFConfig{Enabled: cfg.Auth.CSRF, Secret: cfg.Auth.CSRFSecret}
+	h.RateLimit = reloadable.RateLimit
+	h.Webhooks.Configure(reloadable.WebhookURLs, reloadable.WebhookSecret)
+	h.Notifications.Configure(reloadable.Slack)
+	h.Public.Enabled = getEnv("PUBLIC_GALLERY_ENABLED", "false") == "true"
+	h.Public.Prefix = getEnv("PUBLIC_GALLERY_PREFIX", h.Public.Prefix)
+	h.Public.RatePerMinute = getEnvInt("PUBLIC_GALLERY_RATE_LIMIT", h.Public.RatePerMinute)
+	h.Public.Burst = getEnvInt("PUBLIC_GALLERY_BURST", h.Public.Burst)
+	if h.Public.Enabled {
+		logger.Info("public gallery enabled",
+			"prefix", h.Public.Prefix,
+			"rate_per_minute", h.Public.RatePerMinute,
+			"burst", h.Public.Burst,
+		)
+	}
+	if h.RateLimit.RequestsPerSecond > 0 {
+		logger.Info("API rate limiting enabled",
+			"requests_per_second", h.RateLimit.RequestsPerSecond,
+			"burst", h.RateLimit.Burst,
+		)
+	}
+	h.TrustedProxies = cfg.trustedProxies()
+	h.AccessLog = cfg.accessLog()
+	if h.AccessLog.SampleRate > 1 {
+		logger.Info("access log sampling enabled", "sample_rate", h.AccessLog.SampleRate)
+	}
+	if len(h.AccessLog.ExcludePaths) > 0 {
+		logger.Info("access log excludes paths", "paths", h.AccessLog.ExcludePaths)
+	}
+	if err := configureLatencyBudget(&h.LatencyBudget); err != nil {
+		logger.Error("invalid latency SLO configuration", "error", err)
+		os.Exit(1)
+	}
+	for _, slo := range h.LatencyBudget.SLOs {
+		logger.Info("latency SLO enabled",
+			"route", slo.Route,
+			"threshold", slo.Threshold,
+			"objective", slo.Objective,
+			"fast_fail", slo.FastFail,
+		)
+	}
+	authenticator, err := configureAuth(cfg.Auth)
+	if err != nil {
+		logger.Error("invalid auth configuration", "error", err)
+		os.Exit(1)
+	}
+	h.Auth = authenticator
+	h.AuthMethod = cfg.Auth.Method
+	logger.Info("api authentication configured", "method", cfg.Auth.Method)
+	h.AdminToken = cfg.Auth.AdminToken
+	if h.AdminToken != "" {
+		logger.Info("admin endpoints enabled")
+	}
+	if cfg.Server.ReadOnly {
+		h.SetReadOnly(true)
+		logger.Warn("read-only mode enabled: changes are rejected until an admin turns it off")
+	}
+	if len(cfg.Webhooks.URLs) > 0 {
+		logger.Info("global webhooks enabled", "count", len(cfg.Webhooks.URLs))
+	}
+	if cfg.Notifications.SlackWebhookURL != "" || len(cfg.Notifications.ProjectSlackWebhookURLs) > 0 {
+		logger.Info("slack notifications enabled",
+			"default_channel", cfg.Notifications.SlackWebhookURL != "",
+			"projects", len(cfg.Notifications.ProjectSlackWebhookURLs),
+		)
+	}
+	if dir := os.Getenv("GIT_SYNC_DIR"); dir != "" {
+		h.Sync = gitsync.New(gitsync.Config{
+			Dir:         dir,
+			Remote:      os.Getenv("GIT_SYNC_REMOTE"),
+			Branch:      getEnv("GIT_SYNC_BRANCH", "main"),
+			AuthorName:  os.Getenv("GIT_SYNC_AUTHOR_NAME"),
+			AuthorEmail: os.Getenv("GIT_SYNC_AUTHOR_EMAIL"),
+		}, prompts, logger)
+		logger.Info("git sync enabled", "dir", dir, "remote", os.Getenv("GIT_SYNC_REMOTE") != "")
+	}
+	if dir := os.Getenv("BACKUP_DIR"); dir != "" {
+		backups, err := backup.New(backup.Config{
+			Dir:      dir,
+			Schedule: getEnv("BACKUP_SCHEDULE", "@daily"),
+			Keep:     getEnvInt("BACKUP_KEEP", backup.DefaultKeep),
+		}, db, logger)
+		if err != nil {
+			logger.Error("invalid backup configuration", "error", err)
+			os.Exit(1)
+		}
+		backups.Start()
+		defer backups.Stop()
+		h.Backups = backups
+		logger.Info("scheduled backups enabled", "dir", dir, "schedule", backups.Status().Schedule, "keep", backups.Status().Keep)
+	}
+	usageFlush := handlers.DefaultUsageFlushInterval
+	if seconds := getEnvInt("USAGE_FLUSH_SECONDS", 0); seconds > 0 {
+		usageFlush = time.Duration(seconds) * time.Second
+	}
+	h.Usage.Start(usageFlush)
+	h.Retention.Default = models.RetentionPolicy{
+		KeepVersions: getEnvInt("RETENTION_KEEP_VERSIONS", 0),
+		KeepDays:     getEnvInt("RETENTION_KEEP_DAYS", 0),
+	}
+	if err := store.ValidateRetentionPolicy(h.Retention.Default); err != nil {
+		logger.Error("invalid retention configuration", "error", err)
+		os.Exit(1)
+	}
+	h.Retention.DryRun = getEnv("RETENTION_DRY_RUN", "false") == "true"
+	retentionInterval := handlers.DefaultRetentionInterval
+	if minutes := getEnvInt("RETENTION_INTERVAL_MINUTES", 0); minutes > 0 {
+		retentionInterval = time.Duration(minutes) * time.Minute
+	}
+	h.Retention.Start(retentionInterval)
+	defer h.Retention.Stop()
+	logger.Info("version retention enabled",
+		"keep_versions", h.Retention.Default.KeepVersions,
+		"keep_days", h.Retention.Default.KeepDays,
+		"interval", retentionInterval,
+		"dry_run", h.Retention.DryRun,
+	)
+	h.Providers = configureProviders()
+	if h.Providers.Len() > 0 {
+		if name := os.Getenv("DEFAULT_PROVIDER"); name != "" {
+			if err := h.Providers.SetDefault(name); err != nil {
+				logger.Error("invalid DEFAULT_PROVIDER", "error", err)
+				os.Exit(1)
+			}
+		}
+		logger.Info("prompt execution enabled", "providers", h.Providers.Names())
+	}
+
+	embedder, err := configureEmbedder()
+	if err != nil {
+		logger.Error("invalid embeddings configuration", "error", err)
+		os.Exit(1)
+	}
+	if embedder != nil {
+		h.Embeddings = handlers.NewEmbeddingIndexer(embedder, prompts, logger)
+		h.Embeddings.BatchSize = getEnvInt("EMBEDDINGS_BATCH_SIZE", h.Embeddings.BatchSize)
+		h.Embeddings.Pause(h.ReadOnly())
+		embeddingInterval := handlers.DefaultEmbeddingInterval
+		if seconds := getEnvInt("EMBEDDINGS_INTERVAL_SECONDS", 0); seconds > 0 {
+			embeddingInterval = time.Duration(seconds) * time.Second
+		}
+		h.Embeddings.Start(embeddingInterval)
+		defer h.Embeddings.Stop()
+		logger.Info("semantic search enabled",
+			"provider", os.Getenv("EMBEDDINGS_PROVIDER"),
+			"model", embedder.Model(),
+			"interval", embeddingInterval,
+		)
+	}
 
 	// Mount all routes (including frontend)
 	handler := h.Routes()
 
 	// Create HTTP server
 	server := &http.Server{
-		Addr:         ":" + port,
+		Addr:         ":" + cfg.Server.Port,
 		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout),
+	}
+	redirect, err := configureTLS(cfg.Server, server, logger)
+	if err != nil {
+		logger.Error("invalid TLS configuration", "error", err)
+		os.Exit(1)
 	}
 
-	// Start server in a goroutine
-	serverErr := make(chan error, 1)
+	// Start servers in goroutines
+	serverErr := make(chan error, 2)
 	go func() {
-		logger.Info("server listening", "address", server.Addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("server listening", "address", server.Addr, "tls", cfg.Server.tlsEnabled())
+		var err error
+		if cfg.Server.tlsEnabled() {
+			// Empty paths use the autocert certificates in server.TLSConfig
+			err = server.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			serverErr <- err
 		}
 	}()
+	if redirect != nil {
+		go func() {
+			logger.Info("redirecting HTTP to HTTPS", "address", redirect.Addr)
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serverErr <- err
+			}
+		}()
+	}
 
-	// Wait for interrupt signal for graceful shutdown
+	// Wait for interrupt signal for graceful shutdown, reloading the
+	// configuration on SIGHUP in the meantime
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
 
-	select {
-	case err := <-serverErr:
-		logger.Error("server error", "error", err)
-		os.Exit(1)
-	case sig := <-quit:
-		logger.Info("received shutdown signal", "signal", sig.String())
+wait:
+	for {
+		select {
+		case err := <-serverErr:
+			logger.Error("server error", "error", err)
+			os.Exit(1)
+		case <-hup:
+			cfg = reloadConfig(cfg, load, h, level, logger)
+		case sig := <-quit:
+			logger.Info("received shutdown signal", "signal", sig.String())
+			break wait
+		}
 	}
 
 	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
 	defer cancel()
 
 	logger.Info("shutting down server...")
+	if redirect != nil {
+		if err := redirect.Shutdown(ctx); err != nil {
+			logger.Error("redirect server shutdown error", "error", err)
+		}
+	}
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Error("server shutdown error", "error", err)
 		os.Exit(1)
 	}
+	// Let queued integration deliveries finish before the process exits
+	h.Integrations.Wait()
+	// Write fetch counts from the final requests
+	h.Usage.Stop()
 
 	logger.Info("server stopped gracefully")
 }
 
+// configureProviders registers each LLM provider whose credentials are set.
+// The first one registered (openai, anthropic, local) is the default.
+func configureProviders() *providers.Registry {
+	registry := providers.NewRegistry()
+	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
+		registry.Register(providers.NewOpenAICompatible("openai",
+			getEnv("OPENAI_BASE_URL", providers.OpenAIBaseURL),
+			apiKey,
+			getEnv("OPENAI_MODEL", "gpt-4o-mini"),
+		))
+	}
+	if apiKey := os.Getenv("ANTHROPIC_API_KEY"); apiKey != "" {
+		registry.Register(providers.NewAnthropic(
+			getEnv("ANTHROPIC_BASE_URL", providers.AnthropicBaseURL),
+			apiKey,
+			getEnv("ANTHROPIC_MODEL", "claude-3-5-haiku-latest"),
+		))
+	}
+	if baseURL := os.Getenv("LOCAL_LLM_BASE_URL"); baseURL != "" {
+		registry.Register(providers.NewOpenAICompatible("local",
+			baseURL,
+			os.Getenv("LOCAL_LLM_API_KEY"),
+			getEnv("LOCAL_LLM_MODEL", "llama3.1"),
+		))
+	}
+	return registry
+}
+
+// configureEmbedder returns the embedder selected by EMBEDDINGS_PROVIDER,
+// or nil when semantic search is off. Unset settings fall back to the
+// matching completion provider's.
+func configureEmbedder() (providers.Embedder, error) {
+	switch name := os.Getenv("EMBEDDINGS_PROVIDER"); name {
+	case "":
+		return nil, nil
+	case "openai":
+		apiKey := getEnv("EMBEDDINGS_API_KEY", os.Getenv("OPENAI_API_KEY"))
+		if apiKey == "" {
+			return nil, errors.New("EMBEDDINGS_PROVIDER=openai needs EMBEDDINGS_API_KEY or OPENAI_API_KEY")
+		}
+		return providers.NewOpenAIEmbedder(name,
+			getEnv("EMBEDDINGS_BASE_URL", getEnv("OPENAI_BASE_URL", providers.OpenAIBaseURL)),
+			apiKey,
+			getEnv("EMBEDDINGS_MODEL", providers.OpenAIEmbeddingModel),
+		), nil
+	case "local":
+		baseURL := getEnv("EMBEDDINGS_BASE_URL", os.Getenv("LOCAL_LLM_BASE_URL"))
+		if baseURL == "" {
+			return nil, errors.New("EMBEDDINGS_PROVIDER=local needs EMBEDDINGS_BASE_URL or LOCAL_LLM_BASE_URL")
+		}
+		return providers.NewOpenAIEmbedder(name,
+			baseURL,
+			getEnv("EMBEDDINGS_API_KEY", os.Getenv("LOCAL_LLM_API_KEY")),
+			getEnv("EMBEDDINGS_MODEL", "nomic-embed-text"),
+		), nil
+	default:
+		return nil, fmt.Errorf("EMBEDDINGS_PROVIDER %q must be openai or local", name)
+	}
+}
+
+// configureAuth builds the API authenticator selected by auth.method
+func configureAuth(cfg AuthConfig) (auth.Authenticator, error) {
+	config := auth.Config{
+		Method:              cfg.Method,
+		OIDCIssuer:          cfg.OIDCIssuer,
+		OIDCAudience:        cfg.OIDCAudience,
+		MTLSAllowedSubjects: cfg.MTLSAllowedSubjects,
+	}
+	if len(cfg.APIKeys) > 0 {
+		config.APIKeys = make(map[string]string, len(cfg.APIKeys))
+		for name, key := range cfg.APIKeys {
+			config.APIKeys[key] = name
+		}
+	}
+	return auth.New(config)
+}
+
+// configureLatencyBudget reads latency SLOs from LATENCY_SLOS, a
+// comma-separated list of route=threshold:objective[:fast-fail] entries
+func configureLatencyBudget(config *handlers.LatencyBudgetConfig) error {
+	for _, spec := range getEnvList("LATENCY_SLOS") {
+		slo, err := handlers.ParseLatencySLO(spec)
+		if err != nil {
+			return err
+		}
+		config.SLOs = append(config.SLOs, slo)
+	}
+	if window := os.Getenv("LATENCY_SLO_WINDOW"); window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			return fmt.Errorf("invalid LATENCY_SLO_WINDOW: %w", err)
+		}
+		config.Window = d
+	}
+	config.MinRequests = getEnvInt("LATENCY_SLO_MIN_REQUESTS", config.MinRequests)
+	return config.Validate()
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -124,3 +479,28 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvList returns the non-empty entries of a comma-separated environment variable
+func getEnvList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// getEnvInt retrieves an integer environment variable or returns a default value
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("invalid integer environment variable, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/shahram/prompt-registry/backend/store"
+)
+
+// runMigrate carries out --migrate: "status" lists the migrations, "up"
+// applies every pending one, "down" reverts the latest, and a version number
+// migrates up or down to it
+func runMigrate(w io.Writer, command, dbPath string, opts store.Options, logger *slog.Logger) error {
+	status, err := store.MigrationStatus(dbPath, opts)
+	if err != nil {
+		return err
+	}
+	current := 0
+	for _, m := range status {
+		if m.AppliedAt != nil {
+			current = m.Version
+		}
+	}
+
+	var target int
+	switch command {
+	case "status":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED\tREVERSIBLE")
+		for _, m := range status {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = m.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			reversible := "no"
+			if m.Reversible {
+				reversible = "yes"
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", m.Version, m.Name, applied, reversible)
+		}
+		return tw.Flush()
+	case "up":
+		target = store.LatestSchemaVersion()
+	case "down":
+		if current == 0 {
+			return fmt.Errorf("no migrations to revert")
+		}
+		target = current - 1
+	default:
+		if target, err = strconv.Atoi(command); err != nil {
+			return fmt.Errorf("invalid --migrate %q: want status, up, down, or a schema version", command)
+		}
+	}
+
+	result, err := store.MigrateFile(dbPath, opts, target)
+	if err != nil {
+		return err
+	}
+	logger.Info("database migrated",
+		"path", result.Path,
+		"from_version", result.FromVersion,
+		"to_version", result.ToVersion,
+	)
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"github.com/shahram/prompt-registry/backend/handlers"
+)
+
+// slogLevel converts logging.level, which Validate has checked
+func (c LoggingConfig) slogLevel() slog.Level {
+	switch c.Level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// withReloadable returns c with the settings SIGHUP can change taken from next
+func (c Config) withReloadable(next Config) Config {
+	c.Logging.Level = next.Logging.Level
+	c.CORS = next.CORS
+	c.RateLimit = next.RateLimit
+	c.Webhooks = next.Webhooks
+	c.Notifications = next.Notifications
+	return c
+}
+
+// reloadConfig loads the configuration again and applies the log level,
+// CORS, rate limit, webhook, and notification settings to the running server. It returns
+// the configuration now in effect; an invalid configuration changes nothing.
+func reloadConfig(current Config, load func() (Config, error), h *handlers.Handler, level *slog.LevelVar, logger *slog.Logger) Config {
+	next, err := load()
+	if err != nil {
+		logger.Error("configuration reload failed, keeping the current settings", "error", err)
+		return current
+	}
+
+	var changes []string
+	if next.Logging.Level != current.Logging.Level {
+		level.Set(next.Logging.slogLevel())
+		changes = append(changes, fmt.Sprintf("logging.level: %s -> %s", current.Logging.Level, next.Logging.Level))
+	}
+	changes = append(changes, h.Reload(next.reloadable())...)
+
+	if len(changes) == 0 {
+		logger.Info("configuration reloaded, nothing changed")
+	} else {
+		logger.Info("configuration reloaded", "changes", strings.Join(changes, "; "))
+	}
+	if !reflect.DeepEqual(current.withReloadable(next), next) {
+		logger.Warn("configuration has changes that need a restart to take effect")
+	}
+	return current.withReloadable(next)
+}
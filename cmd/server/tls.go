@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsEnabled reports whether the server serves HTTPS, from certificate files
+// or from Let's Encrypt
+func (c ServerConfig) tlsEnabled() bool {
+	return c.TLSCertFile != "" || len(c.TLSAutocertDomains) > 0
+}
+
+// configureTLS sets up server's TLS config from cfg and returns the plain
+// HTTP server that redirects to it, or nil if cfg doesn't ask for one.
+// With autocert the redirect server also answers Let's Encrypt's HTTP-01
+// challenges; without it, certificates are still issued over TLS-ALPN-01 on
+// the HTTPS port.
+func configureTLS(cfg ServerConfig, server *http.Server, logger *slog.Logger) (*http.Server, error) {
+	if !cfg.tlsEnabled() {
+		return nil, nil
+	}
+
+	var manager *autocert.Manager
+	if len(cfg.TLSAutocertDomains) > 0 {
+		if err := os.MkdirAll(cfg.TLSAutocertCacheDir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create autocert cache dir: %w", err)
+		}
+		manager = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLSAutocertDomains...),
+			Cache:      autocert.DirCache(cfg.TLSAutocertCacheDir),
+			Email:      cfg.TLSAutocertEmail,
+		}
+		server.TLSConfig = manager.TLSConfig()
+		logger.Info("automatic certificates enabled", "domains", cfg.TLSAutocertDomains, "cache_dir", cfg.TLSAutocertCacheDir)
+	}
+
+	if caFile := cfg.TLSClientCAFile; caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file %s: %w", caFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client CA file %s contains no certificates", caFile)
+		}
+		if server.TLSConfig == nil {
+			server.TLSConfig = &tls.Config{}
+		}
+		// Certificates are optional at the TLS layer so /health and the
+		// public gallery keep working; the mtls authenticator requires them on the API
+		server.TLSConfig.ClientCAs = pool
+		server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	if cfg.HTTPRedirectPort == "" {
+		return nil, nil
+	}
+	var redirect http.Handler = httpsRedirect(cfg.Port)
+	if manager != nil {
+		redirect = manager.HTTPHandler(redirect)
+	}
+	return &http.Server{
+		Addr:         ":" + cfg.HTTPRedirectPort,
+		Handler:      redirect,
+		ReadTimeout:  server.ReadTimeout,
+		WriteTimeout: server.WriteTimeout,
+		IdleTimeout:  server.IdleTimeout,
+	}, nil
+}
+
+// httpsRedirect sends every request to the same host and path over HTTPS on
+// port. 308 keeps the method and body, so API writes aren't silently turned
+// into GETs.
+func httpsRedirect(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
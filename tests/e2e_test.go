@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
@@ -329,8 +330,10 @@ func TestE2E_CompleteUserFlow(t *testing.T) {
 			t.Errorf("Expected status 200, got %d", resp.StatusCode)
 		}
 
-		body := make([]byte, resp.ContentLength)
-		resp.Body.Read(body)
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to read metrics: %v", err)
+		}
 		metricsText := string(body)
 
 		// Verify metrics exist
@@ -486,9 +489,11 @@ func TestE2E_FrontendServing(t *testing.T) {
 	}
 
 	// Read HTML content
-	body := make([]byte, 10000)
-	n, _ := resp.Body.Read(body)
-	html := string(body[:n])
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	html := string(body)
 
 	// Verify it's HTML
 	if !containsSubstring(html, "<!DOCTYPE html>") && !containsSubstring(html, "<html") {